@@ -111,6 +111,8 @@ func init() {
 	filerS3Options.allowEmptyFolder = cmdFiler.Flag.Bool("s3.allowEmptyFolder", true, "allow empty folders")
 	filerS3Options.allowDeleteBucketNotEmpty = cmdFiler.Flag.Bool("s3.allowDeleteBucketNotEmpty", true, "allow recursive deleting all entries along with bucket")
 	filerS3Options.localSocket = cmdFiler.Flag.String("s3.localSocket", "", "default to /tmp/seaweedfs-s3-<port>.sock")
+	filerS3Options.portWebsite = cmdFiler.Flag.Int("s3.port.website", 0, "static website http listen port, serving the bucket named after the host or {bucket}.{website.domainName}")
+	filerS3Options.websiteDomainName = cmdFiler.Flag.String("s3.website.domainName", "", "suffix of the website host name in comma separated list, {bucket}.{website.domainName}, also served on the s3 port")
 
 	// start webdav on filer
 	filerStartWebDav = cmdFiler.Flag.Bool("webdav", false, "whether to start webdav gateway")
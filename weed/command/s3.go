@@ -55,6 +55,8 @@ type S3Options struct {
 	localFilerSocket          *string
 	dataCenter                *string
 	localSocket               *string
+	portWebsite               *int
+	websiteDomainName         *string
 	certProvider              certprovider.Provider
 }
 
@@ -79,6 +81,8 @@ func init() {
 	s3StandaloneOptions.allowDeleteBucketNotEmpty = cmdS3.Flag.Bool("allowDeleteBucketNotEmpty", true, "allow recursive deleting all entries along with bucket")
 	s3StandaloneOptions.localFilerSocket = cmdS3.Flag.String("localFilerSocket", "", "local filer socket path")
 	s3StandaloneOptions.localSocket = cmdS3.Flag.String("localSocket", "", "default to /tmp/seaweedfs-s3-<port>.sock")
+	s3StandaloneOptions.portWebsite = cmdS3.Flag.Int("port.website", 0, "static website http listen port, serving the bucket named after the host or {bucket}.{website.domainName}")
+	s3StandaloneOptions.websiteDomainName = cmdS3.Flag.String("website.domainName", "", "suffix of the website host name in comma separated list, {bucket}.{website.domainName}, also served on the s3 port")
 }
 
 var cmdS3 = &Command{
@@ -231,6 +235,7 @@ func (s3opt *S3Options) startS3Server() bool {
 		LocalFilerSocket:          localFilerSocket,
 		DataCenter:                *s3opt.dataCenter,
 		FilerGroup:                filerGroup,
+		WebsiteDomainName:         *s3opt.websiteDomainName,
 	})
 	if s3ApiServer_err != nil {
 		glog.Fatalf("S3 API Server startup error: %v", s3ApiServer_err)
@@ -269,6 +274,29 @@ func (s3opt *S3Options) startS3Server() bool {
 		glog.Fatalf("S3 API Server listener on %s error: %v", listenAddress, err)
 	}
 
+	if *s3opt.portWebsite > 0 {
+		websiteRouter := mux.NewRouter().SkipClean(true)
+		s3ApiServer.RegisterWebsiteRouter(websiteRouter)
+		websiteListener, websiteLocalListener, err := util.NewIpAndLocalListeners(*s3opt.bindIp, *s3opt.portWebsite, time.Duration(10)*time.Second)
+		if err != nil {
+			glog.Fatalf("S3 website listener on %s:%d error: %v", *s3opt.bindIp, *s3opt.portWebsite, err)
+		}
+		websiteS := &http.Server{Handler: websiteRouter}
+		glog.V(0).Infof("Start Seaweed S3 website Server %s at http port %d", util.Version(), *s3opt.portWebsite)
+		if websiteLocalListener != nil {
+			go func() {
+				if err := websiteS.Serve(websiteLocalListener); err != nil {
+					glog.Fatalf("S3 website Server Fail to serve: %v", err)
+				}
+			}()
+		}
+		go func() {
+			if err := websiteS.Serve(websiteListener); err != nil {
+				glog.Fatalf("S3 website Server Fail to serve: %v", err)
+			}
+		}()
+	}
+
 	if len(*s3opt.auditLogConfig) > 0 {
 		s3err.InitAuditLog(*s3opt.auditLogConfig)
 		if s3err.Logger != nil {
@@ -139,6 +139,11 @@ func init() {
 	serverOptions.v.inflightUploadDataTimeout = cmdServer.Flag.Duration("volume.inflightUploadDataTimeout", 60*time.Second, "inflight upload data wait timeout of volume servers")
 	serverOptions.v.hasSlowRead = cmdServer.Flag.Bool("volume.hasSlowRead", true, "<experimental> if true, this prevents slow reads from blocking other requests, but large file read P99 latency will increase.")
 	serverOptions.v.readBufferSizeMB = cmdServer.Flag.Int("volume.readBufferSizeMB", 4, "<experimental> larger values can optimize query performance but will increase some memory usage,Use with hasSlowRead normally")
+	serverOptions.v.scrubInterval = cmdServer.Flag.Duration("volume.scrubInterval", 7*24*time.Hour, "verify the checksum of every needle and repair the corrupt ones once per interval, 0 to disable")
+	serverOptions.v.scrubMBPerSecond = cmdServer.Flag.Int("volume.scrubMBps", 8, "limit background scrubbing speed in mega bytes per second")
+	serverOptions.v.writeConsistency = cmdServer.Flag.String("volume.writeConsistency", "all", "copies to write before acknowledging a replicated write: all, quorum or one, optionally overridden per collection, e.g. quorum,logs=one")
+	serverOptions.v.remoteCacheDir = cmdServer.Flag.String("volume.remoteCache.dir", "", "directory to cache the blocks read from the remote tiered volumes, default to remote_cache in the first -dir")
+	serverOptions.v.remoteCacheSizeMB = cmdServer.Flag.Int("volume.remoteCache.sizeMB", 0, "limit the disk space of the remote tiered volume block cache, 0 to disable")
 
 	s3Options.port = cmdServer.Flag.Int("s3.port", 8333, "s3 server http listen port")
 	s3Options.portHttps = cmdServer.Flag.Int("s3.port.https", 0, "s3 server https listen port")
@@ -154,6 +159,8 @@ func init() {
 	s3Options.allowEmptyFolder = cmdServer.Flag.Bool("s3.allowEmptyFolder", true, "allow empty folders")
 	s3Options.allowDeleteBucketNotEmpty = cmdServer.Flag.Bool("s3.allowDeleteBucketNotEmpty", true, "allow recursive deleting all entries along with bucket")
 	s3Options.localSocket = cmdServer.Flag.String("s3.localSocket", "", "default to /tmp/seaweedfs-s3-<port>.sock")
+	s3Options.portWebsite = cmdServer.Flag.Int("s3.port.website", 0, "static website http listen port, serving the bucket named after the host or {bucket}.{website.domainName}")
+	s3Options.websiteDomainName = cmdServer.Flag.String("s3.website.domainName", "", "suffix of the website host name in comma separated list, {bucket}.{website.domainName}, also served on the s3 port")
 
 	iamOptions.port = cmdServer.Flag.Int("iam.port", 8111, "iam server http listen port")
 
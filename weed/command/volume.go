@@ -5,6 +5,7 @@ import (
 	"net/http"
 	httppprof "net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime/pprof"
 	"strconv"
 	"strings"
@@ -29,6 +30,8 @@ import (
 	weed_server "github.com/seaweedfs/seaweedfs/weed/server"
 	stats_collect "github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
@@ -70,6 +73,11 @@ type VolumeServerOptions struct {
 	hasSlowRead               *bool
 	readBufferSizeMB          *int
 	ldbTimeout                *int64
+	scrubInterval             *time.Duration
+	scrubMBPerSecond          *int
+	writeConsistency          *string
+	remoteCacheDir            *string
+	remoteCacheSizeMB         *int
 }
 
 func init() {
@@ -103,6 +111,11 @@ func init() {
 	v.inflightUploadDataTimeout = cmdVolume.Flag.Duration("inflightUploadDataTimeout", 60*time.Second, "inflight upload data wait timeout of volume servers")
 	v.hasSlowRead = cmdVolume.Flag.Bool("hasSlowRead", true, "<experimental> if true, this prevents slow reads from blocking other requests, but large file read P99 latency will increase.")
 	v.readBufferSizeMB = cmdVolume.Flag.Int("readBufferSizeMB", 4, "<experimental> larger values can optimize query performance but will increase some memory usage,Use with hasSlowRead normally.")
+	v.scrubInterval = cmdVolume.Flag.Duration("scrubInterval", 7*24*time.Hour, "verify the checksum of every needle and repair the corrupt ones once per interval, 0 to disable")
+	v.scrubMBPerSecond = cmdVolume.Flag.Int("scrubMBps", 8, "limit background scrubbing speed in mega bytes per second")
+	v.writeConsistency = cmdVolume.Flag.String("writeConsistency", "all", "copies to write before acknowledging a replicated write: all, quorum or one, optionally overridden per collection, e.g. quorum,logs=one")
+	v.remoteCacheDir = cmdVolume.Flag.String("remoteCache.dir", "", "directory to cache the blocks read from the remote tiered volumes, default to remote_cache in the first -dir")
+	v.remoteCacheSizeMB = cmdVolume.Flag.Int("remoteCache.sizeMB", 0, "limit the disk space of the remote tiered volume block cache, 0 to disable")
 }
 
 var cmdVolume = &Command{
@@ -237,6 +250,28 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		volumeNeedleMapKind = storage.NeedleMapLevelDbLarge
 	}
 
+	writeConsistency, err := topology.ParseWriteConsistencyConfig(*v.writeConsistency)
+	if err != nil {
+		glog.Fatalf("invalid -writeConsistency: %v", err)
+	}
+
+	if *v.remoteCacheSizeMB > 0 {
+		remoteCacheDir := *v.remoteCacheDir
+		if remoteCacheDir == "" {
+			remoteCacheDir = filepath.Join(v.folders[0], "remote_cache")
+		}
+		remoteCacheDir = filepath.Clean(util.ResolvePath(remoteCacheDir))
+		for _, folder := range append([]string{*v.idxFolder}, v.folders...) {
+			if folder != "" && filepath.Clean(util.ResolvePath(folder)) == remoteCacheDir {
+				glog.Fatalf("remote block cache dir %s is also a volume folder", remoteCacheDir)
+			}
+		}
+		backend.RemoteBlockCache, err = backend.NewBlockCache(remoteCacheDir, int64(*v.remoteCacheSizeMB)*1024*1024)
+		if err != nil {
+			glog.Fatalf("remote block cache: %v", err)
+		}
+	}
+
 	volumeServer := weed_server.NewVolumeServer(volumeMux, publicVolumeMux,
 		*v.ip, *v.port, *v.portGrpc, *v.publicUrl,
 		v.folders, v.folderMaxLimits, minFreeSpaces, diskTypes,
@@ -253,6 +288,9 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		*v.hasSlowRead,
 		*v.readBufferSizeMB,
 		*v.ldbTimeout,
+		*v.scrubInterval,
+		*v.scrubMBPerSecond,
+		writeConsistency,
 	)
 	// starting grpc server
 	grpcS := v.startGrpcService(volumeServer)
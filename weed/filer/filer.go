@@ -239,7 +239,7 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 		glog.V(4).Infof("UpdateEntry %s: old entry: %v", entry.FullPath, oldEntry.Name())
 		if err := f.UpdateEntry(ctx, oldEntry, entry); err != nil {
 			glog.Errorf("update entry %s: %v", entry.FullPath, err)
-			return fmt.Errorf("update entry %s: %v", entry.FullPath, err)
+			return fmt.Errorf("update entry %s: %w", entry.FullPath, err)
 		}
 	}
 
@@ -321,6 +321,10 @@ func (f *Filer) UpdateEntry(ctx context.Context, oldEntry, entry *Entry) (err er
 			glog.Errorf("existing %s is a file", oldEntry.FullPath)
 			return fmt.Errorf("existing %s is a file", oldEntry.FullPath)
 		}
+		if err := checkObjectLockUpdate(oldEntry, entry, isObjectLockRelaxed(ctx)); err != nil {
+			glog.Errorf("update entry %s: %v", oldEntry.FullPath, err)
+			return err
+		}
 	}
 	return f.Store.UpdateEntry(ctx, entry)
 }
@@ -345,7 +349,7 @@ func (f *Filer) FindEntry(ctx context.Context, p util.FullPath) (entry *Entry, e
 	}
 	entry, err = f.Store.FindEntry(ctx, p)
 	if entry != nil && entry.TtlSec > 0 {
-		if entry.Crtime.Add(time.Duration(entry.TtlSec) * time.Second).Before(time.Now()) {
+		if isExpired(entry, time.Now()) {
 			f.Store.DeleteOneEntry(ctx, entry)
 			return nil, filer_pb.ErrNotFound
 		}
@@ -361,7 +365,7 @@ func (f *Filer) doListDirectoryEntries(ctx context.Context, p util.FullPath, sta
 			return false
 		default:
 			if entry.TtlSec > 0 {
-				if entry.Crtime.Add(time.Duration(entry.TtlSec) * time.Second).Before(time.Now()) {
+				if isExpired(entry, time.Now()) {
 					f.Store.DeleteOneEntry(ctx, entry)
 					expiredCount++
 					return true
@@ -376,6 +380,11 @@ func (f *Filer) doListDirectoryEntries(ctx context.Context, p util.FullPath, sta
 	return
 }
 
+// isExpired tells whether the ttl of the entry has passed, while entries under object lock never expire
+func isExpired(entry *Entry, now time.Time) bool {
+	return entry.Crtime.Add(time.Duration(entry.TtlSec)*time.Second).Before(now) && !IsObjectLocked(entry.Extended, now)
+}
+
 func (f *Filer) Shutdown() {
 	f.LocalMetaLogBuffer.ShutdownLogBuffer()
 	f.Store.Shutdown()
@@ -0,0 +1,32 @@
+package filer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ErrPreconditionFailed is returned when the If-Match or If-None-Match condition of a write does not hold
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// CheckWritePreconditions evaluates the If-Match and If-None-Match conditions of a write,
+// against the etag of the existing file, or "" if there is none.
+// "If-None-Match: *" only allows creating a new file.
+func CheckWritePreconditions(ifMatch, ifNoneMatch string, etag string) error {
+	if ifMatch != "" && (etag == "" || !util.MatchETag(ifMatch, etag)) {
+		return fmt.Errorf("if-match %s: %w", ifMatch, ErrPreconditionFailed)
+	}
+	if ifNoneMatch != "" && etag != "" && util.MatchETag(ifNoneMatch, etag) {
+		return fmt.Errorf("if-none-match %s: %w", ifNoneMatch, ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// ETagOfFile returns the etag used by write preconditions, "" for a missing entry or a directory
+func ETagOfFile(entry *Entry) string {
+	if entry == nil || entry.IsDirectory() {
+		return ""
+	}
+	return ETagEntry(entry)
+}
@@ -0,0 +1,24 @@
+package filer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckWritePreconditions(t *testing.T) {
+	etag := "0cc175b9c0f1b6a831c399e269772661"
+
+	assert.NoError(t, CheckWritePreconditions("", "", etag))
+	assert.NoError(t, CheckWritePreconditions(`"`+etag+`"`, "", etag))
+	assert.NoError(t, CheckWritePreconditions(`"abc", "`+etag+`"`, "", etag))
+	assert.NoError(t, CheckWritePreconditions("*", "", etag))
+	assert.True(t, errors.Is(CheckWritePreconditions(`"abc"`, "", etag), ErrPreconditionFailed))
+	assert.True(t, errors.Is(CheckWritePreconditions("*", "", ""), ErrPreconditionFailed), "if-match on a missing file")
+
+	assert.NoError(t, CheckWritePreconditions("", "*", ""), "create only")
+	assert.True(t, errors.Is(CheckWritePreconditions("", "*", etag), ErrPreconditionFailed))
+	assert.True(t, errors.Is(CheckWritePreconditions("", `W/"`+etag+`"`, etag), ErrPreconditionFailed))
+	assert.NoError(t, CheckWritePreconditions("", `"abc"`, etag))
+}
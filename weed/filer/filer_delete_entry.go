@@ -26,6 +26,12 @@ func (f *Filer) DeleteEntryMetaAndData(ctx context.Context, p util.FullPath, isR
 	if findErr != nil {
 		return findErr
 	}
+	// a renamed entry keeps its object lock at the new path, checked by CheckObjectLockBeforeRename
+	if ctx.Value("OP") != "MV" {
+		if lockErr := f.checkObjectLockBeforeDelete(ctx, entry, isRecursive); lockErr != nil {
+			return lockErr
+		}
+	}
 	isDeleteCollection := f.isBucket(entry)
 	if entry.IsDirectory() {
 		// delete the folder children, not including the folder itself
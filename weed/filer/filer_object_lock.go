@@ -0,0 +1,210 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ErrObjectLocked is returned when deleting or overwriting an entry protected by S3 object lock
+var ErrObjectLocked = errors.New("object is locked")
+
+// ObjectLockRetention returns the retention mode and retain-until date kept in the entry extended attributes
+func ObjectLockRetention(extended map[string][]byte) (mode string, retainUntil time.Time) {
+	mode = string(extended[s3_constants.AmzObjectLockMode])
+	if mode == "" {
+		return "", time.Time{}
+	}
+	retainUntil, err := time.Parse(time.RFC3339, string(extended[s3_constants.AmzObjectLockRetainUntilDate]))
+	if err != nil {
+		return "", time.Time{}
+	}
+	return mode, retainUntil
+}
+
+// IsObjectLegalHold tells whether a legal hold is placed on the entry
+func IsObjectLegalHold(extended map[string][]byte) bool {
+	return string(extended[s3_constants.AmzObjectLockLegalHold]) == s3_constants.ObjectLockLegalHoldOn
+}
+
+// IsObjectLocked tells whether the entry is under legal hold or an unexpired retention period
+func IsObjectLocked(extended map[string][]byte, now time.Time) bool {
+	if len(extended) == 0 {
+		return false
+	}
+	if IsObjectLegalHold(extended) {
+		return true
+	}
+	mode, retainUntil := ObjectLockRetention(extended)
+	return mode != "" && now.Before(retainUntil)
+}
+
+type relaxObjectLockKey struct{}
+
+// WithObjectLockRelaxed lets the updates in the context remove a legal hold or shorten a governance retention,
+// once the S3 gateway has authorized it
+func WithObjectLockRelaxed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, relaxObjectLockKey{}, true)
+}
+
+func isObjectLockRelaxed(ctx context.Context) bool {
+	relaxed, _ := ctx.Value(relaxObjectLockKey{}).(bool)
+	return relaxed
+}
+
+// checkObjectLockUpdate refuses content or ttl changes of locked entries, and any weakening of their locks.
+// Compliance retention can only be extended. Removing a legal hold or shortening a governance retention
+// is only accepted when relaxed, after the S3 gateway checked the permissions.
+func checkObjectLockUpdate(oldEntry, newEntry *Entry, relaxed bool) error {
+	if oldEntry.IsDirectory() {
+		return nil
+	}
+	now := time.Now()
+	if !IsObjectLocked(oldEntry.Extended, now) {
+		return nil
+	}
+	if !isSameContent(oldEntry, newEntry) {
+		return fmt.Errorf("overwrite %s: %w", oldEntry.FullPath, ErrObjectLocked)
+	}
+	if oldEntry.TtlSec != newEntry.TtlSec || !oldEntry.Crtime.Equal(newEntry.Crtime) {
+		// the ttl would expire the entry
+		return fmt.Errorf("change ttl of %s: %w", oldEntry.FullPath, ErrObjectLocked)
+	}
+	if IsObjectLegalHold(oldEntry.Extended) && !IsObjectLegalHold(newEntry.Extended) && !relaxed {
+		return fmt.Errorf("remove legal hold of %s: %w", oldEntry.FullPath, ErrObjectLocked)
+	}
+	oldMode, oldRetainUntil := ObjectLockRetention(oldEntry.Extended)
+	if oldMode == "" || !now.Before(oldRetainUntil) {
+		return nil
+	}
+	newMode, newRetainUntil := ObjectLockRetention(newEntry.Extended)
+	weakened := newMode == "" || newRetainUntil.Before(oldRetainUntil)
+	switch oldMode {
+	case s3_constants.ObjectLockModeCompliance:
+		if weakened || newMode != s3_constants.ObjectLockModeCompliance {
+			return fmt.Errorf("shorten compliance retention of %s: %w", oldEntry.FullPath, ErrObjectLocked)
+		}
+	default:
+		if weakened && !relaxed {
+			return fmt.Errorf("shorten governance retention of %s: %w", oldEntry.FullPath, ErrObjectLocked)
+		}
+	}
+	return nil
+}
+
+func isSameContent(oldEntry, newEntry *Entry) bool {
+	if oldEntry.FileSize != newEntry.FileSize || !bytes.Equal(oldEntry.Content, newEntry.Content) {
+		return false
+	}
+	oldChunks, newChunks := oldEntry.GetChunks(), newEntry.GetChunks()
+	if len(oldChunks) != len(newChunks) {
+		return false
+	}
+	for i, chunk := range oldChunks {
+		if chunk.GetFileIdString() != newChunks[i].GetFileIdString() {
+			return false
+		}
+	}
+	return true
+}
+
+// checkObjectLockBeforeDelete refuses to delete a locked entry, or a folder containing one.
+// Locked entries only stay in the buckets with object lock enabled, so only their folders are searched.
+func (f *Filer) checkObjectLockBeforeDelete(ctx context.Context, entry *Entry, isRecursive bool) error {
+	now := time.Now()
+	if !entry.IsDirectory() {
+		if IsObjectLocked(entry.Extended, now) {
+			return fmt.Errorf("delete %s: %w", entry.FullPath, ErrObjectLocked)
+		}
+		return nil
+	}
+	if !isRecursive {
+		// a folder with children is not deleted anyway
+		return nil
+	}
+	checkFolder := func(dir util.FullPath) error {
+		return f.walkEntries(ctx, dir, func(sub *Entry) error {
+			if !sub.IsDirectory() && IsObjectLocked(sub.Extended, now) {
+				return fmt.Errorf("delete %s: %w", sub.FullPath, ErrObjectLocked)
+			}
+			return nil
+		})
+	}
+	if bucket := f.DetectBucket(entry.FullPath); bucket != "" {
+		if !f.isObjectLockEnabled(ctx, bucket) {
+			return nil
+		}
+		return checkFolder(entry.FullPath)
+	}
+	if bucketsDir := util.FullPath(f.DirBucketsPath); bucketsDir != entry.FullPath && !bucketsDir.IsUnder(entry.FullPath) {
+		return nil
+	}
+	// the folder contains all the buckets
+	return f.listAllEntries(ctx, util.FullPath(f.DirBucketsPath), func(bucketEntry *Entry) error {
+		if !bucketEntry.IsDirectory() || !isObjectLockConfigured(bucketEntry) {
+			return nil
+		}
+		return checkFolder(bucketEntry.FullPath)
+	})
+}
+
+// CheckObjectLockBeforeRename refuses to move locked entries, unless they stay in the same bucket with object lock enabled
+func (f *Filer) CheckObjectLockBeforeRename(ctx context.Context, entry *Entry, newPath util.FullPath) error {
+	bucket := f.DetectBucket(entry.FullPath)
+	if bucket != "" && bucket == f.DetectBucket(newPath) && f.isObjectLockEnabled(ctx, bucket) {
+		return nil
+	}
+	if err := f.checkObjectLockBeforeDelete(ctx, entry, true); err != nil {
+		return fmt.Errorf("move %s: %w", entry.FullPath, err)
+	}
+	return nil
+}
+
+func (f *Filer) isObjectLockEnabled(ctx context.Context, bucket string) bool {
+	bucketEntry, err := f.FindEntry(ctx, util.NewFullPath(f.DirBucketsPath, bucket))
+	if err != nil {
+		return false
+	}
+	return isObjectLockConfigured(bucketEntry)
+}
+
+func isObjectLockConfigured(bucketEntry *Entry) bool {
+	_, found := bucketEntry.Extended[s3_constants.ExtObjectLockConfigKey]
+	return found
+}
+
+func (f *Filer) listAllEntries(ctx context.Context, dir util.FullPath, fn func(entry *Entry) error) error {
+	lastFileName := ""
+	for {
+		entries, _, err := f.ListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "")
+		if err != nil {
+			return fmt.Errorf("list folder %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			if err = fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(entries) < PaginationSize {
+			return nil
+		}
+	}
+}
+
+func (f *Filer) walkEntries(ctx context.Context, dir util.FullPath, fn func(entry *Entry) error) error {
+	return f.listAllEntries(ctx, dir, func(entry *Entry) error {
+		if err := fn(entry); err != nil {
+			return err
+		}
+		if entry.IsDirectory() {
+			return f.walkEntries(ctx, entry.FullPath, fn)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+package filer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func lockedEntry(mode string, retainUntil time.Time, legalHold string) *Entry {
+	entry := &Entry{
+		FullPath: "/buckets/b/obj",
+		Attr:     Attr{FileSize: 3},
+		Chunks:   []*filer_pb.FileChunk{{FileId: "1,abc", Size: 3}},
+		Extended: map[string][]byte{},
+	}
+	if mode != "" {
+		entry.Extended[s3_constants.AmzObjectLockMode] = []byte(mode)
+		entry.Extended[s3_constants.AmzObjectLockRetainUntilDate] = []byte(retainUntil.UTC().Format(time.RFC3339))
+	}
+	if legalHold != "" {
+		entry.Extended[s3_constants.AmzObjectLockLegalHold] = []byte(legalHold)
+	}
+	return entry
+}
+
+func TestIsObjectLocked(t *testing.T) {
+	now := time.Now()
+	assert.False(t, IsObjectLocked(nil, now))
+	assert.True(t, IsObjectLocked(lockedEntry(s3_constants.ObjectLockModeGovernance, now.Add(time.Hour), "").Extended, now))
+	assert.False(t, IsObjectLocked(lockedEntry(s3_constants.ObjectLockModeCompliance, now.Add(-time.Hour), "").Extended, now))
+	assert.True(t, IsObjectLocked(lockedEntry("", now, s3_constants.ObjectLockLegalHoldOn).Extended, now))
+	assert.False(t, IsObjectLocked(lockedEntry("", now, s3_constants.ObjectLockLegalHoldOff).Extended, now))
+}
+
+func TestCheckObjectLockUpdate(t *testing.T) {
+	retainUntil := time.Now().Add(time.Hour)
+	compliance := lockedEntry(s3_constants.ObjectLockModeCompliance, retainUntil, "")
+
+	// overwriting the content is refused
+	overwrite := lockedEntry(s3_constants.ObjectLockModeCompliance, retainUntil, "")
+	overwrite.Chunks = []*filer_pb.FileChunk{{FileId: "2,def", Size: 3}}
+	assert.True(t, errors.Is(checkObjectLockUpdate(compliance, overwrite, true), ErrObjectLocked))
+
+	// compliance retention can be extended, but not shortened or removed, even when relaxed
+	assert.NoError(t, checkObjectLockUpdate(compliance, lockedEntry(s3_constants.ObjectLockModeCompliance, retainUntil.Add(time.Hour), ""), false))
+	assert.True(t, errors.Is(checkObjectLockUpdate(compliance, lockedEntry(s3_constants.ObjectLockModeCompliance, retainUntil.Add(-time.Minute), ""), true), ErrObjectLocked))
+	assert.True(t, errors.Is(checkObjectLockUpdate(compliance, lockedEntry("", retainUntil, ""), true), ErrObjectLocked))
+
+	// governance retention can be extended, but only shortened or removed when relaxed by the S3 gateway
+	governance := lockedEntry(s3_constants.ObjectLockModeGovernance, retainUntil, "")
+	assert.NoError(t, checkObjectLockUpdate(governance, lockedEntry(s3_constants.ObjectLockModeCompliance, retainUntil.Add(time.Hour), ""), false))
+	assert.True(t, errors.Is(checkObjectLockUpdate(governance, lockedEntry("", retainUntil, ""), false), ErrObjectLocked))
+	assert.True(t, errors.Is(checkObjectLockUpdate(governance, lockedEntry(s3_constants.ObjectLockModeGovernance, retainUntil.Add(-time.Minute), ""), false), ErrObjectLocked))
+	assert.NoError(t, checkObjectLockUpdate(governance, lockedEntry("", retainUntil, ""), true))
+
+	// a legal hold is only removed when relaxed by the S3 gateway
+	legalHold := lockedEntry("", retainUntil, s3_constants.ObjectLockLegalHoldOn)
+	assert.True(t, errors.Is(checkObjectLockUpdate(legalHold, lockedEntry("", retainUntil, s3_constants.ObjectLockLegalHoldOff), false), ErrObjectLocked))
+	assert.NoError(t, checkObjectLockUpdate(legalHold, lockedEntry("", retainUntil, s3_constants.ObjectLockLegalHoldOff), true))
+
+	// a ttl would expire the locked entry
+	withTtl := lockedEntry("", retainUntil, s3_constants.ObjectLockLegalHoldOn)
+	withTtl.TtlSec = 60
+	assert.True(t, errors.Is(checkObjectLockUpdate(legalHold, withTtl, true), ErrObjectLocked))
+
+	// expired retention does not protect anything
+	expired := lockedEntry(s3_constants.ObjectLockModeCompliance, time.Now().Add(-time.Hour), "")
+	assert.NoError(t, checkObjectLockUpdate(expired, overwrite, false))
+}
+
+func TestLockedEntryDoesNotExpire(t *testing.T) {
+	now := time.Now()
+	entry := lockedEntry(s3_constants.ObjectLockModeGovernance, now.Add(time.Hour), "")
+	entry.Crtime = now.Add(-time.Hour)
+	entry.TtlSec = 60
+	assert.False(t, isExpired(entry, now))
+	entry.Extended = nil
+	assert.True(t, isExpired(entry, now))
+}
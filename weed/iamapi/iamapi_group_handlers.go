@@ -0,0 +1,211 @@
+package iamapi
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+)
+
+func findGroup(s3cfg *iam_pb.S3ApiConfiguration, groupName string) *iam_pb.Group {
+	for _, group := range s3cfg.Groups {
+		if group.Name == groupName {
+			return group
+		}
+	}
+	return nil
+}
+
+func newIamGroup(group *iam_pb.Group) *iam.Group {
+	groupName, path := group.Name, "/"
+	arn := fmt.Sprintf("arn:aws:iam:::group/%s", group.Name)
+	groupId := Hash(&arn)
+	return &iam.Group{GroupName: &groupName, GroupId: &groupId, Arn: &arn, Path: &path}
+}
+
+func hasUser(s3cfg *iam_pb.S3ApiConfiguration, userName string) bool {
+	for _, ident := range s3cfg.Identities {
+		if ident.Name == userName {
+			return true
+		}
+	}
+	return false
+}
+
+// renameGroupMember follows the renaming of a user, or removes the deleted user with an empty new name
+func renameGroupMember(s3cfg *iam_pb.S3ApiConfiguration, userName, newUserName string) {
+	for _, group := range s3cfg.Groups {
+		for i, member := range group.Members {
+			if member != userName {
+				continue
+			}
+			if newUserName == "" {
+				group.Members = append(group.Members[:i], group.Members[i+1:]...)
+			} else {
+				group.Members[i] = newUserName
+			}
+			break
+		}
+	}
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_CreateGroup.html
+func (iama *IamApiServer) CreateGroup(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreateGroupResponse, err error) {
+	groupName := values.Get("GroupName")
+	if groupName == "" {
+		return resp, newIamError(iam.ErrCodeInvalidInputException, "GroupName is required.")
+	}
+	if findGroup(s3cfg, groupName) != nil {
+		return resp, newIamError(iam.ErrCodeEntityAlreadyExistsException, "Group with name %s already exists.", groupName)
+	}
+	group := &iam_pb.Group{Name: groupName}
+	s3cfg.Groups = append(s3cfg.Groups, group)
+	resp.CreateGroupResult.Group = *newIamGroup(group)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetGroup.html
+func (iama *IamApiServer) GetGroup(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetGroupResponse, err error) {
+	groupName := values.Get("GroupName")
+	group := findGroup(s3cfg, groupName)
+	if group == nil {
+		return resp, newNoSuchEntityError("group", groupName)
+	}
+	resp.GetGroupResult.Group = *newIamGroup(group)
+	for _, member := range group.Members {
+		userName := member
+		resp.GetGroupResult.Users = append(resp.GetGroupResult.Users, &iam.User{UserName: &userName})
+	}
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListGroups.html
+func (iama *IamApiServer) ListGroups(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListGroupsResponse) {
+	for _, group := range s3cfg.Groups {
+		resp.ListGroupsResult.Groups = append(resp.ListGroupsResult.Groups, newIamGroup(group))
+	}
+	return resp
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_UpdateGroup.html
+func (iama *IamApiServer) UpdateGroup(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp UpdateGroupResponse, err error) {
+	groupName := values.Get("GroupName")
+	group := findGroup(s3cfg, groupName)
+	if group == nil {
+		return resp, newNoSuchEntityError("group", groupName)
+	}
+	if newGroupName := values.Get("NewGroupName"); newGroupName != "" && newGroupName != groupName {
+		if findGroup(s3cfg, newGroupName) != nil {
+			return resp, newIamError(iam.ErrCodeEntityAlreadyExistsException, "Group with name %s already exists.", newGroupName)
+		}
+		group.Name = newGroupName
+	}
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DeleteGroup.html
+func (iama *IamApiServer) DeleteGroup(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeleteGroupResponse, err error) {
+	groupName := values.Get("GroupName")
+	for i, group := range s3cfg.Groups {
+		if group.Name == groupName {
+			if len(group.Members) > 0 || len(group.AttachedPolicies) > 0 || len(group.InlinePolicies) > 0 {
+				return resp, newIamError(iam.ErrCodeDeleteConflictException, "Cannot delete a group with members or policies.")
+			}
+			s3cfg.Groups = append(s3cfg.Groups[:i], s3cfg.Groups[i+1:]...)
+			return resp, nil
+		}
+	}
+	return resp, newNoSuchEntityError("group", groupName)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_AddUserToGroup.html
+func (iama *IamApiServer) AddUserToGroup(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp AddUserToGroupResponse, err error) {
+	groupName, userName := values.Get("GroupName"), values.Get("UserName")
+	group := findGroup(s3cfg, groupName)
+	if group == nil {
+		return resp, newNoSuchEntityError("group", groupName)
+	}
+	if !hasUser(s3cfg, userName) {
+		return resp, newNoSuchEntityError("user", userName)
+	}
+	for _, member := range group.Members {
+		if member == userName {
+			return resp, nil
+		}
+	}
+	group.Members = append(group.Members, userName)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_RemoveUserFromGroup.html
+func (iama *IamApiServer) RemoveUserFromGroup(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp RemoveUserFromGroupResponse, err error) {
+	groupName, userName := values.Get("GroupName"), values.Get("UserName")
+	group := findGroup(s3cfg, groupName)
+	if group == nil {
+		return resp, newNoSuchEntityError("group", groupName)
+	}
+	for i, member := range group.Members {
+		if member == userName {
+			group.Members = append(group.Members[:i], group.Members[i+1:]...)
+			return resp, nil
+		}
+	}
+	return resp, newNoSuchEntityError("user", userName)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListGroupsForUser.html
+func (iama *IamApiServer) ListGroupsForUser(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListGroupsForUserResponse, err error) {
+	userName := values.Get("UserName")
+	if !hasUser(s3cfg, userName) {
+		return resp, newNoSuchEntityError("user", userName)
+	}
+	for _, group := range s3cfg.Groups {
+		for _, member := range group.Members {
+			if member == userName {
+				resp.ListGroupsForUserResult.Groups = append(resp.ListGroupsForUserResult.Groups, newIamGroup(group))
+				break
+			}
+		}
+	}
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_PutGroupPolicy.html
+func (iama *IamApiServer) PutGroupPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp PutGroupPolicyResponse, err error) {
+	return resp, putInlinePolicy(s3cfg, "group", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetGroupPolicy.html
+func (iama *IamApiServer) GetGroupPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetGroupPolicyResponse, err error) {
+	result := &resp.GetGroupPolicyResult
+	result.GroupName, result.PolicyName, result.PolicyDocument, err = getInlinePolicy(s3cfg, "group", values)
+	return resp, err
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DeleteGroupPolicy.html
+func (iama *IamApiServer) DeleteGroupPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeleteGroupPolicyResponse, err error) {
+	return resp, deleteInlinePolicy(s3cfg, "group", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListGroupPolicies.html
+func (iama *IamApiServer) ListGroupPolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListGroupPoliciesResponse, err error) {
+	resp.ListGroupPoliciesResult.PolicyNames, err = listInlinePolicies(s3cfg, "group", values)
+	return resp, err
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_AttachGroupPolicy.html
+func (iama *IamApiServer) AttachGroupPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp AttachGroupPolicyResponse, err error) {
+	return resp, attachPolicy(s3cfg, "group", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DetachGroupPolicy.html
+func (iama *IamApiServer) DetachGroupPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DetachGroupPolicyResponse, err error) {
+	return resp, detachPolicy(s3cfg, "group", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListAttachedGroupPolicies.html
+func (iama *IamApiServer) ListAttachedGroupPolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListAttachedGroupPoliciesResponse, err error) {
+	resp.ListAttachedGroupPoliciesResult.AttachedPolicies, err = listAttachedPolicies(s3cfg, "group", values)
+	return resp, err
+}
@@ -1,6 +1,7 @@
 package iamapi
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
@@ -8,6 +9,24 @@ import (
 	"net/http"
 )
 
+// iamError is an IAM error code with its message
+type iamError struct {
+	code    string
+	message string
+}
+
+func newIamError(code string, format string, a ...interface{}) error {
+	return &iamError{code: code, message: fmt.Sprintf(format, a...)}
+}
+
+func (e *iamError) Error() string {
+	return e.code
+}
+
+func newNoSuchEntityError(object string, value string) error {
+	return newIamError(iam.ErrCodeNoSuchEntityException, "The %s with name %s cannot be found.", object, value)
+}
+
 func writeIamErrorResponse(w http.ResponseWriter, r *http.Request, err error, object string, value string, msg error) {
 	errCode := err.Error()
 	errorResp := ErrorResponse{}
@@ -17,12 +36,22 @@ func writeIamErrorResponse(w http.ResponseWriter, r *http.Request, err error, ob
 		errMsg := msg.Error()
 		errorResp.Error.Message = &errMsg
 	}
+	var iamErr *iamError
+	if errors.As(err, &iamErr) {
+		errorResp.Error.Message = &iamErr.message
+	}
 	glog.Errorf("Response %+v", err)
 	switch errCode {
 	case iam.ErrCodeNoSuchEntityException:
-		msg := fmt.Sprintf("The %s with name %s cannot be found.", object, value)
-		errorResp.Error.Message = &msg
+		if iamErr == nil {
+			msg := fmt.Sprintf("The %s with name %s cannot be found.", object, value)
+			errorResp.Error.Message = &msg
+		}
 		s3err.WriteXMLResponse(w, r, http.StatusNotFound, errorResp)
+	case iam.ErrCodeEntityAlreadyExistsException, iam.ErrCodeDeleteConflictException, iam.ErrCodeLimitExceededException:
+		s3err.WriteXMLResponse(w, r, http.StatusConflict, errorResp)
+	case iam.ErrCodeMalformedPolicyDocumentException, iam.ErrCodeInvalidInputException:
+		s3err.WriteXMLResponse(w, r, http.StatusBadRequest, errorResp)
 	case iam.ErrCodeServiceFailureException:
 		s3err.WriteXMLResponse(w, r, http.StatusInternalServerError, errorResp)
 	default:
@@ -10,7 +10,6 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
@@ -38,8 +37,6 @@ const (
 var (
 	seededRand *rand.Rand = rand.New(
 		rand.NewSource(time.Now().UnixNano()))
-	policyDocuments = map[string]*PolicyDocument{}
-	policyLock      = sync.RWMutex{}
 )
 
 func MapToStatementAction(action string) string {
@@ -141,17 +138,22 @@ func (iama *IamApiServer) ListAccessKeys(s3cfg *iam_pb.S3ApiConfiguration, value
 	return resp
 }
 
-func (iama *IamApiServer) CreateUser(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreateUserResponse) {
+func (iama *IamApiServer) CreateUser(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreateUserResponse, err error) {
 	userName := values.Get("UserName")
+	// users and roles share the same names in the S3 API configuration
+	if hasUser(s3cfg, userName) || findRole(s3cfg, userName) != nil {
+		return resp, newIamError(iam.ErrCodeEntityAlreadyExistsException, "User with name %s already exists.", userName)
+	}
 	resp.CreateUserResult.User.UserName = &userName
 	s3cfg.Identities = append(s3cfg.Identities, &iam_pb.Identity{Name: userName})
-	return resp
+	return resp, nil
 }
 
 func (iama *IamApiServer) DeleteUser(s3cfg *iam_pb.S3ApiConfiguration, userName string) (resp DeleteUserResponse, err error) {
 	for i, ident := range s3cfg.Identities {
 		if userName == ident.Name {
 			s3cfg.Identities = append(s3cfg.Identities[:i], s3cfg.Identities[i+1:]...)
+			renameGroupMember(s3cfg, userName, "")
 			return resp, nil
 		}
 	}
@@ -171,10 +173,14 @@ func (iama *IamApiServer) GetUser(s3cfg *iam_pb.S3ApiConfiguration, userName str
 func (iama *IamApiServer) UpdateUser(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp UpdateUserResponse, err error) {
 	userName := values.Get("UserName")
 	newUserName := values.Get("NewUserName")
+	if newUserName != "" && newUserName != userName && (hasUser(s3cfg, newUserName) || findRole(s3cfg, newUserName) != nil) {
+		return resp, newIamError(iam.ErrCodeEntityAlreadyExistsException, "User with name %s already exists.", newUserName)
+	}
 	if newUserName != "" {
 		for _, ident := range s3cfg.Identities {
 			if userName == ident.Name {
 				ident.Name = newUserName
+				renameGroupMember(s3cfg, userName, newUserName)
 				return resp, nil
 			}
 		}
@@ -184,57 +190,12 @@ func (iama *IamApiServer) UpdateUser(s3cfg *iam_pb.S3ApiConfiguration, values ur
 	return resp, fmt.Errorf(iam.ErrCodeNoSuchEntityException)
 }
 
-func GetPolicyDocument(policy *string) (policyDocument PolicyDocument, err error) {
-	if err = json.Unmarshal([]byte(*policy), &policyDocument); err != nil {
-		return PolicyDocument{}, err
-	}
-	return policyDocument, err
-}
-
-func (iama *IamApiServer) CreatePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreatePolicyResponse, err error) {
-	policyName := values.Get("PolicyName")
-	policyDocumentString := values.Get("PolicyDocument")
-	policyDocument, err := GetPolicyDocument(&policyDocumentString)
-	if err != nil {
-		return CreatePolicyResponse{}, err
-	}
-	policyId := Hash(&policyDocumentString)
-	arn := fmt.Sprintf("arn:aws:iam:::policy/%s", policyName)
-	resp.CreatePolicyResult.Policy.PolicyName = &policyName
-	resp.CreatePolicyResult.Policy.Arn = &arn
-	resp.CreatePolicyResult.Policy.PolicyId = &policyId
-	policies := Policies{}
-	policyLock.Lock()
-	defer policyLock.Unlock()
-	if err = iama.s3ApiConfig.GetPolicies(&policies); err != nil {
-		return resp, err
-	}
-	policies.Policies[policyName] = policyDocument
-	if err = iama.s3ApiConfig.PutPolicies(&policies); err != nil {
-		return resp, err
-	}
-	return resp, nil
-}
-
 // https://docs.aws.amazon.com/IAM/latest/APIReference/API_PutUserPolicy.html
+// The inline policies are enforced by the S3 gateway with their conditions,
+// so they are not flattened into the coarse actions of the user.
 func (iama *IamApiServer) PutUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp PutUserPolicyResponse, err error) {
-	userName := values.Get("UserName")
-	policyName := values.Get("PolicyName")
-	policyDocumentString := values.Get("PolicyDocument")
-	policyDocument, err := GetPolicyDocument(&policyDocumentString)
-	if err != nil {
-		return PutUserPolicyResponse{}, err
-	}
-	policyDocuments[policyName] = &policyDocument
-	actions := GetActions(&policyDocument)
-	for _, ident := range s3cfg.Identities {
-		if userName != ident.Name {
-			continue
-		}
-		ident.Actions = actions
-		return resp, nil
-	}
-	return resp, fmt.Errorf("%s: the user with name %s cannot be found", iam.ErrCodeNoSuchEntityException, userName)
+	err = putInlinePolicy(s3cfg, "user", values)
+	return resp, err
 }
 
 func (iama *IamApiServer) GetUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetUserPolicyResponse, err error) {
@@ -247,8 +208,15 @@ func (iama *IamApiServer) GetUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values
 
 		resp.GetUserPolicyResult.UserName = userName
 		resp.GetUserPolicyResult.PolicyName = policyName
-		if len(ident.Actions) == 0 {
-			return resp, fmt.Errorf(iam.ErrCodeNoSuchEntityException)
+		for _, policy := range ident.InlinePolicies {
+			if policy.Name == policyName {
+				resp.GetUserPolicyResult.PolicyDocument = url.QueryEscape(policy.Document)
+				return resp, nil
+			}
+		}
+		// the user policy put before inline policies were stored is rebuilt from the actions
+		if len(ident.InlinePolicies) > 0 || len(ident.Actions) == 0 {
+			return resp, newNoSuchEntityError("policy", policyName)
 		}
 
 		policyDocument := PolicyDocument{Version: policyDocumentVersion}
@@ -285,21 +253,27 @@ func (iama *IamApiServer) GetUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values
 			policyDocumentStatement.Resource = append(policyDocumentStatement.Resource, resource)
 			policyDocument.Statement = append(policyDocument.Statement, &policyDocumentStatement)
 		}
-		resp.GetUserPolicyResult.PolicyDocument = policyDocument.String()
+		resp.GetUserPolicyResult.PolicyDocument = url.QueryEscape(policyDocument.String())
 		return resp, nil
 	}
 	return resp, fmt.Errorf(iam.ErrCodeNoSuchEntityException)
 }
 
-func (iama *IamApiServer) DeleteUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp PutUserPolicyResponse, err error) {
+func (iama *IamApiServer) DeleteUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeleteUserPolicyResponse, err error) {
 	userName := values.Get("UserName")
-	for i, ident := range s3cfg.Identities {
-		if ident.Name == userName {
-			s3cfg.Identities = append(s3cfg.Identities[:i], s3cfg.Identities[i+1:]...)
+	for _, ident := range s3cfg.Identities {
+		if ident.Name != userName {
+			continue
+		}
+		if len(ident.InlinePolicies) == 0 && len(ident.Actions) > 0 {
+			// the user policy put before inline policies were stored
+			ident.Actions = nil
 			return resp, nil
 		}
+		err = deleteInlinePolicy(s3cfg, "user", values)
+		return resp, err
 	}
-	return resp, fmt.Errorf(iam.ErrCodeNoSuchEntityException)
+	return resp, newNoSuchEntityError("user", userName)
 }
 
 func GetActions(policy *PolicyDocument) (actions []string) {
@@ -430,6 +404,7 @@ func (iama *IamApiServer) DoActions(w http.ResponseWriter, r *http.Request) {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
 		return
 	}
+	policiesMigrated := iama.migratePolicies(s3cfg)
 
 	glog.V(4).Infof("DoActions: %+v", values)
 	var response interface{}
@@ -444,7 +419,7 @@ func (iama *IamApiServer) DoActions(w http.ResponseWriter, r *http.Request) {
 		response = iama.ListAccessKeys(s3cfg, values)
 		changed = false
 	case "CreateUser":
-		response = iama.CreateUser(s3cfg, values)
+		response, err = iama.CreateUser(s3cfg, values)
 	case "GetUser":
 		userName := values.Get("UserName")
 		response, err = iama.GetUser(s3cfg, userName)
@@ -455,7 +430,7 @@ func (iama *IamApiServer) DoActions(w http.ResponseWriter, r *http.Request) {
 		changed = false
 	case "UpdateUser":
 		response, err = iama.UpdateUser(s3cfg, values)
-		if err != nil {
+		if err != nil && !errors.As(err, new(*iamError)) {
 			glog.Errorf("UpdateUser: %+v", err)
 			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
 			return
@@ -473,32 +448,110 @@ func (iama *IamApiServer) DoActions(w http.ResponseWriter, r *http.Request) {
 	case "DeleteAccessKey":
 		handleImplicitUsername(r, values)
 		response = iama.DeleteAccessKey(s3cfg, values)
-	case "CreatePolicy":
-		response, err = iama.CreatePolicy(s3cfg, values)
-		if err != nil {
-			glog.Errorf("CreatePolicy:  %+v", err)
-			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
-			return
-		}
 	case "PutUserPolicy":
 		response, err = iama.PutUserPolicy(s3cfg, values)
-		if err != nil {
-			glog.Errorf("PutUserPolicy:  %+v", err)
-			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
-			return
-		}
 	case "GetUserPolicy":
 		response, err = iama.GetUserPolicy(s3cfg, values)
-		if err != nil {
-			writeIamErrorResponse(w, r, err, "user", values.Get("UserName"), nil)
-			return
-		}
 		changed = false
 	case "DeleteUserPolicy":
-		if response, err = iama.DeleteUserPolicy(s3cfg, values); err != nil {
-			writeIamErrorResponse(w, r, err, "user", values.Get("UserName"), nil)
-			return
-		}
+		response, err = iama.DeleteUserPolicy(s3cfg, values)
+	case "ListUserPolicies":
+		response, err = iama.ListUserPolicies(s3cfg, values)
+		changed = false
+	case "AttachUserPolicy":
+		response, err = iama.AttachUserPolicy(s3cfg, values)
+	case "DetachUserPolicy":
+		response, err = iama.DetachUserPolicy(s3cfg, values)
+	case "ListAttachedUserPolicies":
+		response, err = iama.ListAttachedUserPolicies(s3cfg, values)
+		changed = false
+	case "CreateGroup":
+		response, err = iama.CreateGroup(s3cfg, values)
+	case "GetGroup":
+		response, err = iama.GetGroup(s3cfg, values)
+		changed = false
+	case "ListGroups":
+		response = iama.ListGroups(s3cfg, values)
+		changed = false
+	case "UpdateGroup":
+		response, err = iama.UpdateGroup(s3cfg, values)
+	case "DeleteGroup":
+		response, err = iama.DeleteGroup(s3cfg, values)
+	case "AddUserToGroup":
+		response, err = iama.AddUserToGroup(s3cfg, values)
+	case "RemoveUserFromGroup":
+		response, err = iama.RemoveUserFromGroup(s3cfg, values)
+	case "ListGroupsForUser":
+		response, err = iama.ListGroupsForUser(s3cfg, values)
+		changed = false
+	case "PutGroupPolicy":
+		response, err = iama.PutGroupPolicy(s3cfg, values)
+	case "GetGroupPolicy":
+		response, err = iama.GetGroupPolicy(s3cfg, values)
+		changed = false
+	case "DeleteGroupPolicy":
+		response, err = iama.DeleteGroupPolicy(s3cfg, values)
+	case "ListGroupPolicies":
+		response, err = iama.ListGroupPolicies(s3cfg, values)
+		changed = false
+	case "AttachGroupPolicy":
+		response, err = iama.AttachGroupPolicy(s3cfg, values)
+	case "DetachGroupPolicy":
+		response, err = iama.DetachGroupPolicy(s3cfg, values)
+	case "ListAttachedGroupPolicies":
+		response, err = iama.ListAttachedGroupPolicies(s3cfg, values)
+		changed = false
+	case "CreateRole":
+		response, err = iama.CreateRole(s3cfg, values)
+	case "GetRole":
+		response, err = iama.GetRole(s3cfg, values)
+		changed = false
+	case "ListRoles":
+		response = iama.ListRoles(s3cfg, values)
+		changed = false
+	case "UpdateAssumeRolePolicy":
+		response, err = iama.UpdateAssumeRolePolicy(s3cfg, values)
+	case "DeleteRole":
+		response, err = iama.DeleteRole(s3cfg, values)
+	case "PutRolePolicy":
+		response, err = iama.PutRolePolicy(s3cfg, values)
+	case "GetRolePolicy":
+		response, err = iama.GetRolePolicy(s3cfg, values)
+		changed = false
+	case "DeleteRolePolicy":
+		response, err = iama.DeleteRolePolicy(s3cfg, values)
+	case "ListRolePolicies":
+		response, err = iama.ListRolePolicies(s3cfg, values)
+		changed = false
+	case "AttachRolePolicy":
+		response, err = iama.AttachRolePolicy(s3cfg, values)
+	case "DetachRolePolicy":
+		response, err = iama.DetachRolePolicy(s3cfg, values)
+	case "ListAttachedRolePolicies":
+		response, err = iama.ListAttachedRolePolicies(s3cfg, values)
+		changed = false
+	case "CreatePolicy":
+		response, err = iama.CreatePolicy(s3cfg, values)
+	case "GetPolicy":
+		response, err = iama.GetPolicy(s3cfg, values)
+		changed = false
+	case "ListPolicies":
+		response = iama.ListPolicies(s3cfg, values)
+		changed = false
+	case "DeletePolicy":
+		response, err = iama.DeletePolicy(s3cfg, values)
+	case "CreatePolicyVersion":
+		response, err = iama.CreatePolicyVersion(s3cfg, values)
+	case "GetPolicyVersion":
+		response, err = iama.GetPolicyVersion(s3cfg, values)
+		changed = false
+	case "ListPolicyVersions":
+		response, err = iama.ListPolicyVersions(s3cfg, values)
+		changed = false
+	case "DeletePolicyVersion":
+		response, err = iama.DeletePolicyVersion(s3cfg, values)
+	case "SetDefaultPolicyVersion":
+		response, err = iama.SetDefaultPolicyVersion(s3cfg, values)
 	default:
 		errNotImplemented := s3err.GetAPIError(s3err.ErrNotImplemented)
 		errorResponse := ErrorResponse{}
@@ -507,12 +560,22 @@ func (iama *IamApiServer) DoActions(w http.ResponseWriter, r *http.Request) {
 		s3err.WriteXMLResponse(w, r, errNotImplemented.HTTPStatusCode, errorResponse)
 		return
 	}
+	if err != nil {
+		writeIamErrorResponse(w, r, err, "", "", nil)
+		return
+	}
 	if changed {
 		err := iama.s3ApiConfig.PutS3ApiConfiguration(s3cfg)
 		if err != nil {
 			writeIamErrorResponse(w, r, fmt.Errorf(iam.ErrCodeServiceFailureException), "", "", err)
 			return
 		}
+		if policiesMigrated {
+			// the policies now live in the S3 API configuration
+			if err = iama.s3ApiConfig.PutPolicies(&Policies{Policies: map[string]PolicyDocument{}}); err != nil {
+				glog.Warningf("empty legacy policies: %v", err)
+			}
+		}
 	}
 	s3err.WriteXMLResponse(w, r, http.StatusOK, response)
 }
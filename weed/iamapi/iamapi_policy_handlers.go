@@ -0,0 +1,495 @@
+package iamapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api"
+)
+
+// Managed policies, and the inline policies of users, groups and roles, are kept in the S3 API configuration,
+// so the S3 gateways evaluate them, with their wildcard resources and explicit denies.
+
+const (
+	maxPolicyVersions     = 5
+	maxPolicyDocumentSize = 6144
+	policyArnPrefix       = "arn:aws:iam:::policy/"
+)
+
+// policyHolder is a user, a group or a role, which policies are attached to
+type policyHolder struct {
+	attachedPolicies *[]string
+	inlinePolicies   *[]*iam_pb.InlinePolicy
+}
+
+func findPolicyHolder(s3cfg *iam_pb.S3ApiConfiguration, kind, name string) (*policyHolder, error) {
+	switch kind {
+	case "user":
+		for _, ident := range s3cfg.Identities {
+			if ident.Name == name {
+				return &policyHolder{&ident.AttachedPolicies, &ident.InlinePolicies}, nil
+			}
+		}
+	case "group":
+		if group := findGroup(s3cfg, name); group != nil {
+			return &policyHolder{&group.AttachedPolicies, &group.InlinePolicies}, nil
+		}
+	case "role":
+		if role := findRole(s3cfg, name); role != nil {
+			return &policyHolder{&role.AttachedPolicies, &role.InlinePolicies}, nil
+		}
+	}
+	return nil, newNoSuchEntityError(kind, name)
+}
+
+func validatePolicyDocument(document string) error {
+	if len(document) > maxPolicyDocumentSize {
+		return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "The policy document is larger than %d characters.", maxPolicyDocumentSize)
+	}
+	if err := s3api.ValidateIdentityPolicy(document); err != nil {
+		return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "%v", err)
+	}
+	return nil
+}
+
+// putInlinePolicy adds or replaces the inline policy of the holder
+func putInlinePolicy(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) error {
+	holder, err := findPolicyHolder(s3cfg, kind, values.Get(holderNameParam(kind)))
+	if err != nil {
+		return err
+	}
+	policyName := values.Get("PolicyName")
+	document := values.Get("PolicyDocument")
+	if policyName == "" {
+		return newIamError(iam.ErrCodeInvalidInputException, "PolicyName is required.")
+	}
+	if err = validatePolicyDocument(document); err != nil {
+		return err
+	}
+	for _, policy := range *holder.inlinePolicies {
+		if policy.Name == policyName {
+			policy.Document = document
+			return nil
+		}
+	}
+	*holder.inlinePolicies = append(*holder.inlinePolicies, &iam_pb.InlinePolicy{Name: policyName, Document: document})
+	return nil
+}
+
+func getInlinePolicy(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) (holderName, policyName, document string, err error) {
+	holderName = values.Get(holderNameParam(kind))
+	holder, err := findPolicyHolder(s3cfg, kind, holderName)
+	if err != nil {
+		return
+	}
+	policyName = values.Get("PolicyName")
+	for _, policy := range *holder.inlinePolicies {
+		if policy.Name == policyName {
+			return holderName, policyName, url.QueryEscape(policy.Document), nil
+		}
+	}
+	return holderName, policyName, "", newNoSuchEntityError("policy", policyName)
+}
+
+func deleteInlinePolicy(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) error {
+	holder, err := findPolicyHolder(s3cfg, kind, values.Get(holderNameParam(kind)))
+	if err != nil {
+		return err
+	}
+	policyName := values.Get("PolicyName")
+	for i, policy := range *holder.inlinePolicies {
+		if policy.Name == policyName {
+			*holder.inlinePolicies = append((*holder.inlinePolicies)[:i], (*holder.inlinePolicies)[i+1:]...)
+			return nil
+		}
+	}
+	return newNoSuchEntityError("policy", policyName)
+}
+
+func listInlinePolicies(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) (policyNames []string, err error) {
+	holder, err := findPolicyHolder(s3cfg, kind, values.Get(holderNameParam(kind)))
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range *holder.inlinePolicies {
+		policyNames = append(policyNames, policy.Name)
+	}
+	return policyNames, nil
+}
+
+func attachPolicy(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) error {
+	holder, err := findPolicyHolder(s3cfg, kind, values.Get(holderNameParam(kind)))
+	if err != nil {
+		return err
+	}
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return err
+	}
+	for _, name := range *holder.attachedPolicies {
+		if name == policy.Name {
+			return nil
+		}
+	}
+	*holder.attachedPolicies = append(*holder.attachedPolicies, policy.Name)
+	return nil
+}
+
+func detachPolicy(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) error {
+	holder, err := findPolicyHolder(s3cfg, kind, values.Get(holderNameParam(kind)))
+	if err != nil {
+		return err
+	}
+	policyArn := values.Get("PolicyArn")
+	policyName := policyNameFromArn(policyArn)
+	for i, name := range *holder.attachedPolicies {
+		if name == policyName {
+			*holder.attachedPolicies = append((*holder.attachedPolicies)[:i], (*holder.attachedPolicies)[i+1:]...)
+			return nil
+		}
+	}
+	return newIamError(iam.ErrCodeNoSuchEntityException, "Policy %s was not found.", policyArn)
+}
+
+func listAttachedPolicies(s3cfg *iam_pb.S3ApiConfiguration, kind string, values url.Values) (attachedPolicies []*iam.AttachedPolicy, err error) {
+	holder, err := findPolicyHolder(s3cfg, kind, values.Get(holderNameParam(kind)))
+	if err != nil {
+		return nil, err
+	}
+	// all the policies are at the root path
+	if pathPrefix := values.Get("PathPrefix"); pathPrefix != "" && pathPrefix != "/" {
+		return nil, nil
+	}
+	for _, name := range *holder.attachedPolicies {
+		policyName, policyArn := name, getPolicyArn(name)
+		attachedPolicies = append(attachedPolicies, &iam.AttachedPolicy{PolicyName: &policyName, PolicyArn: &policyArn})
+	}
+	return attachedPolicies, nil
+}
+
+func holderNameParam(kind string) string {
+	switch kind {
+	case "group":
+		return "GroupName"
+	case "role":
+		return "RoleName"
+	default:
+		return "UserName"
+	}
+}
+
+func getPolicyArn(policyName string) string {
+	return policyArnPrefix + policyName
+}
+
+// policyNameFromArn accepts any account and path in the policy arn
+func policyNameFromArn(policyArn string) string {
+	return policyArn[strings.LastIndex(policyArn, "/")+1:]
+}
+
+func findManagedPolicy(s3cfg *iam_pb.S3ApiConfiguration, policyArn string) (*iam_pb.ManagedPolicy, error) {
+	policyName := policyNameFromArn(policyArn)
+	for _, policy := range s3cfg.Policies {
+		if policy.Name == policyName {
+			return policy, nil
+		}
+	}
+	return nil, newIamError(iam.ErrCodeNoSuchEntityException, "Policy %s was not found.", policyArn)
+}
+
+func findPolicyVersion(policy *iam_pb.ManagedPolicy, versionId string) (int, *iam_pb.PolicyVersion) {
+	for i, version := range policy.Versions {
+		if version.VersionId == versionId {
+			return i, version
+		}
+	}
+	return -1, nil
+}
+
+// policyAttachmentCount counts the users, groups and roles the policy is attached to
+func policyAttachmentCount(s3cfg *iam_pb.S3ApiConfiguration, policyName string) (count int64) {
+	var attachments [][]string
+	for _, ident := range s3cfg.Identities {
+		attachments = append(attachments, ident.AttachedPolicies)
+	}
+	for _, group := range s3cfg.Groups {
+		attachments = append(attachments, group.AttachedPolicies)
+	}
+	for _, role := range s3cfg.Roles {
+		attachments = append(attachments, role.AttachedPolicies)
+	}
+	for _, attachedPolicies := range attachments {
+		for _, name := range attachedPolicies {
+			if name == policyName {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func newIamPolicy(s3cfg *iam_pb.S3ApiConfiguration, policy *iam_pb.ManagedPolicy) *iam.Policy {
+	policyName, policyArn, path := policy.Name, getPolicyArn(policy.Name), "/"
+	defaultVersionId, description := policy.DefaultVersionId, policy.Description
+	attachmentCount, isAttachable := policyAttachmentCount(s3cfg, policy.Name), true
+	createDate := time.Unix(policy.CreateDate, 0).UTC()
+	updateDate := createDate
+	policyId := policy.Name
+	if _, version := findPolicyVersion(policy, policy.DefaultVersionId); version != nil {
+		updateDate = time.Unix(version.CreateDate, 0).UTC()
+		policyId = Hash(&version.Document)
+	}
+	iamPolicy := &iam.Policy{
+		PolicyName:       &policyName,
+		PolicyId:         &policyId,
+		Arn:              &policyArn,
+		Path:             &path,
+		DefaultVersionId: &defaultVersionId,
+		AttachmentCount:  &attachmentCount,
+		IsAttachable:     &isAttachable,
+		CreateDate:       &createDate,
+		UpdateDate:       &updateDate,
+	}
+	if description != "" {
+		iamPolicy.Description = &description
+	}
+	return iamPolicy
+}
+
+func newIamPolicyVersion(policy *iam_pb.ManagedPolicy, version *iam_pb.PolicyVersion, withDocument bool) *iam.PolicyVersion {
+	versionId := version.VersionId
+	isDefaultVersion := version.VersionId == policy.DefaultVersionId
+	createDate := time.Unix(version.CreateDate, 0).UTC()
+	policyVersion := &iam.PolicyVersion{
+		VersionId:        &versionId,
+		IsDefaultVersion: &isDefaultVersion,
+		CreateDate:       &createDate,
+	}
+	if withDocument {
+		document := url.QueryEscape(version.Document)
+		policyVersion.Document = &document
+	}
+	return policyVersion
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_CreatePolicy.html
+func (iama *IamApiServer) CreatePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreatePolicyResponse, err error) {
+	policyName := values.Get("PolicyName")
+	policyDocumentString := values.Get("PolicyDocument")
+	if policyName == "" {
+		return resp, newIamError(iam.ErrCodeInvalidInputException, "PolicyName is required.")
+	}
+	if err = validatePolicyDocument(policyDocumentString); err != nil {
+		return resp, err
+	}
+	if _, err = findManagedPolicy(s3cfg, policyName); err == nil {
+		return resp, newIamError(iam.ErrCodeEntityAlreadyExistsException, "A policy called %s already exists.", policyName)
+	}
+	now := time.Now().Unix()
+	policy := &iam_pb.ManagedPolicy{
+		Name:             policyName,
+		Description:      values.Get("Description"),
+		DefaultVersionId: "v1",
+		Versions:         []*iam_pb.PolicyVersion{{VersionId: "v1", Document: policyDocumentString, CreateDate: now}},
+		CreateDate:       now,
+	}
+	s3cfg.Policies = append(s3cfg.Policies, policy)
+	resp.CreatePolicyResult.Policy = *newIamPolicy(s3cfg, policy)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetPolicy.html
+func (iama *IamApiServer) GetPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetPolicyResponse, err error) {
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return resp, err
+	}
+	resp.GetPolicyResult.Policy = *newIamPolicy(s3cfg, policy)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListPolicies.html
+func (iama *IamApiServer) ListPolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListPoliciesResponse) {
+	if scope := values.Get("Scope"); scope == iam.PolicyScopeTypeAws {
+		return resp
+	}
+	onlyAttached := values.Get("OnlyAttached") == "true"
+	for _, policy := range s3cfg.Policies {
+		iamPolicy := newIamPolicy(s3cfg, policy)
+		if onlyAttached && *iamPolicy.AttachmentCount == 0 {
+			continue
+		}
+		resp.ListPoliciesResult.Policies = append(resp.ListPoliciesResult.Policies, iamPolicy)
+	}
+	return resp
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DeletePolicy.html
+func (iama *IamApiServer) DeletePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeletePolicyResponse, err error) {
+	policyArn := values.Get("PolicyArn")
+	policy, err := findManagedPolicy(s3cfg, policyArn)
+	if err != nil {
+		return resp, err
+	}
+	if policyAttachmentCount(s3cfg, policy.Name) > 0 {
+		return resp, newIamError(iam.ErrCodeDeleteConflictException, "Cannot delete a policy attached to entities.")
+	}
+	for i, p := range s3cfg.Policies {
+		if p == policy {
+			s3cfg.Policies = append(s3cfg.Policies[:i], s3cfg.Policies[i+1:]...)
+			break
+		}
+	}
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_CreatePolicyVersion.html
+func (iama *IamApiServer) CreatePolicyVersion(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreatePolicyVersionResponse, err error) {
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return resp, err
+	}
+	document := values.Get("PolicyDocument")
+	if err = validatePolicyDocument(document); err != nil {
+		return resp, err
+	}
+	if len(policy.Versions) >= maxPolicyVersions {
+		return resp, newIamError(iam.ErrCodeLimitExceededException, "A managed policy can have up to %d versions. Before you create a new version, you must delete an existing version.", maxPolicyVersions)
+	}
+	var lastVersion int
+	for _, version := range policy.Versions {
+		if n, err := strconv.Atoi(strings.TrimPrefix(version.VersionId, "v")); err == nil && n > lastVersion {
+			lastVersion = n
+		}
+	}
+	version := &iam_pb.PolicyVersion{
+		VersionId:  fmt.Sprintf("v%d", lastVersion+1),
+		Document:   document,
+		CreateDate: time.Now().Unix(),
+	}
+	policy.Versions = append(policy.Versions, version)
+	if values.Get("SetAsDefault") == "true" {
+		policy.DefaultVersionId = version.VersionId
+	}
+	glog.V(1).Infof("policy %s has a new version %s, default version %s", policy.Name, version.VersionId, policy.DefaultVersionId)
+	resp.CreatePolicyVersionResult.PolicyVersion = *newIamPolicyVersion(policy, version, false)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetPolicyVersion.html
+func (iama *IamApiServer) GetPolicyVersion(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetPolicyVersionResponse, err error) {
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return resp, err
+	}
+	versionId := values.Get("VersionId")
+	_, version := findPolicyVersion(policy, versionId)
+	if version == nil {
+		return resp, newIamError(iam.ErrCodeNoSuchEntityException, "Policy %s version %s does not exist.", values.Get("PolicyArn"), versionId)
+	}
+	resp.GetPolicyVersionResult.PolicyVersion = *newIamPolicyVersion(policy, version, true)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListPolicyVersions.html
+func (iama *IamApiServer) ListPolicyVersions(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListPolicyVersionsResponse, err error) {
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return resp, err
+	}
+	for _, version := range policy.Versions {
+		resp.ListPolicyVersionsResult.Versions = append(resp.ListPolicyVersionsResult.Versions, newIamPolicyVersion(policy, version, false))
+	}
+	// the latest version first
+	sort.SliceStable(resp.ListPolicyVersionsResult.Versions, func(i, j int) bool {
+		return resp.ListPolicyVersionsResult.Versions[i].CreateDate.After(*resp.ListPolicyVersionsResult.Versions[j].CreateDate)
+	})
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DeletePolicyVersion.html
+func (iama *IamApiServer) DeletePolicyVersion(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeletePolicyVersionResponse, err error) {
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return resp, err
+	}
+	versionId := values.Get("VersionId")
+	i, version := findPolicyVersion(policy, versionId)
+	if version == nil {
+		return resp, newIamError(iam.ErrCodeNoSuchEntityException, "Policy %s version %s does not exist.", values.Get("PolicyArn"), versionId)
+	}
+	if versionId == policy.DefaultVersionId {
+		return resp, newIamError(iam.ErrCodeDeleteConflictException, "Cannot delete the default version of a policy.")
+	}
+	policy.Versions = append(policy.Versions[:i], policy.Versions[i+1:]...)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_SetDefaultPolicyVersion.html
+func (iama *IamApiServer) SetDefaultPolicyVersion(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp SetDefaultPolicyVersionResponse, err error) {
+	policy, err := findManagedPolicy(s3cfg, values.Get("PolicyArn"))
+	if err != nil {
+		return resp, err
+	}
+	versionId := values.Get("VersionId")
+	if _, version := findPolicyVersion(policy, versionId); version == nil {
+		return resp, newIamError(iam.ErrCodeNoSuchEntityException, "Policy %s version %s does not exist.", values.Get("PolicyArn"), versionId)
+	}
+	policy.DefaultVersionId = versionId
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_AttachUserPolicy.html
+func (iama *IamApiServer) AttachUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp AttachUserPolicyResponse, err error) {
+	return resp, attachPolicy(s3cfg, "user", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DetachUserPolicy.html
+func (iama *IamApiServer) DetachUserPolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DetachUserPolicyResponse, err error) {
+	return resp, detachPolicy(s3cfg, "user", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListAttachedUserPolicies.html
+func (iama *IamApiServer) ListAttachedUserPolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListAttachedUserPoliciesResponse, err error) {
+	resp.ListAttachedUserPoliciesResult.AttachedPolicies, err = listAttachedPolicies(s3cfg, "user", values)
+	return resp, err
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListUserPolicies.html
+func (iama *IamApiServer) ListUserPolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListUserPoliciesResponse, err error) {
+	resp.ListUserPoliciesResult.PolicyNames, err = listInlinePolicies(s3cfg, "user", values)
+	return resp, err
+}
+
+// migratePolicies imports the policies created before they were kept in the S3 API configuration,
+// it reports whether any was imported so that the legacy file gets emptied once the configuration is saved
+func (iama *IamApiServer) migratePolicies(s3cfg *iam_pb.S3ApiConfiguration) bool {
+	if len(s3cfg.Policies) > 0 {
+		return false
+	}
+	policies := Policies{}
+	if err := iama.s3ApiConfig.GetPolicies(&policies); err != nil {
+		glog.Warningf("read legacy policies: %v", err)
+		return false
+	}
+	names := make([]string, 0, len(policies.Policies))
+	for name := range policies.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s3cfg.Policies = append(s3cfg.Policies, &iam_pb.ManagedPolicy{
+			Name:             name,
+			DefaultVersionId: "v1",
+			Versions:         []*iam_pb.PolicyVersion{{VersionId: "v1", Document: policies.Policies[name].String()}},
+		})
+	}
+	return len(names) > 0
+}
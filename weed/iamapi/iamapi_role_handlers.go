@@ -0,0 +1,271 @@
+package iamapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+)
+
+const (
+	minRoleSessionDuration = 3600
+	maxRoleSessionDuration = 43200
+)
+
+// stringList is a json string, or an array of strings
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*l = stringList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+type assumeRolePolicyDocument struct {
+	Version   string
+	Statement []struct {
+		Effect    string
+		Principal json.RawMessage
+		Action    stringList
+		Condition map[string]map[string]stringList
+	}
+}
+
+// applyAssumeRolePolicy derives who can assume the role from its trust policy:
+// sts:AssumeRole is granted to the "AWS" principals, which are user names, user arns, or "*",
+// sts:AssumeRoleWithWebIdentity to the "Federated" OIDC providers, limited to the subjects of their "sub" conditions.
+func applyAssumeRolePolicy(role *iam_pb.Role, document string) error {
+	var policy assumeRolePolicyDocument
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "%v", err)
+	}
+	if policy.Version != policyDocumentVersion && policy.Version != "2008-10-17" {
+		return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "unsupported policy version %q", policy.Version)
+	}
+	var trustedIdentities, trustedIssuers, trustedSubjects []string
+	for i, statement := range policy.Statement {
+		if statement.Effect != "Allow" {
+			return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "statement %d: only Allow is supported in a trust policy", i)
+		}
+		principals := make(map[string]stringList)
+		var wildcard string
+		if err := json.Unmarshal(statement.Principal, &wildcard); err == nil && wildcard == "*" {
+			principals["AWS"] = stringList{"*"}
+		} else if err = json.Unmarshal(statement.Principal, &principals); err != nil {
+			return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "statement %d: invalid principal", i)
+		}
+		for _, action := range statement.Action {
+			switch action {
+			case "sts:AssumeRole", "sts:*", "*":
+				for _, principal := range principals["AWS"] {
+					trustedIdentities = append(trustedIdentities, trustedIdentityOf(principal))
+				}
+			}
+			switch action {
+			case "sts:AssumeRoleWithWebIdentity", "sts:*", "*":
+				for _, principal := range principals["Federated"] {
+					issuer := trustedIssuerOf(principal)
+					trustedIssuers = append(trustedIssuers, issuer)
+					for operator, conditions := range statement.Condition {
+						if operator != "StringEquals" && operator != "StringLike" {
+							continue
+						}
+						for key, subjects := range conditions {
+							if strings.HasSuffix(key, ":sub") && strings.Contains(issuer, strings.TrimSuffix(key, ":sub")) {
+								trustedSubjects = append(trustedSubjects, subjects...)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(trustedIdentities) == 0 && len(trustedIssuers) == 0 {
+		return newIamError(iam.ErrCodeMalformedPolicyDocumentException, "The trust policy allows nobody to assume the role.")
+	}
+	role.AssumeRolePolicyDocument = document
+	role.TrustedIdentities = trustedIdentities
+	role.TrustedIssuers = trustedIssuers
+	role.TrustedSubjects = trustedSubjects
+	return nil
+}
+
+// trustedIdentityOf maps arn:aws:iam::<account>:user/<name> to the identity name, and an account root to any identity
+func trustedIdentityOf(principal string) string {
+	if strings.HasPrefix(principal, "arn:") {
+		if strings.HasSuffix(principal, ":root") {
+			return "*"
+		}
+		return principal[strings.LastIndex(principal, "/")+1:]
+	}
+	return principal
+}
+
+// trustedIssuerOf maps arn:aws:iam::<account>:oidc-provider/<host> to the issuer url
+func trustedIssuerOf(principal string) string {
+	if i := strings.Index(principal, ":oidc-provider/"); i >= 0 {
+		return "https://" + principal[i+len(":oidc-provider/"):]
+	}
+	return principal
+}
+
+func findRole(s3cfg *iam_pb.S3ApiConfiguration, roleName string) *iam_pb.Role {
+	for _, role := range s3cfg.Roles {
+		if role.Name == roleName {
+			return role
+		}
+	}
+	return nil
+}
+
+func newIamRole(role *iam_pb.Role) *iam.Role {
+	roleName, path := role.Name, "/"
+	arn := fmt.Sprintf("arn:aws:iam:::role/%s", role.Name)
+	roleId := Hash(&arn)
+	document := url.QueryEscape(role.AssumeRolePolicyDocument)
+	maxSessionDuration := role.MaxSessionDurationSeconds
+	if maxSessionDuration == 0 {
+		maxSessionDuration = minRoleSessionDuration
+	}
+	return &iam.Role{
+		RoleName:                 &roleName,
+		RoleId:                   &roleId,
+		Arn:                      &arn,
+		Path:                     &path,
+		AssumeRolePolicyDocument: &document,
+		MaxSessionDuration:       &maxSessionDuration,
+	}
+}
+
+func parseMaxSessionDuration(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < minRoleSessionDuration || seconds > maxRoleSessionDuration {
+		return 0, newIamError(iam.ErrCodeInvalidInputException, "MaxSessionDuration should be between %d and %d seconds.", minRoleSessionDuration, maxRoleSessionDuration)
+	}
+	return seconds, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_CreateRole.html
+func (iama *IamApiServer) CreateRole(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp CreateRoleResponse, err error) {
+	roleName := values.Get("RoleName")
+	if roleName == "" {
+		return resp, newIamError(iam.ErrCodeInvalidInputException, "RoleName is required.")
+	}
+	// roles and users share the same names in the S3 API configuration
+	if findRole(s3cfg, roleName) != nil || hasUser(s3cfg, roleName) {
+		return resp, newIamError(iam.ErrCodeEntityAlreadyExistsException, "Role with name %s already exists.", roleName)
+	}
+	role := &iam_pb.Role{Name: roleName}
+	if role.MaxSessionDurationSeconds, err = parseMaxSessionDuration(values.Get("MaxSessionDuration")); err != nil {
+		return resp, err
+	}
+	if err = applyAssumeRolePolicy(role, values.Get("AssumeRolePolicyDocument")); err != nil {
+		return resp, err
+	}
+	s3cfg.Roles = append(s3cfg.Roles, role)
+	resp.CreateRoleResult.Role = *newIamRole(role)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetRole.html
+func (iama *IamApiServer) GetRole(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetRoleResponse, err error) {
+	roleName := values.Get("RoleName")
+	role := findRole(s3cfg, roleName)
+	if role == nil {
+		return resp, newNoSuchEntityError("role", roleName)
+	}
+	resp.GetRoleResult.Role = *newIamRole(role)
+	return resp, nil
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListRoles.html
+func (iama *IamApiServer) ListRoles(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListRolesResponse) {
+	for _, role := range s3cfg.Roles {
+		resp.ListRolesResult.Roles = append(resp.ListRolesResult.Roles, newIamRole(role))
+	}
+	return resp
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_UpdateAssumeRolePolicy.html
+func (iama *IamApiServer) UpdateAssumeRolePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp UpdateAssumeRolePolicyResponse, err error) {
+	roleName := values.Get("RoleName")
+	role := findRole(s3cfg, roleName)
+	if role == nil {
+		return resp, newNoSuchEntityError("role", roleName)
+	}
+	return resp, applyAssumeRolePolicy(role, values.Get("PolicyDocument"))
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DeleteRole.html
+func (iama *IamApiServer) DeleteRole(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeleteRoleResponse, err error) {
+	roleName := values.Get("RoleName")
+	for i, role := range s3cfg.Roles {
+		if role.Name == roleName {
+			if len(role.AttachedPolicies) > 0 || len(role.InlinePolicies) > 0 {
+				return resp, newIamError(iam.ErrCodeDeleteConflictException, "Cannot delete entity, must detach all policies first.")
+			}
+			s3cfg.Roles = append(s3cfg.Roles[:i], s3cfg.Roles[i+1:]...)
+			return resp, nil
+		}
+	}
+	return resp, newNoSuchEntityError("role", roleName)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_PutRolePolicy.html
+func (iama *IamApiServer) PutRolePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp PutRolePolicyResponse, err error) {
+	return resp, putInlinePolicy(s3cfg, "role", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetRolePolicy.html
+func (iama *IamApiServer) GetRolePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp GetRolePolicyResponse, err error) {
+	result := &resp.GetRolePolicyResult
+	result.RoleName, result.PolicyName, result.PolicyDocument, err = getInlinePolicy(s3cfg, "role", values)
+	return resp, err
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DeleteRolePolicy.html
+func (iama *IamApiServer) DeleteRolePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DeleteRolePolicyResponse, err error) {
+	return resp, deleteInlinePolicy(s3cfg, "role", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListRolePolicies.html
+func (iama *IamApiServer) ListRolePolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListRolePoliciesResponse, err error) {
+	resp.ListRolePoliciesResult.PolicyNames, err = listInlinePolicies(s3cfg, "role", values)
+	return resp, err
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_AttachRolePolicy.html
+func (iama *IamApiServer) AttachRolePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp AttachRolePolicyResponse, err error) {
+	return resp, attachPolicy(s3cfg, "role", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_DetachRolePolicy.html
+func (iama *IamApiServer) DetachRolePolicy(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp DetachRolePolicyResponse, err error) {
+	return resp, detachPolicy(s3cfg, "role", values)
+}
+
+// https://docs.aws.amazon.com/IAM/latest/APIReference/API_ListAttachedRolePolicies.html
+func (iama *IamApiServer) ListAttachedRolePolicies(s3cfg *iam_pb.S3ApiConfiguration, values url.Values) (resp ListAttachedRolePoliciesResponse, err error) {
+	resp.ListAttachedRolePoliciesResult.AttachedPolicies, err = listAttachedPolicies(s3cfg, "role", values)
+	return resp, err
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/jinzhu/copier"
 	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 )
 
 var GetS3ApiConfiguration func(s3cfg *iam_pb.S3ApiConfiguration) (err error)
@@ -28,12 +29,13 @@ var ias = IamApiServer{s3ApiConfig: iamS3ApiConfigureMock{}}
 type iamS3ApiConfigureMock struct{}
 
 func (iam iamS3ApiConfigureMock) GetS3ApiConfiguration(s3cfg *iam_pb.S3ApiConfiguration) (err error) {
-	_ = copier.Copy(&s3cfg.Identities, &s3config.Identities)
+	proto.Merge(s3cfg, &s3config)
 	return nil
 }
 
 func (iam iamS3ApiConfigureMock) PutS3ApiConfiguration(s3cfg *iam_pb.S3ApiConfiguration) (err error) {
-	_ = copier.Copy(&s3config.Identities, &s3cfg.Identities)
+	proto.Reset(&s3config)
+	proto.Merge(&s3config, s3cfg)
 	return nil
 }
 
@@ -150,6 +152,14 @@ func TestPutUserPolicy(t *testing.T) {
 	response, err := executeRequest(req.HTTPRequest, out)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, http.StatusOK, response.Code)
+
+	// the inline policy is enforced with its conditions, not flattened into the coarse actions
+	for _, ident := range s3config.Identities {
+		if ident.Name == *userName {
+			assert.Equal(t, 1, len(ident.InlinePolicies))
+			assert.Empty(t, ident.Actions)
+		}
+	}
 }
 
 func TestGetUserPolicy(t *testing.T) {
@@ -186,6 +196,117 @@ func TestDeleteUser(t *testing.T) {
 	assert.Equal(t, http.StatusOK, response.Code)
 }
 
+func TestGroupsAndManagedPolicies(t *testing.T) {
+	svc := iam.New(session.New())
+	policyDocument := `{
+		"Version": "2012-10-17",
+		"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::EXAMPLE-BUCKET/*"}]
+	}`
+	policyArn := aws.String("arn:aws:iam:::policy/group-read")
+
+	req, _ := svc.CreateUserRequest(&iam.CreateUserInput{UserName: aws.String("GroupMember")})
+	_ = req.Build()
+	response, err := executeRequest(req.HTTPRequest, CreateUserResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	req, _ = svc.CreateGroupRequest(&iam.CreateGroupInput{GroupName: aws.String("readers")})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, CreateGroupResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	req, _ = svc.AddUserToGroupRequest(&iam.AddUserToGroupInput{GroupName: aws.String("readers"), UserName: aws.String("GroupMember")})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, AddUserToGroupResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	req, _ = svc.CreatePolicyRequest(&iam.CreatePolicyInput{PolicyName: aws.String("group-read"), PolicyDocument: aws.String(policyDocument)})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, CreatePolicyResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	req, _ = svc.AttachGroupPolicyRequest(&iam.AttachGroupPolicyInput{GroupName: aws.String("readers"), PolicyArn: policyArn})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, AttachGroupPolicyResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, []string{"group-read"}, s3config.Groups[0].AttachedPolicies)
+	assert.Equal(t, []string{"GroupMember"}, s3config.Groups[0].Members)
+
+	// an attached policy can not be deleted
+	req, _ = svc.DeletePolicyRequest(&iam.DeletePolicyInput{PolicyArn: policyArn})
+	_ = req.Build()
+	response, _ = executeRequest(req.HTTPRequest, DeletePolicyResponse{})
+	assert.Equal(t, http.StatusConflict, response.Code)
+
+	req, _ = svc.CreatePolicyVersionRequest(&iam.CreatePolicyVersionInput{PolicyArn: policyArn, PolicyDocument: aws.String(policyDocument), SetAsDefault: aws.Bool(true)})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, CreatePolicyVersionResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+	policy := s3config.Policies[len(s3config.Policies)-1]
+	assert.Equal(t, "v2", policy.DefaultVersionId)
+	assert.Equal(t, 2, len(policy.Versions))
+
+	// the default version can not be deleted
+	req, _ = svc.DeletePolicyVersionRequest(&iam.DeletePolicyVersionInput{PolicyArn: policyArn, VersionId: aws.String("v2")})
+	_ = req.Build()
+	response, _ = executeRequest(req.HTTPRequest, DeletePolicyVersionResponse{})
+	assert.Equal(t, http.StatusConflict, response.Code)
+
+	req, _ = svc.PutGroupPolicyRequest(&iam.PutGroupPolicyInput{GroupName: aws.String("readers"), PolicyName: aws.String("invalid"), PolicyDocument: aws.String(`{"Version": "2012-10-17"}`)})
+	_ = req.Build()
+	response, _ = executeRequest(req.HTTPRequest, PutGroupPolicyResponse{})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// renaming and deleting the user follows in its groups
+	req, _ = svc.UpdateUserRequest(&iam.UpdateUserInput{UserName: aws.String("GroupMember"), NewUserName: aws.String("GroupMember-New")})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, UpdateUserResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"GroupMember-New"}, s3config.Groups[0].Members)
+
+	req, _ = svc.DeleteUserRequest(&iam.DeleteUserInput{UserName: aws.String("GroupMember-New")})
+	_ = req.Build()
+	response, err = executeRequest(req.HTTPRequest, DeleteUserResponse{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(s3config.Groups[0].Members))
+}
+
+func TestCreateRole(t *testing.T) {
+	trustPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::000000000000:user/alice"}, "Action": "sts:AssumeRole"},
+			{
+				"Effect": "Allow",
+				"Principal": {"Federated": "arn:aws:iam::000000000000:oidc-provider/accounts.example.com"},
+				"Action": "sts:AssumeRoleWithWebIdentity",
+				"Condition": {"StringEquals": {"accounts.example.com:sub": "ci"}}
+			}
+		]
+	}`
+	req, _ := iam.New(session.New()).CreateRoleRequest(&iam.CreateRoleInput{RoleName: aws.String("deployer"), AssumeRolePolicyDocument: aws.String(trustPolicy)})
+	_ = req.Build()
+	out := CreateRoleResponse{}
+	response, err := executeRequest(req.HTTPRequest, out)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+	role := s3config.Roles[0]
+	assert.Equal(t, []string{"alice"}, role.TrustedIdentities)
+	assert.Equal(t, []string{"https://accounts.example.com"}, role.TrustedIssuers)
+	assert.Equal(t, []string{"ci"}, role.TrustedSubjects)
+
+	// a user can not take the name of a role
+	req, _ = iam.New(session.New()).CreateUserRequest(&iam.CreateUserInput{UserName: aws.String("deployer")})
+	_ = req.Build()
+	response, _ = executeRequest(req.HTTPRequest, CreateUserResponse{})
+	assert.Equal(t, http.StatusConflict, response.Code)
+}
+
 func executeRequest(req *http.Request, v interface{}) (*httptest.ResponseRecorder, error) {
 	rr := httptest.NewRecorder()
 	apiRouter := mux.NewRouter().SkipClean(true)
@@ -0,0 +1,36 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Converted decodes the image and encodes it in the format, one of "png", "jpeg" or "gif"
+func Converted(read io.Reader, format string) (converted io.ReadSeeker, err error) {
+	srcImage, _, err := image.Decode(read)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, srcImage)
+	case "jpeg", "jpg":
+		err = jpeg.Encode(&buf, srcImage, nil)
+	case "gif":
+		err = gif.Encode(&buf, srcImage, nil)
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
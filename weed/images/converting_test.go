@@ -0,0 +1,25 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"testing"
+)
+
+func TestConverting(t *testing.T) {
+	dat, _ := os.ReadFile("sample2.webp")
+
+	converted, err := Converted(bytes.NewReader(dat), "png")
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	_, format, err := image.Decode(converted)
+	if err != nil || format != "png" {
+		t.Fatalf("converted format %s: %v", format, err)
+	}
+
+	if _, err = Converted(bytes.NewReader(dat), "bmp"); err == nil {
+		t.Fatalf("converted to an unsupported format")
+	}
+}
@@ -1,6 +1,10 @@
 package notification
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
+
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"google.golang.org/protobuf/proto"
@@ -12,6 +16,8 @@ type MessageQueue interface {
 	// Initialize initializes the file store
 	Initialize(configuration util.Configuration, prefix string) error
 	SendMessage(key string, message proto.Message) error
+	// SendRawMessage sends an already encoded message, such as the json of S3 event notifications
+	SendRawMessage(key string, data []byte) error
 }
 
 var (
@@ -54,3 +60,43 @@ func validateOneEnabledQueue(config *util.ViperProxy) {
 		}
 	}
 }
+
+// LoadNamedQueues initializes a message queue for each table under the prefix, named by the table name.
+// The "type" of each table is the name of the message queue, and the other keys are its configuration,
+// so that several queues of the same type can be used together, such as for the S3 event notification targets.
+func LoadNamedQueues(config *util.ViperProxy, prefix string) (map[string]MessageQueue, error) {
+	queues := make(map[string]MessageQueue)
+	if config == nil {
+		return queues, nil
+	}
+
+	var names []string
+	for name := range config.GetStringMap(prefix) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		queueType := config.GetString(prefix + "." + name + ".type")
+		queue := newMessageQueue(queueType)
+		if queue == nil {
+			return nil, fmt.Errorf("%s.%s: unknown message queue type %q", prefix, name, queueType)
+		}
+		if err := queue.Initialize(config, prefix+"."+name+"."); err != nil {
+			return nil, fmt.Errorf("initialize %s.%s: %v", prefix, name, err)
+		}
+		glog.V(0).Infof("Configure %s message queue %s", queueType, name)
+		queues[name] = queue
+	}
+	return queues, nil
+}
+
+// newMessageQueue creates another instance of a registered message queue
+func newMessageQueue(queueType string) MessageQueue {
+	for _, queue := range MessageQueues {
+		if queue.GetName() == queueType {
+			return reflect.New(reflect.TypeOf(queue).Elem()).Interface().(MessageQueue)
+		}
+	}
+	return nil
+}
@@ -107,6 +107,10 @@ func (k *GoCDKPubSub) SendMessage(key string, message proto.Message) error {
 	if err != nil {
 		return err
 	}
+	return k.SendRawMessage(key, bytes)
+}
+
+func (k *GoCDKPubSub) SendRawMessage(key string, bytes []byte) error {
 	k.topicLock.RLock()
 	defer k.topicLock.RUnlock()
 	err = k.topic.Send(context.Background(), &pubsub.Message{
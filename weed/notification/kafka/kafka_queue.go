@@ -52,6 +52,10 @@ func (k *KafkaQueue) SendMessage(key string, message proto.Message) (err error)
 		return
 	}
 
+	return k.SendRawMessage(key, bytes)
+}
+
+func (k *KafkaQueue) SendRawMessage(key string, bytes []byte) (err error) {
 	msg := &sarama.ProducerMessage{
 		Topic: k.topic,
 		Key:   sarama.StringEncoder(key),
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/notification"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	notification.MessageQueues = append(notification.MessageQueues, &Webhook{})
+}
+
+// Webhook posts each message as json to an HTTP endpoint
+type Webhook struct {
+	url         string
+	bearerToken string
+	client      *http.Client
+}
+
+func (k *Webhook) GetName() string {
+	return "webhook"
+}
+
+func (k *Webhook) Initialize(configuration util.Configuration, prefix string) (err error) {
+	glog.V(0).Infof("notification.webhook.url: %v", configuration.GetString(prefix+"url"))
+	configuration.SetDefault(prefix+"timeout_seconds", 10)
+	return k.initialize(
+		configuration.GetString(prefix+"url"),
+		configuration.GetString(prefix+"bearer_token"),
+		time.Duration(configuration.GetInt(prefix+"timeout_seconds"))*time.Second,
+	)
+}
+
+func (k *Webhook) initialize(url, bearerToken string, timeout time.Duration) error {
+	if url == "" {
+		return fmt.Errorf("webhook url is not set")
+	}
+	k.url = url
+	k.bearerToken = bearerToken
+	k.client = &http.Client{Timeout: timeout}
+	return nil
+}
+
+func (k *Webhook) SendMessage(key string, message proto.Message) error {
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("send message marshal %+v: %v", message, err)
+	}
+	return k.SendRawMessage(key, data)
+}
+
+func (k *Webhook) SendRawMessage(key string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, k.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Seaweedfs-Key", key)
+	if k.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+k.bearerToken)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send message to webhook %s: %v", k.url, err)
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("send message to webhook %s: %s %s", k.url, resp.Status, body)
+	}
+	return nil
+}
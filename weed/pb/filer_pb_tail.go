@@ -17,6 +17,7 @@ const (
 	TrivialOnError EventErrorType = iota
 	FatalOnError
 	RetryForeverOnError
+	ReturnOnError // ends the subscription with the error, without moving past the event
 )
 
 // MetadataFollowOption is used to control the behavior of the metadata following
@@ -89,6 +90,8 @@ func makeSubscribeMetadataFunc(option *MetadataFollowOption, processEventFn Proc
 					glog.Errorf("process %v: %v", resp, err)
 				case FatalOnError:
 					glog.Fatalf("process %v: %v", resp, err)
+				case ReturnOnError:
+					return err
 				case RetryForeverOnError:
 					util.RetryUntil("followMetaUpdates", func() error {
 						return processEventFn(resp)
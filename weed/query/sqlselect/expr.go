@@ -0,0 +1,312 @@
+package sqlselect
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expr is a node of a parsed SQL expression
+type Expr interface {
+	eval(env *env) (Value, error)
+}
+
+// env is what an expression is evaluated against, a record, or the aggregated results
+type env struct {
+	record     Record
+	aggregates []Value
+}
+
+// PathElement is a step into a record, either a column or field name or an array index
+type PathElement struct {
+	Name string
+	// Quoted names are case sensitive
+	Quoted bool
+	Index  int
+	// IsIndex is set for array indexes, like [0]
+	IsIndex bool
+}
+
+type literal struct {
+	value Value
+}
+
+func (e *literal) eval(env *env) (Value, error) {
+	return e.value, nil
+}
+
+type columnRef struct {
+	path []PathElement
+}
+
+func (e *columnRef) eval(env *env) (Value, error) {
+	if env.record == nil {
+		return nullValue, fmt.Errorf("column %s is not in an aggregate function", e.name())
+	}
+	return env.record.Get(e.path), nil
+}
+
+// name is used to name the output column
+func (e *columnRef) name() string {
+	for i := len(e.path) - 1; i >= 0; i-- {
+		if !e.path[i].IsIndex {
+			return e.path[i].Name
+		}
+	}
+	return ""
+}
+
+type unaryExpr struct {
+	op      string
+	operand Expr
+}
+
+func (e *unaryExpr) eval(env *env) (Value, error) {
+	v, err := e.operand.eval(env)
+	if err != nil || v.IsNull() {
+		return v, err
+	}
+	if e.op == "NOT" {
+		b, known := v.boolean()
+		if !known {
+			return nullValue, nil
+		}
+		return BoolValue(!b), nil
+	}
+	if e.op == "-" {
+		return arithmetic("-", IntValue(0), v)
+	}
+	return arithmetic("+", IntValue(0), v)
+}
+
+type binaryExpr struct {
+	op          string
+	left, right Expr
+}
+
+func (e *binaryExpr) eval(env *env) (Value, error) {
+	left, err := e.left.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+	switch e.op {
+	case "AND", "OR":
+		return e.evalLogical(env, left)
+	}
+	right, err := e.right.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+	switch e.op {
+	case "+", "-", "*", "/", "%":
+		return arithmetic(e.op, left, right)
+	case "||":
+		if left.IsNull() || right.IsNull() {
+			return nullValue, nil
+		}
+		return StringValue(left.String() + right.String()), nil
+	}
+	if left.IsNull() || right.IsNull() {
+		return nullValue, nil
+	}
+	result, ok := compare(left, right)
+	if !ok {
+		return nullValue, nil
+	}
+	switch e.op {
+	case "=":
+		return BoolValue(result == 0), nil
+	case "!=", "<>":
+		return BoolValue(result != 0), nil
+	case "<":
+		return BoolValue(result < 0), nil
+	case "<=":
+		return BoolValue(result <= 0), nil
+	case ">":
+		return BoolValue(result > 0), nil
+	case ">=":
+		return BoolValue(result >= 0), nil
+	}
+	return nullValue, fmt.Errorf("unknown operator %s", e.op)
+}
+
+// evalLogical follows the three valued logic of SQL, and skips the right side when the left decides
+func (e *binaryExpr) evalLogical(env *env, left Value) (Value, error) {
+	l, lKnown := left.boolean()
+	if lKnown && l == (e.op == "OR") {
+		return BoolValue(l), nil
+	}
+	right, err := e.right.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+	r, rKnown := right.boolean()
+	if rKnown && r == (e.op == "OR") {
+		return BoolValue(r), nil
+	}
+	if !lKnown || !rKnown {
+		return nullValue, nil
+	}
+	return BoolValue(e.op == "AND"), nil
+}
+
+type likeExpr struct {
+	operand, pattern Expr
+	escape           rune
+	not              bool
+}
+
+func (e *likeExpr) eval(env *env) (Value, error) {
+	v, err := e.operand.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+	pattern, err := e.pattern.eval(env)
+	if err != nil || v.IsNull() || pattern.IsNull() {
+		return nullValue, err
+	}
+	return BoolValue(like(v.String(), pattern.String(), e.escape) != e.not), nil
+}
+
+type isNullExpr struct {
+	operand Expr
+	not     bool
+}
+
+func (e *isNullExpr) eval(env *env) (Value, error) {
+	v, err := e.operand.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+	return BoolValue(v.IsNull() != e.not), nil
+}
+
+type inExpr struct {
+	operand Expr
+	list    []Expr
+	not     bool
+}
+
+func (e *inExpr) eval(env *env) (Value, error) {
+	v, err := e.operand.eval(env)
+	if err != nil || v.IsNull() {
+		return nullValue, err
+	}
+	sawNull := false
+	for _, item := range e.list {
+		x, err := item.eval(env)
+		if err != nil {
+			return nullValue, err
+		}
+		if x.IsNull() {
+			sawNull = true
+			continue
+		}
+		if result, ok := compare(v, x); ok && result == 0 {
+			return BoolValue(!e.not), nil
+		}
+	}
+	if sawNull {
+		return nullValue, nil
+	}
+	return BoolValue(e.not), nil
+}
+
+type betweenExpr struct {
+	operand, low, high Expr
+	not                bool
+}
+
+func (e *betweenExpr) eval(env *env) (Value, error) {
+	and := &binaryExpr{
+		op:    "AND",
+		left:  &binaryExpr{op: ">=", left: e.operand, right: e.low},
+		right: &binaryExpr{op: "<=", left: e.operand, right: e.high},
+	}
+	v, err := and.eval(env)
+	if err != nil || !e.not {
+		return v, err
+	}
+	return (&unaryExpr{op: "NOT", operand: &literal{value: v}}).eval(env)
+}
+
+type castExpr struct {
+	operand Expr
+	to      Type
+}
+
+func (e *castExpr) eval(env *env) (Value, error) {
+	v, err := e.operand.eval(env)
+	if err != nil {
+		return nullValue, err
+	}
+	return cast(v, e.to)
+}
+
+type functionExpr struct {
+	name string
+	args []Expr
+}
+
+// functionArgs is the number of arguments of each function, -1 for any
+var functionArgs = map[string]int{
+	"LOWER":            1,
+	"UPPER":            1,
+	"TRIM":             1,
+	"CHAR_LENGTH":      1,
+	"CHARACTER_LENGTH": 1,
+	"NULLIF":           2,
+	"COALESCE":         -1,
+}
+
+func (e *functionExpr) eval(env *env) (Value, error) {
+	args := make([]Value, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nullValue, err
+		}
+		args[i] = v
+	}
+	switch e.name {
+	case "COALESCE":
+		for _, v := range args {
+			if !v.IsNull() {
+				return v, nil
+			}
+		}
+		return nullValue, nil
+	case "NULLIF":
+		if result, ok := compare(args[0], args[1]); ok && result == 0 {
+			return nullValue, nil
+		}
+		return args[0], nil
+	}
+	if args[0].IsNull() {
+		return nullValue, nil
+	}
+	s := args[0].String()
+	switch e.name {
+	case "LOWER":
+		return StringValue(strings.ToLower(s)), nil
+	case "UPPER":
+		return StringValue(strings.ToUpper(s)), nil
+	case "TRIM":
+		return StringValue(strings.TrimSpace(s)), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return IntValue(int64(utf8.RuneCountInString(s))), nil
+	}
+	return nullValue, fmt.Errorf("unknown function %s", e.name)
+}
+
+// aggregateExpr reads the result of an aggregate function, computed by the Selection
+type aggregateExpr struct {
+	index int
+}
+
+func (e *aggregateExpr) eval(env *env) (Value, error) {
+	if env.aggregates == nil {
+		return nullValue, fmt.Errorf("aggregate function used outside of the projection")
+	}
+	return env.aggregates[e.index], nil
+}
@@ -0,0 +1,124 @@
+package sqlselect
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at %d", t.text, t.pos)
+}
+
+// is reports whether the token is the given operator or case insensitive keyword
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokenOperator:
+		return t.text == s
+	case tokenIdent:
+		return strings.EqualFold(t.text, s)
+	}
+	return false
+}
+
+var twoCharOperators = []string{"<=", ">=", "<>", "!=", "||"}
+
+func tokenize(sql string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(sql); {
+		c := rune(sql[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			text, next, err := scanQuoted(sql, i)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i = next
+		case isDigit(c) || c == '.' && i+1 < len(sql) && isDigit(rune(sql[i+1])):
+			start := i
+			for i < len(sql) && (isDigit(rune(sql[i])) || sql[i] == '.') {
+				i++
+			}
+			if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+				j := i + 1
+				if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+					j++
+				}
+				if j < len(sql) && isDigit(rune(sql[j])) {
+					for i = j; i < len(sql) && isDigit(rune(sql[i])); i++ {
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: sql[start:i], pos: start})
+		case c == '_' || unicode.IsLetter(c) || c >= 0x80:
+			start := i
+			for i < len(sql) && (sql[i] == '_' || sql[i] >= 0x80 || unicode.IsLetter(rune(sql[i])) || isDigit(rune(sql[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: sql[start:i], pos: start})
+		default:
+			op := string(c)
+			for _, two := range twoCharOperators {
+				if strings.HasPrefix(sql[i:], two) {
+					op = two
+				}
+			}
+			if !strings.Contains("=<>!|+-*/%(),.[]", op[:1]) || op == "!" || op == "|" {
+				return nil, fmt.Errorf("unexpected character %q at %d", op, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(sql)}), nil
+}
+
+// scanQuoted reads a quoted string starting at sql[start], a doubled quote stands for itself
+func scanQuoted(sql string, start int) (text string, next int, err error) {
+	quote := sql[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			sb.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			sb.WriteByte(quote)
+			i++
+			continue
+		}
+		return sb.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated quote at %d", start)
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
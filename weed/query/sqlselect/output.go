@@ -0,0 +1,108 @@
+package sqlselect
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// RecordWriter serializes the selected columns of a record
+type RecordWriter interface {
+	WriteRecord(columns []Column) error
+}
+
+type CSVOutput struct {
+	// QuoteFields is ALWAYS or ASNEEDED
+	QuoteFields          string
+	RecordDelimiter      string
+	FieldDelimiter       string
+	QuoteCharacter       string
+	QuoteEscapeCharacter string
+}
+
+type csvWriter struct {
+	w                               io.Writer
+	alwaysQuote                     bool
+	recordDelimiter, fieldDelimiter string
+	quoteCharacter, escapedQuote    string
+}
+
+func NewCSVWriter(w io.Writer, out CSVOutput) RecordWriter {
+	cw := &csvWriter{
+		w:               w,
+		alwaysQuote:     strings.EqualFold(out.QuoteFields, "ALWAYS"),
+		recordDelimiter: withDefault(out.RecordDelimiter, "\n"),
+		fieldDelimiter:  withDefault(out.FieldDelimiter, ","),
+		quoteCharacter:  withDefault(out.QuoteCharacter, `"`),
+	}
+	cw.escapedQuote = withDefault(out.QuoteEscapeCharacter, cw.quoteCharacter) + cw.quoteCharacter
+	return cw
+}
+
+func withDefault(s, defaultValue string) string {
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+func (cw *csvWriter) WriteRecord(columns []Column) error {
+	var sb strings.Builder
+	for i, column := range columns {
+		if i > 0 {
+			sb.WriteString(cw.fieldDelimiter)
+		}
+		field := column.Value.String()
+		if cw.alwaysQuote || strings.Contains(field, cw.fieldDelimiter) || strings.Contains(field, cw.quoteCharacter) ||
+			strings.Contains(field, cw.recordDelimiter) || strings.ContainsAny(field, "\r\n") {
+			field = cw.quoteCharacter + strings.ReplaceAll(field, cw.quoteCharacter, cw.escapedQuote) + cw.quoteCharacter
+		}
+		sb.WriteString(field)
+	}
+	sb.WriteString(cw.recordDelimiter)
+	_, err := io.WriteString(cw.w, sb.String())
+	return err
+}
+
+type jsonWriter struct {
+	w               io.Writer
+	recordDelimiter string
+}
+
+func NewJSONWriter(w io.Writer, recordDelimiter string) RecordWriter {
+	return &jsonWriter{w: w, recordDelimiter: withDefault(recordDelimiter, "\n")}
+}
+
+func (jw *jsonWriter) WriteRecord(columns []Column) error {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		name, _ := json.Marshal(column.Name)
+		sb.Write(name)
+		sb.WriteByte(':')
+		sb.WriteString(jsonLiteral(column.Value))
+	}
+	sb.WriteByte('}')
+	sb.WriteString(jw.recordDelimiter)
+	_, err := io.WriteString(jw.w, sb.String())
+	return err
+}
+
+func jsonLiteral(v Value) string {
+	switch v.Type {
+	case Null:
+		return "null"
+	case String:
+		s, _ := json.Marshal(v.s)
+		return string(s)
+	case Float:
+		if s, err := json.Marshal(v.f); err == nil {
+			return string(s)
+		}
+		return "null"
+	}
+	return v.String()
+}
@@ -0,0 +1,490 @@
+package sqlselect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Statement is a parsed SELECT ... FROM S3Object [alias] [WHERE ...] [LIMIT n]
+type Statement struct {
+	// projections is empty for SELECT *
+	projections []projection
+	where       Expr
+	limit       int64
+	aggregates  []aggregateFunc
+}
+
+type projection struct {
+	expr Expr
+	name string
+}
+
+type aggregateFunc struct {
+	name string
+	// arg is nil for COUNT(*)
+	arg Expr
+}
+
+// HasFilter reports whether the statement has a WHERE clause
+func (stmt *Statement) HasFilter() bool {
+	return stmt.where != nil
+}
+
+// Match evaluates the WHERE clause, records without a WHERE clause always match
+func (stmt *Statement) Match(record Record) (bool, error) {
+	if stmt.where == nil {
+		return true, nil
+	}
+	v, err := stmt.where.eval(&env{record: record})
+	if err != nil {
+		return false, err
+	}
+	b, known := v.boolean()
+	return known && b, nil
+}
+
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "ESCAPE": true,
+	"IS": true, "NULL": true, "IN": true, "BETWEEN": true, "CAST": true,
+	"TRUE": true, "FALSE": true,
+}
+
+var castTypes = map[string]Type{
+	"INT":     Int,
+	"INTEGER": Int,
+	"BIGINT":  Int,
+	"FLOAT":   Float,
+	"REAL":    Float,
+	"DOUBLE":  Float,
+	"DECIMAL": Float,
+	"NUMERIC": Float,
+	"STRING":  String,
+	"VARCHAR": String,
+	"CHAR":    String,
+	"BOOL":    Bool,
+	"BOOLEAN": Bool,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	stmt   *Statement
+	// columns collects the column references, to strip the table alias once it is known
+	columns []*columnRef
+	// inAggregate and bareColumns check that aggregate queries do not mix in plain columns
+	inAggregate bool
+	bareColumns int
+	inWhere     bool
+}
+
+// Parse parses the subset of SQL supported by S3 Select
+func Parse(sql string) (*Statement, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, stmt: &Statement{limit: -1}}
+	if err = p.parseStatement(); err != nil {
+		return nil, err
+	}
+	return p.stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given operator or keyword
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("expect %s, got %v", s, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parseStatement() error {
+	if err := p.expect("SELECT"); err != nil {
+		return err
+	}
+	if err := p.parseProjections(); err != nil {
+		return err
+	}
+	if len(p.stmt.aggregates) > 0 && p.bareColumns > 0 {
+		return fmt.Errorf("columns must be in aggregate functions when the projection has aggregates")
+	}
+	if err := p.expect("FROM"); err != nil {
+		return err
+	}
+	if t := p.next(); t.kind != tokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		return fmt.Errorf("expect S3Object, got %v", t)
+	}
+	alias := ""
+	if p.accept("AS") || p.isAlias() {
+		alias = p.next().text
+	}
+	if p.accept("WHERE") {
+		p.inWhere = true
+		where, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		p.stmt.where = where
+	}
+	if p.accept("LIMIT") {
+		t := p.next()
+		limit, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokenNumber || err != nil || limit < 0 {
+			return fmt.Errorf("invalid LIMIT %v", t)
+		}
+		p.stmt.limit = limit
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return fmt.Errorf("unexpected %v", t)
+	}
+	if alias != "" {
+		for _, c := range p.columns {
+			if len(c.path) > 1 && !c.path[0].IsIndex && strings.EqualFold(c.path[0].Name, alias) {
+				c.path = c.path[1:]
+			}
+		}
+	}
+	return nil
+}
+
+// isAlias reports whether the next token is a name, which is not a keyword
+func (p *parser) isAlias() bool {
+	t := p.peek()
+	return t.kind == tokenQuotedIdent || t.kind == tokenIdent && !reservedWords[strings.ToUpper(t.text)]
+}
+
+func (p *parser) parseProjections() error {
+	if p.accept("*") {
+		return nil
+	}
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		proj := projection{expr: expr}
+		if p.accept("AS") || p.isAlias() {
+			if !p.isAlias() {
+				return fmt.Errorf("expect a column alias, got %v", p.peek())
+			}
+			proj.name = p.next().text
+		} else if c, ok := expr.(*columnRef); ok {
+			proj.name = c.name()
+		}
+		if proj.name == "" {
+			proj.name = fmt.Sprintf("_%d", len(p.stmt.projections)+1)
+		}
+		p.stmt.projections = append(p.stmt.projections, proj)
+		if !p.accept(",") {
+			break
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseBinary(0)
+}
+
+// binaryLevels lists the binary operators from the lowest precedence
+var binaryLevels = [][]string{
+	{"OR"},
+	{"AND"},
+	nil, // NOT and the predicates
+	{"+", "-", "||"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (Expr, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	if binaryLevels[level] == nil {
+		return p.parsePredicate(level)
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, candidate := range binaryLevels[level] {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+var comparisonOperators = []string{"=", "!=", "<>", "<=", ">=", "<", ">"}
+
+func (p *parser) parsePredicate(level int) (Expr, error) {
+	if p.accept("NOT") {
+		operand, err := p.parsePredicate(level)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", operand: operand}, nil
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range comparisonOperators {
+		if p.accept(op) {
+			right, err := p.parseBinary(level + 1)
+			if err != nil {
+				return nil, err
+			}
+			return &binaryExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	if p.accept("IS") {
+		not := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{operand: left, not: not}, nil
+	}
+	not := p.accept("NOT")
+	switch {
+	case p.accept("LIKE"):
+		pattern, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		e := &likeExpr{operand: left, pattern: pattern, not: not}
+		if p.accept("ESCAPE") {
+			t := p.next()
+			if t.kind != tokenString || utf8.RuneCountInString(t.text) != 1 {
+				return nil, fmt.Errorf("expect a single character ESCAPE, got %v", t)
+			}
+			e.escape, _ = utf8.DecodeRuneInString(t.text)
+		}
+		return e, nil
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		e := &inExpr{operand: left, not: not}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			e.list = append(e.list, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return e, p.expect(")")
+	case p.accept("BETWEEN"):
+		low, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{operand: left, low: low, high: high, not: not}, nil
+	}
+	if not {
+		return nil, fmt.Errorf("expect LIKE, IN or BETWEEN after NOT, got %v", p.peek())
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	for _, op := range []string{"-", "+"} {
+		if p.accept(op) {
+			operand, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &unaryExpr{op: op, operand: operand}, nil
+		}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{value: IntValue(i)}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %v", t)
+		}
+		return &literal{value: FloatValue(f)}, nil
+	case tokenString:
+		return &literal{value: StringValue(t.text)}, nil
+	case tokenQuotedIdent:
+		return p.parseColumnRef(PathElement{Name: t.text, Quoted: true})
+	case tokenOperator:
+		if t.text == "(" {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		}
+	case tokenIdent:
+		keyword := strings.ToUpper(t.text)
+		switch keyword {
+		case "TRUE", "FALSE":
+			return &literal{value: BoolValue(keyword == "TRUE")}, nil
+		case "NULL":
+			return &literal{value: nullValue}, nil
+		case "CAST":
+			return p.parseCast()
+		}
+		if p.peek().is("(") {
+			return p.parseFunction(keyword)
+		}
+		if !reservedWords[keyword] {
+			return p.parseColumnRef(PathElement{Name: t.text})
+		}
+	}
+	return nil, fmt.Errorf("unexpected %v", t)
+}
+
+func (p *parser) parseColumnRef(first PathElement) (Expr, error) {
+	c := &columnRef{path: []PathElement{first}}
+	for {
+		if p.accept(".") {
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				return nil, fmt.Errorf("expect a field name, got %v", t)
+			}
+			c.path = append(c.path, PathElement{Name: t.text, Quoted: t.kind == tokenQuotedIdent})
+			continue
+		}
+		if p.accept("[") {
+			t := p.next()
+			index, err := strconv.Atoi(t.text)
+			if t.kind != tokenNumber || err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid array index %v", t)
+			}
+			c.path = append(c.path, PathElement{Index: index, IsIndex: true})
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+	if !p.inAggregate {
+		p.bareColumns++
+	}
+	p.columns = append(p.columns, c)
+	return c, nil
+}
+
+func (p *parser) parseCast() (Expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	operand, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect("AS"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	to, found := castTypes[strings.ToUpper(t.text)]
+	if t.kind != tokenIdent || !found {
+		return nil, fmt.Errorf("unsupported CAST type %v", t)
+	}
+	return &castExpr{operand: operand, to: to}, p.expect(")")
+}
+
+func (p *parser) parseFunction(name string) (Expr, error) {
+	p.next() // (
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		return p.parseAggregate(name)
+	}
+	argCount, found := functionArgs[name]
+	if !found {
+		return nil, fmt.Errorf("unsupported function %s", name)
+	}
+	e := &functionExpr{name: name}
+	for !p.peek().is(")") {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		e.args = append(e.args, arg)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if argCount >= 0 && len(e.args) != argCount || len(e.args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments to %s", name)
+	}
+	return e, nil
+}
+
+func (p *parser) parseAggregate(name string) (Expr, error) {
+	if p.inAggregate || p.inWhere {
+		return nil, fmt.Errorf("aggregate function %s is only allowed in the projection", name)
+	}
+	f := aggregateFunc{name: name}
+	if !(name == "COUNT" && p.accept("*")) {
+		p.inAggregate = true
+		arg, err := p.parseExpr()
+		p.inAggregate = false
+		if err != nil {
+			return nil, err
+		}
+		f.arg = arg
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	p.stmt.aggregates = append(p.stmt.aggregates, f)
+	return &aggregateExpr{index: len(p.stmt.aggregates) - 1}, nil
+}
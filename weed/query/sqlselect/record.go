@@ -0,0 +1,361 @@
+package sqlselect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// MaxRecordSize is the longest record a RecordReader accepts
+const MaxRecordSize = 4 * 1024 * 1024
+
+// Record is one CSV row or JSON object
+type Record interface {
+	// Get returns the value at the path, or NULL if it is missing
+	Get(path []PathElement) Value
+	// Columns returns the top level values, for SELECT *
+	Columns() []Column
+}
+
+type Column struct {
+	Name  string
+	Value Value
+}
+
+// RecordParser turns the raw bytes of a record into a Record
+type RecordParser interface {
+	// Parse returns a nil Record for blank lines and comments
+	Parse(raw []byte) (Record, error)
+}
+
+type CSVInput struct {
+	// FileHeaderInfo is NONE, USE or IGNORE
+	FileHeaderInfo             string
+	RecordDelimiter            string
+	FieldDelimiter             string
+	QuoteCharacter             string
+	QuoteEscapeCharacter       string
+	Comments                   string
+	AllowQuotedRecordDelimiter bool
+}
+
+// HasHeader reports whether the first record is a header, to use or to ignore
+func (in CSVInput) HasHeader() bool {
+	switch strings.ToUpper(in.FileHeaderInfo) {
+	case "USE", "IGNORE":
+		return true
+	}
+	return false
+}
+
+// Delimiter is the record delimiter, a new line by default
+func (in CSVInput) Delimiter() []byte {
+	if in.RecordDelimiter == "" {
+		return []byte("\n")
+	}
+	return []byte(in.RecordDelimiter)
+}
+
+type CSVParser struct {
+	delimiter, quote, escape rune
+	comments                 string
+	trimCR                   bool
+	useHeader                bool
+	names                    []string
+	columns                  map[string]int
+	foldedColumns            map[string]int
+}
+
+func NewCSVParser(in CSVInput) *CSVParser {
+	p := &CSVParser{
+		delimiter: firstRune(in.FieldDelimiter, ','),
+		quote:     firstRune(in.QuoteCharacter, '"'),
+		comments:  in.Comments,
+		trimCR:    in.RecordDelimiter == "" || in.RecordDelimiter == "\n",
+		useHeader: strings.EqualFold(in.FileHeaderInfo, "USE"),
+	}
+	p.escape = firstRune(in.QuoteEscapeCharacter, p.quote)
+	if p.comments == "" {
+		p.comments = "#"
+	}
+	return p
+}
+
+func firstRune(s string, defaultRune rune) rune {
+	if s == "" {
+		return defaultRune
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+// SetHeader takes the column names from the header record, if the header is to be used
+func (p *CSVParser) SetHeader(raw []byte) {
+	if p.useHeader {
+		p.SetColumnNames(p.split(p.trim(raw)))
+	}
+}
+
+// ColumnNames returns the column names set from the header
+func (p *CSVParser) ColumnNames() []string {
+	return p.names
+}
+
+func (p *CSVParser) SetColumnNames(names []string) {
+	p.names = names
+	p.columns = make(map[string]int)
+	p.foldedColumns = make(map[string]int)
+	for i := len(names) - 1; i >= 0; i-- {
+		p.columns[names[i]] = i
+		p.foldedColumns[strings.ToLower(names[i])] = i
+	}
+}
+
+func (p *CSVParser) trim(raw []byte) string {
+	if p.trimCR {
+		raw = bytes.TrimSuffix(raw, []byte("\r"))
+	}
+	return string(raw)
+}
+
+func (p *CSVParser) Parse(raw []byte) (Record, error) {
+	line := p.trim(raw)
+	if line == "" || strings.HasPrefix(line, p.comments) {
+		return nil, nil
+	}
+	return &csvRecord{parser: p, fields: p.split(line)}, nil
+}
+
+// split cuts a line into fields, removing the quotes around them
+func (p *CSVParser) split(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inQuote := false
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		next, nextSize := utf8.DecodeRuneInString(line[i+size:])
+		switch {
+		case inQuote && r == p.escape && next == p.quote && i+size < len(line):
+			field.WriteRune(p.quote)
+			i += size + nextSize
+			continue
+		case inQuote && r == p.quote:
+			inQuote = false
+		case !inQuote && r == p.quote:
+			inQuote = true
+		case !inQuote && r == p.delimiter:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+		i += size
+	}
+	return append(fields, field.String())
+}
+
+type csvRecord struct {
+	parser *CSVParser
+	fields []string
+}
+
+func (r *csvRecord) Get(path []PathElement) Value {
+	if len(path) != 1 || path[0].IsIndex {
+		return nullValue
+	}
+	name := path[0].Name
+	index, found := r.parser.columns[name]
+	if !found && !path[0].Quoted {
+		index, found = r.parser.foldedColumns[strings.ToLower(name)]
+	}
+	if !found && strings.HasPrefix(name, "_") {
+		if n, err := strconv.Atoi(name[1:]); err == nil && n > 0 {
+			index, found = n-1, true
+		}
+	}
+	if !found || index >= len(r.fields) {
+		return nullValue
+	}
+	return StringValue(r.fields[index])
+}
+
+func (r *csvRecord) Columns() []Column {
+	columns := make([]Column, len(r.fields))
+	for i, field := range r.fields {
+		name := fmt.Sprintf("_%d", i+1)
+		if i < len(r.parser.names) {
+			name = r.parser.names[i]
+		}
+		columns[i] = Column{Name: name, Value: StringValue(field)}
+	}
+	return columns
+}
+
+type JSONParser struct{}
+
+func (JSONParser) Parse(raw []byte) (Record, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	if !gjson.ValidBytes(raw) {
+		return nil, fmt.Errorf("invalid json record: %.64q", raw)
+	}
+	return &jsonRecord{root: gjson.ParseBytes(raw)}, nil
+}
+
+type jsonRecord struct {
+	root gjson.Result
+}
+
+func (r *jsonRecord) Get(path []PathElement) Value {
+	v := r.root
+	for _, elem := range path {
+		if elem.IsIndex {
+			if !v.IsArray() {
+				return nullValue
+			}
+			v = v.Get(strconv.Itoa(elem.Index))
+		} else {
+			v = jsonField(v, elem.Name, !elem.Quoted)
+		}
+		if !v.Exists() {
+			return nullValue
+		}
+	}
+	return fromJson(v)
+}
+
+// jsonField looks up a key of an object, unquoted names fall back to a case insensitive match
+func jsonField(v gjson.Result, name string, fold bool) (found gjson.Result) {
+	if !v.IsObject() {
+		return
+	}
+	var folded gjson.Result
+	v.ForEach(func(key, value gjson.Result) bool {
+		if key.Str == name {
+			found = value
+			return false
+		}
+		if fold && !folded.Exists() && strings.EqualFold(key.Str, name) {
+			folded = value
+		}
+		return true
+	})
+	if found.Exists() {
+		return found
+	}
+	return folded
+}
+
+func (r *jsonRecord) Columns() []Column {
+	if !r.root.IsObject() {
+		return []Column{{Name: "_1", Value: fromJson(r.root)}}
+	}
+	var columns []Column
+	r.root.ForEach(func(key, value gjson.Result) bool {
+		columns = append(columns, Column{Name: key.Str, Value: fromJson(value)})
+		return true
+	})
+	return columns
+}
+
+func fromJson(v gjson.Result) Value {
+	switch v.Type {
+	case gjson.False:
+		return BoolValue(false)
+	case gjson.True:
+		return BoolValue(true)
+	case gjson.Number:
+		if !strings.ContainsAny(v.Raw, ".eE") {
+			if i, err := strconv.ParseInt(v.Raw, 10, 64); err == nil {
+				return IntValue(i)
+			}
+		}
+		return FloatValue(v.Num)
+	case gjson.String:
+		return StringValue(v.Str)
+	case gjson.JSON:
+		return jsonValue(v.Raw)
+	}
+	return nullValue
+}
+
+// RecordReader reads raw records from a stream
+type RecordReader interface {
+	// Next returns io.EOF after the last record
+	Next() ([]byte, error)
+}
+
+type delimitedReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewDelimitedReader splits the stream by the record delimiter.
+// A non zero quote character allows delimiters inside quoted fields.
+func NewDelimitedReader(r io.Reader, delimiter []byte, quote byte) RecordReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxRecordSize)
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := IndexDelimiter(data, delimiter, quote); i >= 0 {
+			return i + len(delimiter), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	return &delimitedReader{scanner: scanner}
+}
+
+func (r *delimitedReader) Next() ([]byte, error) {
+	if r.scanner.Scan() {
+		return r.scanner.Bytes(), nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// IndexDelimiter finds the first record delimiter, skipping quoted ones if the quote character is not zero
+func IndexDelimiter(data, delimiter []byte, quote byte) int {
+	if quote == 0 {
+		return bytes.Index(data, delimiter)
+	}
+	inQuote := false
+	for i := 0; i < len(data); i++ {
+		if data[i] == quote {
+			inQuote = !inQuote
+		} else if !inQuote && bytes.HasPrefix(data[i:], delimiter) {
+			return i
+		}
+	}
+	return -1
+}
+
+type jsonDocumentReader struct {
+	decoder *json.Decoder
+}
+
+// NewJSONDocumentReader reads a sequence of JSON values, which may span multiple lines
+func NewJSONDocumentReader(r io.Reader) RecordReader {
+	return &jsonDocumentReader{decoder: json.NewDecoder(r)}
+}
+
+func (r *jsonDocumentReader) Next() ([]byte, error) {
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
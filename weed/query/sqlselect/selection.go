@@ -0,0 +1,141 @@
+package sqlselect
+
+import "fmt"
+
+// Selection runs a statement over a sequence of records, writing out the selected ones
+type Selection struct {
+	stmt       *Statement
+	writer     RecordWriter
+	returned   int64
+	aggregates []aggregateState
+}
+
+type aggregateState struct {
+	count    int64
+	sum      Value
+	min, max Value
+}
+
+func (stmt *Statement) NewSelection(writer RecordWriter) *Selection {
+	return &Selection{
+		stmt:       stmt,
+		writer:     writer,
+		aggregates: make([]aggregateState, len(stmt.aggregates)),
+	}
+}
+
+// Done reports whether the LIMIT is reached, so later records can be skipped
+func (s *Selection) Done() bool {
+	return len(s.stmt.aggregates) == 0 && s.stmt.limit >= 0 && s.returned >= s.stmt.limit
+}
+
+// Write evaluates the statement over one record
+func (s *Selection) Write(record Record) error {
+	if record == nil || s.Done() {
+		return nil
+	}
+	matched, err := s.stmt.Match(record)
+	if err != nil || !matched {
+		return err
+	}
+	if len(s.stmt.aggregates) > 0 {
+		return s.accumulate(record)
+	}
+	s.returned++
+	if len(s.stmt.projections) == 0 {
+		return s.writer.WriteRecord(record.Columns())
+	}
+	return s.project(&env{record: record})
+}
+
+// Close writes the result of the aggregate functions, if any
+func (s *Selection) Close() error {
+	if len(s.stmt.aggregates) == 0 {
+		return nil
+	}
+	results := make([]Value, len(s.aggregates))
+	for i, f := range s.stmt.aggregates {
+		results[i] = s.aggregates[i].result(f.name)
+	}
+	return s.project(&env{aggregates: results})
+}
+
+func (s *Selection) project(env *env) error {
+	columns := make([]Column, len(s.stmt.projections))
+	for i, proj := range s.stmt.projections {
+		v, err := proj.expr.eval(env)
+		if err != nil {
+			return err
+		}
+		columns[i] = Column{Name: proj.name, Value: v}
+	}
+	return s.writer.WriteRecord(columns)
+}
+
+func (s *Selection) accumulate(record Record) error {
+	for i, f := range s.stmt.aggregates {
+		state := &s.aggregates[i]
+		if f.arg == nil {
+			state.count++
+			continue
+		}
+		v, err := f.arg.eval(&env{record: record})
+		if err != nil {
+			return err
+		}
+		if v.IsNull() || v.isBlank() {
+			continue
+		}
+		switch f.name {
+		case "SUM", "AVG":
+			n, ok := v.number()
+			if !ok {
+				return fmt.Errorf("%s of non numeric value %q", f.name, v.String())
+			}
+			if state.count == 0 {
+				state.sum = n
+			} else if state.sum, err = arithmetic("+", state.sum, n); err != nil {
+				return err
+			}
+		case "MIN", "MAX":
+			if state.count == 0 {
+				state.min, state.max = v, v
+				break
+			}
+			current := state.min
+			if f.name == "MAX" {
+				current = state.max
+			}
+			result, ok := compare(v, current)
+			if !ok {
+				return fmt.Errorf("%s of values %q and %q which can not be compared", f.name, v.String(), current.String())
+			}
+			if f.name == "MIN" && result < 0 {
+				state.min = v
+			}
+			if f.name == "MAX" && result > 0 {
+				state.max = v
+			}
+		}
+		state.count++
+	}
+	return nil
+}
+
+func (state *aggregateState) result(name string) Value {
+	if name == "COUNT" {
+		return IntValue(state.count)
+	}
+	if state.count == 0 {
+		return nullValue
+	}
+	switch name {
+	case "SUM":
+		return state.sum
+	case "AVG":
+		return FloatValue(state.sum.float() / float64(state.count))
+	case "MIN":
+		return state.min
+	}
+	return state.max
+}
@@ -0,0 +1,170 @@
+package sqlselect
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const csvData = "name,age,city\n" +
+	"alice,30,Berlin\n" +
+	"bob,25,\"Paris, France\"\n" +
+	"# a comment\n" +
+	"carol,41,london\n" +
+	"dave,,Berlin\n"
+
+const jsonData = `{"name":"alice","age":30,"address":{"city":"Berlin"},"tags":["a","b"]}
+{"name":"bob","age":25.5,"address":{"city":"Paris"},"tags":[]}
+{"name":"carol","age":41,"active":true}
+`
+
+func selectCSV(t *testing.T, sql string, header string) string {
+	stmt, err := Parse(sql)
+	if !assert.NoError(t, err, sql) {
+		return ""
+	}
+	in := CSVInput{FileHeaderInfo: header}
+	parser := NewCSVParser(in)
+	reader := NewDelimitedReader(strings.NewReader(csvData), in.Delimiter(), 0)
+	var out bytes.Buffer
+	sel := stmt.NewSelection(NewCSVWriter(&out, CSVOutput{}))
+	if in.HasHeader() {
+		raw, _ := reader.Next()
+		parser.SetHeader(raw)
+	}
+	for !sel.Done() {
+		raw, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		record, err := parser.Parse(raw)
+		assert.NoError(t, err)
+		assert.NoError(t, sel.Write(record), sql)
+	}
+	assert.NoError(t, sel.Close())
+	return out.String()
+}
+
+func selectJSON(t *testing.T, sql string) string {
+	stmt, err := Parse(sql)
+	if !assert.NoError(t, err, sql) {
+		return ""
+	}
+	reader := NewJSONDocumentReader(strings.NewReader(jsonData))
+	var out bytes.Buffer
+	sel := stmt.NewSelection(NewJSONWriter(&out, ""))
+	for {
+		raw, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		record, err := JSONParser{}.Parse(raw)
+		assert.NoError(t, err)
+		assert.NoError(t, sel.Write(record), sql)
+	}
+	assert.NoError(t, sel.Close())
+	return out.String()
+}
+
+func TestSelectCSV(t *testing.T) {
+	tests := []struct {
+		sql, header, expected string
+	}{
+		{"SELECT * FROM S3Object", "USE", "alice,30,Berlin\nbob,25,\"Paris, France\"\ncarol,41,london\ndave,,Berlin\n"},
+		{"select s.name from S3Object s where s.city = 'Berlin'", "USE", "alice\ndave\n"},
+		{"SELECT _1, _3 FROM S3Object WHERE _2 > 26", "IGNORE", "alice,Berlin\ncarol,london\n"},
+		{"SELECT name FROM S3Object WHERE CAST(age AS INT) >= 30 AND NOT city LIKE 'B%'", "USE", "carol\n"},
+		{"SELECT name FROM S3Object WHERE age < 30 OR LOWER(city) = 'london'", "USE", "bob\ncarol\n"},
+		{"SELECT name FROM S3Object WHERE age = '' OR age IS NULL", "USE", "dave\n"},
+		{"SELECT \"name\", age * 2 + 1 FROM S3Object WHERE name IN ('bob', 'carol')", "USE", "bob,51\ncarol,83\n"},
+		{"SELECT UPPER(name) AS n FROM S3Object LIMIT 2", "USE", "ALICE\nBOB\n"},
+		{"SELECT name FROM S3Object WHERE age BETWEEN 25 AND 30", "USE", "alice\nbob\n"},
+		{"SELECT COUNT(*), SUM(age), AVG(CAST(age AS FLOAT)), MIN(name), MAX(CAST(age AS INT)) FROM S3Object WHERE city <> 'london'", "USE", "3,55,27.5,alice,30\n"},
+		{"SELECT COUNT(age) FROM S3Object WHERE age != ''", "USE", "3\n"},
+		{"SELECT name FROM S3Object LIMIT 0", "USE", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, selectCSV(t, tt.sql, tt.header), tt.sql)
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	tests := []struct {
+		sql, expected string
+	}{
+		{"SELECT s.name, s.address.city FROM S3Object s WHERE s.age > 26",
+			`{"name":"alice","city":"Berlin"}` + "\n" + `{"name":"carol","city":null}` + "\n"},
+		{"SELECT s.tags[1] AS t FROM S3Object s WHERE s.tags[0] = 'a'", `{"t":"b"}` + "\n"},
+		{"SELECT * FROM S3Object WHERE active", `{"name":"carol","age":41,"active":true}` + "\n"},
+		{"SELECT name FROM S3Object WHERE age = 25.5", `{"name":"bob"}` + "\n"},
+		{"SELECT address FROM S3Object WHERE NAME = 'bob'", `{"address":{"city":"Paris"}}` + "\n"},
+		{"SELECT COUNT(*) AS c, SUM(age) FROM S3Object", `{"c":3,"_2":96.5}` + "\n"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, selectJSON(t, tt.sql), tt.sql)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, sql := range []string{
+		"",
+		"SELECT FROM S3Object",
+		"SELECT * FROM table",
+		"SELECT a FROM S3Object WHERE",
+		"SELECT a FROM S3Object LIMIT -1",
+		"SELECT name, COUNT(*) FROM S3Object",
+		"SELECT a FROM S3Object WHERE COUNT(*) > 1",
+		"SELECT SUM(MAX(a)) FROM S3Object",
+		"SELECT CAST(a AS DATE) FROM S3Object",
+		"SELECT a FROM S3Object WHERE a = 'unterminated",
+		"SELECT foo(a) FROM S3Object",
+		"SELECT a FROM S3Object extra tokens",
+	} {
+		_, err := Parse(sql)
+		assert.Error(t, err, sql)
+	}
+}
+
+func TestEvaluationErrors(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM S3Object WHERE CAST(age AS INT) > 1")
+	assert.NoError(t, err)
+	record, _ := NewCSVParser(CSVInput{}).Parse([]byte("x"))
+	record.(*csvRecord).parser.SetColumnNames([]string{"age"})
+	_, err = stmt.Match(record)
+	assert.Error(t, err)
+}
+
+func TestCSVParser(t *testing.T) {
+	p := NewCSVParser(CSVInput{FieldDelimiter: ";", QuoteEscapeCharacter: `\`})
+	record, err := p.Parse([]byte(`a;"b;\"c\"";"d""e"` + "\r"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", `b;"c"`, "de"}, record.(*csvRecord).fields)
+
+	record, _ = p.Parse([]byte("#comment"))
+	assert.Nil(t, record)
+}
+
+func TestDelimitedReader(t *testing.T) {
+	var records []string
+	reader := NewDelimitedReader(strings.NewReader("a|\"b|c\"|d"), []byte("|"), '"')
+	for {
+		raw, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		records = append(records, string(raw))
+	}
+	assert.Equal(t, []string{"a", `"b|c"`, "d"}, records)
+}
+
+func TestLike(t *testing.T) {
+	assert.True(t, like("hello", "h%o", 0))
+	assert.True(t, like("hello", "_ell_", 0))
+	assert.True(t, like("50%", `50\%`, '\\'))
+	assert.False(t, like("500", `50\%`, '\\'))
+	assert.False(t, like("hello", "h%x", 0))
+	assert.True(t, like("", "%", 0))
+}
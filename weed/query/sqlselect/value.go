@@ -0,0 +1,303 @@
+package sqlselect
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Type int
+
+const (
+	Null Type = iota
+	Bool
+	Int
+	Float
+	String
+	// JSON is a nested object or array, kept in its raw form
+	JSON
+)
+
+// Value is the result of evaluating an expression
+type Value struct {
+	Type Type
+	b    bool
+	i    int64
+	f    float64
+	s    string
+}
+
+var nullValue = Value{}
+
+func BoolValue(b bool) Value {
+	return Value{Type: Bool, b: b}
+}
+
+func IntValue(i int64) Value {
+	return Value{Type: Int, i: i}
+}
+
+func FloatValue(f float64) Value {
+	return Value{Type: Float, f: f}
+}
+
+func StringValue(s string) Value {
+	return Value{Type: String, s: s}
+}
+
+func jsonValue(raw string) Value {
+	return Value{Type: JSON, s: raw}
+}
+
+func (v Value) IsNull() bool {
+	return v.Type == Null
+}
+
+// String formats the value as it appears in CSV output
+func (v Value) String() string {
+	switch v.Type {
+	case Bool:
+		return strconv.FormatBool(v.b)
+	case Int:
+		return strconv.FormatInt(v.i, 10)
+	case Float:
+		return strconv.FormatFloat(v.f, 'g', -1, 64)
+	case String, JSON:
+		return v.s
+	}
+	return ""
+}
+
+// number converts the value to Int or Float, parsing strings as CSV fields need
+func (v Value) number() (Value, bool) {
+	switch v.Type {
+	case Int, Float:
+		return v, true
+	case String:
+		s := strings.TrimSpace(v.s)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return IntValue(i), true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return FloatValue(f), true
+		}
+	}
+	return nullValue, false
+}
+
+func (v Value) float() float64 {
+	if v.Type == Int {
+		return float64(v.i)
+	}
+	return v.f
+}
+
+// boolean reports the truth of the value, known is false for NULL and non boolean values
+func (v Value) boolean() (b, known bool) {
+	switch v.Type {
+	case Bool:
+		return v.b, true
+	case String:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v.s)); err == nil {
+			return b, true
+		}
+	}
+	return false, false
+}
+
+// compare orders two non NULL values, ok is false if they are not comparable
+func compare(a, b Value) (result int, ok bool) {
+	if a.Type == String && b.Type == String {
+		return strings.Compare(a.s, b.s), true
+	}
+	if a.Type == Bool || b.Type == Bool {
+		x, okA := a.boolean()
+		y, okB := b.boolean()
+		if !okA || !okB {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case y:
+			return -1, true
+		}
+		return 1, true
+	}
+	if a.Type == JSON || b.Type == JSON {
+		if a.Type == JSON && b.Type == JSON {
+			return strings.Compare(a.s, b.s), true
+		}
+		return 0, false
+	}
+	x, okA := a.number()
+	y, okB := b.number()
+	if !okA || !okB {
+		return 0, false
+	}
+	if x.Type == Int && y.Type == Int {
+		switch {
+		case x.i < y.i:
+			return -1, true
+		case x.i > y.i:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch xf, yf := x.float(), y.float(); {
+	case xf < yf:
+		return -1, true
+	case xf > yf:
+		return 1, true
+	}
+	return 0, true
+}
+
+func arithmetic(op string, a, b Value) (Value, error) {
+	if a.IsNull() || b.IsNull() {
+		return nullValue, nil
+	}
+	x, okA := a.number()
+	y, okB := b.number()
+	if !okA || !okB {
+		return nullValue, fmt.Errorf("invalid operands of %s: %q and %q", op, a.String(), b.String())
+	}
+	if x.Type == Int && y.Type == Int {
+		switch op {
+		case "+":
+			return IntValue(x.i + y.i), nil
+		case "-":
+			return IntValue(x.i - y.i), nil
+		case "*":
+			return IntValue(x.i * y.i), nil
+		case "/", "%":
+			if y.i == 0 {
+				return nullValue, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				return IntValue(x.i / y.i), nil
+			}
+			return IntValue(x.i % y.i), nil
+		}
+	}
+	xf, yf := x.float(), y.float()
+	switch op {
+	case "+":
+		return FloatValue(xf + yf), nil
+	case "-":
+		return FloatValue(xf - yf), nil
+	case "*":
+		return FloatValue(xf * yf), nil
+	case "/", "%":
+		if yf == 0 {
+			return nullValue, fmt.Errorf("division by zero")
+		}
+		if op == "/" {
+			return FloatValue(xf / yf), nil
+		}
+		return FloatValue(math.Mod(xf, yf)), nil
+	}
+	return nullValue, fmt.Errorf("unknown operator %s", op)
+}
+
+// isBlank reports whether the value is an empty string, like an empty CSV field
+func (v Value) isBlank() bool {
+	return v.Type == String && strings.TrimSpace(v.s) == ""
+}
+
+// cast converts the value to one of the CAST target types, blank strings become NULL except for STRING
+func cast(v Value, to Type) (Value, error) {
+	if v.IsNull() || v.Type == to {
+		return v, nil
+	}
+	if v.isBlank() && to != String {
+		return nullValue, nil
+	}
+	switch to {
+	case String:
+		return StringValue(v.String()), nil
+	case Bool:
+		if v.Type == Int || v.Type == Float {
+			return BoolValue(v.float() != 0), nil
+		}
+		if b, ok := v.boolean(); ok {
+			return BoolValue(b), nil
+		}
+	case Int:
+		if v.Type == Bool {
+			if v.b {
+				return IntValue(1), nil
+			}
+			return IntValue(0), nil
+		}
+		if n, ok := v.number(); ok {
+			if n.Type == Float {
+				return IntValue(int64(n.f)), nil
+			}
+			return n, nil
+		}
+	case Float:
+		if v.Type == Bool {
+			if v.b {
+				return FloatValue(1), nil
+			}
+			return FloatValue(0), nil
+		}
+		if n, ok := v.number(); ok {
+			return FloatValue(n.float()), nil
+		}
+	}
+	return nullValue, fmt.Errorf("can not cast %q to %s", v.String(), typeNames[to])
+}
+
+var typeNames = map[Type]string{
+	Null:   "NULL",
+	Bool:   "BOOL",
+	Int:    "INT",
+	Float:  "FLOAT",
+	String: "STRING",
+	JSON:   "JSON",
+}
+
+// like matches a LIKE pattern, where % matches any sequence and _ matches any single character
+func like(s, pattern string, escape rune) bool {
+	str, pat := []rune(s), []rune(pattern)
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		for j < len(pat) {
+			switch c := pat[j]; {
+			case c == escape && j+1 < len(pat):
+				if i >= len(str) || str[i] != pat[j+1] {
+					return false
+				}
+				i, j = i+1, j+2
+			case c == '%':
+				for j < len(pat) && pat[j] == '%' {
+					j++
+				}
+				if j == len(pat) {
+					return true
+				}
+				for k := i; k <= len(str); k++ {
+					if match(k, j) {
+						return true
+					}
+				}
+				return false
+			case c == '_':
+				if i >= len(str) {
+					return false
+				}
+				i, j = i+1, j+1
+			default:
+				if i >= len(str) || str[i] != c {
+					return false
+				}
+				i, j = i+1, j+1
+			}
+		}
+		return i == len(str)
+	}
+	return match(0, 0)
+}
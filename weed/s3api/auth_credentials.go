@@ -1,11 +1,13 @@
 package s3api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
@@ -27,6 +29,8 @@ type IdentityAccessManagement struct {
 
 	identities        []*Identity
 	accessKeyIdent    map[string]*Identity
+	roles             map[string]*Role
+	oidcProviders     map[string]*iam_pb.OidcProvider
 	accounts          map[string]*Account
 	emailAccount      map[string]*Account
 	hashes            map[string]*sync.Pool
@@ -35,6 +39,11 @@ type IdentityAccessManagement struct {
 	hashMu            sync.RWMutex
 	domain            string
 	isAuthEnabled     bool
+	bucketPolicies    BucketPolicyGetter
+	objectAcls        ObjectAclGetter
+	// signs the session tokens of the temporary credentials issued by STS
+	stsKey []byte
+	jwks   *jwksCache
 }
 
 type Identity struct {
@@ -42,6 +51,12 @@ type Identity struct {
 	Account     *Account
 	Credentials []*Credential
 	Actions     []Action
+
+	// set for the temporary credentials of an assumed role, whose name is the identity name
+	RoleSessionName string
+	sessionPolicy   *BucketPolicy
+	// combined from the inline, attached and group policies
+	policy *BucketPolicy
 }
 
 // Account represents a system user, a system user can
@@ -77,6 +92,8 @@ var (
 type Credential struct {
 	AccessKey string
 	SecretKey string
+	// presigned URLs signed before this time are revoked
+	PresignedNotBefore time.Time
 }
 
 func (i *Identity) isAnonymous() bool {
@@ -118,6 +135,7 @@ func NewIdentityAccessManagement(option *S3ApiServerOption) *IdentityAccessManag
 		domain:       option.DomainName,
 		hashes:       make(map[string]*sync.Pool),
 		hashCounters: make(map[string]*int32),
+		jwks:         newJwksCache(),
 	}
 	if option.Config != "" {
 		if err := iam.loadS3ApiConfigurationFromFile(option.Config); err != nil {
@@ -240,15 +258,32 @@ func (iam *IdentityAccessManagement) loadS3ApiConfiguration(config *iam_pb.S3Api
 			t.Actions = append(t.Actions, Action(action))
 		}
 		for _, cred := range ident.Credentials {
-			t.Credentials = append(t.Credentials, &Credential{
+			credential := &Credential{
 				AccessKey: cred.AccessKey,
 				SecretKey: cred.SecretKey,
-			})
+			}
+			if cred.PresignedNotBefore > 0 {
+				credential.PresignedNotBefore = time.Unix(cred.PresignedNotBefore, 0)
+			}
+			t.Credentials = append(t.Credentials, credential)
 			accessKeyIdent[cred.AccessKey] = t
 		}
 		identities = append(identities, t)
 	}
 
+	roles, err := loadRoles(config, accounts, identities)
+	if err != nil {
+		return err
+	}
+	loadIdentityPolicies(config, identities, roles)
+	oidcProviders := make(map[string]*iam_pb.OidcProvider)
+	for _, provider := range config.OidcProviders {
+		if len(provider.ClientIds) == 0 {
+			return fmt.Errorf("oidc provider %s has no client ids", provider.Issuer)
+		}
+		oidcProviders[provider.Issuer] = provider
+	}
+
 	iam.m.Lock()
 	// atomically switch
 	iam.identities = identities
@@ -256,8 +291,10 @@ func (iam *IdentityAccessManagement) loadS3ApiConfiguration(config *iam_pb.S3Api
 	iam.accounts = accounts
 	iam.emailAccount = emailAccount
 	iam.accessKeyIdent = accessKeyIdent
+	iam.roles = roles
+	iam.oidcProviders = oidcProviders
 	if !iam.isAuthEnabled { // one-directional, no toggling
-		iam.isAuthEnabled = len(identities) > 0
+		iam.isAuthEnabled = len(identities) > 0 || len(roles) > 0
 	}
 	iam.m.Unlock()
 
@@ -316,6 +353,10 @@ func (iam *IdentityAccessManagement) Auth(f http.HandlerFunc, action Action) htt
 			return
 		}
 
+		r.Header.Del(s3_constants.AmzPolicyAllowed)
+		r.Header.Del(s3_constants.AmzAclAllowed)
+		r.Header.Del(s3_constants.AmzIdentityId)
+		r.Header.Del(s3_constants.AmzIsAdmin)
 		identity, errCode := iam.authRequest(r, action)
 		if errCode == s3err.ErrNone {
 			if identity != nil && identity.Name != "" {
@@ -326,6 +367,9 @@ func (iam *IdentityAccessManagement) Auth(f http.HandlerFunc, action Action) htt
 					r.Header.Del(s3_constants.AmzIsAdmin)
 				}
 			}
+			if identity != nil {
+				r = r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity))
+			}
 			f(w, r)
 			return
 		}
@@ -365,8 +409,8 @@ func (iam *IdentityAccessManagement) authRequest(r *http.Request, action Action)
 	case authTypeAnonymous:
 		authType = "Anonymous"
 		if identity, found = iam.lookupAnonymous(); !found {
-			r.Header.Set(s3_constants.AmzAuthType, authType)
-			return identity, s3err.ErrAccessDenied
+			// without an anonymous identity, only bucket policies and object ACLs can grant access
+			identity = &Identity{Account: &AccountAnonymous}
 		}
 	default:
 		return identity, s3err.ErrNotImplemented
@@ -383,10 +427,34 @@ func (iam *IdentityAccessManagement) authRequest(r *http.Request, action Action)
 
 	bucket, object := s3_constants.GetBucketAndObject(r)
 
-	if !identity.canDo(action, bucket, object) {
+	if !identity.isAllowedBySessionPolicy(r, bucket, object) {
+		return identity, s3err.ErrAccessDenied
+	}
+
+	identityPolicyEffect := identity.evaluateIdentityPolicy(r, bucket, object)
+	if identityPolicyEffect == policyEffectDeny {
 		return identity, s3err.ErrAccessDenied
 	}
 
+	switch iam.evaluateBucketPolicy(r, identity, bucket, object) {
+	case policyEffectDeny:
+		// an explicit deny applies to admins too, except for managing the policy itself so that they can always repair a bad policy
+		if !identity.isAdmin() || !isBucketPolicyAction(policyActionOf(r, object)) {
+			return identity, s3err.ErrAccessDenied
+		}
+	case policyEffectAllow:
+		r.Header.Set(s3_constants.AmzPolicyAllowed, "true")
+	default:
+		if identityPolicyEffect == policyEffectAllow {
+			r.Header.Set(s3_constants.AmzPolicyAllowed, "true")
+		} else if !identity.canDo(action, bucket, object) {
+			if !iam.isAllowedByObjectAcl(r, identity, action, bucket, object) {
+				return identity, s3err.ErrAccessDenied
+			}
+			r.Header.Set(s3_constants.AmzAclAllowed, "true")
+		}
+	}
+
 	r.Header.Set(s3_constants.AmzAccountId, identity.Account.Id)
 
 	return identity, s3err.ErrNone
@@ -480,6 +548,89 @@ func (identity *Identity) canDo(action Action, bucket string, objectKey string)
 	return false
 }
 
+type identityContextKey struct{}
+
+// getRequestIdentity returns the identity authenticated by Auth, nil if not known
+func getRequestIdentity(r *http.Request) *Identity {
+	identity, _ := r.Context().Value(identityContextKey{}).(*Identity)
+	return identity
+}
+
+// isAuthorizedFor checks whether the requester may also take the policy action, e.g. "s3:BypassGovernanceRetention",
+// on the object or the "/" of the bucket, besides the action of the request itself checked by authRequest.
+// An explicit Deny of any policy refuses it, an Allow grants it, and otherwise the coarse action is required.
+func (iam *IdentityAccessManagement) isAuthorizedFor(r *http.Request, action Action, policyAction, bucket, object string) bool {
+	if !iam.isEnabled() {
+		return true
+	}
+	identity := getRequestIdentity(r)
+	if identity == nil {
+		return false
+	}
+	req := newPolicyRequest(r, identity, bucket, object)
+	req.action = policyAction
+	if identity.sessionPolicy != nil && identity.sessionPolicy.evaluate(req) != policyEffectAllow {
+		return false
+	}
+	var effects []policyEffect
+	if identity.policy != nil {
+		effects = append(effects, identity.policy.evaluate(req))
+	}
+	if iam.bucketPolicies != nil {
+		if policy := iam.bucketPolicies.GetBucketPolicy(bucket); policy != nil {
+			effects = append(effects, policy.evaluate(req))
+		}
+	}
+	allowed := false
+	for _, effect := range effects {
+		if effect == policyEffectDeny {
+			return false
+		}
+		allowed = allowed || effect == policyEffectAllow
+	}
+	return allowed || identity.canDo(action, bucket, object)
+}
+
+func isBucketPolicyAction(policyAction string) bool {
+	switch policyAction {
+	case "s3:GetBucketPolicy", "s3:PutBucketPolicy", "s3:DeleteBucketPolicy":
+		return true
+	}
+	return false
+}
+
+// evaluateBucketPolicy checks the request against the policy of the bucket it targets
+func (iam *IdentityAccessManagement) evaluateBucketPolicy(r *http.Request, identity *Identity, bucket, object string) policyEffect {
+	if bucket == "" || iam.bucketPolicies == nil {
+		return policyEffectNone
+	}
+	policy := iam.bucketPolicies.GetBucketPolicy(bucket)
+	if policy == nil {
+		return policyEffectNone
+	}
+	effect := policy.evaluate(newPolicyRequest(r, identity, bucket, object))
+	glog.V(3).Infof("bucket %s policy effect %d for %s %s", bucket, effect, r.Method, r.URL.Path)
+	return effect
+}
+
+// isAllowedByObjectAcl checks the request against the grants of the object it targets
+func (iam *IdentityAccessManagement) isAllowedByObjectAcl(r *http.Request, identity *Identity, action Action, bucket, object string) bool {
+	if bucket == "" || object == "/" || iam.objectAcls == nil {
+		return false
+	}
+	permission := objectAclPermission(r, action)
+	if permission == "" {
+		return false
+	}
+	ownerId, grants, found := iam.objectAcls.GetObjectAcp(bucket, object, r.URL.Query().Get("versionId"))
+	if !found {
+		return false
+	}
+	allowed := isGrantedByAcl(identity.Account.Id, ownerId, grants, permission)
+	glog.V(3).Infof("object %s%s acl allows %s for account %s: %v", bucket, object, permission, identity.Account.Id, allowed)
+	return allowed
+}
+
 func (identity *Identity) isAdmin() bool {
 	for _, a := range identity.Actions {
 		if a == "Admin" {
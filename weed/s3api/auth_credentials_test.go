@@ -3,6 +3,8 @@ package s3api
 import (
 	. "github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
@@ -251,3 +253,45 @@ func TestLoadS3ApiConfiguration(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadIdentityPolicies(t *testing.T) {
+	config := &iam_pb.S3ApiConfiguration{
+		Identities: []*iam_pb.Identity{
+			{
+				Name:        "member",
+				Credentials: []*iam_pb.Credential{{AccessKey: "member_access_key", SecretKey: "member_secret_key"}},
+				InlinePolicies: []*iam_pb.InlinePolicy{{Name: "deny-secret", Document: `{
+					"Version": "2012-10-17",
+					"Statement": [{"Effect": "Deny", "Action": "s3:*", "Resource": "arn:aws:s3:::bucket/secret/*"}]
+				}`}},
+			},
+			{
+				Name:        "outsider",
+				Credentials: []*iam_pb.Credential{{AccessKey: "outsider_access_key", SecretKey: "outsider_secret_key"}},
+			},
+		},
+		Groups: []*iam_pb.Group{{Name: "readers", Members: []string{"member"}, AttachedPolicies: []string{"read"}}},
+		Policies: []*iam_pb.ManagedPolicy{{
+			Name:             "read",
+			DefaultVersionId: "v2",
+			Versions: []*iam_pb.PolicyVersion{
+				{VersionId: "v1", Document: `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`},
+				{VersionId: "v2", Document: `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"]}]}`},
+			},
+		}},
+	}
+	iam := IdentityAccessManagement{}
+	assert.NoError(t, iam.loadS3ApiConfiguration(config))
+
+	member, _, _ := iam.lookupByAccessKey("member_access_key")
+	outsider, _, _ := iam.lookupByAccessKey("outsider_access_key")
+	get := httptest.NewRequest(http.MethodGet, "/bucket/file", nil)
+	put := httptest.NewRequest(http.MethodPut, "/bucket/file", nil)
+	assert.Equal(t, policyEffectAllow, member.evaluateIdentityPolicy(get, "bucket", "/file"))
+	assert.Equal(t, policyEffectNone, member.evaluateIdentityPolicy(put, "bucket", "/file"))
+	assert.Equal(t, policyEffectDeny, member.evaluateIdentityPolicy(get, "bucket", "/secret/file"))
+	assert.Equal(t, policyEffectNone, member.evaluateIdentityPolicy(get, "other", "/file"))
+	assert.Equal(t, policyEffectNone, outsider.evaluateIdentityPolicy(get, "bucket", "/file"))
+	assert.True(t, member.isBucketListed(get, "bucket"))
+	assert.False(t, member.isBucketListed(get, "other"))
+}
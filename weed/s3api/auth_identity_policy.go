@@ -0,0 +1,158 @@
+package s3api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+)
+
+// Besides the coarse actions, identities and roles get the IAM policies managed by "weed iam":
+// their inline policies, the default versions of their attached managed policies, and those of their groups.
+// All of them are evaluated together like identity-based policies of AWS IAM,
+// an explicit Deny overrides any allowance, and an Allow grants the access on its own.
+
+// parseIdentityPolicy parses a policy attached to identities, groups or roles.
+// It has no principal, its statements apply to whoever it is attached to.
+func parseIdentityPolicy(data []byte) (*BucketPolicy, error) {
+	policy, err := parseBucketPolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Version != policyVersion2012 && policy.Version != policyVersion2008 {
+		return nil, fmt.Errorf("unsupported policy version %q", policy.Version)
+	}
+	if len(policy.Statement) == 0 {
+		return nil, fmt.Errorf("policy has no statement")
+	}
+	for i := range policy.Statement {
+		statement := &policy.Statement[i]
+		if statement.Effect != policyEffectAllowName && statement.Effect != policyEffectDenyName {
+			return nil, fmt.Errorf("statement %d: invalid effect %q", i, statement.Effect)
+		}
+		if statement.Principal != nil {
+			return nil, fmt.Errorf("statement %d: an identity policy has no principal", i)
+		}
+		if (len(statement.Action) == 0) == (len(statement.NotAction) == 0) {
+			return nil, fmt.Errorf("statement %d: exactly one of Action and NotAction is required", i)
+		}
+		for _, action := range append(statement.Action, statement.NotAction...) {
+			if action != "*" && strings.Count(action, ":") != 1 {
+				return nil, fmt.Errorf("statement %d: invalid action %q", i, action)
+			}
+		}
+		if (len(statement.Resource) == 0) == (len(statement.NotResource) == 0) {
+			return nil, fmt.Errorf("statement %d: exactly one of Resource and NotResource is required", i)
+		}
+		for _, resource := range append(statement.Resource, statement.NotResource...) {
+			if resource != "*" && !strings.HasPrefix(resource, "arn:") {
+				return nil, fmt.Errorf("statement %d: invalid resource %q", i, resource)
+			}
+		}
+		for operator := range statement.Condition {
+			if !isKnownConditionOperator(operator) {
+				return nil, fmt.Errorf("statement %d: unknown condition operator %q", i, operator)
+			}
+		}
+		statement.Principal = &policyPrincipal{any: true}
+	}
+	return policy, nil
+}
+
+// ValidateIdentityPolicy checks a policy document before it is attached to identities, groups or roles
+func ValidateIdentityPolicy(document string) error {
+	_, err := parseIdentityPolicy([]byte(document))
+	return err
+}
+
+// loadIdentityPolicies combines the policies applying to each identity and role
+func loadIdentityPolicies(config *iam_pb.S3ApiConfiguration, identities []*Identity, roles map[string]*Role) {
+	managedPolicies := make(map[string]*BucketPolicy)
+	for _, managedPolicy := range config.Policies {
+		for _, version := range managedPolicy.Versions {
+			if version.VersionId != managedPolicy.DefaultVersionId {
+				continue
+			}
+			policy, err := parseIdentityPolicy([]byte(version.Document))
+			if err != nil {
+				glog.Warningf("skip managed policy %s %s: %v", managedPolicy.Name, version.VersionId, err)
+				continue
+			}
+			managedPolicies[managedPolicy.Name] = policy
+		}
+	}
+	collect := func(owner string, attachedPolicies []string, inlinePolicies []*iam_pb.InlinePolicy) (statements policyStatements) {
+		for _, name := range attachedPolicies {
+			if policy, found := managedPolicies[name]; found {
+				statements = append(statements, policy.Statement...)
+			} else {
+				glog.Warningf("%s is attached to the missing managed policy %s", owner, name)
+			}
+		}
+		for _, inlinePolicy := range inlinePolicies {
+			policy, err := parseIdentityPolicy([]byte(inlinePolicy.Document))
+			if err != nil {
+				glog.Warningf("skip policy %s of %s: %v", inlinePolicy.Name, owner, err)
+				continue
+			}
+			statements = append(statements, policy.Statement...)
+		}
+		return
+	}
+
+	groupStatements := make(map[string]policyStatements)
+	for _, group := range config.Groups {
+		statements := collect("group "+group.Name, group.AttachedPolicies, group.InlinePolicies)
+		for _, member := range group.Members {
+			groupStatements[member] = append(groupStatements[member], statements...)
+		}
+	}
+	identityByName := make(map[string]*Identity)
+	for _, identity := range identities {
+		identityByName[identity.Name] = identity
+	}
+	for _, ident := range config.Identities {
+		if identity, found := identityByName[ident.Name]; found {
+			statements := collect("identity "+ident.Name, ident.AttachedPolicies, ident.InlinePolicies)
+			identity.policy = newIdentityPolicy(append(statements, groupStatements[ident.Name]...))
+		}
+	}
+	for _, r := range config.Roles {
+		if role, found := roles[r.Name]; found {
+			role.policy = newIdentityPolicy(collect("role "+r.Name, r.AttachedPolicies, r.InlinePolicies))
+		}
+	}
+}
+
+func newIdentityPolicy(statements policyStatements) *BucketPolicy {
+	if len(statements) == 0 {
+		return nil
+	}
+	return &BucketPolicy{Version: policyVersion2012, Statement: statements}
+}
+
+// evaluateIdentityPolicy evaluates the IAM policies of the identity, requests out of any bucket are left to the actions
+func (identity *Identity) evaluateIdentityPolicy(r *http.Request, bucket, object string) policyEffect {
+	if identity == nil || identity.policy == nil || bucket == "" {
+		return policyEffectNone
+	}
+	return identity.policy.evaluate(newPolicyRequest(r, identity, bucket, object))
+}
+
+// isBucketListed checks whether ListBuckets shows the bucket to the identity
+func (identity *Identity) isBucketListed(r *http.Request, bucket string) bool {
+	if identity.policy != nil {
+		req := newPolicyRequest(r, identity, bucket, "/")
+		req.action = "s3:ListBucket"
+		switch identity.policy.evaluate(req) {
+		case policyEffectDeny:
+			return false
+		case policyEffectAllow:
+			return true
+		}
+	}
+	return identity.canDo(s3_constants.ACTION_LIST, bucket, "")
+}
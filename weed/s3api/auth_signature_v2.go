@@ -241,6 +241,11 @@ func (iam *IdentityAccessManagement) doesPresignV2SignatureMatch(r *http.Request
 		return nil, s3err.ErrExpiredPresignRequest
 	}
 
+	// The signing time is not part of a V2 presigned URL, all of them are revoked with the access key.
+	if !cred.PresignedNotBefore.IsZero() {
+		return nil, s3err.ErrPresignedUrlRevoked
+	}
+
 	encodedResource, err = getResource(encodedResource, r.Host, iam.domain)
 	if err != nil {
 		return nil, s3err.ErrInvalidRequest
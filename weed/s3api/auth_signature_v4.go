@@ -25,6 +25,7 @@ import (
 	"encoding/hex"
 	"hash"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -36,6 +37,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
 	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
 )
 
@@ -115,10 +117,10 @@ func (iam *IdentityAccessManagement) doesSignatureMatch(hashedPayload string, r
 		return nil, errCode
 	}
 
-	// Verify if the access key id matches.
-	identity, cred, found := iam.lookupByAccessKey(signV4Values.Credential.accessKey)
-	if !found {
-		return nil, s3err.ErrInvalidAccessKeyID
+	// Verify if the access key id matches, temporary credentials come with a session token.
+	identity, cred, errCode := iam.lookupCredential(signV4Values.Credential.accessKey, req.Header.Get("X-Amz-Security-Token"))
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// Extract date, if not present throw error.
@@ -323,9 +325,9 @@ func (iam *IdentityAccessManagement) doesPolicySignatureV4Match(formValues http.
 		return s3err.ErrMissingFields
 	}
 
-	_, cred, found := iam.lookupByAccessKey(credHeader.accessKey)
-	if !found {
-		return s3err.ErrInvalidAccessKeyID
+	_, cred, errCode := iam.lookupCredential(credHeader.accessKey, formValues.Get("X-Amz-Security-Token"))
+	if errCode != s3err.ErrNone {
+		return errCode
 	}
 
 	// Get signature.
@@ -359,10 +361,11 @@ func (iam *IdentityAccessManagement) doesPresignedSignatureMatch(hashedPayload s
 		return nil, err
 	}
 
-	// Verify if the access key id matches.
-	identity, cred, found := iam.lookupByAccessKey(pSignValues.Credential.accessKey)
-	if !found {
-		return nil, s3err.ErrInvalidAccessKeyID
+	// Verify if the access key id matches, temporary credentials come with a session token.
+	sessionToken := r.URL.Query().Get("X-Amz-Security-Token")
+	identity, cred, errCode := iam.lookupCredential(pSignValues.Credential.accessKey, sessionToken)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
 	}
 
 	// Extract all the signed headers along with its values.
@@ -390,6 +393,11 @@ func (iam *IdentityAccessManagement) doesPresignedSignatureMatch(hashedPayload s
 		return nil, s3err.ErrExpiredPresignRequest
 	}
 
+	// The access key may have revoked the urls it signed before a given time.
+	if pSignValues.Date.Before(cred.PresignedNotBefore) {
+		return nil, s3err.ErrPresignedUrlRevoked
+	}
+
 	// Save the date and expires.
 	t := pSignValues.Date
 	expireSeconds := int(pSignValues.Expires / time.Second)
@@ -399,6 +407,9 @@ func (iam *IdentityAccessManagement) doesPresignedSignatureMatch(hashedPayload s
 	query.Set("X-Amz-Expires", strconv.Itoa(expireSeconds))
 	query.Set("X-Amz-SignedHeaders", getSignedHeaders(extractedSignedHeaders))
 	query.Set("X-Amz-Credential", cred.AccessKey+"/"+getScope(t, pSignValues.Credential.scope.region))
+	if sessionToken != "" {
+		query.Set("X-Amz-Security-Token", sessionToken)
+	}
 
 	// Save other headers available in the request parameters.
 	for k, v := range req.URL.Query() {
@@ -461,9 +472,60 @@ func (iam *IdentityAccessManagement) doesPresignedSignatureMatch(hashedPayload s
 	if !compareSignatureV4(req.URL.Query().Get("X-Amz-Signature"), newSignature) {
 		return nil, s3err.ErrSignatureDoesNotMatch
 	}
+
+	// The constraints are signed query parameters, only check them once the signature is verified.
+	if errCode = checkPresignedConstraints(req.URL.Query(), r); errCode != s3err.ErrNone {
+		return nil, errCode
+	}
 	return identity, s3err.ErrNone
 }
 
+// checkPresignedConstraints enforces the source addresses and the maximum content length a presigned url is bound to
+func checkPresignedConstraints(query url.Values, r *http.Request) s3err.ErrorCode {
+	if sourceIps := query.Get(s3_constants.PresignedSourceIp); sourceIps != "" {
+		// only the peer address is trusted, forwarding headers can be set by anyone
+		host := r.RemoteAddr
+		if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			host = h
+		}
+		if !ipMatchesAny(net.ParseIP(host), strings.Split(sourceIps, ",")) {
+			return s3err.ErrAccessDenied
+		}
+	}
+	if maxContentLength := query.Get(s3_constants.PresignedMaxContentLength); maxContentLength != "" {
+		limit, err := strconv.ParseInt(maxContentLength, 10, 64)
+		if err != nil || limit < 0 {
+			return s3err.ErrInvalidQueryParams
+		}
+		// a chunked upload of unknown length could exceed the limit
+		if r.ContentLength < 0 {
+			return s3err.ErrMissingContentLength
+		}
+		if r.ContentLength > limit {
+			return s3err.ErrEntityTooLarge
+		}
+	}
+	return s3err.ErrNone
+}
+
+// ipMatchesAny checks the ip against a list of addresses and CIDR blocks
+func ipMatchesAny(ip net.IP, sourceIps []string) bool {
+	if ip == nil {
+		return false
+	}
+	for _, sourceIp := range sourceIps {
+		sourceIp = strings.TrimSpace(sourceIp)
+		if _, network, err := net.ParseCIDR(sourceIp); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(sourceIp); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (iam *IdentityAccessManagement) getSignature(secretKey string, t time.Time, region string, service string, stringToSign string) string {
 	pool := iam.getSignatureHashPool(secretKey, t, region, service)
 	h := pool.Get().(hash.Hash)
@@ -0,0 +1,340 @@
+package s3api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+// The temporary credentials issued by STS are not stored anywhere.
+// The session token is a JWT signed with the "s3.sts.key" of security.toml, naming the assumed role,
+// and the secret key is derived from the temporary access key with the same key.
+// So every S3 gateway sharing the key accepts them, and the current actions of the role always apply.
+
+const (
+	stsDefaultSessionDuration = time.Hour
+	stsMinSessionDuration     = 15 * time.Minute
+	stsMaxSessionDuration     = 12 * time.Hour
+	stsAccessKeyPrefix        = "ASIA"
+	stsAccessKeyLength        = 20
+	stsMaxSessionPolicySize   = 2048
+	stsMaxSessionNameLength   = 64
+)
+
+var errStsNotConfigured = errors.New("s3.sts.key is not configured in security.toml")
+
+// Role can be assumed through STS, to get temporary credentials with its actions
+type Role struct {
+	Name               string
+	Account            *Account
+	Actions            []Action
+	TrustedIdentities  []string
+	TrustedIssuers     []string
+	TrustedSubjects    []string
+	MaxSessionDuration time.Duration
+	policy             *BucketPolicy
+}
+
+type stsSessionClaims struct {
+	AccessKey string `json:"akid"`
+	Role      string `json:"role"`
+	Policy    string `json:"policy,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type stsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+func loadRoles(config *iam_pb.S3ApiConfiguration, accounts map[string]*Account, identities []*Identity) (map[string]*Role, error) {
+	identityNames := make(map[string]bool)
+	for _, ident := range identities {
+		identityNames[ident.Name] = true
+	}
+	roles := make(map[string]*Role)
+	for _, r := range config.Roles {
+		if r.Name == "" {
+			return nil, fmt.Errorf("role without name")
+		}
+		if _, found := roles[r.Name]; found || identityNames[r.Name] {
+			return nil, fmt.Errorf("role %s: the name is already used", r.Name)
+		}
+		role := &Role{
+			Name:               r.Name,
+			Account:            &AccountAdmin,
+			TrustedIdentities:  r.TrustedIdentities,
+			TrustedIssuers:     r.TrustedIssuers,
+			TrustedSubjects:    r.TrustedSubjects,
+			MaxSessionDuration: stsDefaultSessionDuration,
+		}
+		if r.Account != nil {
+			if account, ok := accounts[r.Account.Id]; ok {
+				role.Account = account
+			} else {
+				glog.Warningf("role %s is associated with a non exist account ID, the association is invalid", r.Name)
+			}
+		}
+		for _, action := range r.Actions {
+			role.Actions = append(role.Actions, Action(action))
+		}
+		if r.MaxSessionDurationSeconds > 0 {
+			role.MaxSessionDuration = time.Duration(r.MaxSessionDurationSeconds) * time.Second
+			if role.MaxSessionDuration < stsMinSessionDuration || role.MaxSessionDuration > stsMaxSessionDuration {
+				return nil, fmt.Errorf("role %s: max session duration should be between %v and %v", r.Name, stsMinSessionDuration, stsMaxSessionDuration)
+			}
+		}
+		roles[r.Name] = role
+	}
+	return roles, nil
+}
+
+func (iam *IdentityAccessManagement) lookupRole(name string) (*Role, bool) {
+	iam.m.RLock()
+	defer iam.m.RUnlock()
+	role, found := iam.roles[name]
+	return role, found
+}
+
+func (iam *IdentityAccessManagement) getStsKey() []byte {
+	iam.m.RLock()
+	defer iam.m.RUnlock()
+	return iam.stsKey
+}
+
+// roleNameFromArn accepts both a role arn, arn:aws:iam::<account>:role/<path>/<name>, and a plain role name
+func roleNameFromArn(roleArn string) string {
+	if i := strings.Index(roleArn, ":role/"); i >= 0 {
+		roleArn = roleArn[i+len(":role/"):]
+		if j := strings.LastIndex(roleArn, "/"); j >= 0 {
+			roleArn = roleArn[j+1:]
+		}
+	}
+	return roleArn
+}
+
+func (role *Role) assumedRoleArn(sessionName string) string {
+	return "arn:aws:sts::" + role.Account.Id + ":assumed-role/" + role.Name + "/" + sessionName
+}
+
+// isTrustedIdentity checks whether the identity can assume the role with AssumeRole
+func (role *Role) isTrustedIdentity(identity *Identity) bool {
+	if identity == nil || identity.isAnonymous() || identity.RoleSessionName != "" {
+		return false
+	}
+	if identity.isAdmin() {
+		return true
+	}
+	for _, trusted := range role.TrustedIdentities {
+		if policyWildcardMatch(trusted, identity.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrustedWebIdentity checks whether the subject of an OIDC issuer can assume the role with AssumeRoleWithWebIdentity
+func (role *Role) isTrustedWebIdentity(issuer, subject string) bool {
+	issuerTrusted := false
+	for _, trusted := range role.TrustedIssuers {
+		if strings.TrimSuffix(trusted, "/") == strings.TrimSuffix(issuer, "/") {
+			issuerTrusted = true
+			break
+		}
+	}
+	if !issuerTrusted {
+		return false
+	}
+	if len(role.TrustedSubjects) == 0 {
+		return true
+	}
+	for _, trusted := range role.TrustedSubjects {
+		if policyWildcardMatch(trusted, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionDuration checks the requested DurationSeconds against the limits of the role
+func (role *Role) sessionDuration(durationSeconds string) (time.Duration, s3err.ErrorCode) {
+	if durationSeconds == "" {
+		if role.MaxSessionDuration < stsDefaultSessionDuration {
+			return role.MaxSessionDuration, s3err.ErrNone
+		}
+		return stsDefaultSessionDuration, s3err.ErrNone
+	}
+	var seconds int64
+	if _, err := fmt.Sscanf(durationSeconds, "%d", &seconds); err != nil {
+		return 0, s3err.ErrStsValidation
+	}
+	duration := time.Duration(seconds) * time.Second
+	if duration < stsMinSessionDuration || duration > role.MaxSessionDuration {
+		return 0, s3err.ErrStsValidation
+	}
+	return duration, s3err.ErrNone
+}
+
+func isValidRoleSessionName(name string) bool {
+	if len(name) < 2 || len(name) > stsMaxSessionNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_=,.@-", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSessionPolicy parses the inline policy passed when assuming a role,
+// which can only narrow down the S3 actions of the role.
+func parseSessionPolicy(data []byte) (*BucketPolicy, error) {
+	if len(data) > stsMaxSessionPolicySize {
+		return nil, fmt.Errorf("session policy is larger than %d bytes", stsMaxSessionPolicySize)
+	}
+	policy, err := parseIdentityPolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	for i, statement := range policy.Statement {
+		for _, action := range append(statement.Action, statement.NotAction...) {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return nil, fmt.Errorf("statement %d: invalid action %q", i, action)
+			}
+		}
+		for _, resource := range append(statement.Resource, statement.NotResource...) {
+			if resource != "*" && !strings.HasPrefix(resource, policyResourceArnPrefix) {
+				return nil, fmt.Errorf("statement %d: invalid resource %q", i, resource)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// isAllowedBySessionPolicy checks the request against the session policy of temporary credentials, if any
+func (identity *Identity) isAllowedBySessionPolicy(r *http.Request, bucket, object string) bool {
+	if identity == nil || identity.sessionPolicy == nil || bucket == "" {
+		return true
+	}
+	return identity.sessionPolicy.evaluate(newPolicyRequest(r, identity, bucket, object)) == policyEffectAllow
+}
+
+// issueSessionCredentials creates the temporary credentials of a role session
+func (iam *IdentityAccessManagement) issueSessionCredentials(role *Role, sessionName, sessionPolicy string, duration time.Duration, now time.Time) (*stsCredentials, error) {
+	key := iam.getStsKey()
+	if len(key) == 0 {
+		return nil, errStsNotConfigured
+	}
+	accessKey, err := newSessionAccessKey()
+	if err != nil {
+		return nil, err
+	}
+	expiration := now.Add(duration).Truncate(time.Second)
+	claims := stsSessionClaims{
+		AccessKey: accessKey,
+		Role:      role.Name,
+		Policy:    sessionPolicy,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   sessionName,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiration),
+		},
+	}
+	sessionToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+	return &stsCredentials{
+		AccessKeyId:     accessKey,
+		SecretAccessKey: sessionSecretKey(key, accessKey),
+		SessionToken:    sessionToken,
+		Expiration:      expiration,
+	}, nil
+}
+
+func newSessionAccessKey() (string, error) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	var sb strings.Builder
+	sb.WriteString(stsAccessKeyPrefix)
+	for sb.Len() < stsAccessKeyLength {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(alphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+func sessionSecretKey(stsKey []byte, accessKey string) string {
+	mac := hmac.New(sha256.New, stsKey)
+	mac.Write([]byte(accessKey))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))[:40]
+}
+
+// lookupBySessionToken verifies the session token of temporary credentials, and builds the identity of the role session
+func (iam *IdentityAccessManagement) lookupBySessionToken(accessKey, sessionToken string) (*Identity, *Credential, s3err.ErrorCode) {
+	key := iam.getStsKey()
+	if len(key) == 0 {
+		return nil, nil, s3err.ErrInvalidToken
+	}
+	var claims stsSessionClaims
+	_, err := jwt.ParseWithClaims(sessionToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, nil, s3err.ErrExpiredToken
+	}
+	if err != nil {
+		glog.V(3).Infof("invalid session token of %s: %v", accessKey, err)
+		return nil, nil, s3err.ErrInvalidToken
+	}
+	if claims.AccessKey != accessKey {
+		return nil, nil, s3err.ErrInvalidAccessKeyID
+	}
+	role, found := iam.lookupRole(claims.Role)
+	if !found {
+		glog.V(3).Infof("session token of %s assumes the removed role %s", accessKey, claims.Role)
+		return nil, nil, s3err.ErrInvalidToken
+	}
+	identity := &Identity{
+		Name:            role.Name,
+		Account:         role.Account,
+		Actions:         role.Actions,
+		RoleSessionName: claims.Subject,
+		policy:          role.policy,
+	}
+	if claims.Policy != "" {
+		if identity.sessionPolicy, err = parseSessionPolicy([]byte(claims.Policy)); err != nil {
+			return nil, nil, s3err.ErrInvalidToken
+		}
+	}
+	return identity, &Credential{AccessKey: accessKey, SecretKey: sessionSecretKey(key, accessKey)}, s3err.ErrNone
+}
+
+// lookupCredential finds the identity of long-lived access keys, or of temporary credentials with a session token
+func (iam *IdentityAccessManagement) lookupCredential(accessKey, sessionToken string) (*Identity, *Credential, s3err.ErrorCode) {
+	if sessionToken != "" {
+		return iam.lookupBySessionToken(accessKey, sessionToken)
+	}
+	identity, cred, found := iam.lookupByAccessKey(accessKey)
+	if !found {
+		return nil, nil, s3err.ErrInvalidAccessKeyID
+	}
+	return identity, cred, s3err.ErrNone
+}
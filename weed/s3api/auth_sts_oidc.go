@@ -0,0 +1,294 @@
+package s3api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	jwksRefreshInterval    = time.Hour
+	jwksMinRefreshInterval = time.Minute
+	jwksMaxResponseSize    = 1 << 20
+)
+
+var webIdentitySigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// jwksCache keeps the signing keys of the OIDC providers, refreshed periodically or on unknown key ids
+type jwksCache struct {
+	sync.Mutex
+	client   *http.Client
+	keySets  map[string]*jwks
+	fetching map[string]chan struct{} // closed when the key set of the issuer is fetched
+}
+
+type jwks struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type webIdentityClaims struct {
+	jwt.RegisteredClaims
+}
+
+func newJwksCache() *jwksCache {
+	return &jwksCache{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		keySets:  make(map[string]*jwks),
+		fetching: make(map[string]chan struct{}),
+	}
+}
+
+func (iam *IdentityAccessManagement) lookupOidcProvider(issuer string) (*iam_pb.OidcProvider, bool) {
+	iam.m.RLock()
+	defer iam.m.RUnlock()
+	provider, found := iam.oidcProviders[issuer]
+	if !found {
+		provider, found = iam.oidcProviders[strings.TrimSuffix(issuer, "/")]
+	}
+	return provider, found
+}
+
+// verifyWebIdentityToken validates the OIDC token against the signing keys and client ids of its issuer
+func (iam *IdentityAccessManagement) verifyWebIdentityToken(token string) (*webIdentityClaims, *iam_pb.OidcProvider, s3err.ErrorCode) {
+	var unverified webIdentityClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &unverified); err != nil {
+		glog.V(3).Infof("parse web identity token: %v", err)
+		return nil, nil, s3err.ErrInvalidIdentityToken
+	}
+	provider, found := iam.lookupOidcProvider(unverified.Issuer)
+	if !found {
+		glog.V(3).Infof("web identity token from unknown issuer %q", unverified.Issuer)
+		return nil, nil, s3err.ErrInvalidIdentityToken
+	}
+
+	var claims webIdentityClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return iam.jwks.getKey(provider, kid)
+	}, jwt.WithValidMethods(webIdentitySigningMethods), jwt.WithIssuer(unverified.Issuer), jwt.WithExpirationRequired(), jwt.WithLeeway(time.Minute))
+	if err == nil && !isAudienceAllowed(claims.Audience, provider.ClientIds) {
+		err = fmt.Errorf("audience %v is not a client id of %s", claims.Audience, provider.Issuer)
+	}
+	if err == nil && claims.Subject == "" {
+		err = fmt.Errorf("missing subject")
+	}
+	if err != nil {
+		glog.V(3).Infof("verify web identity token of %s: %v", provider.Issuer, err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, nil, s3err.ErrExpiredToken
+		}
+		return nil, nil, s3err.ErrInvalidIdentityToken
+	}
+	return &claims, provider, s3err.ErrNone
+}
+
+// isAudienceAllowed requires the token to be issued to one of the client ids, the providers are configured with at least one
+func isAudienceAllowed(audience jwt.ClaimStrings, clientIds []string) bool {
+	for _, aud := range audience {
+		for _, clientId := range clientIds {
+			if aud == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getKey returns the signing key of the provider with the key id, fetching the key set if needed
+func (c *jwksCache) getKey(provider *iam_pb.OidcProvider, kid string) (interface{}, error) {
+	c.Lock()
+	keySet, found := c.keySets[provider.Issuer]
+	if found && time.Since(keySet.fetchedAt) < jwksRefreshInterval {
+		if key := keySet.find(kid); key != nil {
+			c.Unlock()
+			return key, nil
+		}
+	}
+	// the provider may have rotated its keys, but do not let unknown key ids flood it
+	if !found || time.Since(keySet.fetchedAt) >= jwksMinRefreshInterval {
+		var err error
+		if keySet, err = c.fetchOnce(provider); err != nil {
+			return nil, err
+		}
+	} else {
+		c.Unlock()
+	}
+	if key := keySet.find(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no signing key %q for %s", kid, provider.Issuer)
+}
+
+// fetchOnce is called with the lock held and releases it, fetching the key set without holding the lock,
+// the concurrent callers for the same issuer wait for the one fetch
+func (c *jwksCache) fetchOnce(provider *iam_pb.OidcProvider) (*jwks, error) {
+	if done, fetching := c.fetching[provider.Issuer]; fetching {
+		c.Unlock()
+		<-done
+		c.Lock()
+		keySet, found := c.keySets[provider.Issuer]
+		c.Unlock()
+		if !found {
+			return nil, fmt.Errorf("fetch signing keys of %s failed", provider.Issuer)
+		}
+		return keySet, nil
+	}
+	done := make(chan struct{})
+	c.fetching[provider.Issuer] = done
+	c.Unlock()
+
+	fetched, err := c.fetch(provider)
+
+	c.Lock()
+	delete(c.fetching, provider.Issuer)
+	if err == nil {
+		c.keySets[provider.Issuer] = fetched
+	}
+	c.Unlock()
+	close(done)
+	return fetched, err
+}
+
+func (s *jwks) find(kid string) interface{} {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+func (c *jwksCache) fetch(provider *iam_pb.OidcProvider) (*jwks, error) {
+	jwksUri := provider.JwksUri
+	if jwksUri == "" {
+		var discovery struct {
+			JwksUri string `json:"jwks_uri"`
+		}
+		if err := c.getJson(strings.TrimSuffix(provider.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.JwksUri == "" {
+			return nil, fmt.Errorf("openid configuration of %s has no jwks_uri", provider.Issuer)
+		}
+		jwksUri = discovery.JwksUri
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := c.getJson(jwksUri, &keySet); err != nil {
+		return nil, err
+	}
+	fetched := &jwks{keys: make(map[string]interface{}), fetchedAt: time.Now()}
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			glog.Warningf("skip key %s of %s: %v", jwk.Kid, provider.Issuer, err)
+			continue
+		}
+		fetched.keys[jwk.Kid] = key
+	}
+	glog.V(1).Infof("fetched %d signing keys of %s", len(fetched.keys), provider.Issuer)
+	return fetched, nil
+}
+
+func (c *jwksCache) getJson(url string, v interface{}) error {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return fmt.Errorf("get %s: %v", url, err)
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, jwksMaxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %v", url, err)
+	}
+	return nil
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJwkInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeJwkInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeJwkInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -99,6 +99,73 @@ func TestIsReqAuthenticated(t *testing.T) {
 	}
 }
 
+func TestPresignedUrlRevocationAndConstraints(t *testing.T) {
+	newIam := func(presignedNotBefore time.Time) *IdentityAccessManagement {
+		iam := &IdentityAccessManagement{
+			hashes:       make(map[string]*sync.Pool),
+			hashCounters: make(map[string]*int32),
+		}
+		_ = iam.loadS3ApiConfiguration(&iam_pb.S3ApiConfiguration{
+			Identities: []*iam_pb.Identity{
+				{
+					Name: "someone",
+					Credentials: []*iam_pb.Credential{
+						{
+							AccessKey:          "access_key_1",
+							SecretKey:          "secret_key_1",
+							PresignedNotBefore: presignedNotBefore.Unix(),
+						},
+					},
+				},
+			},
+		})
+		return iam
+	}
+
+	// urls signed before the not before time are revoked
+	iam := newIam(time.Now().Add(time.Hour))
+	req := mustNewPresignedRequest(iam, "GET", "http://127.0.0.1:9000/bucket/object", 0, nil, t)
+	if _, errCode := iam.doesPresignedSignatureMatch(unsignedPayload, req); errCode != s3err.ErrPresignedUrlRevoked {
+		t.Fatalf("revoked url: want %d - got %d", s3err.ErrPresignedUrlRevoked, errCode)
+	}
+	iam = newIam(time.Now().Add(-time.Hour))
+	req = mustNewPresignedRequest(iam, "GET", "http://127.0.0.1:9000/bucket/object", 0, nil, t)
+	if _, errCode := iam.doesPresignedSignatureMatch(unsignedPayload, req); errCode != s3err.ErrNone {
+		t.Fatalf("url signed after the not before time: want %d - got %d", s3err.ErrNone, errCode)
+	}
+
+	constrained := "http://127.0.0.1:9000/bucket/object?" + url.Values{
+		s3_constants.PresignedSourceIp:         {"10.0.0.0/8,192.0.2.1"},
+		s3_constants.PresignedMaxContentLength: {"1024"},
+	}.Encode()
+	testCases := []struct {
+		remoteAddr    string
+		contentLength int64
+		tamper        bool
+		s3Error       s3err.ErrorCode
+	}{
+		{"10.1.2.3:5000", 1024, false, s3err.ErrNone},
+		{"192.0.2.1:5000", 0, false, s3err.ErrNone},
+		{"192.0.2.2:5000", 0, false, s3err.ErrAccessDenied},
+		{"10.1.2.3:5000", 1025, false, s3err.ErrEntityTooLarge},
+		{"10.1.2.3:5000", -1, false, s3err.ErrMissingContentLength},
+		// the constraints are part of the signature
+		{"192.0.2.2:5000", 0, true, s3err.ErrSignatureDoesNotMatch},
+	}
+	for i, testCase := range testCases {
+		req = mustNewPresignedRequest(iam, "PUT", constrained, testCase.contentLength, nil, t)
+		req.RemoteAddr = testCase.remoteAddr
+		if testCase.tamper {
+			query := req.URL.Query()
+			query.Del(s3_constants.PresignedSourceIp)
+			req.URL.RawQuery = query.Encode()
+		}
+		if _, errCode := iam.doesPresignedSignatureMatch(unsignedPayload, req); errCode != testCase.s3Error {
+			t.Errorf("Test %d: want %d - got %d", i, testCase.s3Error, errCode)
+		}
+	}
+}
+
 func TestCheckaAnonymousRequestAuthType(t *testing.T) {
 	iam := &IdentityAccessManagement{
 		hashes:       make(map[string]*sync.Pool),
@@ -193,7 +260,7 @@ func mustNewRequest(method string, urlStr string, contentLength int64, body io.R
 // is signed with AWS Signature V4, fails if not able to do so.
 func mustNewSignedRequest(method string, urlStr string, contentLength int64, body io.ReadSeeker, t *testing.T) *http.Request {
 	req := mustNewRequest(method, urlStr, contentLength, body, t)
-	cred := &Credential{"access_key_1", "secret_key_1"}
+	cred := &Credential{AccessKey: "access_key_1", SecretKey: "secret_key_1"}
 	if err := signRequestV4(req, cred.AccessKey, cred.SecretKey); err != nil {
 		t.Fatalf("Unable to initialized new signed http request %s", err)
 	}
@@ -204,7 +271,7 @@ func mustNewSignedRequest(method string, urlStr string, contentLength int64, bod
 // is presigned with AWS Signature V4, fails if not able to do so.
 func mustNewPresignedRequest(iam *IdentityAccessManagement, method string, urlStr string, contentLength int64, body io.ReadSeeker, t *testing.T) *http.Request {
 	req := mustNewRequest(method, urlStr, contentLength, body, t)
-	cred := &Credential{"access_key_1", "secret_key_1"}
+	cred := &Credential{AccessKey: "access_key_1", SecretKey: "secret_key_1"}
 	if err := preSignV4(iam, req, cred.AccessKey, cred.SecretKey, int64(10*time.Minute.Seconds())); err != nil {
 		t.Fatalf("Unable to initialized new signed http request %s", err)
 	}
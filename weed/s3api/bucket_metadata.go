@@ -38,6 +38,34 @@ type BucketMetaData struct {
 
 	// A list of grants for access controls.
 	Acl []*s3.Grant `locationName:"AccessControlList" locationNameList:"Grant" type:"list"`
+
+	// Versioning status of the bucket: "", "Enabled" or "Suspended".
+	// An empty value means versioning has never been configured.
+	Versioning string
+
+	// Parsed bucket policy, nil if the bucket has none.
+	Policy *BucketPolicy
+
+	// CORS configuration, nil if the bucket has none.
+	Cors *s3.CORSConfiguration
+
+	// Object lock configuration, nil if object lock is not enabled.
+	ObjectLock *s3.ObjectLockConfiguration
+
+	// Event notification configuration, nil if the bucket has none.
+	Notification *s3.NotificationConfiguration
+
+	// Static website configuration, nil if the bucket is not a website.
+	Website *s3.WebsiteConfiguration
+
+	// Replication configuration, nil if the bucket is not replicated.
+	Replication *s3.ReplicationConfiguration
+
+	// GET object transformation configuration, nil if the bucket has none.
+	Transformation *TransformationConfiguration
+
+	// Server access logging target, nil if logging is disabled.
+	Logging *s3.LoggingEnabled
 }
 
 type BucketRegistry struct {
@@ -106,6 +134,91 @@ func buildBucketMetadata(accountManager AccountManager, entry *filer_pb.Entry) *
 			}
 		}
 
+		//versioning
+		if versioning, ok := entry.Extended[s3_constants.ExtVersioningKey]; ok {
+			bucketMetadata.Versioning = string(versioning)
+		}
+
+		//bucket policy
+		if policyBytes, ok := entry.Extended[s3_constants.ExtBucketPolicyKey]; ok && len(policyBytes) > 0 {
+			policy, err := parseBucketPolicy(policyBytes)
+			if err == nil {
+				bucketMetadata.Policy = policy
+			} else {
+				glog.Warningf("Unmarshal bucket policy: %s(%v), bucket: %s", string(policyBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//cors
+		if corsBytes, ok := entry.Extended[s3_constants.ExtCorsKey]; ok && len(corsBytes) > 0 {
+			var cors s3.CORSConfiguration
+			if err := json.Unmarshal(corsBytes, &cors); err == nil {
+				bucketMetadata.Cors = &cors
+			} else {
+				glog.Warningf("Unmarshal bucket cors: %s(%v), bucket: %s", string(corsBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//object lock
+		if lockBytes, ok := entry.Extended[s3_constants.ExtObjectLockConfigKey]; ok && len(lockBytes) > 0 {
+			var objectLock s3.ObjectLockConfiguration
+			if err := json.Unmarshal(lockBytes, &objectLock); err == nil {
+				bucketMetadata.ObjectLock = &objectLock
+			} else {
+				glog.Warningf("Unmarshal bucket object lock: %s(%v), bucket: %s", string(lockBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//notification
+		if notificationBytes, ok := entry.Extended[s3_constants.ExtNotificationKey]; ok && len(notificationBytes) > 0 {
+			var notificationConfiguration s3.NotificationConfiguration
+			if err := json.Unmarshal(notificationBytes, &notificationConfiguration); err == nil {
+				bucketMetadata.Notification = &notificationConfiguration
+			} else {
+				glog.Warningf("Unmarshal bucket notification: %s(%v), bucket: %s", string(notificationBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//website
+		if websiteBytes, ok := entry.Extended[s3_constants.ExtWebsiteKey]; ok && len(websiteBytes) > 0 {
+			var website s3.WebsiteConfiguration
+			if err := json.Unmarshal(websiteBytes, &website); err == nil {
+				bucketMetadata.Website = &website
+			} else {
+				glog.Warningf("Unmarshal bucket website: %s(%v), bucket: %s", string(websiteBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//replication
+		if replicationBytes, ok := entry.Extended[s3_constants.ExtReplicationKey]; ok && len(replicationBytes) > 0 {
+			var replication s3.ReplicationConfiguration
+			if err := json.Unmarshal(replicationBytes, &replication); err == nil {
+				bucketMetadata.Replication = &replication
+			} else {
+				glog.Warningf("Unmarshal bucket replication: %s(%v), bucket: %s", string(replicationBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//transformation
+		if transformationBytes, ok := entry.Extended[s3_constants.ExtTransformationKey]; ok && len(transformationBytes) > 0 {
+			var transformation TransformationConfiguration
+			if err := json.Unmarshal(transformationBytes, &transformation); err == nil {
+				bucketMetadata.Transformation = &transformation
+			} else {
+				glog.Warningf("Unmarshal bucket transformation: %s(%v), bucket: %s", string(transformationBytes), err, bucketMetadata.Name)
+			}
+		}
+
+		//logging
+		if loggingBytes, ok := entry.Extended[s3_constants.ExtLoggingKey]; ok && len(loggingBytes) > 0 {
+			var logging s3.LoggingEnabled
+			if err := json.Unmarshal(loggingBytes, &logging); err == nil {
+				bucketMetadata.Logging = &logging
+			} else {
+				glog.Warningf("Unmarshal bucket logging: %s(%v), bucket: %s", string(loggingBytes), err, bucketMetadata.Name)
+			}
+		}
+
 		//access control policy
 		//owner
 		acpOwnerBytes, ok := entry.Extended[s3_constants.ExtAmzOwnerKey]
@@ -166,6 +279,15 @@ func (r *BucketRegistry) GetBucketMetadata(bucketName string) (*BucketMetaData,
 	return bucketMetadata, s3err.ErrNone
 }
 
+// GetBucketPolicy returns the cached policy of the bucket, nil if there is none
+func (r *BucketRegistry) GetBucketPolicy(bucketName string) *BucketPolicy {
+	bucketMetadata, errCode := r.GetBucketMetadata(bucketName)
+	if errCode != s3err.ErrNone {
+		return nil
+	}
+	return bucketMetadata.Policy
+}
+
 func (r *BucketRegistry) LoadBucketMetadataFromFiler(bucketName string) (*BucketMetaData, s3err.ErrorCode) {
 	r.notFoundLock.Lock()
 	defer r.notFoundLock.Unlock()
@@ -68,13 +68,16 @@ func (iam *IdentityAccessManagement) calculateSeedSignature(r *http.Request) (cr
 		return nil, "", "", time.Time{}, errCode
 	}
 	// Verify if the access key id matches.
-	identity, cred, found := iam.lookupByAccessKey(signV4Values.Credential.accessKey)
-	if !found {
-		return nil, "", "", time.Time{}, s3err.ErrInvalidAccessKeyID
+	identity, cred, errCode := iam.lookupCredential(signV4Values.Credential.accessKey, r.Header.Get("X-Amz-Security-Token"))
+	if errCode != s3err.ErrNone {
+		return nil, "", "", time.Time{}, errCode
 	}
 
 	bucket, object := s3_constants.GetBucketAndObject(r)
-	if !identity.canDo(s3_constants.ACTION_WRITE, bucket, object) {
+	identityPolicyEffect := identity.evaluateIdentityPolicy(r, bucket, object)
+	if identityPolicyEffect == policyEffectDeny ||
+		identityPolicyEffect != policyEffectAllow && !identity.canDo(s3_constants.ACTION_WRITE, bucket, object) ||
+		!identity.isAllowedBySessionPolicy(r, bucket, object) {
 		errCode = s3err.ErrAccessDenied
 		return
 	}
@@ -180,7 +180,10 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 		return nil, s3err.ErrEntityTooSmall
 	}
 	mime := pentry.Attributes.Mime
+	isEncrypted := len(pentry.Extended[s3_constants.ExtSseTypeKey]) > 0
 	var finalParts []*filer_pb.FileChunk
+	var sseSegments []string
+	completedEntries := make(map[int]*filer_pb.Entry, len(completedPartNumbers))
 	var offset int64
 	for _, partNumber := range completedPartNumbers {
 		partEntriesByNumber, ok := partEntries[partNumber]
@@ -201,6 +204,7 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 				stats.S3HandlerCounter.WithLabelValues(stats.ErrorCompletedPartEntryMismatch).Inc()
 				continue
 			}
+			partOffset := offset
 			for _, chunk := range entry.GetChunks() {
 				p := &filer_pb.FileChunk{
 					FileId:       chunk.GetFileIdString(),
@@ -213,11 +217,35 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 				finalParts = append(finalParts, p)
 				offset += int64(chunk.Size)
 			}
+			if isEncrypted {
+				// each part is encrypted with its own iv
+				iv := string(entry.Extended[s3_constants.ExtSseIvKey])
+				if iv == "" {
+					glog.Errorf("completeMultipartUpload %s part %s has no sse iv", *input.UploadId, entry.Name)
+					return nil, s3err.ErrInternalError
+				}
+				sseSegments = append(sseSegments, formatSseSegment(offset-partOffset, iv))
+			}
+			completedEntries[partNumber] = entry
 			found = true
 		}
 	}
+	if errCode := checkCompletedPartChecksums(parts.Parts, completedEntries); errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	orderedEntries := make([]*filer_pb.Entry, 0, len(completedPartNumbers))
+	for _, partNumber := range completedPartNumbers {
+		orderedEntries = append(orderedEntries, completedEntries[partNumber])
+	}
+	checksumAlgorithm, checksum := compositeChecksum(findChecksumAlgorithm(string(pentry.Extended[s3_constants.ExtChecksumAlgorithmKey])), orderedEntries)
 
 	entryName, dirName := s3a.getEntryNameAndDir(input)
+	object := "/" + *objectKey(input.Key)
+	versionId, err := s3a.prepareVersionedWrite(*input.Bucket, object)
+	if err != nil {
+		glog.Errorf("completeMultipartUpload %s/%s prepare version: %v", dirName, entryName, err)
+		return nil, s3err.ErrInternalError
+	}
 	err = s3a.mkFile(dirName, entryName, finalParts, func(entry *filer_pb.Entry) {
 		if entry.Extended == nil {
 			entry.Extended = make(map[string][]byte)
@@ -228,6 +256,15 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 				entry.Extended[k] = v
 			}
 		}
+		if versionId != "" {
+			entry.Extended[s3_constants.ExtVersionIdKey] = []byte(versionId)
+		}
+		if len(sseSegments) > 0 {
+			entry.Extended[s3_constants.ExtSseSegmentsKey] = []byte(strings.Join(sseSegments, ","))
+		}
+		if checksum != "" {
+			entry.Extended[checksumAlgorithm.extendedKey()] = []byte(checksum)
+		}
 		if pentry.Attributes.Mime != "" {
 			entry.Attributes.Mime = pentry.Attributes.Mime
 		} else if mime != "" {
@@ -238,6 +275,7 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 
 	if err != nil {
 		glog.Errorf("completeMultipartUpload %s/%s error: %v", dirName, entryName, err)
+		s3a.rollbackVersionedWrite(*input.Bucket, object)
 		return nil, s3err.ErrInternalError
 	}
 
@@ -249,6 +287,10 @@ func (s3a *S3ApiServer) completeMultipartUpload(input *s3.CompleteMultipartUploa
 			Key:      objectKey(input.Key),
 		},
 	}
+	if versionId != "" {
+		output.VersionId = aws.String(versionId)
+	}
+	setChecksumFields(checksumAlgorithm, checksum, &output.ChecksumCRC32, &output.ChecksumCRC32C, &output.ChecksumSHA1, &output.ChecksumSHA256)
 
 	for _, deleteEntry := range deleteEntries {
 		//delete unused part data
@@ -424,12 +466,15 @@ func (s3a *S3ApiServer) listObjectParts(input *s3.ListPartsInput) (output *ListP
 				glog.Errorf("listObjectParts %s %s parse %s: %v", *input.Bucket, *input.UploadId, entry.Name, err)
 				continue
 			}
-			output.Part = append(output.Part, &s3.Part{
+			part := &s3.Part{
 				PartNumber:   aws.Int64(int64(partNumber)),
 				LastModified: aws.Time(time.Unix(entry.Attributes.Mtime, 0).UTC()),
 				Size:         aws.Int64(int64(filer.FileSize(entry))),
 				ETag:         aws.String("\"" + filer.ETag(entry) + "\""),
-			})
+			}
+			algorithm, checksum := entryChecksum(entry.Extended)
+			setChecksumFields(algorithm, checksum, &part.ChecksumCRC32, &part.ChecksumCRC32C, &part.ChecksumSHA1, &part.ChecksumSHA256)
+			output.Part = append(output.Part, part)
 			if !isLast {
 				output.NextPartNumberMarker = aws.Int64(int64(partNumber))
 			}
@@ -4,4 +4,32 @@ const (
 	ExtAmzOwnerKey  = "Seaweed-X-Amz-Owner"
 	ExtAmzAclKey    = "Seaweed-X-Amz-Acl"
 	ExtOwnershipKey = "Seaweed-X-Amz-Ownership"
+
+	ExtVersioningKey   = "Seaweed-X-Amz-Versioning"
+	ExtVersionIdKey    = "Seaweed-X-Amz-Version-Id"
+	ExtDeleteMarkerKey = "Seaweed-X-Amz-Delete-Marker"
+
+	ExtBucketPolicyKey = "Seaweed-X-Amz-Bucket-Policy"
+	ExtCorsKey         = "Seaweed-X-Amz-Cors"
+	ExtLifecycleKey    = "Seaweed-X-Amz-Lifecycle"
+	ExtNotificationKey = "Seaweed-X-Amz-Notification"
+	ExtWebsiteKey      = "Seaweed-X-Amz-Website"
+	ExtReplicationKey  = "Seaweed-X-Amz-Replication"
+
+	ExtTransformationKey = "Seaweed-X-Amz-Transformation"
+	ExtLoggingKey        = "Seaweed-X-Amz-Logging"
+	ExtInventoryKey      = "Seaweed-X-Amz-Inventory"
+
+	ExtObjectLockConfigKey = "Seaweed-X-Amz-Object-Lock-Configuration"
+
+	ExtSseTypeKey           = "Seaweed-X-Amz-Sse-Type"
+	ExtSseKmsKeyIdKey       = "Seaweed-X-Amz-Sse-Kms-Key-Id"
+	ExtSseDataKeyKey        = "Seaweed-X-Amz-Sse-Data-Key"
+	ExtSseCustomerKeyMd5Key = "Seaweed-X-Amz-Sse-Customer-Key-Md5"
+	ExtSseIvKey             = "Seaweed-X-Amz-Sse-Iv"
+	ExtSseSegmentsKey       = "Seaweed-X-Amz-Sse-Segments"
+
+	// followed by the checksum algorithm, such as "Seaweed-X-Amz-Checksum-Crc32c"
+	ExtChecksumPrefix       = "Seaweed-X-Amz-Checksum-"
+	ExtChecksumAlgorithmKey = "Seaweed-X-Amz-Checksum-Algorithm"
 )
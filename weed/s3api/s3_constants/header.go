@@ -27,6 +27,12 @@ const (
 	// S3 storage class
 	AmzStorageClass = "x-amz-storage-class"
 
+	// S3 static website redirect of the object
+	AmzWebsiteRedirectLocation = "X-Amz-Website-Redirect-Location"
+
+	// S3 bucket replication status of the object
+	AmzReplicationStatus = "X-Amz-Replication-Status"
+
 	// S3 user-defined metadata
 	AmzUserMetaPrefix    = "X-Amz-Meta-"
 	AmzUserMetaDirective = "X-Amz-Metadata-Directive"
@@ -41,6 +47,14 @@ const (
 	SeaweedFSIsDirectoryKey = "X-Seaweedfs-Is-Directory-Key"
 	SeaweedFSPartNumber     = "X-Seaweedfs-Part-Number"
 	SeaweedFSUploadId       = "X-Seaweedfs-Upload-Id"
+	// the versioning state of a conditional write, the filer archives the current object after checking the conditions
+	SeaweedFSArchiveVersioning = "X-Seaweedfs-Archive-Versioning"
+
+	// GET object transformations: the query parameter selecting the transformation rule,
+	// and the headers describing the object to the HTTP callout transformers
+	SeaweedTransformQuery  = "x-seaweed-transform"
+	SeaweedTransformBucket = "X-Seaweedfs-Transform-Bucket"
+	SeaweedTransformKey    = "X-Seaweedfs-Transform-Key"
 
 	// S3 ACL headers
 	AmzCannedAcl      = "X-Amz-Acl"
@@ -51,6 +65,39 @@ const (
 	AmzAclWriteAcp    = "X-Amz-Grant-Write-Acp"
 
 	AmzMpPartsCount = "X-Amz-Mp-Parts-Count"
+
+	// S3 object versioning
+	AmzVersionId    = "X-Amz-Version-Id"
+	AmzDeleteMarker = "X-Amz-Delete-Marker"
+
+	// S3 object lock
+	AmzObjectLockMode            = "X-Amz-Object-Lock-Mode"
+	AmzObjectLockRetainUntilDate = "X-Amz-Object-Lock-Retain-Until-Date"
+	AmzObjectLockLegalHold       = "X-Amz-Object-Lock-Legal-Hold"
+	AmzBypassGovernanceRetention = "X-Amz-Bypass-Governance-Retention"
+	AmzBucketObjectLockEnabled   = "X-Amz-Bucket-Object-Lock-Enabled"
+
+	// S3 server side encryption
+	AmzServerSideEncryption                            = "X-Amz-Server-Side-Encryption"
+	AmzServerSideEncryptionAwsKmsKeyId                 = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+	AmzServerSideEncryptionCustomerAlgorithm           = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	AmzServerSideEncryptionCustomerKey                 = "X-Amz-Server-Side-Encryption-Customer-Key"
+	AmzServerSideEncryptionCustomerKeyMD5              = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+	AmzCopySourceServerSideEncryptionCustomerAlgorithm = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Algorithm"
+	AmzCopySourceServerSideEncryptionCustomerKey       = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key"
+	AmzCopySourceServerSideEncryptionCustomerKeyMD5    = "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5"
+
+	// S3 conditional copy
+	AmzCopySourceIfMatch           = "X-Amz-Copy-Source-If-Match"
+	AmzCopySourceIfNoneMatch       = "X-Amz-Copy-Source-If-None-Match"
+	AmzCopySourceIfModifiedSince   = "X-Amz-Copy-Source-If-Modified-Since"
+	AmzCopySourceIfUnmodifiedSince = "X-Amz-Copy-Source-If-Unmodified-Since"
+
+	// S3 additional checksums
+	AmzChecksumPrefix       = "X-Amz-Checksum-"
+	AmzChecksumAlgorithm    = "X-Amz-Checksum-Algorithm"
+	AmzSdkChecksumAlgorithm = "X-Amz-Sdk-Checksum-Algorithm"
+	AmzChecksumMode         = "X-Amz-Checksum-Mode"
 )
 
 // Non-Standard S3 HTTP request constants
@@ -59,8 +106,26 @@ const (
 	AmzAccountId  = "s3-account-id"
 	AmzAuthType   = "s3-auth-type"
 	AmzIsAdmin    = "s3-is-admin" // only set to http request header as a context
+
+	AmzPolicyAllowed = "s3-policy-allowed" // only set to http request header as a context
+	AmzAclAllowed    = "s3-acl-allowed"    // only set to http request header as a context
+
+	// signed query parameters restricting who can use a presigned url
+	PresignedSourceIp         = "X-Seaweed-Source-Ip"
+	PresignedMaxContentLength = "X-Seaweed-Max-Content-Length"
 )
 
+// IsValidWebsiteRedirectLocation checks the website redirect location is an absolute path or an http(s) url
+func IsValidWebsiteRedirectLocation(location string) bool {
+	if strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\") {
+		// protocol relative urls lead to another host
+		return false
+	}
+	return strings.HasPrefix(location, "/") ||
+		strings.HasPrefix(location, "http://") ||
+		strings.HasPrefix(location, "https://")
+}
+
 func GetBucketAndObject(r *http.Request) (bucket, object string) {
 	vars := mux.Vars(r)
 	bucket = vars["bucket"]
@@ -0,0 +1,19 @@
+package s3_constants
+
+const (
+	ObjectLockEnabled = "Enabled"
+
+	ObjectLockModeGovernance = "GOVERNANCE"
+	ObjectLockModeCompliance = "COMPLIANCE"
+
+	ObjectLockLegalHoldOn  = "ON"
+	ObjectLockLegalHoldOff = "OFF"
+)
+
+func ValidateObjectLockMode(mode string) bool {
+	return mode == ObjectLockModeGovernance || mode == ObjectLockModeCompliance
+}
+
+func ValidateObjectLockLegalHold(status string) bool {
+	return status == ObjectLockLegalHoldOn || status == ObjectLockLegalHoldOff
+}
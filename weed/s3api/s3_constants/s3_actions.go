@@ -11,5 +11,8 @@ const (
 
 	SeaweedStorageDestinationHeader = "x-seaweedfs-destination"
 	MultipartUploadsFolder          = ".uploads"
+	VersionsFolder                  = ".versions"
+	NullVersionId                   = "null"
+	VersioningSuspended             = "Suspended"
 	FolderMimeType                  = "httpd/unix-directory"
 )
@@ -0,0 +1,12 @@
+package s3_constants
+
+const (
+	// values of the server side encryption headers
+	SseAlgorithmAES256 = "AES256"
+	SseAlgorithmKms    = "aws:kms"
+
+	// how an object is encrypted, kept in ExtSseTypeKey
+	SseTypeS3       = "SSE-S3"
+	SseTypeKms      = "SSE-KMS"
+	SseTypeCustomer = "SSE-C"
+)
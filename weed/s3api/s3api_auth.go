@@ -90,3 +90,21 @@ func getRequestAuthType(r *http.Request) authType {
 	}
 	return authTypeUnknown
 }
+
+// getRequestAccessKey returns the access key the request is signed with, or "" if it is not signed
+func getRequestAccessKey(r *http.Request) string {
+	switch getRequestAuthType(r) {
+	case authTypeSignedV2:
+		accessKey, _ := validateV2AuthHeader(r.Header.Get("Authorization"))
+		return accessKey
+	case authTypePresignedV2:
+		return r.URL.Query().Get("AWSAccessKeyId")
+	case authTypeSigned, authTypeStreamingSigned:
+		signV4Values, _ := parseSignV4(r.Header.Get("Authorization"))
+		return signV4Values.Credential.accessKey
+	case authTypePresigned:
+		accessKey, _, _ := strings.Cut(r.URL.Query().Get("X-Amz-Credential"), "/")
+		return accessKey
+	}
+	return ""
+}
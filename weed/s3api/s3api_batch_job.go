@@ -0,0 +1,668 @@
+package s3api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Batch jobs run one operation on every object under a prefix of a bucket, or on every object
+// listed by a CSV manifest of "bucket,key[,versionId]" lines, in the background.
+// The jobs are kept in the filer with their progress and the position of the last processed object,
+// so that a job resumes where it stopped when the gateway running it restarts or loses the lock.
+// The objects under a prefix are visited in key order, which is also the order of the resume position.
+
+const (
+	batchJobLockName           = "s3.batch"
+	batchJobScanInterval       = 10 * time.Second
+	batchJobCheckpointInterval = 5 * time.Second
+	batchJobListLimit          = 1024
+	maxBatchJobFailures        = 1000
+
+	batchJobStatusNew       = "New"
+	batchJobStatusActive    = "Active"
+	batchJobStatusComplete  = "Complete"
+	batchJobStatusCancelled = "Cancelled"
+	batchJobStatusFailed    = "Failed"
+)
+
+var (
+	errBatchJobCancelled = errors.New("batch job cancelled")
+	errBatchJobStopped   = errors.New("batch job stopped")
+)
+
+// BatchJob is an operation on the objects under a prefix of a bucket, or listed by a manifest
+type BatchJob struct {
+	XMLName         xml.Name          `xml:"BatchJob" json:"-"`
+	JobId           string            `xml:"JobId,omitempty"`
+	Bucket          string            `xml:"Bucket,omitempty"`
+	Prefix          string            `xml:"Prefix,omitempty"`
+	Manifest        *BatchJobManifest `xml:"Manifest,omitempty"`
+	Operation       BatchJobOperation `xml:"Operation"`
+	Report          *BatchJobReport   `xml:"Report,omitempty"`
+	Status          string            `xml:"Status,omitempty"`
+	FailureReason   string            `xml:"FailureReason,omitempty"`
+	CreationTime    time.Time         `xml:"CreationTime"`
+	TerminationTime *time.Time        `xml:"TerminationTime,omitempty"`
+	Progress        BatchJobProgress  `xml:"ProgressSummary"`
+	Failures        []BatchJobFailure `xml:"Failures>Failure,omitempty"`
+
+	// the account creating the job, owning the copied objects
+	AccountId string `xml:"-"`
+	// the last processed key, or the number of processed manifest lines
+	Position string `xml:"-"`
+}
+
+// BatchJobManifest is a CSV object listing the objects of the job
+type BatchJobManifest struct {
+	Bucket string `xml:"Bucket"`
+	Key    string `xml:"Key"`
+}
+
+// BatchJobOperation holds exactly one operation
+type BatchJobOperation struct {
+	Delete          *BatchJobDelete          `xml:"Delete,omitempty"`
+	Copy            *BatchJobCopy            `xml:"Copy,omitempty"`
+	PutTagging      *BatchJobPutTagging      `xml:"PutTagging,omitempty"`
+	SetStorageClass *BatchJobSetStorageClass `xml:"SetStorageClass,omitempty"`
+}
+
+// BatchJobDelete deletes the objects, or adds delete markers if the bucket is versioned
+type BatchJobDelete struct{}
+
+// BatchJobCopy copies the objects, with their metadata and tags, to the target prefix of the target bucket
+type BatchJobCopy struct {
+	TargetBucket string `xml:"TargetBucket"`
+	TargetPrefix string `xml:"TargetPrefix,omitempty"`
+	StorageClass string `xml:"StorageClass,omitempty"`
+}
+
+// BatchJobPutTagging replaces the tags of the objects
+type BatchJobPutTagging struct {
+	TagSet TagSet `xml:"TagSet"`
+}
+
+// BatchJobSetStorageClass moves the objects to another storage class, like a lifecycle transition
+type BatchJobSetStorageClass struct {
+	StorageClass string `xml:"StorageClass"`
+}
+
+// BatchJobReport is where the job report is written when the job terminates
+type BatchJobReport struct {
+	Bucket string `xml:"Bucket"`
+	Prefix string `xml:"Prefix,omitempty"`
+}
+
+type BatchJobProgress struct {
+	NumberOfTasksSucceeded int64 `xml:"NumberOfTasksSucceeded"`
+	NumberOfTasksFailed    int64 `xml:"NumberOfTasksFailed"`
+}
+
+type BatchJobFailure struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+	Error     string `xml:"Error"`
+}
+
+func (op *BatchJobOperation) count() (n int) {
+	for _, set := range []bool{op.Delete != nil, op.Copy != nil, op.PutTagging != nil, op.SetStorageClass != nil} {
+		if set {
+			n++
+		}
+	}
+	return
+}
+
+// validateBatchJob checks the job, and that the other buckets it uses have the same owner as the bucket
+func (s3a *S3ApiServer) validateBatchJob(bucket string, job *BatchJob) s3err.ErrorCode {
+	op := &job.Operation
+	if op.count() != 1 {
+		return s3err.ErrMalformedXML
+	}
+	if job.Manifest != nil && (job.Prefix != "" || job.Manifest.Bucket == "" || job.Manifest.Key == "") {
+		return s3err.ErrInvalidRequest
+	}
+	switch {
+	case op.Copy != nil:
+		if op.Copy.TargetBucket == "" {
+			return s3err.ErrMalformedXML
+		}
+		// the copies must not be copied again by the same job
+		if op.Copy.TargetBucket == bucket && (op.Copy.TargetPrefix == "" || job.Manifest == nil && strings.HasPrefix(op.Copy.TargetPrefix, job.Prefix)) {
+			return s3err.ErrInvalidRequest
+		}
+	case op.PutTagging != nil:
+		if err := ValidateTags((&Tagging{TagSet: op.PutTagging.TagSet}).ToTags()); err != nil {
+			return s3err.ErrInvalidTag
+		}
+	case op.SetStorageClass != nil:
+		if op.SetStorageClass.StorageClass == "" {
+			return s3err.ErrMalformedXML
+		}
+	}
+
+	var otherBuckets []string
+	if op.Copy != nil {
+		otherBuckets = append(otherBuckets, op.Copy.TargetBucket)
+	}
+	if job.Manifest != nil {
+		otherBuckets = append(otherBuckets, job.Manifest.Bucket)
+	}
+	if job.Report != nil {
+		otherBuckets = append(otherBuckets, job.Report.Bucket)
+	}
+	if len(otherBuckets) == 0 {
+		return s3err.ErrNone
+	}
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	for _, otherBucket := range otherBuckets {
+		otherMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(otherBucket)
+		if errCode == s3err.ErrNoSuchBucket {
+			return s3err.ErrInvalidRequest
+		}
+		if errCode != s3err.ErrNone {
+			return errCode
+		}
+		if bucketOwnerId(bucketMetadata) != bucketOwnerId(otherMetadata) {
+			return s3err.ErrAccessDenied
+		}
+	}
+	return s3err.ErrNone
+}
+
+// authorizeBatchJob checks that the requester may take the job operation on the objects under the job prefix,
+// read the manifest, and write the copies and the report, since the job runs as the gateway later on
+func (s3a *S3ApiServer) authorizeBatchJob(r *http.Request, bucket string, job *BatchJob) s3err.ErrorCode {
+	type permission struct {
+		action       Action
+		policyAction string
+		bucket       string
+		prefix       string
+	}
+	var permissions []permission
+	op := &job.Operation
+	switch {
+	case op.Delete != nil:
+		permissions = append(permissions, permission{s3_constants.ACTION_WRITE, "s3:DeleteObject", bucket, job.Prefix})
+		if job.Manifest != nil {
+			permissions = append(permissions, permission{s3_constants.ACTION_WRITE, "s3:DeleteObjectVersion", bucket, job.Prefix})
+		}
+	case op.Copy != nil:
+		permissions = append(permissions,
+			permission{s3_constants.ACTION_READ, "s3:GetObject", bucket, job.Prefix},
+			permission{s3_constants.ACTION_WRITE, "s3:PutObject", op.Copy.TargetBucket, op.Copy.TargetPrefix})
+	case op.PutTagging != nil:
+		permissions = append(permissions, permission{s3_constants.ACTION_TAGGING, "s3:PutObjectTagging", bucket, job.Prefix})
+	case op.SetStorageClass != nil:
+		permissions = append(permissions, permission{s3_constants.ACTION_WRITE, "s3:PutObject", bucket, job.Prefix})
+	}
+	if job.Report != nil {
+		permissions = append(permissions, permission{s3_constants.ACTION_WRITE, "s3:PutObject", job.Report.Bucket, job.Report.Prefix})
+	}
+	// the objects under a prefix are checked as the prefix followed by a wildcard
+	for i := range permissions {
+		permissions[i].prefix += "*"
+	}
+	if job.Manifest != nil {
+		permissions = append(permissions, permission{s3_constants.ACTION_READ, "s3:GetObject", job.Manifest.Bucket, job.Manifest.Key})
+	}
+	for _, p := range permissions {
+		if !s3a.iam.isAuthorizedFor(r, p.action, p.policyAction, p.bucket, "/"+p.prefix) {
+			glog.V(1).Infof("batch job on bucket %s: %s denied on %s/%s", bucket, p.policyAction, p.bucket, p.prefix)
+			return s3err.ErrAccessDenied
+		}
+	}
+	return s3err.ErrNone
+}
+
+func (s3a *S3ApiServer) saveBatchJob(job *BatchJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return filer.SaveInsideFiler(client, s3_constants.BatchJobConfigDir, job.JobId+".json", data)
+	})
+}
+
+func (s3a *S3ApiServer) loadBatchJob(jobId string) (job *BatchJob, err error) {
+	err = s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		data, err := filer.ReadInsideFiler(client, s3_constants.BatchJobConfigDir, jobId+".json")
+		if err != nil {
+			return err
+		}
+		job = &BatchJob{}
+		return json.Unmarshal(data, job)
+	})
+	return
+}
+
+// listBatchJobs lists the jobs of the bucket, or of all buckets, by creation time
+func (s3a *S3ApiServer) listBatchJobs(bucket string) (jobs []*BatchJob, err error) {
+	err = s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.SeaweedList(client, s3_constants.BatchJobConfigDir, "", func(entry *filer_pb.Entry, isLast bool) error {
+			if entry.IsDirectory || !strings.HasSuffix(entry.Name, ".json") {
+				return nil
+			}
+			job := &BatchJob{}
+			if err := json.Unmarshal(entry.Content, job); err != nil {
+				glog.Errorf("unmarshal batch job %s: %v", entry.Name, err)
+				return nil
+			}
+			if bucket == "" || job.Bucket == bucket {
+				jobs = append(jobs, job)
+			}
+			return nil
+		}, "", false, math.MaxInt32)
+	})
+	if err == filer_pb.ErrNotFound {
+		err = nil
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreationTime.Before(jobs[j].CreationTime)
+	})
+	return
+}
+
+// startBatchJobWorker runs the new and interrupted batch jobs, one at a time.
+// All S3 gateways compete for the same filer lock, so only one of them runs the jobs.
+func (s3a *S3ApiServer) startBatchJobWorker() {
+	owner := fmt.Sprintf("%s:%d", util.DetectedHostAddress(), s3a.option.Port)
+	lockClient := cluster.NewLockClient(s3a.option.GrpcDialOption, s3a.option.Filer)
+	lock := lockClient.StartLongLivedLock(batchJobLockName, owner, func(newLockOwner string) {
+		glog.V(0).Infof("s3 batch job worker is %s", newLockOwner)
+	})
+	isOwner := func() bool {
+		return lock.LockOwner() == owner
+	}
+	for {
+		time.Sleep(batchJobScanInterval)
+		if !isOwner() {
+			continue
+		}
+		jobs, err := s3a.listBatchJobs("")
+		if err != nil {
+			glog.Errorf("list batch jobs: %v", err)
+			continue
+		}
+		for _, job := range jobs {
+			if job.Status != batchJobStatusNew && job.Status != batchJobStatusActive {
+				continue
+			}
+			if !isOwner() {
+				break
+			}
+			s3a.runBatchJob(job, isOwner)
+		}
+	}
+}
+
+// batchJobRun tracks a running job, and saves its progress periodically
+type batchJobRun struct {
+	s3a      *S3ApiServer
+	job      *BatchJob
+	isOwner  func() bool
+	lastSave time.Time
+}
+
+func (s3a *S3ApiServer) runBatchJob(job *BatchJob, isOwner func() bool) {
+	glog.V(0).Infof("run batch job %s on bucket %s from %q", job.JobId, job.Bucket, job.Position)
+	run := &batchJobRun{s3a: s3a, job: job, isOwner: isOwner}
+	job.Status = batchJobStatusActive
+	err := run.save()
+	if err == nil {
+		if job.Manifest != nil {
+			err = run.processManifest()
+		} else {
+			err = run.processPrefix()
+		}
+	}
+
+	switch err {
+	case errBatchJobStopped:
+		glog.V(0).Infof("batch job %s stopped at %q", job.JobId, job.Position)
+		return
+	case errBatchJobCancelled:
+		job.Status = batchJobStatusCancelled
+	case nil:
+		job.Status = batchJobStatusComplete
+	default:
+		glog.Errorf("batch job %s: %v", job.JobId, err)
+		job.Status = batchJobStatusFailed
+		job.FailureReason = err.Error()
+	}
+	now := time.Now()
+	job.TerminationTime = &now
+	if err := s3a.writeBatchJobReport(job); err != nil {
+		glog.Errorf("write batch job %s report: %v", job.JobId, err)
+	}
+	if err := s3a.saveBatchJob(job); err != nil {
+		glog.Errorf("save batch job %s: %v", job.JobId, err)
+	}
+	glog.V(0).Infof("batch job %s %s: %d succeeded, %d failed", job.JobId, job.Status, job.Progress.NumberOfTasksSucceeded, job.Progress.NumberOfTasksFailed)
+}
+
+// save stores the progress, unless this gateway lost the lock or the job has been cancelled
+func (run *batchJobRun) save() error {
+	if !run.isOwner() {
+		return errBatchJobStopped
+	}
+	stored, err := run.s3a.loadBatchJob(run.job.JobId)
+	if err != nil {
+		return fmt.Errorf("load job: %v", err)
+	}
+	if stored.Status == batchJobStatusCancelled {
+		return errBatchJobCancelled
+	}
+	if err = run.s3a.saveBatchJob(run.job); err != nil {
+		return fmt.Errorf("save job: %v", err)
+	}
+	run.lastSave = time.Now()
+	return nil
+}
+
+// process runs the operation on one object, then moves the position past it
+func (run *batchJobRun) process(key, versionId, position string) error {
+	if err := run.s3a.executeBatchJobOperation(run.job, key, versionId); err != nil {
+		run.job.recordFailure(key, versionId, err)
+	} else {
+		run.job.Progress.NumberOfTasksSucceeded++
+	}
+	run.job.Position = position
+	if time.Since(run.lastSave) < batchJobCheckpointInterval {
+		return nil
+	}
+	return run.save()
+}
+
+func (job *BatchJob) recordFailure(key, versionId string, err error) {
+	glog.V(1).Infof("batch job %s on %s/%s: %v", job.JobId, job.Bucket, key, err)
+	job.Progress.NumberOfTasksFailed++
+	if len(job.Failures) < maxBatchJobFailures {
+		job.Failures = append(job.Failures, BatchJobFailure{Key: key, VersionId: versionId, Error: err.Error()})
+	}
+}
+
+func (run *batchJobRun) processPrefix() error {
+	bucketDir := fmt.Sprintf("%s/%s", run.s3a.option.BucketsPath, run.job.Bucket)
+	return run.s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		err := walkBatchJobKeys(client, bucketDir, "", run.job.Prefix, run.job.Position, func(key string, entry *filer_pb.Entry) error {
+			return run.process(key, "", key)
+		})
+		if err == filer_pb.ErrNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// walkBatchJobKeys visits the objects under bucketDir/relativeDir whose key starts with prefix, in key order,
+// after the key startAfter. The folders are listed by pages, so that their objects can be changed while visited.
+func walkBatchJobKeys(client filer_pb.SeaweedFilerClient, bucketDir, relativeDir, prefix, startAfter string, fn func(key string, entry *filer_pb.Entry) error) error {
+	dir := bucketDir
+	if relativeDir != "" {
+		dir = bucketDir + "/" + relativeDir
+	}
+	// resuming inside a sub folder lists from that folder included
+	startFrom, subStartAfter, inSubDir := strings.Cut(startAfter, "/")
+	for {
+		var entries []*filer_pb.Entry
+		err := filer_pb.SeaweedList(client, dir, "", func(entry *filer_pb.Entry, isLast bool) error {
+			entries = append(entries, entry)
+			return nil
+		}, startFrom, inSubDir, batchJobListLimit)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			key := entry.Name
+			if relativeDir != "" {
+				key = relativeDir + "/" + entry.Name
+			}
+			if !entry.IsDirectory {
+				if strings.HasPrefix(key, prefix) {
+					if err := fn(key, entry); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if relativeDir == "" && (entry.Name == s3_constants.MultipartUploadsFolder || entry.Name == s3_constants.VersionsFolder) {
+				continue
+			}
+			if !strings.HasPrefix(key, prefix) && !strings.HasPrefix(prefix, key+"/") {
+				continue
+			}
+			after := ""
+			if inSubDir && entry.Name == startFrom {
+				after = subStartAfter
+			}
+			if err := walkBatchJobKeys(client, bucketDir, key, prefix, after, fn); err != nil {
+				return err
+			}
+		}
+		if len(entries) < batchJobListLimit {
+			return nil
+		}
+		startFrom, inSubDir = entries[len(entries)-1].Name, false
+	}
+}
+
+// processManifest skips the lines processed before, and runs the operation on the objects of the other lines
+func (run *batchJobRun) processManifest() error {
+	manifest := run.job.Manifest
+	dir, name := util.FullPath(fmt.Sprintf("%s/%s/%s", run.s3a.option.BucketsPath, manifest.Bucket, manifest.Key)).DirAndName()
+	entry, err := run.s3a.getEntry(dir, name)
+	if err != nil {
+		return fmt.Errorf("manifest %s/%s: %v", manifest.Bucket, manifest.Key, err)
+	}
+	processed, _ := strconv.Atoi(run.job.Position)
+
+	reader := csv.NewReader(filer.NewFileReader(run.s3a, entry))
+	reader.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("manifest %s/%s line %d: %v", manifest.Bucket, manifest.Key, line, err)
+		}
+		if line <= processed {
+			continue
+		}
+		// the manifest content is not echoed in the failures, which are visible to whoever can describe the job
+		bucket, key, versionId, err := parseBatchJobManifestRecord(record)
+		if err != nil {
+			run.job.recordFailure("", "", fmt.Errorf("manifest line %d: invalid record", line))
+			continue
+		}
+		if bucket != run.job.Bucket {
+			run.job.recordFailure("", "", fmt.Errorf("manifest line %d: object of another bucket", line))
+			continue
+		}
+		if err = run.process(key, versionId, strconv.Itoa(line)); err != nil {
+			return err
+		}
+	}
+}
+
+// parseBatchJobManifestRecord parses a manifest line of "bucket,key[,versionId]", with an URL encoded key
+func parseBatchJobManifestRecord(record []string) (bucket, key, versionId string, err error) {
+	if len(record) < 2 || len(record) > 3 {
+		return "", "", "", fmt.Errorf("%d fields instead of 2 or 3", len(record))
+	}
+	if key, err = url.QueryUnescape(record[1]); err != nil {
+		return "", "", "", err
+	}
+	if len(record) == 3 {
+		versionId = record[2]
+	}
+	return record[0], strings.TrimPrefix(key, "/"), versionId, nil
+}
+
+// executeBatchJobOperation runs the job operation on an object. Only deletions apply to specific versions.
+func (s3a *S3ApiServer) executeBatchJobOperation(job *BatchJob, key, versionId string) error {
+	op := &job.Operation
+	if versionId != "" && op.Delete == nil {
+		return fmt.Errorf("only Delete applies to a version")
+	}
+	object := "/" + key
+	dir, name := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, job.Bucket, object)).DirAndName()
+	switch {
+	case op.Delete != nil:
+		if !s3a.isVersioningConfigured(job.Bucket) {
+			if versionId != "" && versionId != nullVersionId {
+				return fmt.Errorf("versioning is not configured")
+			}
+			return s3a.rm(dir, name, true, false)
+		}
+		r, _ := http.NewRequest(http.MethodDelete, "", nil)
+		if errCode := s3a.checkObjectLockForDelete(r, job.Bucket, object, versionId); errCode != s3err.ErrNone {
+			return errors.New(s3err.GetAPIError(errCode).Code)
+		}
+		_, _, err := s3a.deleteVersionedObject(job.Bucket, object, versionId)
+		return err
+	case op.Copy != nil:
+		return s3a.copyBatchJobObject(job, dir, name, key)
+	case op.PutTagging != nil:
+		return s3a.setTags(dir, name, (&Tagging{TagSet: op.PutTagging.TagSet}).ToTags())
+	case op.SetStorageClass != nil:
+		entry, err := s3a.getEntry(dir, name)
+		if err != nil {
+			return err
+		}
+		return s3a.transitionObject(&lifecycleTransition{dir: dir, entry: entry, storageClass: op.SetStorageClass.StorageClass}, time.Now())
+	}
+	return nil
+}
+
+// copyBatchJobObject copies the object content, decrypted if needed, with its metadata and tags.
+// The copy is encrypted the same way, and owned by the account which created the job.
+func (s3a *S3ApiServer) copyBatchJobObject(job *BatchJob, dir, name, key string) error {
+	entry, err := s3a.getEntry(dir, name)
+	if err != nil {
+		return err
+	}
+	copyOp := job.Operation.Copy
+	dstBucket, dstObject := copyOp.TargetBucket, "/"+copyOp.TargetPrefix+key
+
+	r, _ := http.NewRequest(http.MethodPut, "", nil)
+	r.Header.Set(s3_constants.AmzAccountId, job.AccountId)
+	var reader io.Reader = filer.NewFileReader(s3a, entry)
+	info, err := sseInfoFromExtended(entry.Extended)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		if info.sseType == s3_constants.SseTypeCustomer {
+			return fmt.Errorf("objects encrypted with customer keys can not be copied")
+		}
+		dataKey, errCode := s3a.sseDataKey(info, nil)
+		if errCode != s3err.ErrNone {
+			return fmt.Errorf("object data key: %v", s3err.GetAPIError(errCode).Code)
+		}
+		if reader, err = newSseReader(reader, dataKey, info.segments, 0); err != nil {
+			return err
+		}
+		if info.sseType == s3_constants.SseTypeKms {
+			r.Header.Set(s3_constants.AmzServerSideEncryption, s3_constants.SseAlgorithmKms)
+			r.Header.Set(s3_constants.AmzServerSideEncryptionAwsKmsKeyId, info.kmsKeyId)
+		} else {
+			r.Header.Set(s3_constants.AmzServerSideEncryption, s3_constants.SseAlgorithmAES256)
+		}
+	}
+
+	if entry.Attributes != nil && entry.Attributes.Mime != "" {
+		r.Header.Set("Content-Type", entry.Attributes.Mime)
+	}
+	var tags []string
+	for k, v := range entry.Extended {
+		switch {
+		case strings.HasPrefix(k, s3_constants.AmzUserMetaPrefix), k == "Content-Encoding", k == s3_constants.AmzStorageClass:
+			r.Header.Set(k, string(v))
+		case strings.HasPrefix(k, S3TAG_PREFIX):
+			tags = append(tags, k[len(S3TAG_PREFIX):]+"="+string(v))
+		}
+	}
+	if len(tags) > 0 {
+		r.Header.Set(s3_constants.AmzObjectTagging, strings.Join(tags, "&"))
+	}
+	if copyOp.StorageClass != "" {
+		r.Header.Set(s3_constants.AmzStorageClass, copyOp.StorageClass)
+	}
+
+	if errCode := s3a.setObjectLockHeaders(r, dstBucket); errCode != s3err.ErrNone {
+		return errors.New(s3err.GetAPIError(errCode).Code)
+	}
+	if errCode := s3a.setObjectAclHeaders(r, dstBucket); errCode != s3err.ErrNone {
+		return errors.New(s3err.GetAPIError(errCode).Code)
+	}
+	dstReader, _, errCode := s3a.encryptObject(r, reader)
+	if errCode != s3err.ErrNone {
+		return errors.New(s3err.GetAPIError(errCode).Code)
+	}
+	if _, errCode = s3a.setVersionIdHeader(r, dstBucket, dstObject); errCode != s3err.ErrNone {
+		return errors.New(s3err.GetAPIError(errCode).Code)
+	}
+	destination := fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, dstBucket, dstObject)
+	if _, errCode = s3a.putToFiler(r, s3a.toFilerUrl(dstBucket, dstObject), dstReader, destination, dstBucket, nil); errCode != s3err.ErrNone {
+		s3a.rollbackVersionedWrite(dstBucket, dstObject)
+		return errors.New(s3err.GetAPIError(errCode).Code)
+	}
+	return nil
+}
+
+// writeBatchJobReport writes the job summary as "job-<id>/report.json", and its failures as "job-<id>/failures.csv"
+func (s3a *S3ApiServer) writeBatchJobReport(job *BatchJob) error {
+	if job.Report == nil {
+		return nil
+	}
+	reportPrefix := strings.TrimPrefix(fmt.Sprintf("%s/job-%s/", strings.TrimSuffix(job.Report.Prefix, "/"), job.JobId), "/")
+
+	var failures bytes.Buffer
+	writer := csv.NewWriter(&failures)
+	for _, failure := range job.Failures {
+		writer.Write([]string{job.Bucket, url.QueryEscape(failure.Key), failure.VersionId, failure.Error})
+	}
+	writer.Flush()
+	if err := s3a.putObjectBytes(job.Report.Bucket, reportPrefix+"failures.csv", "text/csv", failures.Bytes()); err != nil {
+		return err
+	}
+
+	summary, err := json.Marshal(struct {
+		JobId           string
+		Bucket          string
+		Status          string
+		FailureReason   string `json:",omitempty"`
+		CreationTime    time.Time
+		TerminationTime *time.Time
+		Progress        BatchJobProgress
+	}{job.JobId, job.Bucket, job.Status, job.FailureReason, job.CreationTime, job.TerminationTime, job.Progress})
+	if err != nil {
+		return err
+	}
+	return s3a.putObjectBytes(job.Report.Bucket, reportPrefix+"report.json", "application/json", summary)
+}
@@ -0,0 +1,166 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type CreateBatchJobResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CreateBatchJobResult"`
+	JobId   string   `xml:"JobId"`
+}
+
+type ListBatchJobsResult struct {
+	XMLName xml.Name    `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBatchJobsResult"`
+	Jobs    []*BatchJob `xml:"BatchJob"`
+}
+
+// CreateBatchJobHandler Create a batch job on the objects of the bucket, run in the background
+func (s3a *S3ApiServer) CreateBatchJobHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("CreateBatchJobHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var job BatchJob
+	defer util.CloseRequest(r)
+
+	if err := xml.NewDecoder(r.Body).Decode(&job); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if errCode := s3a.validateBatchJob(bucket, &job); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.authorizeBatchJob(r, bucket, &job); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	job.JobId = uuid.New().String()
+	job.Bucket = bucket
+	job.AccountId = getAccountId(r)
+	job.Status = batchJobStatusNew
+	job.CreationTime = time.Now().UTC()
+	job.FailureReason, job.TerminationTime, job.Progress, job.Failures, job.Position = "", nil, BatchJobProgress{}, nil, ""
+	if err := s3a.saveBatchJob(&job); err != nil {
+		glog.Errorf("save batch job on bucket %s: %v", bucket, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	writeSuccessResponseXML(w, r, &CreateBatchJobResult{JobId: job.JobId})
+}
+
+// DescribeBatchJobHandler Describe a batch job, with its progress and first failures
+func (s3a *S3ApiServer) DescribeBatchJobHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	jobId := r.URL.Query().Get("id")
+	glog.V(3).Infof("DescribeBatchJobHandler %s %s", bucket, jobId)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	job, errCode := s3a.getBucketBatchJob(bucket, jobId)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseXML(w, r, job)
+}
+
+// ListBatchJobsHandler List the batch jobs of the bucket, by creation time
+func (s3a *S3ApiServer) ListBatchJobsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("ListBatchJobsHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	jobs, err := s3a.listBatchJobs(bucket)
+	if err != nil {
+		glog.Errorf("list batch jobs of bucket %s: %v", bucket, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	// the failures are only returned when describing a job
+	for _, job := range jobs {
+		job.Failures = nil
+	}
+
+	writeSuccessResponseXML(w, r, &ListBatchJobsResult{Jobs: jobs})
+}
+
+// CancelBatchJobHandler Cancel a batch job, the running job stops at its next progress update
+func (s3a *S3ApiServer) CancelBatchJobHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	jobId := r.URL.Query().Get("id")
+	glog.V(3).Infof("CancelBatchJobHandler %s %s", bucket, jobId)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	job, errCode := s3a.getBucketBatchJob(bucket, jobId)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if job.Status != batchJobStatusNew && job.Status != batchJobStatusActive {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+	job.Status = batchJobStatusCancelled
+	if job.TerminationTime == nil {
+		now := time.Now().UTC()
+		job.TerminationTime = &now
+	}
+	if err := s3a.saveBatchJob(job); err != nil {
+		glog.Errorf("cancel batch job %s: %v", jobId, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	s3err.WriteEmptyResponse(w, r, http.StatusNoContent)
+}
+
+func (s3a *S3ApiServer) getBucketBatchJob(bucket, jobId string) (*BatchJob, s3err.ErrorCode) {
+	if _, err := uuid.Parse(jobId); err != nil {
+		return nil, s3err.ErrNoSuchBatchJob
+	}
+	job, err := s3a.loadBatchJob(jobId)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			return nil, s3err.ErrNoSuchBatchJob
+		}
+		glog.Errorf("load batch job %s: %v", jobId, err)
+		return nil, s3err.ErrInternalError
+	}
+	if job.Bucket != bucket {
+		return nil, s3err.ErrNoSuchBatchJob
+	}
+	return job, s3err.ErrNone
+}
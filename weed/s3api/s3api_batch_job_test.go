@@ -0,0 +1,131 @@
+package s3api
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchJobXML(t *testing.T) {
+	body := `<BatchJob>
+   <Prefix>logs/2023/</Prefix>
+   <Operation><Delete/></Operation>
+   <Report><Bucket>reports</Bucket><Prefix>batch</Prefix></Report>
+</BatchJob>`
+	var job BatchJob
+	assert.NoError(t, xml.Unmarshal([]byte(body), &job))
+	assert.Equal(t, "logs/2023/", job.Prefix)
+	assert.NotNil(t, job.Operation.Delete)
+	assert.Nil(t, job.Operation.Copy)
+	assert.Equal(t, 1, job.Operation.count())
+	assert.Equal(t, "reports", job.Report.Bucket)
+
+	body = `<BatchJob>
+   <Manifest><Bucket>bucket</Bucket><Key>manifest.csv</Key></Manifest>
+   <Operation><PutTagging><TagSet><Tag><Key>team</Key><Value>data</Value></Tag></TagSet></PutTagging></Operation>
+</BatchJob>`
+	job = BatchJob{}
+	assert.NoError(t, xml.Unmarshal([]byte(body), &job))
+	assert.Equal(t, "manifest.csv", job.Manifest.Key)
+	assert.Equal(t, map[string]string{"team": "data"}, (&Tagging{TagSet: job.Operation.PutTagging.TagSet}).ToTags())
+
+	// the resume position and the account are internal
+	job.Position, job.AccountId = "12", "account"
+	data, err := xml.Marshal(&job)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "account")
+	assert.NotContains(t, string(data), "12")
+}
+
+func TestValidateBatchJob(t *testing.T) {
+	s3a := &S3ApiServer{}
+	tests := []struct {
+		name     string
+		job      BatchJob
+		expected s3err.ErrorCode
+	}{
+		{"delete", BatchJob{Prefix: "a/", Operation: BatchJobOperation{Delete: &BatchJobDelete{}}}, s3err.ErrNone},
+		{"tagging", BatchJob{Operation: BatchJobOperation{PutTagging: &BatchJobPutTagging{TagSet: TagSet{Tag: []Tag{{Key: "team", Value: "data"}}}}}}, s3err.ErrNone},
+		{"no operation", BatchJob{}, s3err.ErrMalformedXML},
+		{"two operations", BatchJob{Operation: BatchJobOperation{Delete: &BatchJobDelete{}, SetStorageClass: &BatchJobSetStorageClass{StorageClass: "COLD"}}}, s3err.ErrMalformedXML},
+		{"prefix and manifest", BatchJob{Prefix: "a/", Manifest: &BatchJobManifest{Bucket: "bucket", Key: "manifest.csv"}, Operation: BatchJobOperation{Delete: &BatchJobDelete{}}}, s3err.ErrInvalidRequest},
+		{"copy onto itself", BatchJob{Operation: BatchJobOperation{Copy: &BatchJobCopy{TargetBucket: "bucket"}}}, s3err.ErrInvalidRequest},
+		{"copy into the prefix", BatchJob{Prefix: "a/", Operation: BatchJobOperation{Copy: &BatchJobCopy{TargetBucket: "bucket", TargetPrefix: "a/copies/"}}}, s3err.ErrInvalidRequest},
+		{"invalid tag", BatchJob{Operation: BatchJobOperation{PutTagging: &BatchJobPutTagging{TagSet: TagSet{Tag: []Tag{{Key: "a<b"}}}}}}, s3err.ErrInvalidTag},
+		{"missing storage class", BatchJob{Operation: BatchJobOperation{SetStorageClass: &BatchJobSetStorageClass{}}}, s3err.ErrMalformedXML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, s3a.validateBatchJob("bucket", &tt.job))
+		})
+	}
+}
+
+func TestAuthorizeBatchJob(t *testing.T) {
+	policy, err := parseBucketPolicy([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": {"AWS": "alice"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::target/*"},
+    {"Effect": "Deny", "Principal": "*", "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::bucket/audit/*"}
+  ]
+}`))
+	assert.NoError(t, err)
+	s3a := &S3ApiServer{iam: &IdentityAccessManagement{isAuthEnabled: true, bucketPolicies: testBucketPolicies{"bucket": policy, "target": policy}}}
+	alice := &Identity{Name: "alice", Account: &Account{Id: "alice"}, Actions: []Action{s3_constants.ACTION_READ + ":bucket", s3_constants.ACTION_WRITE + ":bucket"}}
+	request := func(identity *Identity) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/bucket?batch-job", nil)
+		return r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity))
+	}
+	tests := []struct {
+		name     string
+		job      BatchJob
+		expected s3err.ErrorCode
+	}{
+		{"delete", BatchJob{Prefix: "logs/", Operation: BatchJobOperation{Delete: &BatchJobDelete{}}}, s3err.ErrNone},
+		{"delete denied by the bucket policy", BatchJob{Prefix: "audit/", Operation: BatchJobOperation{Delete: &BatchJobDelete{}}}, s3err.ErrAccessDenied},
+		{"tagging needs the tagging permission", BatchJob{Operation: BatchJobOperation{PutTagging: &BatchJobPutTagging{}}}, s3err.ErrAccessDenied},
+		{"copy granted by the target bucket policy", BatchJob{Operation: BatchJobOperation{Copy: &BatchJobCopy{TargetBucket: "target"}}}, s3err.ErrNone},
+		{"copy to a bucket not writable", BatchJob{Operation: BatchJobOperation{Copy: &BatchJobCopy{TargetBucket: "other"}}}, s3err.ErrAccessDenied},
+		{"manifest not readable", BatchJob{Manifest: &BatchJobManifest{Bucket: "other", Key: "manifest.csv"}, Operation: BatchJobOperation{Delete: &BatchJobDelete{}}}, s3err.ErrAccessDenied},
+		{"report not writable", BatchJob{Operation: BatchJobOperation{Delete: &BatchJobDelete{}}, Report: &BatchJobReport{Bucket: "other"}}, s3err.ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, s3a.authorizeBatchJob(request(alice), "bucket", &tt.job))
+		})
+	}
+}
+
+func TestParseBatchJobManifestRecord(t *testing.T) {
+	bucket, key, versionId, err := parseBatchJobManifestRecord([]string{"bucket", "dir%2Fa+b.txt"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "dir/a b.txt", key)
+	assert.Empty(t, versionId)
+
+	_, key, versionId, err = parseBatchJobManifestRecord([]string{"bucket", "a.txt", "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a.txt", key)
+	assert.Equal(t, "v1", versionId)
+
+	_, _, _, err = parseBatchJobManifestRecord([]string{"bucket"})
+	assert.Error(t, err)
+	_, _, _, err = parseBatchJobManifestRecord([]string{"bucket", "a%zz"})
+	assert.Error(t, err)
+}
+
+func TestBatchJobRecordFailure(t *testing.T) {
+	job := &BatchJob{JobId: "job", Bucket: "bucket"}
+	for i := 0; i < maxBatchJobFailures+10; i++ {
+		job.recordFailure("a", "", errors.New("failed"))
+	}
+	assert.Equal(t, int64(maxBatchJobFailures+10), job.Progress.NumberOfTasksFailed)
+	assert.Len(t, job.Failures, maxBatchJobFailures)
+}
@@ -0,0 +1,53 @@
+package s3api
+
+import (
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+// updateBucketEntry applies the update to the extended attributes of the bucket entry, and saves the entry
+// unless nothing is changed. The entry is read and written under the lock, so the concurrent updates of the
+// different configurations of a bucket do not overwrite each other, and the bucket registry is refreshed
+// without waiting for the metadata subscription.
+func (s3a *S3ApiServer) updateBucketEntry(bucket string, update func(extended map[string][]byte) (changed bool, errCode s3err.ErrorCode)) s3err.ErrorCode {
+	s3a.bucketEntryLock.Lock()
+	defer s3a.bucketEntryLock.Unlock()
+
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			return s3err.ErrNoSuchBucket
+		}
+		glog.Errorf("get bucket %s: %v", bucket, err)
+		return s3err.ErrInternalError
+	}
+	if bucketEntry.Extended == nil {
+		bucketEntry.Extended = make(map[string][]byte)
+	}
+	changed, errCode := update(bucketEntry.Extended)
+	if errCode != s3err.ErrNone || !changed {
+		return errCode
+	}
+	if err = s3a.updateEntry(s3a.option.BucketsPath, bucketEntry); err != nil {
+		glog.Errorf("update bucket %s: %v", bucket, err)
+		return s3err.ErrInternalError
+	}
+	s3a.bucketRegistry.LoadBucketMetadata(bucketEntry)
+	return s3err.ErrNone
+}
+
+// updateBucketConfig stores the configuration under the key of the bucket entry, or removes it if config is empty
+func (s3a *S3ApiServer) updateBucketConfig(bucket, key string, config []byte) s3err.ErrorCode {
+	return s3a.updateBucketEntry(bucket, func(extended map[string][]byte) (bool, s3err.ErrorCode) {
+		if len(config) == 0 {
+			if _, found := extended[key]; !found {
+				return false, s3err.ErrNone
+			}
+			delete(extended, key)
+			return true, s3err.ErrNone
+		}
+		extended[key] = config
+		return true, s3err.ErrNone
+	})
+}
@@ -0,0 +1,265 @@
+package s3api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+const (
+	maxCorsRules = 100
+
+	corsAllowOrigin      = "Access-Control-Allow-Origin"
+	corsAllowMethods     = "Access-Control-Allow-Methods"
+	corsAllowHeaders     = "Access-Control-Allow-Headers"
+	corsExposeHeaders    = "Access-Control-Expose-Headers"
+	corsMaxAge           = "Access-Control-Max-Age"
+	corsAllowCredentials = "Access-Control-Allow-Credentials"
+	corsRequestMethod    = "Access-Control-Request-Method"
+	corsRequestHeaders   = "Access-Control-Request-Headers"
+)
+
+var corsAllowedMethods = map[string]struct{}{
+	http.MethodGet:    {},
+	http.MethodPut:    {},
+	http.MethodHead:   {},
+	http.MethodPost:   {},
+	http.MethodDelete: {},
+}
+
+// validateCorsConfiguration checks the rules the same way AWS S3 does when putting a CORS configuration
+func validateCorsConfiguration(config *s3.CORSConfiguration) s3err.ErrorCode {
+	if len(config.CORSRules) == 0 || len(config.CORSRules) > maxCorsRules {
+		return s3err.ErrMalformedXML
+	}
+	for _, rule := range config.CORSRules {
+		if rule == nil || len(rule.AllowedMethods) == 0 || len(rule.AllowedOrigins) == 0 {
+			return s3err.ErrMalformedXML
+		}
+		for _, method := range rule.AllowedMethods {
+			if _, ok := corsAllowedMethods[aws.StringValue(method)]; !ok {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(aws.StringValue(origin), "*") > 1 {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(aws.StringValue(header), "*") > 1 {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		if rule.MaxAgeSeconds != nil && *rule.MaxAgeSeconds < 0 {
+			return s3err.ErrInvalidRequest
+		}
+	}
+	return s3err.ErrNone
+}
+
+// findCorsRule returns the first rule allowing the origin, method and all request headers
+func findCorsRule(config *s3.CORSConfiguration, origin, method string, requestHeaders []string) *s3.CORSRule {
+	for _, rule := range config.CORSRules {
+		if corsRuleMatches(rule, origin, method, requestHeaders) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func corsRuleMatches(rule *s3.CORSRule, origin, method string, requestHeaders []string) bool {
+	originAllowed := false
+	for _, allowedOrigin := range rule.AllowedOrigins {
+		if matchCorsWildcard(aws.StringValue(allowedOrigin), origin) {
+			originAllowed = true
+			break
+		}
+	}
+	if !originAllowed {
+		return false
+	}
+
+	methodAllowed := false
+	for _, allowedMethod := range rule.AllowedMethods {
+		if aws.StringValue(allowedMethod) == method {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		return false
+	}
+
+	for _, requestHeader := range requestHeaders {
+		headerAllowed := false
+		for _, allowedHeader := range rule.AllowedHeaders {
+			if matchCorsWildcard(strings.ToLower(aws.StringValue(allowedHeader)), strings.ToLower(requestHeader)) {
+				headerAllowed = true
+				break
+			}
+		}
+		if !headerAllowed {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCorsWildcard matches value against a pattern with at most one '*' wildcard
+func matchCorsWildcard(pattern, value string) bool {
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	if !found {
+		return pattern == value
+	}
+	return len(value) >= len(prefix)+len(suffix) && strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix)
+}
+
+func parseCorsRequestHeaders(value string) (headers []string) {
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return
+}
+
+// corsResponseHeaders builds the headers a matching rule adds to the response
+func corsResponseHeaders(rule *s3.CORSRule, origin string, requestHeaders []string, isPreflight bool) http.Header {
+	header := make(http.Header)
+	wildcardOrigin := false
+	for _, allowedOrigin := range rule.AllowedOrigins {
+		if aws.StringValue(allowedOrigin) == "*" {
+			wildcardOrigin = true
+			break
+		}
+	}
+	if wildcardOrigin {
+		header.Set(corsAllowOrigin, "*")
+	} else {
+		header.Set(corsAllowOrigin, origin)
+		header.Set(corsAllowCredentials, "true")
+	}
+	header.Set("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+	header.Set(corsAllowMethods, strings.Join(aws.StringValueSlice(rule.AllowedMethods), ", "))
+	if isPreflight && len(requestHeaders) > 0 {
+		header.Set(corsAllowHeaders, strings.Join(requestHeaders, ", "))
+	}
+	if len(rule.ExposeHeaders) > 0 {
+		header.Set(corsExposeHeaders, strings.Join(aws.StringValueSlice(rule.ExposeHeaders), ", "))
+	}
+	if rule.MaxAgeSeconds != nil {
+		header.Set(corsMaxAge, strconv.FormatInt(*rule.MaxAgeSeconds, 10))
+	}
+	return header
+}
+
+// getBucketCors returns the cached CORS configuration of the bucket, nil if there is none
+func (s3a *S3ApiServer) getBucketCors(bucket string) *s3.CORSConfiguration {
+	if bucket == "" {
+		return nil
+	}
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return nil
+	}
+	return bucketMetadata.Cors
+}
+
+// corsMiddleware decorates responses of cross-origin requests according to the bucket CORS configuration.
+// Buckets without a CORS configuration keep the globally configured behavior.
+func (s3a *S3ApiServer) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bucket, _ := s3_constants.GetBucketAndObject(r)
+		config := s3a.getBucketCors(bucket)
+		if config == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var corsHeaders http.Header
+		if rule := findCorsRule(config, origin, r.Method, nil); rule != nil {
+			corsHeaders = corsResponseHeaders(rule, origin, nil, false)
+		}
+		next.ServeHTTP(&corsResponseWriter{ResponseWriter: w, corsHeaders: corsHeaders}, r)
+	})
+}
+
+// corsResponseWriter replaces any CORS headers set while handling the request,
+// e.g. by the filer or the global defaults, with the ones from the bucket configuration
+type corsResponseWriter struct {
+	http.ResponseWriter
+	corsHeaders http.Header
+	wroteHeader bool
+}
+
+func (cw *corsResponseWriter) applyCorsHeaders() {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	header := cw.ResponseWriter.Header()
+	for _, key := range []string{corsAllowOrigin, corsAllowMethods, corsAllowHeaders, corsExposeHeaders, corsMaxAge, corsAllowCredentials} {
+		header.Del(key)
+	}
+	for key, values := range cw.corsHeaders {
+		header[key] = values
+	}
+}
+
+func (cw *corsResponseWriter) WriteHeader(statusCode int) {
+	cw.applyCorsHeaders()
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *corsResponseWriter) Write(p []byte) (int, error) {
+	cw.applyCorsHeaders()
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *corsResponseWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeDefaultOptionsResponse answers preflight requests from the globally allowed origins
+func (s3a *S3ApiServer) writeDefaultOptionsResponse(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin != "" {
+		if s3a.option.AllowedOrigins == nil || len(s3a.option.AllowedOrigins) == 0 || s3a.option.AllowedOrigins[0] == "*" {
+			origin = "*"
+		} else {
+			originFound := false
+			for _, allowedOrigin := range s3a.option.AllowedOrigins {
+				if origin == allowedOrigin {
+					originFound = true
+				}
+			}
+			if !originFound {
+				writeFailureResponse(w, r, http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	w.Header().Set(corsAllowOrigin, origin)
+	w.Header().Set(corsExposeHeaders, "*")
+	w.Header().Set(corsAllowMethods, "*")
+	w.Header().Set(corsAllowHeaders, "*")
+	writeSuccessResponseEmpty(w, r)
+}
+
+func corsRuleString(rule *s3.CORSRule) string {
+	return fmt.Sprintf("origins=%v methods=%v", aws.StringValueSlice(rule.AllowedOrigins), aws.StringValueSlice(rule.AllowedMethods))
+}
@@ -0,0 +1,147 @@
+package s3api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// GetBucketCorsHandler Get bucket CORS
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
+func (s3a *S3ApiServer) GetBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetBucketCorsHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchBucket)
+			return
+		}
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	corsBytes, found := bucketEntry.Extended[s3_constants.ExtCorsKey]
+	if !found || len(corsBytes) == 0 {
+		s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchCORSConfiguration)
+		return
+	}
+	var corsConfiguration s3.CORSConfiguration
+	if err = json.Unmarshal(corsBytes, &corsConfiguration); err != nil {
+		glog.Errorf("unmarshal bucket %s cors: %v", bucket, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutBucketCorsInput{
+		CORSConfiguration: &corsConfiguration,
+	})
+}
+
+// PutBucketCorsHandler Put bucket CORS
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+func (s3a *S3ApiServer) PutBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutBucketCorsHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var corsConfiguration s3.CORSConfiguration
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&corsConfiguration, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if errCode := validateCorsConfiguration(&corsConfiguration); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	corsBytes, err := json.Marshal(&corsConfiguration)
+	if err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	if errCode := s3a.updateBucketConfig(bucket, s3_constants.ExtCorsKey, corsBytes); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
+
+// DeleteBucketCorsHandler Delete bucket CORS
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
+func (s3a *S3ApiServer) DeleteBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("DeleteBucketCorsHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if errCode := s3a.updateBucketConfig(bucket, s3_constants.ExtCorsKey, nil); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	s3err.WriteEmptyResponse(w, r, http.StatusNoContent)
+}
+
+// BucketCorsPreflightHandler answers the OPTIONS preflight request of browsers
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTOPTIONSobject.html
+func (s3a *S3ApiServer) BucketCorsPreflightHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("BucketCorsPreflightHandler %s%s", bucket, object)
+
+	config := s3a.getBucketCors(bucket)
+	if config == nil {
+		s3a.writeDefaultOptionsResponse(w, r)
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get(corsRequestMethod)
+	if origin == "" || method == "" {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+	requestHeaders := parseCorsRequestHeaders(r.Header.Get(corsRequestHeaders))
+
+	rule := findCorsRule(config, origin, method, requestHeaders)
+	if rule == nil {
+		glog.V(3).Infof("bucket %s cors: no rule allows %s %s %v", bucket, origin, method, requestHeaders)
+		s3err.WriteErrorResponse(w, r, s3err.ErrCORSForbidden)
+		return
+	}
+	glog.V(4).Infof("bucket %s cors: %s %s allowed by %s", bucket, origin, method, corsRuleString(rule))
+
+	for key, values := range corsResponseHeaders(rule, origin, requestHeaders, true) {
+		w.Header()[key] = values
+	}
+	writeSuccessResponseEmpty(w, r)
+}
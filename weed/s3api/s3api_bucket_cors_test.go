@@ -0,0 +1,100 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCorsConfiguration = &s3.CORSConfiguration{
+	CORSRules: []*s3.CORSRule{
+		{
+			AllowedOrigins: aws.StringSlice([]string{"https://*.example.com"}),
+			AllowedMethods: aws.StringSlice([]string{"PUT", "POST"}),
+			AllowedHeaders: aws.StringSlice([]string{"Content-*", "x-amz-*"}),
+			ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+			MaxAgeSeconds:  aws.Int64(3000),
+		},
+		{
+			AllowedOrigins: aws.StringSlice([]string{"*"}),
+			AllowedMethods: aws.StringSlice([]string{"GET"}),
+		},
+	},
+}
+
+func TestFindCorsRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		origin   string
+		method   string
+		headers  []string
+		expected *s3.CORSRule
+	}{
+		{"wildcard sub domain", "https://app.example.com", "PUT", []string{"content-type", "X-Amz-Date"}, testCorsConfiguration.CORSRules[0]},
+		{"header not allowed", "https://app.example.com", "PUT", []string{"authorization"}, nil},
+		{"scheme mismatch", "http://app.example.com", "PUT", nil, nil},
+		{"any origin get", "https://other.org", "GET", nil, testCorsConfiguration.CORSRules[1]},
+		{"method not allowed", "https://other.org", "DELETE", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, findCorsRule(testCorsConfiguration, tt.origin, tt.method, tt.headers))
+		})
+	}
+}
+
+func TestCorsResponseHeaders(t *testing.T) {
+	header := corsResponseHeaders(testCorsConfiguration.CORSRules[0], "https://app.example.com", []string{"content-type"}, true)
+	assert.Equal(t, "https://app.example.com", header.Get(corsAllowOrigin))
+	assert.Equal(t, "true", header.Get(corsAllowCredentials))
+	assert.Equal(t, "PUT, POST", header.Get(corsAllowMethods))
+	assert.Equal(t, "content-type", header.Get(corsAllowHeaders))
+	assert.Equal(t, "ETag", header.Get(corsExposeHeaders))
+	assert.Equal(t, "3000", header.Get(corsMaxAge))
+
+	header = corsResponseHeaders(testCorsConfiguration.CORSRules[1], "https://other.org", nil, false)
+	assert.Equal(t, "*", header.Get(corsAllowOrigin))
+	assert.Equal(t, "", header.Get(corsAllowCredentials))
+	assert.Equal(t, "", header.Get(corsMaxAge))
+}
+
+func TestValidateCorsConfiguration(t *testing.T) {
+	assert.Equal(t, s3err.ErrNone, validateCorsConfiguration(testCorsConfiguration))
+	assert.Equal(t, s3err.ErrMalformedXML, validateCorsConfiguration(&s3.CORSConfiguration{}))
+	assert.Equal(t, s3err.ErrInvalidRequest, validateCorsConfiguration(&s3.CORSConfiguration{
+		CORSRules: []*s3.CORSRule{{
+			AllowedOrigins: aws.StringSlice([]string{"*"}),
+			AllowedMethods: aws.StringSlice([]string{"PATCH"}),
+		}},
+	}))
+	assert.Equal(t, s3err.ErrInvalidRequest, validateCorsConfiguration(&s3.CORSConfiguration{
+		CORSRules: []*s3.CORSRule{{
+			AllowedOrigins: aws.StringSlice([]string{"https://*.*.example.com"}),
+			AllowedMethods: aws.StringSlice([]string{"GET"}),
+		}},
+	}))
+}
+
+func TestCorsResponseWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	corsHeaders := corsResponseHeaders(testCorsConfiguration.CORSRules[1], "https://other.org", nil, false)
+	w := &corsResponseWriter{ResponseWriter: recorder, corsHeaders: corsHeaders}
+	w.Header().Set(corsAllowOrigin, "https://filer.example.com")
+	w.Header().Set(corsAllowHeaders, "*")
+	w.WriteHeader(http.StatusOK)
+	assert.Equal(t, "*", recorder.Header().Get(corsAllowOrigin))
+	assert.Equal(t, "", recorder.Header().Get(corsAllowHeaders))
+	assert.Equal(t, "GET", recorder.Header().Get(corsAllowMethods))
+
+	// without a matching rule, no CORS headers are exposed at all
+	recorder = httptest.NewRecorder()
+	w = &corsResponseWriter{ResponseWriter: recorder}
+	w.Header().Set(corsAllowOrigin, "*")
+	_, _ = w.Write([]byte("data"))
+	assert.Equal(t, "", recorder.Header().Get(corsAllowOrigin))
+}
@@ -2,6 +2,7 @@ package s3api
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
@@ -59,7 +61,7 @@ func (s3a *S3ApiServer) ListBucketsHandler(w http.ResponseWriter, r *http.Reques
 	var buckets []*s3.Bucket
 	for _, entry := range entries {
 		if entry.IsDirectory {
-			if identity != nil && !identity.canDo(s3_constants.ACTION_LIST, entry.Name, "") {
+			if identity != nil && !identity.isBucketListed(r, entry.Name) {
 				continue
 			}
 			buckets = append(buckets, &s3.Bucket{
@@ -123,6 +125,7 @@ func (s3a *S3ApiServer) PutBucketHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	objectLockEnabled := strings.EqualFold(r.Header.Get(s3_constants.AmzBucketObjectLockEnabled), "true")
 	fn := func(entry *filer_pb.Entry) {
 		if identityId := r.Header.Get(s3_constants.AmzIdentityId); identityId != "" {
 			if entry.Extended == nil {
@@ -130,6 +133,14 @@ func (s3a *S3ApiServer) PutBucketHandler(w http.ResponseWriter, r *http.Request)
 			}
 			entry.Extended[s3_constants.AmzIdentityId] = []byte(identityId)
 		}
+		if objectLockEnabled {
+			if entry.Extended == nil {
+				entry.Extended = make(map[string][]byte)
+			}
+			// object lock requires versioning, which can not be suspended later
+			entry.Extended[s3_constants.ExtVersioningKey] = []byte(s3.BucketVersioningStatusEnabled)
+			entry.Extended[s3_constants.ExtObjectLockConfigKey] = []byte(`{"ObjectLockEnabled":"Enabled"}`)
+		}
 	}
 
 	// create the folder for bucket, but lazily create actual collection
@@ -227,6 +238,9 @@ func (s3a *S3ApiServer) hasAccess(r *http.Request, entry *filer_pb.Entry) bool {
 	if isAdmin {
 		return true
 	}
+	if r.Header.Get(s3_constants.AmzPolicyAllowed) != "" || r.Header.Get(s3_constants.AmzAclAllowed) != "" {
+		return true
+	}
 	if entry.Extended == nil {
 		return true
 	}
@@ -314,6 +328,30 @@ func (s3a *S3ApiServer) GetBucketLifecycleConfigurationHandler(w http.ResponseWr
 		s3err.WriteErrorResponse(w, r, err)
 		return
 	}
+
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchBucket)
+			return
+		}
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	if lifecycleBytes, found := bucketEntry.Extended[s3_constants.ExtLifecycleKey]; found && len(lifecycleBytes) > 0 {
+		var lifecycleConfiguration s3.BucketLifecycleConfiguration
+		if err = json.Unmarshal(lifecycleBytes, &lifecycleConfiguration); err != nil {
+			glog.Errorf("unmarshal bucket %s lifecycle: %v", bucket, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+		s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutBucketLifecycleConfigurationInput{
+			LifecycleConfiguration: &lifecycleConfiguration,
+		})
+		return
+	}
+
+	// fall back to the TTLs configured for the bucket collection
 	fc, err := filer.ReadFilerConf(s3a.option.Filer, s3a.option.GrpcDialOption, nil)
 	if err != nil {
 		glog.Errorf("GetBucketLifecycleConfigurationHandler: %s", err)
@@ -346,17 +384,61 @@ func (s3a *S3ApiServer) GetBucketLifecycleConfigurationHandler(w http.ResponseWr
 // PutBucketLifecycleConfigurationHandler Put Bucket Lifecycle configuration
 // https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
 func (s3a *S3ApiServer) PutBucketLifecycleConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutBucketLifecycleConfigurationHandler %s", bucket)
 
-	s3err.WriteErrorResponse(w, r, s3err.ErrNotImplemented)
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var lifecycleConfiguration s3.BucketLifecycleConfiguration
+	defer util.CloseRequest(r)
 
+	if err := xmlutil.UnmarshalXML(&lifecycleConfiguration, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if errCode := validateLifecycleConfiguration(&lifecycleConfiguration); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	lifecycleBytes, err := json.Marshal(&lifecycleConfiguration)
+	if err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	if errCode := s3a.updateBucketConfig(bucket, s3_constants.ExtLifecycleKey, lifecycleBytes); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
 }
 
 // DeleteBucketLifecycleHandler Delete Bucket Lifecycle
 // https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
 func (s3a *S3ApiServer) DeleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("DeleteBucketLifecycleHandler %s", bucket)
 
-	s3err.WriteEmptyResponse(w, r, http.StatusNoContent)
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if errCode := s3a.updateBucketConfig(bucket, s3_constants.ExtLifecycleKey, nil); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
 
+	s3err.WriteEmptyResponse(w, r, http.StatusNoContent)
 }
 
 // GetBucketLocationHandler Get bucket location
@@ -538,9 +620,60 @@ func (s3a *S3ApiServer) GetBucketVersioningHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
+	versioningConfiguration := &s3.VersioningConfiguration{}
+	if versioning := s3a.getVersioningState(bucket); versioning != "" {
+		versioningConfiguration.Status = aws.String(versioning)
+	}
+
 	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutBucketVersioningInput{
-		VersioningConfiguration: &s3.VersioningConfiguration{
-			Status: aws.String(s3.BucketVersioningStatusSuspended),
-		},
+		VersioningConfiguration: versioningConfiguration,
 	})
 }
+
+// PutBucketVersioningHandler Put bucket Versioning
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+func (s3a *S3ApiServer) PutBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutBucketVersioning %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var versioningConfiguration s3.VersioningConfiguration
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&versioningConfiguration, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+
+	status := aws.StringValue(versioningConfiguration.Status)
+	if status != s3.BucketVersioningStatusEnabled && status != s3.BucketVersioningStatusSuspended {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+
+	errCode := s3a.updateBucketEntry(bucket, func(extended map[string][]byte) (bool, s3err.ErrorCode) {
+		// versioning cannot be suspended once object lock or replication is enabled
+		_, hasObjectLock := extended[s3_constants.ExtObjectLockConfigKey]
+		_, hasReplication := extended[s3_constants.ExtReplicationKey]
+		if (hasObjectLock || hasReplication) && status != s3.BucketVersioningStatusEnabled {
+			return false, s3err.ErrInvalidBucketState
+		}
+		extended[s3_constants.ExtVersioningKey] = []byte(status)
+		return true, s3err.ErrNone
+	})
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
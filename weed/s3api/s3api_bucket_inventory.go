@@ -0,0 +1,439 @@
+package s3api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Inventory reports list the objects of a bucket daily or weekly, as gzipped CSV files and
+// an AWS style manifest.json written into the destination bucket:
+//
+//	<prefix>/<source bucket>/<configuration id>/data/<uuid>.csv.gz
+//	<prefix>/<source bucket>/<configuration id>/<YYYY-MM-DDTHH-MMZ>/manifest.json
+//	<prefix>/<source bucket>/<configuration id>/<YYYY-MM-DDTHH-MMZ>/manifest.checksum
+//
+// The objects are walked directly in the filer, instead of through ListObjects.
+// Besides the AWS optional fields, the SeaweedFS specific "Tags" field lists the object tags.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-inventory.html
+
+const (
+	inventoryLockName           = "s3.inventory"
+	inventoryScanInterval       = time.Hour
+	inventoryWorkerKeyPrefix    = "s3.inventory."
+	inventoryRowsPerFile        = 1000000
+	inventoryManifestVersion    = "2016-11-30"
+	inventoryManifestTimeFormat = "2006-01-02T15-04Z"
+	maxInventoryConfigurations  = 1000
+	inventoryOptionalFieldTags  = "Tags"
+)
+
+// inventoryOptionalFields are the supported optional fields, with how to read them from the object entry
+var inventoryOptionalFields = map[string]func(entry *filer_pb.Entry) string{
+	s3.InventoryOptionalFieldSize: func(entry *filer_pb.Entry) string {
+		return strconv.FormatUint(filer.FileSize(entry), 10)
+	},
+	s3.InventoryOptionalFieldLastModifiedDate: func(entry *filer_pb.Entry) string {
+		return time.Unix(entry.Attributes.GetMtime(), 0).UTC().Format(time.RFC3339)
+	},
+	s3.InventoryOptionalFieldEtag: func(entry *filer_pb.Entry) string {
+		return filer.ETag(entry)
+	},
+	s3.InventoryOptionalFieldStorageClass: func(entry *filer_pb.Entry) string {
+		if storageClass := string(entry.Extended[s3_constants.AmzStorageClass]); storageClass != "" {
+			return storageClass
+		}
+		return s3.StorageClassStandard
+	},
+	s3.InventoryOptionalFieldIsMultipartUploaded: func(entry *filer_pb.Entry) string {
+		return strconv.FormatBool(strings.Contains(filer.ETag(entry), "-"))
+	},
+	s3.InventoryOptionalFieldReplicationStatus: func(entry *filer_pb.Entry) string {
+		return string(entry.Extended[s3_constants.AmzReplicationStatus])
+	},
+	s3.InventoryOptionalFieldEncryptionStatus: func(entry *filer_pb.Entry) string {
+		if sseType := string(entry.Extended[s3_constants.ExtSseTypeKey]); sseType != "" {
+			return sseType
+		}
+		return "NOT-SSE"
+	},
+	s3.InventoryOptionalFieldObjectLockMode: func(entry *filer_pb.Entry) string {
+		mode, _ := filer.ObjectLockRetention(entry.Extended)
+		return mode
+	},
+	s3.InventoryOptionalFieldObjectLockRetainUntilDate: func(entry *filer_pb.Entry) string {
+		if mode, retainUntil := filer.ObjectLockRetention(entry.Extended); mode != "" {
+			return retainUntil.UTC().Format(time.RFC3339)
+		}
+		return ""
+	},
+	s3.InventoryOptionalFieldObjectLockLegalHoldStatus: func(entry *filer_pb.Entry) string {
+		if filer.IsObjectLegalHold(entry.Extended) {
+			return s3.ObjectLockLegalHoldStatusOn
+		}
+		return s3.ObjectLockLegalHoldStatusOff
+	},
+	inventoryOptionalFieldTags: func(entry *filer_pb.Entry) string {
+		tags := url.Values{}
+		for k, v := range entry.Extended {
+			if strings.HasPrefix(k, s3_constants.AmzObjectTaggingPrefix) {
+				tags.Set(k[len(s3_constants.AmzObjectTaggingPrefix):], string(v))
+			}
+		}
+		return tags.Encode()
+	},
+}
+
+// validateInventoryConfiguration checks the configuration, and that the destination bucket has the same owner as the bucket
+func (s3a *S3ApiServer) validateInventoryConfiguration(bucket, id string, config *s3.InventoryConfiguration) s3err.ErrorCode {
+	if aws.StringValue(config.Id) != id || config.IsEnabled == nil || config.Schedule == nil {
+		return s3err.ErrMalformedXML
+	}
+	if config.Destination == nil || config.Destination.S3BucketDestination == nil {
+		return s3err.ErrMalformedXML
+	}
+	destination := config.Destination.S3BucketDestination
+	switch aws.StringValue(destination.Format) {
+	case s3.InventoryFormatCsv:
+	case s3.InventoryFormatParquet, s3.InventoryFormatOrc:
+		return s3err.ErrNotImplemented
+	default:
+		return s3err.ErrInvalidRequest
+	}
+	if destination.Encryption != nil {
+		return s3err.ErrNotImplemented
+	}
+	switch aws.StringValue(config.Schedule.Frequency) {
+	case s3.InventoryFrequencyDaily, s3.InventoryFrequencyWeekly:
+	default:
+		return s3err.ErrInvalidRequest
+	}
+	switch aws.StringValue(config.IncludedObjectVersions) {
+	case s3.InventoryIncludedObjectVersionsAll, s3.InventoryIncludedObjectVersionsCurrent:
+	default:
+		return s3err.ErrInvalidRequest
+	}
+	for _, field := range config.OptionalFields {
+		if _, found := inventoryOptionalFields[aws.StringValue(field)]; !found {
+			return s3err.ErrInvalidRequest
+		}
+	}
+
+	sourceMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	destinationMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(inventoryDestinationBucket(destination))
+	if errCode == s3err.ErrNoSuchBucket {
+		return s3err.ErrInvalidRequest
+	}
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	if bucketOwnerId(sourceMetadata) != bucketOwnerId(destinationMetadata) {
+		return s3err.ErrAccessDenied
+	}
+	return s3err.ErrNone
+}
+
+// inventoryDestinationBucket returns the bucket name of the destination bucket ARN
+func inventoryDestinationBucket(destination *s3.InventoryS3BucketDestination) string {
+	return strings.TrimPrefix(aws.StringValue(destination.Bucket), "arn:aws:s3:::")
+}
+
+func inventoryFrequency(config *s3.InventoryConfiguration) time.Duration {
+	if aws.StringValue(config.Schedule.Frequency) == s3.InventoryFrequencyWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// inventoryFileSchema lists the columns of the reports of the configuration
+func inventoryFileSchema(config *s3.InventoryConfiguration) []string {
+	schema := []string{"Bucket", "Key"}
+	if aws.StringValue(config.IncludedObjectVersions) == s3.InventoryIncludedObjectVersionsAll {
+		schema = append(schema, "VersionId", "IsLatest", "IsDeleteMarker")
+	}
+	for _, field := range config.OptionalFields {
+		schema = append(schema, aws.StringValue(field))
+	}
+	return schema
+}
+
+// startInventoryWorker generates the due inventory reports periodically.
+// All S3 gateways compete for the same filer lock, so only one of them generates the reports.
+func (s3a *S3ApiServer) startInventoryWorker() {
+	owner := fmt.Sprintf("%s:%d", util.DetectedHostAddress(), s3a.option.Port)
+	lockClient := cluster.NewLockClient(s3a.option.GrpcDialOption, s3a.option.Filer)
+	lock := lockClient.StartLongLivedLock(inventoryLockName, owner, func(newLockOwner string) {
+		glog.V(0).Infof("s3 inventory worker is %s", newLockOwner)
+	})
+	for {
+		time.Sleep(inventoryScanInterval)
+		if lock.LockOwner() != owner {
+			continue
+		}
+		if err := s3a.generateDueInventories(time.Now()); err != nil {
+			glog.Errorf("generate inventories: %v", err)
+		}
+	}
+}
+
+func (s3a *S3ApiServer) generateDueInventories(now time.Time) error {
+	var buckets []*filer_pb.Entry
+	err := s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.SeaweedList(client, s3a.option.BucketsPath, "", func(entry *filer_pb.Entry, isLast bool) error {
+			if entry.IsDirectory && len(entry.Extended[s3_constants.ExtInventoryKey]) > 0 {
+				buckets = append(buckets, entry)
+			}
+			return nil
+		}, "", false, math.MaxInt32)
+	})
+	if err != nil {
+		return fmt.Errorf("list buckets: %v", err)
+	}
+
+	for _, bucketEntry := range buckets {
+		var configs []*s3.InventoryConfiguration
+		if err := json.Unmarshal(bucketEntry.Extended[s3_constants.ExtInventoryKey], &configs); err != nil {
+			glog.Errorf("unmarshal bucket %s inventory: %v", bucketEntry.Name, err)
+			continue
+		}
+		for _, config := range configs {
+			if !aws.BoolValue(config.IsEnabled) {
+				continue
+			}
+			workerKey := inventoryWorkerKeyPrefix + bucketEntry.Name + "." + aws.StringValue(config.Id)
+			lastRunTsNs, err := s3a.readWorkerOffset(workerKey)
+			if err != nil {
+				glog.Errorf("read bucket %s inventory %s last run: %v", bucketEntry.Name, aws.StringValue(config.Id), err)
+				continue
+			}
+			if now.Sub(time.Unix(0, lastRunTsNs)) < inventoryFrequency(config) {
+				continue
+			}
+			if err := s3a.generateInventory(bucketEntry.Name, config, now); err != nil {
+				glog.Errorf("generate bucket %s inventory %s: %v", bucketEntry.Name, aws.StringValue(config.Id), err)
+				continue
+			}
+			if err := s3a.saveWorkerOffset(workerKey, now.UnixNano()); err != nil {
+				glog.Errorf("save bucket %s inventory %s last run: %v", bucketEntry.Name, aws.StringValue(config.Id), err)
+			}
+		}
+	}
+	return nil
+}
+
+type inventoryManifestFile struct {
+	Key         string `json:"key"`
+	Size        int    `json:"size"`
+	MD5checksum string `json:"MD5checksum"`
+}
+
+type inventoryManifest struct {
+	SourceBucket      string                  `json:"sourceBucket"`
+	DestinationBucket string                  `json:"destinationBucket"`
+	Version           string                  `json:"version"`
+	CreationTimestamp string                  `json:"creationTimestamp"`
+	FileFormat        string                  `json:"fileFormat"`
+	FileSchema        string                  `json:"fileSchema"`
+	Files             []inventoryManifestFile `json:"files"`
+}
+
+// inventoryWriter writes the inventory rows as gzipped CSV files of up to inventoryRowsPerFile rows
+type inventoryWriter struct {
+	dataPrefix string
+	putFn      func(key string, data []byte) error
+	buf        bytes.Buffer
+	gzipWriter *gzip.Writer
+	csvWriter  *csv.Writer
+	rows       int
+	files      []inventoryManifestFile
+}
+
+func (iw *inventoryWriter) write(record []string) error {
+	if iw.csvWriter == nil {
+		iw.buf.Reset()
+		iw.gzipWriter = gzip.NewWriter(&iw.buf)
+		iw.csvWriter = csv.NewWriter(iw.gzipWriter)
+	}
+	if err := iw.csvWriter.Write(record); err != nil {
+		return err
+	}
+	iw.rows++
+	if iw.rows >= inventoryRowsPerFile {
+		return iw.flush()
+	}
+	return nil
+}
+
+func (iw *inventoryWriter) flush() error {
+	if iw.csvWriter == nil {
+		return nil
+	}
+	iw.csvWriter.Flush()
+	if err := iw.csvWriter.Error(); err != nil {
+		return err
+	}
+	if err := iw.gzipWriter.Close(); err != nil {
+		return err
+	}
+	data := iw.buf.Bytes()
+	key := iw.dataPrefix + uuid.New().String() + ".csv.gz"
+	if err := iw.putFn(key, data); err != nil {
+		return err
+	}
+	checksum := md5.Sum(data)
+	iw.files = append(iw.files, inventoryManifestFile{Key: key, Size: len(data), MD5checksum: hex.EncodeToString(checksum[:])})
+	iw.csvWriter, iw.gzipWriter, iw.rows = nil, nil, 0
+	return nil
+}
+
+// generateInventory writes the data files and then the manifest of an inventory report of the bucket
+func (s3a *S3ApiServer) generateInventory(bucket string, config *s3.InventoryConfiguration, now time.Time) error {
+	destination := config.Destination.S3BucketDestination
+	destinationBucket := inventoryDestinationBucket(destination)
+	reportPrefix := fmt.Sprintf("%s/%s/%s/", strings.TrimSuffix(aws.StringValue(destination.Prefix), "/"), bucket, aws.StringValue(config.Id))
+	reportPrefix = strings.TrimPrefix(reportPrefix, "/")
+
+	iw := &inventoryWriter{
+		dataPrefix: reportPrefix + "data/",
+		putFn: func(key string, data []byte) error {
+			return s3a.putObjectBytes(destinationBucket, key, "application/octet-stream", data)
+		},
+	}
+	if err := s3a.walkInventory(bucket, config, iw.write); err != nil {
+		return err
+	}
+	if err := iw.flush(); err != nil {
+		return err
+	}
+
+	manifest := &inventoryManifest{
+		SourceBucket:      bucket,
+		DestinationBucket: aws.StringValue(destination.Bucket),
+		Version:           inventoryManifestVersion,
+		CreationTimestamp: strconv.FormatInt(now.UnixMilli(), 10),
+		FileFormat:        aws.StringValue(destination.Format),
+		FileSchema:        strings.Join(inventoryFileSchema(config), ", "),
+		Files:             iw.files,
+	}
+	if manifest.Files == nil {
+		manifest.Files = []inventoryManifestFile{}
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestPrefix := reportPrefix + now.UTC().Format(inventoryManifestTimeFormat) + "/"
+	if err = s3a.putObjectBytes(destinationBucket, manifestPrefix+"manifest.json", "application/json", manifestData); err != nil {
+		return err
+	}
+	checksum := md5.Sum(manifestData)
+	return s3a.putObjectBytes(destinationBucket, manifestPrefix+"manifest.checksum", "text/plain", []byte(hex.EncodeToString(checksum[:])))
+}
+
+// walkInventory lists the current objects, and the archived versions if all versions are included
+func (s3a *S3ApiServer) walkInventory(bucket string, config *s3.InventoryConfiguration, writeFn func(record []string) error) error {
+	bucketDir := fmt.Sprintf("%s/%s", s3a.option.BucketsPath, bucket)
+	prefix := ""
+	if config.Filter != nil {
+		prefix = aws.StringValue(config.Filter.Prefix)
+	}
+	allVersions := aws.StringValue(config.IncludedObjectVersions) == s3.InventoryIncludedObjectVersionsAll
+
+	return s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		var writeErr error
+		write := func(key string, entry *filer_pb.Entry, versionId string, isLatest bool) {
+			if writeErr == nil {
+				writeErr = writeFn(inventoryRecord(bucket, key, entry, config, allVersions, versionId, isLatest))
+			}
+		}
+
+		err := s3a.walkVersionKeys(client, bucketDir, "", prefix, false, func(key string, entry *filer_pb.Entry) {
+			write(key, entry, entryVersionId(entry), true)
+		})
+		if err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("walk objects: %v", err)
+		}
+		if writeErr != nil || !allVersions {
+			return writeErr
+		}
+
+		// the versions of a key are listed together, the newest delete marker is the latest version without a current object
+		var versionsKey string
+		var versions []*objectVersion
+		writeVersions := func() {
+			if len(versions) == 0 {
+				return
+			}
+			sortVersions(versions)
+			latest := false
+			if versions[0].isDeleteMarker {
+				dir, name := util.FullPath(bucketDir + "/" + versionsKey).DirAndName()
+				_, lookupErr := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{Directory: dir, Name: name})
+				latest = lookupErr == filer_pb.ErrNotFound
+			}
+			for i, v := range versions {
+				write(versionsKey, v.entry, v.versionId, latest && i == 0)
+			}
+			versions = versions[:0]
+		}
+		err = s3a.walkVersionKeys(client, bucketDir+"/"+s3_constants.VersionsFolder, "", prefix, true, func(key string, entry *filer_pb.Entry) {
+			if key != versionsKey {
+				writeVersions()
+				versionsKey = key
+			}
+			versions = append(versions, &objectVersion{versionId: entry.Name, entry: entry, isDeleteMarker: isDeleteMarker(entry)})
+		})
+		if err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("walk versions: %v", err)
+		}
+		writeVersions()
+		return writeErr
+	})
+}
+
+func inventoryRecord(bucket, key string, entry *filer_pb.Entry, config *s3.InventoryConfiguration, allVersions bool, versionId string, isLatest bool) []string {
+	record := []string{bucket, url.QueryEscape(key)}
+	deleteMarker := isDeleteMarker(entry)
+	if allVersions {
+		record = append(record, versionId, strconv.FormatBool(isLatest), strconv.FormatBool(deleteMarker))
+	}
+	for _, field := range config.OptionalFields {
+		value := ""
+		if !deleteMarker {
+			value = inventoryOptionalFields[aws.StringValue(field)](entry)
+		}
+		record = append(record, value)
+	}
+	return record
+}
+
+// sortInventoryConfigurations orders the configurations by id, as they are listed
+func sortInventoryConfigurations(configs []*s3.InventoryConfiguration) {
+	sort.Slice(configs, func(i, j int) bool {
+		return aws.StringValue(configs[i].Id) < aws.StringValue(configs[j].Id)
+	})
+}
@@ -0,0 +1,223 @@
+package s3api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const maxInventoryConfigurationsPerList = 100
+
+// listInventoryConfigurationsResult wraps the list output with its XML root element
+type listInventoryConfigurationsResult struct {
+	_      struct{}                                    `type:"structure" payload:"Result"`
+	Result *s3.ListBucketInventoryConfigurationsOutput `locationName:"ListInventoryConfigurationsResult" type:"structure" xmlURI:"http://s3.amazonaws.com/doc/2006-03-01/"`
+}
+
+// GetBucketInventoryConfigurationHandler Get bucket inventory configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketInventoryConfiguration.html
+func (s3a *S3ApiServer) GetBucketInventoryConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	id := r.URL.Query().Get("id")
+	glog.V(3).Infof("GetBucketInventoryConfigurationHandler %s %s", bucket, id)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	configs, errCode := s3a.getBucketInventoryConfigurations(bucket)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	for _, config := range configs {
+		if aws.StringValue(config.Id) == id {
+			s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutBucketInventoryConfigurationInput{
+				InventoryConfiguration: config,
+			})
+			return
+		}
+	}
+
+	s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchInventoryConfiguration)
+}
+
+// PutBucketInventoryConfigurationHandler Put bucket inventory configuration, replacing the one with the same id
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketInventoryConfiguration.html
+func (s3a *S3ApiServer) PutBucketInventoryConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	id := r.URL.Query().Get("id")
+	glog.V(3).Infof("PutBucketInventoryConfigurationHandler %s %s", bucket, id)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var config s3.InventoryConfiguration
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&config, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if errCode := s3a.validateInventoryConfiguration(bucket, id, &config); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	errCode := s3a.updateBucketInventory(bucket, func(configs []*s3.InventoryConfiguration) ([]*s3.InventoryConfiguration, s3err.ErrorCode) {
+		for i, existing := range configs {
+			if aws.StringValue(existing.Id) == id {
+				configs[i] = &config
+				return configs, s3err.ErrNone
+			}
+		}
+		if len(configs) >= maxInventoryConfigurations {
+			return nil, s3err.ErrInvalidRequest
+		}
+		return append(configs, &config), s3err.ErrNone
+	})
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
+
+// DeleteBucketInventoryConfigurationHandler Delete bucket inventory configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketInventoryConfiguration.html
+func (s3a *S3ApiServer) DeleteBucketInventoryConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	id := r.URL.Query().Get("id")
+	glog.V(3).Infof("DeleteBucketInventoryConfigurationHandler %s %s", bucket, id)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	errCode := s3a.updateBucketInventory(bucket, func(configs []*s3.InventoryConfiguration) ([]*s3.InventoryConfiguration, s3err.ErrorCode) {
+		remaining := configs[:0]
+		for _, config := range configs {
+			if aws.StringValue(config.Id) != id {
+				remaining = append(remaining, config)
+			}
+		}
+		if len(remaining) == len(configs) {
+			return nil, s3err.ErrNoSuchInventoryConfiguration
+		}
+		return remaining, s3err.ErrNone
+	})
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	s3err.WriteEmptyResponse(w, r, http.StatusNoContent)
+}
+
+// ListBucketInventoryConfigurationsHandler List bucket inventory configurations, ordered by id
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListBucketInventoryConfigurations.html
+func (s3a *S3ApiServer) ListBucketInventoryConfigurationsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	continuationToken := r.URL.Query().Get("continuation-token")
+	glog.V(3).Infof("ListBucketInventoryConfigurationsHandler %s %s", bucket, continuationToken)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	configs, errCode := s3a.getBucketInventoryConfigurations(bucket)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	sortInventoryConfigurations(configs)
+
+	// the continuation token is the id of the last listed configuration
+	output := &s3.ListBucketInventoryConfigurationsOutput{IsTruncated: aws.Bool(false)}
+	if continuationToken != "" {
+		output.ContinuationToken = aws.String(continuationToken)
+	}
+	for _, config := range configs {
+		if aws.StringValue(config.Id) <= continuationToken {
+			continue
+		}
+		if len(output.InventoryConfigurationList) >= maxInventoryConfigurationsPerList {
+			output.IsTruncated = aws.Bool(true)
+			output.NextContinuationToken = output.InventoryConfigurationList[len(output.InventoryConfigurationList)-1].Id
+			break
+		}
+		output.InventoryConfigurationList = append(output.InventoryConfigurationList, config)
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &listInventoryConfigurationsResult{Result: output})
+}
+
+func (s3a *S3ApiServer) getBucketInventoryConfigurations(bucket string) ([]*s3.InventoryConfiguration, s3err.ErrorCode) {
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			return nil, s3err.ErrNoSuchBucket
+		}
+		return nil, s3err.ErrInternalError
+	}
+
+	configs, err := decodeBucketInventory(bucketEntry.Extended)
+	if err != nil {
+		glog.Errorf("unmarshal bucket %s inventory: %v", bucket, err)
+		return nil, s3err.ErrInternalError
+	}
+	return configs, s3err.ErrNone
+}
+
+func decodeBucketInventory(extended map[string][]byte) (configs []*s3.InventoryConfiguration, err error) {
+	if inventoryBytes := extended[s3_constants.ExtInventoryKey]; len(inventoryBytes) > 0 {
+		err = json.Unmarshal(inventoryBytes, &configs)
+	}
+	return
+}
+
+// updateBucketInventory applies the update to the inventory configurations of the bucket entry,
+// and removes them if there are none left
+func (s3a *S3ApiServer) updateBucketInventory(bucket string, update func(configs []*s3.InventoryConfiguration) ([]*s3.InventoryConfiguration, s3err.ErrorCode)) s3err.ErrorCode {
+	return s3a.updateBucketEntry(bucket, func(extended map[string][]byte) (bool, s3err.ErrorCode) {
+		configs, err := decodeBucketInventory(extended)
+		if err != nil {
+			glog.Errorf("unmarshal bucket %s inventory: %v", bucket, err)
+			return false, s3err.ErrInternalError
+		}
+		configs, errCode := update(configs)
+		if errCode != s3err.ErrNone {
+			return false, errCode
+		}
+		if len(configs) == 0 {
+			delete(extended, s3_constants.ExtInventoryKey)
+			return true, s3err.ErrNone
+		}
+		inventoryBytes, err := json.Marshal(configs)
+		if err != nil {
+			return false, s3err.ErrInternalError
+		}
+		extended[s3_constants.ExtInventoryKey] = inventoryBytes
+		return true, s3err.ErrNone
+	})
+}
@@ -0,0 +1,155 @@
+package s3api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInventoryConfiguration() *s3.InventoryConfiguration {
+	return &s3.InventoryConfiguration{
+		Id:        aws.String("daily"),
+		IsEnabled: aws.Bool(true),
+		Destination: &s3.InventoryDestination{S3BucketDestination: &s3.InventoryS3BucketDestination{
+			Bucket: aws.String("arn:aws:s3:::reports"),
+			Format: aws.String(s3.InventoryFormatCsv),
+			Prefix: aws.String("inventory"),
+		}},
+		Schedule:               &s3.InventorySchedule{Frequency: aws.String(s3.InventoryFrequencyDaily)},
+		IncludedObjectVersions: aws.String(s3.InventoryIncludedObjectVersionsCurrent),
+		OptionalFields:         aws.StringSlice([]string{s3.InventoryOptionalFieldSize, s3.InventoryOptionalFieldEtag, s3.InventoryOptionalFieldStorageClass, inventoryOptionalFieldTags}),
+	}
+}
+
+func TestValidateInventoryConfiguration(t *testing.T) {
+	s3a := &S3ApiServer{}
+	tests := []struct {
+		name     string
+		id       string
+		modify   func(config *s3.InventoryConfiguration)
+		expected s3err.ErrorCode
+	}{
+		{"mismatched id", "weekly", func(config *s3.InventoryConfiguration) {}, s3err.ErrMalformedXML},
+		{"missing destination", "daily", func(config *s3.InventoryConfiguration) { config.Destination = nil }, s3err.ErrMalformedXML},
+		{"parquet", "daily", func(config *s3.InventoryConfiguration) {
+			config.Destination.S3BucketDestination.Format = aws.String(s3.InventoryFormatParquet)
+		}, s3err.ErrNotImplemented},
+		{"encryption", "daily", func(config *s3.InventoryConfiguration) {
+			config.Destination.S3BucketDestination.Encryption = &s3.InventoryEncryption{SSES3: &s3.SSES3{}}
+		}, s3err.ErrNotImplemented},
+		{"invalid frequency", "daily", func(config *s3.InventoryConfiguration) {
+			config.Schedule.Frequency = aws.String("Hourly")
+		}, s3err.ErrInvalidRequest},
+		{"invalid versions", "daily", func(config *s3.InventoryConfiguration) {
+			config.IncludedObjectVersions = aws.String("Some")
+		}, s3err.ErrInvalidRequest},
+		{"invalid field", "daily", func(config *s3.InventoryConfiguration) {
+			config.OptionalFields = aws.StringSlice([]string{"Owner"})
+		}, s3err.ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestInventoryConfiguration()
+			tt.modify(config)
+			assert.Equal(t, tt.expected, s3a.validateInventoryConfiguration("bucket", tt.id, config))
+		})
+	}
+}
+
+func TestInventoryRecord(t *testing.T) {
+	config := newTestInventoryConfiguration()
+	entry := &filer_pb.Entry{
+		Name:       "a b.txt",
+		Attributes: &filer_pb.FuseAttributes{FileSize: 5, Mtime: 1700000000, Md5: []byte{0x5d, 0x41, 0x40, 0x2a, 0xbc, 0x4b, 0x2a, 0x76, 0xb9, 0x71, 0x9d, 0x91, 0x10, 0x17, 0xc5, 0x92}},
+		Extended: map[string][]byte{
+			s3_constants.AmzStorageClass:                   []byte("STANDARD_IA"),
+			s3_constants.AmzObjectTaggingPrefix + "team":   []byte("data"),
+			s3_constants.AmzObjectTaggingPrefix + "expiry": []byte("1 day"),
+		},
+	}
+
+	assert.Equal(t, []string{"Bucket", "Key", "Size", "ETag", "StorageClass", "Tags"}, inventoryFileSchema(config))
+	assert.Equal(t,
+		[]string{"bucket", "dir%2Fa+b.txt", "5", "5d41402abc4b2a76b9719d911017c592", "STANDARD_IA", "expiry=1+day&team=data"},
+		inventoryRecord("bucket", "dir/a b.txt", entry, config, false, "", true))
+
+	config.IncludedObjectVersions = aws.String(s3.InventoryIncludedObjectVersionsAll)
+	assert.Equal(t, []string{"Bucket", "Key", "VersionId", "IsLatest", "IsDeleteMarker", "Size", "ETag", "StorageClass", "Tags"}, inventoryFileSchema(config))
+	deleteMarker := &filer_pb.Entry{Name: "v1", Attributes: &filer_pb.FuseAttributes{}, Extended: map[string][]byte{s3_constants.ExtDeleteMarkerKey: []byte("true")}}
+	assert.Equal(t,
+		[]string{"bucket", "a", "v1", "true", "true", "", "", "", ""},
+		inventoryRecord("bucket", "a", deleteMarker, config, true, "v1", true))
+}
+
+func TestInventoryWriter(t *testing.T) {
+	written := make(map[string][]byte)
+	iw := &inventoryWriter{
+		dataPrefix: "inventory/bucket/daily/data/",
+		putFn: func(key string, data []byte) error {
+			written[key] = data
+			return nil
+		},
+	}
+	assert.NoError(t, iw.write([]string{"bucket", "a", "1"}))
+	assert.NoError(t, iw.write([]string{"bucket", "b,c", "2"}))
+	assert.NoError(t, iw.flush())
+	assert.NoError(t, iw.flush())
+
+	assert.Len(t, iw.files, 1)
+	assert.True(t, strings.HasPrefix(iw.files[0].Key, "inventory/bucket/daily/data/"))
+	assert.True(t, strings.HasSuffix(iw.files[0].Key, ".csv.gz"))
+	data := written[iw.files[0].Key]
+	assert.Equal(t, len(data), iw.files[0].Size)
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	records, err := csv.NewReader(gz).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"bucket", "a", "1"}, {"bucket", "b,c", "2"}}, records)
+}
+
+func TestInventoryXML(t *testing.T) {
+	body := `<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+   <Id>daily</Id>
+   <IsEnabled>true</IsEnabled>
+   <Filter><Prefix>logs/</Prefix></Filter>
+   <Destination>
+      <S3BucketDestination>
+         <Format>CSV</Format>
+         <Bucket>arn:aws:s3:::reports</Bucket>
+      </S3BucketDestination>
+   </Destination>
+   <Schedule><Frequency>Weekly</Frequency></Schedule>
+   <IncludedObjectVersions>All</IncludedObjectVersions>
+   <OptionalFields><Field>Size</Field><Field>Tags</Field></OptionalFields>
+</InventoryConfiguration>`
+	var config s3.InventoryConfiguration
+	assert.NoError(t, xmlutil.UnmarshalXML(&config, xml.NewDecoder(strings.NewReader(body)), ""))
+	assert.Equal(t, "logs/", aws.StringValue(config.Filter.Prefix))
+	assert.Equal(t, "reports", inventoryDestinationBucket(config.Destination.S3BucketDestination))
+	assert.Equal(t, 7*24*time.Hour, inventoryFrequency(&config))
+	assert.Equal(t, []string{"Size", "Tags"}, aws.StringValueSlice(config.OptionalFields))
+
+	var buf bytes.Buffer
+	result := &listInventoryConfigurationsResult{Result: &s3.ListBucketInventoryConfigurationsOutput{
+		InventoryConfigurationList: []*s3.InventoryConfiguration{&config},
+		IsTruncated:                aws.Bool(false),
+	}}
+	assert.NoError(t, xmlutil.BuildXML(result, xml.NewEncoder(&buf)))
+	// the child elements are not built in a fixed order
+	assert.True(t, strings.HasPrefix(buf.String(), `<ListInventoryConfigurationsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`), buf.String())
+	assert.Contains(t, buf.String(), `<InventoryConfiguration>`)
+	assert.Contains(t, buf.String(), `<Id>daily</Id>`)
+}
@@ -0,0 +1,259 @@
+package s3api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+const (
+	maxLifecycleRules     = 1000
+	maxLifecycleRuleIdLen = 255
+)
+
+// validateLifecycleConfiguration checks the rules the same way AWS S3 does when putting a lifecycle configuration
+func validateLifecycleConfiguration(config *s3.BucketLifecycleConfiguration) s3err.ErrorCode {
+	if len(config.Rules) == 0 || len(config.Rules) > maxLifecycleRules {
+		return s3err.ErrMalformedXML
+	}
+	ruleIds := make(map[string]struct{})
+	for _, rule := range config.Rules {
+		if rule == nil {
+			return s3err.ErrMalformedXML
+		}
+		if errCode := validateLifecycleRule(rule); errCode != s3err.ErrNone {
+			return errCode
+		}
+		if id := aws.StringValue(rule.ID); id != "" {
+			if _, found := ruleIds[id]; found {
+				return s3err.ErrInvalidLifecycleRule
+			}
+			ruleIds[id] = struct{}{}
+		}
+	}
+	return s3err.ErrNone
+}
+
+func validateLifecycleRule(rule *s3.LifecycleRule) s3err.ErrorCode {
+	if len(aws.StringValue(rule.ID)) > maxLifecycleRuleIdLen {
+		return s3err.ErrInvalidLifecycleRule
+	}
+	status := aws.StringValue(rule.Status)
+	if status != s3.ExpirationStatusEnabled && status != s3.ExpirationStatusDisabled {
+		return s3err.ErrMalformedXML
+	}
+
+	hasTags := false
+	if filter := rule.Filter; filter != nil {
+		if rule.Prefix != nil {
+			return s3err.ErrMalformedXML
+		}
+		conditions := 0
+		for _, set := range []bool{filter.Prefix != nil, filter.Tag != nil, filter.And != nil} {
+			if set {
+				conditions++
+			}
+		}
+		if conditions > 1 {
+			return s3err.ErrMalformedXML
+		}
+		hasTags = filter.Tag != nil || filter.And != nil && len(filter.And.Tags) > 0
+	}
+
+	if rule.Expiration == nil && len(rule.Transitions) == 0 && rule.NoncurrentVersionExpiration == nil &&
+		len(rule.NoncurrentVersionTransitions) == 0 && rule.AbortIncompleteMultipartUpload == nil {
+		return s3err.ErrInvalidLifecycleRule
+	}
+
+	if expiration := rule.Expiration; expiration != nil {
+		actions := 0
+		if expiration.Days != nil {
+			if *expiration.Days <= 0 {
+				return s3err.ErrInvalidLifecycleRule
+			}
+			actions++
+		}
+		if expiration.Date != nil {
+			if !isMidnightUTC(*expiration.Date) {
+				return s3err.ErrInvalidLifecycleRule
+			}
+			actions++
+		}
+		if expiration.ExpiredObjectDeleteMarker != nil {
+			if hasTags {
+				return s3err.ErrInvalidLifecycleRule
+			}
+			actions++
+		}
+		if actions != 1 {
+			return s3err.ErrMalformedXML
+		}
+	}
+
+	for _, transition := range rule.Transitions {
+		if transition == nil || aws.StringValue(transition.StorageClass) == "" || (transition.Days == nil) == (transition.Date == nil) {
+			return s3err.ErrMalformedXML
+		}
+		if transition.Days != nil && *transition.Days < 0 || transition.Date != nil && !isMidnightUTC(*transition.Date) {
+			return s3err.ErrInvalidLifecycleRule
+		}
+	}
+
+	if expiration := rule.NoncurrentVersionExpiration; expiration != nil {
+		if aws.Int64Value(expiration.NoncurrentDays) <= 0 || aws.Int64Value(expiration.NewerNoncurrentVersions) < 0 {
+			return s3err.ErrInvalidLifecycleRule
+		}
+	}
+	for _, transition := range rule.NoncurrentVersionTransitions {
+		if transition == nil || aws.StringValue(transition.StorageClass) == "" {
+			return s3err.ErrMalformedXML
+		}
+		if aws.Int64Value(transition.NoncurrentDays) < 0 || aws.Int64Value(transition.NewerNoncurrentVersions) < 0 {
+			return s3err.ErrInvalidLifecycleRule
+		}
+	}
+
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+		if hasTags || aws.Int64Value(abort.DaysAfterInitiation) <= 0 {
+			return s3err.ErrInvalidLifecycleRule
+		}
+	}
+	return s3err.ErrNone
+}
+
+func isMidnightUTC(t time.Time) bool {
+	return t.UTC().Equal(t.UTC().Truncate(24 * time.Hour))
+}
+
+// lifecycleDueTime adds the days to the start time, rounded up to the next midnight UTC as AWS S3 does
+func lifecycleDueTime(start time.Time, days int64) time.Time {
+	due := start.UTC().AddDate(0, 0, int(days))
+	if midnight := due.Truncate(24 * time.Hour); midnight.Before(due) {
+		return midnight.Add(24 * time.Hour)
+	}
+	return due
+}
+
+// lifecycleRulePrefix returns the key prefix the rule applies to
+func lifecycleRulePrefix(rule *s3.LifecycleRule) string {
+	if filter := rule.Filter; filter != nil {
+		if filter.And != nil {
+			return aws.StringValue(filter.And.Prefix)
+		}
+		return aws.StringValue(filter.Prefix)
+	}
+	return aws.StringValue(rule.Prefix)
+}
+
+// lifecycleRuleMatches tells whether the rule filter selects the object
+func lifecycleRuleMatches(rule *s3.LifecycleRule, key string, entry *filer_pb.Entry) bool {
+	if aws.StringValue(rule.Status) != s3.ExpirationStatusEnabled {
+		return false
+	}
+	if !strings.HasPrefix(key, lifecycleRulePrefix(rule)) {
+		return false
+	}
+	filter := rule.Filter
+	if filter == nil {
+		return true
+	}
+
+	tags, sizeGreaterThan, sizeLessThan := filter.Tag, filter.ObjectSizeGreaterThan, filter.ObjectSizeLessThan
+	var andTags []*s3.Tag
+	if filter.And != nil {
+		andTags, sizeGreaterThan, sizeLessThan = filter.And.Tags, filter.And.ObjectSizeGreaterThan, filter.And.ObjectSizeLessThan
+	}
+	if tags != nil {
+		andTags = append(andTags, tags)
+	}
+	for _, tag := range andTags {
+		value, found := entry.Extended[s3_constants.AmzObjectTagging+"-"+aws.StringValue(tag.Key)]
+		if !found || string(value) != aws.StringValue(tag.Value) {
+			return false
+		}
+	}
+
+	size := int64(filer.FileSize(entry))
+	if sizeGreaterThan != nil && size <= *sizeGreaterThan {
+		return false
+	}
+	if sizeLessThan != nil && size >= *sizeLessThan {
+		return false
+	}
+	return true
+}
+
+// isExpirationDue tells whether the current object, last modified at mtime, should expire
+func isExpirationDue(rule *s3.LifecycleRule, mtime, now time.Time) bool {
+	expiration := rule.Expiration
+	if expiration == nil {
+		return false
+	}
+	if expiration.Days != nil {
+		return !now.Before(lifecycleDueTime(mtime, *expiration.Days))
+	}
+	if expiration.Date != nil {
+		return !now.Before(*expiration.Date)
+	}
+	return false
+}
+
+// dueTransition returns the storage class of the latest transition that is due, or "" if there is none
+func dueTransition(transitions []*s3.Transition, mtime, now time.Time) (storageClass string) {
+	var latest time.Time
+	for _, transition := range transitions {
+		var due time.Time
+		if transition.Days != nil {
+			due = lifecycleDueTime(mtime, *transition.Days)
+		} else {
+			due = aws.TimeValue(transition.Date)
+		}
+		if !now.Before(due) && !due.Before(latest) {
+			latest, storageClass = due, aws.StringValue(transition.StorageClass)
+		}
+	}
+	return
+}
+
+// dueNoncurrentTransition is like dueTransition, for versions that became noncurrent at noncurrentSince
+// and have newerNoncurrent noncurrent versions newer than themselves
+func dueNoncurrentTransition(transitions []*s3.NoncurrentVersionTransition, noncurrentSince, now time.Time, newerNoncurrent int) (storageClass string) {
+	var latest time.Time
+	for _, transition := range transitions {
+		if int64(newerNoncurrent) < aws.Int64Value(transition.NewerNoncurrentVersions) {
+			continue
+		}
+		due := lifecycleDueTime(noncurrentSince, aws.Int64Value(transition.NoncurrentDays))
+		if !now.Before(due) && !due.Before(latest) {
+			latest, storageClass = due, aws.StringValue(transition.StorageClass)
+		}
+	}
+	return
+}
+
+// isNoncurrentExpirationDue tells whether a noncurrent version should be permanently deleted
+func isNoncurrentExpirationDue(rule *s3.LifecycleRule, noncurrentSince, now time.Time, newerNoncurrent int) bool {
+	expiration := rule.NoncurrentVersionExpiration
+	if expiration == nil {
+		return false
+	}
+	if int64(newerNoncurrent) < aws.Int64Value(expiration.NewerNoncurrentVersions) {
+		return false
+	}
+	return !now.Before(lifecycleDueTime(noncurrentSince, aws.Int64Value(expiration.NoncurrentDays)))
+}
+
+// isAbortUploadDue tells whether an incomplete multipart upload initiated at the given time should be aborted
+func isAbortUploadDue(rule *s3.LifecycleRule, initiated, now time.Time) bool {
+	abort := rule.AbortIncompleteMultipartUpload
+	if abort == nil {
+		return false
+	}
+	return !now.Before(lifecycleDueTime(initiated, aws.Int64Value(abort.DaysAfterInitiation)))
+}
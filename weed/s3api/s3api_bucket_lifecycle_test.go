@@ -0,0 +1,182 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLifecycleConfiguration(t *testing.T) {
+	body := `<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Rule>
+    <ID>logs</ID>
+    <Filter>
+      <And>
+        <Prefix>logs/</Prefix>
+        <Tag><Key>class</Key><Value>temp</Value></Tag>
+      </And>
+    </Filter>
+    <Status>Enabled</Status>
+    <Transition><Days>30</Days><StorageClass>ssd</StorageClass></Transition>
+    <Expiration><Days>365</Days></Expiration>
+    <NoncurrentVersionExpiration><NoncurrentDays>7</NoncurrentDays><NewerNoncurrentVersions>2</NewerNoncurrentVersions></NoncurrentVersionExpiration>
+  </Rule>
+  <Rule>
+    <Filter><Prefix></Prefix></Filter>
+    <Status>Enabled</Status>
+    <AbortIncompleteMultipartUpload><DaysAfterInitiation>3</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+  </Rule>
+</LifecycleConfiguration>`
+
+	var config s3.BucketLifecycleConfiguration
+	err := xmlutil.UnmarshalXML(&config, xml.NewDecoder(strings.NewReader(body)), "")
+	assert.NoError(t, err)
+	assert.Equal(t, s3err.ErrNone, validateLifecycleConfiguration(&config))
+	assert.Len(t, config.Rules, 2)
+	assert.Equal(t, "logs/", lifecycleRulePrefix(config.Rules[0]))
+	assert.Equal(t, int64(365), aws.Int64Value(config.Rules[0].Expiration.Days))
+	assert.Equal(t, "ssd", aws.StringValue(config.Rules[0].Transitions[0].StorageClass))
+	assert.Equal(t, int64(3), aws.Int64Value(config.Rules[1].AbortIncompleteMultipartUpload.DaysAfterInitiation))
+}
+
+func TestValidateLifecycleConfiguration(t *testing.T) {
+	midnight := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		rule     *s3.LifecycleRule
+		expected s3err.ErrorCode
+	}{
+		{"expiration days", lifecycleRule("", &s3.LifecycleExpiration{Days: aws.Int64(1)}), s3err.ErrNone},
+		{"expiration date", lifecycleRule("", &s3.LifecycleExpiration{Date: aws.Time(midnight)}), s3err.ErrNone},
+		{"expiration date not at midnight", lifecycleRule("", &s3.LifecycleExpiration{Date: aws.Time(midnight.Add(time.Hour))}), s3err.ErrInvalidLifecycleRule},
+		{"expiration zero days", lifecycleRule("", &s3.LifecycleExpiration{Days: aws.Int64(0)}), s3err.ErrInvalidLifecycleRule},
+		{"expiration days and date", lifecycleRule("", &s3.LifecycleExpiration{Days: aws.Int64(1), Date: aws.Time(midnight)}), s3err.ErrMalformedXML},
+		{"no action", lifecycleRule("", nil), s3err.ErrInvalidLifecycleRule},
+		{"bad status", &s3.LifecycleRule{Status: aws.String("On"), Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)}}, s3err.ErrMalformedXML},
+		{"prefix and filter", &s3.LifecycleRule{
+			Status:     aws.String("Enabled"),
+			Prefix:     aws.String("a/"),
+			Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("a/")},
+			Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)},
+		}, s3err.ErrMalformedXML},
+		{"transition without storage class", &s3.LifecycleRule{
+			Status:      aws.String("Enabled"),
+			Transitions: []*s3.Transition{{Days: aws.Int64(1)}},
+		}, s3err.ErrMalformedXML},
+		{"abort upload with tag filter", &s3.LifecycleRule{
+			Status:                         aws.String("Enabled"),
+			Filter:                         &s3.LifecycleRuleFilter{Tag: &s3.Tag{Key: aws.String("k"), Value: aws.String("v")}},
+			AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(1)},
+		}, s3err.ErrInvalidLifecycleRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{tt.rule}}
+			assert.Equal(t, tt.expected, validateLifecycleConfiguration(config))
+		})
+	}
+
+	duplicated := &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{
+		lifecycleRule("", &s3.LifecycleExpiration{Days: aws.Int64(1)}),
+		lifecycleRule("", &s3.LifecycleExpiration{Days: aws.Int64(2)}),
+	}}
+	duplicated.Rules[0].ID, duplicated.Rules[1].ID = aws.String("same"), aws.String("same")
+	assert.Equal(t, s3err.ErrInvalidLifecycleRule, validateLifecycleConfiguration(duplicated))
+	assert.Equal(t, s3err.ErrMalformedXML, validateLifecycleConfiguration(&s3.BucketLifecycleConfiguration{}))
+}
+
+func TestLifecycleDueTime(t *testing.T) {
+	start := time.Date(2014, 1, 15, 10, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2014, 1, 19, 0, 0, 0, 0, time.UTC), lifecycleDueTime(start, 3))
+
+	midnight := time.Date(2014, 1, 15, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2014, 1, 18, 0, 0, 0, 0, time.UTC), lifecycleDueTime(midnight, 3))
+}
+
+func TestLifecycleRuleMatches(t *testing.T) {
+	entry := &filer_pb.Entry{
+		Name:       "a.log",
+		Attributes: &filer_pb.FuseAttributes{FileSize: 100},
+		Extended: map[string][]byte{
+			s3_constants.AmzObjectTagging + "-class": []byte("temp"),
+		},
+	}
+
+	rule := lifecycleRule("logs/", &s3.LifecycleExpiration{Days: aws.Int64(1)})
+	assert.True(t, lifecycleRuleMatches(rule, "logs/a.log", entry))
+	assert.False(t, lifecycleRuleMatches(rule, "data/a.log", entry))
+
+	rule.Status = aws.String("Disabled")
+	assert.False(t, lifecycleRuleMatches(rule, "logs/a.log", entry))
+
+	tagged := &s3.LifecycleRule{
+		Status: aws.String("Enabled"),
+		Filter: &s3.LifecycleRuleFilter{And: &s3.LifecycleRuleAndOperator{
+			Prefix: aws.String("logs/"),
+			Tags:   []*s3.Tag{{Key: aws.String("class"), Value: aws.String("temp")}},
+		}},
+	}
+	assert.True(t, lifecycleRuleMatches(tagged, "logs/a.log", entry))
+	tagged.Filter.And.Tags[0].Value = aws.String("keep")
+	assert.False(t, lifecycleRuleMatches(tagged, "logs/a.log", entry))
+
+	sized := &s3.LifecycleRule{
+		Status: aws.String("Enabled"),
+		Filter: &s3.LifecycleRuleFilter{ObjectSizeGreaterThan: aws.Int64(50)},
+	}
+	assert.True(t, lifecycleRuleMatches(sized, "logs/a.log", entry))
+	sized.Filter.ObjectSizeGreaterThan = aws.Int64(100)
+	assert.False(t, lifecycleRuleMatches(sized, "logs/a.log", entry))
+}
+
+func TestDueTransition(t *testing.T) {
+	mtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	transitions := []*s3.Transition{
+		{Days: aws.Int64(30), StorageClass: aws.String("hdd")},
+		{Days: aws.Int64(90), StorageClass: aws.String("REMOTE")},
+	}
+	assert.Equal(t, "", dueTransition(transitions, mtime, mtime.AddDate(0, 0, 10)))
+	assert.Equal(t, "hdd", dueTransition(transitions, mtime, mtime.AddDate(0, 0, 31)))
+	assert.Equal(t, "REMOTE", dueTransition(transitions, mtime, mtime.AddDate(0, 0, 91)))
+}
+
+func TestIsNoncurrentExpirationDue(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule := &s3.LifecycleRule{
+		Status: aws.String("Enabled"),
+		NoncurrentVersionExpiration: &s3.NoncurrentVersionExpiration{
+			NoncurrentDays:          aws.Int64(7),
+			NewerNoncurrentVersions: aws.Int64(2),
+		},
+	}
+	assert.False(t, isNoncurrentExpirationDue(rule, since, since.AddDate(0, 0, 6), 5))
+	assert.False(t, isNoncurrentExpirationDue(rule, since, since.AddDate(0, 0, 8), 1))
+	assert.True(t, isNoncurrentExpirationDue(rule, since, since.AddDate(0, 0, 8), 2))
+}
+
+func TestIsAbortUploadDue(t *testing.T) {
+	initiated := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	rule := &s3.LifecycleRule{
+		Status:                         aws.String("Enabled"),
+		AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(2)},
+	}
+	assert.False(t, isAbortUploadDue(rule, initiated, initiated.AddDate(0, 0, 1)))
+	assert.True(t, isAbortUploadDue(rule, initiated, initiated.AddDate(0, 0, 3)))
+}
+
+func lifecycleRule(prefix string, expiration *s3.LifecycleExpiration) *s3.LifecycleRule {
+	return &s3.LifecycleRule{
+		Status:     aws.String("Enabled"),
+		Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String(prefix)},
+		Expiration: expiration,
+	}
+}
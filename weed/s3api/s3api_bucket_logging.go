@@ -0,0 +1,251 @@
+package s3api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/log_buffer"
+	"google.golang.org/protobuf/proto"
+)
+
+// The requests to buckets with logging enabled are logged in the AWS server access log format.
+// The log lines are collected in a log buffer, keyed by the source bucket, and each flush writes
+// one log object per source bucket into its target bucket, under the target prefix.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+
+const (
+	accessLogFlushInterval = time.Minute
+	accessLogTimeFormat    = "[02/Jan/2006:15:04:05 -0700]"
+	accessLogKeyTimeFormat = "2006-01-02-15-04-05"
+	accessLogMaxErrorBody  = 1024
+	accessLogWriteRetries  = 3
+)
+
+var accessLogErrorCodeRegexp = regexp.MustCompile(`<Code>([^<]+)</Code>`)
+
+func (s3a *S3ApiServer) getBucketLogging(bucket string) (*BucketMetaData, *s3.LoggingEnabled) {
+	if bucket == "" {
+		return nil, nil
+	}
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone || bucketMetadata.Logging == nil {
+		return nil, nil
+	}
+	return bucketMetadata, bucketMetadata.Logging
+}
+
+// accessLogMiddleware records the requests to the buckets with logging enabled.
+// The requester is the identity the authentication sets, never one sent by the client.
+func (s3a *S3ApiServer) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(s3_constants.AmzIdentityId)
+		if s3a.accessLogBuffer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bucket, _ := s3_constants.GetBucketAndObject(r)
+		bucketMetadata, logging := s3a.getBucketLogging(bucket)
+		if logging == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		aw := &accessLogResponseWriter{ResponseWriter: w, start: time.Now(), statusCode: http.StatusOK}
+		next.ServeHTTP(aw, r)
+
+		line := formatAccessLog(bucketOwnerId(bucketMetadata), newAccessLog(r, aw))
+		s3a.accessLogBuffer.AddToBuffer([]byte(bucket), []byte(line), 0)
+	})
+}
+
+// accessLogResponseWriter records the status, size and timing of the response,
+// and the beginning of the error responses to find their error code
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	start          time.Time
+	firstByteTime  time.Time
+	statusCode     int
+	bytesSent      int64
+	errorBody      []byte
+	headersWritten bool
+}
+
+func (aw *accessLogResponseWriter) WriteHeader(statusCode int) {
+	if !aw.headersWritten {
+		aw.headersWritten = true
+		aw.statusCode = statusCode
+		aw.firstByteTime = time.Now()
+	}
+	aw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (aw *accessLogResponseWriter) Write(p []byte) (int, error) {
+	if !aw.headersWritten {
+		aw.WriteHeader(http.StatusOK)
+	}
+	if aw.statusCode >= http.StatusBadRequest && len(aw.errorBody) < accessLogMaxErrorBody {
+		aw.errorBody = append(aw.errorBody, p[:min(len(p), accessLogMaxErrorBody-len(aw.errorBody))]...)
+	}
+	n, err := aw.ResponseWriter.Write(p)
+	aw.bytesSent += int64(n)
+	return n, err
+}
+
+func (aw *accessLogResponseWriter) Flush() {
+	if flusher, ok := aw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func newAccessLog(r *http.Request, aw *accessLogResponseWriter) *s3err.AccessLogExtend {
+	log := &s3err.AccessLogExtend{
+		AccessLog: *s3err.GetAccessLog(r, aw.statusCode, s3err.ErrNone),
+		AccessLogHTTP: s3err.AccessLogHTTP{
+			RequestURI: fmt.Sprintf("%s %s %s", r.Method, r.RequestURI, r.Proto),
+			BytesSent:  strconv.FormatInt(aw.bytesSent, 10),
+			TotalTime:  int(time.Since(aw.start).Milliseconds()),
+			Referer:    r.Header.Get("Referer"),
+			VersionId:  aw.Header().Get(s3_constants.AmzVersionId),
+		},
+	}
+	log.Key = strings.TrimPrefix(log.Key, "/")
+	log.TurnAroundTime = log.TotalTime
+	if !aw.firstByteTime.IsZero() {
+		log.TurnAroundTime = int(aw.firstByteTime.Sub(aw.start).Milliseconds())
+	}
+	if match := accessLogErrorCodeRegexp.FindSubmatch(aw.errorBody); match != nil {
+		log.ErrorCode = string(match[1])
+	}
+	if log.Key != "" {
+		switch {
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Decoded-Content-Length") != "":
+			log.ObjectSize = r.Header.Get("X-Amz-Decoded-Content-Length")
+		case r.Method == http.MethodPut && r.ContentLength >= 0:
+			log.ObjectSize = strconv.FormatInt(r.ContentLength, 10)
+		case (r.Method == http.MethodGet || r.Method == http.MethodHead) && aw.statusCode < http.StatusMultipleChoices:
+			log.ObjectSize = aw.Header().Get("Content-Length")
+		}
+	}
+	switch getRequestAuthType(r) {
+	case authTypeSigned, authTypeSignedV2, authTypeStreamingSigned:
+		log.AuthenticationType = "AuthHeader"
+	case authTypePresigned, authTypePresignedV2:
+		log.AuthenticationType = "QueryString"
+	}
+	if r.TLS != nil {
+		log.TLSVersion = tls.VersionName(r.TLS.Version)
+		log.CipherSuite = tls.CipherSuiteName(r.TLS.CipherSuite)
+	}
+	return log
+}
+
+// formatAccessLog formats the log in the AWS server access log format, with "-" for the missing fields
+func formatAccessLog(owner string, log *s3err.AccessLogExtend) string {
+	field := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+	quoted := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return strings.Join([]string{
+		field(owner),
+		field(log.Bucket),
+		time.Unix(log.Time, 0).UTC().Format(accessLogTimeFormat),
+		field(log.RemoteIP),
+		field(log.Requester),
+		field(log.RequestID),
+		field(log.Operation),
+		field(log.Key),
+		quoted(log.RequestURI),
+		strconv.Itoa(log.HTTPStatus),
+		field(log.ErrorCode),
+		field(log.BytesSent),
+		field(log.ObjectSize),
+		strconv.Itoa(log.TotalTime),
+		strconv.Itoa(log.TurnAroundTime),
+		quoted(log.Referer),
+		quoted(log.UserAgent),
+		field(log.VersionId),
+		field(log.HostId),
+		field(log.SignatureVersion),
+		field(log.CipherSuite),
+		field(log.AuthenticationType),
+		field(log.HostHeader),
+		field(log.TLSVersion),
+	}, " ") + "\n"
+}
+
+// groupAccessLogs splits the flushed log buffer into the log lines of each source bucket
+func groupAccessLogs(buf []byte) map[string]*bytes.Buffer {
+	logs := make(map[string]*bytes.Buffer)
+	for pos := 0; pos+4 <= len(buf); {
+		size := int(util.BytesToUint32(buf[pos : pos+4]))
+		if pos+4+size > len(buf) {
+			glog.Errorf("s3 access log entry size %d exceeds the buffer", size)
+			break
+		}
+		logEntry := &filer_pb.LogEntry{}
+		if err := proto.Unmarshal(buf[pos+4:pos+4+size], logEntry); err != nil {
+			glog.Errorf("unmarshal s3 access log entry: %v", err)
+		} else {
+			bucket := string(logEntry.Key)
+			if logs[bucket] == nil {
+				logs[bucket] = &bytes.Buffer{}
+			}
+			logs[bucket].Write(logEntry.Data)
+		}
+		pos += 4 + size
+	}
+	return logs
+}
+
+// flushAccessLogs writes the buffered log lines into the target bucket of each source bucket
+func (s3a *S3ApiServer) flushAccessLogs(logBuffer *log_buffer.LogBuffer, startTime, stopTime time.Time, buf []byte) {
+	for bucket, logs := range groupAccessLogs(buf) {
+		_, logging := s3a.getBucketLogging(bucket)
+		if logging == nil {
+			glog.V(1).Infof("drop s3 access logs of bucket %s: logging is disabled", bucket)
+			continue
+		}
+		targetBucket := aws.StringValue(logging.TargetBucket)
+		key := fmt.Sprintf("%s%s-%08X%08X", aws.StringValue(logging.TargetPrefix), startTime.UTC().Format(accessLogKeyTimeFormat), uint32(s3a.randomClientId), rand.Uint32())
+		if err := s3a.writeAccessLogObject(targetBucket, key, logs.Bytes()); err != nil {
+			glog.Errorf("write s3 access logs of bucket %s to %s/%s: %v", bucket, targetBucket, key, err)
+		}
+	}
+}
+
+func (s3a *S3ApiServer) writeAccessLogObject(bucket, key string, data []byte) (err error) {
+	for i := 0; i < accessLogWriteRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		r, _ := http.NewRequest(http.MethodPut, "", nil)
+		r.Header.Set("Content-Type", "text/plain")
+		_, errCode := s3a.putToFiler(r, s3a.toFilerUrl(bucket, "/"+key), bytes.NewReader(data), "", bucket, nil)
+		if errCode == s3err.ErrNone {
+			return nil
+		}
+		err = fmt.Errorf("%s", s3err.GetAPIError(errCode).Code)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package s3api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// GetBucketLoggingHandler Get bucket logging status
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLogging.html
+func (s3a *S3ApiServer) GetBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetBucketLoggingHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchBucket)
+			return
+		}
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	// a bucket without logging has an empty logging status
+	output := &s3.PutBucketLoggingInput{BucketLoggingStatus: &s3.BucketLoggingStatus{}}
+	if loggingBytes, found := bucketEntry.Extended[s3_constants.ExtLoggingKey]; found && len(loggingBytes) > 0 {
+		var loggingEnabled s3.LoggingEnabled
+		if err = json.Unmarshal(loggingBytes, &loggingEnabled); err != nil {
+			glog.Errorf("unmarshal bucket %s logging: %v", bucket, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+		output.BucketLoggingStatus.LoggingEnabled = &loggingEnabled
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, output)
+}
+
+// PutBucketLoggingHandler Put bucket logging status, an empty status disables logging
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLogging.html
+func (s3a *S3ApiServer) PutBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutBucketLoggingHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var loggingStatus s3.BucketLoggingStatus
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&loggingStatus, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+
+	var loggingBytes []byte
+	if loggingEnabled := loggingStatus.LoggingEnabled; loggingEnabled != nil {
+		if errCode := s3a.validateBucketLogging(bucket, loggingEnabled); errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+		var err error
+		if loggingBytes, err = json.Marshal(loggingEnabled); err != nil {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+	}
+	if errCode := s3a.updateBucketConfig(bucket, s3_constants.ExtLoggingKey, loggingBytes); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
+
+// validateBucketLogging checks the target bucket exists and has the same owner as the source bucket
+func (s3a *S3ApiServer) validateBucketLogging(bucket string, loggingEnabled *s3.LoggingEnabled) s3err.ErrorCode {
+	targetBucket := aws.StringValue(loggingEnabled.TargetBucket)
+	if targetBucket == "" {
+		return s3err.ErrMalformedXML
+	}
+	sourceMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	targetMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(targetBucket)
+	if errCode == s3err.ErrNoSuchBucket {
+		return s3err.ErrInvalidTargetBucketForLogging
+	}
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	if bucketOwnerId(sourceMetadata) != bucketOwnerId(targetMetadata) {
+		return s3err.ErrInvalidTargetBucketForLogging
+	}
+	return s3err.ErrNone
+}
+
+func bucketOwnerId(bucketMetadata *BucketMetaData) string {
+	if bucketMetadata.Owner == nil {
+		return ""
+	}
+	return aws.StringValue(bucketMetadata.Owner.ID)
+}
@@ -0,0 +1,90 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFormatAccessLog(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/photos/puppy.jpg?versionId=1", nil)
+	r = mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": "photos/puppy.jpg"})
+	r.RemoteAddr = "192.0.2.3"
+	r.Header.Set("User-Agent", "aws-cli/2.0")
+	r.Header.Set(s3_constants.AmzIdentityId, "alice")
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=key/20130524/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc")
+
+	w := httptest.NewRecorder()
+	aw := &accessLogResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	aw.Header().Set("Content-Length", "5")
+	aw.Write([]byte("hello"))
+
+	fields := strings.Fields(formatAccessLog("owner_id", newAccessLog(r, aw)))
+	assert.Equal(t, "owner_id", fields[0])
+	assert.Equal(t, "bucket", fields[1])
+	assert.Equal(t, "192.0.2.3", fields[4])
+	assert.Equal(t, "alice", fields[5])
+	assert.Equal(t, "REST.GET.OBJECT", fields[7])
+	assert.Equal(t, "photos/puppy.jpg", fields[8])
+	assert.Equal(t, `"GET`, fields[9])
+	assert.Equal(t, "200", fields[12])
+	assert.Equal(t, "-", fields[13])
+	assert.Equal(t, "5", fields[14])
+	assert.Equal(t, "5", fields[15])
+	assert.Equal(t, `"aws-cli/2.0"`, fields[19])
+	assert.Equal(t, "AuthHeader", fields[24])
+}
+
+func TestAccessLogErrorCode(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/missing", nil)
+	r = mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": "missing"})
+	w := httptest.NewRecorder()
+	aw := &accessLogResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	s3err.WriteErrorResponse(aw, r, s3err.ErrNoSuchKey)
+
+	log := newAccessLog(r, aw)
+	assert.Equal(t, http.StatusNotFound, log.HTTPStatus)
+	assert.Equal(t, "NoSuchKey", log.ErrorCode)
+	assert.Empty(t, log.ObjectSize)
+}
+
+func TestAccessLogMiddlewareDropsClientIdentity(t *testing.T) {
+	var requester string
+	s3a := &S3ApiServer{}
+	handler := s3a.accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requester = r.Header.Get(s3_constants.AmzIdentityId)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/bucket/object", nil)
+	r.Header.Set(s3_constants.AmzIdentityId, "admin")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Empty(t, requester)
+}
+
+func TestGroupAccessLogs(t *testing.T) {
+	var buf []byte
+	for _, entry := range []*filer_pb.LogEntry{
+		{Key: []byte("a"), Data: []byte("line 1\n")},
+		{Key: []byte("b"), Data: []byte("line 2\n")},
+		{Key: []byte("a"), Data: []byte("line 3\n")},
+	} {
+		data, _ := proto.Marshal(entry)
+		size := make([]byte, 4)
+		util.Uint32toBytes(size, uint32(len(data)))
+		buf = append(buf, size...)
+		buf = append(buf, data...)
+	}
+
+	logs := groupAccessLogs(buf)
+	assert.Len(t, logs, 2)
+	assert.Equal(t, "line 1\nline 3\n", logs["a"].String())
+	assert.Equal(t, "line 2\n", logs["b"].String())
+}
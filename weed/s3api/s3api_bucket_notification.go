@@ -0,0 +1,512 @@
+package s3api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/notification"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/aws_sqs"
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/gocdk_pub_sub"
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/google_pub_sub"
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/kafka"
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/log"
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/webhook"
+)
+
+// Bucket event notifications are derived from the filer metadata changes under the buckets folder,
+// and sent as AWS compatible event json to the targets configured in notification.toml, such as
+//
+//	[notification.s3.my_hook]
+//	type = "webhook"
+//	url = "http://localhost:8080/events"
+//
+// The queue, topic or lambda function ARN of a bucket notification configuration refers to a target
+// by the last part of the ARN, e.g. "arn:aws:sqs:us-east-1:000000000000:my_hook".
+
+const (
+	notificationTargetsPrefix   = "notification.s3"
+	notificationLockName        = "s3.notification"
+	notificationOffsetKey       = "s3.notification.offset"
+	notificationOffsetInterval  = 10 * time.Second
+	workerLockCheckInterval     = time.Second
+	notificationMaxAttempts     = 3
+	notificationMaxFilterLength = 1024
+
+	notificationEventObjectCreated           = "s3:ObjectCreated:"
+	notificationEventObjectRemoved           = "s3:ObjectRemoved:"
+	notificationEventPut                     = "s3:ObjectCreated:Put"
+	notificationEventCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	notificationEventDelete                  = "s3:ObjectRemoved:Delete"
+	notificationEventDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+)
+
+var errWorkerLockLost = errors.New("worker lock is held by another gateway")
+
+var supportedNotificationEvents = map[string]bool{
+	notificationEventObjectCreated + "*":     true,
+	notificationEventPut:                     true,
+	notificationEventCompleteMultipartUpload: true,
+	notificationEventObjectRemoved + "*":     true,
+	notificationEventDelete:                  true,
+	notificationEventDeleteMarkerCreated:     true,
+}
+
+// notificationRule is a queue, topic or lambda function configuration of a bucket
+type notificationRule struct {
+	id     string
+	arn    string
+	events []*string
+	filter *s3.NotificationConfigurationFilter
+}
+
+func notificationRules(config *s3.NotificationConfiguration) (rules []notificationRule) {
+	if config == nil {
+		return nil
+	}
+	for _, c := range config.QueueConfigurations {
+		rules = append(rules, notificationRule{aws.StringValue(c.Id), aws.StringValue(c.QueueArn), c.Events, c.Filter})
+	}
+	for _, c := range config.TopicConfigurations {
+		rules = append(rules, notificationRule{aws.StringValue(c.Id), aws.StringValue(c.TopicArn), c.Events, c.Filter})
+	}
+	for _, c := range config.LambdaFunctionConfigurations {
+		rules = append(rules, notificationRule{aws.StringValue(c.Id), aws.StringValue(c.LambdaFunctionArn), c.Events, c.Filter})
+	}
+	return
+}
+
+// notificationTargetName is the name of the configured target an ARN refers to
+func notificationTargetName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// validateNotificationConfiguration checks the events and filters of each configuration, and that its target exists.
+// Configurations without an id are given one.
+func validateNotificationConfiguration(config *s3.NotificationConfiguration, targets map[string]notification.MessageQueue, skipDestinationValidation bool) s3err.ErrorCode {
+	if config.EventBridgeConfiguration != nil {
+		return s3err.ErrNotImplemented
+	}
+	ids := make(map[string]struct{})
+	for _, rule := range notificationRules(config) {
+		if rule.arn == "" || len(rule.events) == 0 {
+			return s3err.ErrMalformedXML
+		}
+		if _, found := targets[notificationTargetName(rule.arn)]; !found && !skipDestinationValidation {
+			return s3err.ErrInvalidNotificationConfiguration
+		}
+		for _, event := range rule.events {
+			if !supportedNotificationEvents[aws.StringValue(event)] {
+				return s3err.ErrInvalidNotificationConfiguration
+			}
+		}
+		if errCode := validateNotificationFilter(rule.filter); errCode != s3err.ErrNone {
+			return errCode
+		}
+		if rule.id != "" {
+			if _, found := ids[rule.id]; found {
+				return s3err.ErrInvalidNotificationConfiguration
+			}
+			ids[rule.id] = struct{}{}
+		}
+	}
+
+	setId := func(id **string) {
+		if aws.StringValue(*id) == "" {
+			*id = aws.String(uuid.NewString())
+		}
+	}
+	for _, c := range config.QueueConfigurations {
+		setId(&c.Id)
+	}
+	for _, c := range config.TopicConfigurations {
+		setId(&c.Id)
+	}
+	for _, c := range config.LambdaFunctionConfigurations {
+		setId(&c.Id)
+	}
+	return s3err.ErrNone
+}
+
+// validateNotificationFilter allows at most one prefix and one suffix rule
+func validateNotificationFilter(filter *s3.NotificationConfigurationFilter) s3err.ErrorCode {
+	if filter == nil || filter.Key == nil {
+		return s3err.ErrNone
+	}
+	names := make(map[string]struct{})
+	for _, rule := range filter.Key.FilterRules {
+		if rule == nil {
+			return s3err.ErrMalformedXML
+		}
+		name := strings.ToLower(aws.StringValue(rule.Name))
+		if name != "prefix" && name != "suffix" {
+			return s3err.ErrInvalidNotificationConfiguration
+		}
+		if _, found := names[name]; found {
+			return s3err.ErrInvalidNotificationConfiguration
+		}
+		if len(aws.StringValue(rule.Value)) > notificationMaxFilterLength {
+			return s3err.ErrInvalidNotificationConfiguration
+		}
+		names[name] = struct{}{}
+	}
+	return s3err.ErrNone
+}
+
+// matches checks the event name, such as "s3:ObjectCreated:Put", and the object key against the rule
+func (rule notificationRule) matches(eventName, key string) bool {
+	matched := false
+	for _, event := range rule.events {
+		e := aws.StringValue(event)
+		if e == eventName || strings.HasSuffix(e, ":*") && strings.HasPrefix(eventName, strings.TrimSuffix(e, "*")) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	if rule.filter == nil || rule.filter.Key == nil {
+		return true
+	}
+	for _, filterRule := range rule.filter.Key.FilterRules {
+		value := aws.StringValue(filterRule.Value)
+		switch strings.ToLower(aws.StringValue(filterRule.Name)) {
+		case "prefix":
+			if !strings.HasPrefix(key, value) {
+				return false
+			}
+		case "suffix":
+			if !strings.HasSuffix(key, value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bucketEvent is an object change in a bucket
+type bucketEvent struct {
+	name      string
+	bucket    string
+	key       string
+	versionId string
+	entry     *filer_pb.Entry
+	tsNs      int64
+}
+
+// notificationObject is the object a filer entry of a bucket stores,
+// either the current object or a version in the versions folder
+type notificationObject struct {
+	bucket     string
+	key        string
+	versionId  string
+	inVersions bool
+}
+
+func (s3a *S3ApiServer) toNotificationObject(dir string, entry *filer_pb.Entry) (object notificationObject, ok bool) {
+	if entry == nil || entry.IsDirectory {
+		return
+	}
+	relativePath, found := strings.CutPrefix(dir+"/"+entry.Name, s3a.option.BucketsPath+"/")
+	if !found {
+		return
+	}
+	bucket, key, found := strings.Cut(relativePath, "/")
+	if !found || key == "" {
+		return
+	}
+	if key == s3_constants.MultipartUploadsFolder || strings.HasPrefix(key, s3_constants.MultipartUploadsFolder+"/") {
+		return
+	}
+	object.bucket = bucket
+	if versionPath, isVersion := strings.CutPrefix(key, s3_constants.VersionsFolder+"/"); isVersion {
+		lastSlash := strings.LastIndex(versionPath, "/")
+		if lastSlash <= 0 {
+			return
+		}
+		object.key, object.versionId, object.inVersions = versionPath[:lastSlash], versionPath[lastSlash+1:], true
+		return object, true
+	}
+	object.key = key
+	if _, hasVersion := entry.Extended[s3_constants.ExtVersionIdKey]; hasVersion {
+		object.versionId = entryVersionId(entry)
+	}
+	return object, true
+}
+
+// toBucketEvents derives the bucket events of a filer metadata change.
+// Versioning moves the current object into the versions folder before it is overwritten or deleted,
+// these moves and the promotion of the previous version after a version is deleted are not events.
+func (s3a *S3ApiServer) toBucketEvents(resp *filer_pb.SubscribeMetadataResponse) (events []bucketEvent) {
+	message := resp.EventNotification
+	newDir := resp.Directory
+	if message.NewParentPath != "" {
+		newDir = message.NewParentPath
+	}
+	oldObject, hasOld := s3a.toNotificationObject(resp.Directory, message.OldEntry)
+	newObject, hasNew := s3a.toNotificationObject(newDir, message.NewEntry)
+	samePath := hasOld && hasNew && oldObject == newObject
+
+	if hasNew {
+		switch {
+		case newObject.inVersions:
+			if message.OldEntry == nil && isDeleteMarker(message.NewEntry) {
+				events = append(events, bucketEvent{notificationEventDeleteMarkerCreated, newObject.bucket, newObject.key, newObject.versionId, message.NewEntry, resp.TsNs})
+			}
+		case hasOld && oldObject.inVersions:
+			// the previous version becomes the current object
+		case !samePath || isObjectContentChanged(message.OldEntry, message.NewEntry):
+			eventName := notificationEventPut
+			if _, found := message.NewEntry.Extended[s3_constants.SeaweedFSUploadId]; found {
+				eventName = notificationEventCompleteMultipartUpload
+			}
+			events = append(events, bucketEvent{eventName, newObject.bucket, newObject.key, newObject.versionId, message.NewEntry, resp.TsNs})
+		}
+	}
+
+	if hasOld && !samePath {
+		archived := hasNew && newObject.inVersions && !oldObject.inVersions
+		promoted := hasNew && oldObject.inVersions
+		if !archived && !promoted {
+			events = append(events, bucketEvent{notificationEventDelete, oldObject.bucket, oldObject.key, oldObject.versionId, message.OldEntry, resp.TsNs})
+		}
+	}
+	return
+}
+
+func isObjectContentChanged(oldEntry, newEntry *filer_pb.Entry) bool {
+	if oldEntry.Attributes == nil || newEntry.Attributes == nil {
+		return true
+	}
+	return oldEntry.Attributes.Crtime != newEntry.Attributes.Crtime || filer.ETag(oldEntry) != filer.ETag(newEntry)
+}
+
+// s3EventRecords is the AWS S3 event message structure
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html
+type s3EventRecords struct {
+	Records []s3EventRecord `json:"Records"`
+}
+
+type s3EventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      s3EventIdentity   `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                s3EventEntity     `json:"s3"`
+}
+
+type s3EventIdentity struct {
+	PrincipalId string `json:"principalId"`
+}
+
+type s3EventEntity struct {
+	SchemaVersion   string        `json:"s3SchemaVersion"`
+	ConfigurationId string        `json:"configurationId"`
+	Bucket          s3EventBucket `json:"bucket"`
+	Object          s3EventObject `json:"object"`
+}
+
+type s3EventBucket struct {
+	Name          string          `json:"name"`
+	OwnerIdentity s3EventIdentity `json:"ownerIdentity"`
+	Arn           string          `json:"arn"`
+}
+
+type s3EventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionId string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+func newS3EventRecord(event bucketEvent, configurationId, bucketOwnerId string) s3EventRecord {
+	object := s3EventObject{
+		Key:       url.QueryEscape(event.key),
+		VersionId: event.versionId,
+		Sequencer: fmt.Sprintf("%016X", event.tsNs),
+	}
+	if !strings.HasPrefix(event.name, notificationEventObjectRemoved) {
+		object.Size = int64(filer.FileSize(event.entry))
+		object.ETag = filer.ETag(event.entry)
+	}
+	return s3EventRecord{
+		EventVersion:      "2.1",
+		EventSource:       "aws:s3",
+		AwsRegion:         "us-east-1",
+		EventTime:         time.Unix(0, event.tsNs).UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:         strings.TrimPrefix(event.name, "s3:"),
+		UserIdentity:      s3EventIdentity{PrincipalId: GetAcpOwner(event.entry.Extended, bucketOwnerId)},
+		RequestParameters: map[string]string{},
+		ResponseElements:  map[string]string{},
+		S3: s3EventEntity{
+			SchemaVersion:   "1.0",
+			ConfigurationId: configurationId,
+			Bucket: s3EventBucket{
+				Name:          event.bucket,
+				OwnerIdentity: s3EventIdentity{PrincipalId: bucketOwnerId},
+				Arn:           "arn:aws:s3:::" + event.bucket,
+			},
+			Object: object,
+		},
+	}
+}
+
+// startNotificationWorker follows the object changes of all buckets, and sends the events matching
+// the bucket notification configurations. Only the S3 gateway holding the filer lock follows the changes.
+func (s3a *S3ApiServer) startNotificationWorker() {
+	if len(s3a.notificationTargets) == 0 {
+		return
+	}
+	s3a.followBucketEventsWhileLocked("notification", notificationLockName, notificationOffsetKey, notificationOffsetInterval, s3a.sendBucketEvent)
+}
+
+// sendBucketEvent sends the event to the target of each matching configuration of the bucket
+func (s3a *S3ApiServer) sendBucketEvent(event bucketEvent) {
+	metadata, errCode := s3a.bucketRegistry.GetBucketMetadata(event.bucket)
+	if errCode != s3err.ErrNone || metadata.Notification == nil {
+		return
+	}
+	bucketOwnerId := aws.StringValue(metadata.Owner.ID)
+	for _, rule := range notificationRules(metadata.Notification) {
+		if !rule.matches(event.name, event.key) {
+			continue
+		}
+		target, found := s3a.notificationTargets[notificationTargetName(rule.arn)]
+		if !found {
+			glog.Warningf("bucket %s notification %s: unknown target %s", event.bucket, rule.id, rule.arn)
+			continue
+		}
+		data, err := json.Marshal(s3EventRecords{Records: []s3EventRecord{newS3EventRecord(event, rule.id, bucketOwnerId)}})
+		if err != nil {
+			glog.Errorf("marshal bucket %s event: %v", event.bucket, err)
+			continue
+		}
+		if err = sendWithRetry(target, event.bucket+"/"+event.key, data); err != nil {
+			glog.Errorf("send bucket %s event %s %s to %s: %v", event.bucket, event.name, event.key, rule.arn, err)
+		}
+	}
+}
+
+func sendWithRetry(target notification.MessageQueue, key string, data []byte) (err error) {
+	waitTime := time.Second
+	for attempt := 1; ; attempt++ {
+		if err = target.SendRawMessage(key, data); err == nil || attempt >= notificationMaxAttempts {
+			return err
+		}
+		time.Sleep(waitTime)
+		waitTime *= 2
+	}
+}
+
+// followBucketEventsWhileLocked follows the object changes of all buckets only while holding the filer lock.
+// Each time the lock is acquired, it resumes from the offset saved by the previous lock holder,
+// so the events are processed at least once across the gateways.
+func (s3a *S3ApiServer) followBucketEventsWhileLocked(name, lockName, offsetKey string, offsetInterval time.Duration, processFn func(event bucketEvent)) {
+	owner := fmt.Sprintf("%s:%d", util.DetectedHostAddress(), s3a.option.Port)
+	lockClient := cluster.NewLockClient(s3a.option.GrpcDialOption, s3a.option.Filer)
+	lock := lockClient.StartLongLivedLock(lockName, owner, func(newLockOwner string) {
+		glog.V(0).Infof("s3 %s worker is %s", name, newLockOwner)
+	})
+	isOwner := func() bool {
+		return lock.LockOwner() == owner
+	}
+
+	processEventFn := pb.AddOffsetFunc(func(resp *filer_pb.SubscribeMetadataResponse) error {
+		if !isOwner() {
+			return errWorkerLockLost
+		}
+		for _, event := range s3a.toBucketEvents(resp) {
+			processFn(event)
+		}
+		return nil
+	}, offsetInterval, func(counter int64, lastTsNs int64) error {
+		if err := s3a.saveWorkerOffset(offsetKey, lastTsNs); err != nil {
+			glog.Warningf("save s3 %s offset: %v", name, err)
+		}
+		return nil
+	})
+
+	metadataFollowOption := &pb.MetadataFollowOption{
+		ClientName:     "s3." + name,
+		ClientId:       s3a.randomClientId,
+		ClientEpoch:    1,
+		PathPrefix:     s3a.option.BucketsPath + "/",
+		EventErrorType: pb.ReturnOnError,
+	}
+	for {
+		for !isOwner() {
+			time.Sleep(workerLockCheckInterval)
+		}
+		startTsNs, err := s3a.readWorkerOffset(offsetKey)
+		if err != nil {
+			glog.Warningf("read s3 %s offset: %v", name, err)
+			time.Sleep(workerLockCheckInterval)
+			continue
+		}
+		if startTsNs == 0 {
+			startTsNs = time.Now().UnixNano()
+		}
+		metadataFollowOption.StartTsNs = startTsNs
+		for isOwner() {
+			metadataFollowOption.ClientEpoch++
+			err = pb.WithFilerClientFollowMetadata(s3a, metadataFollowOption, processEventFn)
+			glog.V(0).Infof("s3 %s follow metadata changes: %v", name, err)
+			time.Sleep(workerLockCheckInterval)
+		}
+	}
+}
+
+// readWorkerOffset reads the last processed metadata change of a worker from the filer key value store
+func (s3a *S3ApiServer) readWorkerOffset(key string) (offsetTsNs int64, err error) {
+	err = s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.KvGet(context.Background(), &filer_pb.KvGetRequest{Key: []byte(key)})
+		if err != nil {
+			return err
+		}
+		if len(resp.Error) != 0 {
+			return errors.New(resp.Error)
+		}
+		if len(resp.Value) >= 8 {
+			offsetTsNs = int64(util.BytesToUint64(resp.Value))
+		}
+		return nil
+	})
+	return
+}
+
+func (s3a *S3ApiServer) saveWorkerOffset(key string, offsetTsNs int64) error {
+	return s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		value := make([]byte, 8)
+		util.Uint64toBytes(value, uint64(offsetTsNs))
+		resp, err := client.KvPut(context.Background(), &filer_pb.KvPutRequest{Key: []byte(key), Value: value})
+		if err != nil {
+			return err
+		}
+		if len(resp.Error) != 0 {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,98 @@
+package s3api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// GetBucketNotificationConfigurationHandler Get bucket notification configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+func (s3a *S3ApiServer) GetBucketNotificationConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetBucketNotificationConfigurationHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchBucket)
+			return
+		}
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	// a bucket without notification has an empty configuration
+	var notificationConfiguration s3.NotificationConfiguration
+	if notificationBytes, found := bucketEntry.Extended[s3_constants.ExtNotificationKey]; found && len(notificationBytes) > 0 {
+		if err = json.Unmarshal(notificationBytes, &notificationConfiguration); err != nil {
+			glog.Errorf("unmarshal bucket %s notification: %v", bucket, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutBucketNotificationConfigurationInput{
+		NotificationConfiguration: &notificationConfiguration,
+	})
+}
+
+// PutBucketNotificationConfigurationHandler Put bucket notification configuration,
+// an empty configuration turns off the notifications of the bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+func (s3a *S3ApiServer) PutBucketNotificationConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutBucketNotificationConfigurationHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var notificationConfiguration s3.NotificationConfiguration
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&notificationConfiguration, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	skipDestinationValidation := strings.EqualFold(r.Header.Get("x-amz-skip-destination-validation"), "true")
+	if errCode := validateNotificationConfiguration(&notificationConfiguration, s3a.notificationTargets, skipDestinationValidation); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	var notificationBytes []byte
+	if len(notificationRules(&notificationConfiguration)) > 0 {
+		var err error
+		if notificationBytes, err = json.Marshal(&notificationConfiguration); err != nil {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+	}
+	if errCode := s3a.updateBucketConfig(bucket, s3_constants.ExtNotificationKey, notificationBytes); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
@@ -0,0 +1,185 @@
+package s3api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/notification"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseNotificationConfiguration(t *testing.T, body string) *s3.NotificationConfiguration {
+	var config s3.NotificationConfiguration
+	assert.NoError(t, xmlutil.UnmarshalXML(&config, xml.NewDecoder(strings.NewReader(body)), ""))
+	return &config
+}
+
+func TestValidateNotificationConfiguration(t *testing.T) {
+	targets := map[string]notification.MessageQueue{"hook": nil}
+	config := parseNotificationConfiguration(t, `<NotificationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <QueueConfiguration>
+    <Queue>arn:aws:sqs:us-east-1:000000000000:hook</Queue>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter><S3Key><FilterRule><Name>prefix</Name><Value>images/</Value></FilterRule><FilterRule><Name>Suffix</Name><Value>.jpg</Value></FilterRule></S3Key></Filter>
+  </QueueConfiguration>
+  <CloudFunctionConfiguration>
+    <Id>removed</Id>
+    <CloudFunction>arn:aws:lambda:us-east-1:000000000000:function:hook</CloudFunction>
+    <Event>s3:ObjectRemoved:Delete</Event>
+  </CloudFunctionConfiguration>
+</NotificationConfiguration>`)
+	assert.Equal(t, s3err.ErrNone, validateNotificationConfiguration(config, targets, false))
+	assert.NotEmpty(t, aws.StringValue(config.QueueConfigurations[0].Id), "an id is generated")
+	assert.Equal(t, "removed", aws.StringValue(config.LambdaFunctionConfigurations[0].Id))
+	assert.Len(t, notificationRules(config), 2)
+
+	config.QueueConfigurations[0].QueueArn = aws.String("arn:aws:sqs:us-east-1:000000000000:missing")
+	assert.Equal(t, s3err.ErrInvalidNotificationConfiguration, validateNotificationConfiguration(config, targets, false))
+	assert.Equal(t, s3err.ErrNone, validateNotificationConfiguration(config, targets, true))
+
+	config.QueueConfigurations[0].Events = []*string{aws.String("s3:ObjectRestore:Post")}
+	assert.Equal(t, s3err.ErrInvalidNotificationConfiguration, validateNotificationConfiguration(config, targets, true))
+
+	config.QueueConfigurations[0].Events = []*string{aws.String("s3:ObjectCreated:Put")}
+	config.QueueConfigurations[0].Filter.Key.FilterRules[1].Name = aws.String("prefix")
+	assert.Equal(t, s3err.ErrInvalidNotificationConfiguration, validateNotificationConfiguration(config, targets, true), "two prefix rules")
+
+	config.QueueConfigurations[0].Filter = nil
+	config.QueueConfigurations[0].Id = aws.String("removed")
+	assert.Equal(t, s3err.ErrInvalidNotificationConfiguration, validateNotificationConfiguration(config, targets, true), "duplicated id")
+
+	assert.Equal(t, s3err.ErrNone, validateNotificationConfiguration(&s3.NotificationConfiguration{}, targets, false))
+}
+
+func TestNotificationRuleMatches(t *testing.T) {
+	rule := notificationRule{
+		events: []*string{aws.String("s3:ObjectCreated:*"), aws.String("s3:ObjectRemoved:DeleteMarkerCreated")},
+		filter: &s3.NotificationConfigurationFilter{Key: &s3.KeyFilter{FilterRules: []*s3.FilterRule{
+			{Name: aws.String("Prefix"), Value: aws.String("images/")},
+			{Name: aws.String("suffix"), Value: aws.String(".jpg")},
+		}}},
+	}
+	assert.True(t, rule.matches(notificationEventPut, "images/a.jpg"))
+	assert.True(t, rule.matches(notificationEventCompleteMultipartUpload, "images/a.jpg"))
+	assert.True(t, rule.matches(notificationEventDeleteMarkerCreated, "images/a.jpg"))
+	assert.False(t, rule.matches(notificationEventDelete, "images/a.jpg"))
+	assert.False(t, rule.matches(notificationEventPut, "docs/a.jpg"))
+	assert.False(t, rule.matches(notificationEventPut, "images/a.png"))
+}
+
+func TestToBucketEvents(t *testing.T) {
+	s3a := &S3ApiServer{option: &S3ApiServerOption{BucketsPath: "/buckets"}}
+	object := func(name string, extended map[string]string) *filer_pb.Entry {
+		entry := &filer_pb.Entry{Name: name, Attributes: &filer_pb.FuseAttributes{Crtime: 1, Md5: []byte(name)}, Extended: map[string][]byte{}}
+		for k, v := range extended {
+			entry.Extended[k] = []byte(v)
+		}
+		return entry
+	}
+	eventNames := func(resp *filer_pb.SubscribeMetadataResponse) (names []string) {
+		for _, event := range s3a.toBucketEvents(resp) {
+			names = append(names, event.name+" "+event.bucket+"/"+event.key+"@"+event.versionId)
+		}
+		return
+	}
+
+	// put a new object
+	assert.Equal(t, []string{"s3:ObjectCreated:Put bucket/dir/a.txt@"}, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket/dir",
+		EventNotification: &filer_pb.EventNotification{NewEntry: object("a.txt", nil)},
+	}))
+	// only the tags of the object are changed
+	assert.Empty(t, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket/dir",
+		EventNotification: &filer_pb.EventNotification{OldEntry: object("a.txt", nil), NewEntry: object("a.txt", map[string]string{"X-Amz-Tagging-k": "v"})},
+	}))
+	// complete a multipart upload, and clean up its parts
+	assert.Equal(t, []string{"s3:ObjectCreated:CompleteMultipartUpload bucket/big@"}, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket",
+		EventNotification: &filer_pb.EventNotification{NewEntry: object("big", map[string]string{s3_constants.SeaweedFSUploadId: "upload"})},
+	}))
+	assert.Empty(t, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket/.uploads/upload",
+		EventNotification: &filer_pb.EventNotification{OldEntry: object("0001_1.part", nil)},
+	}))
+	// delete an object
+	assert.Equal(t, []string{"s3:ObjectRemoved:Delete bucket/dir/a.txt@"}, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket/dir",
+		EventNotification: &filer_pb.EventNotification{OldEntry: object("a.txt", nil)},
+	}))
+
+	// a versioned overwrite archives the current version before creating the new one
+	assert.Empty(t, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory: "/buckets/bucket",
+		EventNotification: &filer_pb.EventNotification{
+			OldEntry:      object("a.txt", map[string]string{s3_constants.ExtVersionIdKey: "v1"}),
+			NewEntry:      object("v1", map[string]string{s3_constants.ExtVersionIdKey: "v1"}),
+			NewParentPath: "/buckets/bucket/.versions/a.txt",
+		},
+	}))
+	assert.Equal(t, []string{"s3:ObjectCreated:Put bucket/a.txt@v2"}, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket",
+		EventNotification: &filer_pb.EventNotification{NewEntry: object("a.txt", map[string]string{s3_constants.ExtVersionIdKey: "v2"})},
+	}))
+	// a versioned delete creates a delete marker
+	assert.Equal(t, []string{"s3:ObjectRemoved:DeleteMarkerCreated bucket/dir/a.txt@v3"}, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket/.versions/dir/a.txt",
+		EventNotification: &filer_pb.EventNotification{NewEntry: object("v3", map[string]string{s3_constants.ExtDeleteMarkerKey: "true"})},
+	}))
+	// deleting a version, and promoting the previous version
+	assert.Equal(t, []string{"s3:ObjectRemoved:Delete bucket/a.txt@v1"}, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets/bucket/.versions/a.txt",
+		EventNotification: &filer_pb.EventNotification{OldEntry: object("v1", nil)},
+	}))
+	assert.Empty(t, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory: "/buckets/bucket/.versions/a.txt",
+		EventNotification: &filer_pb.EventNotification{
+			OldEntry:      object("v1", map[string]string{s3_constants.ExtVersionIdKey: "v1"}),
+			NewEntry:      object("a.txt", map[string]string{s3_constants.ExtVersionIdKey: "v1"}),
+			NewParentPath: "/buckets/bucket",
+		},
+	}))
+
+	// buckets and folders are not objects
+	assert.Empty(t, eventNames(&filer_pb.SubscribeMetadataResponse{
+		Directory:         "/buckets",
+		EventNotification: &filer_pb.EventNotification{NewEntry: &filer_pb.Entry{Name: "bucket", IsDirectory: true}},
+	}))
+}
+
+func TestNewS3EventRecord(t *testing.T) {
+	entry := &filer_pb.Entry{
+		Name:       "a b.txt",
+		Attributes: &filer_pb.FuseAttributes{FileSize: 5, Md5: []byte{0x01, 0x02}},
+		Extended:   map[string][]byte{s3_constants.ExtAmzOwnerKey: []byte("writer")},
+	}
+	event := bucketEvent{notificationEventPut, "bucket", "dir/a b.txt", "v1", entry, 1700000000123456789}
+	data, err := json.Marshal(s3EventRecords{Records: []s3EventRecord{newS3EventRecord(event, "config", "admin")}})
+	assert.NoError(t, err)
+
+	var records map[string][]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &records))
+	record := records["Records"][0]
+	assert.Equal(t, "2.1", record["eventVersion"])
+	assert.Equal(t, "aws:s3", record["eventSource"])
+	assert.Equal(t, "ObjectCreated:Put", record["eventName"])
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", record["eventTime"])
+	assert.Equal(t, map[string]interface{}{"principalId": "writer"}, record["userIdentity"])
+	s3Entity := record["s3"].(map[string]interface{})
+	assert.Equal(t, "config", s3Entity["configurationId"])
+	assert.Equal(t, "arn:aws:s3:::bucket", s3Entity["bucket"].(map[string]interface{})["arn"])
+	object := s3Entity["object"].(map[string]interface{})
+	assert.Equal(t, "dir%2Fa+b.txt", object["key"])
+	assert.Equal(t, float64(5), object["size"])
+	assert.Equal(t, "0102", object["eTag"])
+	assert.Equal(t, "v1", object["versionId"])
+	assert.Equal(t, "17979CFE3D85CD15", object["sequencer"])
+}
@@ -0,0 +1,729 @@
+package s3api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+)
+
+const (
+	policyVersion2012 = "2012-10-17"
+	policyVersion2008 = "2008-10-17"
+
+	policyEffectAllowName = "Allow"
+	policyEffectDenyName  = "Deny"
+
+	policyResourceArnPrefix = "arn:aws:s3:::"
+
+	// maxBucketPolicySize is the size limit AWS applies to bucket policies
+	maxBucketPolicySize = 20 * 1024
+)
+
+type policyEffect int
+
+const (
+	policyEffectNone policyEffect = iota
+	policyEffectAllow
+	policyEffectDeny
+)
+
+// BucketPolicyGetter looks up the parsed policy attached to a bucket, nil if there is none
+type BucketPolicyGetter interface {
+	GetBucketPolicy(bucket string) *BucketPolicy
+}
+
+// BucketPolicy is a resource based policy document attached to a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/bucket-policies.html
+type BucketPolicy struct {
+	Version   string
+	Id        string `json:",omitempty"`
+	Statement policyStatements
+}
+
+type PolicyStatement struct {
+	Sid         string `json:",omitempty"`
+	Effect      string
+	Principal   *policyPrincipal `json:",omitempty"`
+	Action      policyValues     `json:",omitempty"`
+	NotAction   policyValues     `json:",omitempty"`
+	Resource    policyValues     `json:",omitempty"`
+	NotResource policyValues     `json:",omitempty"`
+	// operator -> condition key -> values
+	Condition map[string]map[string]policyValues `json:",omitempty"`
+}
+
+// policyStatements accepts either a single statement or a list of statements
+type policyStatements []PolicyStatement
+
+func (s *policyStatements) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var statement PolicyStatement
+		if err := json.Unmarshal(data, &statement); err != nil {
+			return err
+		}
+		*s = policyStatements{statement}
+		return nil
+	}
+	var statements []PolicyStatement
+	if err := json.Unmarshal(data, &statements); err != nil {
+		return err
+	}
+	*s = statements
+	return nil
+}
+
+// policyValues accepts either a single scalar or a list of scalars
+type policyValues []string
+
+func (v *policyValues) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	if list, ok := raw.([]interface{}); ok {
+		values := make(policyValues, 0, len(list))
+		for _, item := range list {
+			value, err := policyScalar(item)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		*v = values
+		return nil
+	}
+	value, err := policyScalar(raw)
+	if err != nil {
+		return err
+	}
+	*v = policyValues{value}
+	return nil
+}
+
+func policyScalar(raw interface{}) (string, error) {
+	switch t := raw.(type) {
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case json.Number:
+		return t.String(), nil
+	}
+	return "", fmt.Errorf("unexpected policy value %v", raw)
+}
+
+// policyPrincipal is either "*" or a map such as {"AWS": ["arn:aws:iam::123456789012:user/alice"]}
+type policyPrincipal struct {
+	any       bool
+	aws       policyValues
+	canonical policyValues
+}
+
+func (p *policyPrincipal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != "*" {
+			return fmt.Errorf("invalid principal %q", wildcard)
+		}
+		p.any = true
+		return nil
+	}
+	var principals map[string]policyValues
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	for key, values := range principals {
+		switch key {
+		case "AWS":
+			p.aws = append(p.aws, values...)
+		case "CanonicalUser":
+			p.canonical = append(p.canonical, values...)
+		}
+	}
+	for _, value := range p.aws {
+		if value == "*" {
+			p.any = true
+		}
+	}
+	return nil
+}
+
+func (p *policyPrincipal) MarshalJSON() ([]byte, error) {
+	if p.any && len(p.aws) == 0 {
+		return json.Marshal("*")
+	}
+	principals := make(map[string]policyValues)
+	if len(p.aws) > 0 {
+		principals["AWS"] = p.aws
+	}
+	if len(p.canonical) > 0 {
+		principals["CanonicalUser"] = p.canonical
+	}
+	return json.Marshal(principals)
+}
+
+// policyRequest is what a bucket policy is evaluated against
+type policyRequest struct {
+	// principals identifying the requester, empty for anonymous requests
+	principals []string
+	action     string
+	resource   string
+	// condition keys in lower case
+	conditions map[string]string
+}
+
+func parseBucketPolicy(data []byte) (*BucketPolicy, error) {
+	var policy BucketPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// validate checks the policy is well-formed and only refers to the given bucket
+func (p *BucketPolicy) validate(bucket string) error {
+	if p.Version != policyVersion2012 && p.Version != policyVersion2008 {
+		return fmt.Errorf("unsupported policy version %q", p.Version)
+	}
+	if len(p.Statement) == 0 {
+		return fmt.Errorf("policy has no statement")
+	}
+	bucketArn := policyResourceArnPrefix + bucket
+	for i, statement := range p.Statement {
+		if statement.Effect != policyEffectAllowName && statement.Effect != policyEffectDenyName {
+			return fmt.Errorf("statement %d: invalid effect %q", i, statement.Effect)
+		}
+		if statement.Principal == nil {
+			return fmt.Errorf("statement %d: missing principal", i)
+		}
+		if (len(statement.Action) == 0) == (len(statement.NotAction) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Action and NotAction is required", i)
+		}
+		for _, action := range append(statement.Action, statement.NotAction...) {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return fmt.Errorf("statement %d: invalid action %q", i, action)
+			}
+		}
+		if (len(statement.Resource) == 0) == (len(statement.NotResource) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Resource and NotResource is required", i)
+		}
+		for _, resource := range append(statement.Resource, statement.NotResource...) {
+			if resource != bucketArn && !strings.HasPrefix(resource, bucketArn+"/") {
+				return fmt.Errorf("statement %d: resource %q is outside of bucket %s", i, resource, bucket)
+			}
+		}
+		for operator := range statement.Condition {
+			if !isKnownConditionOperator(operator) {
+				return fmt.Errorf("statement %d: unknown condition operator %q", i, operator)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluate returns policyEffectDeny if any matching statement denies the request,
+// policyEffectAllow if at least one matching statement allows it, and policyEffectNone otherwise.
+func (p *BucketPolicy) evaluate(req *policyRequest) policyEffect {
+	effect := policyEffectNone
+	for _, statement := range p.Statement {
+		if !statement.matches(req) {
+			continue
+		}
+		if statement.Effect == policyEffectDenyName {
+			return policyEffectDeny
+		}
+		effect = policyEffectAllow
+	}
+	return effect
+}
+
+func (s *PolicyStatement) matches(req *policyRequest) bool {
+	if req.action == "" {
+		// requests of unknown sub-resources are left to the identity actions
+		return false
+	}
+	if s.Principal == nil || !s.Principal.matches(req.principals) {
+		return false
+	}
+	if len(s.Action) > 0 && !matchAnyPolicyPattern(s.Action, req.action, true) {
+		return false
+	}
+	if len(s.NotAction) > 0 && matchAnyPolicyPattern(s.NotAction, req.action, true) {
+		return false
+	}
+	if len(s.Resource) > 0 && !matchAnyPolicyPattern(s.Resource, req.resource, false) {
+		return false
+	}
+	if len(s.NotResource) > 0 && matchAnyPolicyPattern(s.NotResource, req.resource, false) {
+		return false
+	}
+	for operator, conditions := range s.Condition {
+		for key, values := range conditions {
+			if !evaluatePolicyCondition(operator, key, values, req.conditions) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *policyPrincipal) matches(principals []string) bool {
+	if p.any {
+		return true
+	}
+	for _, principal := range principals {
+		if matchAnyPolicyPattern(p.aws, principal, false) || matchAnyPolicyPattern(p.canonical, principal, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyPolicyPattern(patterns []string, value string, ignoreCase bool) bool {
+	if ignoreCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if policyWildcardMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyWildcardMatch matches value against a pattern where '*' matches any
+// sequence of characters and '?' matches exactly one character
+func policyWildcardMatch(pattern, value string) bool {
+	p, v := 0, 0
+	starP, starV := -1, 0
+	for v < len(value) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]) {
+			p++
+			v++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			starP, starV = p, v
+			p++
+		} else if starP >= 0 {
+			starV++
+			p, v = starP+1, starV
+		} else {
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// negated condition operators and their positive counterparts
+var negatedConditionOperators = map[string]string{
+	"StringNotEquals":           "StringEquals",
+	"StringNotEqualsIgnoreCase": "StringEqualsIgnoreCase",
+	"StringNotLike":             "StringLike",
+	"NumericNotEquals":          "NumericEquals",
+	"DateNotEquals":             "DateEquals",
+	"NotIpAddress":              "IpAddress",
+	"ArnNotEquals":              "ArnEquals",
+	"ArnNotLike":                "ArnLike",
+}
+
+var positiveConditionOperators = map[string]struct{}{
+	"StringEquals":             {},
+	"StringEqualsIgnoreCase":   {},
+	"StringLike":               {},
+	"NumericEquals":            {},
+	"NumericLessThan":          {},
+	"NumericLessThanEquals":    {},
+	"NumericGreaterThan":       {},
+	"NumericGreaterThanEquals": {},
+	"DateEquals":               {},
+	"DateLessThan":             {},
+	"DateLessThanEquals":       {},
+	"DateGreaterThan":          {},
+	"DateGreaterThanEquals":    {},
+	"Bool":                     {},
+	"IpAddress":                {},
+	"ArnEquals":                {},
+	"ArnLike":                  {},
+}
+
+func isKnownConditionOperator(operator string) bool {
+	if operator == "Null" {
+		return true
+	}
+	operator = strings.TrimSuffix(operator, "IfExists")
+	if _, ok := positiveConditionOperators[operator]; ok {
+		return true
+	}
+	_, ok := negatedConditionOperators[operator]
+	return ok
+}
+
+func evaluatePolicyCondition(operator, key string, values policyValues, conditions map[string]string) bool {
+	requestValue, found := conditions[strings.ToLower(key)]
+	if operator == "Null" {
+		wantAbsent := len(values) > 0 && strings.EqualFold(values[0], "true")
+		return wantAbsent != found
+	}
+	ifExists := strings.HasSuffix(operator, "IfExists")
+	operator = strings.TrimSuffix(operator, "IfExists")
+	positive, negated := negatedConditionOperators[operator]
+	if !found {
+		return ifExists || negated
+	}
+	if !negated {
+		positive = operator
+	}
+	matched := false
+	for _, value := range values {
+		if matchPolicyConditionValue(positive, value, requestValue) {
+			matched = true
+			break
+		}
+	}
+	return matched != negated
+}
+
+func matchPolicyConditionValue(operator, policyValue, requestValue string) bool {
+	switch operator {
+	case "StringEquals", "ArnEquals":
+		return policyValue == requestValue
+	case "StringEqualsIgnoreCase":
+		return strings.EqualFold(policyValue, requestValue)
+	case "StringLike", "ArnLike":
+		return policyWildcardMatch(policyValue, requestValue)
+	case "Bool":
+		return strings.EqualFold(policyValue, requestValue)
+	case "IpAddress":
+		return matchPolicyIpAddress(policyValue, requestValue)
+	}
+	if strings.HasPrefix(operator, "Numeric") {
+		expected, err1 := strconv.ParseFloat(policyValue, 64)
+		actual, err2 := strconv.ParseFloat(requestValue, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		return compareOrdered(strings.TrimPrefix(operator, "Numeric"), actual-expected)
+	}
+	if strings.HasPrefix(operator, "Date") {
+		expected, ok1 := parsePolicyTime(policyValue)
+		actual, ok2 := parsePolicyTime(requestValue)
+		if !ok1 || !ok2 {
+			return false
+		}
+		return compareOrdered(strings.TrimPrefix(operator, "Date"), float64(actual.Sub(expected)))
+	}
+	return false
+}
+
+// compareOrdered interprets the sign of actual minus expected for a comparison
+func compareOrdered(comparison string, diff float64) bool {
+	switch comparison {
+	case "Equals":
+		return diff == 0
+	case "LessThan":
+		return diff < 0
+	case "LessThanEquals":
+		return diff <= 0
+	case "GreaterThan":
+		return diff > 0
+	case "GreaterThanEquals":
+		return diff >= 0
+	}
+	return false
+}
+
+func matchPolicyIpAddress(cidr, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	if !strings.Contains(cidr, "/") {
+		expected := net.ParseIP(cidr)
+		return expected != nil && expected.Equal(ip)
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	return network.Contains(ip)
+}
+
+func parsePolicyTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
+
+// newPolicyRequest collects the action, resource and condition keys of an S3 request
+func newPolicyRequest(r *http.Request, identity *Identity, bucket, object string) *policyRequest {
+	resource := policyResourceArnPrefix + bucket
+	if object != "/" {
+		resource += object
+	}
+	req := &policyRequest{
+		principals: policyPrincipals(identity),
+		action:     policyActionOf(r, object),
+		resource:   resource,
+		conditions: make(map[string]string),
+	}
+
+	now := time.Now().UTC()
+	req.conditions["aws:currenttime"] = now.Format(time.RFC3339)
+	req.conditions["aws:epochtime"] = strconv.FormatInt(now.Unix(), 10)
+	// only the connection itself is trusted, forwarding headers can be set by anyone
+	req.conditions["aws:securetransport"] = strconv.FormatBool(r.TLS != nil)
+	sourceIp := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		sourceIp = host
+	}
+	req.conditions["aws:sourceip"] = sourceIp
+	if userAgent := r.Header.Get("User-Agent"); userAgent != "" {
+		req.conditions["aws:useragent"] = userAgent
+	}
+	if referer := r.Header.Get("Referer"); referer != "" {
+		req.conditions["aws:referer"] = referer
+	}
+	if identity != nil && !identity.isAnonymous() {
+		req.conditions["aws:username"] = identity.Name
+		req.conditions["aws:userid"] = identity.Name
+		req.conditions["aws:principalaccount"] = identity.Account.Id
+	}
+
+	query := r.URL.Query()
+	for _, key := range []string{"prefix", "delimiter", "max-keys", "versionId"} {
+		if _, ok := query[key]; ok {
+			req.conditions["s3:"+strings.ToLower(key)] = query.Get(key)
+		}
+	}
+	for _, header := range []string{
+		s3_constants.AmzCannedAcl,
+		s3_constants.AmzStorageClass,
+		s3_constants.AmzUserMetaDirective,
+		"X-Amz-Copy-Source",
+		"X-Amz-Server-Side-Encryption",
+		"X-Amz-Content-Sha256",
+	} {
+		if value := r.Header.Get(header); value != "" {
+			req.conditions["s3:"+strings.ToLower(header)] = value
+		}
+	}
+	return req
+}
+
+func policyPrincipals(identity *Identity) []string {
+	if identity == nil || identity.Account == nil || identity.isAnonymous() {
+		return nil
+	}
+	accountId := identity.Account.Id
+	if identity.RoleSessionName != "" {
+		return []string{
+			identity.Name,
+			accountId,
+			"arn:aws:iam::" + accountId + ":root",
+			"arn:aws:iam::" + accountId + ":role/" + identity.Name,
+			"arn:aws:sts::" + accountId + ":assumed-role/" + identity.Name + "/" + identity.RoleSessionName,
+		}
+	}
+	return []string{
+		identity.Name,
+		accountId,
+		"arn:aws:iam::" + accountId + ":root",
+		"arn:aws:iam::" + accountId + ":user/" + identity.Name,
+	}
+}
+
+// policySubResourceAction maps a sub-resource query parameter to the policy action it requires.
+// The query can list several parameters separated by "&", which all have to be present.
+type policySubResourceAction struct {
+	query  string
+	action string
+}
+
+// policyPlainQueryParameters are the query parameters which are not sub-resources,
+// besides the "X-Amz-" signature and the "response-" header override parameters
+var policyPlainQueryParameters = map[string]bool{
+	"prefix":             true,
+	"delimiter":          true,
+	"marker":             true,
+	"max-keys":           true,
+	"encoding-type":      true,
+	"list-type":          true,
+	"continuation-token": true,
+	"fetch-owner":        true,
+	"start-after":        true,
+	"key-marker":         true,
+	"version-id-marker":  true,
+	"upload-id-marker":   true,
+	"max-uploads":        true,
+	"max-parts":          true,
+	"part-number-marker": true,
+	"partNumber":         true,
+	"uploadId":           true,
+	"versionId":          true,
+	"x-id":               true,
+	"AWSAccessKeyId":     true,
+	"Signature":          true,
+	"Expires":            true,
+}
+
+var (
+	policyObjectActions = map[string][]policySubResourceAction{
+		http.MethodGet: {
+			{"acl", "s3:GetObjectAcl"},
+			{"tagging", "s3:GetObjectTagging"},
+			{"uploadId", "s3:ListMultipartUploadParts"},
+			{"retention", "s3:GetObjectRetention"},
+			{"legal-hold", "s3:GetObjectLegalHold"},
+			{"versionId", "s3:GetObjectVersion"},
+			{"", "s3:GetObject"},
+		},
+		http.MethodPut: {
+			{"acl", "s3:PutObjectAcl"},
+			{"tagging", "s3:PutObjectTagging"},
+			{"retention", "s3:PutObjectRetention"},
+			{"legal-hold", "s3:PutObjectLegalHold"},
+			{"", "s3:PutObject"},
+		},
+		http.MethodPost: {
+			{"select", "s3:GetObject"},
+			{"uploads", "s3:PutObject"},
+			{"", "s3:PutObject"},
+		},
+		http.MethodDelete: {
+			{"tagging", "s3:DeleteObjectTagging"},
+			{"uploadId", "s3:AbortMultipartUpload"},
+			{"versionId", "s3:DeleteObjectVersion"},
+			{"", "s3:DeleteObject"},
+		},
+	}
+	policyBucketActions = map[string][]policySubResourceAction{
+		http.MethodGet: {
+			{"acl", "s3:GetBucketAcl"},
+			{"policy", "s3:GetBucketPolicy"},
+			{"cors", "s3:GetBucketCORS"},
+			{"lifecycle", "s3:GetLifecycleConfiguration"},
+			{"versioning", "s3:GetBucketVersioning"},
+			{"versions", "s3:ListBucketVersions"},
+			{"uploads", "s3:ListBucketMultipartUploads"},
+			{"tagging", "s3:GetBucketTagging"},
+			{"object-lock", "s3:GetBucketObjectLockConfiguration"},
+			{"location", "s3:GetBucketLocation"},
+			{"requestPayment", "s3:GetBucketRequestPayment"},
+			{"notification", "s3:GetBucketNotification"},
+			{"website", "s3:GetBucketWebsite"},
+			{"replication", "s3:GetReplicationConfiguration"},
+			{"transformation", "s3:GetBucketTransformation"},
+			{"logging", "s3:GetBucketLogging"},
+			{"inventory", "s3:GetInventoryConfiguration"},
+			{"batch-job&id", "s3:DescribeJob"},
+			{"batch-job", "s3:ListJobs"},
+			{"ownershipControls", "s3:GetBucketOwnershipControls"},
+			{"", "s3:ListBucket"},
+		},
+		http.MethodPut: {
+			{"acl", "s3:PutBucketAcl"},
+			{"policy", "s3:PutBucketPolicy"},
+			{"cors", "s3:PutBucketCORS"},
+			{"lifecycle", "s3:PutLifecycleConfiguration"},
+			{"versioning", "s3:PutBucketVersioning"},
+			{"tagging", "s3:PutBucketTagging"},
+			{"object-lock", "s3:PutBucketObjectLockConfiguration"},
+			{"notification", "s3:PutBucketNotification"},
+			{"website", "s3:PutBucketWebsite"},
+			{"replication", "s3:PutReplicationConfiguration"},
+			{"transformation", "s3:PutBucketTransformation"},
+			{"logging", "s3:PutBucketLogging"},
+			{"inventory", "s3:PutInventoryConfiguration"},
+			{"ownershipControls", "s3:PutBucketOwnershipControls"},
+			{"", "s3:CreateBucket"},
+		},
+		http.MethodPost: {
+			{"delete", "s3:DeleteObject"},
+			{"batch-job", "s3:CreateJob"},
+			{"", "s3:PutObject"},
+		},
+		http.MethodDelete: {
+			{"policy", "s3:DeleteBucketPolicy"},
+			{"cors", "s3:PutBucketCORS"},
+			{"lifecycle", "s3:PutLifecycleConfiguration"},
+			{"tagging", "s3:PutBucketTagging"},
+			{"website", "s3:DeleteBucketWebsite"},
+			{"replication", "s3:PutReplicationConfiguration"},
+			{"transformation", "s3:PutBucketTransformation"},
+			{"inventory", "s3:PutInventoryConfiguration"},
+			{"batch-job", "s3:UpdateJobStatus"},
+			{"ownershipControls", "s3:PutBucketOwnershipControls"},
+			{"", "s3:DeleteBucket"},
+		},
+	}
+)
+
+// policyActionOf derives the policy action name, e.g. "s3:GetObject", from the request
+func policyActionOf(r *http.Request, object string) string {
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	actions := policyBucketActions[method]
+	if object != "/" {
+		actions = policyObjectActions[method]
+	}
+	query := r.URL.Query()
+	for _, a := range actions {
+		if a.query == "" {
+			if hasUnknownSubResource(query) {
+				// not granted or denied by the policies as another action
+				return ""
+			}
+			return a.action
+		}
+		if hasQueryParameters(query, a.query) {
+			return a.action
+		}
+	}
+	return ""
+}
+
+func hasQueryParameters(query url.Values, names string) bool {
+	for _, name := range strings.Split(names, "&") {
+		if _, ok := query[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasUnknownSubResource(query url.Values) bool {
+	for name := range query {
+		if policyPlainQueryParameters[name] ||
+			strings.HasPrefix(strings.ToLower(name), "x-amz-") ||
+			strings.HasPrefix(name, "response-") {
+			continue
+		}
+		return true
+	}
+	return false
+}
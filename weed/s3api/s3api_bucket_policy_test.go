@@ -0,0 +1,221 @@
+package s3api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBucketPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "PublicRead",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:GetObject"],
+      "Resource": "arn:aws:s3:::bucket/public/*"
+    },
+    {
+      "Sid": "ListPublicPrefix",
+      "Effect": "Allow",
+      "Principal": {"AWS": "*"},
+      "Action": "s3:ListBucket",
+      "Resource": "arn:aws:s3:::bucket",
+      "Condition": {"StringLike": {"s3:prefix": "public/*"}}
+    },
+    {
+      "Sid": "AliceWrite",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::123456789012:user/alice"]},
+      "Action": "s3:Put*",
+      "Resource": "arn:aws:s3:::bucket/*"
+    },
+    {
+      "Sid": "DenyOutsideOffice",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"],
+      "Condition": {"NotIpAddress": {"aws:SourceIp": ["10.0.0.0/8", "127.0.0.1"]}}
+    }
+  ]
+}`
+
+func newTestPolicyRequest(method, target, remoteAddr string, identity *Identity, bucket, object string) *policyRequest {
+	r := httptest.NewRequest(method, target, nil)
+	r.RemoteAddr = remoteAddr
+	return newPolicyRequest(r, identity, bucket, object)
+}
+
+func TestBucketPolicyEvaluate(t *testing.T) {
+	policy, err := parseBucketPolicy([]byte(testBucketPolicy))
+	assert.NoError(t, err)
+	assert.NoError(t, policy.validate("bucket"))
+
+	alice := &Identity{Name: "alice", Account: &Account{Id: "123456789012"}}
+	bob := &Identity{Name: "bob", Account: &Account{Id: "123456789012"}}
+	anonymous := &Identity{Account: &AccountAnonymous}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		remote   string
+		identity *Identity
+		object   string
+		expected policyEffect
+	}{
+		{"anonymous public read", http.MethodGet, "/bucket/public/a.txt", "10.1.2.3:1234", anonymous, "/public/a.txt", policyEffectAllow},
+		{"anonymous private read", http.MethodGet, "/bucket/private/a.txt", "10.1.2.3:1234", anonymous, "/private/a.txt", policyEffectNone},
+		{"anonymous head is read", http.MethodHead, "/bucket/public/a.txt", "10.1.2.3:1234", anonymous, "/public/a.txt", policyEffectAllow},
+		{"list with public prefix", http.MethodGet, "/bucket?prefix=public/x", "10.1.2.3:1234", anonymous, "/", policyEffectAllow},
+		{"list without prefix", http.MethodGet, "/bucket", "10.1.2.3:1234", anonymous, "/", policyEffectNone},
+		{"alice put", http.MethodPut, "/bucket/a.txt", "127.0.0.1:1234", alice, "/a.txt", policyEffectAllow},
+		{"bob put", http.MethodPut, "/bucket/a.txt", "127.0.0.1:1234", bob, "/a.txt", policyEffectNone},
+		{"alice put tagging", http.MethodPut, "/bucket/a.txt?tagging", "127.0.0.1:1234", alice, "/a.txt", policyEffectAllow},
+		{"alice delete", http.MethodDelete, "/bucket/a.txt", "127.0.0.1:1234", alice, "/a.txt", policyEffectNone},
+		{"outside network denied", http.MethodPut, "/bucket/a.txt", "192.168.1.1:1234", alice, "/a.txt", policyEffectDeny},
+		{"outside network public read denied", http.MethodGet, "/bucket/public/a.txt", "192.168.1.1:1234", anonymous, "/public/a.txt", policyEffectDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestPolicyRequest(tt.method, tt.target, tt.remote, tt.identity, "bucket", tt.object)
+			assert.Equal(t, tt.expected, policy.evaluate(req))
+		})
+	}
+}
+
+func TestBucketPolicyDenyGetObjectBlocksSelect(t *testing.T) {
+	policy, err := parseBucketPolicy([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::bucket/*"},
+    {"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}
+  ]
+}`))
+	assert.NoError(t, err)
+	anonymous := &Identity{Account: &AccountAnonymous}
+
+	req := newTestPolicyRequest(http.MethodPost, "/bucket/a.csv?select&select-type=2", "10.1.2.3:1234", anonymous, "bucket", "/a.csv")
+	assert.Equal(t, "s3:GetObject", req.action)
+	assert.Equal(t, policyEffectDeny, policy.evaluate(req))
+}
+
+func TestPolicyActionOfSubResources(t *testing.T) {
+	tests := []struct {
+		method   string
+		target   string
+		object   string
+		expected string
+	}{
+		{http.MethodGet, "/bucket?list-type=2&prefix=a&X-Amz-Signature=x", "/", "s3:ListBucket"},
+		{http.MethodGet, "/bucket?notification", "/", "s3:GetBucketNotification"},
+		{http.MethodPut, "/bucket?notification", "/", "s3:PutBucketNotification"},
+		{http.MethodGet, "/bucket?website", "/", "s3:GetBucketWebsite"},
+		{http.MethodDelete, "/bucket?replication", "/", "s3:PutReplicationConfiguration"},
+		{http.MethodPut, "/bucket?logging", "/", "s3:PutBucketLogging"},
+		{http.MethodGet, "/bucket?inventory&id=a", "/", "s3:GetInventoryConfiguration"},
+		{http.MethodGet, "/bucket?batch-job&id=a", "/", "s3:DescribeJob"},
+		{http.MethodGet, "/bucket?batch-job", "/", "s3:ListJobs"},
+		{http.MethodPost, "/bucket?batch-job", "/", "s3:CreateJob"},
+		{http.MethodGet, "/bucket?unknown", "/", ""},
+		{http.MethodPut, "/bucket?unknown", "/", ""},
+		{http.MethodGet, "/bucket/a.txt?response-content-type=text/plain", "/a.txt", "s3:GetObject"},
+		{http.MethodPut, "/bucket/a.txt?partNumber=1&uploadId=x", "/a.txt", "s3:PutObject"},
+		{http.MethodGet, "/bucket/a.txt?unknown", "/a.txt", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		assert.Equal(t, tt.expected, policyActionOf(r, tt.object), "%s %s", tt.method, tt.target)
+	}
+
+	// unknown sub-resources are not granted by a public list policy
+	policy, err := parseBucketPolicy([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::bucket"},
+    {"Effect": "Allow", "Principal": "*", "NotAction": "s3:DeleteBucket", "Resource": "arn:aws:s3:::bucket"}
+  ]
+}`))
+	assert.NoError(t, err)
+	anonymous := &Identity{Account: &AccountAnonymous}
+	req := newTestPolicyRequest(http.MethodGet, "/bucket?unknown", "10.1.2.3:1234", anonymous, "bucket", "/")
+	assert.Equal(t, policyEffectNone, policy.evaluate(req))
+}
+
+func TestBucketPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		valid  bool
+	}{
+		{"single statement", `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`, true},
+		{"bad version", `{"Version":"2020-01-01","Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`, false},
+		{"bad effect", `{"Version":"2012-10-17","Statement":{"Effect":"Maybe","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`, false},
+		{"missing principal", `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}}`, false},
+		{"other bucket", `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket2/*"}}`, false},
+		{"non s3 action", `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":"iam:CreateUser","Resource":"arn:aws:s3:::bucket/*"}}`, false},
+		{"unknown operator", `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*","Condition":{"Sometimes":{"aws:SourceIp":"1.2.3.4"}}}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := parseBucketPolicy([]byte(tt.policy))
+			if err == nil {
+				err = policy.validate("bucket")
+			}
+			assert.Equal(t, tt.valid, err == nil, "%v", err)
+		})
+	}
+}
+
+func TestEvaluatePolicyCondition(t *testing.T) {
+	conditions := map[string]string{
+		"aws:sourceip":        "192.168.1.10",
+		"aws:securetransport": "false",
+		"s3:max-keys":         "100",
+		"aws:currenttime":     "2023-06-01T00:00:00Z",
+	}
+	tests := []struct {
+		operator string
+		key      string
+		values   policyValues
+		expected bool
+	}{
+		{"IpAddress", "aws:SourceIp", policyValues{"192.168.1.0/24"}, true},
+		{"NotIpAddress", "aws:SourceIp", policyValues{"192.168.1.0/24"}, false},
+		{"Bool", "aws:SecureTransport", policyValues{"false"}, true},
+		{"NumericLessThanEquals", "s3:max-keys", policyValues{"100"}, true},
+		{"NumericGreaterThan", "s3:max-keys", policyValues{"100"}, false},
+		{"DateLessThan", "aws:CurrentTime", policyValues{"2024-01-01T00:00:00Z"}, true},
+		{"StringEquals", "s3:prefix", policyValues{"a"}, false},
+		{"StringEqualsIfExists", "s3:prefix", policyValues{"a"}, true},
+		{"StringNotEquals", "s3:prefix", policyValues{"a"}, true},
+		{"Null", "s3:prefix", policyValues{"true"}, true},
+		{"Null", "aws:SourceIp", policyValues{"true"}, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, evaluatePolicyCondition(tt.operator, tt.key, tt.values, conditions), "%s %s %v", tt.operator, tt.key, tt.values)
+	}
+}
+
+func TestPolicyRequestSecureTransport(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/a", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, "false", newPolicyRequest(r, &Identity{Account: &AccountAnonymous}, "bucket", "/a").conditions["aws:securetransport"])
+
+	r.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "true", newPolicyRequest(r, &Identity{Account: &AccountAnonymous}, "bucket", "/a").conditions["aws:securetransport"])
+}
+
+func TestPolicyWildcardMatch(t *testing.T) {
+	assert.True(t, policyWildcardMatch("arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket/a/b"))
+	assert.True(t, policyWildcardMatch("s3:get*", "s3:getobject"))
+	assert.True(t, policyWildcardMatch("a?c", "abc"))
+	assert.True(t, policyWildcardMatch("*", ""))
+	assert.False(t, policyWildcardMatch("arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket"))
+	assert.False(t, policyWildcardMatch("a?c", "ac"))
+}
@@ -0,0 +1,428 @@
+package s3api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/google/uuid"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Bucket replication copies the new objects of a bucket to a bucket on another S3 endpoint, such as
+// another SeaweedFS cluster, following the filer metadata changes like the bucket event notifications.
+// The destination endpoints are configured in replication.toml, such as
+//
+//	[s3.replication.dr_site]
+//	endpoint = "http://dr-site:8333"
+//	region = "us-east-1"
+//	aws_access_key_id = "some_access_key"
+//	aws_secret_access_key = "some_secret_key"
+//
+// The role ARN of a bucket replication configuration refers to an endpoint by the last part of the ARN,
+// e.g. "arn:aws:iam::000000000000:role/dr_site", and the destination bucket ARN names the bucket there.
+// The copies are marked as replicas, so that replicating back does not loop, which the destination only
+// accepts from credentials allowed s3:ReplicateObject.
+
+const (
+	replicationTargetsPrefix   = "s3.replication"
+	replicationLockName        = "s3.replication"
+	replicationOffsetKey       = "s3.replication.offset"
+	replicationOffsetInterval  = 10 * time.Second
+	replicationMaxRetries      = 3
+	replicationMaxRules        = 1000
+	replicationMaxRuleIdLength = 255
+	replicationBucketArnPrefix = "arn:aws:s3:::"
+)
+
+// replicationTarget is a destination S3 endpoint
+type replicationTarget struct {
+	client   s3iface.S3API
+	uploader *s3manager.Uploader
+}
+
+// loadReplicationTargets creates a client for each table under the prefix, named by the table name
+func loadReplicationTargets(config *util.ViperProxy, prefix string) (map[string]*replicationTarget, error) {
+	targets := make(map[string]*replicationTarget)
+	if config == nil {
+		return targets, nil
+	}
+	for name := range config.GetStringMap(prefix) {
+		target, err := newReplicationTarget(config, prefix+"."+name+".")
+		if err != nil {
+			return nil, fmt.Errorf("initialize %s.%s: %v", prefix, name, err)
+		}
+		glog.V(0).Infof("Configure s3 replication target %s", name)
+		targets[name] = target
+	}
+	return targets, nil
+}
+
+func newReplicationTarget(configuration util.Configuration, prefix string) (*replicationTarget, error) {
+	configuration.SetDefault(prefix+"region", "us-east-1")
+	configuration.SetDefault(prefix+"s3_force_path_style", true)
+	endpoint := configuration.GetString(prefix + "endpoint")
+	if endpoint == "" {
+		return nil, fmt.Errorf("missing endpoint")
+	}
+	config := &aws.Config{
+		Region:           aws.String(configuration.GetString(prefix + "region")),
+		Endpoint:         aws.String(endpoint),
+		S3ForcePathStyle: aws.Bool(configuration.GetBool(prefix + "s3_force_path_style")),
+		MaxRetries:       aws.Int(replicationMaxRetries),
+	}
+	accessKeyId, secretAccessKey := configuration.GetString(prefix+"aws_access_key_id"), configuration.GetString(prefix+"aws_secret_access_key")
+	if accessKeyId != "" && secretAccessKey != "" {
+		config.Credentials = credentials.NewStaticCredentials(accessKeyId, secretAccessKey, "")
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("create aws session: %v", err)
+	}
+	client := s3.New(sess)
+	return &replicationTarget{
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+	}, nil
+}
+
+// isReplicaWrite checks that the request marks the object it writes as a replica, which bucket replication
+// then skips, and is allowed to. Only the credentials a replication source signs with, granted
+// s3:ReplicateObject, may do so; the header of other requests is dropped.
+func (s3a *S3ApiServer) isReplicaWrite(r *http.Request, bucket, object string) bool {
+	if r.Header.Get(s3_constants.AmzReplicationStatus) == "" {
+		return false
+	}
+	return s3a.iam.isAuthorizedFor(r, s3_constants.ACTION_ADMIN, "s3:ReplicateObject", bucket, object)
+}
+
+// replicationTargetName is the name of the configured target a role ARN refers to
+func replicationTargetName(roleArn string) string {
+	return roleArn[strings.LastIndexAny(roleArn, ":/")+1:]
+}
+
+// validateReplicationConfiguration checks the rules and that the target of the role exists.
+// Rules without an id are given one.
+func validateReplicationConfiguration(config *s3.ReplicationConfiguration, targets map[string]*replicationTarget) s3err.ErrorCode {
+	role := aws.StringValue(config.Role)
+	if role == "" {
+		return s3err.ErrMalformedXML
+	}
+	if _, found := targets[replicationTargetName(role)]; !found {
+		return s3err.ErrInvalidRequest
+	}
+	if len(config.Rules) == 0 || len(config.Rules) > replicationMaxRules {
+		return s3err.ErrMalformedXML
+	}
+	ids := make(map[string]struct{})
+	priorities := make(map[int64]struct{})
+	for _, rule := range config.Rules {
+		if rule == nil {
+			return s3err.ErrMalformedXML
+		}
+		status := aws.StringValue(rule.Status)
+		if status != s3.ReplicationRuleStatusEnabled && status != s3.ReplicationRuleStatusDisabled {
+			return s3err.ErrMalformedXML
+		}
+		if rule.Destination == nil || !strings.HasPrefix(aws.StringValue(rule.Destination.Bucket), replicationBucketArnPrefix) ||
+			aws.StringValue(rule.Destination.Bucket) == replicationBucketArnPrefix {
+			return s3err.ErrInvalidRequest
+		}
+		if rule.DeleteMarkerReplication != nil {
+			status := aws.StringValue(rule.DeleteMarkerReplication.Status)
+			if status != s3.DeleteMarkerReplicationStatusEnabled && status != s3.DeleteMarkerReplicationStatusDisabled {
+				return s3err.ErrMalformedXML
+			}
+		}
+		if rule.Filter != nil {
+			if errCode := validateReplicationRuleFilter(rule); errCode != s3err.ErrNone {
+				return errCode
+			}
+			if _, found := priorities[*rule.Priority]; found {
+				return s3err.ErrInvalidRequest
+			}
+			priorities[*rule.Priority] = struct{}{}
+		}
+		id := aws.StringValue(rule.ID)
+		if len(id) > replicationMaxRuleIdLength {
+			return s3err.ErrInvalidRequest
+		}
+		if id != "" {
+			if _, found := ids[id]; found {
+				return s3err.ErrInvalidRequest
+			}
+			ids[id] = struct{}{}
+		}
+	}
+
+	for _, rule := range config.Rules {
+		if aws.StringValue(rule.ID) == "" {
+			rule.ID = aws.String(uuid.NewString())
+		}
+	}
+	return s3err.ErrNone
+}
+
+// validateReplicationRuleFilter checks a rule using a filter instead of the deprecated prefix.
+// Such a rule needs a priority, and delete markers cannot be replicated by tags.
+func validateReplicationRuleFilter(rule *s3.ReplicationRule) s3err.ErrorCode {
+	filter := rule.Filter
+	if rule.Prefix != nil || rule.Priority == nil {
+		return s3err.ErrMalformedXML
+	}
+	conditions := 0
+	for _, set := range []bool{filter.Prefix != nil, filter.Tag != nil, filter.And != nil} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions > 1 {
+		return s3err.ErrMalformedXML
+	}
+	var tags []*s3.Tag
+	if filter.Tag != nil {
+		tags = append(tags, filter.Tag)
+	}
+	if filter.And != nil {
+		tags = append(tags, filter.And.Tags...)
+	}
+	keys := make(map[string]struct{})
+	for _, tag := range tags {
+		if tag == nil || aws.StringValue(tag.Key) == "" {
+			return s3err.ErrMalformedXML
+		}
+		if _, found := keys[*tag.Key]; found {
+			return s3err.ErrInvalidTag
+		}
+		keys[*tag.Key] = struct{}{}
+	}
+	if len(tags) > 0 && rule.DeleteMarkerReplication != nil &&
+		aws.StringValue(rule.DeleteMarkerReplication.Status) == s3.DeleteMarkerReplicationStatusEnabled {
+		return s3err.ErrInvalidRequest
+	}
+	return s3err.ErrNone
+}
+
+// replicationRuleMatches checks the object key and tags against the prefix or filter of the rule
+func replicationRuleMatches(rule *s3.ReplicationRule, key string, tags map[string]string) bool {
+	if rule.Filter == nil {
+		return strings.HasPrefix(key, aws.StringValue(rule.Prefix))
+	}
+	prefix := aws.StringValue(rule.Filter.Prefix)
+	var filterTags []*s3.Tag
+	if rule.Filter.Tag != nil {
+		filterTags = append(filterTags, rule.Filter.Tag)
+	}
+	if rule.Filter.And != nil {
+		prefix = aws.StringValue(rule.Filter.And.Prefix)
+		filterTags = append(filterTags, rule.Filter.And.Tags...)
+	}
+	if !strings.HasPrefix(key, prefix) {
+		return false
+	}
+	for _, tag := range filterTags {
+		if value, found := tags[aws.StringValue(tag.Key)]; !found || value != aws.StringValue(tag.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// findReplicationRule returns the matching rule with the highest priority, which may be disabled
+func findReplicationRule(config *s3.ReplicationConfiguration, key string, tags map[string]string) (matched *s3.ReplicationRule) {
+	for _, rule := range config.Rules {
+		if !replicationRuleMatches(rule, key, tags) {
+			continue
+		}
+		if matched == nil || aws.Int64Value(rule.Priority) > aws.Int64Value(matched.Priority) {
+			matched = rule
+		}
+	}
+	return
+}
+
+// objectTags reads the object tags from the entry extended attributes
+func objectTags(entry *filer_pb.Entry) map[string]string {
+	tags := make(map[string]string)
+	for k, v := range entry.Extended {
+		if strings.HasPrefix(k, S3TAG_PREFIX) {
+			tags[k[len(S3TAG_PREFIX):]] = string(v)
+		}
+	}
+	return tags
+}
+
+// startReplicationWorker follows the object changes of all buckets, and copies the objects matching
+// the bucket replication configurations to their destination. Only the S3 gateway holding the filer lock
+// follows the changes.
+func (s3a *S3ApiServer) startReplicationWorker() {
+	if len(s3a.replicationTargets) == 0 {
+		return
+	}
+	s3a.followBucketEventsWhileLocked("replication", replicationLockName, replicationOffsetKey, replicationOffsetInterval, s3a.replicateBucketEvent)
+}
+
+// replicateBucketEvent copies a new object, or a delete marker if the rule replicates them, to the destination.
+// Replicas are not replicated again, and deleting an object version is never replicated.
+func (s3a *S3ApiServer) replicateBucketEvent(event bucketEvent) {
+	metadata, errCode := s3a.bucketRegistry.GetBucketMetadata(event.bucket)
+	if errCode != s3err.ErrNone || metadata.Replication == nil {
+		return
+	}
+	target, found := s3a.replicationTargets[replicationTargetName(aws.StringValue(metadata.Replication.Role))]
+	if !found {
+		glog.Warningf("bucket %s replication: unknown target %s", event.bucket, aws.StringValue(metadata.Replication.Role))
+		return
+	}
+
+	switch event.name {
+	case notificationEventPut, notificationEventCompleteMultipartUpload:
+		if string(event.entry.Extended[s3_constants.AmzReplicationStatus]) == s3.ReplicationStatusReplica {
+			return
+		}
+		rule := findReplicationRule(metadata.Replication, event.key, objectTags(event.entry))
+		if rule == nil || aws.StringValue(rule.Status) != s3.ReplicationRuleStatusEnabled {
+			return
+		}
+		if string(event.entry.Extended[s3_constants.ExtSseTypeKey]) == s3_constants.SseTypeCustomer {
+			// the gateway does not have the customer key to read the object
+			glog.V(1).Infof("bucket %s replication: skip SSE-C object %s", event.bucket, event.key)
+			return
+		}
+		s3a.setReplicationStatus(event, s3.ReplicationStatusPending)
+		status := s3.ReplicationStatusCompleted
+		if err := s3a.replicateObject(target, rule, event); err != nil {
+			glog.Errorf("bucket %s replicate %s to %s: %v", event.bucket, event.key, aws.StringValue(rule.Destination.Bucket), err)
+			status = s3.ReplicationStatusFailed
+		}
+		s3a.setReplicationStatus(event, status)
+	case notificationEventDeleteMarkerCreated:
+		rule := findReplicationRule(metadata.Replication, event.key, nil)
+		if rule == nil || aws.StringValue(rule.Status) != s3.ReplicationRuleStatusEnabled ||
+			rule.DeleteMarkerReplication == nil || aws.StringValue(rule.DeleteMarkerReplication.Status) != s3.DeleteMarkerReplicationStatusEnabled {
+			return
+		}
+		_, err := target.client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(strings.TrimPrefix(aws.StringValue(rule.Destination.Bucket), replicationBucketArnPrefix)),
+			Key:    aws.String(event.key),
+		})
+		if err != nil {
+			glog.Errorf("bucket %s replicate delete marker %s to %s: %v", event.bucket, event.key, aws.StringValue(rule.Destination.Bucket), err)
+		}
+	}
+}
+
+// replicateObject uploads the object content, decrypted if needed, with its metadata and tags.
+// The replica is marked with the REPLICA status, so that it is not replicated back.
+func (s3a *S3ApiServer) replicateObject(target *replicationTarget, rule *s3.ReplicationRule, event bucketEvent) error {
+	entry := event.entry
+	var reader io.Reader = filer.NewFileReader(s3a, entry)
+	info, err := sseInfoFromExtended(entry.Extended)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		dataKey, errCode := s3a.sseDataKey(info, nil)
+		if errCode != s3err.ErrNone {
+			return fmt.Errorf("object data key: %v", s3err.GetAPIError(errCode).Code)
+		}
+		if reader, err = newSseReader(reader, dataKey, info.segments, 0); err != nil {
+			return err
+		}
+	}
+
+	input := &s3manager.UploadInput{
+		Bucket:   aws.String(strings.TrimPrefix(aws.StringValue(rule.Destination.Bucket), replicationBucketArnPrefix)),
+		Key:      aws.String(event.key),
+		Body:     reader,
+		Metadata: make(map[string]*string),
+	}
+	if entry.Attributes != nil && entry.Attributes.Mime != "" {
+		input.ContentType = aws.String(entry.Attributes.Mime)
+	}
+	if info != nil {
+		input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAes256)
+	}
+	storageClass := string(entry.Extended[s3_constants.AmzStorageClass])
+	if rule.Destination.StorageClass != nil {
+		storageClass = *rule.Destination.StorageClass
+	}
+	if storageClass != "" {
+		input.StorageClass = aws.String(storageClass)
+	}
+	for k, v := range entry.Extended {
+		if strings.HasPrefix(k, s3_constants.AmzUserMetaPrefix) {
+			input.Metadata[k[len(s3_constants.AmzUserMetaPrefix):]] = aws.String(string(v))
+		}
+	}
+	if v := entry.Extended["Content-Encoding"]; len(v) > 0 {
+		input.ContentEncoding = aws.String(string(v))
+	}
+	if v := entry.Extended["Cache-Control"]; len(v) > 0 {
+		input.CacheControl = aws.String(string(v))
+	}
+	if v := entry.Extended["Content-Disposition"]; len(v) > 0 {
+		input.ContentDisposition = aws.String(string(v))
+	}
+	if tags := objectTags(entry); len(tags) > 0 {
+		tagging := url.Values{}
+		for k, v := range tags {
+			tagging.Set(k, v)
+		}
+		input.Tagging = aws.String(tagging.Encode())
+	}
+
+	_, err = target.uploader.Upload(input, func(u *s3manager.Uploader) {
+		u.RequestOptions = append(u.RequestOptions, request.WithSetRequestHeaders(map[string]string{
+			s3_constants.AmzReplicationStatus: s3.ReplicationStatusReplica,
+		}))
+	})
+	return err
+}
+
+// setReplicationStatus sets the replication status on the object of the event, unless it has been overwritten.
+// The object may have become a noncurrent version in the meantime.
+func (s3a *S3ApiServer) setReplicationStatus(event bucketEvent, status string) {
+	bucketDir := s3a.option.BucketsPath + "/" + event.bucket
+	locations := []util.FullPath{util.NewFullPath(bucketDir, event.key)}
+	if event.versionId != "" {
+		locations = append(locations, util.NewFullPath(s3a.genVersionsFolder(event.bucket, "/"+event.key), event.versionId))
+	}
+	for _, location := range locations {
+		dir, name := location.DirAndName()
+		entry, err := s3a.getEntry(dir, name)
+		if err != nil {
+			if err != filer_pb.ErrNotFound {
+				glog.Errorf("bucket %s replication status of %s: %v", event.bucket, event.key, err)
+				return
+			}
+			continue
+		}
+		if entryVersionId(entry) != entryVersionId(event.entry) || isObjectContentChanged(event.entry, entry) {
+			continue
+		}
+		if entry.Extended == nil {
+			entry.Extended = make(map[string][]byte)
+		}
+		entry.Extended[s3_constants.AmzReplicationStatus] = []byte(status)
+		if err = s3a.updateEntry(dir, entry); err != nil {
+			glog.Errorf("bucket %s replication status of %s: %v", event.bucket, event.key, err)
+		}
+		return
+	}
+}
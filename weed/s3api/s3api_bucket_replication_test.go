@@ -0,0 +1,120 @@
+package s3api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+var testReplicationTargets = map[string]*replicationTarget{"dr_site": {}}
+
+func newTestReplicationRule(priority int64, filter *s3.ReplicationRuleFilter) *s3.ReplicationRule {
+	return &s3.ReplicationRule{
+		Status:      aws.String(s3.ReplicationRuleStatusEnabled),
+		Priority:    aws.Int64(priority),
+		Filter:      filter,
+		Destination: &s3.Destination{Bucket: aws.String("arn:aws:s3:::backup")},
+	}
+}
+
+func TestValidateReplicationConfiguration(t *testing.T) {
+	tagFilter := &s3.ReplicationRuleFilter{Tag: &s3.Tag{Key: aws.String("dr"), Value: aws.String("yes")}}
+	deleteMarkersByTag := newTestReplicationRule(1, tagFilter)
+	deleteMarkersByTag.DeleteMarkerReplication = &s3.DeleteMarkerReplication{Status: aws.String(s3.DeleteMarkerReplicationStatusEnabled)}
+	noPriority := newTestReplicationRule(1, &s3.ReplicationRuleFilter{Prefix: aws.String("logs/")})
+	noPriority.Priority = nil
+	legacyPrefix := newTestReplicationRule(0, nil)
+	legacyPrefix.Prefix, legacyPrefix.Priority = aws.String("logs/"), nil
+
+	tests := []struct {
+		name     string
+		role     string
+		rules    []*s3.ReplicationRule
+		expected s3err.ErrorCode
+	}{
+		{"valid", "arn:aws:iam::000000000000:role/dr_site", []*s3.ReplicationRule{
+			newTestReplicationRule(1, &s3.ReplicationRuleFilter{Prefix: aws.String("logs/")}),
+			newTestReplicationRule(2, tagFilter),
+		}, s3err.ErrNone},
+		{"legacy prefix", "arn:aws:iam::000000000000:role/dr_site", []*s3.ReplicationRule{legacyPrefix}, s3err.ErrNone},
+		{"unknown target", "arn:aws:iam::000000000000:role/other", []*s3.ReplicationRule{newTestReplicationRule(1, nil)}, s3err.ErrInvalidRequest},
+		{"missing role", "", []*s3.ReplicationRule{newTestReplicationRule(1, nil)}, s3err.ErrMalformedXML},
+		{"no rules", "arn:aws:iam::000000000000:role/dr_site", nil, s3err.ErrMalformedXML},
+		{"missing priority", "arn:aws:iam::000000000000:role/dr_site", []*s3.ReplicationRule{noPriority}, s3err.ErrMalformedXML},
+		{"same priority", "arn:aws:iam::000000000000:role/dr_site", []*s3.ReplicationRule{
+			newTestReplicationRule(1, &s3.ReplicationRuleFilter{Prefix: aws.String("a/")}),
+			newTestReplicationRule(1, &s3.ReplicationRuleFilter{Prefix: aws.String("b/")}),
+		}, s3err.ErrInvalidRequest},
+		{"delete markers by tag", "arn:aws:iam::000000000000:role/dr_site", []*s3.ReplicationRule{deleteMarkersByTag}, s3err.ErrInvalidRequest},
+		{"invalid destination", "arn:aws:iam::000000000000:role/dr_site", []*s3.ReplicationRule{{
+			Status:      aws.String(s3.ReplicationRuleStatusEnabled),
+			Destination: &s3.Destination{Bucket: aws.String("backup")},
+		}}, s3err.ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &s3.ReplicationConfiguration{Role: aws.String(tt.role), Rules: tt.rules}
+			assert.Equal(t, tt.expected, validateReplicationConfiguration(config, testReplicationTargets))
+			if tt.expected == s3err.ErrNone {
+				for _, rule := range config.Rules {
+					assert.NotEmpty(t, aws.StringValue(rule.ID))
+				}
+			}
+		})
+	}
+}
+
+func TestFindReplicationRule(t *testing.T) {
+	logs := newTestReplicationRule(1, &s3.ReplicationRuleFilter{Prefix: aws.String("logs/")})
+	tagged := newTestReplicationRule(2, &s3.ReplicationRuleFilter{And: &s3.ReplicationRuleAndOperator{
+		Prefix: aws.String("logs/"),
+		Tags:   []*s3.Tag{{Key: aws.String("dr"), Value: aws.String("no")}},
+	}})
+	tagged.Status = aws.String(s3.ReplicationRuleStatusDisabled)
+	config := &s3.ReplicationConfiguration{Rules: []*s3.ReplicationRule{logs, tagged}}
+
+	assert.Equal(t, logs, findReplicationRule(config, "logs/today.log", nil))
+	assert.Equal(t, logs, findReplicationRule(config, "logs/today.log", map[string]string{"dr": "yes"}))
+	// the disabled rule has the higher priority
+	assert.Equal(t, tagged, findReplicationRule(config, "logs/today.log", map[string]string{"dr": "no"}))
+	assert.Nil(t, findReplicationRule(config, "data/today.csv", nil))
+}
+
+func TestReplicationTargetName(t *testing.T) {
+	assert.Equal(t, "dr_site", replicationTargetName("arn:aws:iam::000000000000:role/dr_site"))
+	assert.Equal(t, "dr_site", replicationTargetName("dr_site"))
+}
+
+func TestIsReplicaWrite(t *testing.T) {
+	policy, err := parseBucketPolicy([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": {"AWS": "replicator"}, "Action": "s3:ReplicateObject", "Resource": "arn:aws:s3:::bucket/*"}
+  ]
+}`))
+	assert.NoError(t, err)
+	s3a := &S3ApiServer{iam: &IdentityAccessManagement{isAuthEnabled: true, bucketPolicies: testBucketPolicies{"bucket": policy}}}
+	request := func(identity *Identity, replica bool) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/bucket/object", nil)
+		if replica {
+			r.Header.Set(s3_constants.AmzReplicationStatus, s3.ReplicationStatusReplica)
+		}
+		return r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity))
+	}
+	replicator := &Identity{Name: "replicator", Account: &Account{Id: "replicator"}, Actions: []Action{s3_constants.ACTION_WRITE}}
+	writer := &Identity{Name: "writer", Account: &Account{Id: "writer"}, Actions: []Action{s3_constants.ACTION_WRITE}}
+	admin := &Identity{Name: "admin", Account: &Account{Id: "admin"}, Actions: []Action{s3_constants.ACTION_ADMIN}}
+
+	assert.False(t, s3a.isReplicaWrite(request(replicator, false), "bucket", "/object"), "the header is required")
+	assert.True(t, s3a.isReplicaWrite(request(replicator, true), "bucket", "/object"))
+	assert.False(t, s3a.isReplicaWrite(request(writer, true), "bucket", "/object"), "writing is not enough")
+	assert.True(t, s3a.isReplicaWrite(request(admin, true), "bucket", "/object"))
+	assert.False(t, s3a.isReplicaWrite(request(nil, true), "bucket", "/object"), "the requester is not known")
+}
@@ -0,0 +1,341 @@
+package s3api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Buckets with a website configuration are served as static web sites,
+// either on the website port, or on the S3 port for the {bucket}.{websiteDomainName} hosts.
+// Website requests are always anonymous: the objects need to be readable by anybody,
+// through the anonymous identity, a bucket policy or a public-read ACL.
+
+const maxWebsiteRoutingRules = 50
+
+// validateWebsiteConfiguration checks the configuration the same way AWS S3 does when putting a website configuration
+func validateWebsiteConfiguration(config *s3.WebsiteConfiguration) s3err.ErrorCode {
+	if config.RedirectAllRequestsTo != nil {
+		// redirecting all requests excludes any other setting
+		if config.IndexDocument != nil || config.ErrorDocument != nil || len(config.RoutingRules) > 0 {
+			return s3err.ErrInvalidRequest
+		}
+		if aws.StringValue(config.RedirectAllRequestsTo.HostName) == "" || !isValidWebsiteProtocol(config.RedirectAllRequestsTo.Protocol) {
+			return s3err.ErrInvalidRequest
+		}
+		return s3err.ErrNone
+	}
+	if config.IndexDocument == nil {
+		return s3err.ErrMalformedXML
+	}
+	if suffix := aws.StringValue(config.IndexDocument.Suffix); suffix == "" || strings.Contains(suffix, "/") {
+		return s3err.ErrInvalidRequest
+	}
+	if config.ErrorDocument != nil && aws.StringValue(config.ErrorDocument.Key) == "" {
+		return s3err.ErrInvalidRequest
+	}
+	if len(config.RoutingRules) > maxWebsiteRoutingRules {
+		return s3err.ErrInvalidRequest
+	}
+	for _, rule := range config.RoutingRules {
+		if rule == nil || rule.Redirect == nil {
+			return s3err.ErrMalformedXML
+		}
+		redirect := rule.Redirect
+		if redirect.ReplaceKeyWith != nil && redirect.ReplaceKeyPrefixWith != nil {
+			return s3err.ErrInvalidRequest
+		}
+		if !isValidWebsiteProtocol(redirect.Protocol) {
+			return s3err.ErrInvalidRequest
+		}
+		if redirect.HttpRedirectCode != nil {
+			if code, err := strconv.Atoi(*redirect.HttpRedirectCode); err != nil || code < 300 || code > 399 {
+				return s3err.ErrInvalidRequest
+			}
+		}
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != nil {
+			if code, err := strconv.Atoi(*rule.Condition.HttpErrorCodeReturnedEquals); err != nil || code < 400 || code > 599 {
+				return s3err.ErrInvalidRequest
+			}
+		}
+	}
+	return s3err.ErrNone
+}
+
+func isValidWebsiteProtocol(protocol *string) bool {
+	return protocol == nil || *protocol == "http" || *protocol == "https"
+}
+
+// findWebsiteRoutingRule returns the first rule matching the requested key,
+// before looking up the object if statusCode is 0, or after it failed with statusCode
+func findWebsiteRoutingRule(config *s3.WebsiteConfiguration, key string, statusCode int) *s3.RoutingRule {
+	for _, rule := range config.RoutingRules {
+		condition := rule.Condition
+		if condition == nil {
+			condition = &s3.Condition{}
+		}
+		if statusCode == 0 && condition.HttpErrorCodeReturnedEquals != nil {
+			continue
+		}
+		if statusCode != 0 && aws.StringValue(condition.HttpErrorCodeReturnedEquals) != strconv.Itoa(statusCode) {
+			continue
+		}
+		if !strings.HasPrefix(key, aws.StringValue(condition.KeyPrefixEquals)) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// websiteRedirectLocation builds where the rule redirects the requested key
+func websiteRedirectLocation(r *http.Request, rule *s3.RoutingRule, key string) (location string, statusCode int) {
+	redirect := rule.Redirect
+	protocol := aws.StringValue(redirect.Protocol)
+	if protocol == "" {
+		protocol = requestProtocol(r)
+	}
+	host := aws.StringValue(redirect.HostName)
+	if host == "" {
+		host = r.Host
+	}
+	switch {
+	case redirect.ReplaceKeyWith != nil:
+		key = *redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != nil:
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = aws.StringValue(rule.Condition.KeyPrefixEquals)
+		}
+		key = *redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	statusCode = http.StatusMovedPermanently
+	if redirect.HttpRedirectCode != nil {
+		statusCode, _ = strconv.Atoi(*redirect.HttpRedirectCode)
+	}
+	return fmt.Sprintf("%s://%s/%s", protocol, host, key), statusCode
+}
+
+func requestProtocol(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
+
+func (s3a *S3ApiServer) getBucketWebsite(bucket string) (*s3.WebsiteConfiguration, s3err.ErrorCode) {
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	if bucketMetadata.Website == nil {
+		return nil, s3err.ErrNoSuchWebsiteConfiguration
+	}
+	return bucketMetadata.Website, s3err.ErrNone
+}
+
+// WebsiteHandler serves GET and HEAD requests to a bucket configured as a static website
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteHosting.html
+func (s3a *S3ApiServer) WebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	key := strings.TrimPrefix(object, "/")
+	glog.V(3).Infof("WebsiteHandler %s %s", bucket, key)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeWebsiteErrorPage(w, r, bucket, key, s3err.ErrMethodNotAllowed)
+		return
+	}
+	config, errCode := s3a.getBucketWebsite(bucket)
+	if errCode != s3err.ErrNone {
+		writeWebsiteErrorPage(w, r, bucket, key, errCode)
+		return
+	}
+
+	if redirectAll := config.RedirectAllRequestsTo; redirectAll != nil {
+		protocol := aws.StringValue(redirectAll.Protocol)
+		if protocol == "" {
+			protocol = requestProtocol(r)
+		}
+		http.Redirect(w, r, fmt.Sprintf("%s://%s/%s", protocol, aws.StringValue(redirectAll.HostName), key), http.StatusMovedPermanently)
+		return
+	}
+	if rule := findWebsiteRoutingRule(config, key, 0); rule != nil {
+		location, statusCode := websiteRedirectLocation(r, rule, key)
+		http.Redirect(w, r, location, statusCode)
+		return
+	}
+
+	indexSuffix := aws.StringValue(config.IndexDocument.Suffix)
+	target := key
+	if target == "" || strings.HasSuffix(target, "/") {
+		target += indexSuffix
+	}
+	req, entry, errCode := s3a.lookupWebsiteObject(r, bucket, target)
+	if errCode == s3err.ErrNoSuchKey && target == key {
+		// a folder requested without the trailing slash
+		if _, _, indexErrCode := s3a.lookupWebsiteObject(r, bucket, key+"/"+indexSuffix); indexErrCode == s3err.ErrNone {
+			http.Redirect(w, r, "/"+key+"/", http.StatusFound)
+			return
+		}
+	}
+	if errCode != s3err.ErrNone {
+		s3a.writeWebsiteError(w, r, config, bucket, key, errCode)
+		return
+	}
+
+	if location := entry.Extended[s3_constants.AmzWebsiteRedirectLocation]; len(location) > 0 && s3_constants.IsValidWebsiteRedirectLocation(string(location)) {
+		http.Redirect(w, r, string(location), http.StatusMovedPermanently)
+		return
+	}
+	s3a.serveWebsiteObject(w, req, http.StatusOK)
+}
+
+// checkWebsiteRedirectLocation rejects redirects to anything but a path or an http(s) url
+func checkWebsiteRedirectLocation(header http.Header) s3err.ErrorCode {
+	if location := header.Get(s3_constants.AmzWebsiteRedirectLocation); location != "" && !s3_constants.IsValidWebsiteRedirectLocation(location) {
+		return s3err.ErrInvalidRedirectLocation
+	}
+	return s3err.ErrNone
+}
+
+// lookupWebsiteObject checks the object can be read anonymously,
+// and returns the anonymous request to serve it
+func (s3a *S3ApiServer) lookupWebsiteObject(r *http.Request, bucket, key string) (*http.Request, *filer_pb.Entry, s3err.ErrorCode) {
+	req := r.Clone(r.Context())
+	req.Header.Del("Authorization")
+	req.Header.Del(s3_constants.AmzPolicyAllowed)
+	req.Header.Del(s3_constants.AmzAclAllowed)
+	req.URL.RawQuery = ""
+	req.URL.Path = "/" + key
+	req = mux.SetURLVars(req, map[string]string{"bucket": bucket, "object": key})
+
+	if s3a.iam.isEnabled() {
+		if _, errCode := s3a.iam.authRequest(req, s3_constants.ACTION_READ); errCode != s3err.ErrNone {
+			return nil, nil, errCode
+		}
+	}
+
+	target := util.FullPath(fmt.Sprintf("%s/%s/%s", s3a.option.BucketsPath, bucket, key))
+	dir, name := target.DirAndName()
+	entry, err := s3a.getEntry(dir, name)
+	if err == filer_pb.ErrNotFound || err == nil && (entry.IsDirectory || isDeleteMarker(entry)) {
+		return nil, nil, s3err.ErrNoSuchKey
+	}
+	if err != nil {
+		glog.Errorf("website %s/%s: %v", bucket, key, err)
+		return nil, nil, s3err.ErrInternalError
+	}
+	return req, entry, s3err.ErrNone
+}
+
+// serveWebsiteObject serves the object of the anonymous request, with statusCode for an error document
+func (s3a *S3ApiServer) serveWebsiteObject(w http.ResponseWriter, req *http.Request, statusCode int) {
+	if statusCode != http.StatusOK {
+		for _, header := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "Range"} {
+			req.Header.Del(header)
+		}
+		w = &websiteErrorDocumentWriter{ResponseWriter: w, statusCode: statusCode}
+	}
+	if req.Method == http.MethodHead {
+		s3a.HeadObjectHandler(w, req)
+	} else {
+		s3a.GetObjectHandler(w, req)
+	}
+}
+
+// writeWebsiteError redirects by the routing rules, or serves the error document, or a default error page
+func (s3a *S3ApiServer) writeWebsiteError(w http.ResponseWriter, r *http.Request, config *s3.WebsiteConfiguration, bucket, key string, errCode s3err.ErrorCode) {
+	statusCode := s3err.GetAPIError(errCode).HTTPStatusCode
+	if rule := findWebsiteRoutingRule(config, key, statusCode); rule != nil {
+		location, redirectCode := websiteRedirectLocation(r, rule, key)
+		http.Redirect(w, r, location, redirectCode)
+		return
+	}
+	if config.ErrorDocument != nil && statusCode >= 400 && statusCode < 500 {
+		if req, _, docErrCode := s3a.lookupWebsiteObject(r, bucket, aws.StringValue(config.ErrorDocument.Key)); docErrCode == s3err.ErrNone {
+			s3a.serveWebsiteObject(w, req, statusCode)
+			return
+		}
+	}
+	writeWebsiteErrorPage(w, r, bucket, key, errCode)
+}
+
+func writeWebsiteErrorPage(w http.ResponseWriter, r *http.Request, bucket, key string, errCode s3err.ErrorCode) {
+	apiError := s3err.GetAPIError(errCode)
+	title := fmt.Sprintf("%d %s", apiError.HTTPStatusCode, http.StatusText(apiError.HTTPStatusCode))
+	var body strings.Builder
+	fmt.Fprintf(&body, "<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n", title, title)
+	fmt.Fprintf(&body, "<li>Code: %s</li>\n<li>Message: %s</li>\n", apiError.Code, html.EscapeString(apiError.Description))
+	if bucket != "" {
+		fmt.Fprintf(&body, "<li>BucketName: %s</li>\n", html.EscapeString(bucket))
+	}
+	if key != "" && errCode == s3err.ErrNoSuchKey {
+		fmt.Fprintf(&body, "<li>Key: %s</li>\n", html.EscapeString(key))
+	}
+	body.WriteString("</ul>\n</body>\n</html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(apiError.HTTPStatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write([]byte(body.String()))
+	}
+}
+
+// websiteErrorDocumentWriter serves the error document with the status of the error
+type websiteErrorDocumentWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *websiteErrorDocumentWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusOK {
+		statusCode = w.statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *websiteErrorDocumentWriter) Write(data []byte) (int, error) {
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *websiteErrorDocumentWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// registerWebsiteRouter serves the websites of the {bucket}.{websiteDomainName} hosts,
+// and with anyHost, also those of the buckets named after the whole host name
+func (s3a *S3ApiServer) registerWebsiteRouter(router *mux.Router, anyHost bool) {
+	var routers []*mux.Router
+	for _, domainName := range strings.Split(s3a.option.WebsiteDomainName, ",") {
+		if domainName != "" {
+			routers = append(routers, router.Host(fmt.Sprintf("%s.%s", "{bucket:.+}", domainName)).Subrouter())
+		}
+	}
+	if anyHost {
+		routers = append(routers, router.Host("{bucket:.+}").Subrouter())
+	}
+	for _, website := range routers {
+		website.Path("/{object:.*}").HandlerFunc(track(s3a.WebsiteHandler, "GET"))
+	}
+}
+
+// RegisterWebsiteRouter serves the bucket websites on their own listener
+func (s3a *S3ApiServer) RegisterWebsiteRouter(router *mux.Router) {
+	s3a.registerWebsiteRouter(router, true)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeWebsiteErrorPage(w, r, "", "", s3err.ErrNoSuchBucket)
+	})
+}
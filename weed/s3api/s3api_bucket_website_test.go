@@ -0,0 +1,107 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+var testWebsiteConfiguration = &s3.WebsiteConfiguration{
+	IndexDocument: &s3.IndexDocument{Suffix: aws.String("index.html")},
+	ErrorDocument: &s3.ErrorDocument{Key: aws.String("404.html")},
+	RoutingRules: []*s3.RoutingRule{
+		{
+			Condition: &s3.Condition{KeyPrefixEquals: aws.String("docs/")},
+			Redirect:  &s3.Redirect{ReplaceKeyPrefixWith: aws.String("documents/")},
+		},
+		{
+			Condition: &s3.Condition{HttpErrorCodeReturnedEquals: aws.String("404")},
+			Redirect:  &s3.Redirect{HostName: aws.String("fallback.example.com"), Protocol: aws.String("https"), HttpRedirectCode: aws.String("302")},
+		},
+	},
+}
+
+func TestValidateWebsiteConfiguration(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *s3.WebsiteConfiguration
+		expected s3err.ErrorCode
+	}{
+		{"valid", testWebsiteConfiguration, s3err.ErrNone},
+		{"redirect all", &s3.WebsiteConfiguration{RedirectAllRequestsTo: &s3.RedirectAllRequestsTo{HostName: aws.String("example.com")}}, s3err.ErrNone},
+		{"redirect all with index", &s3.WebsiteConfiguration{
+			RedirectAllRequestsTo: &s3.RedirectAllRequestsTo{HostName: aws.String("example.com")},
+			IndexDocument:         &s3.IndexDocument{Suffix: aws.String("index.html")},
+		}, s3err.ErrInvalidRequest},
+		{"missing index", &s3.WebsiteConfiguration{ErrorDocument: &s3.ErrorDocument{Key: aws.String("error.html")}}, s3err.ErrMalformedXML},
+		{"index with slash", &s3.WebsiteConfiguration{IndexDocument: &s3.IndexDocument{Suffix: aws.String("a/index.html")}}, s3err.ErrInvalidRequest},
+		{"both key replacements", &s3.WebsiteConfiguration{
+			IndexDocument: &s3.IndexDocument{Suffix: aws.String("index.html")},
+			RoutingRules:  []*s3.RoutingRule{{Redirect: &s3.Redirect{ReplaceKeyWith: aws.String("a"), ReplaceKeyPrefixWith: aws.String("b")}}},
+		}, s3err.ErrInvalidRequest},
+		{"invalid redirect code", &s3.WebsiteConfiguration{
+			IndexDocument: &s3.IndexDocument{Suffix: aws.String("index.html")},
+			RoutingRules:  []*s3.RoutingRule{{Redirect: &s3.Redirect{HttpRedirectCode: aws.String("200")}}},
+		}, s3err.ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, validateWebsiteConfiguration(tt.config))
+		})
+	}
+}
+
+func TestWebsiteRoutingRules(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://bucket.website.example.com/docs/guide.html", nil)
+
+	rule := findWebsiteRoutingRule(testWebsiteConfiguration, "docs/guide.html", 0)
+	assert.Equal(t, testWebsiteConfiguration.RoutingRules[0], rule)
+	location, statusCode := websiteRedirectLocation(r, rule, "docs/guide.html")
+	assert.Equal(t, "http://bucket.website.example.com/documents/guide.html", location)
+	assert.Equal(t, http.StatusMovedPermanently, statusCode)
+
+	// error conditions only apply once the object lookup failed
+	assert.Nil(t, findWebsiteRoutingRule(testWebsiteConfiguration, "missing.html", 0))
+	assert.Nil(t, findWebsiteRoutingRule(testWebsiteConfiguration, "missing.html", http.StatusForbidden))
+	rule = findWebsiteRoutingRule(testWebsiteConfiguration, "missing.html", http.StatusNotFound)
+	assert.Equal(t, testWebsiteConfiguration.RoutingRules[1], rule)
+	location, statusCode = websiteRedirectLocation(r, rule, "missing.html")
+	assert.Equal(t, "https://fallback.example.com/missing.html", location)
+	assert.Equal(t, http.StatusFound, statusCode)
+}
+
+func TestWebsiteErrorDocumentWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := &websiteErrorDocumentWriter{ResponseWriter: recorder, statusCode: http.StatusNotFound}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("not here"))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "not here", recorder.Body.String())
+}
+
+func TestCheckWebsiteRedirectLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		expected s3err.ErrorCode
+	}{
+		{"", s3err.ErrNone},
+		{"/docs/index.html", s3err.ErrNone},
+		{"http://example.com/", s3err.ErrNone},
+		{"https://example.com/a", s3err.ErrNone},
+		{"docs/index.html", s3err.ErrInvalidRedirectLocation},
+		{"//evil.example.com", s3err.ErrInvalidRedirectLocation},
+		{"/\\evil.example.com", s3err.ErrInvalidRedirectLocation},
+		{"javascript:alert(1)", s3err.ErrInvalidRedirectLocation},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set(s3_constants.AmzWebsiteRedirectLocation, tt.location)
+		assert.Equal(t, tt.expected, checkWebsiteRedirectLocation(header), tt.location)
+	}
+}
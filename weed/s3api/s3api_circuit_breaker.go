@@ -21,6 +21,12 @@ type CircuitBreaker struct {
 	Enabled     bool
 	counters    map[string]*int64
 	limitations map[string]int64
+
+	// token bucket rate limits, applied whether or not the circuit breaker is enabled
+	rateLimited          atomic.Bool
+	globalRateLimiter    *rateLimiter
+	bucketRateLimiters   map[string]*rateLimiter
+	identityRateLimiters map[string]*rateLimiter
 }
 
 func NewCircuitBreaker(option *S3ApiServerOption) *CircuitBreaker {
@@ -84,19 +90,61 @@ func (cb *CircuitBreaker) loadCircuitBreakerConfig(cfg *s3_pb.S3CircuitBreakerCo
 	}
 
 	cb.limitations = limitations
+
+	cb.loadRateLimits(cfg)
 	return nil
 }
 
+// loadRateLimits applies the rate limits of the enabled global, bucket and access key options
+func (cb *CircuitBreaker) loadRateLimits(cfg *s3_pb.S3CircuitBreakerConfig) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	var globalRateLimiter *rateLimiter
+	if cfg.Global != nil && cfg.Global.Enabled {
+		globalRateLimiter = newRateLimiter(cb.globalRateLimiter, rateLimitScopeGlobal, "", cfg.Global.RateLimit)
+	}
+	rateLimited := globalRateLimiter != nil
+
+	loadLimiters := func(existing map[string]*rateLimiter, scope string, options map[string]*s3_pb.S3CircuitBreakerOptions) map[string]*rateLimiter {
+		limiters := make(map[string]*rateLimiter)
+		for name, cbOptions := range options {
+			if !cbOptions.Enabled {
+				continue
+			}
+			if l := newRateLimiter(existing[name], scope, name, cbOptions.RateLimit); l != nil {
+				limiters[name] = l
+				rateLimited = true
+			}
+		}
+		return limiters
+	}
+	cb.globalRateLimiter = globalRateLimiter
+	cb.bucketRateLimiters = loadLimiters(cb.bucketRateLimiters, rateLimitScopeBucket, cfg.Buckets)
+	cb.identityRateLimiters = loadLimiters(cb.identityRateLimiters, rateLimitScopeIdentity, cfg.Identities)
+	cb.rateLimited.Store(rateLimited)
+}
+
 func (cb *CircuitBreaker) Limit(f func(w http.ResponseWriter, r *http.Request), action string) (http.HandlerFunc, Action) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucket := vars["bucket"]
+
+		if cb.rateLimited.Load() {
+			limiters := cb.rateLimitersOf(bucket, getRequestAccessKey(r))
+			if retryAfter, allowed := allowRequest(limiters); !allowed {
+				setRetryAfter(w, retryAfter)
+				s3err.WriteErrorResponse(w, r, s3err.ErrSlowDown)
+				return
+			}
+			w, r = limitBandwidth(w, r, limiters)
+		}
+
 		if !cb.Enabled {
 			f(w, r)
 			return
 		}
 
-		vars := mux.Vars(r)
-		bucket := vars["bucket"]
-
 		rollback, errCode := cb.limit(r, bucket, action)
 		defer func() {
 			for _, rf := range rollback {
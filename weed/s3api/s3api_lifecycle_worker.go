@@ -0,0 +1,373 @@
+package s3api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/remote_pb"
+	"github.com/seaweedfs/seaweedfs/weed/remote_storage"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	lifecycleLockName     = "s3.lifecycle"
+	lifecycleScanInterval = time.Hour
+)
+
+// startLifecycleWorker applies the bucket lifecycle rules periodically.
+// All S3 gateways compete for the same filer lock, so only one of them works on the buckets.
+func (s3a *S3ApiServer) startLifecycleWorker() {
+	owner := fmt.Sprintf("%s:%d", util.DetectedHostAddress(), s3a.option.Port)
+	lockClient := cluster.NewLockClient(s3a.option.GrpcDialOption, s3a.option.Filer)
+	lock := lockClient.StartLongLivedLock(lifecycleLockName, owner, func(newLockOwner string) {
+		glog.V(0).Infof("s3 lifecycle worker is %s", newLockOwner)
+	})
+	for {
+		time.Sleep(lifecycleScanInterval)
+		if lock.LockOwner() != owner {
+			continue
+		}
+		if err := s3a.applyLifecycleRules(time.Now()); err != nil {
+			glog.Errorf("apply lifecycle rules: %v", err)
+		}
+	}
+}
+
+func (s3a *S3ApiServer) applyLifecycleRules(now time.Time) error {
+	var buckets []*filer_pb.Entry
+	err := s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.SeaweedList(client, s3a.option.BucketsPath, "", func(entry *filer_pb.Entry, isLast bool) error {
+			if entry.IsDirectory && len(entry.Extended[s3_constants.ExtLifecycleKey]) > 0 {
+				buckets = append(buckets, entry)
+			}
+			return nil
+		}, "", false, math.MaxInt32)
+	})
+	if err != nil {
+		return fmt.Errorf("list buckets: %v", err)
+	}
+
+	for _, bucketEntry := range buckets {
+		var config s3.BucketLifecycleConfiguration
+		if err := json.Unmarshal(bucketEntry.Extended[s3_constants.ExtLifecycleKey], &config); err != nil {
+			glog.Errorf("unmarshal bucket %s lifecycle: %v", bucketEntry.Name, err)
+			continue
+		}
+		if err := s3a.applyBucketLifecycle(bucketEntry.Name, config.Rules, now); err != nil {
+			glog.Errorf("apply bucket %s lifecycle: %v", bucketEntry.Name, err)
+		}
+	}
+	return nil
+}
+
+// lifecycleTransition is an object version to be moved to another storage class
+type lifecycleTransition struct {
+	dir          string
+	entry        *filer_pb.Entry
+	storageClass string
+}
+
+// applyBucketLifecycle expires and transitions objects, and aborts incomplete multipart uploads.
+// Matching objects are collected first, so the folders are not changed while being listed.
+func (s3a *S3ApiServer) applyBucketLifecycle(bucket string, rules []*s3.LifecycleRule, now time.Time) error {
+	bucketDir := fmt.Sprintf("%s/%s", s3a.option.BucketsPath, bucket)
+	isVersioned := s3a.isVersioningConfigured(bucket)
+
+	var expiredKeys []string
+	var transitions []*lifecycleTransition
+	var expiredVersions []*objectVersion
+	var expiredVersionKeys []string
+	var abortedUploads []string
+
+	err := s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		// current objects
+		if err := s3a.walkVersionKeys(client, bucketDir, "", "", false, func(key string, entry *filer_pb.Entry) {
+			mtime := time.Unix(entry.Attributes.GetMtime(), 0)
+			storageClass := ""
+			for _, rule := range rules {
+				if !lifecycleRuleMatches(rule, key, entry) {
+					continue
+				}
+				if isExpirationDue(rule, mtime, now) {
+					expiredKeys = append(expiredKeys, key)
+					return
+				}
+				if sc := dueTransition(rule.Transitions, mtime, now); sc != "" {
+					storageClass = sc
+				}
+			}
+			if storageClass != "" {
+				dir, _ := util.FullPath(bucketDir + "/" + key).DirAndName()
+				transitions = append(transitions, &lifecycleTransition{dir: dir, entry: entry, storageClass: storageClass})
+			}
+		}); err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("walk objects: %v", err)
+		}
+
+		// noncurrent versions and delete markers
+		if isVersioned {
+			versionsByKey := make(map[string][]*objectVersion)
+			if err := s3a.walkVersionKeys(client, bucketDir+"/"+s3_constants.VersionsFolder, "", "", true, func(key string, entry *filer_pb.Entry) {
+				versionsByKey[key] = append(versionsByKey[key], &objectVersion{
+					versionId:      entry.Name,
+					entry:          entry,
+					isDeleteMarker: isDeleteMarker(entry),
+				})
+			}); err != nil {
+				return fmt.Errorf("walk versions: %v", err)
+			}
+			for key, versions := range versionsByKey {
+				sortVersions(versions)
+				dir, name := util.FullPath(bucketDir + "/" + key).DirAndName()
+				current, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{Directory: dir, Name: name})
+				if err != nil && err != filer_pb.ErrNotFound {
+					return err
+				}
+				var successor *filer_pb.Entry
+				if current != nil {
+					successor = current.Entry
+				} else {
+					// the newest archived version, usually a delete marker, is the current one
+					latest := versions[0]
+					versions = versions[1:]
+					if latest.isDeleteMarker && len(versions) == 0 && isDeleteMarkerExpirationDue(rules, key) {
+						expiredVersions = append(expiredVersions, latest)
+						expiredVersionKeys = append(expiredVersionKeys, key)
+					}
+					successor = latest.entry
+				}
+				for i, v := range versions {
+					noncurrentSince := time.Unix(successor.Attributes.GetMtime(), 0)
+					successor = v.entry
+					storageClass := ""
+					for _, rule := range rules {
+						if !lifecycleRuleMatches(rule, key, v.entry) {
+							continue
+						}
+						if isNoncurrentExpirationDue(rule, noncurrentSince, now, i) {
+							expiredVersions = append(expiredVersions, v)
+							expiredVersionKeys = append(expiredVersionKeys, key)
+							storageClass = ""
+							break
+						}
+						if sc := dueNoncurrentTransition(rule.NoncurrentVersionTransitions, noncurrentSince, now, i); sc != "" && !v.isDeleteMarker {
+							storageClass = sc
+						}
+					}
+					if storageClass != "" {
+						transitions = append(transitions, &lifecycleTransition{dir: s3a.genVersionsFolder(bucket, "/"+key), entry: v.entry, storageClass: storageClass})
+					}
+				}
+			}
+		}
+
+		// incomplete multipart uploads
+		err := filer_pb.SeaweedList(client, s3a.genUploadsFolder(bucket), "", func(entry *filer_pb.Entry, isLast bool) error {
+			if !entry.IsDirectory {
+				return nil
+			}
+			key := strings.TrimPrefix(string(entry.Extended["key"]), "/")
+			initiated := time.Unix(entry.Attributes.GetCrtime(), 0)
+			for _, rule := range rules {
+				if aws.StringValue(rule.Status) == s3.ExpirationStatusEnabled && strings.HasPrefix(key, lifecycleRulePrefix(rule)) && isAbortUploadDue(rule, initiated, now) {
+					abortedUploads = append(abortedUploads, entry.Name)
+					break
+				}
+			}
+			return nil
+		}, "", false, math.MaxInt32)
+		if err != nil && err != filer_pb.ErrNotFound {
+			return fmt.Errorf("list uploads: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expiredKeys {
+		glog.V(1).Infof("lifecycle expires %s/%s", bucket, key)
+		if isVersioned {
+			_, _, err = s3a.deleteVersionedObject(bucket, "/"+key, "")
+		} else {
+			dir, name := util.FullPath(bucketDir + "/" + key).DirAndName()
+			err = s3a.rm(dir, name, true, false)
+		}
+		if err != nil {
+			glog.Errorf("lifecycle expire %s/%s: %v", bucket, key, err)
+		}
+	}
+	for i, v := range expiredVersions {
+		glog.V(1).Infof("lifecycle deletes %s/%s version %s", bucket, expiredVersionKeys[i], v.versionId)
+		if _, _, err = s3a.deleteVersionedObject(bucket, "/"+expiredVersionKeys[i], v.versionId); err != nil {
+			glog.Errorf("lifecycle delete %s/%s version %s: %v", bucket, expiredVersionKeys[i], v.versionId, err)
+		}
+	}
+	for _, uploadId := range abortedUploads {
+		glog.V(1).Infof("lifecycle aborts upload %s in %s", uploadId, bucket)
+		if err = s3a.rm(s3a.genUploadsFolder(bucket), uploadId, true, true); err != nil {
+			glog.Errorf("lifecycle abort upload %s in %s: %v", uploadId, bucket, err)
+		}
+	}
+	for _, t := range transitions {
+		if err = s3a.transitionObject(t, now); err != nil {
+			glog.Errorf("lifecycle transition %s/%s to %s: %v", t.dir, t.entry.Name, t.storageClass, err)
+		}
+	}
+	return nil
+}
+
+// isDeleteMarkerExpirationDue tells whether a delete marker without any remaining versions should be removed
+func isDeleteMarkerExpirationDue(rules []*s3.LifecycleRule, key string) bool {
+	for _, rule := range rules {
+		if aws.StringValue(rule.Status) != s3.ExpirationStatusEnabled || !strings.HasPrefix(key, lifecycleRulePrefix(rule)) {
+			continue
+		}
+		if rule.Expiration != nil && aws.BoolValue(rule.Expiration.ExpiredObjectDeleteMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionObject moves the object data to a remote storage, if the object folder is mounted to a remote storage
+// with the storage class as name, or otherwise to volumes of the disk type named by the storage class.
+func (s3a *S3ApiServer) transitionObject(t *lifecycleTransition, now time.Time) error {
+	entry := t.entry
+	if currentClass, found := entry.Extended[s3_constants.AmzStorageClass]; found && string(currentClass) == t.storageClass {
+		return nil
+	}
+	if len(entry.GetChunks()) == 0 {
+		// stored inline or already only in the remote storage
+		return nil
+	}
+	if filer.IsObjectLocked(entry.Extended, now) {
+		glog.V(1).Infof("lifecycle skips transition of locked %s/%s", t.dir, entry.Name)
+		return nil
+	}
+
+	original := proto.Clone(entry).(*filer_pb.Entry)
+	fullPath := util.FullPath(t.dir).Child(entry.Name)
+	_, mountDir, remoteLocation, remoteConf, err := filer.DetectMountInfo(s3a.option.GrpcDialOption, s3a.option.Filer, string(fullPath))
+	if err == nil && strings.EqualFold(remoteLocation.Name, t.storageClass) {
+		err = s3a.transitionToRemoteStorage(fullPath, entry, mountDir, remoteLocation, remoteConf)
+	} else {
+		err = s3a.transitionToDiskType(fullPath, entry, types.ToDiskType(t.storageClass).String())
+	}
+	if err != nil {
+		return err
+	}
+
+	// do not revert an object overwritten while its data was copied
+	latest, err := s3a.getEntry(t.dir, entry.Name)
+	if err != nil {
+		return err
+	}
+	if !filer.IsSameData(latest, original) {
+		return fmt.Errorf("%s changed during transition", fullPath)
+	}
+
+	glog.V(1).Infof("lifecycle transitioned %s to %s", fullPath, t.storageClass)
+	if entry.Extended == nil {
+		entry.Extended = make(map[string][]byte)
+	}
+	entry.Extended[s3_constants.AmzStorageClass] = []byte(t.storageClass)
+	return s3a.updateEntry(t.dir, entry)
+}
+
+// transitionToRemoteStorage uploads the object unless it is already synchronized, then drops the local copy.
+// Later reads cache the object back from the remote storage.
+func (s3a *S3ApiServer) transitionToRemoteStorage(fullPath util.FullPath, entry *filer_pb.Entry, mountDir string, mountLocation *remote_pb.RemoteStorageLocation, remoteConf *remote_pb.RemoteConf) error {
+	if entry.RemoteEntry == nil || entry.RemoteEntry.LastLocalSyncTsNs/1e9 < entry.Attributes.Mtime {
+		client, err := remote_storage.GetRemoteStorage(remoteConf)
+		if err != nil {
+			return err
+		}
+		remoteLocation := filer.MapFullPathToRemoteStorageLocation(util.FullPath(mountDir), mountLocation, fullPath)
+		remoteEntry, err := client.WriteFile(remoteLocation, entry, filer.NewFileReader(s3a, entry))
+		if err != nil {
+			return fmt.Errorf("upload to %s: %v", remote_storage.FormatLocation(remoteLocation), err)
+		}
+		entry.RemoteEntry = remoteEntry
+	}
+	entry.RemoteEntry.LastLocalSyncTsNs = 0
+	entry.Chunks = nil
+	return nil
+}
+
+// transitionToDiskType copies every chunk to a volume of the disk type.
+// Chunk boundaries are kept, so that the object ETag does not change.
+func (s3a *S3ApiServer) transitionToDiskType(fullPath util.FullPath, entry *filer_pb.Entry, diskType string) error {
+	lookupFn := filer.LookupFn(s3a)
+	chunks, _, err := filer.ResolveChunkManifest(lookupFn, entry.GetChunks(), 0, math.MaxInt64)
+	if err != nil {
+		return fmt.Errorf("resolve chunks: %v", err)
+	}
+
+	saveFn := func(reader io.Reader, offset int64, tsNs int64, cipher bool) (*filer_pb.FileChunk, error) {
+		fileId, uploadResult, err, _ := operation.UploadWithRetry(
+			s3a,
+			&filer_pb.AssignVolumeRequest{
+				Count:    1,
+				DiskType: diskType,
+				Path:     string(fullPath),
+			},
+			&operation.UploadOption{
+				Filename: entry.Name,
+				Cipher:   cipher,
+			},
+			func(host, fileId string) string {
+				return fmt.Sprintf("http://%s/%s", host, fileId)
+			},
+			reader,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if uploadResult.Error != "" {
+			return nil, fmt.Errorf("upload result: %v", uploadResult.Error)
+		}
+		return uploadResult.ToPbFileChunk(fileId, offset, tsNs), nil
+	}
+
+	var newChunks []*filer_pb.FileChunk
+	for _, chunk := range chunks {
+		urlStrings, err := lookupFn(chunk.GetFileIdString())
+		if err != nil {
+			return err
+		}
+		data := make([]byte, chunk.Size)
+		if _, err = util.RetriedFetchChunkData(data, urlStrings, chunk.CipherKey, chunk.IsCompressed, true, 0); err != nil {
+			return fmt.Errorf("read chunk %s: %v", chunk.GetFileIdString(), err)
+		}
+		newChunk, err := saveFn(util.NewBytesReader(data), chunk.Offset, chunk.ModifiedTsNs, chunk.CipherKey != nil)
+		if err != nil {
+			return fmt.Errorf("copy chunk %s: %v", chunk.GetFileIdString(), err)
+		}
+		newChunks = append(newChunks, newChunk)
+	}
+
+	manifestedChunks, err := filer.MaybeManifestize(func(reader io.Reader, name string, offset int64, tsNs int64) (*filer_pb.FileChunk, error) {
+		return saveFn(reader, offset, tsNs, false)
+	}, newChunks)
+	if err != nil {
+		return fmt.Errorf("manifestize chunks: %v", err)
+	}
+	// the filer deletes the replaced chunks when updating the entry
+	entry.Chunks = manifestedChunks
+	return nil
+}
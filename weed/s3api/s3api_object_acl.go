@@ -0,0 +1,177 @@
+package s3api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Object ACLs are kept in the entry extended attributes, as the owner account id and the json grants.
+// They only apply when the bucket ownership is not "BucketOwnerEnforced", which is the default and
+// disables ACLs. The owner of an object always has full control on it.
+
+// ObjectAclGetter looks up the owner and grants of an object, found is false if ACLs do not apply to it
+type ObjectAclGetter interface {
+	GetObjectAcp(bucket, object, versionId string) (ownerId string, grants []*s3.Grant, found bool)
+}
+
+// GetObjectAcp implements ObjectAclGetter, for the ACLs to allow requests that the identity can not do by itself
+func (s3a *S3ApiServer) GetObjectAcp(bucket, object, versionId string) (ownerId string, grants []*s3.Grant, found bool) {
+	metadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone || metadata.ObjectOwnership == s3_constants.OwnershipBucketOwnerEnforced {
+		return "", nil, false
+	}
+
+	var entry *filer_pb.Entry
+	var err error
+	if versionId != "" {
+		_, entry, err = s3a.getObjectVersion(bucket, object, versionId)
+	} else {
+		dir, name := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object)).DirAndName()
+		entry, err = s3a.getEntry(dir, name)
+	}
+	if err != nil {
+		if err != filer_pb.ErrNotFound {
+			glog.Warningf("GetObjectAcp %s%s: %v", bucket, object, err)
+		}
+		return "", nil, false
+	}
+	if entry.IsDirectory || isDeleteMarker(entry) {
+		return "", nil, false
+	}
+	return GetAcpOwner(entry.Extended, aws.StringValue(metadata.Owner.ID)), GetAcpGrants(entry.Extended), true
+}
+
+// objectAclPermission is the ACL permission needed for an object request, "" if ACLs can not grant it.
+// READ only allows to get the object data and metadata, not its other subresources.
+func objectAclPermission(r *http.Request, action Action) string {
+	switch action {
+	case s3_constants.ACTION_READ:
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return ""
+		}
+		query := r.URL.Query()
+		for _, subresource := range []string{"uploadId", "tagging", "retention", "legal-hold"} {
+			if _, ok := query[subresource]; ok {
+				return ""
+			}
+		}
+		return s3_constants.PermissionRead
+	case s3_constants.ACTION_READ_ACP:
+		return s3_constants.PermissionReadAcp
+	case s3_constants.ACTION_WRITE_ACP:
+		return s3_constants.PermissionWriteAcp
+	}
+	return ""
+}
+
+// isGrantedByAcl checks whether the grants of an object, for a group the account belongs to
+// or for the account itself, include the permission
+func isGrantedByAcl(accountId, ownerId string, grants []*s3.Grant, permission string) bool {
+	if accountId == ownerId {
+		return true
+	}
+	for _, requested := range DetermineReqGrants(accountId, permission) {
+		for _, grant := range grants {
+			if GrantEquals(requested, grant) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasExplicitAclHeaders checks whether a write request grants permissions with x-amz-grant-* headers
+func hasExplicitAclHeaders(header http.Header) bool {
+	for _, k := range []string{s3_constants.AmzAclFullControl, s3_constants.AmzAclRead, s3_constants.AmzAclReadAcp, s3_constants.AmzAclWrite, s3_constants.AmzAclWriteAcp} {
+		if header.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// setObjectAclHeaders parses the ACL headers of a write request, and passes the owner and the grants
+// of the new object to the filer. When the bucket disables ACLs, only "bucket-owner-full-control"
+// is accepted and the bucket owner owns the object.
+func (s3a *S3ApiServer) setObjectAclHeaders(r *http.Request, bucket string) s3err.ErrorCode {
+	// only set by the gateway
+	r.Header.Del(s3_constants.ExtAmzOwnerKey)
+	r.Header.Del(s3_constants.ExtAmzAclKey)
+	if !s3a.iam.isEnabled() {
+		return s3err.ErrNone
+	}
+
+	metadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	bucketOwnerId := aws.StringValue(metadata.Owner.ID)
+
+	if metadata.ObjectOwnership == s3_constants.OwnershipBucketOwnerEnforced {
+		cannedAcl := r.Header.Get(s3_constants.AmzCannedAcl)
+		if hasExplicitAclHeaders(r.Header) || (cannedAcl != "" && cannedAcl != s3_constants.CannedAclBucketOwnerFullControl) {
+			return s3err.ErrAccessControlListNotSupported
+		}
+		SetAcpOwnerHeader(r, bucketOwnerId)
+		return s3err.ErrNone
+	}
+
+	ownerId, grants, errCode := ParseAndValidateAclHeaders(r, s3a.iam, metadata.ObjectOwnership, bucketOwnerId, getAccountId(r), false)
+	if errCode != s3err.ErrNone {
+		return errCode
+	}
+	SetAcpOwnerHeader(r, ownerId)
+	SetAcpGrantsHeader(r, grants)
+	return s3err.ErrNone
+}
+
+// hideAclHeaders removes the ACL attributes from the object headers, they are only returned by GetObjectAcl
+func hideAclHeaders(resp *http.Response) {
+	resp.Header.Del(s3_constants.ExtAmzOwnerKey)
+	resp.Header.Del(s3_constants.ExtAmzAclKey)
+}
+
+// toAccessControlPolicy builds the GetObjectAcl response, where an object without grants is private to its owner
+func (s3a *S3ApiServer) toAccessControlPolicy(ownerId string, grants []*s3.Grant) AccessControlPolicy {
+	if len(grants) == 0 {
+		grants = []*s3.Grant{{
+			Grantee:    &s3.Grantee{Type: &s3_constants.GrantTypeCanonicalUser, ID: &ownerId},
+			Permission: &s3_constants.PermissionFullControl,
+		}}
+	}
+	response := AccessControlPolicy{
+		Owner: CanonicalUser{
+			ID:          ownerId,
+			DisplayName: s3a.iam.GetAccountNameById(ownerId),
+		},
+	}
+	for _, grant := range grants {
+		if grant.Grantee == nil {
+			continue
+		}
+		granteeType := aws.StringValue(grant.Grantee.Type)
+		grantee := Grantee{
+			Type:   granteeType,
+			XMLXSI: granteeType,
+			XMLNS:  "http://www.w3.org/2001/XMLSchema-instance",
+			ID:     aws.StringValue(grant.Grantee.ID),
+			URI:    aws.StringValue(grant.Grantee.URI),
+		}
+		if grantee.ID != "" {
+			grantee.DisplayName = s3a.iam.GetAccountNameById(grantee.ID)
+		}
+		response.AccessControlList.Grant = append(response.AccessControlList.Grant, Grant{
+			Grantee:    grantee,
+			Permission: Permission(aws.StringValue(grant.Permission)),
+		})
+	}
+	return response
+}
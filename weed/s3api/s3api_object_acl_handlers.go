@@ -0,0 +1,89 @@
+package s3api
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+// GetObjectAclHandler Get object ACL
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectAcl.html
+func (s3a *S3ApiServer) GetObjectAclHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetObjectAclHandler %s %s", bucket, object)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+	metadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	_, entry, errCode := s3a.getObjectTarget(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	bucketOwnerId := aws.StringValue(metadata.Owner.ID)
+	if metadata.ObjectOwnership == s3_constants.OwnershipBucketOwnerEnforced {
+		// ACLs are disabled, the bucket owner has full control on every object
+		writeSuccessResponseXML(w, r, s3a.toAccessControlPolicy(bucketOwnerId, nil))
+		return
+	}
+	ownerId := GetAcpOwner(entry.Extended, bucketOwnerId)
+	writeSuccessResponseXML(w, r, s3a.toAccessControlPolicy(ownerId, GetAcpGrants(entry.Extended)))
+}
+
+// PutObjectAclHandler Put object ACL, either a canned ACL header or an access control policy
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectAcl.html
+func (s3a *S3ApiServer) PutObjectAclHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutObjectAclHandler %s %s", bucket, object)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+	metadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if metadata.ObjectOwnership == s3_constants.OwnershipBucketOwnerEnforced {
+		s3err.WriteErrorResponse(w, r, s3err.ErrAccessControlListNotSupported)
+		return
+	}
+
+	dir, entry, errCode := s3a.getObjectTarget(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	// the owner of an object can not be changed, and canned ACLs grant full control to the owner
+	bucketOwnerId := aws.StringValue(metadata.Owner.ID)
+	ownerId := GetAcpOwner(entry.Extended, bucketOwnerId)
+	grants, errCode := ExtractAcl(r, s3a.iam, metadata.ObjectOwnership, bucketOwnerId, ownerId, ownerId)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode = AssembleEntryWithAcp(entry, ownerId, grants); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if err := s3a.updateEntry(dir, entry); err != nil {
+		glog.Errorf("PutObjectAclHandler %s %s: %v", bucket, object, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
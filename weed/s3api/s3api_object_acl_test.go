@@ -0,0 +1,178 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+type testObjectAcls map[string][]*s3.Grant
+
+func (acls testObjectAcls) GetObjectAcp(bucket, object, versionId string) (string, []*s3.Grant, bool) {
+	grants, found := acls[bucket+object]
+	return "owner", grants, found
+}
+
+func canonicalUserGrant(id, permission string) *s3.Grant {
+	return &s3.Grant{
+		Grantee:    &s3.Grantee{Type: &s3_constants.GrantTypeCanonicalUser, ID: &id},
+		Permission: &permission,
+	}
+}
+
+func TestIsGrantedByAcl(t *testing.T) {
+	_, publicRead, _ := ParseCannedAclHeader(s3_constants.OwnershipObjectWriter, "owner", "owner", s3_constants.CannedAclPublicRead, false)
+	assert.True(t, isGrantedByAcl(s3_constants.AccountAnonymousId, "owner", publicRead, s3_constants.PermissionRead))
+	assert.True(t, isGrantedByAcl("other", "owner", publicRead, s3_constants.PermissionRead))
+	assert.False(t, isGrantedByAcl(s3_constants.AccountAnonymousId, "owner", publicRead, s3_constants.PermissionReadAcp))
+	assert.True(t, isGrantedByAcl("owner", "owner", nil, s3_constants.PermissionWriteAcp), "the owner has full control")
+
+	_, authenticatedRead, _ := ParseCannedAclHeader(s3_constants.OwnershipObjectWriter, "owner", "owner", s3_constants.CannedAclAuthenticatedRead, false)
+	assert.False(t, isGrantedByAcl(s3_constants.AccountAnonymousId, "owner", authenticatedRead, s3_constants.PermissionRead))
+	assert.True(t, isGrantedByAcl("other", "owner", authenticatedRead, s3_constants.PermissionRead))
+
+	grants := []*s3.Grant{canonicalUserGrant("reader", s3_constants.PermissionRead), canonicalUserGrant("admin", s3_constants.PermissionFullControl)}
+	assert.True(t, isGrantedByAcl("reader", "owner", grants, s3_constants.PermissionRead))
+	assert.False(t, isGrantedByAcl("reader", "owner", grants, s3_constants.PermissionWriteAcp))
+	assert.True(t, isGrantedByAcl("admin", "owner", grants, s3_constants.PermissionWriteAcp))
+	assert.False(t, isGrantedByAcl("other", "owner", grants, s3_constants.PermissionRead))
+}
+
+func TestObjectAclPermission(t *testing.T) {
+	get := httptest.NewRequest(http.MethodGet, "/bucket/object", nil)
+	assert.Equal(t, s3_constants.PermissionRead, objectAclPermission(get, s3_constants.ACTION_READ))
+	head := httptest.NewRequest(http.MethodHead, "/bucket/object?versionId=1", nil)
+	assert.Equal(t, s3_constants.PermissionRead, objectAclPermission(head, s3_constants.ACTION_READ))
+	tagging := httptest.NewRequest(http.MethodGet, "/bucket/object?tagging", nil)
+	assert.Empty(t, objectAclPermission(tagging, s3_constants.ACTION_READ))
+	put := httptest.NewRequest(http.MethodPut, "/bucket/object", nil)
+	assert.Empty(t, objectAclPermission(put, s3_constants.ACTION_WRITE))
+	assert.Equal(t, s3_constants.PermissionWriteAcp, objectAclPermission(put, s3_constants.ACTION_WRITE_ACP))
+}
+
+func TestAuthRequestObjectAcl(t *testing.T) {
+	iam := &IdentityAccessManagement{
+		isAuthEnabled: true,
+		objectAcls: testObjectAcls{
+			"bucket/public": {canonicalUserGrant("owner", s3_constants.PermissionFullControl), {
+				Grantee:    &s3.Grantee{Type: &s3_constants.GrantTypeGroup, URI: &s3_constants.GranteeGroupAllUsers},
+				Permission: &s3_constants.PermissionRead,
+			}},
+			"bucket/private": {canonicalUserGrant("owner", s3_constants.PermissionFullControl)},
+		},
+	}
+	anonymousRequest := func(method, object string) *http.Request {
+		r := httptest.NewRequest(method, "/bucket/"+object, nil)
+		return mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": object})
+	}
+
+	r := anonymousRequest(http.MethodGet, "public")
+	_, errCode := iam.authRequest(r, s3_constants.ACTION_READ)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "true", r.Header.Get(s3_constants.AmzAclAllowed))
+	assert.Equal(t, s3_constants.AccountAnonymousId, r.Header.Get(s3_constants.AmzAccountId))
+
+	_, errCode = iam.authRequest(anonymousRequest(http.MethodGet, "private"), s3_constants.ACTION_READ)
+	assert.Equal(t, s3err.ErrAccessDenied, errCode)
+	_, errCode = iam.authRequest(anonymousRequest(http.MethodGet, "missing"), s3_constants.ACTION_READ)
+	assert.Equal(t, s3err.ErrAccessDenied, errCode)
+	_, errCode = iam.authRequest(anonymousRequest(http.MethodPut, "public"), s3_constants.ACTION_WRITE)
+	assert.Equal(t, s3err.ErrAccessDenied, errCode, "public-read does not allow writes")
+}
+
+func TestAuthRequestDenyAppliesToAdmins(t *testing.T) {
+	policy, err := parseBucketPolicy([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"]}]
+}`))
+	assert.NoError(t, err)
+	iam := &IdentityAccessManagement{
+		isAuthEnabled:     true,
+		identityAnonymous: &Identity{Name: "anonymous", Account: &AccountAnonymous, Actions: []Action{s3_constants.ACTION_ADMIN}},
+		bucketPolicies:    testBucketPolicies{"bucket": policy},
+	}
+	request := func(method, target, object string) *http.Request {
+		r := httptest.NewRequest(method, target, nil)
+		return mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": object})
+	}
+
+	_, errCode := iam.authRequest(request(http.MethodGet, "/bucket/object", "object"), s3_constants.ACTION_READ)
+	assert.Equal(t, s3err.ErrAccessDenied, errCode)
+	// admins can still repair the policy
+	_, errCode = iam.authRequest(request(http.MethodDelete, "/bucket?policy", ""), s3_constants.ACTION_ADMIN)
+	assert.Equal(t, s3err.ErrNone, errCode)
+}
+
+func TestSetObjectAclHeaders(t *testing.T) {
+	newServer := func(ownership string) *S3ApiServer {
+		iam := &IdentityAccessManagement{isAuthEnabled: true, accounts: map[string]*Account{
+			"writer": {Id: "writer", DisplayName: "writer"},
+			"reader": {Id: "reader", DisplayName: "reader"},
+		}}
+		registry := &BucketRegistry{metadataCache: map[string]*BucketMetaData{"bucket": {
+			Name:            "bucket",
+			ObjectOwnership: ownership,
+			Owner:           &s3.Owner{ID: &AccountAdmin.Id},
+		}}}
+		return &S3ApiServer{iam: iam, bucketRegistry: registry}
+	}
+	newRequest := func(header map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/bucket/object", nil)
+		r.Header.Set(s3_constants.AmzAccountId, "writer")
+		r.Header.Set(s3_constants.ExtAmzAclKey, "injected")
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	s3a := newServer(s3_constants.OwnershipObjectWriter)
+	r := newRequest(map[string]string{s3_constants.AmzCannedAcl: s3_constants.CannedAclPublicRead})
+	assert.Equal(t, s3err.ErrNone, s3a.setObjectAclHeaders(r, "bucket"))
+	assert.Equal(t, "writer", r.Header.Get(s3_constants.ExtAmzOwnerKey))
+	assert.Contains(t, r.Header.Get(s3_constants.ExtAmzAclKey), s3_constants.GranteeGroupAllUsers)
+
+	r = newRequest(map[string]string{s3_constants.AmzAclRead: `id="reader"`})
+	assert.Equal(t, s3err.ErrNone, s3a.setObjectAclHeaders(r, "bucket"))
+	assert.Contains(t, r.Header.Get(s3_constants.ExtAmzAclKey), `"reader"`)
+
+	r = newRequest(nil)
+	assert.Equal(t, s3err.ErrNone, s3a.setObjectAclHeaders(r, "bucket"))
+	assert.Equal(t, "writer", r.Header.Get(s3_constants.ExtAmzOwnerKey))
+	assert.Empty(t, r.Header.Get(s3_constants.ExtAmzAclKey), "the gateway only passes parsed grants")
+
+	s3a = newServer(s3_constants.OwnershipBucketOwnerEnforced)
+	r = newRequest(map[string]string{s3_constants.AmzCannedAcl: s3_constants.CannedAclPublicRead})
+	assert.Equal(t, s3err.ErrAccessControlListNotSupported, s3a.setObjectAclHeaders(r, "bucket"))
+	r = newRequest(map[string]string{s3_constants.AmzCannedAcl: s3_constants.CannedAclBucketOwnerFullControl})
+	assert.Equal(t, s3err.ErrNone, s3a.setObjectAclHeaders(r, "bucket"))
+	assert.Equal(t, AccountAdmin.Id, r.Header.Get(s3_constants.ExtAmzOwnerKey))
+}
+
+func TestExtractObjectAclPolicy(t *testing.T) {
+	iam := &IdentityAccessManagement{accounts: map[string]*Account{"reader": {Id: "reader", DisplayName: "reader"}}}
+	body := `<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Owner><ID>owner</ID></Owner>
+  <AccessControlList>
+    <Grant>
+      <Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>reader</ID></Grantee>
+      <Permission>READ</Permission>
+    </Grant>
+  </AccessControlList>
+</AccessControlPolicy>`
+	r := httptest.NewRequest(http.MethodPut, "/bucket/object?acl", strings.NewReader(body))
+	grants, errCode := ExtractAcl(r, iam, s3_constants.OwnershipObjectWriter, "owner", "owner", "owner")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.True(t, isGrantedByAcl("reader", "owner", grants, s3_constants.PermissionRead))
+
+	r = httptest.NewRequest(http.MethodPut, "/bucket/object?acl", strings.NewReader(strings.Replace(body, "<ID>owner</ID>", "<ID>other</ID>", 1)))
+	_, errCode = ExtractAcl(r, iam, s3_constants.OwnershipObjectWriter, "owner", "owner", "owner")
+	assert.Equal(t, s3err.ErrAccessDenied, errCode, "the owner can not be changed")
+}
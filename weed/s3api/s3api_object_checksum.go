@@ -0,0 +1,287 @@
+package s3api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+// The gateway verifies Content-MD5 and the x-amz-checksum-* headers over the data it receives,
+// before any encryption, so the filer only sees data that was checked end to end.
+// The checksum is passed to the filer as a "Seaweed-" trailer, since it may only be known
+// once the whole body is read, and kept in the entry extended attributes.
+
+// checksumAlgorithm is one of the additional checksums of S3 objects
+type checksumAlgorithm struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+var checksumAlgorithms = []*checksumAlgorithm{
+	{name: "CRC32", newHash: func() hash.Hash { return crc32.NewIEEE() }},
+	{name: "CRC32C", newHash: func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }},
+	{name: "SHA1", newHash: sha1.New},
+	{name: "SHA256", newHash: sha256.New},
+}
+
+func findChecksumAlgorithm(name string) *checksumAlgorithm {
+	for _, algorithm := range checksumAlgorithms {
+		if strings.EqualFold(algorithm.name, name) {
+			return algorithm
+		}
+	}
+	return nil
+}
+
+// header is the request and response header of the checksum, such as "X-Amz-Checksum-Crc32c"
+func (algorithm *checksumAlgorithm) header() string {
+	return http.CanonicalHeaderKey(s3_constants.AmzChecksumPrefix + algorithm.name)
+}
+
+// extendedKey is the entry attribute keeping the checksum
+func (algorithm *checksumAlgorithm) extendedKey() string {
+	return http.CanonicalHeaderKey(s3_constants.ExtChecksumPrefix + algorithm.name)
+}
+
+// decode parses a base64 checksum value, checking its size
+func (algorithm *checksumAlgorithm) decode(value string) ([]byte, error) {
+	checksum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(checksum) != algorithm.newHash().Size() {
+		return nil, fmt.Errorf("invalid %s checksum size %d", algorithm.name, len(checksum))
+	}
+	return checksum, nil
+}
+
+var errBadDigest = errors.New("content digest mismatch")
+
+// checksumReader computes the checksum of the data of a write, and fails at the end of the data
+// if it does not match the Content-MD5 or x-amz-checksum-* header of the request
+type checksumReader struct {
+	reader      io.Reader
+	md5         hash.Hash
+	expectedMd5 []byte
+	algorithm   *checksumAlgorithm
+	hash        hash.Hash
+	expected    []byte
+	trailer     http.Header
+	err         error
+}
+
+// newChecksumReader parses the digest headers of a write. Without an x-amz-checksum-* header,
+// the checksum of x-amz-sdk-checksum-algorithm, or else of the default algorithm, is computed.
+// The Content-MD5 header is removed, as it is checked here instead of by the filer.
+func newChecksumReader(r *http.Request, reader io.Reader, defaultAlgorithm string) (*checksumReader, s3err.ErrorCode) {
+	for k := range r.Header {
+		if strings.HasPrefix(k, s3_constants.ExtChecksumPrefix) {
+			delete(r.Header, k)
+		}
+	}
+
+	cr := &checksumReader{reader: reader}
+	expectedMd5, err := validateContentMd5(r.Header)
+	if err != nil || (len(expectedMd5) > 0 && len(expectedMd5) != md5.Size) {
+		return nil, s3err.ErrInvalidDigest
+	}
+	if len(expectedMd5) > 0 {
+		cr.md5, cr.expectedMd5 = md5.New(), expectedMd5
+	}
+	r.Header.Del("Content-Md5")
+
+	for _, algorithm := range checksumAlgorithms {
+		value := r.Header.Get(algorithm.header())
+		if value == "" {
+			continue
+		}
+		if cr.algorithm != nil {
+			// only one checksum can be given
+			return nil, s3err.ErrInvalidChecksum
+		}
+		if cr.expected, err = algorithm.decode(value); err != nil {
+			return nil, s3err.ErrInvalidChecksum
+		}
+		cr.algorithm = algorithm
+	}
+	if cr.algorithm == nil {
+		name := r.Header.Get(s3_constants.AmzSdkChecksumAlgorithm)
+		if name == "" {
+			name = defaultAlgorithm
+		}
+		if name != "" {
+			if cr.algorithm = findChecksumAlgorithm(name); cr.algorithm == nil {
+				return nil, s3err.ErrInvalidChecksum
+			}
+		}
+	}
+	if cr.algorithm != nil {
+		cr.hash = cr.algorithm.newHash()
+		cr.trailer = http.Header{cr.algorithm.extendedKey(): nil}
+	}
+	return cr, s3err.ErrNone
+}
+
+// newPartChecksumReader verifies the data of an upload part, computing by default the checksum
+// of the algorithm given when the multipart upload was created
+func newPartChecksumReader(r *http.Request, uploadEntry *filer_pb.Entry, reader io.Reader) (*checksumReader, s3err.ErrorCode) {
+	return newChecksumReader(r, reader, string(uploadEntry.Extended[s3_constants.ExtChecksumAlgorithmKey]))
+}
+
+func (cr *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = cr.reader.Read(p)
+	if n > 0 {
+		if cr.md5 != nil {
+			cr.md5.Write(p[:n])
+		}
+		if cr.hash != nil {
+			cr.hash.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		if cr.err = cr.verify(); cr.err != nil {
+			return n, cr.err
+		}
+		if cr.trailer != nil {
+			cr.trailer.Set(cr.algorithm.extendedKey(), cr.checksum())
+		}
+	}
+	return
+}
+
+func (cr *checksumReader) verify() error {
+	if cr.md5 != nil && !bytes.Equal(cr.md5.Sum(nil), cr.expectedMd5) {
+		return fmt.Errorf("Content-MD5: %w", errBadDigest)
+	}
+	if cr.expected != nil && !bytes.Equal(cr.hash.Sum(nil), cr.expected) {
+		return fmt.Errorf("%s: %w", cr.algorithm.header(), errBadDigest)
+	}
+	return nil
+}
+
+// checksum is the base64 checksum of the data read so far, "" if no checksum is computed
+func (cr *checksumReader) checksum() string {
+	if cr == nil || cr.hash == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(cr.hash.Sum(nil))
+}
+
+// setChecksumResponseHeader returns the checksum of the written data
+func (cr *checksumReader) setChecksumResponseHeader(header http.Header) {
+	if checksum := cr.checksum(); checksum != "" {
+		header.Set(cr.algorithm.header(), checksum)
+	}
+}
+
+// entryChecksum returns the algorithm and the checksum kept on an entry, nil if it has none
+func entryChecksum(extended map[string][]byte) (*checksumAlgorithm, string) {
+	for _, algorithm := range checksumAlgorithms {
+		if value := extended[algorithm.extendedKey()]; len(value) > 0 {
+			return algorithm, string(value)
+		}
+	}
+	return nil, ""
+}
+
+// exposeChecksumHeaders translates the checksum kept on the object into its x-amz-checksum-* header,
+// only if asked with x-amz-checksum-mode and for the whole object.
+func exposeChecksumHeaders(r *http.Request, resp *http.Response) {
+	var algorithm *checksumAlgorithm
+	var checksum string
+	for k := range resp.Header {
+		if strings.HasPrefix(k, s3_constants.ExtChecksumPrefix) {
+			if a := findChecksumAlgorithm(strings.TrimPrefix(k, s3_constants.ExtChecksumPrefix)); a != nil {
+				algorithm, checksum = a, resp.Header.Get(k)
+			}
+			resp.Header.Del(k)
+		}
+	}
+	if algorithm == nil || !strings.EqualFold(r.Header.Get(s3_constants.AmzChecksumMode), "ENABLED") || resp.Header.Get("Content-Range") != "" {
+		return
+	}
+	resp.Header.Set(algorithm.header(), checksum)
+}
+
+// compositeChecksum is the checksum of a multipart object, computed over the checksums of its parts
+// and suffixed by the number of parts. It is "" unless all of the parts have a checksum of the algorithm,
+// which is the one of the first part if not given.
+func compositeChecksum(algorithm *checksumAlgorithm, parts []*filer_pb.Entry) (*checksumAlgorithm, string) {
+	if len(parts) == 0 {
+		return nil, ""
+	}
+	if algorithm == nil {
+		if algorithm, _ = entryChecksum(parts[0].Extended); algorithm == nil {
+			return nil, ""
+		}
+	}
+	h := algorithm.newHash()
+	for _, part := range parts {
+		checksum, err := algorithm.decode(string(part.Extended[algorithm.extendedKey()]))
+		if err != nil {
+			return nil, ""
+		}
+		h.Write(checksum)
+	}
+	return algorithm, fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(parts))
+}
+
+// setChecksumFields sets the checksum field of the algorithm, among those of a response
+func setChecksumFields(algorithm *checksumAlgorithm, checksum string, crc32Field, crc32cField, sha1Field, sha256Field **string) {
+	if algorithm == nil || checksum == "" {
+		return
+	}
+	switch algorithm.name {
+	case "CRC32":
+		*crc32Field = &checksum
+	case "CRC32C":
+		*crc32cField = &checksum
+	case "SHA1":
+		*sha1Field = &checksum
+	case "SHA256":
+		*sha256Field = &checksum
+	}
+}
+
+// checksum is the checksum of the algorithm given for the part to complete a multipart upload
+func (part CompletedPart) checksum(algorithm *checksumAlgorithm) string {
+	switch algorithm.name {
+	case "CRC32":
+		return part.ChecksumCRC32
+	case "CRC32C":
+		return part.ChecksumCRC32C
+	case "SHA1":
+		return part.ChecksumSHA1
+	}
+	return part.ChecksumSHA256
+}
+
+// checkCompletedPartChecksums compares the checksums given to complete a multipart upload with the ones of the parts
+func checkCompletedPartChecksums(parts []CompletedPart, partEntries map[int]*filer_pb.Entry) s3err.ErrorCode {
+	for _, part := range parts {
+		for _, algorithm := range checksumAlgorithms {
+			given := part.checksum(algorithm)
+			if given == "" {
+				continue
+			}
+			entry := partEntries[part.PartNumber]
+			if entry == nil || string(entry.Extended[algorithm.extendedKey()]) != given {
+				return s3err.ErrInvalidPart
+			}
+		}
+	}
+	return s3err.ErrNone
+}
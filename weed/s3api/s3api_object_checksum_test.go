@@ -0,0 +1,159 @@
+package s3api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	weed_server "github.com/seaweedfs/seaweedfs/weed/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func crc32Value(data string) string {
+	h := crc32.NewIEEE()
+	h.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func md5Value(data string) string {
+	sum := md5.Sum([]byte(data))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func newPutRequest(data string, header map[string]string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/bucket/object", strings.NewReader(data))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+func TestChecksumReader(t *testing.T) {
+	data := "hello checksum"
+
+	r := newPutRequest(data, map[string]string{"Content-Md5": md5Value(data), "X-Amz-Checksum-Crc32": crc32Value(data)})
+	cr, errCode := newChecksumReader(r, r.Body, "")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Empty(t, r.Header.Get("Content-Md5"), "checked by the gateway")
+	read, err := io.ReadAll(cr)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(read))
+	assert.Equal(t, crc32Value(data), cr.trailer.Get("Seaweed-X-Amz-Checksum-Crc32"))
+
+	r = newPutRequest(data, map[string]string{"X-Amz-Checksum-Crc32": crc32Value("other")})
+	cr, _ = newChecksumReader(r, r.Body, "")
+	_, err = io.ReadAll(cr)
+	assert.ErrorIs(t, err, errBadDigest)
+
+	r = newPutRequest(data, map[string]string{"Content-Md5": md5Value("other")})
+	cr, _ = newChecksumReader(r, r.Body, "")
+	_, err = io.ReadAll(cr)
+	assert.ErrorIs(t, err, errBadDigest)
+
+	r = newPutRequest(data, map[string]string{s3_constants.AmzSdkChecksumAlgorithm: "sha256"})
+	cr, _ = newChecksumReader(r, r.Body, "")
+	_, err = io.ReadAll(cr)
+	assert.NoError(t, err)
+	header := http.Header{}
+	cr.setChecksumResponseHeader(header)
+	assert.Equal(t, "IYd2bruT9X+8tTtVmmErwvlcS8MGq/Nd+hPn5+rVjOA=", header.Get("X-Amz-Checksum-Sha256"))
+
+	r = newPutRequest(data, map[string]string{"X-Amz-Checksum-Crc32": "not base64"})
+	_, errCode = newChecksumReader(r, r.Body, "")
+	assert.Equal(t, s3err.ErrInvalidChecksum, errCode)
+	r = newPutRequest(data, map[string]string{"X-Amz-Checksum-Crc32": crc32Value(data), "X-Amz-Checksum-Sha1": "AAAA"})
+	_, errCode = newChecksumReader(r, r.Body, "")
+	assert.Equal(t, s3err.ErrInvalidChecksum, errCode, "more than one checksum")
+	r = newPutRequest(data, nil)
+	_, errCode = newChecksumReader(r, r.Body, "md4")
+	assert.Equal(t, s3err.ErrInvalidChecksum, errCode)
+}
+
+func TestPutToFilerChecksumTrailer(t *testing.T) {
+	var savedChecksum string
+	filerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return
+		}
+		savedChecksum = r.Trailer.Get("Seaweed-X-Amz-Checksum-Crc32c")
+		json.NewEncoder(w).Encode(weed_server.FilerPostResult{})
+	}))
+	defer filerServer.Close()
+	s3a := &S3ApiServer{client: filerServer.Client(), filerGuard: &security.Guard{}, option: &S3ApiServerOption{}}
+
+	data := "some data to checksum"
+	r := newPutRequest(data, map[string]string{s3_constants.AmzSdkChecksumAlgorithm: "CRC32C"})
+	cr, _ := newChecksumReader(r, r.Body, "")
+	_, errCode := s3a.putToFiler(r, filerServer.URL+"/buckets/bucket/object", cr, "", "bucket", cr)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, cr.checksum(), savedChecksum)
+	assert.NotEmpty(t, savedChecksum)
+
+	savedChecksum = ""
+	r = newPutRequest(data, map[string]string{"X-Amz-Checksum-Crc32c": cr.checksum(), "Content-Md5": md5Value("other")})
+	cr, _ = newChecksumReader(r, r.Body, "")
+	_, errCode = s3a.putToFiler(r, filerServer.URL+"/buckets/bucket/object", cr, "", "bucket", cr)
+	assert.Equal(t, s3err.ErrBadDigest, errCode)
+	assert.Empty(t, savedChecksum, "the upload is aborted")
+}
+
+func TestExposeChecksumHeaders(t *testing.T) {
+	newResponse := func() *http.Response {
+		return &http.Response{Header: http.Header{
+			"Seaweed-X-Amz-Checksum-Sha1":      []string{"checksum"},
+			"Seaweed-X-Amz-Checksum-Algorithm": []string{"SHA1"},
+		}}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/bucket/object", nil)
+	resp := newResponse()
+	exposeChecksumHeaders(r, resp)
+	assert.Empty(t, resp.Header, "only returned with x-amz-checksum-mode")
+
+	r.Header.Set(s3_constants.AmzChecksumMode, "ENABLED")
+	resp = newResponse()
+	exposeChecksumHeaders(r, resp)
+	assert.Equal(t, http.Header{"X-Amz-Checksum-Sha1": []string{"checksum"}}, resp.Header)
+
+	resp = newResponse()
+	resp.Header.Set("Content-Range", "bytes 0-1/10")
+	exposeChecksumHeaders(r, resp)
+	assert.Empty(t, resp.Header.Get("X-Amz-Checksum-Sha1"), "not for a range")
+}
+
+func TestCompositeChecksum(t *testing.T) {
+	part := func(checksum string) *filer_pb.Entry {
+		return &filer_pb.Entry{Extended: map[string][]byte{"Seaweed-X-Amz-Checksum-Crc32": []byte(checksum)}}
+	}
+	parts := []*filer_pb.Entry{part(crc32Value("a")), part(crc32Value("b"))}
+	algorithm, checksum := compositeChecksum(nil, parts)
+	assert.Equal(t, "CRC32", algorithm.name)
+	raw := append(mustDecode(crc32Value("a")), mustDecode(crc32Value("b"))...)
+	h := crc32.NewIEEE()
+	h.Write(raw)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(h.Sum(nil))+"-2", checksum)
+
+	_, checksum = compositeChecksum(findChecksumAlgorithm("SHA256"), parts)
+	assert.Empty(t, checksum, "parts without the checksum")
+	_, checksum = compositeChecksum(nil, append(parts, &filer_pb.Entry{}))
+	assert.Empty(t, checksum)
+
+	entries := map[int]*filer_pb.Entry{1: parts[0], 2: parts[1]}
+	assert.Equal(t, s3err.ErrNone, checkCompletedPartChecksums([]CompletedPart{{PartNumber: 1, ChecksumCRC32: crc32Value("a")}, {PartNumber: 2}}, entries))
+	assert.Equal(t, s3err.ErrInvalidPart, checkCompletedPartChecksums([]CompletedPart{{PartNumber: 2, ChecksumCRC32: crc32Value("a")}}, entries))
+	assert.Equal(t, s3err.ErrInvalidPart, checkCompletedPartChecksums([]CompletedPart{{PartNumber: 1, ChecksumSHA1: "AAAA"}}, entries))
+}
+
+func mustDecode(value string) []byte {
+	decoded, _ := base64.StdEncoding.DecodeString(value)
+	return decoded
+}
@@ -0,0 +1,38 @@
+package s3api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCopySourcePreconditions(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	entry := &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mtime: mtime.Unix(), Md5: []byte{0xab, 0xcd}}}
+	check := func(header map[string]string) s3err.ErrorCode {
+		h := http.Header{}
+		for k, v := range header {
+			h.Set(k, v)
+		}
+		return checkCopySourcePreconditions(h, entry)
+	}
+	before, after := mtime.Add(-time.Hour).Format(http.TimeFormat), mtime.Add(time.Hour).Format(http.TimeFormat)
+
+	assert.Equal(t, s3err.ErrNone, check(nil))
+	assert.Equal(t, s3err.ErrNone, check(map[string]string{s3_constants.AmzCopySourceIfMatch: `"abcd"`}))
+	assert.Equal(t, s3err.ErrPreconditionFailed, check(map[string]string{s3_constants.AmzCopySourceIfMatch: `"ffff"`}))
+	assert.Equal(t, s3err.ErrPreconditionFailed, check(map[string]string{s3_constants.AmzCopySourceIfNoneMatch: `"abcd"`}))
+	assert.Equal(t, s3err.ErrNone, check(map[string]string{s3_constants.AmzCopySourceIfUnmodifiedSince: after}))
+	assert.Equal(t, s3err.ErrPreconditionFailed, check(map[string]string{s3_constants.AmzCopySourceIfUnmodifiedSince: before}))
+	assert.Equal(t, s3err.ErrNone, check(map[string]string{s3_constants.AmzCopySourceIfModifiedSince: before}))
+	assert.Equal(t, s3err.ErrPreconditionFailed, check(map[string]string{s3_constants.AmzCopySourceIfModifiedSince: after}))
+	assert.Equal(t, s3err.ErrNone, check(map[string]string{
+		s3_constants.AmzCopySourceIfMatch:           `"abcd"`,
+		s3_constants.AmzCopySourceIfUnmodifiedSince: before,
+	}), "a matching etag takes precedence")
+}
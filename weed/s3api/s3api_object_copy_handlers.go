@@ -32,10 +32,27 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 		cpSrcPath = r.Header.Get("X-Amz-Copy-Source")
 	}
 
+	cpSrcPath, srcVersionId := splitCopySourceVersionId(cpSrcPath)
 	srcBucket, srcObject := pathToBucketAndObject(cpSrcPath)
 
 	glog.V(3).Infof("CopyObjectHandler %s %s => %s %s", srcBucket, srcObject, dstBucket, dstObject)
 
+	if isVersionsFolderKey(srcObject) || isVersionsFolderKey(dstObject) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	srcCustomerKey, errCode := parseSseCustomerKey(r.Header, true)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	if errCode := checkWebsiteRedirectLocation(r.Header); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
 	replaceMeta, replaceTagging := replaceDirective(r.Header)
 
 	if (srcBucket == dstBucket && srcObject == dstObject || cpSrcPath == "") && (replaceMeta || replaceTagging) {
@@ -46,6 +63,10 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
 			return
 		}
+		if errCode := checkCopySourcePreconditions(r.Header, entry); errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
 		entry.Extended, err = processMetadataBytes(r.Header, entry.Extended, replaceMeta, replaceTagging)
 		entry.Attributes.Mtime = time.Now().Unix()
 		if err != nil {
@@ -70,17 +91,34 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
 		return
 	}
+	if srcVersionId != "" {
+		versionPath, entry, err := s3a.getObjectVersion(srcBucket, srcObject, srcVersionId)
+		if err != nil || entry.IsDirectory || isDeleteMarker(entry) {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
+			return
+		}
+		srcObject = versionPath
+	}
 	srcPath := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, srcBucket, srcObject))
 	dir, name := srcPath.DirAndName()
-	if entry, err := s3a.getEntry(dir, name); err != nil || entry.IsDirectory {
+	srcEntry, err := s3a.getEntry(dir, name)
+	if err != nil || srcEntry.IsDirectory {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
 		return
 	}
+	if errCode := checkCopySourcePreconditions(r.Header, srcEntry); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
 
 	if srcBucket == dstBucket && srcObject == dstObject {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopyDest)
 		return
 	}
+	if errCode := s3a.checkWritePreconditions(r, dstBucket, dstObject); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
 
 	dstUrl := fmt.Sprintf("http://%s%s/%s%s",
 		s3a.option.Filer.ToHttpAddress(), s3a.option.BucketsPath, dstBucket, urlEscapeObject(dstObject))
@@ -94,21 +132,66 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 	}
 	defer util.CloseResponse(resp)
 
+	srcReader, _, errCode := s3a.decryptObject(resp.Header, resp.Body, srcCustomerKey)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
 	tagErr := processMetadata(r.Header, resp.Header, replaceMeta, replaceTagging, s3a.getTags, dir, name)
 	if tagErr != nil {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
 		return
 	}
+	if errCode := s3a.setObjectLockHeaders(r, dstBucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.setObjectAclHeaders(r, dstBucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	// the copy keeps the checksum algorithm of the source, unless another one is asked
+	checksumAlgorithm := r.Header.Get(s3_constants.AmzChecksumAlgorithm)
+	if srcAlgorithm, _ := entryChecksum(srcEntry.Extended); checksumAlgorithm == "" && srcAlgorithm != nil {
+		checksumAlgorithm = srcAlgorithm.name
+	}
+	checksum, errCode := newChecksumReader(r, srcReader, checksumAlgorithm)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	dstReader, sseInfo, errCode := s3a.encryptObject(r, checksum)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
 	glog.V(2).Infof("copy from %s to %s", srcUrl, dstUrl)
+	versionId, errCode := s3a.setVersionIdHeader(r, dstBucket, dstObject)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
 	destination := fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, dstBucket, dstObject)
-	etag, errCode := s3a.putToFiler(r, dstUrl, resp.Body, destination, dstBucket)
+	etag, errCode := s3a.putToFiler(r, dstUrl, dstReader, destination, dstBucket, checksum)
 
 	if errCode != s3err.ErrNone {
+		s3a.rollbackVersionedWrite(dstBucket, dstObject)
 		s3err.WriteErrorResponse(w, r, errCode)
 		return
 	}
 
 	setEtag(w, etag)
+	checksum.setChecksumResponseHeader(w.Header())
+	if versionId != "" {
+		w.Header().Set(s3_constants.AmzVersionId, versionId)
+	}
+	if srcVersionId != "" {
+		w.Header().Set("X-Amz-Copy-Source-Version-Id", srcVersionId)
+	}
+	if sseInfo != nil {
+		setSseResponseHeaders(w.Header(), sseInfo)
+	}
 
 	response := CopyObjectResult{
 		ETag:         etag,
@@ -119,6 +202,14 @@ func (s3a *S3ApiServer) CopyObjectHandler(w http.ResponseWriter, r *http.Request
 
 }
 
+// splitCopySourceVersionId separates the optional "?versionId=" suffix from the copy source
+func splitCopySourceVersionId(cpSrcPath string) (path, versionId string) {
+	if i := strings.Index(cpSrcPath, "?versionId="); i >= 0 {
+		return cpSrcPath[:i], cpSrcPath[i+len("?versionId="):]
+	}
+	return cpSrcPath, ""
+}
+
 func pathToBucketAndObject(path string) (bucket, object string) {
 	path = strings.TrimPrefix(path, "/")
 	parts := strings.SplitN(path, "/", 2)
@@ -145,12 +236,31 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 		cpSrcPath = r.Header.Get("X-Amz-Copy-Source")
 	}
 
+	cpSrcPath, srcVersionId := splitCopySourceVersionId(cpSrcPath)
 	srcBucket, srcObject := pathToBucketAndObject(cpSrcPath)
 	// If source object is empty or bucket is empty, reply back invalid copy source.
 	if srcObject == "" || srcBucket == "" {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
 		return
 	}
+	if srcVersionId != "" {
+		versionPath, entry, err := s3a.getObjectVersion(srcBucket, srcObject, srcVersionId)
+		if err != nil || entry.IsDirectory || isDeleteMarker(entry) {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
+			return
+		}
+		srcObject = versionPath
+	}
+	srcDir, srcName := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, srcBucket, srcObject)).DirAndName()
+	srcEntry, err := s3a.getEntry(srcDir, srcName)
+	if err != nil || srcEntry.IsDirectory {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidCopySource)
+		return
+	}
+	if errCode := checkCopySourcePreconditions(r.Header, srcEntry); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
 
 	uploadID := r.URL.Query().Get("uploadId")
 	partIDString := r.URL.Query().Get("partNumber")
@@ -163,12 +273,28 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 
 	glog.V(3).Infof("CopyObjectPartHandler %s %s => %s part %d", srcBucket, srcObject, dstBucket, partID)
 
+	if isVersionsFolderKey(srcObject) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
 	// check partID with maximum part ID for multipart objects
 	if partID > globalMaxPartID {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidMaxParts)
 		return
 	}
 
+	srcCustomerKey, errCode := parseSseCustomerKey(r.Header, true)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	uploadEntry, err := s3a.getEntry(s3a.genUploadsFolder(dstBucket), uploadID)
+	if err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchUpload)
+		return
+	}
+
 	rangeHeader := r.Header.Get("x-amz-copy-source-range")
 
 	dstUrl := s3a.genPartUploadUrl(dstBucket, uploadID, partID)
@@ -183,9 +309,28 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 	defer util.CloseResponse(resp)
 	defer dataReader.Close()
 
+	srcReader, _, errCode := s3a.decryptObject(resp.Header, dataReader, srcCustomerKey)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	// conditional writes do not apply to parts
+	r.Header.Del("If-Match")
+	r.Header.Del("If-None-Match")
+	checksum, errCode := newPartChecksumReader(r, uploadEntry, srcReader)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	partReader, sseInfo, errCode := s3a.encryptPart(r, uploadEntry, checksum)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
 	glog.V(2).Infof("copy from %s to %s", srcUrl, dstUrl)
 	destination := fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, dstBucket, dstObject)
-	etag, errCode := s3a.putToFiler(r, dstUrl, dataReader, destination, dstBucket)
+	etag, errCode := s3a.putToFiler(r, dstUrl, partReader, destination, dstBucket, checksum)
 
 	if errCode != s3err.ErrNone {
 		s3err.WriteErrorResponse(w, r, errCode)
@@ -193,6 +338,10 @@ func (s3a *S3ApiServer) CopyObjectPartHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	setEtag(w, etag)
+	checksum.setChecksumResponseHeader(w.Header())
+	if sseInfo != nil {
+		setSseResponseHeaders(w.Header(), sseInfo)
+	}
 
 	response := CopyPartResult{
 		ETag:         etag,
@@ -268,6 +417,22 @@ func processMetadataBytes(reqHeader http.Header, existing map[string][]byte, rep
 	if sc := reqHeader.Get(s3_constants.AmzStorageClass); len(sc) > 0 {
 		metadata[s3_constants.AmzStorageClass] = []byte(sc)
 	}
+	// the website redirect is never copied, only set by the request
+	if location := reqHeader.Get(s3_constants.AmzWebsiteRedirectLocation); len(location) > 0 && s3_constants.IsValidWebsiteRedirectLocation(location) {
+		metadata[s3_constants.AmzWebsiteRedirectLocation] = []byte(location)
+	}
+
+	// replacing the metadata in place keeps the version, its object lock, its encryption and its checksum
+	for _, k := range append([]string{s3_constants.ExtVersionIdKey, s3_constants.AmzObjectLockMode, s3_constants.AmzObjectLockRetainUntilDate, s3_constants.AmzObjectLockLegalHold}, sseExtendedKeys...) {
+		if v, found := existing[k]; found {
+			metadata[k] = v
+		}
+	}
+	for k, v := range existing {
+		if strings.HasPrefix(k, s3_constants.ExtChecksumPrefix) {
+			metadata[k] = v
+		}
+	}
 
 	if replaceMeta {
 		for header, values := range reqHeader {
@@ -49,6 +49,11 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 	bucket, object := s3_constants.GetBucketAndObject(r)
 	glog.V(3).Infof("PutObjectHandler %s %s", bucket, object)
 
+	if isVersionsFolderKey(object) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
 	_, err := validateContentMd5(r.Header)
 	if err != nil {
 		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidDigest)
@@ -69,6 +74,25 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if errCode := checkWebsiteRedirectLocation(r.Header); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	if errCode := s3a.setObjectLockHeaders(r, bucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.setObjectAclHeaders(r, bucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	if errCode := s3a.checkWritePreconditions(r, bucket, object); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
 	dataReader := r.Body
 	rAuthType := getRequestAuthType(r)
 	if s3a.iam.isEnabled() {
@@ -115,14 +139,40 @@ func (s3a *S3ApiServer) PutObjectHandler(w http.ResponseWriter, r *http.Request)
 			dataReader = mimeDetect(r, dataReader)
 		}
 
-		etag, errCode := s3a.putToFiler(r, uploadUrl, dataReader, "", bucket)
+		checksum, errCode := newChecksumReader(r, dataReader, "")
+		if errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+
+		objectReader, sseInfo, errCode := s3a.encryptObject(r, checksum)
+		if errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
 
+		versionId, errCode := s3a.setVersionIdHeader(r, bucket, object)
 		if errCode != s3err.ErrNone {
 			s3err.WriteErrorResponse(w, r, errCode)
 			return
 		}
 
+		etag, errCode := s3a.putToFiler(r, uploadUrl, objectReader, "", bucket, checksum)
+
+		if errCode != s3err.ErrNone {
+			s3a.rollbackVersionedWrite(bucket, object)
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+
 		setEtag(w, etag)
+		checksum.setChecksumResponseHeader(w.Header())
+		if versionId != "" {
+			w.Header().Set(s3_constants.AmzVersionId, versionId)
+		}
+		if sseInfo != nil {
+			setSseResponseHeaders(w.Header(), sseInfo)
+		}
 	}
 
 	writeSuccessResponseEmpty(w, r)
@@ -176,14 +226,42 @@ func (s3a *S3ApiServer) GetObjectHandler(w http.ResponseWriter, r *http.Request)
 	bucket, object := s3_constants.GetBucketAndObject(r)
 	glog.V(3).Infof("GetObjectHandler %s %s", bucket, object)
 
+	if isVersionsFolderKey(object) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
 	if strings.HasSuffix(r.URL.Path, "/") {
 		s3err.WriteErrorResponse(w, r, s3err.ErrNotImplemented)
 		return
 	}
 
-	destUrl := s3a.toFilerUrl(bucket, object)
+	customerKey, errCode := parseSseCustomerKey(r.Header, false)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	removeSseRequestHeaders(r.Header)
 
-	s3a.proxyToFiler(w, r, destUrl, false, passThroughResponse)
+	rule, errCode := s3a.getObjectTransformation(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	proxyReq, responseFn := r, passThroughResponse
+	if rule != nil {
+		if proxyReq, responseFn, errCode = s3a.transformedRequest(r, bucket, object, rule); errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+	}
+
+	destUrl, ok := s3a.getVersionedObjectUrl(w, r, bucket, object)
+	if !ok {
+		return
+	}
+
+	s3a.proxyToFiler(w, proxyReq, destUrl, false, s3a.decryptedResponse(r, customerKey, responseFn))
 }
 
 func (s3a *S3ApiServer) HeadObjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -191,9 +269,38 @@ func (s3a *S3ApiServer) HeadObjectHandler(w http.ResponseWriter, r *http.Request
 	bucket, object := s3_constants.GetBucketAndObject(r)
 	glog.V(3).Infof("HeadObjectHandler %s %s", bucket, object)
 
-	destUrl := s3a.toFilerUrl(bucket, object)
+	if isVersionsFolderKey(object) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	customerKey, errCode := parseSseCustomerKey(r.Header, false)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	removeSseRequestHeaders(r.Header)
+
+	// the size and the etag are the ones of the object returned by GET
+	rule, errCode := s3a.getObjectTransformation(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	proxyReq, responseFn := r, passThroughResponse
+	if rule != nil {
+		if proxyReq, responseFn, errCode = s3a.transformedRequest(r, bucket, object, rule); errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+	}
+
+	destUrl, ok := s3a.getVersionedObjectUrl(w, r, bucket, object)
+	if !ok {
+		return
+	}
 
-	s3a.proxyToFiler(w, r, destUrl, false, passThroughResponse)
+	s3a.proxyToFiler(w, proxyReq, destUrl, false, s3a.decryptedResponse(r, customerKey, responseFn))
 }
 
 func (s3a *S3ApiServer) DeleteObjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -201,6 +308,34 @@ func (s3a *S3ApiServer) DeleteObjectHandler(w http.ResponseWriter, r *http.Reque
 	bucket, object := s3_constants.GetBucketAndObject(r)
 	glog.V(3).Infof("DeleteObjectHandler %s %s", bucket, object)
 
+	if isVersionsFolderKey(object) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	if s3a.isVersioningConfigured(bucket) {
+		if errCode := s3a.checkObjectLockForDelete(r, bucket, object, r.URL.Query().Get("versionId")); errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+		versionId, deleteMarker, err := s3a.deleteVersionedObject(bucket, object, r.URL.Query().Get("versionId"))
+		if err == errNoSuchVersion {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchVersion)
+			return
+		}
+		if err != nil {
+			glog.Errorf("DeleteObjectHandler %s %s: %v", bucket, object, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+		w.Header().Set(s3_constants.AmzVersionId, versionId)
+		if deleteMarker {
+			w.Header().Set(s3_constants.AmzDeleteMarker, "true")
+		}
+		s3err.WriteEmptyResponse(w, r, http.StatusNoContent)
+		return
+	}
+
 	destUrl := s3a.toFilerUrl(bucket, object)
 
 	s3a.proxyToFiler(w, r, destUrl, true, func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int) {
@@ -215,7 +350,10 @@ func (s3a *S3ApiServer) DeleteObjectHandler(w http.ResponseWriter, r *http.Reque
 
 // / ObjectIdentifier carries key name for the object to delete.
 type ObjectIdentifier struct {
-	ObjectName string `xml:"Key"`
+	ObjectName            string `xml:"Key"`
+	VersionId             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionId string `xml:"DeleteMarkerVersionId,omitempty"`
 }
 
 // DeleteObjectsRequest - xml carrying the object key names which needs to be deleted.
@@ -276,6 +414,7 @@ func (s3a *S3ApiServer) DeleteMultipleObjectsHandler(w http.ResponseWriter, r *h
 	if s3err.Logger != nil {
 		auditLog = s3err.GetAccessLog(r, http.StatusNoContent, s3err.ErrNone)
 	}
+	isVersioned := s3a.isVersioningConfigured(bucket)
 	s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
 
 		// delete file entries
@@ -283,6 +422,51 @@ func (s3a *S3ApiServer) DeleteMultipleObjectsHandler(w http.ResponseWriter, r *h
 			if object.ObjectName == "" {
 				continue
 			}
+			if isVersionsFolderKey(object.ObjectName) {
+				apiError := s3err.GetAPIError(s3err.ErrInvalidRequest)
+				deleteErrors = append(deleteErrors, DeleteError{
+					Code:    apiError.Code,
+					Message: apiError.Description,
+					Key:     object.ObjectName,
+				})
+				continue
+			}
+			if isVersioned {
+				objectPath := "/" + strings.TrimPrefix(object.ObjectName, "/")
+				if errCode := s3a.checkObjectLockForDelete(r, bucket, objectPath, object.VersionId); errCode != s3err.ErrNone {
+					apiError := s3err.GetAPIError(errCode)
+					deleteErrors = append(deleteErrors, DeleteError{
+						Code:    apiError.Code,
+						Message: apiError.Description,
+						Key:     object.ObjectName,
+					})
+					continue
+				}
+				versionId, deleteMarker, err := s3a.deleteVersionedObject(bucket, objectPath, object.VersionId)
+				if err == errNoSuchVersion {
+					apiError := s3err.GetAPIError(s3err.ErrNoSuchVersion)
+					deleteErrors = append(deleteErrors, DeleteError{
+						Code:    apiError.Code,
+						Message: apiError.Description,
+						Key:     object.ObjectName,
+					})
+					continue
+				}
+				if err != nil {
+					deleteErrors = append(deleteErrors, DeleteError{
+						Code:    "",
+						Message: err.Error(),
+						Key:     object.ObjectName,
+					})
+					continue
+				}
+				if deleteMarker {
+					object.DeleteMarker = true
+					object.DeleteMarkerVersionId = versionId
+				}
+				deletedObjects = append(deletedObjects, object)
+				continue
+			}
 			lastSeparator := strings.LastIndex(object.ObjectName, "/")
 			parentDirectoryPath, entryName, isDeleteData, isRecursive := "", object.ObjectName, true, false
 			if lastSeparator > 0 && lastSeparator+1 < len(object.ObjectName) {
@@ -445,6 +629,9 @@ func (s3a *S3ApiServer) proxyToFiler(w http.ResponseWriter, r *http.Request, des
 	}
 
 	setUserMetadataKeyToLowercase(resp)
+	exposeVersionHeaders(resp)
+	exposeChecksumHeaders(r, resp)
+	hideAclHeaders(resp)
 
 	responseStatusCode := responseFn(resp, w)
 	s3err.PostLog(r, responseStatusCode, s3err.ErrNone)
@@ -478,7 +665,9 @@ func passThroughResponse(proxyResponse *http.Response, w http.ResponseWriter) (s
 	return statusCode
 }
 
-func (s3a *S3ApiServer) putToFiler(r *http.Request, uploadUrl string, dataReader io.Reader, destination string, bucket string) (etag string, code s3err.ErrorCode) {
+// putToFiler writes the data to the filer. The checksum, if not nil, is the reader verifying the data
+// under any encryption, and its checksum is sent to the filer as a trailer.
+func (s3a *S3ApiServer) putToFiler(r *http.Request, uploadUrl string, dataReader io.Reader, destination string, bucket string, checksum *checksumReader) (etag string, code s3err.ErrorCode) {
 
 	hash := md5.New()
 	var body = io.TeeReader(dataReader, hash)
@@ -506,11 +695,25 @@ func (s3a *S3ApiServer) putToFiler(r *http.Request, uploadUrl string, dataReader
 			proxyReq.Header.Add(header, value)
 		}
 	}
+	if _, object := s3_constants.GetBucketAndObject(r); !s3a.isReplicaWrite(r, bucket, object) {
+		proxyReq.Header.Del(s3_constants.AmzReplicationStatus)
+	}
 	// ensure that the Authorization header is overriding any previous
 	// Authorization header which might be already present in proxyReq
 	s3a.maybeAddFilerJwtAuthorization(proxyReq, true)
+	if checksum != nil && checksum.trailer != nil {
+		proxyReq.Trailer = checksum.trailer
+	}
 	resp, postErr := s3a.client.Do(proxyReq)
 
+	if checksum != nil && checksum.err != nil {
+		// the upload is aborted, nothing is written
+		glog.V(1).Infof("upload to %s: %v", uploadUrl, checksum.err)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return "", s3err.ErrBadDigest
+	}
 	if postErr != nil {
 		glog.Errorf("post to filer: %v", postErr)
 		return "", s3err.ErrInternalError
@@ -538,6 +741,16 @@ func (s3a *S3ApiServer) putToFiler(r *http.Request, uploadUrl string, dataReader
 	return etag, s3err.ErrNone
 }
 
+// putObjectBytes writes an object generated by the gateway itself, such as a report
+func (s3a *S3ApiServer) putObjectBytes(bucket, key, contentType string, data []byte) error {
+	r, _ := http.NewRequest(http.MethodPut, "", nil)
+	r.Header.Set("Content-Type", contentType)
+	if _, errCode := s3a.putToFiler(r, s3a.toFilerUrl(bucket, "/"+key), bytes.NewReader(data), "", bucket, nil); errCode != s3err.ErrNone {
+		return fmt.Errorf("put %s/%s: %s", bucket, key, s3err.GetAPIError(errCode).Code)
+	}
+	return nil
+}
+
 func setEtag(w http.ResponseWriter, etag string) {
 	if etag != "" {
 		if strings.HasPrefix(etag, "\"") {
@@ -554,6 +767,8 @@ func filerErrorToS3Error(errString string) s3err.ErrorCode {
 		return s3err.ErrExistingObjectIsDirectory
 	case strings.HasSuffix(errString, "is a file"):
 		return s3err.ErrExistingObjectIsFile
+	case strings.HasSuffix(errString, filer.ErrPreconditionFailed.Error()):
+		return s3err.ErrPreconditionFailed
 	default:
 		return s3err.ErrInternalError
 	}
@@ -57,6 +57,10 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 		formValues.Set("Key", strings.Replace(formValues.Get("Key"), "${filename}", fileName, -1))
 	}
 	object := formValues.Get("Key")
+	if isVersionsFolderKey(object) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
 
 	successRedirect := formValues.Get("success_action_redirect")
 	successStatus := formValues.Get("success_action_status")
@@ -134,14 +138,58 @@ func (s3a *S3ApiServer) PostPolicyBucketHandler(w http.ResponseWriter, r *http.R
 		if strings.HasPrefix(k, s3_constants.AmzUserMetaPrefix) {
 			r.Header.Set(k, formValues.Get(k))
 		}
+
+		if k == s3_constants.AmzObjectLockMode || k == s3_constants.AmzObjectLockRetainUntilDate || k == s3_constants.AmzObjectLockLegalHold {
+			r.Header.Set(k, formValues.Get(k))
+		}
+
+		if strings.HasPrefix(k, s3_constants.AmzServerSideEncryption) {
+			r.Header.Set(k, formValues.Get(k))
+		}
+
+		if k == "Acl" {
+			r.Header.Set(s3_constants.AmzCannedAcl, formValues.Get(k))
+		}
+	}
+
+	if errCode := checkWebsiteRedirectLocation(r.Header); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.setObjectLockHeaders(r, bucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.setObjectAclHeaders(r, bucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	objectReader, sseInfo, errCode := s3a.encryptObject(r, fileBody)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	versionId, errCode := s3a.setVersionIdHeader(r, bucket, "/"+strings.TrimPrefix(object, "/"))
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
 	}
 
-	etag, errCode := s3a.putToFiler(r, uploadUrl, fileBody, "", bucket)
+	etag, errCode := s3a.putToFiler(r, uploadUrl, objectReader, "", bucket, nil)
 
 	if errCode != s3err.ErrNone {
+		s3a.rollbackVersionedWrite(bucket, "/"+strings.TrimPrefix(object, "/"))
 		s3err.WriteErrorResponse(w, r, errCode)
 		return
 	}
+	if versionId != "" {
+		w.Header().Set(s3_constants.AmzVersionId, versionId)
+	}
+	if sseInfo != nil {
+		setSseResponseHeaders(w.Header(), sseInfo)
+	}
 
 	if successRedirect != "" {
 		// Replace raw query params..
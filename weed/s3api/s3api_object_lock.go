@@ -0,0 +1,221 @@
+package s3api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// getObjectLockConfiguration returns the object lock configuration of the bucket, nil if object lock is not enabled
+func (s3a *S3ApiServer) getObjectLockConfiguration(bucket string) *s3.ObjectLockConfiguration {
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return nil
+	}
+	return bucketMetadata.ObjectLock
+}
+
+func validateObjectLockConfiguration(config *s3.ObjectLockConfiguration) s3err.ErrorCode {
+	if aws.StringValue(config.ObjectLockEnabled) != s3_constants.ObjectLockEnabled {
+		return s3err.ErrMalformedXML
+	}
+	if config.Rule == nil {
+		return s3err.ErrNone
+	}
+	retention := config.Rule.DefaultRetention
+	if retention == nil || !s3_constants.ValidateObjectLockMode(aws.StringValue(retention.Mode)) {
+		return s3err.ErrMalformedXML
+	}
+	days, years := aws.Int64Value(retention.Days), aws.Int64Value(retention.Years)
+	if (retention.Days == nil) == (retention.Years == nil) {
+		return s3err.ErrMalformedXML
+	}
+	if days < 0 || years < 0 || days+years == 0 {
+		return s3err.ErrInvalidRetentionPeriod
+	}
+	return s3err.ErrNone
+}
+
+// defaultRetention computes the retention an object gets from the bucket default rule
+func defaultRetention(config *s3.ObjectLockConfiguration, now time.Time) (mode string, retainUntil time.Time, found bool) {
+	if config == nil || config.Rule == nil || config.Rule.DefaultRetention == nil {
+		return "", time.Time{}, false
+	}
+	retention := config.Rule.DefaultRetention
+	retainUntil = now.AddDate(int(aws.Int64Value(retention.Years)), 0, int(aws.Int64Value(retention.Days)))
+	return aws.StringValue(retention.Mode), retainUntil, true
+}
+
+// setObjectLockHeaders validates the object lock headers of a write request, and applies the
+// bucket default retention if the request has none. The filer stores the headers on the new entry.
+func (s3a *S3ApiServer) setObjectLockHeaders(r *http.Request, bucket string) s3err.ErrorCode {
+	mode := r.Header.Get(s3_constants.AmzObjectLockMode)
+	retainUntilDate := r.Header.Get(s3_constants.AmzObjectLockRetainUntilDate)
+	legalHold := r.Header.Get(s3_constants.AmzObjectLockLegalHold)
+
+	config := s3a.getObjectLockConfiguration(bucket)
+	if config == nil {
+		if mode != "" || retainUntilDate != "" || legalHold != "" {
+			return s3err.ErrInvalidRequest
+		}
+		return s3err.ErrNone
+	}
+
+	if legalHold != "" && !s3_constants.ValidateObjectLockLegalHold(legalHold) {
+		return s3err.ErrInvalidRequest
+	}
+
+	now := time.Now()
+	if mode != "" || retainUntilDate != "" {
+		if !s3_constants.ValidateObjectLockMode(mode) || retainUntilDate == "" {
+			return s3err.ErrInvalidRequest
+		}
+		retainUntil, err := time.Parse(time.RFC3339, retainUntilDate)
+		if err != nil {
+			return s3err.ErrInvalidRequest
+		}
+		if !retainUntil.After(now) {
+			return s3err.ErrInvalidRetentionPeriod
+		}
+		r.Header.Set(s3_constants.AmzObjectLockRetainUntilDate, retainUntil.UTC().Format(time.RFC3339))
+		return s3err.ErrNone
+	}
+
+	if mode, retainUntil, found := defaultRetention(config, now); found {
+		r.Header.Set(s3_constants.AmzObjectLockMode, mode)
+		r.Header.Set(s3_constants.AmzObjectLockRetainUntilDate, retainUntil.UTC().Format(time.RFC3339))
+	}
+	return s3err.ErrNone
+}
+
+// isGovernanceBypassed checks that the request asks to bypass the governance retention, and is allowed to
+func (s3a *S3ApiServer) isGovernanceBypassed(r *http.Request, bucket, object string) bool {
+	if !strings.EqualFold(r.Header.Get(s3_constants.AmzBypassGovernanceRetention), "true") {
+		return false
+	}
+	return s3a.iam.isAuthorizedFor(r, s3_constants.ACTION_ADMIN, "s3:BypassGovernanceRetention", bucket, object)
+}
+
+// updateObjectLock saves the entry whose legal hold or governance retention may be relaxed,
+// which the filer only accepts from here, after the request is authorized.
+// The request is signed with the filer write key, when configured.
+func (s3a *S3ApiServer) updateObjectLock(dir string, entry *filer_pb.Entry) error {
+	ctx := context.Background()
+	if encodedJwt := security.GenJwtForFilerServer(s3a.filerGuard.SigningKey, s3a.filerGuard.ExpiresAfterSec); encodedJwt != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+string(encodedJwt))
+	}
+	return s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		if _, err := client.UpdateEntry(ctx, &filer_pb.UpdateEntryRequest{
+			Directory:       dir,
+			Entry:           entry,
+			RelaxObjectLock: true,
+		}); err != nil {
+			return fmt.Errorf("UpdateEntry: %v", err)
+		}
+		return nil
+	})
+}
+
+// checkObjectLockForDelete refuses to permanently delete a locked object version.
+// A governance retention bypassed by the request is cleared so that the filer accepts the deletion.
+func (s3a *S3ApiServer) checkObjectLockForDelete(r *http.Request, bucket, object, versionId string) s3err.ErrorCode {
+	if versionId == "" || s3a.getObjectLockConfiguration(bucket) == nil {
+		// without a version id, versioned buckets only add a delete marker
+		return s3err.ErrNone
+	}
+	objectPath, entry, err := s3a.getObjectVersion(bucket, object, versionId)
+	if err == filer_pb.ErrNotFound {
+		return s3err.ErrNone
+	}
+	if err != nil {
+		glog.Errorf("checkObjectLockForDelete %s%s: %v", bucket, object, err)
+		return s3err.ErrInternalError
+	}
+
+	if filer.IsObjectLegalHold(entry.Extended) {
+		return s3err.ErrObjectLocked
+	}
+	mode, retainUntil := filer.ObjectLockRetention(entry.Extended)
+	if mode == "" || !time.Now().Before(retainUntil) {
+		return s3err.ErrNone
+	}
+	if mode == s3_constants.ObjectLockModeCompliance || !s3a.isGovernanceBypassed(r, bucket, object) {
+		return s3err.ErrObjectLocked
+	}
+
+	delete(entry.Extended, s3_constants.AmzObjectLockMode)
+	delete(entry.Extended, s3_constants.AmzObjectLockRetainUntilDate)
+	dir, _ := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, objectPath)).DirAndName()
+	if err := s3a.updateObjectLock(dir, entry); err != nil {
+		glog.Errorf("clear governance retention %s%s: %v", bucket, objectPath, err)
+		return s3err.ErrInternalError
+	}
+	return s3err.ErrNone
+}
+
+// getObjectTarget resolves the entry whose retention, legal hold or ACL is read or changed
+func (s3a *S3ApiServer) getObjectTarget(r *http.Request, bucket, object string) (dir string, entry *filer_pb.Entry, errCode s3err.ErrorCode) {
+	versionId := r.URL.Query().Get("versionId")
+	objectPath := object
+	var err error
+	if versionId != "" {
+		objectPath, entry, err = s3a.getObjectVersion(bucket, object, versionId)
+	} else {
+		fullPath := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+		dir, name := fullPath.DirAndName()
+		entry, err = s3a.getEntry(dir, name)
+	}
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			if versionId != "" {
+				return "", nil, s3err.ErrNoSuchVersion
+			}
+			return "", nil, s3err.ErrNoSuchKey
+		}
+		glog.Errorf("getObjectTarget %s%s: %v", bucket, object, err)
+		return "", nil, s3err.ErrInternalError
+	}
+	if entry.IsDirectory || isDeleteMarker(entry) {
+		return "", nil, s3err.ErrNoSuchKey
+	}
+	if entry.Extended == nil {
+		entry.Extended = make(map[string][]byte)
+	}
+	dir, _ = util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, objectPath)).DirAndName()
+	return dir, entry, s3err.ErrNone
+}
+
+// checkRetentionChange enforces that compliance retention can only be extended,
+// and that governance retention can only be shortened or removed with an authorized bypass.
+func checkRetentionChange(extended map[string][]byte, newMode string, newRetainUntil time.Time, governanceBypassed bool) s3err.ErrorCode {
+	oldMode, oldRetainUntil := filer.ObjectLockRetention(extended)
+	if oldMode == "" || !time.Now().Before(oldRetainUntil) {
+		return s3err.ErrNone
+	}
+	weakened := newMode == "" || newRetainUntil.Before(oldRetainUntil)
+	switch oldMode {
+	case s3_constants.ObjectLockModeCompliance:
+		if weakened || newMode != s3_constants.ObjectLockModeCompliance {
+			return s3err.ErrObjectLocked
+		}
+	case s3_constants.ObjectLockModeGovernance:
+		if weakened && !governanceBypassed {
+			return s3err.ErrObjectLocked
+		}
+	}
+	return s3err.ErrNone
+}
@@ -0,0 +1,294 @@
+package s3api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// GetObjectLockConfigurationHandler Get bucket object lock configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+func (s3a *S3ApiServer) GetObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetObjectLockConfigurationHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	bucketEntry, err := s3a.getEntry(s3a.option.BucketsPath, bucket)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchBucket)
+			return
+		}
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	configBytes, found := bucketEntry.Extended[s3_constants.ExtObjectLockConfigKey]
+	if !found || len(configBytes) == 0 {
+		s3err.WriteErrorResponse(w, r, s3err.ErrObjectLockConfigurationNotFound)
+		return
+	}
+	var config s3.ObjectLockConfiguration
+	if err = json.Unmarshal(configBytes, &config); err != nil {
+		glog.Errorf("unmarshal bucket %s object lock configuration: %v", bucket, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutObjectLockConfigurationInput{
+		ObjectLockConfiguration: &config,
+	})
+}
+
+// PutObjectLockConfigurationHandler Put bucket object lock configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
+func (s3a *S3ApiServer) PutObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutObjectLockConfigurationHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var config s3.ObjectLockConfiguration
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&config, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if errCode := validateObjectLockConfiguration(&config); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	configBytes, err := json.Marshal(&config)
+	if err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	errCode := s3a.updateBucketEntry(bucket, func(extended map[string][]byte) (bool, s3err.ErrorCode) {
+		// object lock protects object versions, so versioning must stay enabled
+		if string(extended[s3_constants.ExtVersioningKey]) != s3.BucketVersioningStatusEnabled {
+			return false, s3err.ErrInvalidBucketState
+		}
+		extended[s3_constants.ExtObjectLockConfigKey] = configBytes
+		return true, s3err.ErrNone
+	})
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
+
+// GetObjectRetentionHandler Get object retention
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
+func (s3a *S3ApiServer) GetObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetObjectRetentionHandler %s %s", bucket, object)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+	if s3a.getObjectLockConfiguration(bucket) == nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	_, entry, errCode := s3a.getObjectTarget(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	mode, retainUntil := filer.ObjectLockRetention(entry.Extended)
+	if mode == "" {
+		s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchObjectLockConfiguration)
+		return
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutObjectRetentionInput{
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(mode),
+			RetainUntilDate: aws.Time(retainUntil),
+		},
+	})
+}
+
+// PutObjectRetentionHandler Put object retention
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
+func (s3a *S3ApiServer) PutObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutObjectRetentionHandler %s %s", bucket, object)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+	if s3a.getObjectLockConfiguration(bucket) == nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var retention s3.ObjectLockRetention
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&retention, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	mode := aws.StringValue(retention.Mode)
+	if (mode == "") != (retention.RetainUntilDate == nil) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if mode != "" {
+		if !s3_constants.ValidateObjectLockMode(mode) {
+			s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+			return
+		}
+		if !retention.RetainUntilDate.After(time.Now()) {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRetentionPeriod)
+			return
+		}
+	}
+
+	dir, entry, errCode := s3a.getObjectTarget(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	retainUntil := aws.TimeValue(retention.RetainUntilDate)
+	if errCode = checkRetentionChange(entry.Extended, mode, retainUntil, s3a.isGovernanceBypassed(r, bucket, object)); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	if mode == "" {
+		delete(entry.Extended, s3_constants.AmzObjectLockMode)
+		delete(entry.Extended, s3_constants.AmzObjectLockRetainUntilDate)
+	} else {
+		entry.Extended[s3_constants.AmzObjectLockMode] = []byte(mode)
+		entry.Extended[s3_constants.AmzObjectLockRetainUntilDate] = []byte(retainUntil.UTC().Format(time.RFC3339))
+	}
+	if err := s3a.updateObjectLock(dir, entry); err != nil {
+		glog.Errorf("PutObjectRetentionHandler %s %s: %v", bucket, object, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
+
+// GetObjectLegalHoldHandler Get object legal hold
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
+func (s3a *S3ApiServer) GetObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("GetObjectLegalHoldHandler %s %s", bucket, object)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+	if s3a.getObjectLockConfiguration(bucket) == nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	_, entry, errCode := s3a.getObjectTarget(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	status := string(entry.Extended[s3_constants.AmzObjectLockLegalHold])
+	if status == "" {
+		s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchObjectLockConfiguration)
+		return
+	}
+
+	s3err.WriteAwsXMLResponse(w, r, http.StatusOK, &s3.PutObjectLegalHoldInput{
+		LegalHold: &s3.ObjectLockLegalHold{
+			Status: aws.String(status),
+		},
+	})
+}
+
+// PutObjectLegalHoldHandler Put object legal hold
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
+func (s3a *S3ApiServer) PutObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("PutObjectLegalHoldHandler %s %s", bucket, object)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+	if s3a.getObjectLockConfiguration(bucket) == nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+
+	var legalHold s3.ObjectLockLegalHold
+	defer util.CloseRequest(r)
+
+	if err := xmlutil.UnmarshalXML(&legalHold, xml.NewDecoder(r.Body), ""); err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	status := aws.StringValue(legalHold.Status)
+	if !s3_constants.ValidateObjectLockLegalHold(status) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+
+	dir, entry, errCode := s3a.getObjectTarget(r, bucket, object)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	entry.Extended[s3_constants.AmzObjectLockLegalHold] = []byte(status)
+	if err := s3a.updateObjectLock(dir, entry); err != nil {
+		glog.Errorf("PutObjectLegalHoldHandler %s %s: %v", bucket, object, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	writeSuccessResponseEmpty(w, r)
+}
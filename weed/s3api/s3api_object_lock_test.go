@@ -0,0 +1,117 @@
+package s3api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateObjectLockConfiguration(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *s3.ObjectLockConfiguration
+		expected s3err.ErrorCode
+	}{
+		{"enabled only", &s3.ObjectLockConfiguration{ObjectLockEnabled: aws.String("Enabled")}, s3err.ErrNone},
+		{"not enabled", &s3.ObjectLockConfiguration{}, s3err.ErrMalformedXML},
+		{"days", objectLockConfiguration("GOVERNANCE", aws.Int64(30), nil), s3err.ErrNone},
+		{"years", objectLockConfiguration("COMPLIANCE", nil, aws.Int64(1)), s3err.ErrNone},
+		{"days and years", objectLockConfiguration("COMPLIANCE", aws.Int64(1), aws.Int64(1)), s3err.ErrMalformedXML},
+		{"no period", objectLockConfiguration("COMPLIANCE", nil, nil), s3err.ErrMalformedXML},
+		{"zero period", objectLockConfiguration("COMPLIANCE", aws.Int64(0), nil), s3err.ErrInvalidRetentionPeriod},
+		{"bad mode", objectLockConfiguration("STRICT", aws.Int64(1), nil), s3err.ErrMalformedXML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, validateObjectLockConfiguration(tt.config))
+		})
+	}
+}
+
+func TestDefaultRetention(t *testing.T) {
+	now := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	_, _, found := defaultRetention(&s3.ObjectLockConfiguration{ObjectLockEnabled: aws.String("Enabled")}, now)
+	assert.False(t, found)
+
+	mode, retainUntil, found := defaultRetention(objectLockConfiguration("GOVERNANCE", aws.Int64(10), nil), now)
+	assert.True(t, found)
+	assert.Equal(t, "GOVERNANCE", mode)
+	assert.Equal(t, now.AddDate(0, 0, 10), retainUntil)
+
+	_, retainUntil, _ = defaultRetention(objectLockConfiguration("COMPLIANCE", nil, aws.Int64(2)), now)
+	assert.Equal(t, now.AddDate(2, 0, 0), retainUntil)
+}
+
+func TestCheckRetentionChange(t *testing.T) {
+	retainUntil := time.Now().Add(time.Hour)
+	extended := map[string][]byte{
+		s3_constants.AmzObjectLockMode:            []byte(s3_constants.ObjectLockModeGovernance),
+		s3_constants.AmzObjectLockRetainUntilDate: []byte(retainUntil.UTC().Format(time.RFC3339)),
+	}
+	assert.Equal(t, s3err.ErrNone, checkRetentionChange(extended, s3_constants.ObjectLockModeCompliance, retainUntil.Add(time.Hour), false))
+	assert.Equal(t, s3err.ErrObjectLocked, checkRetentionChange(extended, s3_constants.ObjectLockModeGovernance, retainUntil.Add(-time.Hour), false))
+	assert.Equal(t, s3err.ErrObjectLocked, checkRetentionChange(extended, "", time.Time{}, false))
+
+	assert.Equal(t, s3err.ErrNone, checkRetentionChange(extended, "", time.Time{}, true))
+
+	extended[s3_constants.AmzObjectLockMode] = []byte(s3_constants.ObjectLockModeCompliance)
+	assert.Equal(t, s3err.ErrObjectLocked, checkRetentionChange(extended, s3_constants.ObjectLockModeGovernance, retainUntil.Add(time.Hour), true))
+	assert.Equal(t, s3err.ErrObjectLocked, checkRetentionChange(extended, "", time.Time{}, true))
+}
+
+type testBucketPolicies map[string]*BucketPolicy
+
+func (policies testBucketPolicies) GetBucketPolicy(bucket string) *BucketPolicy {
+	return policies[bucket]
+}
+
+func TestIsGovernanceBypassed(t *testing.T) {
+	policy, err := parseBucketPolicy([]byte(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": {"AWS": "alice"}, "Action": "s3:BypassGovernanceRetention", "Resource": "arn:aws:s3:::bucket/*"},
+    {"Effect": "Deny", "Principal": "*", "Action": "s3:BypassGovernanceRetention", "Resource": "arn:aws:s3:::bucket/audit/*"}
+  ]
+}`))
+	assert.NoError(t, err)
+	s3a := &S3ApiServer{iam: &IdentityAccessManagement{isAuthEnabled: true, bucketPolicies: testBucketPolicies{"bucket": policy}}}
+	request := func(identity *Identity, bypass bool) *http.Request {
+		r := httptest.NewRequest(http.MethodDelete, "/bucket/object?versionId=1", nil)
+		if bypass {
+			r.Header.Set(s3_constants.AmzBypassGovernanceRetention, "true")
+		}
+		return r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity))
+	}
+	alice := &Identity{Name: "alice", Account: &Account{Id: "alice"}, Actions: []Action{s3_constants.ACTION_WRITE}}
+	bob := &Identity{Name: "bob", Account: &Account{Id: "bob"}, Actions: []Action{s3_constants.ACTION_WRITE}}
+	admin := &Identity{Name: "admin", Account: &Account{Id: "admin"}, Actions: []Action{s3_constants.ACTION_ADMIN}}
+
+	assert.False(t, s3a.isGovernanceBypassed(request(alice, false), "bucket", "/object"), "the header is required")
+	assert.True(t, s3a.isGovernanceBypassed(request(alice, true), "bucket", "/object"))
+	assert.False(t, s3a.isGovernanceBypassed(request(bob, true), "bucket", "/object"), "writing is not enough")
+	assert.True(t, s3a.isGovernanceBypassed(request(admin, true), "bucket", "/object"))
+	assert.False(t, s3a.isGovernanceBypassed(request(admin, true), "bucket", "/audit/object"), "an explicit deny applies to admins")
+	assert.False(t, s3a.isGovernanceBypassed(request(nil, true), "bucket", "/object"), "the requester is not known")
+}
+
+func objectLockConfiguration(mode string, days, years *int64) *s3.ObjectLockConfiguration {
+	return &s3.ObjectLockConfiguration{
+		ObjectLockEnabled: aws.String("Enabled"),
+		Rule: &s3.ObjectLockRule{
+			DefaultRetention: &s3.DefaultRetention{
+				Mode:  aws.String(mode),
+				Days:  days,
+				Years: years,
+			},
+		},
+	}
+}
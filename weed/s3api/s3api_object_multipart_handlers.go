@@ -31,6 +31,23 @@ const (
 func (s3a *S3ApiServer) NewMultipartUploadHandler(w http.ResponseWriter, r *http.Request) {
 	bucket, object := s3_constants.GetBucketAndObject(r)
 
+	if isVersionsFolderKey(object) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+	if errCode := checkWebsiteRedirectLocation(r.Header); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.setObjectLockHeaders(r, bucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if errCode := s3a.setObjectAclHeaders(r, bucket); errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
 	createMultipartUploadInput := &s3.CreateMultipartUploadInput{
 		Bucket:   aws.String(bucket),
 		Key:      objectKey(aws.String(object)),
@@ -42,6 +59,28 @@ func (s3a *S3ApiServer) NewMultipartUploadHandler(w http.ResponseWriter, r *http
 		createMultipartUploadInput.Metadata[k] = aws.String(string(v))
 	}
 
+	// the parts are encrypted with the data key kept on the upload folder
+	sseInfo, _, errCode := s3a.newSseInfo(r)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	if sseInfo != nil {
+		for k, v := range sseInfo.attributes() {
+			createMultipartUploadInput.Metadata[k] = aws.String(v)
+		}
+	}
+
+	// the checksum of this algorithm is computed for each part
+	var checksumAlgorithm *checksumAlgorithm
+	if name := r.Header.Get(s3_constants.AmzChecksumAlgorithm); name != "" {
+		if checksumAlgorithm = findChecksumAlgorithm(name); checksumAlgorithm == nil {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidChecksum)
+			return
+		}
+		createMultipartUploadInput.Metadata[s3_constants.ExtChecksumAlgorithmKey] = aws.String(checksumAlgorithm.name)
+	}
+
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "" {
 		createMultipartUploadInput.ContentType = &contentType
@@ -55,6 +94,12 @@ func (s3a *S3ApiServer) NewMultipartUploadHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	if sseInfo != nil {
+		setSseResponseHeaders(w.Header(), sseInfo)
+	}
+	if checksumAlgorithm != nil {
+		w.Header().Set(s3_constants.AmzChecksumAlgorithm, checksumAlgorithm.name)
+	}
 	writeSuccessResponseXML(w, r, response)
 
 }
@@ -92,6 +137,12 @@ func (s3a *S3ApiServer) CompleteMultipartUploadHandler(w http.ResponseWriter, r
 		return
 	}
 
+	if response.VersionId != nil {
+		// the version id is returned as a header, not in the xml body
+		w.Header().Set(s3_constants.AmzVersionId, *response.VersionId)
+		response.VersionId = nil
+	}
+
 	writeSuccessResponseXML(w, r, response)
 
 }
@@ -255,13 +306,36 @@ func (s3a *S3ApiServer) PutObjectPartHandler(w http.ResponseWriter, r *http.Requ
 	}
 	destination := fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object)
 
-	etag, errCode := s3a.putToFiler(r, uploadUrl, dataReader, destination, bucket)
+	uploadEntry, err := s3a.getEntry(s3a.genUploadsFolder(bucket), uploadID)
+	if err != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchUpload)
+		return
+	}
+	// conditional writes do not apply to parts
+	r.Header.Del("If-Match")
+	r.Header.Del("If-None-Match")
+	checksum, errCode := newPartChecksumReader(r, uploadEntry, dataReader)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	partReader, sseInfo, errCode := s3a.encryptPart(r, uploadEntry, checksum)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	etag, errCode := s3a.putToFiler(r, uploadUrl, partReader, destination, bucket, checksum)
 	if errCode != s3err.ErrNone {
 		s3err.WriteErrorResponse(w, r, errCode)
 		return
 	}
 
 	setEtag(w, etag)
+	checksum.setChecksumResponseHeader(w.Header())
+	if sseInfo != nil {
+		setSseResponseHeaders(w.Header(), sseInfo)
+	}
 
 	writeSuccessResponseEmpty(w, r)
 
@@ -344,6 +418,10 @@ type CompleteMultipartUpload struct {
 	Parts []CompletedPart `xml:"Part"`
 }
 type CompletedPart struct {
-	ETag       string
-	PartNumber int
+	ETag           string
+	PartNumber     int
+	ChecksumCRC32  string
+	ChecksumCRC32C string
+	ChecksumSHA1   string
+	ChecksumSHA256 string
 }
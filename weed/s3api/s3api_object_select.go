@@ -0,0 +1,575 @@
+package s3api
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/query/sqlselect"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// selectRecordsBatchSize is the size of the selected records to collect into one Records event
+const selectRecordsBatchSize = 64 * 1024
+
+// errSelectDone stops reading records once the LIMIT is reached
+var errSelectDone = errors.New("select done")
+
+// SelectObjectContentHandler filters the content of a CSV or JSON object with an SQL expression
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+func (s3a *S3ApiServer) SelectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+
+	bucket, object := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("SelectObjectContentHandler %s %s", bucket, object)
+
+	customerKey, errCode := parseSseCustomerKey(r.Header, false)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+	removeSseRequestHeaders(r.Header)
+
+	var input s3.SelectObjectContentInput
+	defer util.CloseRequest(r)
+	if err := xmlutil.UnmarshalXML(&input, xml.NewDecoder(r.Body), ""); err != nil {
+		glog.Warningf("SelectObjectContentHandler xml decode: %s", err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrMalformedXML)
+		return
+	}
+	if !strings.EqualFold(aws.StringValue(input.ExpressionType), s3.ExpressionTypeSql) {
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidExpressionType)
+		return
+	}
+	expression := aws.StringValue(input.Expression)
+	stmt, err := sqlselect.Parse(expression)
+	if err != nil {
+		glog.V(1).Infof("select %s/%s %q: %v", bucket, object, expression, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInvalidSelectExpression)
+		return
+	}
+	if input.ScanRange != nil {
+		s3err.WriteErrorResponse(w, r, s3err.ErrNotImplemented)
+		return
+	}
+	format, errCode := newSelectFormat(input.InputSerialization, input.OutputSerialization)
+	if errCode != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, errCode)
+		return
+	}
+
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+	entry, err := s3a.getEntry(dir, name)
+	if err != nil || entry.IsDirectory {
+		if err == nil || err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchKey)
+		} else {
+			glog.Errorf("select %s/%s: %v", bucket, object, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		}
+		return
+	}
+
+	// the volume servers evaluate the WHERE clause when they can read the records from the chunks as stored,
+	// otherwise the object is read through the filer
+	chunks, pushDown := pushDownChunks(entry, format, stmt)
+	var source io.Reader
+	if !pushDown {
+		_, _, resp, err := util.DownloadFile(s3a.toFilerUrl(bucket, object), s3a.maybeGetFilerJwtAuthorizationToken(false))
+		if err != nil {
+			glog.Errorf("select %s/%s: %v", bucket, object, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+			return
+		}
+		defer util.CloseResponse(resp)
+		if resp.StatusCode != http.StatusOK {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchKey)
+			return
+		}
+		if source, _, errCode = s3a.decryptObject(resp.Header, resp.Body, customerKey); errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	events := newSelectEventWriter(w)
+	sel := stmt.NewSelection(format.newWriter(&events.records))
+
+	stats := &s3.Stats{}
+	if pushDown {
+		size := int64(filer.FileSize(entry))
+		stats.SetBytesScanned(size).SetBytesProcessed(size)
+		err = s3a.selectFromVolumes(r.Context(), chunks, expression, format, sel, events)
+	} else {
+		err = selectFromReader(source, format, sel, events, stats)
+	}
+	if err == nil {
+		err = sel.Close()
+	}
+	if err == nil {
+		err = events.flush()
+	}
+	if err != nil {
+		glog.V(1).Infof("select %s/%s %q: %v", bucket, object, expression, err)
+		events.sendError("InternalError", err.Error())
+	} else {
+		stats.SetBytesReturned(events.returned)
+		events.sendStats(stats)
+		events.sendEnd()
+	}
+	s3err.PostLog(r, http.StatusOK, s3err.ErrNone)
+}
+
+// selectFormat holds the input and output serialization of a select request
+type selectFormat struct {
+	// csv is nil for JSON input
+	csv         *sqlselect.CSVInput
+	jsonLines   bool
+	compression string
+	newWriter   func(w io.Writer) sqlselect.RecordWriter
+}
+
+func newSelectFormat(input *s3.InputSerialization, output *s3.OutputSerialization) (*selectFormat, s3err.ErrorCode) {
+	if input == nil || output == nil {
+		return nil, s3err.ErrInvalidSelectSerialization
+	}
+	format := &selectFormat{
+		compression: strings.ToUpper(aws.StringValue(input.CompressionType)),
+	}
+	switch format.compression {
+	case "", s3.CompressionTypeNone, s3.CompressionTypeGzip, s3.CompressionTypeBzip2:
+	default:
+		return nil, s3err.ErrInvalidSelectSerialization
+	}
+
+	switch {
+	case input.CSV != nil:
+		format.csv = &sqlselect.CSVInput{
+			FileHeaderInfo:             aws.StringValue(input.CSV.FileHeaderInfo),
+			RecordDelimiter:            aws.StringValue(input.CSV.RecordDelimiter),
+			FieldDelimiter:             aws.StringValue(input.CSV.FieldDelimiter),
+			QuoteCharacter:             aws.StringValue(input.CSV.QuoteCharacter),
+			QuoteEscapeCharacter:       aws.StringValue(input.CSV.QuoteEscapeCharacter),
+			Comments:                   aws.StringValue(input.CSV.Comments),
+			AllowQuotedRecordDelimiter: aws.BoolValue(input.CSV.AllowQuotedRecordDelimiter),
+		}
+		switch strings.ToUpper(format.csv.FileHeaderInfo) {
+		case "", s3.FileHeaderInfoNone, s3.FileHeaderInfoUse, s3.FileHeaderInfoIgnore:
+		default:
+			return nil, s3err.ErrInvalidSelectSerialization
+		}
+	case input.JSON != nil:
+		switch strings.ToUpper(aws.StringValue(input.JSON.Type)) {
+		case s3.JSONTypeLines:
+			format.jsonLines = true
+		case "", s3.JSONTypeDocument:
+		default:
+			return nil, s3err.ErrInvalidSelectSerialization
+		}
+	case input.Parquet != nil:
+		return nil, s3err.ErrNotImplemented
+	default:
+		return nil, s3err.ErrInvalidSelectSerialization
+	}
+
+	switch {
+	case output.CSV != nil:
+		csvOutput := sqlselect.CSVOutput{
+			QuoteFields:          aws.StringValue(output.CSV.QuoteFields),
+			RecordDelimiter:      aws.StringValue(output.CSV.RecordDelimiter),
+			FieldDelimiter:       aws.StringValue(output.CSV.FieldDelimiter),
+			QuoteCharacter:       aws.StringValue(output.CSV.QuoteCharacter),
+			QuoteEscapeCharacter: aws.StringValue(output.CSV.QuoteEscapeCharacter),
+		}
+		format.newWriter = func(w io.Writer) sqlselect.RecordWriter {
+			return sqlselect.NewCSVWriter(w, csvOutput)
+		}
+	case output.JSON != nil:
+		recordDelimiter := aws.StringValue(output.JSON.RecordDelimiter)
+		format.newWriter = func(w io.Writer) sqlselect.RecordWriter {
+			return sqlselect.NewJSONWriter(w, recordDelimiter)
+		}
+	default:
+		return nil, s3err.ErrInvalidSelectSerialization
+	}
+	return format, s3err.ErrNone
+}
+
+// newParser returns the record parser, and the same parser as a CSVParser for CSV input
+func (format *selectFormat) newParser() (sqlselect.RecordParser, *sqlselect.CSVParser) {
+	if format.csv == nil {
+		return sqlselect.JSONParser{}, nil
+	}
+	csvParser := sqlselect.NewCSVParser(*format.csv)
+	return csvParser, csvParser
+}
+
+func (format *selectFormat) delimiter() []byte {
+	if format.csv == nil {
+		return []byte("\n")
+	}
+	return format.csv.Delimiter()
+}
+
+func (format *selectFormat) hasHeader() bool {
+	return format.csv != nil && format.csv.HasHeader()
+}
+
+// pushDownChunks returns the chunks to query on the volume servers, in the order of the file offsets.
+// The volume servers can only split plain records, so the chunks must not be encrypted or chunk manifests,
+// and together they must cover the file exactly once.
+func pushDownChunks(entry *filer_pb.Entry, format *selectFormat, stmt *sqlselect.Statement) ([]*filer_pb.FileChunk, bool) {
+	if !stmt.HasFilter() || len(entry.Content) > 0 || len(entry.GetChunks()) == 0 {
+		return nil, false
+	}
+	if format.compression != "" && format.compression != s3.CompressionTypeNone {
+		return nil, false
+	}
+	if format.csv != nil && format.csv.AllowQuotedRecordDelimiter || format.csv == nil && !format.jsonLines {
+		return nil, false
+	}
+	if _, found := entry.Extended[s3_constants.ExtSseTypeKey]; found {
+		return nil, false
+	}
+	chunks := make([]*filer_pb.FileChunk, len(entry.GetChunks()))
+	copy(chunks, entry.GetChunks())
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Offset < chunks[j].Offset
+	})
+	var offset int64
+	for _, chunk := range chunks {
+		if chunk.IsChunkManifest || len(chunk.CipherKey) > 0 || chunk.Offset != offset {
+			return nil, false
+		}
+		offset += int64(chunk.Size)
+	}
+	return chunks, uint64(offset) == filer.FileSize(entry)
+}
+
+// selectFromVolumes sends the WHERE clause with each chunk to a volume server holding it.
+// The records cut by the chunk boundaries come back as head and tail, and are evaluated here.
+func (s3a *S3ApiServer) selectFromVolumes(ctx context.Context, chunks []*filer_pb.FileChunk, expression string, format *selectFormat, sel *sqlselect.Selection, events *selectEventWriter) error {
+	locations, err := s3a.lookupChunkVolumes(chunks)
+	if err != nil {
+		return err
+	}
+	parser, csvParser := format.newParser()
+	delimiter := format.delimiter()
+	inputSerialization := &volume_server_pb.QueryRequest_InputSerialization{}
+	if format.csv != nil {
+		inputSerialization.CsvInput = &volume_server_pb.QueryRequest_InputSerialization_CSVInput{
+			FileHeaderInfo:       format.csv.FileHeaderInfo,
+			RecordDelimiter:      format.csv.RecordDelimiter,
+			FieldDelimiter:       format.csv.FieldDelimiter,
+			QuoteCharacter:       format.csv.QuoteCharacter,
+			QuoteEscapeCharacter: format.csv.QuoteEscapeCharacter,
+			Comments:             format.csv.Comments,
+		}
+	} else {
+		inputSerialization.JsonInput = &volume_server_pb.QueryRequest_InputSerialization_JSONInput{
+			Type: s3.JSONTypeLines,
+		}
+	}
+
+	var pending []byte
+	headerRead := !format.hasHeader()
+	for i, chunk := range chunks {
+		if sel.Done() {
+			return nil
+		}
+		if i > 0 && !headerRead {
+			return fmt.Errorf("csv header is longer than the first chunk")
+		}
+		fileId := chunk.GetFileIdString()
+		req := &volume_server_pb.QueryRequest{
+			FromFileIds:        []string{fileId},
+			InputSerialization: inputSerialization,
+			Expression:         expression,
+			SkipFirstRecord:    i > 0 || format.hasHeader(),
+		}
+		if csvParser != nil && i > 0 {
+			req.ColumnNames = csvParser.ColumnNames()
+		}
+		err = s3a.queryChunk(ctx, locations[filer.VolumeId(fileId)], req, func(stripe *volume_server_pb.QueriedStripe) error {
+			if len(stripe.Head) > 0 {
+				raw := append(pending, bytes.TrimSuffix(stripe.Head, delimiter)...)
+				pending = nil
+				if !headerRead {
+					csvParser.SetHeader(raw)
+					headerRead = true
+				} else if err := selectRecord(parser, raw, sel, events); err != nil {
+					return err
+				}
+			}
+			for records := stripe.Records; len(records) > 0; {
+				end := bytes.Index(records, delimiter)
+				if end < 0 {
+					end = len(records)
+				}
+				if err := selectRecord(parser, records[:end], sel, events); err != nil {
+					return err
+				}
+				records = records[min(end+len(delimiter), len(records)):]
+			}
+			pending = append(pending, stripe.Tail...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		return ignoreSelectDone(selectRecord(parser, pending, sel, events))
+	}
+	return nil
+}
+
+func (s3a *S3ApiServer) lookupChunkVolumes(chunks []*filer_pb.FileChunk) (locations map[string]*filer_pb.Locations, err error) {
+	var volumeIds []string
+	for _, chunk := range chunks {
+		volumeIds = append(volumeIds, filer.VolumeId(chunk.GetFileIdString()))
+	}
+	err = s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.LookupVolume(context.Background(), &filer_pb.LookupVolumeRequest{
+			VolumeIds: volumeIds,
+		})
+		if err != nil {
+			return err
+		}
+		for _, vid := range volumeIds {
+			if resp.LocationsMap[vid] == nil || len(resp.LocationsMap[vid].Locations) == 0 {
+				return fmt.Errorf("failed to locate volume %s", vid)
+			}
+		}
+		locations = resp.LocationsMap
+		return nil
+	})
+	return
+}
+
+// queryChunk runs the query on one of the volume servers holding the chunk.
+// It moves on to the next location only if no stripe has been received yet.
+func (s3a *S3ApiServer) queryChunk(ctx context.Context, locations *filer_pb.Locations, req *volume_server_pb.QueryRequest, fn func(stripe *volume_server_pb.QueriedStripe) error) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, location := range locations.Locations {
+		received := false
+		err = operation.WithVolumeServerClient(true, pb.NewServerAddressWithGrpcPort(location.Url, int(location.GrpcPort)), s3a.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			stream, err := client.Query(ctx, req)
+			if err != nil {
+				return err
+			}
+			for {
+				stripe, err := stream.Recv()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				received = true
+				if err = fn(stripe); err != nil {
+					return err
+				}
+			}
+		})
+		if err == nil || received {
+			return ignoreSelectDone(err)
+		}
+		glog.V(1).Infof("query %v on %s: %v", req.FromFileIds, location.Url, err)
+	}
+	return err
+}
+
+// selectFromReader evaluates the whole statement over the object content
+func selectFromReader(source io.Reader, format *selectFormat, sel *sqlselect.Selection, events *selectEventWriter, stats *s3.Stats) (err error) {
+	scanned := &countingReader{reader: source}
+	processed := &countingReader{reader: scanned}
+	defer func() {
+		stats.SetBytesScanned(scanned.count).SetBytesProcessed(processed.count)
+	}()
+	switch format.compression {
+	case s3.CompressionTypeGzip:
+		gzipReader, err := gzip.NewReader(scanned)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		processed.reader = gzipReader
+	case s3.CompressionTypeBzip2:
+		processed.reader = bzip2.NewReader(scanned)
+	}
+
+	parser, csvParser := format.newParser()
+	var records sqlselect.RecordReader
+	switch {
+	case format.csv != nil:
+		var quote byte
+		if format.csv.AllowQuotedRecordDelimiter {
+			quote = '"'
+			if format.csv.QuoteCharacter != "" {
+				quote = format.csv.QuoteCharacter[0]
+			}
+		}
+		records = sqlselect.NewDelimitedReader(processed, format.delimiter(), quote)
+	case format.jsonLines:
+		records = sqlselect.NewDelimitedReader(processed, format.delimiter(), 0)
+	default:
+		records = sqlselect.NewJSONDocumentReader(processed)
+	}
+
+	if format.hasHeader() {
+		raw, err := records.Next()
+		if err != nil {
+			return ignoreEOF(err)
+		}
+		csvParser.SetHeader(raw)
+	}
+	for !sel.Done() {
+		raw, err := records.Next()
+		if err != nil {
+			return ignoreEOF(err)
+		}
+		if err = selectRecord(parser, raw, sel, events); err != nil {
+			return ignoreSelectDone(err)
+		}
+	}
+	return nil
+}
+
+// selectRecord evaluates one raw record, and returns errSelectDone once the LIMIT is reached
+func selectRecord(parser sqlselect.RecordParser, raw []byte, sel *sqlselect.Selection, events *selectEventWriter) error {
+	if sel.Done() {
+		return errSelectDone
+	}
+	record, err := parser.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if err = sel.Write(record); err != nil {
+		return err
+	}
+	if events.records.Len() >= selectRecordsBatchSize {
+		return events.flush()
+	}
+	return nil
+}
+
+func ignoreSelectDone(err error) error {
+	if err == errSelectDone {
+		return nil
+	}
+	return err
+}
+
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// selectEventWriter frames the select response as AWS event stream messages
+type selectEventWriter struct {
+	w        http.ResponseWriter
+	encoder  *eventstream.Encoder
+	records  bytes.Buffer
+	returned int64
+}
+
+func newSelectEventWriter(w http.ResponseWriter) *selectEventWriter {
+	return &selectEventWriter{
+		w:       w,
+		encoder: eventstream.NewEncoder(w),
+	}
+}
+
+func (ew *selectEventWriter) send(headers eventstream.Headers, payload []byte) error {
+	if err := ew.encoder.Encode(eventstream.Message{Headers: headers, Payload: payload}); err != nil {
+		return err
+	}
+	if flusher, ok := ew.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func eventHeaders(eventType, contentType string) eventstream.Headers {
+	var headers eventstream.Headers
+	headers.Set(":message-type", eventstream.StringValue("event"))
+	headers.Set(":event-type", eventstream.StringValue(eventType))
+	if contentType != "" {
+		headers.Set(":content-type", eventstream.StringValue(contentType))
+	}
+	return headers
+}
+
+// flush sends the collected records as a Records event
+func (ew *selectEventWriter) flush() error {
+	if ew.records.Len() == 0 {
+		return nil
+	}
+	ew.returned += int64(ew.records.Len())
+	err := ew.send(eventHeaders("Records", "application/octet-stream"), ew.records.Bytes())
+	ew.records.Reset()
+	return err
+}
+
+func (ew *selectEventWriter) sendStats(stats *s3.Stats) {
+	var payload bytes.Buffer
+	fmt.Fprintf(&payload, "<Stats><BytesScanned>%d</BytesScanned><BytesProcessed>%d</BytesProcessed><BytesReturned>%d</BytesReturned></Stats>",
+		aws.Int64Value(stats.BytesScanned), aws.Int64Value(stats.BytesProcessed), aws.Int64Value(stats.BytesReturned))
+	if err := ew.send(eventHeaders("Stats", "text/xml"), payload.Bytes()); err != nil {
+		glog.V(1).Infof("send select stats: %v", err)
+	}
+}
+
+func (ew *selectEventWriter) sendEnd() {
+	if err := ew.send(eventHeaders("End", ""), nil); err != nil {
+		glog.V(1).Infof("send select end: %v", err)
+	}
+}
+
+// sendError ends the stream with an error message, since the response status is already sent
+func (ew *selectEventWriter) sendError(code, message string) {
+	var headers eventstream.Headers
+	headers.Set(":message-type", eventstream.StringValue("error"))
+	headers.Set(":error-code", eventstream.StringValue(code))
+	headers.Set(":error-message", eventstream.StringValue(message))
+	if err := ew.send(headers, nil); err != nil {
+		glog.V(1).Infof("send select error: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package s3api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/query/sqlselect"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectFromReader(t *testing.T) {
+	format, errCode := newSelectFormat(&s3.InputSerialization{
+		CSV: &s3.CSVInput{FileHeaderInfo: aws.String("USE")},
+	}, &s3.OutputSerialization{
+		JSON: &s3.JSONOutput{},
+	})
+	assert.Equal(t, s3err.ErrNone, errCode)
+	stmt, err := sqlselect.Parse("SELECT s.name FROM S3Object s WHERE CAST(s.size AS INT) > 10 LIMIT 2")
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	events := newSelectEventWriter(recorder)
+	sel := stmt.NewSelection(format.newWriter(&events.records))
+	stats := &s3.Stats{}
+	content := "name,size\na,5\nb,20\nc,30\nd,40\n"
+	assert.NoError(t, selectFromReader(strings.NewReader(content), format, sel, events, stats))
+	assert.NoError(t, events.flush())
+	events.sendStats(stats.SetBytesReturned(events.returned))
+	events.sendEnd()
+
+	decoder := eventstream.NewDecoder(recorder.Body)
+	message, err := decoder.Decode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Records", message.Headers.Get(":event-type").String())
+	assert.Equal(t, `{"name":"b"}`+"\n"+`{"name":"c"}`+"\n", string(message.Payload))
+
+	message, err = decoder.Decode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Stats", message.Headers.Get(":event-type").String())
+	assert.Contains(t, string(message.Payload), "<BytesReturned>26</BytesReturned>")
+
+	message, err = decoder.Decode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "End", message.Headers.Get(":event-type").String())
+}
+
+func TestNewSelectFormat(t *testing.T) {
+	jsonOutput := &s3.OutputSerialization{JSON: &s3.JSONOutput{}}
+	_, errCode := newSelectFormat(&s3.InputSerialization{Parquet: &s3.ParquetInput{}}, jsonOutput)
+	assert.Equal(t, s3err.ErrNotImplemented, errCode)
+	_, errCode = newSelectFormat(&s3.InputSerialization{JSON: &s3.JSONInput{Type: aws.String("XML")}}, jsonOutput)
+	assert.Equal(t, s3err.ErrInvalidSelectSerialization, errCode)
+	_, errCode = newSelectFormat(&s3.InputSerialization{JSON: &s3.JSONInput{}}, &s3.OutputSerialization{})
+	assert.Equal(t, s3err.ErrInvalidSelectSerialization, errCode)
+	format, errCode := newSelectFormat(&s3.InputSerialization{JSON: &s3.JSONInput{Type: aws.String("lines")}}, jsonOutput)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.True(t, format.jsonLines)
+}
+
+func TestPushDownChunks(t *testing.T) {
+	format, _ := newSelectFormat(&s3.InputSerialization{
+		JSON: &s3.JSONInput{Type: aws.String(s3.JSONTypeLines)},
+	}, &s3.OutputSerialization{JSON: &s3.JSONOutput{}})
+	filtered, _ := sqlselect.Parse("SELECT * FROM S3Object WHERE a = 1")
+	unfiltered, _ := sqlselect.Parse("SELECT * FROM S3Object")
+	newEntry := func(chunks ...*filer_pb.FileChunk) *filer_pb.Entry {
+		return &filer_pb.Entry{Chunks: chunks, Attributes: &filer_pb.FuseAttributes{}}
+	}
+
+	chunks, ok := pushDownChunks(newEntry(&filer_pb.FileChunk{Offset: 10, Size: 5}, &filer_pb.FileChunk{Offset: 0, Size: 10}), format, filtered)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), chunks[0].Offset)
+
+	_, ok = pushDownChunks(newEntry(&filer_pb.FileChunk{Offset: 0, Size: 10}), format, unfiltered)
+	assert.False(t, ok, "nothing to filter")
+	_, ok = pushDownChunks(newEntry(&filer_pb.FileChunk{Offset: 0, Size: 10}, &filer_pb.FileChunk{Offset: 5, Size: 10}), format, filtered)
+	assert.False(t, ok, "overlapping chunks")
+	_, ok = pushDownChunks(newEntry(&filer_pb.FileChunk{Offset: 0, Size: 10, CipherKey: []byte("key")}), format, filtered)
+	assert.False(t, ok, "encrypted chunk")
+
+	entry := newEntry(&filer_pb.FileChunk{Offset: 0, Size: 10})
+	entry.Extended = map[string][]byte{s3_constants.ExtSseTypeKey: []byte(s3_constants.SseTypeS3)}
+	_, ok = pushDownChunks(entry, format, filtered)
+	assert.False(t, ok, "encrypted object")
+}
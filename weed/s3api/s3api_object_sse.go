@@ -0,0 +1,458 @@
+package s3api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+// Objects are encrypted by the gateway with AES-256 in CTR mode, so that the ciphertext has the same size
+// as the plaintext and any range can be decrypted. The attributes describing the encryption are passed
+// to the filer as "Seaweed-" headers, and kept in the entry extended attributes.
+// A multipart object is made of segments, one for each part, encrypted with their own IV.
+
+// sseExtendedKeys are the entry attributes describing the encryption
+var sseExtendedKeys = []string{
+	s3_constants.ExtSseTypeKey,
+	s3_constants.ExtSseKmsKeyIdKey,
+	s3_constants.ExtSseDataKeyKey,
+	s3_constants.ExtSseCustomerKeyMd5Key,
+	s3_constants.ExtSseIvKey,
+	s3_constants.ExtSseSegmentsKey,
+}
+
+// sseInfo describes how an object is encrypted
+type sseInfo struct {
+	sseType          string
+	kmsKeyId         string
+	encryptedDataKey []byte
+	customerKeyMd5   string
+	segments         []sseSegment
+}
+
+// sseSegment is a range of the object, starting at offset, encrypted as one CTR stream
+type sseSegment struct {
+	offset int64
+	iv     []byte
+}
+
+// sseCustomerKey is the key given by the client of a SSE-C request
+type sseCustomerKey struct {
+	key []byte
+	md5 string
+}
+
+// parseSseCustomerKey reads the SSE-C headers, or the copy source ones. It returns nil if there are none.
+func parseSseCustomerKey(header http.Header, isCopySource bool) (*sseCustomerKey, s3err.ErrorCode) {
+	algorithmHeader, keyHeader, md5Header := s3_constants.AmzServerSideEncryptionCustomerAlgorithm,
+		s3_constants.AmzServerSideEncryptionCustomerKey, s3_constants.AmzServerSideEncryptionCustomerKeyMD5
+	if isCopySource {
+		algorithmHeader, keyHeader, md5Header = s3_constants.AmzCopySourceServerSideEncryptionCustomerAlgorithm,
+			s3_constants.AmzCopySourceServerSideEncryptionCustomerKey, s3_constants.AmzCopySourceServerSideEncryptionCustomerKeyMD5
+	}
+	algorithm, encodedKey, keyMd5 := header.Get(algorithmHeader), header.Get(keyHeader), header.Get(md5Header)
+	if algorithm == "" && encodedKey == "" && keyMd5 == "" {
+		return nil, s3err.ErrNone
+	}
+	if algorithm != s3_constants.SseAlgorithmAES256 {
+		return nil, s3err.ErrInvalidEncryptionAlgorithm
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, s3err.ErrInvalidSSECustomerKey
+	}
+	sum := md5.Sum(key)
+	if keyMd5 != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, s3err.ErrSSECustomerKeyMD5Mismatch
+	}
+	return &sseCustomerKey{key: key, md5: keyMd5}, s3err.ErrNone
+}
+
+// removeSseRequestHeaders drops the customer keys, so that they are not sent to the filer,
+// and the encryption attributes that only the gateway may set
+func removeSseRequestHeaders(header http.Header) {
+	for _, k := range []string{
+		s3_constants.AmzServerSideEncryptionCustomerAlgorithm,
+		s3_constants.AmzServerSideEncryptionCustomerKey,
+		s3_constants.AmzServerSideEncryptionCustomerKeyMD5,
+		s3_constants.AmzCopySourceServerSideEncryptionCustomerAlgorithm,
+		s3_constants.AmzCopySourceServerSideEncryptionCustomerKey,
+		s3_constants.AmzCopySourceServerSideEncryptionCustomerKeyMD5,
+	} {
+		header.Del(k)
+	}
+	for _, k := range sseExtendedKeys {
+		header.Del(k)
+	}
+}
+
+// newSseInfo prepares the encryption asked by the headers of a write request, nil if none is asked
+func (s3a *S3ApiServer) newSseInfo(r *http.Request) (info *sseInfo, dataKey []byte, errCode s3err.ErrorCode) {
+	customerKey, errCode := parseSseCustomerKey(r.Header, false)
+	if errCode != s3err.ErrNone {
+		return nil, nil, errCode
+	}
+	algorithm := r.Header.Get(s3_constants.AmzServerSideEncryption)
+	kmsKeyId := r.Header.Get(s3_constants.AmzServerSideEncryptionAwsKmsKeyId)
+	if customerKey != nil {
+		if algorithm != "" || kmsKeyId != "" {
+			return nil, nil, s3err.ErrInvalidRequest
+		}
+		return &sseInfo{sseType: s3_constants.SseTypeCustomer, customerKeyMd5: customerKey.md5}, customerKey.key, s3err.ErrNone
+	}
+
+	switch algorithm {
+	case "":
+		if kmsKeyId != "" {
+			return nil, nil, s3err.ErrInvalidRequest
+		}
+		return nil, nil, s3err.ErrNone
+	case s3_constants.SseAlgorithmAES256:
+		if kmsKeyId != "" {
+			return nil, nil, s3err.ErrInvalidRequest
+		}
+		info = &sseInfo{sseType: s3_constants.SseTypeS3}
+	case s3_constants.SseAlgorithmKms:
+		info = &sseInfo{sseType: s3_constants.SseTypeKms, kmsKeyId: kmsKeyId}
+	default:
+		return nil, nil, s3err.ErrInvalidEncryptionAlgorithm
+	}
+
+	dataKey, encryptedDataKey, err := s3a.keyProvider.GenerateDataKey(info.kmsKeyId)
+	if err != nil {
+		return nil, nil, sseKeyErrorCode(err)
+	}
+	info.encryptedDataKey = encryptedDataKey
+	return info, dataKey, s3err.ErrNone
+}
+
+// sseDataKey returns the key the object was encrypted with, checking the customer key of SSE-C objects
+func (s3a *S3ApiServer) sseDataKey(info *sseInfo, customerKey *sseCustomerKey) ([]byte, s3err.ErrorCode) {
+	if info.sseType == s3_constants.SseTypeCustomer {
+		if customerKey == nil {
+			return nil, s3err.ErrSSECustomerKeyMissing
+		}
+		if customerKey.md5 != info.customerKeyMd5 {
+			return nil, s3err.ErrSSECustomerKeyMismatch
+		}
+		return customerKey.key, s3err.ErrNone
+	}
+	dataKey, err := s3a.keyProvider.DecryptDataKey(info.kmsKeyId, info.encryptedDataKey)
+	if err != nil {
+		return nil, sseKeyErrorCode(err)
+	}
+	return dataKey, s3err.ErrNone
+}
+
+func sseKeyErrorCode(err error) s3err.ErrorCode {
+	switch {
+	case errors.Is(err, ErrSseNotConfigured):
+		return s3err.ErrSSENotConfigured
+	case errors.Is(err, ErrSseKeyNotFound):
+		return s3err.ErrKMSKeyNotFound
+	default:
+		glog.Errorf("sse data key: %v", err)
+		return s3err.ErrInternalError
+	}
+}
+
+// attributes returns the entry attributes describing the encryption, except the IV or segments
+func (info *sseInfo) attributes() map[string]string {
+	attributes := map[string]string{
+		s3_constants.ExtSseTypeKey: info.sseType,
+	}
+	if info.kmsKeyId != "" {
+		attributes[s3_constants.ExtSseKmsKeyIdKey] = info.kmsKeyId
+	}
+	if len(info.encryptedDataKey) > 0 {
+		attributes[s3_constants.ExtSseDataKeyKey] = base64.StdEncoding.EncodeToString(info.encryptedDataKey)
+	}
+	if info.customerKeyMd5 != "" {
+		attributes[s3_constants.ExtSseCustomerKeyMd5Key] = info.customerKeyMd5
+	}
+	return attributes
+}
+
+// parseSseInfo reads the encryption of an object from its attributes, nil if the object is not encrypted
+func parseSseInfo(get func(key string) string) (*sseInfo, error) {
+	info := &sseInfo{
+		sseType:        get(s3_constants.ExtSseTypeKey),
+		kmsKeyId:       get(s3_constants.ExtSseKmsKeyIdKey),
+		customerKeyMd5: get(s3_constants.ExtSseCustomerKeyMd5Key),
+	}
+	if info.sseType == "" {
+		return nil, nil
+	}
+	var err error
+	if encoded := get(s3_constants.ExtSseDataKeyKey); encoded != "" {
+		if info.encryptedDataKey, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("decode data key: %v", err)
+		}
+	}
+	if encoded := get(s3_constants.ExtSseSegmentsKey); encoded != "" {
+		if info.segments, err = parseSseSegments(encoded); err != nil {
+			return nil, err
+		}
+	} else if encoded := get(s3_constants.ExtSseIvKey); encoded != "" {
+		iv, err := decodeSseIv(encoded)
+		if err != nil {
+			return nil, err
+		}
+		info.segments = []sseSegment{{offset: 0, iv: iv}}
+	}
+	return info, nil
+}
+
+func sseInfoFromHeader(header http.Header) (*sseInfo, error) {
+	return parseSseInfo(header.Get)
+}
+
+func sseInfoFromExtended(extended map[string][]byte) (*sseInfo, error) {
+	return parseSseInfo(func(key string) string {
+		return string(extended[key])
+	})
+}
+
+// formatSseSegment describes a segment of a multipart object by its size and IV, as "size:iv"
+func formatSseSegment(size int64, encodedIv string) string {
+	return fmt.Sprintf("%d:%s", size, encodedIv)
+}
+
+func parseSseSegments(encoded string) (segments []sseSegment, err error) {
+	var offset int64
+	for _, segment := range strings.Split(encoded, ",") {
+		sizeString, encodedIv, found := strings.Cut(segment, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid sse segment %q", segment)
+		}
+		size, err := strconv.ParseInt(sizeString, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sse segment %q: %v", segment, err)
+		}
+		iv, err := decodeSseIv(encodedIv)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, sseSegment{offset: offset, iv: iv})
+		offset += size
+	}
+	return segments, nil
+}
+
+func decodeSseIv(encoded string) ([]byte, error) {
+	iv, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid sse iv %q", encoded)
+	}
+	return iv, nil
+}
+
+func newSseIv() (encodedIv string, iv []byte) {
+	iv = make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		glog.Fatalf("random iv gen: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(iv), iv
+}
+
+// setSseResponseHeaders tells the client how the object is encrypted
+func setSseResponseHeaders(header http.Header, info *sseInfo) {
+	switch info.sseType {
+	case s3_constants.SseTypeS3:
+		header.Set(s3_constants.AmzServerSideEncryption, s3_constants.SseAlgorithmAES256)
+	case s3_constants.SseTypeKms:
+		header.Set(s3_constants.AmzServerSideEncryption, s3_constants.SseAlgorithmKms)
+		if info.kmsKeyId != "" {
+			header.Set(s3_constants.AmzServerSideEncryptionAwsKmsKeyId, info.kmsKeyId)
+		}
+	case s3_constants.SseTypeCustomer:
+		header.Set(s3_constants.AmzServerSideEncryptionCustomerAlgorithm, s3_constants.SseAlgorithmAES256)
+		header.Set(s3_constants.AmzServerSideEncryptionCustomerKeyMD5, info.customerKeyMd5)
+	}
+}
+
+// encryptObject encrypts the data of a new object as asked by the request headers,
+// and sets the headers to keep the encryption attributes on the entry
+func (s3a *S3ApiServer) encryptObject(r *http.Request, dataReader io.Reader) (io.Reader, *sseInfo, s3err.ErrorCode) {
+	info, dataKey, errCode := s3a.newSseInfo(r)
+	removeSseRequestHeaders(r.Header)
+	if errCode != s3err.ErrNone || info == nil {
+		return dataReader, nil, errCode
+	}
+	for k, v := range info.attributes() {
+		r.Header.Set(k, v)
+	}
+	encodedIv, iv := newSseIv()
+	r.Header.Set(s3_constants.ExtSseIvKey, encodedIv)
+	reader, err := newSseReader(dataReader, dataKey, []sseSegment{{offset: 0, iv: iv}}, 0)
+	if err != nil {
+		glog.Errorf("encrypt object: %v", err)
+		return nil, nil, s3err.ErrInternalError
+	}
+	return reader, info, s3err.ErrNone
+}
+
+// encryptPart encrypts an upload part with the data key of its multipart upload
+func (s3a *S3ApiServer) encryptPart(r *http.Request, uploadEntry *filer_pb.Entry, dataReader io.Reader) (io.Reader, *sseInfo, s3err.ErrorCode) {
+	customerKey, errCode := parseSseCustomerKey(r.Header, false)
+	removeSseRequestHeaders(r.Header)
+	if errCode != s3err.ErrNone {
+		return nil, nil, errCode
+	}
+	info, err := sseInfoFromExtended(uploadEntry.Extended)
+	if err != nil {
+		glog.Errorf("upload %s encryption: %v", uploadEntry.Name, err)
+		return nil, nil, s3err.ErrInternalError
+	}
+	if info == nil {
+		return dataReader, nil, s3err.ErrNone
+	}
+	dataKey, errCode := s3a.sseDataKey(info, customerKey)
+	if errCode != s3err.ErrNone {
+		return nil, nil, errCode
+	}
+	encodedIv, iv := newSseIv()
+	r.Header.Set(s3_constants.ExtSseIvKey, encodedIv)
+	reader, err := newSseReader(dataReader, dataKey, []sseSegment{{offset: 0, iv: iv}}, 0)
+	if err != nil {
+		glog.Errorf("encrypt upload %s part: %v", uploadEntry.Name, err)
+		return nil, nil, s3err.ErrInternalError
+	}
+	return reader, info, s3err.ErrNone
+}
+
+// decryptObject decrypts the body of a filer response, if the object is encrypted.
+// The encryption attributes are removed from the response headers.
+func (s3a *S3ApiServer) decryptObject(header http.Header, body io.Reader, customerKey *sseCustomerKey) (io.Reader, *sseInfo, s3err.ErrorCode) {
+	info, err := sseInfoFromHeader(header)
+	for _, k := range sseExtendedKeys {
+		header.Del(k)
+	}
+	if err != nil {
+		glog.Errorf("object encryption: %v", err)
+		return nil, nil, s3err.ErrInternalError
+	}
+	if info == nil {
+		return body, nil, s3err.ErrNone
+	}
+	dataKey, errCode := s3a.sseDataKey(info, customerKey)
+	if errCode != s3err.ErrNone {
+		return nil, nil, errCode
+	}
+	if strings.HasPrefix(header.Get("Content-Type"), "multipart/byteranges") {
+		// each range would need its own decryption
+		return nil, nil, s3err.ErrNotImplemented
+	}
+	var offset int64
+	if contentRange := header.Get("Content-Range"); contentRange != "" {
+		if _, err = fmt.Sscanf(contentRange, "bytes %d-", &offset); err != nil {
+			glog.Errorf("object encryption content range %q: %v", contentRange, err)
+			return nil, nil, s3err.ErrInternalError
+		}
+	}
+	reader, err := newSseReader(body, dataKey, info.segments, offset)
+	if err != nil {
+		glog.Errorf("decrypt object: %v", err)
+		return nil, nil, s3err.ErrInternalError
+	}
+	return reader, info, s3err.ErrNone
+}
+
+// decryptedResponse passes the object proxied from the filer through, decrypting it if it is encrypted
+func (s3a *S3ApiServer) decryptedResponse(r *http.Request, customerKey *sseCustomerKey, responseFn func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int)) func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int) {
+	return func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int) {
+		reader, info, errCode := s3a.decryptObject(proxyResponse.Header, proxyResponse.Body, customerKey)
+		if errCode != s3err.ErrNone {
+			s3err.WriteErrorResponse(w, r, errCode)
+			return s3err.GetAPIError(errCode).HTTPStatusCode
+		}
+		if info != nil {
+			setSseResponseHeaders(proxyResponse.Header, info)
+			proxyResponse.Body = struct {
+				io.Reader
+				io.Closer
+			}{reader, proxyResponse.Body}
+		}
+		return responseFn(proxyResponse, w)
+	}
+}
+
+// sseReader encrypts or decrypts the object data read from the offset
+type sseReader struct {
+	reader   io.Reader
+	block    cipher.Block
+	segments []sseSegment
+	next     int
+	offset   int64
+	stream   cipher.Stream
+}
+
+func newSseReader(reader io.Reader, dataKey []byte, segments []sseSegment, offset int64) (*sseReader, error) {
+	if len(segments) == 0 || segments[0].offset != 0 {
+		return nil, fmt.Errorf("missing sse iv")
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &sseReader{
+		reader:   reader,
+		block:    block,
+		segments: segments,
+		offset:   offset,
+	}, nil
+}
+
+func (r *sseReader) Read(p []byte) (n int, err error) {
+	if r.next < len(r.segments) && r.segments[r.next].offset <= r.offset {
+		for r.next < len(r.segments) && r.segments[r.next].offset <= r.offset {
+			r.next++
+		}
+		segment := r.segments[r.next-1]
+		r.stream = newCtrStream(r.block, segment.iv, r.offset-segment.offset)
+	}
+	if r.next < len(r.segments) {
+		if remaining := r.segments[r.next].offset - r.offset; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err = r.reader.Read(p)
+	r.stream.XORKeyStream(p[:n], p[:n])
+	r.offset += int64(n)
+	return
+}
+
+// newCtrStream starts the CTR key stream at the offset
+func newCtrStream(block cipher.Block, iv []byte, offset int64) cipher.Stream {
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, iv)
+	low := binary.BigEndian.Uint64(counter[8:])
+	high := binary.BigEndian.Uint64(counter[:8])
+	blocks := uint64(offset / aes.BlockSize)
+	if low+blocks < low {
+		high++
+	}
+	binary.BigEndian.PutUint64(counter[8:], low+blocks)
+	binary.BigEndian.PutUint64(counter[:8], high)
+
+	stream := cipher.NewCTR(block, counter)
+	if skip := offset % aes.BlockSize; skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	return stream
+}
@@ -0,0 +1,160 @@
+package s3api
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSseReaderRange(t *testing.T) {
+	key, plaintext := randomBytes(32), randomBytes(1000)
+	_, iv := newSseIv()
+	segments := []sseSegment{{offset: 0, iv: iv}}
+
+	ciphertext := sseTransform(t, plaintext, key, segments, 0)
+	assert.NotEqual(t, plaintext, ciphertext)
+	assert.Equal(t, plaintext, sseTransform(t, ciphertext, key, segments, 0))
+
+	for _, offset := range []int64{1, 15, 16, 17, 500, 999} {
+		assert.Equal(t, plaintext[offset:], sseTransform(t, ciphertext[offset:], key, segments, offset), "offset %d", offset)
+	}
+}
+
+func TestSseReaderSegments(t *testing.T) {
+	key := randomBytes(32)
+	parts := [][]byte{randomBytes(100), randomBytes(37), randomBytes(250)}
+
+	var plaintext, ciphertext []byte
+	var segments []sseSegment
+	for _, part := range parts {
+		_, iv := newSseIv()
+		segments = append(segments, sseSegment{offset: int64(len(plaintext)), iv: iv})
+		ciphertext = append(ciphertext, sseTransform(t, part, key, []sseSegment{{offset: 0, iv: iv}}, 0)...)
+		plaintext = append(plaintext, part...)
+	}
+
+	for _, offset := range []int64{0, 99, 100, 120, 137, 300} {
+		assert.Equal(t, plaintext[offset:], sseTransform(t, ciphertext[offset:], key, segments, offset), "offset %d", offset)
+	}
+}
+
+func TestNewCtrStreamCounterCarry(t *testing.T) {
+	key := randomBytes(32)
+	iv := bytes.Repeat([]byte{0xff}, aes.BlockSize)
+	iv[0] = 0
+	plaintext := randomBytes(100)
+	segments := []sseSegment{{offset: 0, iv: iv}}
+
+	ciphertext := sseTransform(t, plaintext, key, segments, 0)
+	assert.Equal(t, plaintext[40:], sseTransform(t, ciphertext[40:], key, segments, 40))
+}
+
+func TestParseSseCustomerKey(t *testing.T) {
+	key := randomBytes(32)
+	sum := md5.Sum(key)
+	encodedKey, keyMd5 := base64.StdEncoding.EncodeToString(key), base64.StdEncoding.EncodeToString(sum[:])
+
+	customerKey, errCode := parseSseCustomerKey(http.Header{}, false)
+	assert.Nil(t, customerKey)
+	assert.Equal(t, s3err.ErrNone, errCode)
+
+	header := http.Header{}
+	header.Set(s3_constants.AmzServerSideEncryptionCustomerAlgorithm, "AES256")
+	header.Set(s3_constants.AmzServerSideEncryptionCustomerKey, encodedKey)
+	header.Set(s3_constants.AmzServerSideEncryptionCustomerKeyMD5, keyMd5)
+	customerKey, errCode = parseSseCustomerKey(header, false)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, key, customerKey.key)
+
+	customerKey, _ = parseSseCustomerKey(header, true)
+	assert.Nil(t, customerKey)
+
+	header.Set(s3_constants.AmzServerSideEncryptionCustomerKeyMD5, base64.StdEncoding.EncodeToString(sum[1:]))
+	_, errCode = parseSseCustomerKey(header, false)
+	assert.Equal(t, s3err.ErrSSECustomerKeyMD5Mismatch, errCode)
+
+	header.Set(s3_constants.AmzServerSideEncryptionCustomerKey, base64.StdEncoding.EncodeToString(key[1:]))
+	_, errCode = parseSseCustomerKey(header, false)
+	assert.Equal(t, s3err.ErrInvalidSSECustomerKey, errCode)
+
+	header.Set(s3_constants.AmzServerSideEncryptionCustomerAlgorithm, "DES")
+	_, errCode = parseSseCustomerKey(header, false)
+	assert.Equal(t, s3err.ErrInvalidEncryptionAlgorithm, errCode)
+}
+
+func TestSseInfoAttributes(t *testing.T) {
+	info := &sseInfo{
+		sseType:          s3_constants.SseTypeKms,
+		kmsKeyId:         "my-key",
+		encryptedDataKey: randomBytes(60),
+	}
+	extended := make(map[string][]byte)
+	for k, v := range info.attributes() {
+		extended[k] = []byte(v)
+	}
+
+	parsed, err := sseInfoFromExtended(extended)
+	assert.NoError(t, err)
+	assert.Equal(t, info, parsed)
+
+	encodedIv, iv := newSseIv()
+	extended[s3_constants.ExtSseSegmentsKey] = []byte(formatSseSegment(10, encodedIv) + "," + formatSseSegment(20, encodedIv))
+	parsed, err = sseInfoFromExtended(extended)
+	assert.NoError(t, err)
+	assert.Equal(t, []sseSegment{{offset: 0, iv: iv}, {offset: 10, iv: iv}}, parsed.segments)
+
+	parsed, err = sseInfoFromExtended(map[string][]byte{})
+	assert.NoError(t, err)
+	assert.Nil(t, parsed)
+}
+
+func TestLocalKeyProvider(t *testing.T) {
+	defaultKey, namedKey := randomBytes(32), randomBytes(32)
+	p, err := newLocalKeyProvider(base64.StdEncoding.EncodeToString(defaultKey), map[string]string{
+		"named": base64.StdEncoding.EncodeToString(namedKey),
+	})
+	assert.NoError(t, err)
+
+	for _, keyId := range []string{"", "named"} {
+		dataKey, encryptedDataKey, err := p.GenerateDataKey(keyId)
+		assert.NoError(t, err)
+		assert.Len(t, dataKey, 32)
+		decrypted, err := p.DecryptDataKey(keyId, encryptedDataKey)
+		assert.NoError(t, err)
+		assert.Equal(t, dataKey, decrypted)
+	}
+
+	_, _, err = p.GenerateDataKey("unknown")
+	assert.ErrorIs(t, err, ErrSseKeyNotFound)
+
+	p, err = newLocalKeyProvider("", nil)
+	assert.NoError(t, err)
+	_, _, err = p.GenerateDataKey("")
+	assert.ErrorIs(t, err, ErrSseNotConfigured)
+
+	_, err = newLocalKeyProvider(base64.StdEncoding.EncodeToString(defaultKey[:16]), nil)
+	assert.Error(t, err)
+}
+
+func sseTransform(t *testing.T, data, key []byte, segments []sseSegment, offset int64) []byte {
+	reader, err := newSseReader(bytes.NewReader(data), key, segments, offset)
+	assert.NoError(t, err)
+	result, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return result
+}
+
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
@@ -0,0 +1,525 @@
+package s3api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/images"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Objects can be transformed on GET by the rules of the bucket transformation configuration,
+// to derive variants of the objects without storing copies, like S3 Object Lambda does.
+// A rule runs a registered transformer on the objects under its prefix, when the rule is selected
+// by name with the x-seaweed-transform query parameter. A default rule is applied to every GET
+// under its prefix, like an Object Lambda access point in front of the prefix. HEAD reports the
+// transformed object, and ranges are taken from the transformed object.
+// Transformers are either built in, or HTTP callouts configured in security.toml.
+
+const (
+	maxTransformationRules  = 100
+	maxTransformObjectSize  = 64 * 1024 * 1024
+	httpTransformersPrefix  = "s3.transform"
+	httpTransformerTimeout  = 30 * time.Second
+	transformRedactedFields = "fields"
+)
+
+var transformationRuleNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// TransformationConfiguration is the transformation configuration of a bucket
+type TransformationConfiguration struct {
+	XMLName xml.Name             `xml:"TransformationConfiguration" json:"-"`
+	Rules   []TransformationRule `xml:"Rule"`
+}
+
+type TransformationRule struct {
+	Name        string                    `xml:"Name"`
+	Prefix      string                    `xml:"Prefix,omitempty"`
+	Default     bool                      `xml:"Default,omitempty"`
+	Transformer string                    `xml:"Transformer"`
+	Parameters  []TransformationParameter `xml:"Parameter,omitempty"`
+}
+
+type TransformationParameter struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+func (rule *TransformationRule) parameters() map[string]string {
+	parameters := make(map[string]string, len(rule.Parameters))
+	for _, p := range rule.Parameters {
+		parameters[p.Name] = p.Value
+	}
+	return parameters
+}
+
+// TransformObject is the object data and metadata passed through a transformer
+type TransformObject struct {
+	Bucket          string
+	Key             string
+	ContentType     string
+	ContentEncoding string
+	Body            io.Reader
+}
+
+// ObjectTransformer rewrites the objects returned by GET requests
+type ObjectTransformer interface {
+	// Validate checks the parameters of a rule when the configuration is put
+	Validate(parameters map[string]string) error
+	// Transform replaces the body of the object, and its content type and encoding if they change
+	Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error
+}
+
+var (
+	objectTransformersLock sync.RWMutex
+	objectTransformers     = map[string]ObjectTransformer{
+		"resize":     &resizeTransformer{},
+		"convert":    &convertTransformer{},
+		"decompress": &decompressTransformer{},
+		"redact":     &redactTransformer{},
+	}
+)
+
+// RegisterObjectTransformer makes the transformer available to the bucket transformation rules under the name
+func RegisterObjectTransformer(name string, transformer ObjectTransformer) {
+	objectTransformersLock.Lock()
+	defer objectTransformersLock.Unlock()
+	objectTransformers[name] = transformer
+}
+
+func getObjectTransformer(name string) ObjectTransformer {
+	objectTransformersLock.RLock()
+	defer objectTransformersLock.RUnlock()
+	return objectTransformers[name]
+}
+
+// registerHttpTransformers registers each [s3.transform.<name>] table as an HTTP callout transformer
+func registerHttpTransformers(config *util.ViperProxy, prefix string) error {
+	if config == nil {
+		return nil
+	}
+	for name := range config.GetStringMap(prefix) {
+		endpoint := config.GetString(prefix + "." + name + ".url")
+		if endpoint == "" {
+			return fmt.Errorf("initialize %s.%s: missing url", prefix, name)
+		}
+		if getObjectTransformer(name) != nil {
+			return fmt.Errorf("initialize %s.%s: transformer %s already exists", prefix, name, name)
+		}
+		glog.V(0).Infof("Configure s3 transformer %s to %s", name, endpoint)
+		RegisterObjectTransformer(name, &httpTransformer{
+			endpoint: endpoint,
+			client:   &http.Client{Timeout: httpTransformerTimeout},
+		})
+	}
+	return nil
+}
+
+// validateTransformationConfiguration checks the rules are named uniquely and their transformers accept their parameters
+func validateTransformationConfiguration(config *TransformationConfiguration) s3err.ErrorCode {
+	if len(config.Rules) == 0 || len(config.Rules) > maxTransformationRules {
+		return s3err.ErrInvalidRequest
+	}
+	names := make(map[string]bool)
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if !transformationRuleNameRegexp.MatchString(rule.Name) || names[rule.Name] {
+			return s3err.ErrInvalidRequest
+		}
+		names[rule.Name] = true
+		transformer := getObjectTransformer(rule.Transformer)
+		if transformer == nil {
+			return s3err.ErrInvalidRequest
+		}
+		parameters := rule.parameters()
+		if len(parameters) != len(rule.Parameters) {
+			return s3err.ErrInvalidRequest
+		}
+		if err := transformer.Validate(parameters); err != nil {
+			glog.V(1).Infof("invalid transformation rule %s: %v", rule.Name, err)
+			return s3err.ErrInvalidRequest
+		}
+	}
+	return s3err.ErrNone
+}
+
+// findTransformationRule returns the rule transforming the key, the named one or else the default one with the longest prefix
+func findTransformationRule(config *TransformationConfiguration, key, name string) (*TransformationRule, s3err.ErrorCode) {
+	var found *TransformationRule
+	if config != nil {
+		for i := range config.Rules {
+			rule := &config.Rules[i]
+			if !strings.HasPrefix(key, rule.Prefix) {
+				continue
+			}
+			if name != "" {
+				if rule.Name == name {
+					return rule, s3err.ErrNone
+				}
+				continue
+			}
+			if rule.Default && (found == nil || len(rule.Prefix) > len(found.Prefix)) {
+				found = rule
+			}
+		}
+	}
+	if name != "" {
+		return nil, s3err.ErrNoSuchTransformation
+	}
+	return found, s3err.ErrNone
+}
+
+// getObjectTransformation returns the rule transforming the object of the GET request, nil if it is returned as is
+func (s3a *S3ApiServer) getObjectTransformation(r *http.Request, bucket, object string) (*TransformationRule, s3err.ErrorCode) {
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return nil, errCode
+	}
+	return findTransformationRule(bucketMetadata.Transformation, strings.TrimPrefix(object, "/"), r.URL.Query().Get(s3_constants.SeaweedTransformQuery))
+}
+
+// transformedRequest reads the whole object as it is stored, for the GET or HEAD request of an object transformed by the rule.
+// The range and the part number apply to the transformed object, which has a single part.
+func (s3a *S3ApiServer) transformedRequest(r *http.Request, bucket, object string, rule *TransformationRule) (*http.Request, func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int), s3err.ErrorCode) {
+	query := r.URL.Query()
+	if partNumber := query.Get("partNumber"); partNumber != "" && partNumber != "1" {
+		return nil, nil, s3err.ErrInvalidPart
+	}
+	query.Del("partNumber")
+
+	proxyReq := r.Clone(r.Context())
+	proxyReq.Method = http.MethodGet
+	proxyReq.URL.RawQuery = query.Encode()
+	proxyReq.Header.Del("Range")
+	proxyReq.Header.Del("If-Range")
+	proxyReq.Header.Set("Accept-Encoding", "identity")
+	return proxyReq, s3a.transformedResponse(r, bucket, object, rule), s3err.ErrNone
+}
+
+// transformedResponse runs the object through the transformer of the rule before returning it
+func (s3a *S3ApiServer) transformedResponse(r *http.Request, bucket, object string, rule *TransformationRule) func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int) {
+	return func(proxyResponse *http.Response, w http.ResponseWriter) (statusCode int) {
+		if proxyResponse.StatusCode != http.StatusOK {
+			return passThroughResponse(proxyResponse, w)
+		}
+		if proxyResponse.ContentLength > maxTransformObjectSize {
+			s3err.WriteErrorResponse(w, r, s3err.ErrEntityTooLarge)
+			return s3err.GetAPIError(s3err.ErrEntityTooLarge).HTTPStatusCode
+		}
+		transformer := getObjectTransformer(rule.Transformer)
+		if transformer == nil {
+			glog.Errorf("bucket %s transformation %s: transformer %s not found", bucket, rule.Name, rule.Transformer)
+			s3err.WriteErrorResponse(w, r, s3err.ErrTransformationFailed)
+			return s3err.GetAPIError(s3err.ErrTransformationFailed).HTTPStatusCode
+		}
+
+		transformed := &TransformObject{
+			Bucket:          bucket,
+			Key:             strings.TrimPrefix(object, "/"),
+			ContentType:     proxyResponse.Header.Get("Content-Type"),
+			ContentEncoding: proxyResponse.Header.Get("Content-Encoding"),
+			Body:            proxyResponse.Body,
+		}
+		err := transformer.Transform(r.Context(), transformed, rule.parameters())
+		var data []byte
+		if err == nil {
+			data, err = io.ReadAll(io.LimitReader(transformed.Body, maxTransformObjectSize+1))
+		}
+		if err == nil && len(data) > maxTransformObjectSize {
+			err = fmt.Errorf("transformed object is larger than %d bytes", maxTransformObjectSize)
+		}
+		if err != nil {
+			glog.V(1).Infof("transform %s%s with %s: %v", bucket, object, rule.Name, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrTransformationFailed)
+			return s3err.GetAPIError(s3err.ErrTransformationFailed).HTTPStatusCode
+		}
+
+		// the checksums and the etag are the ones of the stored object
+		for k := range proxyResponse.Header {
+			if strings.HasPrefix(k, "X-Amz-Checksum-") {
+				proxyResponse.Header.Del(k)
+			}
+		}
+		proxyResponse.Header.Del("Etag")
+		proxyResponse.Header.Del("Content-Md5")
+		proxyResponse.Header.Del("Content-Range")
+		proxyResponse.Header.Set("Accept-Ranges", "bytes")
+		setOrDeleteHeader(proxyResponse.Header, "Content-Type", transformed.ContentType)
+		setOrDeleteHeader(proxyResponse.Header, "Content-Encoding", transformed.ContentEncoding)
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			first, last, ok := parseObjectRange(rangeHeader, len(data))
+			if !ok {
+				s3err.WriteErrorResponse(w, r, s3err.ErrInvalidRange)
+				return s3err.GetAPIError(s3err.ErrInvalidRange).HTTPStatusCode
+			}
+			proxyResponse.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(data)))
+			data = data[first : last+1]
+		}
+		proxyResponse.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodHead {
+			data = nil
+		}
+		proxyResponse.Body = io.NopCloser(bytes.NewReader(data))
+		return passThroughResponse(proxyResponse, w)
+	}
+}
+
+// parseObjectRange returns the first and the last byte of the single "bytes=" range of an object of the size
+func parseObjectRange(rangeHeader string, size int) (first, last int, ok bool) {
+	spec, found := strings.CutPrefix(rangeHeader, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	firstText, lastText, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if firstText == "" {
+		// the suffix of the object
+		length, err := strconv.Atoi(lastText)
+		if err != nil || length <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		return max(size-length, 0), size - 1, true
+	}
+	first, err := strconv.Atoi(firstText)
+	if err != nil || first < 0 || first >= size {
+		return 0, 0, false
+	}
+	last = size - 1
+	if lastText != "" {
+		requestedLast, err := strconv.Atoi(lastText)
+		if err != nil || requestedLast < first {
+			return 0, 0, false
+		}
+		last = min(last, requestedLast)
+	}
+	return first, last, true
+}
+
+func setOrDeleteHeader(header http.Header, key, value string) {
+	if value == "" {
+		header.Del(key)
+	} else {
+		header.Set(key, value)
+	}
+}
+
+func parseImageSize(parameters map[string]string, name string) (int, error) {
+	value, found := parameters[name]
+	if !found {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return size, nil
+}
+
+// resizeTransformer resizes images to the width and height parameters, in the "fit" or "fill" mode if any
+type resizeTransformer struct{}
+
+func (t *resizeTransformer) Validate(parameters map[string]string) error {
+	width, err := parseImageSize(parameters, "width")
+	if err != nil {
+		return err
+	}
+	height, err := parseImageSize(parameters, "height")
+	if err != nil {
+		return err
+	}
+	if width == 0 && height == 0 {
+		return fmt.Errorf("missing width or height")
+	}
+	if mode := parameters["mode"]; mode != "" && mode != "fit" && mode != "fill" {
+		return fmt.Errorf("invalid mode %q", mode)
+	}
+	return nil
+}
+
+func (t *resizeTransformer) Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error {
+	width, _ := parseImageSize(parameters, "width")
+	height, _ := parseImageSize(parameters, "height")
+	data, err := io.ReadAll(io.LimitReader(object.Body, maxTransformObjectSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxTransformObjectSize {
+		return fmt.Errorf("image is larger than %d bytes", maxTransformObjectSize)
+	}
+	resized, _, _ := images.Resized(strings.ToLower(filepath.Ext(object.Key)), bytes.NewReader(data), width, height, parameters["mode"])
+	object.Body = resized
+	return nil
+}
+
+// convertTransformer converts images to the format parameter, "png", "jpeg" or "gif"
+type convertTransformer struct{}
+
+var convertedImageContentTypes = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"gif":  "image/gif",
+}
+
+func (t *convertTransformer) Validate(parameters map[string]string) error {
+	if _, found := convertedImageContentTypes[parameters["format"]]; !found {
+		return fmt.Errorf("invalid format %q", parameters["format"])
+	}
+	return nil
+}
+
+func (t *convertTransformer) Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error {
+	converted, err := images.Converted(object.Body, parameters["format"])
+	if err != nil {
+		return err
+	}
+	object.Body = converted
+	object.ContentType = convertedImageContentTypes[parameters["format"]]
+	return nil
+}
+
+// decompressTransformer decompresses gzip compressed objects
+type decompressTransformer struct{}
+
+func (t *decompressTransformer) Validate(parameters map[string]string) error {
+	return nil
+}
+
+func (t *decompressTransformer) Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error {
+	reader, err := gzip.NewReader(object.Body)
+	if err != nil {
+		return err
+	}
+	object.Body = reader
+	if object.ContentEncoding == "gzip" {
+		object.ContentEncoding = ""
+	} else {
+		// the content type was the one of the compressed data
+		object.ContentType = "application/octet-stream"
+	}
+	return nil
+}
+
+// redactTransformer removes the comma separated dotted paths of the fields parameter from JSON objects,
+// or replaces their values with the replacement parameter if any
+type redactTransformer struct{}
+
+func (t *redactTransformer) Validate(parameters map[string]string) error {
+	if strings.TrimSpace(parameters[transformRedactedFields]) == "" {
+		return fmt.Errorf("missing fields")
+	}
+	return nil
+}
+
+func (t *redactTransformer) Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error {
+	decoder := json.NewDecoder(io.LimitReader(object.Body, maxTransformObjectSize+1))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Errorf("decode json: %v", err)
+	}
+	replacement, replaced := parameters["replacement"]
+	for _, field := range strings.Split(parameters[transformRedactedFields], ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			redactJsonField(document, strings.Split(field, "."), replacement, replaced)
+		}
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	object.Body = bytes.NewReader(data)
+	return nil
+}
+
+// redactJsonField redacts the field at the path, through all the elements of the arrays along the path
+func redactJsonField(document interface{}, path []string, replacement string, replaced bool) {
+	switch v := document.(type) {
+	case []interface{}:
+		for _, element := range v {
+			redactJsonField(element, path, replacement, replaced)
+		}
+	case map[string]interface{}:
+		child, found := v[path[0]]
+		if !found {
+			return
+		}
+		if len(path) > 1 {
+			redactJsonField(child, path[1:], replacement, replaced)
+		} else if replaced {
+			v[path[0]] = replacement
+		} else {
+			delete(v, path[0])
+		}
+	}
+}
+
+// httpTransformer posts the object to an HTTP endpoint, and returns the response body as the transformed object.
+// The rule parameters are sent as query parameters, and the bucket and key as headers.
+type httpTransformer struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (t *httpTransformer) Validate(parameters map[string]string) error {
+	return nil
+}
+
+func (t *httpTransformer) Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error {
+	u, err := url.Parse(t.endpoint)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	for k, v := range parameters {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), object.Body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(s3_constants.SeaweedTransformBucket, object.Bucket)
+	req.Header.Set(s3_constants.SeaweedTransformKey, object.Key)
+	if object.ContentType != "" {
+		req.Header.Set("Content-Type", object.ContentType)
+	}
+	if object.ContentEncoding != "" {
+		req.Header.Set("Content-Encoding", object.ContentEncoding)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", t.endpoint, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTransformObjectSize+1))
+	if err != nil {
+		return err
+	}
+	object.Body = bytes.NewReader(data)
+	object.ContentType = resp.Header.Get("Content-Type")
+	object.ContentEncoding = resp.Header.Get("Content-Encoding")
+	return nil
+}
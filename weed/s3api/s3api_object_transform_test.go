@@ -0,0 +1,216 @@
+package s3api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+// upperTransformer is a local stand-in for an external transformer
+type upperTransformer struct{}
+
+func (t *upperTransformer) Validate(parameters map[string]string) error {
+	return nil
+}
+
+func (t *upperTransformer) Transform(ctx context.Context, object *TransformObject, parameters map[string]string) error {
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		return err
+	}
+	object.Body = bytes.NewReader(bytes.ToUpper(data))
+	object.ContentType = "text/plain"
+	return nil
+}
+
+func init() {
+	RegisterObjectTransformer("test_upper", &upperTransformer{})
+}
+
+func TestValidateTransformationConfiguration(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []TransformationRule
+		expected s3err.ErrorCode
+	}{
+		{"valid", []TransformationRule{
+			{Name: "thumbnail", Prefix: "images/", Transformer: "resize", Parameters: []TransformationParameter{{"width", "100"}, {"mode", "fit"}}},
+			{Name: "public", Default: true, Transformer: "redact", Parameters: []TransformationParameter{{"fields", "user.email"}}},
+			{Name: "upper", Transformer: "test_upper"},
+		}, s3err.ErrNone},
+		{"no rules", nil, s3err.ErrInvalidRequest},
+		{"duplicated names", []TransformationRule{
+			{Name: "a", Transformer: "decompress"},
+			{Name: "a", Transformer: "decompress"},
+		}, s3err.ErrInvalidRequest},
+		{"invalid name", []TransformationRule{{Name: "a/b", Transformer: "decompress"}}, s3err.ErrInvalidRequest},
+		{"unknown transformer", []TransformationRule{{Name: "a", Transformer: "unknown"}}, s3err.ErrInvalidRequest},
+		{"missing size", []TransformationRule{{Name: "a", Transformer: "resize"}}, s3err.ErrInvalidRequest},
+		{"invalid format", []TransformationRule{{Name: "a", Transformer: "convert", Parameters: []TransformationParameter{{"format", "bmp"}}}}, s3err.ErrInvalidRequest},
+		{"duplicated parameters", []TransformationRule{{Name: "a", Transformer: "convert", Parameters: []TransformationParameter{{"format", "png"}, {"format", "gif"}}}}, s3err.ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, validateTransformationConfiguration(&TransformationConfiguration{Rules: tt.rules}))
+		})
+	}
+}
+
+func TestFindTransformationRule(t *testing.T) {
+	config := &TransformationConfiguration{Rules: []TransformationRule{
+		{Name: "thumbnail", Prefix: "images/", Transformer: "resize"},
+		{Name: "public", Default: true, Transformer: "redact"},
+		{Name: "public_users", Prefix: "users/", Default: true, Transformer: "redact"},
+	}}
+
+	rule, errCode := findTransformationRule(config, "images/a.png", "thumbnail")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "thumbnail", rule.Name)
+
+	_, errCode = findTransformationRule(config, "docs/a.png", "thumbnail")
+	assert.Equal(t, s3err.ErrNoSuchTransformation, errCode)
+
+	rule, _ = findTransformationRule(config, "images/a.png", "")
+	assert.Equal(t, "public", rule.Name)
+	rule, _ = findTransformationRule(config, "users/a.json", "")
+	assert.Equal(t, "public_users", rule.Name)
+
+	rule, errCode = findTransformationRule(nil, "images/a.png", "")
+	assert.Nil(t, rule)
+	assert.Equal(t, s3err.ErrNone, errCode)
+}
+
+func TestRedactTransformer(t *testing.T) {
+	object := &TransformObject{Body: strings.NewReader(`{"id":1,"user":{"email":"a@b.c","name":"a"},"items":[{"secret":"x","n":2},{"n":3}]}`)}
+	assert.NoError(t, (&redactTransformer{}).Transform(context.Background(), object, map[string]string{"fields": "user.email, items.secret"}))
+	data, _ := io.ReadAll(object.Body)
+	assert.JSONEq(t, `{"id":1,"user":{"name":"a"},"items":[{"n":2},{"n":3}]}`, string(data))
+
+	object = &TransformObject{Body: strings.NewReader(`{"user":{"email":"a@b.c"}}`)}
+	assert.NoError(t, (&redactTransformer{}).Transform(context.Background(), object, map[string]string{"fields": "user.email", "replacement": "***"}))
+	data, _ = io.ReadAll(object.Body)
+	assert.JSONEq(t, `{"user":{"email":"***"}}`, string(data))
+}
+
+func TestDecompressTransformer(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("hello"))
+	gz.Close()
+
+	object := &TransformObject{ContentType: "text/plain", ContentEncoding: "gzip", Body: &compressed}
+	assert.NoError(t, (&decompressTransformer{}).Transform(context.Background(), object, nil))
+	data, _ := io.ReadAll(object.Body)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "text/plain", object.ContentType)
+	assert.Empty(t, object.ContentEncoding)
+}
+
+func TestHttpTransformer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get(s3_constants.SeaweedTransformBucket) + "/" + r.Header.Get(s3_constants.SeaweedTransformKey) + ":" + r.URL.Query().Get("suffix") + ":" + string(data)))
+	}))
+	defer server.Close()
+
+	transformer := &httpTransformer{endpoint: server.URL, client: server.Client()}
+	object := &TransformObject{Bucket: "bucket", Key: "a.txt", Body: strings.NewReader("hello")}
+	assert.NoError(t, transformer.Transform(context.Background(), object, map[string]string{"suffix": "!"}))
+	data, _ := io.ReadAll(object.Body)
+	assert.Equal(t, "bucket/a.txt:!:hello", string(data))
+	assert.Equal(t, "text/plain", object.ContentType)
+}
+
+func TestTransformedResponse(t *testing.T) {
+	s3a := &S3ApiServer{}
+	rule := &TransformationRule{Name: "upper", Transformer: "test_upper"}
+	r := httptest.NewRequest(http.MethodGet, "/bucket/a.txt?x-seaweed-transform=upper", nil)
+
+	proxyResponse := &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":          []string{"application/octet-stream"},
+			"Content-Length":        []string{"5"},
+			"Etag":                  []string{`"5d41402abc4b2a76b9719d911017c592"`},
+			"X-Amz-Checksum-Crc32c": []string{"abc"},
+		},
+		Body: io.NopCloser(strings.NewReader("hello")),
+	}
+	w := httptest.NewRecorder()
+	statusCode := s3a.transformedResponse(r, "bucket", "/a.txt", rule)(proxyResponse, w)
+
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "HELLO", w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Header().Get("Etag"))
+	assert.Empty(t, w.Header().Get("X-Amz-Checksum-Crc32c"))
+}
+
+func TestTransformedResponseRange(t *testing.T) {
+	s3a := &S3ApiServer{}
+	rule := &TransformationRule{Name: "upper", Transformer: "test_upper"}
+	respond := func(r *http.Request) *httptest.ResponseRecorder {
+		proxyResponse := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Length": []string{"5"}},
+			Body:       io.NopCloser(strings.NewReader("hello")),
+		}
+		w := httptest.NewRecorder()
+		s3a.transformedResponse(r, "bucket", "/a.txt", rule)(proxyResponse, w)
+		return w
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/bucket/a.txt", nil)
+	r.Header.Set("Range", "bytes=1-2")
+	w := respond(r)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "EL", w.Body.String())
+	assert.Equal(t, "bytes 1-2/5", w.Header().Get("Content-Range"))
+
+	r.Header.Set("Range", "bytes=5-")
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, respond(r).Code)
+
+	// HEAD reports the transformed object
+	r = httptest.NewRequest(http.MethodHead, "/bucket/a.txt", nil)
+	w = respond(r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+}
+
+func TestParseObjectRange(t *testing.T) {
+	tests := []struct {
+		rangeHeader string
+		first, last int
+		ok          bool
+	}{
+		{"bytes=0-3", 0, 3, true},
+		{"bytes=2-", 2, 9, true},
+		{"bytes=5-100", 5, 9, true},
+		{"bytes=-3", 7, 9, true},
+		{"bytes=-30", 0, 9, true},
+		{"bytes=10-", 0, 0, false},
+		{"bytes=3-2", 0, 0, false},
+		{"bytes=0-1,3-4", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, ok := parseObjectRange(tt.rangeHeader, 10)
+		assert.Equal(t, tt.ok, ok, tt.rangeHeader)
+		if ok {
+			assert.Equal(t, []int{tt.first, tt.last}, []int{first, last}, tt.rangeHeader)
+		}
+	}
+}
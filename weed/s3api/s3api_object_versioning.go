@@ -0,0 +1,733 @@
+package s3api
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Versioned buckets keep the latest version of an object at its normal path.
+// Older versions and delete markers are moved into a hidden folder:
+//
+//	<bucket>/.versions/<object key>/<version id>
+//
+// Version ids sort lexicographically from newest to oldest.
+
+const (
+	nullVersionId = s3_constants.NullVersionId
+)
+
+var errNoSuchVersion = errors.New("no such version")
+
+type ListVersionsResultV2 struct {
+	XMLName             xml.Name            `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name                string              `xml:"Name"`
+	Prefix              string              `xml:"Prefix"`
+	KeyMarker           string              `xml:"KeyMarker"`
+	VersionIdMarker     string              `xml:"VersionIdMarker"`
+	NextKeyMarker       string              `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string              `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int                 `xml:"MaxKeys"`
+	Delimiter           string              `xml:"Delimiter,omitempty"`
+	IsTruncated         bool                `xml:"IsTruncated"`
+	Versions            []VersionEntry      `xml:"Version,omitempty"`
+	DeleteMarkers       []DeleteMarkerEntry `xml:"DeleteMarker,omitempty"`
+	CommonPrefixes      []PrefixEntry       `xml:"CommonPrefixes,omitempty"`
+}
+
+// objectVersion is one version of an object, either the current object or an archived one.
+type objectVersion struct {
+	versionId      string
+	entry          *filer_pb.Entry
+	isDeleteMarker bool
+	isLatest       bool
+}
+
+func generateVersionId() string {
+	// inverted timestamp, so that newer versions are listed first
+	return fmt.Sprintf("%016x%08x", math.MaxInt64-time.Now().UnixNano(), util.RandomInt32())
+}
+
+func (s3a *S3ApiServer) getVersioningState(bucket string) string {
+	bucketMetadata, errCode := s3a.bucketRegistry.GetBucketMetadata(bucket)
+	if errCode != s3err.ErrNone {
+		return ""
+	}
+	return bucketMetadata.Versioning
+}
+
+func (s3a *S3ApiServer) isVersioningConfigured(bucket string) bool {
+	return s3a.getVersioningState(bucket) != ""
+}
+
+func (s3a *S3ApiServer) genVersionsFolder(bucket, object string) string {
+	return fmt.Sprintf("%s/%s/%s%s", s3a.option.BucketsPath, bucket, s3_constants.VersionsFolder, strings.TrimSuffix(object, "/"))
+}
+
+func entryVersionId(entry *filer_pb.Entry) string {
+	if entry.Extended != nil {
+		if versionId, ok := entry.Extended[s3_constants.ExtVersionIdKey]; ok && len(versionId) > 0 {
+			return string(versionId)
+		}
+	}
+	return nullVersionId
+}
+
+func isDeleteMarker(entry *filer_pb.Entry) bool {
+	if entry.Extended == nil {
+		return false
+	}
+	_, found := entry.Extended[s3_constants.ExtDeleteMarkerKey]
+	return found
+}
+
+// prepareVersionedWrite archives the current object before it is overwritten,
+// and returns the version id for the new object, or "" for the "null" version.
+func (s3a *S3ApiServer) prepareVersionedWrite(bucket, object string) (versionId string, err error) {
+	state := s3a.getVersioningState(bucket)
+	if state == "" {
+		return "", nil
+	}
+	if err = s3a.archiveCurrentVersion(bucket, object, state); err != nil {
+		return "", err
+	}
+	if state == s3.BucketVersioningStatusEnabled {
+		return generateVersionId(), nil
+	}
+	return "", nil
+}
+
+// archiveCurrentVersion moves the current object into the versions folder.
+// When versioning is suspended, the "null" version is discarded instead of archived.
+func (s3a *S3ApiServer) archiveCurrentVersion(bucket, object, state string) error {
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+	versionsDir := s3a.genVersionsFolder(bucket, object)
+
+	return s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		if state == s3.BucketVersioningStatusSuspended {
+			if err := doDeleteEntry(client, versionsDir, nullVersionId, true, false); err != nil {
+				return err
+			}
+		}
+		resp, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: dir,
+			Name:      name,
+		})
+		if err != nil {
+			if err == filer_pb.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+		if resp.Entry.IsDirectory {
+			return nil
+		}
+		versionId := entryVersionId(resp.Entry)
+		if state == s3.BucketVersioningStatusSuspended && versionId == nullVersionId {
+			// overwritten in place, same as a non-versioned bucket
+			return nil
+		}
+		glog.V(3).Infof("archive %s as version %s", target, versionId)
+		_, err = client.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{
+			OldDirectory: dir,
+			OldName:      name,
+			NewDirectory: versionsDir,
+			NewName:      versionId,
+		})
+		return err
+	})
+}
+
+// createDeleteMarker records a delete marker as the newest version of the object.
+func (s3a *S3ApiServer) createDeleteMarker(bucket, object, versionId string) error {
+	return s3a.mkFile(s3a.genVersionsFolder(bucket, object), versionId, nil, func(entry *filer_pb.Entry) {
+		if entry.Extended == nil {
+			entry.Extended = make(map[string][]byte)
+		}
+		entry.Extended[s3_constants.ExtDeleteMarkerKey] = []byte("true")
+		if versionId != nullVersionId {
+			entry.Extended[s3_constants.ExtVersionIdKey] = []byte(versionId)
+		}
+	})
+}
+
+// listArchivedVersions lists the archived versions of one object, newest first.
+func (s3a *S3ApiServer) listArchivedVersions(client filer_pb.SeaweedFilerClient, versionsDir string) (versions []*objectVersion, err error) {
+	err = filer_pb.SeaweedList(client, versionsDir, "", func(entry *filer_pb.Entry, isLast bool) error {
+		if entry.IsDirectory {
+			return nil
+		}
+		versions = append(versions, &objectVersion{
+			versionId:      entry.Name,
+			entry:          entry,
+			isDeleteMarker: isDeleteMarker(entry),
+		})
+		return nil
+	}, "", false, math.MaxInt32)
+	sortVersions(versions)
+	return
+}
+
+// versionTimeNs is when the version was written. The version ids embed a nanosecond timestamp,
+// while the "null" versions only have the chunk timestamps and the mtime in seconds.
+func versionTimeNs(v *objectVersion) int64 {
+	if v.versionId != nullVersionId && len(v.versionId) >= 16 {
+		if inverted, err := strconv.ParseInt(v.versionId[:16], 16, 64); err == nil {
+			return math.MaxInt64 - inverted
+		}
+	}
+	tsNs := v.entry.Attributes.GetMtime() * int64(time.Second)
+	for _, chunk := range v.entry.GetChunks() {
+		tsNs = max(tsNs, chunk.ModifiedTsNs)
+	}
+	return tsNs
+}
+
+func sortVersions(versions []*objectVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		ti, tj := versionTimeNs(versions[i]), versionTimeNs(versions[j])
+		if ti != tj {
+			return ti > tj
+		}
+		return versions[i].versionId < versions[j].versionId
+	})
+}
+
+// promoteLatestVersion restores the newest archived version as the current object,
+// unless the newest archived version is a delete marker.
+func (s3a *S3ApiServer) promoteLatestVersion(client filer_pb.SeaweedFilerClient, bucket, object string) error {
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+	if _, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{Directory: dir, Name: name}); err == nil {
+		return nil
+	} else if err != filer_pb.ErrNotFound {
+		return err
+	}
+	versionsDir := s3a.genVersionsFolder(bucket, object)
+	versions, err := s3a.listArchivedVersions(client, versionsDir)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 || versions[0].isDeleteMarker {
+		return nil
+	}
+	glog.V(3).Infof("promote %s/%s to %s", versionsDir, versions[0].versionId, target)
+	_, err = client.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{
+		OldDirectory: versionsDir,
+		OldName:      versions[0].versionId,
+		NewDirectory: dir,
+		NewName:      name,
+	})
+	return err
+}
+
+// deleteVersionedObject deletes an object in a bucket with versioning configured.
+// Without a version id, a delete marker is created. With a version id, that version is removed permanently.
+func (s3a *S3ApiServer) deleteVersionedObject(bucket, object, versionId string) (resultVersionId string, deleteMarker bool, err error) {
+	state := s3a.getVersioningState(bucket)
+
+	if versionId == "" {
+		if err = s3a.archiveCurrentVersion(bucket, object, state); err != nil {
+			return
+		}
+		resultVersionId = nullVersionId
+		if state == s3.BucketVersioningStatusEnabled {
+			resultVersionId = generateVersionId()
+		} else {
+			target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+			dir, name := target.DirAndName()
+			if err = s3a.rm(dir, name, true, false); err != nil {
+				return
+			}
+		}
+		err = s3a.createDeleteMarker(bucket, object, resultVersionId)
+		return resultVersionId, true, err
+	}
+
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+	versionsDir := s3a.genVersionsFolder(bucket, object)
+	resultVersionId = versionId
+
+	err = s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, lookupErr := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{Directory: dir, Name: name})
+		if lookupErr != nil && lookupErr != filer_pb.ErrNotFound {
+			return lookupErr
+		}
+		if lookupErr == nil && !resp.Entry.IsDirectory && entryVersionId(resp.Entry) == versionId {
+			if err := doDeleteEntry(client, dir, name, true, false); err != nil {
+				return err
+			}
+		} else {
+			resp, lookupErr = filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{Directory: versionsDir, Name: versionId})
+			if lookupErr == filer_pb.ErrNotFound {
+				return errNoSuchVersion
+			} else if lookupErr != nil {
+				return lookupErr
+			}
+			deleteMarker = isDeleteMarker(resp.Entry)
+			if err := doDeleteEntry(client, versionsDir, versionId, true, false); err != nil {
+				return err
+			}
+		}
+		return s3a.promoteLatestVersion(client, bucket, object)
+	})
+	return
+}
+
+// getObjectVersion looks up a specific version, and returns the path of the entry holding it.
+func (s3a *S3ApiServer) getObjectVersion(bucket, object, versionId string) (objectPath string, entry *filer_pb.Entry, err error) {
+	target := util.FullPath(fmt.Sprintf("%s/%s%s", s3a.option.BucketsPath, bucket, object))
+	dir, name := target.DirAndName()
+	entry, err = s3a.getEntry(dir, name)
+	if err == nil && !entry.IsDirectory && entryVersionId(entry) == versionId {
+		return object, entry, nil
+	}
+	if err != nil && err != filer_pb.ErrNotFound {
+		return "", nil, err
+	}
+	entry, err = s3a.getEntry(s3a.genVersionsFolder(bucket, object), versionId)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("/%s%s/%s", s3_constants.VersionsFolder, strings.TrimSuffix(object, "/"), versionId), entry, nil
+}
+
+// isVersionsFolderKey tells the keys inside the hidden versions folder, whose versions are only reached
+// through the version id of the object key
+func isVersionsFolderKey(object string) bool {
+	object = strings.TrimPrefix(object, "/")
+	return object == s3_constants.VersionsFolder || strings.HasPrefix(object, s3_constants.VersionsFolder+"/")
+}
+
+// getVersionedObjectUrl resolves the filer url for GET and HEAD requests with a versionId.
+func (s3a *S3ApiServer) getVersionedObjectUrl(w http.ResponseWriter, r *http.Request, bucket, object string) (destUrl string, ok bool) {
+	versionId := r.URL.Query().Get("versionId")
+	if versionId == "" {
+		return s3a.toFilerUrl(bucket, object), true
+	}
+	objectPath, entry, err := s3a.getObjectVersion(bucket, object, versionId)
+	if err != nil {
+		if err == filer_pb.ErrNotFound {
+			s3err.WriteErrorResponse(w, r, s3err.ErrNoSuchVersion)
+		} else {
+			glog.Errorf("get %s/%s version %s: %v", bucket, object, versionId, err)
+			s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		}
+		return "", false
+	}
+	if isDeleteMarker(entry) {
+		w.Header().Set(s3_constants.AmzDeleteMarker, "true")
+		w.Header().Set(s3_constants.AmzVersionId, versionId)
+		s3err.WriteErrorResponse(w, r, s3err.ErrMethodNotAllowed)
+		return "", false
+	}
+	w.Header().Set(s3_constants.AmzVersionId, versionId)
+	return s3a.toFilerUrl(bucket, objectPath), true
+}
+
+// ListObjectVersionsHandler List all versions of objects in a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+func (s3a *S3ApiServer) ListObjectVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, _ := s3_constants.GetBucketAndObject(r)
+	glog.V(3).Infof("ListObjectVersionsHandler %s", bucket)
+
+	if err := s3a.checkBucket(r, bucket); err != s3err.ErrNone {
+		s3err.WriteErrorResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	keyMarker := query.Get("key-marker")
+	versionIdMarker := query.Get("version-id-marker")
+	delimiter := query.Get("delimiter")
+	maxKeys := maxObjectListSizeLimit
+	if query.Get("max-keys") != "" {
+		var err error
+		if maxKeys, err = strconv.Atoi(query.Get("max-keys")); err != nil || maxKeys < 0 {
+			s3err.WriteErrorResponse(w, r, s3err.ErrInvalidMaxKeys)
+			return
+		}
+	}
+
+	response, err := s3a.listObjectVersions(bucket, prefix, keyMarker, versionIdMarker, delimiter, maxKeys)
+	if err != nil {
+		glog.Errorf("ListObjectVersionsHandler %s: %v", bucket, err)
+		s3err.WriteErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+
+	writeSuccessResponseXML(w, r, response)
+}
+
+// listObjectVersions lists the versions from the key marker on. The current objects and the versions folder
+// are walked together, directory by directory from the marker, until max-keys entries are listed.
+func (s3a *S3ApiServer) listObjectVersions(bucket, prefix, keyMarker, versionIdMarker, delimiter string, maxKeys int) (response ListVersionsResultV2, err error) {
+	response = ListVersionsResultV2{
+		Name:            bucket,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxKeys,
+		Delimiter:       delimiter,
+	}
+
+	bucketDir := fmt.Sprintf("%s/%s", s3a.option.BucketsPath, bucket)
+	err = s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		listing := &versionListing{
+			list: func(dir, prefix, startFrom string, inclusive bool, limit uint32) (entries []*filer_pb.Entry, err error) {
+				err = filer_pb.SeaweedList(client, dir, prefix, func(entry *filer_pb.Entry, isLast bool) error {
+					entries = append(entries, entry)
+					return nil
+				}, startFrom, inclusive, limit)
+				return
+			},
+			bucketDir:      bucketDir,
+			versionsRoot:   bucketDir + "/" + s3_constants.VersionsFolder,
+			response:       &response,
+			commonPrefixes: make(map[string]bool),
+		}
+		_, err := listing.walk("")
+		return err
+	})
+	if err != nil {
+		return
+	}
+	if !response.IsTruncated {
+		response.NextKeyMarker = ""
+		response.NextVersionIdMarker = ""
+	}
+	return
+}
+
+const versionListingPageSize = 1024
+
+// versionListing walks the keys of a bucket in the order of the directory listings, which the markers follow
+type versionListing struct {
+	list           func(dir, prefix, startFrom string, inclusive bool, limit uint32) ([]*filer_pb.Entry, error)
+	bucketDir      string
+	versionsRoot   string
+	response       *ListVersionsResultV2
+	count          int
+	commonPrefixes map[string]bool
+}
+
+// walk visits the keys under the relative directory, and tells whether the listing is full
+func (l *versionListing) walk(relDir string) (full bool, err error) {
+	dirKey := ""
+	if relDir != "" {
+		dirKey = relDir + "/"
+	}
+	objects := &dirCursor{list: l.list, dir: strings.TrimSuffix(l.bucketDir+"/"+relDir, "/"), inclusive: true}
+	versions := &dirCursor{list: l.list, dir: strings.TrimSuffix(l.versionsRoot+"/"+relDir, "/"), inclusive: true}
+	if rest, found := strings.CutPrefix(l.response.Prefix, dirKey); found {
+		objects.prefix, _, _ = strings.Cut(rest, "/")
+		versions.prefix = objects.prefix
+	}
+	if rest, found := strings.CutPrefix(l.response.KeyMarker, dirKey); found {
+		objects.startFrom, _, _ = strings.Cut(rest, "/")
+		versions.startFrom = objects.startFrom
+	}
+
+	for {
+		object, err := objects.peek()
+		if err != nil {
+			return false, err
+		}
+		version, err := versions.peek()
+		if err != nil {
+			return false, err
+		}
+		if object == nil && version == nil {
+			return false, nil
+		}
+		var name string
+		if object != nil && (version == nil || object.Name <= version.Name) {
+			name = object.Name
+		} else {
+			name = version.Name
+		}
+		var current *filer_pb.Entry
+		var isDir, hasVersions bool
+		if object != nil && object.Name == name {
+			objects.next()
+			if !object.IsDirectory {
+				current = object
+			} else if relDir != "" || (name != s3_constants.MultipartUploadsFolder && name != s3_constants.VersionsFolder) {
+				isDir = true
+			}
+		}
+		if version != nil && version.Name == name {
+			versions.next()
+			hasVersions = version.IsDirectory
+		}
+		if full, err := l.visit(dirKey+name, current, isDir, hasVersions); full || err != nil {
+			return full, err
+		}
+	}
+}
+
+// visit lists the versions of the key, and then the keys under it
+func (l *versionListing) visit(key string, current *filer_pb.Entry, isDir, hasVersions bool) (full bool, err error) {
+	prefix, keyMarker := l.response.Prefix, l.response.KeyMarker
+	inPrefix := strings.HasPrefix(key, prefix)
+	if !inPrefix && !strings.HasPrefix(prefix, key+"/") {
+		return false, nil
+	}
+	// the key itself is listed before the keys under it, and the marker key was listed up to the version id marker
+	isListed := strings.HasPrefix(keyMarker, key+"/") || key == keyMarker && l.response.VersionIdMarker == ""
+	var versions []*objectVersion
+	if current != nil {
+		versions = append(versions, &objectVersion{versionId: entryVersionId(current), entry: current, isLatest: true})
+	}
+	if hasVersions && (!inPrefix || isListed) {
+		// only the keys under it are listed
+		isDir = true
+	} else if hasVersions {
+		entries, err := l.list(l.versionsRoot+"/"+key, "", "", false, math.MaxInt32)
+		if err != nil {
+			return false, err
+		}
+		var archived []*objectVersion
+		for _, entry := range entries {
+			if entry.IsDirectory {
+				isDir = true
+				continue
+			}
+			archived = append(archived, &objectVersion{versionId: entry.Name, entry: entry, isDeleteMarker: isDeleteMarker(entry)})
+		}
+		sortVersions(archived)
+		if current == nil && len(archived) > 0 {
+			archived[0].isLatest = true
+		}
+		versions = append(versions, archived...)
+	}
+
+	if len(versions) > 0 && inPrefix && !isListed {
+		if full := l.addVersions(key, versions); full {
+			return true, nil
+		}
+	}
+	if !isDir {
+		return false, nil
+	}
+	if l.response.Delimiter == "/" && inPrefix {
+		// all the keys under it share the common prefix
+		return l.addCommonPrefix(key + "/"), nil
+	}
+	return l.walk(key)
+}
+
+func (l *versionListing) addVersions(key string, versions []*objectVersion) (full bool) {
+	response := l.response
+	if delimiter := response.Delimiter; delimiter != "" {
+		if i := strings.Index(key[len(response.Prefix):], delimiter); i >= 0 {
+			return l.addCommonPrefix(key[:len(response.Prefix)+i+len(delimiter)])
+		}
+	}
+	skipping := key == response.KeyMarker
+	for _, v := range versions {
+		if skipping {
+			if v.versionId == response.VersionIdMarker {
+				skipping = false
+			}
+			continue
+		}
+		if l.count >= response.MaxKeys {
+			response.IsTruncated = true
+			return true
+		}
+		lastModified := time.Unix(v.entry.Attributes.GetMtime(), 0).UTC()
+		owner := CanonicalUser{
+			ID:          fmt.Sprintf("%x", v.entry.Attributes.GetUid()),
+			DisplayName: v.entry.Attributes.GetUserName(),
+		}
+		if v.isDeleteMarker {
+			response.DeleteMarkers = append(response.DeleteMarkers, DeleteMarkerEntry{
+				Key:          key,
+				VersionId:    v.versionId,
+				IsLatest:     v.isLatest,
+				LastModified: lastModified,
+				Owner:        owner,
+			})
+		} else {
+			storageClass := "STANDARD"
+			if sc, ok := v.entry.Extended[s3_constants.AmzStorageClass]; ok {
+				storageClass = string(sc)
+			}
+			response.Versions = append(response.Versions, VersionEntry{
+				Key:          key,
+				VersionId:    v.versionId,
+				IsLatest:     v.isLatest,
+				LastModified: lastModified,
+				ETag:         "\"" + filer.ETag(v.entry) + "\"",
+				Size:         int64(filer.FileSize(v.entry)),
+				Owner:        owner,
+				StorageClass: StorageClass(storageClass),
+			})
+		}
+		response.NextKeyMarker = key
+		response.NextVersionIdMarker = v.versionId
+		l.count++
+	}
+	return false
+}
+
+func (l *versionListing) addCommonPrefix(commonPrefix string) (full bool) {
+	response := l.response
+	if l.commonPrefixes[commonPrefix] || commonPrefix <= response.KeyMarker && strings.HasPrefix(response.KeyMarker, commonPrefix) {
+		return false
+	}
+	if l.count >= response.MaxKeys {
+		response.IsTruncated = true
+		return true
+	}
+	l.commonPrefixes[commonPrefix] = true
+	response.CommonPrefixes = append(response.CommonPrefixes, PrefixEntry{Prefix: commonPrefix})
+	response.NextKeyMarker = commonPrefix
+	response.NextVersionIdMarker = ""
+	l.count++
+	return false
+}
+
+// dirCursor reads the entries of a directory page by page
+type dirCursor struct {
+	list      func(dir, prefix, startFrom string, inclusive bool, limit uint32) ([]*filer_pb.Entry, error)
+	dir       string
+	prefix    string
+	startFrom string
+	inclusive bool
+	entries   []*filer_pb.Entry
+	isLast    bool
+}
+
+func (c *dirCursor) peek() (*filer_pb.Entry, error) {
+	if len(c.entries) == 0 && !c.isLast {
+		entries, err := c.list(c.dir, c.prefix, c.startFrom, c.inclusive, versionListingPageSize)
+		if err != nil {
+			return nil, err
+		}
+		c.entries, c.isLast = entries, len(entries) < versionListingPageSize
+		if len(entries) > 0 {
+			c.startFrom, c.inclusive = entries[len(entries)-1].Name, false
+		}
+	}
+	if len(c.entries) == 0 {
+		return nil, nil
+	}
+	return c.entries[0], nil
+}
+
+func (c *dirCursor) next() {
+	c.entries = c.entries[1:]
+}
+
+// walkVersionKeys visits the files under rootDir/startDir whose key starts with prefix.
+// For the versions folder, each file is a version, and its parent directory is the object key.
+func (s3a *S3ApiServer) walkVersionKeys(client filer_pb.SeaweedFilerClient, rootDir, startDir, prefix string, isVersionsFolder bool, fn func(key string, entry *filer_pb.Entry)) error {
+	dir := rootDir
+	if startDir != "" {
+		dir = rootDir + "/" + startDir
+	}
+	var subDirs []string
+	err := filer_pb.SeaweedList(client, dir, "", func(entry *filer_pb.Entry, isLast bool) error {
+		relativeDir := strings.TrimPrefix(strings.TrimPrefix(dir, rootDir), "/")
+		key := entry.Name
+		if relativeDir != "" {
+			key = relativeDir + "/" + entry.Name
+		}
+		if entry.IsDirectory {
+			if dir == rootDir && (entry.Name == s3_constants.MultipartUploadsFolder || entry.Name == s3_constants.VersionsFolder) {
+				return nil
+			}
+			if strings.HasPrefix(key, prefix) || strings.HasPrefix(prefix, key+"/") {
+				subDirs = append(subDirs, key)
+			}
+			return nil
+		}
+		if isVersionsFolder {
+			if strings.HasPrefix(relativeDir, prefix) {
+				fn(relativeDir, entry)
+			}
+			return nil
+		}
+		if strings.HasPrefix(key, prefix) {
+			fn(key, entry)
+		}
+		return nil
+	}, "", false, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	for _, subDir := range subDirs {
+		if err := s3a.walkVersionKeys(client, rootDir, subDir, prefix, isVersionsFolder, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setVersionIdHeader prepares a versioned write, and passes the new version id to the filer
+// through the request headers, where it is saved into the entry's extended attributes.
+// For conditional writes, the filer archives the current object under the lock it checks the conditions with.
+func (s3a *S3ApiServer) setVersionIdHeader(r *http.Request, bucket, object string) (versionId string, errCode s3err.ErrorCode) {
+	r.Header.Del(s3_constants.ExtVersionIdKey)
+	r.Header.Del(s3_constants.ExtDeleteMarkerKey)
+	r.Header.Del(s3_constants.SeaweedFSArchiveVersioning)
+	var err error
+	if r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != "" {
+		if state := s3a.getVersioningState(bucket); state != "" {
+			r.Header.Set(s3_constants.SeaweedFSArchiveVersioning, state)
+			if state == s3.BucketVersioningStatusEnabled {
+				versionId = generateVersionId()
+			}
+		}
+	} else {
+		versionId, err = s3a.prepareVersionedWrite(bucket, object)
+	}
+	if err != nil {
+		glog.Errorf("prepare versioned write %s%s: %v", bucket, object, err)
+		return "", s3err.ErrInternalError
+	}
+	if versionId != "" {
+		r.Header.Set(s3_constants.ExtVersionIdKey, versionId)
+	}
+	return versionId, s3err.ErrNone
+}
+
+// rollbackVersionedWrite restores the archived version if the new version failed to be written.
+func (s3a *S3ApiServer) rollbackVersionedWrite(bucket, object string) {
+	if !s3a.isVersioningConfigured(bucket) {
+		return
+	}
+	if err := s3a.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return s3a.promoteLatestVersion(client, bucket, object)
+	}); err != nil {
+		glog.Errorf("rollback versioned write %s%s: %v", bucket, object, err)
+	}
+}
+
+func exposeVersionHeaders(resp *http.Response) {
+	if versionId := resp.Header.Get(s3_constants.ExtVersionIdKey); versionId != "" {
+		resp.Header.Set(s3_constants.AmzVersionId, versionId)
+	}
+	resp.Header.Del(s3_constants.ExtVersionIdKey)
+	resp.Header.Del(s3_constants.ExtDeleteMarkerKey)
+}
@@ -0,0 +1,228 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateVersionIdOrder(t *testing.T) {
+	older := generateVersionId()
+	time.Sleep(time.Millisecond)
+	newer := generateVersionId()
+	assert.True(t, newer < older, "newer version id %s should sort before %s", newer, older)
+}
+
+func TestSplitCopySourceVersionId(t *testing.T) {
+	path, versionId := splitCopySourceVersionId("/bucket/dir/key?versionId=abc")
+	assert.Equal(t, "/bucket/dir/key", path)
+	assert.Equal(t, "abc", versionId)
+
+	path, versionId = splitCopySourceVersionId("/bucket/dir/key")
+	assert.Equal(t, "/bucket/dir/key", path)
+	assert.Equal(t, "", versionId)
+}
+
+func TestSortVersions(t *testing.T) {
+	versionEntry := func(name string, mtime int64, extended map[string][]byte) *objectVersion {
+		entry := &filer_pb.Entry{Name: name, Attributes: &filer_pb.FuseAttributes{Mtime: mtime}, Extended: extended}
+		return &objectVersion{versionId: name, entry: entry, isDeleteMarker: isDeleteMarker(entry)}
+	}
+	versions := []*objectVersion{
+		versionEntry(nullVersionId, 100, nil),
+		versionEntry("b", 200, nil),
+		versionEntry("a", 200, map[string][]byte{s3_constants.ExtDeleteMarkerKey: []byte("true")}),
+	}
+	sortVersions(versions)
+	assert.Equal(t, "a", versions[0].versionId)
+	assert.True(t, versions[0].isDeleteMarker)
+	assert.Equal(t, "b", versions[1].versionId)
+	assert.Equal(t, nullVersionId, versions[2].versionId)
+}
+
+func TestSortVersionsWithinOneSecond(t *testing.T) {
+	now := time.Now()
+	versionAt := func(versionId string, chunkTsNs int64) *objectVersion {
+		entry := &filer_pb.Entry{Name: versionId, Attributes: &filer_pb.FuseAttributes{Mtime: now.Unix()}}
+		if chunkTsNs != 0 {
+			entry.Chunks = []*filer_pb.FileChunk{{ModifiedTsNs: chunkTsNs}}
+		}
+		return &objectVersion{versionId: versionId, entry: entry}
+	}
+	older := generateVersionId()
+	time.Sleep(time.Millisecond)
+	newer := generateVersionId()
+	// the version ids order the versions written in the same second
+	versions := []*objectVersion{versionAt(newer, 0), versionAt(older, 0), versionAt(nullVersionId, time.Now().UnixNano())}
+	sortVersions(versions)
+	assert.Equal(t, []string{nullVersionId, newer, older}, []string{versions[0].versionId, versions[1].versionId, versions[2].versionId})
+}
+
+func TestEntryVersionId(t *testing.T) {
+	assert.Equal(t, nullVersionId, entryVersionId(&filer_pb.Entry{}))
+	assert.Equal(t, "abc", entryVersionId(&filer_pb.Entry{Extended: map[string][]byte{
+		s3_constants.ExtVersionIdKey: []byte("abc"),
+	}}))
+}
+
+func TestListVersionsResultEncoding(t *testing.T) {
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>bucket</Name><Prefix></Prefix><KeyMarker></KeyMarker><VersionIdMarker></VersionIdMarker><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated><Version><Key xmlns="http://s3.amazonaws.com/doc/2006-03-01/">a.txt</Key><VersionId xmlns="http://s3.amazonaws.com/doc/2006-03-01/">v2</VersionId><IsLatest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">true</IsLatest><ETag xmlns="http://s3.amazonaws.com/doc/2006-03-01/">&#34;etag&#34;</ETag><Size xmlns="http://s3.amazonaws.com/doc/2006-03-01/">3</Size><Owner xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ID>0</ID></Owner><StorageClass xmlns="http://s3.amazonaws.com/doc/2006-03-01/">STANDARD</StorageClass><LastModified xmlns="http://s3.amazonaws.com/doc/2006-03-01/">2011-04-09T12:34:49Z</LastModified></Version><DeleteMarker><Key xmlns="http://s3.amazonaws.com/doc/2006-03-01/">b.txt</Key><VersionId xmlns="http://s3.amazonaws.com/doc/2006-03-01/">v1</VersionId><IsLatest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">true</IsLatest><Owner xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><ID>0</ID></Owner><LastModified xmlns="http://s3.amazonaws.com/doc/2006-03-01/">2011-04-09T12:34:49Z</LastModified></DeleteMarker></ListVersionsResult>`
+
+	lastModified := time.Date(2011, 4, 9, 12, 34, 49, 0, time.UTC)
+	response := ListVersionsResultV2{
+		Name:    "bucket",
+		MaxKeys: 1000,
+		Versions: []VersionEntry{{
+			Key:          "a.txt",
+			VersionId:    "v2",
+			IsLatest:     true,
+			LastModified: lastModified,
+			ETag:         "\"etag\"",
+			Size:         3,
+			Owner:        CanonicalUser{ID: "0"},
+			StorageClass: "STANDARD",
+		}},
+		DeleteMarkers: []DeleteMarkerEntry{{
+			Key:          "b.txt",
+			VersionId:    "v1",
+			IsLatest:     true,
+			LastModified: lastModified,
+			Owner:        CanonicalUser{ID: "0"},
+		}},
+	}
+
+	encoded := string(s3err.EncodeXMLResponse(response))
+	if encoded != expected {
+		t.Errorf("unexpected output: %s\nexpecting:%s", encoded, expected)
+	}
+}
+
+func TestSetVersionIdHeaderOfConditionalWrite(t *testing.T) {
+	s3a := &S3ApiServer{bucketRegistry: &BucketRegistry{metadataCache: map[string]*BucketMetaData{
+		"enabled":   {Name: "enabled", Versioning: s3.BucketVersioningStatusEnabled},
+		"suspended": {Name: "suspended", Versioning: s3.BucketVersioningStatusSuspended},
+	}}}
+
+	// the filer archives the current object once the condition holds, nothing is archived beforehand
+	r := httptest.NewRequest(http.MethodPut, "/enabled/object", nil)
+	r.Header.Set("If-None-Match", "*")
+	versionId, errCode := s3a.setVersionIdHeader(r, "enabled", "/object")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.NotEmpty(t, versionId)
+	assert.Equal(t, versionId, r.Header.Get(s3_constants.ExtVersionIdKey))
+	assert.Equal(t, s3.BucketVersioningStatusEnabled, r.Header.Get(s3_constants.SeaweedFSArchiveVersioning))
+	assert.Equal(t, "*", r.Header.Get("If-None-Match"))
+
+	r = httptest.NewRequest(http.MethodPut, "/suspended/object", nil)
+	r.Header.Set("If-Match", `"abc"`)
+	versionId, errCode = s3a.setVersionIdHeader(r, "suspended", "/object")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Empty(t, versionId)
+	assert.Equal(t, s3.BucketVersioningStatusSuspended, r.Header.Get(s3_constants.SeaweedFSArchiveVersioning))
+}
+
+func TestIsVersionsFolderKey(t *testing.T) {
+	assert.True(t, isVersionsFolderKey("/.versions"))
+	assert.True(t, isVersionsFolderKey("/.versions/key/version"))
+	assert.True(t, isVersionsFolderKey(".versions/key"))
+	assert.False(t, isVersionsFolderKey("/.versionsx"))
+	assert.False(t, isVersionsFolderKey("/dir/.versions/key"))
+}
+
+func TestListObjectVersionsPages(t *testing.T) {
+	file := func(name string, mtime int64, extended map[string][]byte) *filer_pb.Entry {
+		return &filer_pb.Entry{Name: name, Attributes: &filer_pb.FuseAttributes{Mtime: mtime}, Extended: extended}
+	}
+	dir := func(name string) *filer_pb.Entry {
+		return &filer_pb.Entry{Name: name, IsDirectory: true}
+	}
+	tree := map[string][]*filer_pb.Entry{
+		"/buckets/bucket": {
+			dir(s3_constants.VersionsFolder), dir(s3_constants.MultipartUploadsFolder),
+			file("a", 300, map[string][]byte{s3_constants.ExtVersionIdKey: []byte("current")}), dir("b"), file("d", 100, nil),
+		},
+		"/buckets/bucket/b":                  {file("c", 100, nil)},
+		"/buckets/bucket/.versions":          {dir("a"), dir("e")},
+		"/buckets/bucket/.versions/a":        {file("v1", 100, nil), file("v2", 200, nil), dir("nested")},
+		"/buckets/bucket/.versions/e":        {file("m1", 300, map[string][]byte{s3_constants.ExtDeleteMarkerKey: []byte("true")})},
+		"/buckets/bucket/.uploads":           {dir("upload")},
+		"/buckets/bucket/.uploads/upload":    {file("0001.part", 100, nil)},
+		"/buckets/bucket/.versions/a/nested": {file("v3", 100, nil)},
+	}
+	list := func(keyMarker, versionIdMarker, delimiter string, maxKeys int) (ListVersionsResultV2, []string) {
+		var listedDirs []string
+		response := ListVersionsResultV2{KeyMarker: keyMarker, VersionIdMarker: versionIdMarker, Delimiter: delimiter, MaxKeys: maxKeys}
+		listing := &versionListing{
+			list: func(dir, prefix, startFrom string, inclusive bool, limit uint32) (entries []*filer_pb.Entry, err error) {
+				listedDirs = append(listedDirs, dir)
+				for _, entry := range tree[dir] {
+					if strings.HasPrefix(entry.Name, prefix) && (entry.Name > startFrom || inclusive && entry.Name == startFrom) {
+						entries = append(entries, entry)
+					}
+				}
+				sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+				if len(entries) > int(limit) {
+					entries = entries[:limit]
+				}
+				return
+			},
+			bucketDir:      "/buckets/bucket",
+			versionsRoot:   "/buckets/bucket/.versions",
+			response:       &response,
+			commonPrefixes: make(map[string]bool),
+		}
+		_, err := listing.walk("")
+		assert.NoError(t, err)
+		return response, listedDirs
+	}
+	keys := func(response ListVersionsResultV2) (keys []string) {
+		for _, v := range response.Versions {
+			keys = append(keys, v.Key+"@"+v.VersionId)
+		}
+		for _, m := range response.DeleteMarkers {
+			keys = append(keys, m.Key+"@"+m.VersionId+"(marker)")
+		}
+		return
+	}
+
+	response, listedDirs := list("", "", "", 1000)
+	assert.Equal(t, []string{"a@current", "a@v2", "a@v1", "a/nested@v3", "b/c@null", "d@null", "e@m1(marker)"}, keys(response))
+	assert.False(t, response.IsTruncated)
+	assert.True(t, response.DeleteMarkers[0].IsLatest, "the delete marker is the latest version of e")
+	assert.NotContains(t, listedDirs, "/buckets/bucket/.uploads")
+
+	response, _ = list("", "", "", 2)
+	assert.Equal(t, []string{"a@current", "a@v2"}, keys(response))
+	assert.True(t, response.IsTruncated)
+	assert.Equal(t, "a", response.NextKeyMarker)
+	assert.Equal(t, "v2", response.NextVersionIdMarker)
+
+	response, _ = list(response.NextKeyMarker, response.NextVersionIdMarker, "", 2)
+	assert.Equal(t, []string{"a@v1", "a/nested@v3"}, keys(response))
+	assert.True(t, response.IsTruncated)
+
+	response, _ = list(response.NextKeyMarker, response.NextVersionIdMarker, "", 2)
+	assert.Equal(t, []string{"b/c@null", "d@null"}, keys(response))
+	assert.True(t, response.IsTruncated)
+
+	response, listedDirs = list(response.NextKeyMarker, response.NextVersionIdMarker, "", 2)
+	assert.Equal(t, []string{"e@m1(marker)"}, keys(response))
+	assert.False(t, response.IsTruncated)
+	assert.NotContains(t, listedDirs, "/buckets/bucket/b", "the keys before the marker are not read")
+
+	response, listedDirs = list("", "", "/", 1000)
+	assert.Equal(t, []PrefixEntry{{Prefix: "a/"}, {Prefix: "b/"}}, response.CommonPrefixes)
+	assert.Equal(t, []string{"a@current", "a@v2", "a@v1", "d@null", "e@m1(marker)"}, keys(response))
+	assert.NotContains(t, listedDirs, "/buckets/bucket/b", "the common prefix is not walked")
+}
@@ -389,7 +389,7 @@ func (s3a *S3ApiServer) doListFilerEntries(client filer_pb.SeaweedFilerClient, d
 		}
 		if entry.IsDirectory {
 			// glog.V(4).Infof("List Dir Entries %s, file: %s, maxKeys %d", dir, entry.Name, cursor.maxKeys)
-			if entry.Name == s3_constants.MultipartUploadsFolder { // FIXME no need to apply to all directories. this extra also affects maxKeys
+			if entry.Name == s3_constants.MultipartUploadsFolder || entry.Name == s3_constants.VersionsFolder { // FIXME no need to apply to all directories. this extra also affects maxKeys
 				continue
 			}
 			if delimiter != "/" || cursor.prefixEndsOnDelimiter {
@@ -0,0 +1,210 @@
+package s3api
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/s3_pb"
+	stats_collect "github.com/seaweedfs/seaweedfs/weed/stats"
+	"golang.org/x/time/rate"
+)
+
+// Rate limits are token buckets for the requests per second, and for the upload and download bandwidth,
+// of all requests, of each bucket, and of each access key. A request over a request rate is rejected
+// with SlowDown, while the bandwidth limits slow the transfers down.
+
+const (
+	rateLimitScopeGlobal   = "global"
+	rateLimitScopeBucket   = "bucket"
+	rateLimitScopeIdentity = "identity"
+
+	rateLimitDirectionUpload   = "upload"
+	rateLimitDirectionDownload = "download"
+)
+
+type rateLimiter struct {
+	scope    string
+	name     string
+	requests *rate.Limiter
+	upload   *rate.Limiter
+	download *rate.Limiter
+}
+
+// newRateLimiter creates the token buckets of the options, reusing the existing ones to keep their tokens.
+// It returns nil if the options do not limit anything.
+func newRateLimiter(existing *rateLimiter, scope, name string, options *s3_pb.S3RateLimit) *rateLimiter {
+	if options == nil {
+		return nil
+	}
+	if existing == nil {
+		existing = &rateLimiter{}
+	}
+	l := &rateLimiter{
+		scope:    scope,
+		name:     name,
+		requests: newTokenBucket(existing.requests, options.RequestsPerSecond),
+		upload:   newTokenBucket(existing.upload, options.UploadBytesPerSecond),
+		download: newTokenBucket(existing.download, options.DownloadBytesPerSecond),
+	}
+	if l.requests == nil && l.upload == nil && l.download == nil {
+		return nil
+	}
+	return l
+}
+
+// newTokenBucket refills perSecond tokens per second, and holds up to one second of tokens
+func newTokenBucket(existing *rate.Limiter, perSecond int64) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst := int(min(perSecond, math.MaxInt32))
+	if existing == nil {
+		return rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+	existing.SetLimit(rate.Limit(perSecond))
+	existing.SetBurst(burst)
+	return existing
+}
+
+func (l *rateLimiter) bandwidth(direction string) *rate.Limiter {
+	if direction == rateLimitDirectionUpload {
+		return l.upload
+	}
+	return l.download
+}
+
+// rateLimitersOf returns the limiters applying to a request on the bucket signed with the access key
+func (cb *CircuitBreaker) rateLimitersOf(bucket, accessKey string) (limiters []*rateLimiter) {
+	cb.RLock()
+	defer cb.RUnlock()
+	if cb.globalRateLimiter != nil {
+		limiters = append(limiters, cb.globalRateLimiter)
+	}
+	if l, found := cb.bucketRateLimiters[bucket]; found && bucket != "" {
+		limiters = append(limiters, l)
+	}
+	if l, found := cb.identityRateLimiters[accessKey]; found && accessKey != "" {
+		limiters = append(limiters, l)
+	}
+	return
+}
+
+// allowRequest takes a request token from each limiter, or none of them and returns how long to wait
+func allowRequest(limiters []*rateLimiter) (retryAfter time.Duration, allowed bool) {
+	now := time.Now()
+	var reservations []*rate.Reservation
+	for _, l := range limiters {
+		if l.requests == nil {
+			continue
+		}
+		reservation := l.requests.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			for _, taken := range reservations {
+				taken.CancelAt(now)
+			}
+			stats_collect.S3RateLimitRejectedCounter.WithLabelValues(l.scope, l.name).Inc()
+			return delay, false
+		}
+		reservations = append(reservations, reservation)
+	}
+	return 0, true
+}
+
+// setRetryAfter tells the client to retry after the delay, in whole seconds
+func setRetryAfter(w http.ResponseWriter, delay time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(delay.Seconds())))))
+}
+
+// waitBandwidth waits until each limiter allows n more bytes in the direction
+func waitBandwidth(ctx context.Context, limiters []*rateLimiter, direction string, n int) error {
+	for _, l := range limiters {
+		limiter := l.bandwidth(direction)
+		if limiter == nil {
+			continue
+		}
+		start := time.Now()
+		for remaining := n; remaining > 0; {
+			chunk := min(remaining, limiter.Burst())
+			if err := limiter.WaitN(ctx, chunk); err != nil {
+				return err
+			}
+			remaining -= chunk
+		}
+		if waited := time.Since(start); waited > time.Millisecond {
+			stats_collect.S3RateLimitWaitCounter.WithLabelValues(l.scope, l.name, direction).Add(waited.Seconds())
+		}
+	}
+	return nil
+}
+
+func hasBandwidthLimit(limiters []*rateLimiter, direction string) bool {
+	for _, l := range limiters {
+		if l.bandwidth(direction) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitedReader slows the request body down to the upload bandwidth limits
+type rateLimitedReader struct {
+	io.ReadCloser
+	ctx      context.Context
+	limiters []*rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := waitBandwidth(r.ctx, r.limiters, rateLimitDirectionUpload, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return
+}
+
+// rateLimitedResponseWriter slows the response body down to the download bandwidth limits
+type rateLimitedResponseWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	limiters []*rateLimiter
+}
+
+func (w *rateLimitedResponseWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := min(len(p), 64*1024)
+		if err = waitBandwidth(w.ctx, w.limiters, rateLimitDirectionDownload, chunk); err != nil {
+			return
+		}
+		var written int
+		written, err = w.ResponseWriter.Write(p[:chunk])
+		n += written
+		if err != nil {
+			return
+		}
+		p = p[chunk:]
+	}
+	return
+}
+
+func (w *rateLimitedResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// limitBandwidth wraps the request body and the response writer to apply the bandwidth limits
+func limitBandwidth(w http.ResponseWriter, r *http.Request, limiters []*rateLimiter) (http.ResponseWriter, *http.Request) {
+	if r.Body != nil && r.Body != http.NoBody && hasBandwidthLimit(limiters, rateLimitDirectionUpload) {
+		r.Body = &rateLimitedReader{ReadCloser: r.Body, ctx: r.Context(), limiters: limiters}
+	}
+	if hasBandwidthLimit(limiters, rateLimitDirectionDownload) {
+		w = &rateLimitedResponseWriter{ResponseWriter: w, ctx: r.Context(), limiters: limiters}
+	}
+	return w, r
+}
@@ -0,0 +1,79 @@
+package s3api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/seaweedfs/seaweedfs/weed/pb/s3_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	cb := &CircuitBreaker{}
+	assert.NoError(t, cb.loadCircuitBreakerConfig(&s3_pb.S3CircuitBreakerConfig{
+		Buckets: map[string]*s3_pb.S3CircuitBreakerOptions{
+			"limited":  {Enabled: true, RateLimit: &s3_pb.S3RateLimit{RequestsPerSecond: 2}},
+			"disabled": {RateLimit: &s3_pb.S3RateLimit{RequestsPerSecond: 1}},
+		},
+		Identities: map[string]*s3_pb.S3CircuitBreakerOptions{
+			"some_access_key": {Enabled: true, RateLimit: &s3_pb.S3RateLimit{RequestsPerSecond: 1}},
+		},
+	}))
+	assert.True(t, cb.rateLimited.Load())
+	assert.False(t, cb.Enabled)
+
+	handler, _ := cb.Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, s3_constants.ACTION_READ)
+	do := func(bucket, authorization string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/"+bucket, nil)
+		r = mux.SetURLVars(r, map[string]string{"bucket": bucket})
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, do("limited", "").Code)
+	assert.Equal(t, http.StatusOK, do("limited", "").Code)
+	w := do("limited", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "SlowDown")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("disabled", "").Code)
+	}
+
+	authorization := "AWS4-HMAC-SHA256 Credential=some_access_key/20130524/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=abc"
+	assert.Equal(t, http.StatusOK, do("disabled", authorization).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do("disabled", authorization).Code)
+	assert.Equal(t, http.StatusOK, do("disabled", "AWS other_access_key:signature").Code)
+
+	// reloading keeps the tokens of unchanged limits
+	limiter := cb.bucketRateLimiters["limited"]
+	assert.NoError(t, cb.loadCircuitBreakerConfig(&s3_pb.S3CircuitBreakerConfig{
+		Buckets: map[string]*s3_pb.S3CircuitBreakerOptions{
+			"limited": {Enabled: true, RateLimit: &s3_pb.S3RateLimit{RequestsPerSecond: 2}},
+		},
+	}))
+	assert.Equal(t, limiter.requests, cb.bucketRateLimiters["limited"].requests)
+	assert.Equal(t, http.StatusServiceUnavailable, do("limited", "").Code)
+	assert.Empty(t, cb.identityRateLimiters)
+}
+
+func TestGetRequestAccessKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/object?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=presigned_key%2F20130524%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Signature=abc", nil)
+	assert.Equal(t, "presigned_key", getRequestAccessKey(r))
+
+	r = httptest.NewRequest(http.MethodGet, "/bucket/object?AWSAccessKeyId=presigned_v2_key&Signature=abc&Expires=1", nil)
+	assert.Equal(t, "presigned_v2_key", getRequestAccessKey(r))
+
+	r = httptest.NewRequest(http.MethodGet, "/bucket/object", nil)
+	assert.Equal(t, "", getRequestAccessKey(r))
+}
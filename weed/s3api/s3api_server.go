@@ -6,12 +6,15 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/notification"
 	"github.com/seaweedfs/seaweedfs/weed/pb/s3_pb"
 	"github.com/seaweedfs/seaweedfs/weed/util/grace"
+	"github.com/seaweedfs/seaweedfs/weed/util/log_buffer"
 
 	"github.com/gorilla/mux"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
@@ -35,6 +38,7 @@ type S3ApiServerOption struct {
 	LocalFilerSocket          string
 	DataCenter                string
 	FilerGroup                string
+	WebsiteDomainName         string
 }
 
 type S3ApiServer struct {
@@ -46,6 +50,15 @@ type S3ApiServer struct {
 	filerGuard     *security.Guard
 	client         *http.Client
 	bucketRegistry *BucketRegistry
+	keyProvider    KeyProvider
+	// bucket event notification targets by name
+	notificationTargets map[string]notification.MessageQueue
+	// bucket replication destinations by name
+	replicationTargets map[string]*replicationTarget
+	// server access log lines of the buckets with logging enabled
+	accessLogBuffer *log_buffer.LogBuffer
+	// serializes the read-modify-write of the bucket entries
+	bucketEntryLock sync.Mutex
 }
 
 func NewS3ApiServer(router *mux.Router, option *S3ApiServerOption) (s3ApiServer *S3ApiServer, err error) {
@@ -66,12 +79,37 @@ func NewS3ApiServer(router *mux.Router, option *S3ApiServerOption) (s3ApiServer
 		option.AllowedOrigins = domains
 	}
 
+	keyProvider, err := newLocalKeyProvider(v.GetString("s3.sse.key"), v.GetStringMapString("s3.sse.kms_keys"))
+	if err != nil {
+		return nil, fmt.Errorf("load s3 sse keys: %v", err)
+	}
+
+	util.LoadConfiguration("notification", false)
+	notificationTargets, err := notification.LoadNamedQueues(v, notificationTargetsPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("load s3 notification targets: %v", err)
+	}
+
+	if err = registerHttpTransformers(v, httpTransformersPrefix); err != nil {
+		return nil, fmt.Errorf("load s3 transformers: %v", err)
+	}
+
+	util.LoadConfiguration("replication", false)
+	replicationTargets, err := loadReplicationTargets(v, replicationTargetsPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("load s3 replication targets: %v", err)
+	}
+
 	s3ApiServer = &S3ApiServer{
 		option:         option,
 		iam:            NewIdentityAccessManagement(option),
 		randomClientId: util.RandomInt32(),
 		filerGuard:     security.NewGuard([]string{}, signingKey, expiresAfterSec, readSigningKey, readExpiresAfterSec),
 		cb:             NewCircuitBreaker(option),
+		keyProvider:    keyProvider,
+
+		notificationTargets: notificationTargets,
+		replicationTargets:  replicationTargets,
 	}
 	if option.Config != "" {
 		grace.OnReload(func() {
@@ -83,6 +121,9 @@ func NewS3ApiServer(router *mux.Router, option *S3ApiServerOption) (s3ApiServer
 		})
 	}
 	s3ApiServer.bucketRegistry = NewBucketRegistry(s3ApiServer)
+	s3ApiServer.iam.bucketPolicies = s3ApiServer.bucketRegistry
+	s3ApiServer.iam.objectAcls = s3ApiServer
+	s3ApiServer.iam.stsKey = []byte(v.GetString("s3.sts.key"))
 	if option.LocalFilerSocket == "" {
 		s3ApiServer.client = &http.Client{Transport: &http.Transport{
 			MaxIdleConns:        1024,
@@ -101,6 +142,13 @@ func NewS3ApiServer(router *mux.Router, option *S3ApiServerOption) (s3ApiServer
 	s3ApiServer.registerRouter(router)
 
 	go s3ApiServer.subscribeMetaEvents("s3", time.Now().UnixNano(), filer.DirectoryEtcRoot, []string{option.BucketsPath})
+	go s3ApiServer.startLifecycleWorker()
+	go s3ApiServer.startNotificationWorker()
+	go s3ApiServer.startReplicationWorker()
+	go s3ApiServer.startInventoryWorker()
+	go s3ApiServer.startBatchJobWorker()
+	s3ApiServer.accessLogBuffer = log_buffer.NewLogBuffer("s3-access-log", accessLogFlushInterval, s3ApiServer.flushAccessLogs, nil, nil)
+	grace.OnInterrupt(s3ApiServer.accessLogBuffer.ShutdownLogBuffer)
 	return s3ApiServer, nil
 }
 
@@ -111,32 +159,11 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 	// Readiness Probe
 	apiRouter.Methods("GET").Path("/status").HandlerFunc(s3a.StatusHandler)
 
-	apiRouter.Methods("OPTIONS").HandlerFunc(
-		func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			if origin != "" {
-				if s3a.option.AllowedOrigins == nil || len(s3a.option.AllowedOrigins) == 0 || s3a.option.AllowedOrigins[0] == "*" {
-					origin = "*"
-				} else {
-					originFound := false
-					for _, allowedOrigin := range s3a.option.AllowedOrigins {
-						if origin == allowedOrigin {
-							originFound = true
-						}
-					}
-					if !originFound {
-						writeFailureResponse(w, r, http.StatusForbidden)
-						return
-					}
-				}
-			}
+	// static websites on {bucket}.{websiteDomainName}
+	s3a.registerWebsiteRouter(apiRouter, false)
 
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Expose-Headers", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "*")
-			w.Header().Set("Access-Control-Allow-Headers", "*")
-			writeSuccessResponseEmpty(w, r)
-		})
+	// STS
+	apiRouter.Methods("POST").Path("/").HeadersRegexp("Content-Type", "application/x-www-form-urlencoded").HandlerFunc(track(s3a.StsHandler, "POST"))
 
 	var routers []*mux.Router
 	if s3a.option.DomainName != "" {
@@ -152,6 +179,9 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 
 	for _, bucket := range routers {
 
+		bucket.Use(s3a.accessLogMiddleware)
+		bucket.Use(s3a.corsMiddleware)
+
 		// each case should follow the next rule:
 		// - requesting object with query must precede any other methods
 		// - requesting object must precede any methods with buckets
@@ -168,6 +198,8 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.CompleteMultipartUploadHandler, ACTION_WRITE)), "POST")).Queries("uploadId", "{uploadId:.*}")
 		// NewMultipartUpload
 		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.NewMultipartUploadHandler, ACTION_WRITE)), "POST")).Queries("uploads", "")
+		// SelectObjectContent
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.SelectObjectContentHandler, ACTION_READ)), "POST")).Queries("select", "", "select-type", "2")
 		// AbortMultipartUpload
 		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.AbortMultipartUploadHandler, ACTION_WRITE)), "DELETE")).Queries("uploadId", "{uploadId:.*}")
 		// ListObjectParts
@@ -184,12 +216,14 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 
 		// PutObjectACL
 		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutObjectAclHandler, ACTION_WRITE_ACP)), "PUT")).Queries("acl", "")
+		// GetObjectRetention
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetObjectRetentionHandler, ACTION_READ)), "GET")).Queries("retention", "")
 		// PutObjectRetention
 		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutObjectRetentionHandler, ACTION_WRITE)), "PUT")).Queries("retention", "")
+		// GetObjectLegalHold
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetObjectLegalHoldHandler, ACTION_READ)), "GET")).Queries("legal-hold", "")
 		// PutObjectLegalHold
 		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutObjectLegalHoldHandler, ACTION_WRITE)), "PUT")).Queries("legal-hold", "")
-		// PutObjectLockConfiguration
-		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutObjectLockConfigurationHandler, ACTION_WRITE)), "PUT")).Queries("object-lock", "")
 
 		// GetObjectACL
 		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetObjectAclHandler, ACTION_READ_ACP)), "GET")).Queries("acl", "")
@@ -244,16 +278,74 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 		// DeleteBucketLifecycleConfiguration
 		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.DeleteBucketLifecycleHandler, ACTION_WRITE)), "DELETE")).Queries("lifecycle", "")
 
+		// GetBucketNotificationConfiguration
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketNotificationConfigurationHandler, ACTION_READ)), "GET")).Queries("notification", "")
+		// PutBucketNotificationConfiguration
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketNotificationConfigurationHandler, ACTION_WRITE)), "PUT")).Queries("notification", "")
+
+		// GetBucketWebsite
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketWebsiteHandler, ACTION_READ)), "GET")).Queries("website", "")
+		// PutBucketWebsite
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketWebsiteHandler, ACTION_WRITE)), "PUT")).Queries("website", "")
+		// DeleteBucketWebsite
+		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.DeleteBucketWebsiteHandler, ACTION_WRITE)), "DELETE")).Queries("website", "")
+
+		// GetBucketReplication
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketReplicationHandler, ACTION_READ)), "GET")).Queries("replication", "")
+		// PutBucketReplication
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketReplicationHandler, ACTION_WRITE)), "PUT")).Queries("replication", "")
+		// DeleteBucketReplication
+		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.DeleteBucketReplicationHandler, ACTION_WRITE)), "DELETE")).Queries("replication", "")
+
+		// GetBucketTransformation
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketTransformationHandler, ACTION_READ)), "GET")).Queries("transformation", "")
+		// PutBucketTransformation
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketTransformationHandler, ACTION_WRITE)), "PUT")).Queries("transformation", "")
+		// DeleteBucketTransformation
+		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.DeleteBucketTransformationHandler, ACTION_WRITE)), "DELETE")).Queries("transformation", "")
+
+		// GetBucketLogging
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketLoggingHandler, ACTION_READ)), "GET")).Queries("logging", "")
+		// PutBucketLogging
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketLoggingHandler, ACTION_WRITE)), "PUT")).Queries("logging", "")
+
+		// GetBucketInventoryConfiguration
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketInventoryConfigurationHandler, ACTION_READ)), "GET")).Queries("inventory", "", "id", "{id:.+}")
+		// PutBucketInventoryConfiguration
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketInventoryConfigurationHandler, ACTION_WRITE)), "PUT")).Queries("inventory", "", "id", "{id:.+}")
+		// DeleteBucketInventoryConfiguration
+		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.DeleteBucketInventoryConfigurationHandler, ACTION_WRITE)), "DELETE")).Queries("inventory", "", "id", "{id:.+}")
+		// ListBucketInventoryConfigurations
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.ListBucketInventoryConfigurationsHandler, ACTION_READ)), "GET")).Queries("inventory", "")
+
+		// CreateBatchJob
+		bucket.Methods("POST").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.CreateBatchJobHandler, ACTION_WRITE)), "POST")).Queries("batch-job", "")
+		// DescribeBatchJob
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.DescribeBatchJobHandler, ACTION_READ)), "GET")).Queries("batch-job", "", "id", "{id:.+}")
+		// CancelBatchJob
+		bucket.Methods("DELETE").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.CancelBatchJobHandler, ACTION_WRITE)), "DELETE")).Queries("batch-job", "", "id", "{id:.+}")
+		// ListBatchJobs
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.ListBatchJobsHandler, ACTION_READ)), "GET")).Queries("batch-job", "")
+
 		// GetBucketLocation
 		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketLocationHandler, ACTION_READ)), "GET")).Queries("location", "")
 
 		// GetBucketRequestPayment
 		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketRequestPaymentHandler, ACTION_READ)), "GET")).Queries("requestPayment", "")
 
+		// GetObjectLockConfiguration
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetObjectLockConfigurationHandler, ACTION_READ)), "GET")).Queries("object-lock", "")
+		// PutObjectLockConfiguration
+		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutObjectLockConfigurationHandler, ACTION_WRITE)), "PUT")).Queries("object-lock", "")
+
 		// GetBucketVersioning
 		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.GetBucketVersioningHandler, ACTION_READ)), "GET")).Queries("versioning", "")
+		// PutBucketVersioning
 		bucket.Methods("PUT").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PutBucketVersioningHandler, ACTION_WRITE)), "PUT")).Queries("versioning", "")
 
+		// ListObjectVersions
+		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.ListObjectVersionsHandler, ACTION_LIST)), "LIST")).Queries("versions", "")
+
 		// ListObjectsV2
 		bucket.Methods("GET").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.ListObjectsV2Handler, ACTION_LIST)), "LIST")).Queries("list-type", "2")
 
@@ -269,6 +361,9 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 
 		// raw buckets
 
+		// CORS preflight
+		bucket.Methods("OPTIONS").HandlerFunc(track(s3a.BucketCorsPreflightHandler, "OPTIONS"))
+
 		// PostPolicy
 		bucket.Methods("POST").HeadersRegexp("Content-Type", "multipart/form-data*").HandlerFunc(track(s3a.iam.Auth(s3a.cb.Limit(s3a.PostPolicyBucketHandler, ACTION_WRITE)), "POST"))
 
@@ -288,6 +383,8 @@ func (s3a *S3ApiServer) registerRouter(router *mux.Router) {
 
 	}
 
+	apiRouter.Methods("OPTIONS").HandlerFunc(s3a.writeDefaultOptionsResponse)
+
 	// ListBuckets
 	apiRouter.Methods("GET").Path("/").HandlerFunc(track(s3a.ListBucketsHandler, "LIST"))
 
@@ -0,0 +1,104 @@
+package s3api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+var (
+	// ErrSseKeyNotFound is returned by a KeyProvider that does not know the master key id
+	ErrSseKeyNotFound = errors.New("sse master key not found")
+	// ErrSseNotConfigured is returned by a KeyProvider without any master key
+	ErrSseNotConfigured = errors.New("sse master key not configured")
+)
+
+// KeyProvider protects the data keys of SSE-S3 and SSE-KMS objects with master keys.
+// An empty key id selects the default master key, used by SSE-S3.
+// Plug in a key management service by setting it with S3ApiServer.SetKeyProvider.
+type KeyProvider interface {
+	// GenerateDataKey returns a new 256-bit data key, in plaintext and encrypted by the master key
+	GenerateDataKey(keyId string) (dataKey, encryptedDataKey []byte, err error)
+	// DecryptDataKey returns the plaintext of a data key encrypted by the master key
+	DecryptDataKey(keyId string, encryptedDataKey []byte) (dataKey []byte, err error)
+}
+
+// localKeyProvider keeps the master keys in the gateway configuration
+type localKeyProvider struct {
+	defaultKey util.CipherKey
+	keys       map[string]util.CipherKey
+}
+
+// newLocalKeyProvider takes base64 encoded master keys, the default one and the ones by key id
+func newLocalKeyProvider(defaultKey string, kmsKeys map[string]string) (*localKeyProvider, error) {
+	p := &localKeyProvider{
+		keys: make(map[string]util.CipherKey),
+	}
+	if defaultKey != "" {
+		key, err := decodeMasterKey(defaultKey)
+		if err != nil {
+			return nil, fmt.Errorf("default key: %v", err)
+		}
+		p.defaultKey = key
+	}
+	for keyId, encoded := range kmsKeys {
+		key, err := decodeMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", keyId, err)
+		}
+		p.keys[keyId] = key
+	}
+	return p, nil
+}
+
+func decodeMasterKey(encoded string) (util.CipherKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expect a 256-bit key, got %d bits", len(key)*8)
+	}
+	return key, nil
+}
+
+func (p *localKeyProvider) masterKey(keyId string) (util.CipherKey, error) {
+	if keyId == "" {
+		if p.defaultKey == nil {
+			return nil, ErrSseNotConfigured
+		}
+		return p.defaultKey, nil
+	}
+	key, found := p.keys[keyId]
+	if !found {
+		return nil, ErrSseKeyNotFound
+	}
+	return key, nil
+}
+
+func (p *localKeyProvider) GenerateDataKey(keyId string) (dataKey, encryptedDataKey []byte, err error) {
+	masterKey, err := p.masterKey(keyId)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey = util.GenCipherKey()
+	if encryptedDataKey, err = util.Encrypt(dataKey, masterKey); err != nil {
+		return nil, nil, err
+	}
+	return dataKey, encryptedDataKey, nil
+}
+
+func (p *localKeyProvider) DecryptDataKey(keyId string, encryptedDataKey []byte) (dataKey []byte, err error) {
+	masterKey, err := p.masterKey(keyId)
+	if err != nil {
+		return nil, err
+	}
+	return util.Decrypt(encryptedDataKey, masterKey)
+}
+
+// SetKeyProvider replaces the master keys configured in security.toml, f.e. with a key management service
+func (s3a *S3ApiServer) SetKeyProvider(keyProvider KeyProvider) {
+	s3a.keyProvider = keyProvider
+}
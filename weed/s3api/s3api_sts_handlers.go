@@ -0,0 +1,228 @@
+package s3api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+)
+
+const (
+	stsXmlns          = "https://sts.amazonaws.com/doc/2011-06-15/"
+	stsMaxRequestSize = 64 * 1024
+)
+
+type StsCredentials struct {
+	AccessKeyId     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+type StsAssumedRoleUser struct {
+	Arn           string `xml:"Arn"`
+	AssumedRoleId string `xml:"AssumedRoleId"`
+}
+
+type StsResponseMetadata struct {
+	RequestId string `xml:"RequestId"`
+}
+
+type AssumeRoleResponse struct {
+	XMLName          xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleResponse"`
+	AssumeRoleResult struct {
+		Credentials     StsCredentials     `xml:"Credentials"`
+		AssumedRoleUser StsAssumedRoleUser `xml:"AssumedRoleUser"`
+	} `xml:"AssumeRoleResult"`
+	ResponseMetadata StsResponseMetadata `xml:"ResponseMetadata"`
+}
+
+type AssumeRoleWithWebIdentityResponse struct {
+	XMLName                         xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleWithWebIdentityResponse"`
+	AssumeRoleWithWebIdentityResult struct {
+		SubjectFromWebIdentityToken string             `xml:"SubjectFromWebIdentityToken"`
+		Audience                    string             `xml:"Audience"`
+		Provider                    string             `xml:"Provider"`
+		Credentials                 StsCredentials     `xml:"Credentials"`
+		AssumedRoleUser             StsAssumedRoleUser `xml:"AssumedRoleUser"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+	ResponseMetadata StsResponseMetadata `xml:"ResponseMetadata"`
+}
+
+type StsErrorResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ ErrorResponse"`
+	Error   struct {
+		Type    string `xml:"Type"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	RequestId string `xml:"RequestId"`
+}
+
+// StsHandler serves the STS actions, which are form encoded POST requests to the root of the S3 endpoint
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRoleWithWebIdentity.html
+func (s3a *S3ApiServer) StsHandler(w http.ResponseWriter, r *http.Request) {
+	// keep the body, AssumeRole needs it again to verify the signature
+	body, err := io.ReadAll(io.LimitReader(r.Body, stsMaxRequestSize))
+	if err != nil {
+		writeStsErrorResponse(w, r, s3err.ErrInvalidRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeStsErrorResponse(w, r, s3err.ErrStsValidation)
+		return
+	}
+	glog.V(3).Infof("StsHandler %s", values.Get("Action"))
+
+	switch values.Get("Action") {
+	case "AssumeRole":
+		s3a.assumeRole(w, r, values)
+	case "AssumeRoleWithWebIdentity":
+		s3a.assumeRoleWithWebIdentity(w, r, values)
+	default:
+		writeStsErrorResponse(w, r, s3err.ErrNotImplemented)
+	}
+}
+
+func (s3a *S3ApiServer) assumeRole(w http.ResponseWriter, r *http.Request, values url.Values) {
+	identity, errCode := s3a.iam.authUser(r)
+	if errCode != s3err.ErrNone {
+		writeStsErrorResponse(w, r, errCode)
+		return
+	}
+	role, sessionName, duration, errCode := s3a.checkAssumeRoleRequest(values)
+	if errCode != s3err.ErrNone {
+		writeStsErrorResponse(w, r, errCode)
+		return
+	}
+	if !role.isTrustedIdentity(identity) {
+		glog.V(2).Infof("identity %v is not trusted by role %s", identity, role.Name)
+		writeStsErrorResponse(w, r, s3err.ErrAccessDenied)
+		return
+	}
+
+	credentials, err := s3a.iam.issueSessionCredentials(role, sessionName, values.Get("Policy"), duration, time.Now())
+	if err != nil {
+		glog.Errorf("assume role %s: %v", role.Name, err)
+		writeStsErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	glog.V(1).Infof("identity %s assumed role %s as %s until %v", identity.Name, role.Name, sessionName, credentials.Expiration)
+
+	var response AssumeRoleResponse
+	response.AssumeRoleResult.Credentials = newStsCredentials(credentials)
+	response.AssumeRoleResult.AssumedRoleUser = newStsAssumedRoleUser(role, credentials, sessionName)
+	response.ResponseMetadata.RequestId = newStsRequestId()
+	s3err.WriteXMLResponse(w, r, http.StatusOK, response)
+}
+
+func (s3a *S3ApiServer) assumeRoleWithWebIdentity(w http.ResponseWriter, r *http.Request, values url.Values) {
+	role, sessionName, duration, errCode := s3a.checkAssumeRoleRequest(values)
+	if errCode != s3err.ErrNone {
+		writeStsErrorResponse(w, r, errCode)
+		return
+	}
+	claims, provider, errCode := s3a.iam.verifyWebIdentityToken(values.Get("WebIdentityToken"))
+	if errCode != s3err.ErrNone {
+		writeStsErrorResponse(w, r, errCode)
+		return
+	}
+	if !role.isTrustedWebIdentity(provider.Issuer, claims.Subject) {
+		glog.V(2).Infof("subject %s of %s is not trusted by role %s", claims.Subject, provider.Issuer, role.Name)
+		writeStsErrorResponse(w, r, s3err.ErrAccessDenied)
+		return
+	}
+	// the session can not outlive the web identity token
+	if expiresIn := time.Until(claims.ExpiresAt.Time); values.Get("DurationSeconds") == "" && expiresIn < duration && expiresIn >= stsMinSessionDuration {
+		duration = expiresIn
+	}
+
+	credentials, err := s3a.iam.issueSessionCredentials(role, sessionName, values.Get("Policy"), duration, time.Now())
+	if err != nil {
+		glog.Errorf("assume role %s with web identity: %v", role.Name, err)
+		writeStsErrorResponse(w, r, s3err.ErrInternalError)
+		return
+	}
+	glog.V(1).Infof("subject %s of %s assumed role %s as %s until %v", claims.Subject, provider.Issuer, role.Name, sessionName, credentials.Expiration)
+
+	var response AssumeRoleWithWebIdentityResponse
+	result := &response.AssumeRoleWithWebIdentityResult
+	result.SubjectFromWebIdentityToken = claims.Subject
+	if len(claims.Audience) > 0 {
+		result.Audience = claims.Audience[0]
+	}
+	result.Provider = provider.Issuer
+	result.Credentials = newStsCredentials(credentials)
+	result.AssumedRoleUser = newStsAssumedRoleUser(role, credentials, sessionName)
+	response.ResponseMetadata.RequestId = newStsRequestId()
+	s3err.WriteXMLResponse(w, r, http.StatusOK, response)
+}
+
+// checkAssumeRoleRequest validates the parameters shared by the AssumeRole actions
+func (s3a *S3ApiServer) checkAssumeRoleRequest(values url.Values) (role *Role, sessionName string, duration time.Duration, errCode s3err.ErrorCode) {
+	if len(s3a.iam.getStsKey()) == 0 {
+		glog.V(1).Infof("reject STS request: %v", errStsNotConfigured)
+		return nil, "", 0, s3err.ErrNotImplemented
+	}
+	sessionName = values.Get("RoleSessionName")
+	if !isValidRoleSessionName(sessionName) {
+		return nil, "", 0, s3err.ErrStsValidation
+	}
+	role, found := s3a.iam.lookupRole(roleNameFromArn(values.Get("RoleArn")))
+	if !found {
+		return nil, "", 0, s3err.ErrAccessDenied
+	}
+	if duration, errCode = role.sessionDuration(values.Get("DurationSeconds")); errCode != s3err.ErrNone {
+		return nil, "", 0, errCode
+	}
+	if policy := values.Get("Policy"); policy != "" {
+		if _, err := parseSessionPolicy([]byte(policy)); err != nil {
+			glog.V(2).Infof("invalid session policy: %v", err)
+			return nil, "", 0, s3err.ErrMalformedPolicyDocument
+		}
+	}
+	return role, sessionName, duration, s3err.ErrNone
+}
+
+func newStsCredentials(credentials *stsCredentials) StsCredentials {
+	return StsCredentials{
+		AccessKeyId:     credentials.AccessKeyId,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SessionToken,
+		Expiration:      credentials.Expiration.UTC().Format(time.RFC3339),
+	}
+}
+
+func newStsAssumedRoleUser(role *Role, credentials *stsCredentials, sessionName string) StsAssumedRoleUser {
+	return StsAssumedRoleUser{
+		Arn:           role.assumedRoleArn(sessionName),
+		AssumedRoleId: credentials.AccessKeyId + ":" + sessionName,
+	}
+}
+
+func newStsRequestId() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+func writeStsErrorResponse(w http.ResponseWriter, r *http.Request, errorCode s3err.ErrorCode) {
+	apiError := s3err.GetAPIError(errorCode)
+	var response StsErrorResponse
+	response.Error.Type = "Sender"
+	if apiError.HTTPStatusCode >= http.StatusInternalServerError {
+		response.Error.Type = "Receiver"
+	}
+	response.Error.Code = apiError.Code
+	response.Error.Message = apiError.Description
+	response.RequestId = newStsRequestId()
+	s3err.WriteXMLResponse(w, r, apiError.HTTPStatusCode, response)
+	s3err.PostLog(r, apiError.HTTPStatusCode, errorCode)
+}
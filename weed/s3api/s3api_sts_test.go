@@ -0,0 +1,237 @@
+package s3api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/seaweedfs/seaweedfs/weed/pb/iam_pb"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
+	"github.com/seaweedfs/seaweedfs/weed/s3api/s3err"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStsTestIAM(t *testing.T, config *iam_pb.S3ApiConfiguration) *IdentityAccessManagement {
+	iam := &IdentityAccessManagement{
+		hashes:       make(map[string]*sync.Pool),
+		hashCounters: make(map[string]*int32),
+		jwks:         newJwksCache(),
+		stsKey:       []byte("sts-test-key"),
+	}
+	assert.NoError(t, iam.loadS3ApiConfiguration(config))
+	return iam
+}
+
+func TestLoadRoles(t *testing.T) {
+	iam := newStsTestIAM(t, &iam_pb.S3ApiConfiguration{
+		Identities: []*iam_pb.Identity{{Name: "someone"}},
+		Roles:      []*iam_pb.Role{{Name: "reader", Actions: []string{"Read"}, MaxSessionDurationSeconds: 7200}},
+	})
+	role, found := iam.lookupRole("reader")
+	assert.True(t, found)
+	assert.Equal(t, 2*time.Hour, role.MaxSessionDuration)
+	assert.Equal(t, &AccountAdmin, role.Account)
+
+	assert.Error(t, iam.loadS3ApiConfiguration(&iam_pb.S3ApiConfiguration{
+		Identities: []*iam_pb.Identity{{Name: "someone"}},
+		Roles:      []*iam_pb.Role{{Name: "someone"}},
+	}), "a role can not be named as an identity")
+	assert.Error(t, iam.loadS3ApiConfiguration(&iam_pb.S3ApiConfiguration{
+		Roles: []*iam_pb.Role{{Name: "reader", MaxSessionDurationSeconds: 60}},
+	}))
+
+	iam = &IdentityAccessManagement{}
+	assert.NoError(t, iam.loadS3ApiConfiguration(&iam_pb.S3ApiConfiguration{Roles: []*iam_pb.Role{{Name: "reader"}}}))
+	assert.True(t, iam.isEnabled(), "roles enable the authentication")
+}
+
+func TestRoleTrust(t *testing.T) {
+	role := &Role{
+		Name:              "reader",
+		TrustedIdentities: []string{"app-*"},
+		TrustedIssuers:    []string{"https://issuer.example.com/"},
+		TrustedSubjects:   []string{"user:*"},
+	}
+	assert.True(t, role.isTrustedIdentity(&Identity{Name: "app-1", Account: &AccountAdmin}))
+	assert.False(t, role.isTrustedIdentity(&Identity{Name: "other", Account: &AccountAdmin}))
+	assert.True(t, role.isTrustedIdentity(&Identity{Name: "other", Account: &AccountAdmin, Actions: []Action{"Admin"}}))
+	assert.False(t, role.isTrustedIdentity(&Identity{Name: "app-1", Account: &AccountAdmin, RoleSessionName: "s"}), "no role chaining")
+	assert.False(t, role.isTrustedIdentity(nil))
+
+	assert.True(t, role.isTrustedWebIdentity("https://issuer.example.com", "user:1"))
+	assert.False(t, role.isTrustedWebIdentity("https://issuer.example.com", "service:1"))
+	assert.False(t, role.isTrustedWebIdentity("https://other.example.com", "user:1"))
+
+	assert.Equal(t, "reader", roleNameFromArn("arn:aws:iam::000000000000:role/path/reader"))
+	assert.Equal(t, "reader", roleNameFromArn("reader"))
+}
+
+func TestSessionDuration(t *testing.T) {
+	role := &Role{MaxSessionDuration: 2 * time.Hour}
+	duration, errCode := role.sessionDuration("")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, time.Hour, duration)
+	duration, errCode = role.sessionDuration("7200")
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, 2*time.Hour, duration)
+	_, errCode = role.sessionDuration("7201")
+	assert.Equal(t, s3err.ErrStsValidation, errCode)
+	_, errCode = role.sessionDuration("60")
+	assert.Equal(t, s3err.ErrStsValidation, errCode)
+	_, errCode = role.sessionDuration("abc")
+	assert.Equal(t, s3err.ErrStsValidation, errCode)
+}
+
+func TestSessionCredentials(t *testing.T) {
+	iam := newStsTestIAM(t, &iam_pb.S3ApiConfiguration{
+		Roles: []*iam_pb.Role{{Name: "reader", Actions: []string{"Read:bucket"}}},
+	})
+	role, _ := iam.lookupRole("reader")
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/public/*"}]}`
+	credentials, err := iam.issueSessionCredentials(role, "session", policy, time.Hour, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(credentials.AccessKeyId, stsAccessKeyPrefix))
+	assert.Len(t, credentials.AccessKeyId, stsAccessKeyLength)
+
+	identity, cred, errCode := iam.lookupCredential(credentials.AccessKeyId, credentials.SessionToken)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, credentials.SecretAccessKey, cred.SecretKey)
+	assert.Equal(t, "reader", identity.Name)
+	assert.Equal(t, "session", identity.RoleSessionName)
+	assert.True(t, identity.canDo(s3_constants.ACTION_READ, "bucket", "/private/a"))
+	assert.Contains(t, policyPrincipals(identity), "arn:aws:sts::"+AccountAdmin.Id+":assumed-role/reader/session")
+
+	get := func(object string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/bucket"+object, nil)
+		return mux.SetURLVars(r, map[string]string{"bucket": "bucket", "object": object})
+	}
+	assert.True(t, identity.isAllowedBySessionPolicy(get("/public/a"), "bucket", "/public/a"))
+	assert.False(t, identity.isAllowedBySessionPolicy(get("/private/a"), "bucket", "/private/a"), "the session policy narrows down the role")
+
+	_, _, errCode = iam.lookupCredential("ASIAOTHER", credentials.SessionToken)
+	assert.Equal(t, s3err.ErrInvalidAccessKeyID, errCode)
+	_, _, errCode = iam.lookupCredential(credentials.AccessKeyId, credentials.SessionToken+"x")
+	assert.Equal(t, s3err.ErrInvalidToken, errCode)
+	_, _, errCode = iam.lookupCredential(credentials.AccessKeyId, "")
+	assert.Equal(t, s3err.ErrInvalidAccessKeyID, errCode)
+
+	expired, err := iam.issueSessionCredentials(role, "session", "", time.Hour, time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+	_, _, errCode = iam.lookupCredential(expired.AccessKeyId, expired.SessionToken)
+	assert.Equal(t, s3err.ErrExpiredToken, errCode)
+
+	// removing the role revokes its sessions
+	assert.NoError(t, iam.loadS3ApiConfiguration(&iam_pb.S3ApiConfiguration{}))
+	_, _, errCode = iam.lookupCredential(credentials.AccessKeyId, credentials.SessionToken)
+	assert.Equal(t, s3err.ErrInvalidToken, errCode)
+
+	iam.stsKey = nil
+	_, err = iam.issueSessionCredentials(role, "session", "", time.Hour, time.Now())
+	assert.Equal(t, errStsNotConfigured, err)
+}
+
+func TestParseSessionPolicy(t *testing.T) {
+	_, err := parseSessionPolicy([]byte(`{"Version":"2012-10-17","Statement":{"Effect":"Deny","Action":"s3:*","Resource":"*"}}`))
+	assert.NoError(t, err)
+	_, err = parseSessionPolicy([]byte(`{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":"*","Action":"s3:*","Resource":"*"}}`))
+	assert.Error(t, err)
+	_, err = parseSessionPolicy([]byte(`{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"iam:*","Resource":"*"}}`))
+	assert.Error(t, err)
+	_, err = parseSessionPolicy([]byte(`{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:iam::0:role/x"}}`))
+	assert.Error(t, err)
+	_, err = parseSessionPolicy([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestSessionTokenSignatureV4(t *testing.T) {
+	iam := newStsTestIAM(t, &iam_pb.S3ApiConfiguration{
+		Roles: []*iam_pb.Role{{Name: "reader", Actions: []string{"Read"}}},
+	})
+	role, _ := iam.lookupRole("reader")
+	credentials, err := iam.issueSessionCredentials(role, "session", "", time.Hour, time.Now())
+	assert.NoError(t, err)
+
+	req := mustNewRequest("GET", "http://127.0.0.1:9000/bucket/object", 0, nil, t)
+	req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	assert.NoError(t, signRequestV4(req, credentials.AccessKeyId, credentials.SecretAccessKey))
+	identity, errCode := iam.reqSignatureV4Verify(req)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "reader", identity.Name)
+
+	req = mustNewRequest("GET", "http://127.0.0.1:9000/bucket/object", 0, nil, t)
+	assert.NoError(t, signRequestV4(req, credentials.AccessKeyId, credentials.SecretAccessKey))
+	_, errCode = iam.reqSignatureV4Verify(req)
+	assert.Equal(t, s3err.ErrInvalidAccessKeyID, errCode, "temporary credentials need the session token")
+
+	req = mustNewRequest("GET", "http://127.0.0.1:9000/bucket/object?X-Amz-Security-Token="+url.QueryEscape(credentials.SessionToken), 0, nil, t)
+	assert.NoError(t, preSignV4(iam, req, credentials.AccessKeyId, credentials.SecretAccessKey, 600))
+	identity, errCode = iam.reqSignatureV4Verify(req)
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "session", identity.RoleSessionName)
+}
+
+func TestVerifyWebIdentityToken(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	var issuer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": "key1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+			}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	issuer = server.URL
+
+	iam := newStsTestIAM(t, &iam_pb.S3ApiConfiguration{
+		OidcProviders: []*iam_pb.OidcProvider{{Issuer: issuer, ClientIds: []string{"app"}}},
+	})
+	newToken := func(kid string, claims jwt.RegisteredClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(privateKey)
+		assert.NoError(t, err)
+		return signed
+	}
+	expiresAt := jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	claims, provider, errCode := iam.verifyWebIdentityToken(newToken("key1", jwt.RegisteredClaims{Issuer: issuer, Subject: "user:1", Audience: jwt.ClaimStrings{"app"}, ExpiresAt: expiresAt}))
+	assert.Equal(t, s3err.ErrNone, errCode)
+	assert.Equal(t, "user:1", claims.Subject)
+	assert.Equal(t, issuer, provider.Issuer)
+
+	_, _, errCode = iam.verifyWebIdentityToken(newToken("key1", jwt.RegisteredClaims{Issuer: issuer, Subject: "user:1", Audience: jwt.ClaimStrings{"other"}, ExpiresAt: expiresAt}))
+	assert.Equal(t, s3err.ErrInvalidIdentityToken, errCode)
+	_, _, errCode = iam.verifyWebIdentityToken(newToken("key2", jwt.RegisteredClaims{Issuer: issuer, Subject: "user:1", Audience: jwt.ClaimStrings{"app"}, ExpiresAt: expiresAt}))
+	assert.Equal(t, s3err.ErrInvalidIdentityToken, errCode)
+	_, _, errCode = iam.verifyWebIdentityToken(newToken("key1", jwt.RegisteredClaims{Issuer: "https://unknown", Subject: "user:1", Audience: jwt.ClaimStrings{"app"}, ExpiresAt: expiresAt}))
+	assert.Equal(t, s3err.ErrInvalidIdentityToken, errCode)
+	_, _, errCode = iam.verifyWebIdentityToken(newToken("key1", jwt.RegisteredClaims{Issuer: issuer, Subject: "user:1", Audience: jwt.ClaimStrings{"app"}, ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour))}))
+	assert.Equal(t, s3err.ErrExpiredToken, errCode)
+
+	// a provider must name the client ids the tokens are issued to
+	assert.Error(t, iam.loadS3ApiConfiguration(&iam_pb.S3ApiConfiguration{
+		OidcProviders: []*iam_pb.OidcProvider{{Issuer: issuer}},
+	}))
+}
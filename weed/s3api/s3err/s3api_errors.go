@@ -53,11 +53,41 @@ const (
 	ErrNoSuchBucket
 	ErrNoSuchBucketPolicy
 	ErrNoSuchCORSConfiguration
+	ErrCORSForbidden
 	ErrNoSuchLifecycleConfiguration
+	ErrNoSuchWebsiteConfiguration
+	ErrReplicationConfigurationNotFound
+	ErrNoSuchTransformationConfiguration
+	ErrNoSuchTransformation
+	ErrTransformationFailed
+	ErrInvalidTargetBucketForLogging
+	ErrNoSuchInventoryConfiguration
+	ErrNoSuchBatchJob
 	ErrNoSuchKey
 	ErrNoSuchUpload
+	ErrNoSuchVersion
+	ErrObjectLockConfigurationNotFound
+	ErrNoSuchObjectLockConfiguration
+	ErrInvalidBucketState
+	ErrObjectLocked
+	ErrInvalidRetentionPeriod
+	ErrInvalidLifecycleRule
+	ErrInvalidEncryptionAlgorithm
+	ErrInvalidSSECustomerKey
+	ErrSSECustomerKeyMD5Mismatch
+	ErrSSECustomerKeyMissing
+	ErrSSECustomerKeyMismatch
+	ErrSSENotConfigured
+	ErrKMSKeyNotFound
+	ErrInvalidExpressionType
+	ErrInvalidSelectExpression
+	ErrInvalidSelectSerialization
 	ErrInvalidBucketName
 	ErrInvalidDigest
+	ErrBadDigest
+	ErrInvalidChecksum
+	ErrAccessControlListNotSupported
+	ErrInvalidNotificationConfiguration
 	ErrInvalidMaxKeys
 	ErrInvalidMaxUploads
 	ErrInvalidMaxParts
@@ -65,6 +95,7 @@ const (
 	ErrInvalidPartNumberMarker
 	ErrInvalidPart
 	ErrInvalidRange
+	ErrInvalidRedirectLocation
 	ErrInternalError
 	ErrInvalidCopyDest
 	ErrInvalidCopySource
@@ -76,10 +107,12 @@ const (
 	ErrPostPolicyConditionInvalidFormat
 	ErrEntityTooSmall
 	ErrEntityTooLarge
+	ErrMissingContentLength
 	ErrMissingFields
 	ErrMissingCredTag
 	ErrCredMalformed
 	ErrMalformedXML
+	ErrMalformedPolicy
 	ErrMalformedDate
 	ErrMalformedPresignedDate
 	ErrMalformedCredentialDate
@@ -89,12 +122,18 @@ const (
 	ErrInvalidQueryParams
 	ErrInvalidQuerySignatureAlgo
 	ErrExpiredPresignRequest
+	ErrPresignedUrlRevoked
 	ErrMalformedExpires
 	ErrNegativeExpires
 	ErrMaximumExpires
 	ErrSignatureDoesNotMatch
 	ErrContentSHA256Mismatch
 	ErrInvalidAccessKeyID
+	ErrInvalidToken
+	ErrExpiredToken
+	ErrInvalidIdentityToken
+	ErrMalformedPolicyDocument
+	ErrStsValidation
 	ErrRequestNotReadyYet
 	ErrMissingDateHeader
 	ErrInvalidRequest
@@ -106,6 +145,7 @@ const (
 	ErrExistingObjectIsFile
 
 	ErrTooManyRequest
+	ErrSlowDown
 	ErrRequestBytesExceed
 
 	OwnershipControlsNotFoundError
@@ -149,6 +189,26 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "The Content-Md5 you specified is not valid.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrBadDigest: {
+		Code:           "BadDigest",
+		Description:    "The Content-MD5 or checksum value you specified did not match what we received.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksum: {
+		Code:           "InvalidRequest",
+		Description:    "The checksum algorithm or value you specified is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrAccessControlListNotSupported: {
+		Code:           "AccessControlListNotSupported",
+		Description:    "The bucket does not allow ACLs",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidNotificationConfiguration: {
+		Code:           "InvalidArgument",
+		Description:    "Unable to validate the following destination configurations",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrInvalidMaxUploads: {
 		Code:           "InvalidArgument",
 		Description:    "Argument max-uploads must be an integer between 0 and 2147483647",
@@ -189,11 +249,56 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "The CORS configuration does not exist",
 		HTTPStatusCode: http.StatusNotFound,
 	},
+	ErrCORSForbidden: {
+		Code:           "AccessForbidden",
+		Description:    "CORSResponse: This CORS request is not allowed. This is usually because the evaluation of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
 	ErrNoSuchLifecycleConfiguration: {
 		Code:           "NoSuchLifecycleConfiguration",
 		Description:    "The lifecycle configuration does not exist",
 		HTTPStatusCode: http.StatusNotFound,
 	},
+	ErrNoSuchWebsiteConfiguration: {
+		Code:           "NoSuchWebsiteConfiguration",
+		Description:    "The specified bucket does not have a website configuration",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrReplicationConfigurationNotFound: {
+		Code:           "ReplicationConfigurationNotFoundError",
+		Description:    "The replication configuration was not found",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchTransformationConfiguration: {
+		Code:           "NoSuchTransformationConfiguration",
+		Description:    "The specified bucket does not have a transformation configuration",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchTransformation: {
+		Code:           "NoSuchTransformation",
+		Description:    "The requested transformation does not apply to the object",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrTransformationFailed: {
+		Code:           "TransformationFailed",
+		Description:    "The object could not be transformed",
+		HTTPStatusCode: http.StatusInternalServerError,
+	},
+	ErrInvalidTargetBucketForLogging: {
+		Code:           "InvalidTargetBucketForLogging",
+		Description:    "The target bucket for logging does not exist or is not owned by the bucket owner",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchInventoryConfiguration: {
+		Code:           "NoSuchConfiguration",
+		Description:    "The specified inventory configuration does not exist",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchBatchJob: {
+		Code:           "NoSuchBatchJob",
+		Description:    "The specified batch job does not exist",
+		HTTPStatusCode: http.StatusNotFound,
+	},
 	ErrNoSuchKey: {
 		Code:           "NoSuchKey",
 		Description:    "The specified key does not exist.",
@@ -204,6 +309,91 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "The specified multipart upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
 		HTTPStatusCode: http.StatusNotFound,
 	},
+	ErrNoSuchVersion: {
+		Code:           "NoSuchVersion",
+		Description:    "The specified version does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrObjectLockConfigurationNotFound: {
+		Code:           "ObjectLockConfigurationNotFoundError",
+		Description:    "Object Lock configuration does not exist for this bucket",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchObjectLockConfiguration: {
+		Code:           "NoSuchObjectLockConfiguration",
+		Description:    "The specified object does not have a ObjectLock configuration",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidBucketState: {
+		Code:           "InvalidBucketState",
+		Description:    "The request is not valid with the current state of the bucket.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	ErrObjectLocked: {
+		Code:           "AccessDenied",
+		Description:    "Access Denied because object protected by object lock.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrInvalidRetentionPeriod: {
+		Code:           "InvalidArgument",
+		Description:    "The retain until date must be in the future and the default retention period must be positive.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidLifecycleRule: {
+		Code:           "InvalidArgument",
+		Description:    "The lifecycle configuration contains an invalid rule.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidEncryptionAlgorithm: {
+		Code:           "InvalidEncryptionAlgorithmError",
+		Description:    "The encryption request you specified is not valid. The valid value is AES256.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidSSECustomerKey: {
+		Code:           "InvalidArgument",
+		Description:    "The secret key was invalid for the specified algorithm.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrSSECustomerKeyMD5Mismatch: {
+		Code:           "InvalidArgument",
+		Description:    "The calculated MD5 hash of the key did not match the hash that was provided.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrSSECustomerKeyMissing: {
+		Code:           "InvalidRequest",
+		Description:    "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrSSECustomerKeyMismatch: {
+		Code:           "AccessDenied",
+		Description:    "The provided encryption key does not match the key used to encrypt the object.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrSSENotConfigured: {
+		Code:           "NotImplemented",
+		Description:    "Server side encryption keys are not configured on this server.",
+		HTTPStatusCode: http.StatusNotImplemented,
+	},
+	ErrKMSKeyNotFound: {
+		Code:           "KMS.NotFoundException",
+		Description:    "The specified KMS key does not exist.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidExpressionType: {
+		Code:           "InvalidExpressionType",
+		Description:    "The ExpressionType is invalid. Only SQL expressions are supported.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidSelectExpression: {
+		Code:           "ParseSelectFailure",
+		Description:    "The SQL expression could not be parsed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidSelectSerialization: {
+		Code:           "InvalidRequestParameter",
+		Description:    "The input or output serialization is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrInternalError: {
 		Code:           "InternalError",
 		Description:    "We encountered an internal error, please try again.",
@@ -236,6 +426,11 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrMalformedPolicy: {
+		Code:           "MalformedPolicy",
+		Description:    "Policy has invalid resource, action, principal or condition.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrAuthHeaderEmpty: {
 		Code:           "InvalidArgument",
 		Description:    "Authorization header is invalid -- one and only one ' ' (space) required.",
@@ -271,6 +466,11 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "Your proposed upload exceeds the maximum allowed object size.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrMissingContentLength: {
+		Code:           "MissingContentLength",
+		Description:    "You must provide the Content-Length HTTP header.",
+		HTTPStatusCode: http.StatusLengthRequired,
+	},
 	ErrMissingFields: {
 		Code:           "MissingFields",
 		Description:    "Missing fields in request.",
@@ -327,6 +527,11 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "Request has expired",
 		HTTPStatusCode: http.StatusForbidden,
 	},
+	ErrPresignedUrlRevoked: {
+		Code:           "AccessDenied",
+		Description:    "The presigned URL has been revoked",
+		HTTPStatusCode: http.StatusForbidden,
+	},
 	ErrMalformedExpires: {
 		Code:           "AuthorizationQueryParametersError",
 		Description:    "X-Amz-Expires should be a number",
@@ -348,6 +553,31 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "The access key ID you provided does not exist in our records.",
 		HTTPStatusCode: http.StatusForbidden,
 	},
+	ErrInvalidToken: {
+		Code:           "InvalidToken",
+		Description:    "The provided token is malformed or otherwise invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrExpiredToken: {
+		Code:           "ExpiredToken",
+		Description:    "The provided token has expired.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidIdentityToken: {
+		Code:           "InvalidIdentityToken",
+		Description:    "The web identity token that was passed could not be validated.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrMalformedPolicyDocument: {
+		Code:           "MalformedPolicyDocument",
+		Description:    "The session policy is not a valid policy document.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrStsValidation: {
+		Code:           "ValidationError",
+		Description:    "The request parameters are not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 
 	ErrRequestNotReadyYet: {
 		Code:           "AccessDenied",
@@ -381,6 +611,11 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "The requested range is not satisfiable",
 		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
 	},
+	ErrInvalidRedirectLocation: {
+		Code:           "InvalidRedirectLocation",
+		Description:    "The website redirect location must have a prefix of 'http://' or 'https://' or '/'",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrAuthNotSetup: {
 		Code:           "InvalidRequest",
 		Description:    "Signed request requires setting up SeaweedFS S3 authentication",
@@ -411,6 +646,11 @@ var errorCodeResponse = map[ErrorCode]APIError{
 		Description:    "Too many simultaneous request count",
 		HTTPStatusCode: http.StatusTooManyRequests,
 	},
+	ErrSlowDown: {
+		Code:           "SlowDown",
+		Description:    "Please reduce your request rate.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	},
 	ErrRequestBytesExceed: {
 		Code:           "ErrRequestBytesExceed",
 		Description:    "Simultaneous request bytes exceed limitations",
@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"google.golang.org/grpc/metadata"
+
 	"github.com/seaweedfs/seaweedfs/weed/cluster"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
@@ -15,6 +18,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
@@ -189,6 +193,12 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 		return &filer_pb.UpdateEntryResponse{}, err
 	}
 
+	if req.RelaxObjectLock {
+		if !fs.isFromS3Gateway(ctx) {
+			return &filer_pb.UpdateEntryResponse{}, fmt.Errorf("update %s: relaxing the object lock is only accepted from the s3 gateway", fullpath)
+		}
+		ctx = filer.WithObjectLockRelaxed(ctx)
+	}
 	if err = fs.filer.UpdateEntry(ctx, entry, newEntry); err == nil {
 		fs.filer.DeleteChunksNotRecursive(garbage)
 
@@ -201,6 +211,25 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 	return &filer_pb.UpdateEntryResponse{}, err
 }
 
+// isFromS3Gateway checks the jwt the S3 gateway signs with the filer write key, same as for the filer http api
+func (fs *FilerServer) isFromS3Gateway(ctx context.Context) bool {
+	if len(fs.filerGuard.SigningKey) == 0 {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, bearer := range md.Get("authorization") {
+		if len(bearer) <= 7 || !strings.EqualFold(bearer[0:6], "bearer") {
+			continue
+		}
+		token, err := security.DecodeJwt(fs.filerGuard.SigningKey, security.EncodedJwt(bearer[7:]), &security.SeaweedFilerClaims{})
+		if err == nil && token.Valid {
+			return true
+		}
+		glog.V(1).Infof("invalid jwt to relax the object lock: %v", err)
+	}
+	return false
+}
+
 func (fs *FilerServer) cleanupChunks(fullpath string, existingEntry *filer.Entry, newEntry *filer_pb.Entry) (chunks, garbage []*filer_pb.FileChunk, err error) {
 
 	// remove old chunks if not included in the new ones
@@ -48,6 +48,7 @@ import (
 	_ "github.com/seaweedfs/seaweedfs/weed/notification/google_pub_sub"
 	_ "github.com/seaweedfs/seaweedfs/weed/notification/kafka"
 	_ "github.com/seaweedfs/seaweedfs/weed/notification/log"
+	_ "github.com/seaweedfs/seaweedfs/weed/notification/webhook"
 	"github.com/seaweedfs/seaweedfs/weed/security"
 )
 
@@ -101,6 +102,10 @@ type FilerServer struct {
 	// track known metadata listeners
 	knownListenersLock sync.Mutex
 	knownListeners     map[int32]int32
+
+	// serializes the writes with If-Match or If-None-Match conditions to this filer,
+	// which also take the distributed lock of the path against the other filers
+	conditionalWriteLocks *util.LockTable[util.FullPath]
 }
 
 func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption) (fs *FilerServer, err error) {
@@ -137,6 +142,7 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 		grpcDialOption:        security.LoadClientTLS(util.GetViper(), "grpc.filer"),
 		knownListeners:        make(map[int32]int32),
 		inFlightDataLimitCond: sync.NewCond(new(sync.Mutex)),
+		conditionalWriteLocks: util.NewLockTable[util.FullPath](),
 	}
 	fs.listenersCond = sync.NewCond(&fs.listenersLock)
 
@@ -11,6 +11,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
@@ -218,6 +219,10 @@ func (fs *FilerServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		glog.V(1).Infoln("deleting", objectPath, ":", err.Error())
+		if errors.Is(err, filer.ErrObjectLocked) {
+			writeJsonError(w, r, http.StatusForbidden, err)
+			return
+		}
 		writeJsonError(w, r, http.StatusInternalServerError, err)
 		return
 	}
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
@@ -53,6 +54,10 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 			writeJsonError(w, r, util.HttpStatusCancelled, err)
 		} else if strings.HasSuffix(err.Error(), "is a file") || strings.HasSuffix(err.Error(), "already exists") {
 			writeJsonError(w, r, http.StatusConflict, err)
+		} else if errors.Is(err, filer.ErrObjectLocked) {
+			writeJsonError(w, r, http.StatusForbidden, err)
+		} else if errors.Is(err, filer.ErrPreconditionFailed) {
+			writeJsonError(w, r, http.StatusPreconditionFailed, err)
 		} else {
 			writeJsonError(w, r, http.StatusInternalServerError, err)
 		}
@@ -265,6 +270,39 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 			}
 		}
 	}
+	// attributes only known once the body is read, such as checksums computed by the S3 gateway
+	for k, v := range r.Trailer {
+		if len(v) > 0 && len(v[0]) > 0 && strings.HasPrefix(k, needle.PairNamePrefix) {
+			entry.Extended[k] = []byte(v[0])
+		}
+	}
+
+	if ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match"); ifMatch != "" || ifNoneMatch != "" {
+		// the local lock queues the writes to this filer, and the distributed lock the ones to the other filers
+		lock := fs.conditionalWriteLocks.AcquireLock("conditionalWrite", entry.FullPath, util.ExclusiveLock)
+		defer fs.conditionalWriteLocks.ReleaseLock(entry.FullPath, lock)
+		lockClient := cluster.NewLockClient(fs.grpcDialOption, fs.option.Host)
+		clusterLock := lockClient.NewShortLivedLock(string(entry.FullPath), string(fs.option.Host))
+		defer clusterLock.StopShortLivedLock()
+		existingEntry, findErr := fs.filer.FindEntry(ctx, entry.FullPath)
+		if findErr != nil && findErr != filer_pb.ErrNotFound {
+			replyerr = findErr
+			filerResult.Error = findErr.Error()
+			return filerResult, replyerr
+		}
+		if replyerr = filer.CheckWritePreconditions(ifMatch, ifNoneMatch, filer.ETagOfFile(existingEntry)); replyerr != nil {
+			filerResult.Error = replyerr.Error()
+			glog.V(1).Infof("conditional write %s: %v", path, replyerr)
+			return filerResult, replyerr
+		}
+		if versioning := r.Header.Get(s3_constants.SeaweedFSArchiveVersioning); versioning != "" {
+			if replyerr = fs.archiveCurrentVersion(ctx, entry.FullPath, existingEntry, versioning); replyerr != nil {
+				filerResult.Error = replyerr.Error()
+				glog.V(0).Infof("archive current version of %s: %v", path, replyerr)
+				return filerResult, replyerr
+			}
+		}
+	}
 
 	if dbErr := fs.filer.CreateEntry(ctx, entry, false, false, nil, skipCheckParentDirEntry(r), so.MaxFileNameLength); dbErr != nil {
 		replyerr = dbErr
@@ -274,6 +312,43 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 	return filerResult, replyerr
 }
 
+// archiveCurrentVersion moves the current object of a versioned bucket into its versions folder before it is overwritten,
+// the same as the S3 gateway does for unconditional writes. When versioning is suspended, the "null" version is discarded instead.
+func (fs *FilerServer) archiveCurrentVersion(ctx context.Context, path util.FullPath, currentEntry *filer.Entry, versioning string) error {
+	bucketAndKey, found := strings.CutPrefix(string(path), fs.filer.DirBucketsPath+"/")
+	bucket, key, hasKey := strings.Cut(bucketAndKey, "/")
+	if !found || !hasKey {
+		return fmt.Errorf("%s is not an object", path)
+	}
+	versionsDir := util.NewFullPath(fs.filer.DirBucketsPath+"/"+bucket+"/"+s3_constants.VersionsFolder, key)
+	suspended := versioning == s3_constants.VersioningSuspended
+
+	if suspended {
+		if err := fs.filer.DeleteEntryMetaAndData(ctx, versionsDir.Child(s3_constants.NullVersionId), false, false, true, false, nil); err != nil && err != filer_pb.ErrNotFound {
+			return err
+		}
+	}
+	if currentEntry == nil || currentEntry.IsDirectory() {
+		return nil
+	}
+	versionId := s3_constants.NullVersionId
+	if id := currentEntry.Extended[s3_constants.ExtVersionIdKey]; len(id) > 0 {
+		versionId = string(id)
+	}
+	if suspended && versionId == s3_constants.NullVersionId {
+		// overwritten in place, same as a non-versioned bucket
+		return nil
+	}
+	dir, name := path.DirAndName()
+	_, err := fs.AtomicRenameEntry(ctx, &filer_pb.AtomicRenameEntryRequest{
+		OldDirectory: dir,
+		OldName:      name,
+		NewDirectory: string(versionsDir),
+		NewName:      versionId,
+	})
+	return err
+}
+
 func (fs *FilerServer) saveAsChunk(so *operation.StorageOption) filer.SaveDataAsChunkFunctionType {
 
 	return func(reader io.Reader, name string, offset int64, tsNs int64) (*filer_pb.FileChunk, error) {
@@ -377,10 +452,29 @@ func SaveAmzMetaData(r *http.Request, existing map[string][]byte, isReplace bool
 		metadata[s3_constants.AmzStorageClass] = []byte(sc)
 	}
 
+	if location := r.Header.Get(s3_constants.AmzWebsiteRedirectLocation); location != "" && s3_constants.IsValidWebsiteRedirectLocation(location) {
+		metadata[s3_constants.AmzWebsiteRedirectLocation] = []byte(location)
+	}
+
+	// only replicas are marked by the request, the source object status is set by the replication worker
+	if status := r.Header.Get(s3_constants.AmzReplicationStatus); status == "REPLICA" {
+		metadata[s3_constants.AmzReplicationStatus] = []byte(status)
+	}
+
 	if ce := r.Header.Get("Content-Encoding"); ce != "" {
 		metadata["Content-Encoding"] = []byte(ce)
 	}
 
+	for _, lockHeader := range []string{
+		s3_constants.AmzObjectLockMode,
+		s3_constants.AmzObjectLockRetainUntilDate,
+		s3_constants.AmzObjectLockLegalHold,
+	} {
+		if v := r.Header.Get(lockHeader); v != "" {
+			metadata[lockHeader] = []byte(v)
+		}
+	}
+
 	if tags := r.Header.Get(s3_constants.AmzObjectTagging); tags != "" {
 		for _, v := range strings.Split(tags, "&") {
 			tag := strings.Split(v, "=")
@@ -408,7 +502,7 @@ func SaveAmzMetaData(r *http.Request, existing map[string][]byte, isReplace bool
 
 	//acp-grants
 	acpGrants := r.Header.Get(s3_constants.ExtAmzAclKey)
-	if len(acpOwner) > 0 {
+	if len(acpGrants) > 0 {
 		metadata[s3_constants.ExtAmzAclKey] = []byte(acpGrants)
 	}
 
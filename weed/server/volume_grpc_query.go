@@ -1,14 +1,23 @@
 package weed_server
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 	"github.com/seaweedfs/seaweedfs/weed/query/json"
+	"github.com/seaweedfs/seaweedfs/weed/query/sqlselect"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 	"github.com/tidwall/gjson"
 )
 
+// queriedStripeSize is the size of the matched records to collect before sending a stripe
+const queriedStripeSize = 1024 * 1024
+
 func (vs *VolumeServer) Query(req *volume_server_pb.QueryRequest, stream volume_server_pb.VolumeServer_QueryServer) error {
 
 	for _, fid := range req.FromFileIds {
@@ -34,8 +43,16 @@ func (vs *VolumeServer) Query(req *volume_server_pb.QueryRequest, stream volume_
 			return err
 		}
 
-		if req.InputSerialization.CsvInput != nil {
+		if req.Expression != "" {
+			if err = queryRecords(req, n, stream); err != nil {
+				glog.V(0).Infof("volume query fid %s: %v", fid, err)
+				return err
+			}
+			continue
+		}
 
+		if req.InputSerialization.CsvInput != nil {
+			return fmt.Errorf("csv query needs an sql expression")
 		}
 
 		if req.InputSerialization.JsonInput != nil {
@@ -67,3 +84,101 @@ func (vs *VolumeServer) Query(req *volume_server_pb.QueryRequest, stream volume_
 
 	return nil
 }
+
+// queryRecords sends the records matching the WHERE clause of the expression.
+// The bytes before the first and after the last record delimiter are sent as head and tail,
+// to be joined with the neighbouring chunks of the same file.
+func queryRecords(req *volume_server_pb.QueryRequest, n *needle.Needle, stream volume_server_pb.VolumeServer_QueryServer) error {
+	stmt, err := sqlselect.Parse(req.Expression)
+	if err != nil {
+		return err
+	}
+
+	data := n.Data
+	if n.IsCompressed() {
+		if data, err = util.DecompressData(data); err != nil {
+			return err
+		}
+	}
+
+	var parser sqlselect.RecordParser
+	var csvParser *sqlselect.CSVParser
+	delimiter := []byte("\n")
+	switch input := req.InputSerialization; {
+	case input.CsvInput != nil:
+		csvInput := sqlselect.CSVInput{
+			FileHeaderInfo:       input.CsvInput.FileHeaderInfo,
+			RecordDelimiter:      input.CsvInput.RecordDelimiter,
+			FieldDelimiter:       input.CsvInput.FieldDelimiter,
+			QuoteCharacter:       input.CsvInput.QuoteCharacter,
+			QuoteEscapeCharacter: input.CsvInput.QuoteEscapeCharacter,
+			Comments:             input.CsvInput.Comments,
+		}
+		if input.CsvInput.AllowQuotedRecordDelimiter {
+			return fmt.Errorf("quoted record delimiters are not supported")
+		}
+		csvParser = sqlselect.NewCSVParser(csvInput)
+		if len(req.ColumnNames) > 0 {
+			csvParser.SetColumnNames(req.ColumnNames)
+		}
+		parser, delimiter = csvParser, csvInput.Delimiter()
+	case input.JsonInput != nil:
+		if !strings.EqualFold(input.JsonInput.Type, "LINES") {
+			return fmt.Errorf("only json lines are supported")
+		}
+		parser = sqlselect.JSONParser{}
+	default:
+		return fmt.Errorf("unsupported input serialization")
+	}
+
+	stripe := &volume_server_pb.QueriedStripe{}
+	if req.SkipFirstRecord {
+		i := bytes.Index(data, delimiter)
+		if i < 0 {
+			stripe.Tail = data
+			return stream.Send(stripe)
+		}
+		stripe.Head, data = data[:i+len(delimiter)], data[i+len(delimiter):]
+		if csvParser != nil && len(req.ColumnNames) == 0 {
+			csvParser.SetHeader(stripe.Head[:i])
+		}
+	}
+
+	var tail []byte
+	if last := bytes.LastIndex(data, delimiter); last < 0 {
+		tail, data = data, nil
+	} else {
+		tail, data = data[last+len(delimiter):], data[:last+len(delimiter)]
+	}
+
+	for len(data) > 0 {
+		i := bytes.Index(data, delimiter)
+		raw := data[:i+len(delimiter)]
+		data = data[i+len(delimiter):]
+
+		record, err := parser.Parse(raw[:i])
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			continue
+		}
+		matched, err := stmt.Match(record)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		stripe.Records = append(stripe.Records, raw...)
+		if len(stripe.Records) >= queriedStripeSize {
+			if err = stream.Send(stripe); err != nil {
+				return err
+			}
+			stripe = &volume_server_pb.QueriedStripe{}
+		}
+	}
+
+	stripe.Tail = tail
+	return stream.Send(stripe)
+}
@@ -2,11 +2,13 @@ package weed_server
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 // VolumeTierMoveDatFromRemote copy dat file from a remote tier to local volume server
@@ -46,7 +48,17 @@ func (vs *VolumeServer) VolumeTierMoveDatFromRemote(req *volume_server_pb.Volume
 	}
 
 	startTime := time.Now()
+	// the progress is reported from concurrent part transfers
+	throttler := util.NewWriteThrottler(req.IoBytePerSecond)
+	var throttleLock sync.Mutex
+	var lastProgressed int64
 	fn := func(progressed int64, percentage float32) error {
+		throttleLock.Lock()
+		if progressed > lastProgressed {
+			throttler.MaybeSlowdown(progressed - lastProgressed)
+			lastProgressed = progressed
+		}
+		throttleLock.Unlock()
 		now := time.Now()
 		if now.Sub(startTime) < time.Second {
 			return nil
@@ -73,8 +85,9 @@ func (vs *VolumeServer) VolumeTierMoveDatFromRemote(req *volume_server_pb.Volume
 	}
 
 	// forget remote file
-	v.GetVolumeInfo().Files = v.GetVolumeInfo().Files[1:]
-	if err := v.SaveVolumeInfo(); err != nil {
+	if err := v.UpdateVolumeInfo(func(volumeInfo *volume_server_pb.VolumeInfo) {
+		volumeInfo.Files = volumeInfo.Files[1:]
+	}); err != nil {
 		return fmt.Errorf("volume %d failed to save remote file info: %v", v.Id, err)
 	}
 
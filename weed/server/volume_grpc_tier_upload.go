@@ -3,11 +3,13 @@ package weed_server
 import (
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 // VolumeTierMoveDatToRemote copy dat file to a remote tier
@@ -50,7 +52,17 @@ func (vs *VolumeServer) VolumeTierMoveDatToRemote(req *volume_server_pb.VolumeTi
 	}
 
 	startTime := time.Now()
+	// the progress is reported from concurrent part transfers
+	throttler := util.NewWriteThrottler(req.IoBytePerSecond)
+	var throttleLock sync.Mutex
+	var lastProgressed int64
 	fn := func(progressed int64, percentage float32) error {
+		throttleLock.Lock()
+		if progressed > lastProgressed {
+			throttler.MaybeSlowdown(progressed - lastProgressed)
+			lastProgressed = progressed
+		}
+		throttleLock.Unlock()
 		now := time.Now()
 		if now.Sub(startTime) < time.Second {
 			return nil
@@ -69,17 +81,17 @@ func (vs *VolumeServer) VolumeTierMoveDatToRemote(req *volume_server_pb.VolumeTi
 	}
 
 	// save the remote file to volume tier info
-	v.GetVolumeInfo().Files = append(v.GetVolumeInfo().GetFiles(), &volume_server_pb.RemoteFile{
-		BackendType:  backendType,
-		BackendId:    backendId,
-		Key:          key,
-		Offset:       0,
-		FileSize:     uint64(size),
-		ModifiedTime: uint64(time.Now().Unix()),
-		Extension:    ".dat",
-	})
-
-	if err := v.SaveVolumeInfo(); err != nil {
+	if err := v.UpdateVolumeInfo(func(volumeInfo *volume_server_pb.VolumeInfo) {
+		volumeInfo.Files = append(volumeInfo.GetFiles(), &volume_server_pb.RemoteFile{
+			BackendType:  backendType,
+			BackendId:    backendId,
+			Key:          key,
+			Offset:       0,
+			FileSize:     uint64(size),
+			ModifiedTime: uint64(time.Now().Unix()),
+			Extension:    ".dat",
+		})
+	}); err != nil {
 		return fmt.Errorf("volume %d failed to save remote file info: %v", v.Id, err)
 	}
 
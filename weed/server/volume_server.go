@@ -17,6 +17,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/topology"
 )
 
 type VolumeServer struct {
@@ -48,6 +49,7 @@ type VolumeServer struct {
 	metricsAddress          string
 	metricsIntervalSec      int
 	fileSizeLimitBytes      int64
+	writeConsistency        *topology.WriteConsistencyConfig
 	isHeartbeating          bool
 	stopChan                chan bool
 }
@@ -70,6 +72,9 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	hasSlowRead bool,
 	readBufferSizeMB int,
 	ldbTimeout int64,
+	scrubInterval time.Duration,
+	scrubMBPerSecond int,
+	writeConsistency *topology.WriteConsistencyConfig,
 ) *VolumeServer {
 
 	v := util.GetViper()
@@ -92,6 +97,7 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 		grpcDialOption:                security.LoadClientTLS(util.GetViper(), "grpc.volume"),
 		compactionBytePerSecond:       int64(compactionMBPerSecond) * 1024 * 1024,
 		fileSizeLimitBytes:            int64(fileSizeLimitMB) * 1024 * 1024,
+		writeConsistency:              writeConsistency,
 		isHeartbeating:                true,
 		stopChan:                      make(chan bool),
 		inFlightUploadDataLimitCond:   sync.NewCond(new(sync.Mutex)),
@@ -130,6 +136,8 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	}
 
 	go vs.heartbeat()
+	go vs.store.StartScrubbing(scrubInterval, int64(scrubMBPerSecond)*1024*1024)
+	go vs.store.StartReplicationCatchUp()
 	go stats.LoopPushingMetric("volumeServer", util.JoinHostPort(ip, port), vs.metricsAddress, vs.metricsIntervalSec)
 
 	return vs
@@ -159,14 +159,16 @@ func swap(data []*CandidateEcNode, i, j int) {
 	data[j] = t
 }
 
-func countShards(ecShardInfos []*master_pb.VolumeEcShardInformationMessage) (count int) {
+// countShardSlotUnits sums up the volume slots taken by the shards, in erasure_coding.VolumeSlotUnits
+func countShardSlotUnits(ecShardInfos []*master_pb.VolumeEcShardInformationMessage) (units int64) {
 	for _, ecShardInfo := range ecShardInfos {
-		shardBits := erasure_coding.ShardBits(ecShardInfo.EcIndexBits)
-		count += shardBits.ShardIdCount()
+		scheme := erasure_coding.EcSchemeFromCounts(ecShardInfo.DataShards, ecShardInfo.ParityShards)
+		units += scheme.SlotUnits(erasure_coding.ShardBits(ecShardInfo.EcIndexBits).ShardIdCount())
 	}
 	return
 }
 
+// countFreeShardSlots counts the free slots in shards of the default scheme
 func countFreeShardSlots(dn *master_pb.DataNodeInfo, diskType types.DiskType) (count int) {
 	if dn.DiskInfos == nil {
 		return 0
@@ -175,7 +177,8 @@ func countFreeShardSlots(dn *master_pb.DataNodeInfo, diskType types.DiskType) (c
 	if diskInfo == nil {
 		return 0
 	}
-	return int(diskInfo.MaxVolumeCount-diskInfo.VolumeCount)*erasure_coding.DataShardsCount - countShards(diskInfo.EcShardInfos)
+	usedShardSlots := (countShardSlotUnits(diskInfo.EcShardInfos)*erasure_coding.DataShardsCount + erasure_coding.VolumeSlotUnits - 1) / erasure_coding.VolumeSlotUnits
+	return int(diskInfo.MaxVolumeCount-diskInfo.VolumeCount)*erasure_coding.DataShardsCount - int(usedShardSlots)
 }
 
 type RackId string
@@ -299,6 +302,20 @@ func findEcVolumeShards(ecNode *EcNode, vid needle.VolumeId) erasure_coding.Shar
 	return 0
 }
 
+// findEcVolumeScheme returns the erasure coding scheme reported with the ec shards of the volume
+func findEcVolumeScheme(ecNodes []*EcNode, vid needle.VolumeId) erasure_coding.EcScheme {
+	for _, ecNode := range ecNodes {
+		for _, diskInfo := range ecNode.info.DiskInfos {
+			for _, shardInfo := range diskInfo.EcShardInfos {
+				if needle.VolumeId(shardInfo.Id) == vid {
+					return erasure_coding.EcSchemeFromCounts(shardInfo.DataShards, shardInfo.ParityShards)
+				}
+			}
+		}
+	}
+	return erasure_coding.DefaultEcScheme
+}
+
 func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string, shardIds []uint32) *EcNode {
 
 	foundVolume := false
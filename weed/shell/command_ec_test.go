@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 )
 
@@ -15,7 +16,7 @@ func TestCommandEcDistribution(t *testing.T) {
 		newEcNode("dc1", "rack2", "dn2", 100),
 	}
 
-	allocated := balancedEcDistribution(allEcNodes)
+	allocated := balancedEcDistribution(allEcNodes, erasure_coding.DefaultEcScheme)
 
 	fmt.Printf("allocated: %+v", allocated)
 }
@@ -123,6 +124,26 @@ func TestCommandEcBalanceVolumeEvenButRackUneven(t *testing.T) {
 	balanceEcRacks(nil, racks, false)
 }
 
+func TestCountFreeShardSlots(t *testing.T) {
+	dn := &master_pb.DataNodeInfo{
+		DiskInfos: map[string]*master_pb.DiskInfo{
+			"": {
+				MaxVolumeCount: 10,
+				VolumeCount:    2,
+				EcShardInfos: []*master_pb.VolumeEcShardInformationMessage{
+					// the default shards take 1/10 of a volume slot
+					{Id: 1, EcIndexBits: uint32(erasure_coding.ShardBits(0).AddShardId(0).AddShardId(1))},
+					// the shards of a 5+1 volume take 1/5 of a volume slot
+					{Id: 2, EcIndexBits: uint32(erasure_coding.ShardBits(0).AddShardId(0)), DataShards: 5, ParityShards: 1},
+				},
+			},
+		},
+	}
+	if count := countFreeShardSlots(dn, ""); count != 80-2-2 {
+		t.Errorf("unexpected free shard slots %d", count)
+	}
+}
+
 func newEcNode(dc string, rack string, dataNodeId string, freeEcSlot int) *EcNode {
 	return &EcNode{
 		info: &master_pb.DataNodeInfo{
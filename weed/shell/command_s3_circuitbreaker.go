@@ -48,6 +48,18 @@ func (c *commandS3CircuitBreaker) Help() string {
 
 	# clear all circuit breaker config
 	s3.circuitBreaker -delete -apply
+
+	# limit bucket x to 100 requests per second, and 50MB per second of uploads and downloads
+	s3.circuitBreaker -buckets x -rps 100 -uploadMBps 50 -downloadMBps 50 -apply
+
+	# limit the requests signed with access key k to 10 per second
+	s3.circuitBreaker -accessKeys k -rps 10 -apply
+
+	# remove the request rate limit of access key k
+	s3.circuitBreaker -accessKeys k -rps 0 -apply
+
+	Rate limits are token buckets, applied on each S3 gateway whether or not the global circuit breaker is enabled.
+	A request over a request rate limit is rejected with 503 SlowDown, and the bandwidth limits slow the transfers down.
 	`
 }
 
@@ -58,11 +70,16 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 	s3CircuitBreakerCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
 	buckets := s3CircuitBreakerCommand.String("buckets", "", "the bucket name(s) to configure, eg: -buckets x,y,z")
 	global := s3CircuitBreakerCommand.Bool("global", false, "configure global circuit breaker")
+	accessKeys := s3CircuitBreakerCommand.String("accessKeys", "", "the access key(s) to configure rate limits for, eg: -accessKeys x,y,z")
 
 	actions := s3CircuitBreakerCommand.String("actions", "", "comma separated actions names: Read,Write,List,Tagging,Admin")
 	limitType := s3CircuitBreakerCommand.String("type", "", "'Count' or 'MB'; Count represents the number of simultaneous requests, and MB represents the content size of all simultaneous requests")
 	values := s3CircuitBreakerCommand.String("values", "", "comma separated values")
 
+	requestsPerSecond := s3CircuitBreakerCommand.Int64("rps", -1, "requests per second limit, 0 to remove it")
+	uploadMBps := s3CircuitBreakerCommand.Int64("uploadMBps", -1, "upload bandwidth limit in MB per second, 0 to remove it")
+	downloadMBps := s3CircuitBreakerCommand.Int64("downloadMBps", -1, "download bandwidth limit in MB per second, 0 to remove it")
+
 	disabled := s3CircuitBreakerCommand.Bool("disable", false, "disable global or buckets circuit breaker")
 	deleted := s3CircuitBreakerCommand.Bool("delete", false, "delete circuit breaker config")
 
@@ -80,7 +97,8 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 	}
 
 	cbCfg := &s3_pb.S3CircuitBreakerConfig{
-		Buckets: make(map[string]*s3_pb.S3CircuitBreakerOptions),
+		Buckets:    make(map[string]*s3_pb.S3CircuitBreakerOptions),
+		Identities: make(map[string]*s3_pb.S3CircuitBreakerOptions),
 	}
 	if buf.Len() > 0 {
 		if err = filer.ParseS3ConfigurationFromBytes(buf.Bytes(), cbCfg); err != nil {
@@ -94,7 +112,12 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 			return err
 		}
 
-		if len(cmdBuckets) <= 0 && !*global {
+		var cmdAccessKeys []string
+		if len(*accessKeys) > 0 {
+			cmdAccessKeys = strings.Split(*accessKeys, ",")
+		}
+
+		if len(cmdBuckets) <= 0 && len(cmdAccessKeys) <= 0 && !*global {
 			if len(cmdActions) > 0 {
 				deleteGlobalActions(cbCfg, cmdActions, limitType)
 				if cbCfg.Buckets != nil {
@@ -107,8 +130,12 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 			} else {
 				cbCfg.Global = nil
 				cbCfg.Buckets = nil
+				cbCfg.Identities = nil
 			}
 		} else {
+			for _, accessKey := range cmdAccessKeys {
+				delete(cbCfg.Identities, accessKey)
+			}
 			if len(cmdBuckets) > 0 {
 				deleteBucketsActions(cmdBuckets, cbCfg, cmdActions, limitType)
 			}
@@ -122,8 +149,12 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 			return err
 		}
 
-		if len(cmdActions) > 0 && len(*buckets) <= 0 && !*global {
-			return fmt.Errorf("one of -global and -buckets must be specified")
+		hasRateLimits := *requestsPerSecond >= 0 || *uploadMBps >= 0 || *downloadMBps >= 0
+		if (len(cmdActions) > 0 || hasRateLimits) && len(*buckets) <= 0 && len(*accessKeys) <= 0 && !*global {
+			return fmt.Errorf("one of -global, -buckets and -accessKeys must be specified")
+		}
+		if len(cmdActions) > 0 && len(*accessKeys) > 0 {
+			return fmt.Errorf("only rate limits can be configured for -accessKeys")
 		}
 
 		if len(*buckets) > 0 {
@@ -142,13 +173,30 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 						return err
 					}
 				}
+				updateRateLimit(cbOptions, *requestsPerSecond, *uploadMBps, *downloadMBps)
 
-				if len(cbOptions.Actions) <= 0 && !cbOptions.Enabled {
+				if len(cbOptions.Actions) <= 0 && cbOptions.RateLimit == nil && !cbOptions.Enabled {
 					delete(cbCfg.Buckets, bucket)
 				}
 			}
 		}
 
+		if len(*accessKeys) > 0 {
+			for _, accessKey := range strings.Split(*accessKeys, ",") {
+				cbOptions, exists := cbCfg.Identities[accessKey]
+				if !exists {
+					cbOptions = &s3_pb.S3CircuitBreakerOptions{}
+					cbCfg.Identities[accessKey] = cbOptions
+				}
+				cbOptions.Enabled = !*disabled
+				updateRateLimit(cbOptions, *requestsPerSecond, *uploadMBps, *downloadMBps)
+
+				if cbOptions.RateLimit == nil && !cbOptions.Enabled {
+					delete(cbCfg.Identities, accessKey)
+				}
+			}
+		}
+
 		if *global {
 			globalOptions := cbCfg.Global
 			if globalOptions == nil {
@@ -163,8 +211,9 @@ func (c *commandS3CircuitBreaker) Do(args []string, commandEnv *CommandEnv, writ
 					return err
 				}
 			}
+			updateRateLimit(globalOptions, *requestsPerSecond, *uploadMBps, *downloadMBps)
 
-			if len(globalOptions.Actions) <= 0 && !globalOptions.Enabled {
+			if len(globalOptions.Actions) <= 0 && globalOptions.RateLimit == nil && !globalOptions.Enabled {
 				cbCfg.Global = nil
 			}
 		}
@@ -216,6 +265,30 @@ func insertOrUpdateValues(cbOptions *s3_pb.S3CircuitBreakerOptions, cmdActions [
 	return nil
 }
 
+// updateRateLimit sets the given rate limits, negative ones are left as they are and 0 removes a limit
+func updateRateLimit(cbOptions *s3_pb.S3CircuitBreakerOptions, requestsPerSecond, uploadMBps, downloadMBps int64) {
+	if requestsPerSecond < 0 && uploadMBps < 0 && downloadMBps < 0 {
+		return
+	}
+	rateLimit := cbOptions.RateLimit
+	if rateLimit == nil {
+		rateLimit = &s3_pb.S3RateLimit{}
+	}
+	if requestsPerSecond >= 0 {
+		rateLimit.RequestsPerSecond = requestsPerSecond
+	}
+	if uploadMBps >= 0 {
+		rateLimit.UploadBytesPerSecond = uploadMBps * 1024 * 1024
+	}
+	if downloadMBps >= 0 {
+		rateLimit.DownloadBytesPerSecond = downloadMBps * 1024 * 1024
+	}
+	if rateLimit.RequestsPerSecond == 0 && rateLimit.UploadBytesPerSecond == 0 && rateLimit.DownloadBytesPerSecond == 0 {
+		rateLimit = nil
+	}
+	cbOptions.RateLimit = rateLimit
+}
+
 func deleteBucketsActions(cmdBuckets []string, cbCfg *s3_pb.S3CircuitBreakerConfig, cmdActions []string, limitType *string) {
 	if cbCfg.Buckets == nil {
 		return
@@ -234,7 +307,7 @@ func deleteBucketsActions(cmdBuckets []string, cbCfg *s3_pb.S3CircuitBreakerConf
 					}
 				}
 
-				if len(cbOption.Actions) == 0 && !cbOption.Enabled {
+				if len(cbOption.Actions) == 0 && cbOption.RateLimit == nil && !cbOption.Enabled {
 					delete(cbCfg.Buckets, bucket)
 				}
 			}
@@ -261,7 +334,7 @@ func deleteGlobalActions(cbCfg *s3_pb.S3CircuitBreakerConfig, cmdActions []strin
 		}
 	}
 
-	if len(globalOptions.Actions) == 0 && !globalOptions.Enabled {
+	if len(globalOptions.Actions) == 0 && globalOptions.RateLimit == nil && !globalOptions.Enabled {
 		cbCfg.Global = nil
 	}
 }
@@ -243,6 +243,128 @@ var (
 			}`,
 		},
 
+		//limit the request rate and bandwidth of bucket y
+		{
+			args: strings.Split("-buckets y -rps 100 -uploadMBps 10 -downloadMBps 20", " "),
+			result: `{
+			  "global": {
+				"enabled": true,
+				"actions": {
+				  "Read:Count": "500",
+				  "Write:Count": "200"
+				}
+			  },
+			  "buckets": {
+				"x": {
+				  "enabled": true
+				},
+				"y": {
+				  "enabled": true,
+				  "actions": {
+					"Read:Count": "200",
+					"Write:Count": "100"
+				  },
+				  "rateLimit": {
+					"requestsPerSecond": "100",
+					"uploadBytesPerSecond": "10485760",
+					"downloadBytesPerSecond": "20971520"
+				  }
+				},
+				"z": {
+				  "enabled": true,
+				  "actions": {
+					"Read:Count": "200",
+					"Write:Count": "100"
+				  }
+				}
+			  }
+			}`,
+		},
+
+		//limit the request rate of access key k and bucket y to 10 per second
+		{
+			args: strings.Split("-accessKeys k -rps 10 -buckets y", " "),
+			result: `{
+			  "global": {
+				"enabled": true,
+				"actions": {
+				  "Read:Count": "500",
+				  "Write:Count": "200"
+				}
+			  },
+			  "buckets": {
+				"x": {
+				  "enabled": true
+				},
+				"y": {
+				  "enabled": true,
+				  "actions": {
+					"Read:Count": "200",
+					"Write:Count": "100"
+				  },
+				  "rateLimit": {
+					"requestsPerSecond": "10",
+					"uploadBytesPerSecond": "10485760",
+					"downloadBytesPerSecond": "20971520"
+				  }
+				},
+				"z": {
+				  "enabled": true,
+				  "actions": {
+					"Read:Count": "200",
+					"Write:Count": "100"
+				  }
+				}
+			  },
+			  "identities": {
+				"k": {
+				  "enabled": true,
+				  "rateLimit": {
+					"requestsPerSecond": "10"
+				  }
+				}
+			  }
+			}`,
+		},
+
+		//delete the rate limits of access key k
+		{
+			args: strings.Split("-accessKeys k -delete", " "),
+			result: `{
+			  "global": {
+				"enabled": true,
+				"actions": {
+				  "Read:Count": "500",
+				  "Write:Count": "200"
+				}
+			  },
+			  "buckets": {
+				"x": {
+				  "enabled": true
+				},
+				"y": {
+				  "enabled": true,
+				  "actions": {
+					"Read:Count": "200",
+					"Write:Count": "100"
+				  },
+				  "rateLimit": {
+					"requestsPerSecond": "10",
+					"uploadBytesPerSecond": "10485760",
+					"downloadBytesPerSecond": "20971520"
+				  }
+				},
+				"z": {
+				  "enabled": true,
+				  "actions": {
+					"Read:Count": "200",
+					"Write:Count": "100"
+				  }
+				}
+			  }
+			}`,
+		},
+
 		//clear all circuit breaker config
 		{
 			args: strings.Split("-delete", " "),
@@ -30,6 +30,8 @@ func (c *commandS3CleanUploads) Help() string {
 	Example:
 		s3.clean.uploads -timeAgo 1.5h
 
+	Buckets with an AbortIncompleteMultipartUpload lifecycle rule are cleaned up by the S3 gateway automatically.
+
 `
 }
 
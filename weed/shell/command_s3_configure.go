@@ -7,6 +7,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 
@@ -30,6 +31,12 @@ func (c *commandS3Configure) Help() string {
 
 	# see the current configuration file content
 	s3.configure
+
+	# revoke the presigned urls signed so far by an access key, or by all access keys of the user
+	s3.configure -user <name> [-access_key <key>] -presignedNotBefore now -apply
+
+	Presigned V4 urls are rejected if signed before the given time, and presigned V2 urls are all rejected
+	since they do not carry their signing time. Use "-presignedNotBefore 0" to stop revoking.
 	`
 }
 
@@ -41,11 +48,18 @@ func (c *commandS3Configure) Do(args []string, commandEnv *CommandEnv, writer io
 	buckets := s3ConfigureCommand.String("buckets", "", "bucket name")
 	accessKey := s3ConfigureCommand.String("access_key", "", "specify the access key")
 	secretKey := s3ConfigureCommand.String("secret_key", "", "specify the secret key")
+	presignedNotBefore := s3ConfigureCommand.String("presignedNotBefore", "", "revoke presigned urls signed before this time, \"now\" or RFC3339, \"0\" to clear")
 	isDelete := s3ConfigureCommand.Bool("delete", false, "delete users, actions or access keys")
 	apply := s3ConfigureCommand.Bool("apply", false, "update and apply s3 configuration")
 	if err = s3ConfigureCommand.Parse(args); err != nil {
 		return nil
 	}
+	var notBefore int64
+	if *presignedNotBefore != "" {
+		if notBefore, err = parsePresignedNotBefore(*presignedNotBefore, time.Now()); err != nil {
+			return err
+		}
+	}
 
 	var buf bytes.Buffer
 	if err = commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
@@ -134,7 +148,13 @@ func (c *commandS3Configure) Do(args []string, commandEnv *CommandEnv, writer io
 					}
 				}
 			}
-			if *accessKey != "" && *user != "anonymous" {
+			if *presignedNotBefore != "" {
+				for _, credential := range s3cfg.Identities[idx].Credentials {
+					if *accessKey == "" || credential.AccessKey == *accessKey {
+						credential.PresignedNotBefore = notBefore
+					}
+				}
+			} else if *accessKey != "" && *user != "anonymous" {
 				found := false
 				for _, credential := range s3cfg.Identities[idx].Credentials {
 					if credential.AccessKey == *accessKey {
@@ -187,3 +207,18 @@ func (c *commandS3Configure) Do(args []string, commandEnv *CommandEnv, writer io
 
 	return nil
 }
+
+// parsePresignedNotBefore returns the unix time of "now" or of a RFC3339 time, and 0 for "0"
+func parsePresignedNotBefore(value string, now time.Time) (int64, error) {
+	switch value {
+	case "0":
+		return 0, nil
+	case "now":
+		return now.Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid presignedNotBefore %s: %v", value, err)
+	}
+	return t.Unix(), nil
+}
@@ -0,0 +1,27 @@
+package shell
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePresignedNotBefore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	notBefore, err := parsePresignedNotBefore("now", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Unix(), notBefore)
+
+	notBefore, err = parsePresignedNotBefore("2023-11-14T22:13:20Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000), notBefore)
+
+	notBefore, err = parsePresignedNotBefore("0", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), notBefore)
+
+	_, err = parsePresignedNotBefore("yesterday", now)
+	assert.Error(t, err)
+}
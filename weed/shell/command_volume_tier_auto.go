@@ -0,0 +1,243 @@
+package shell
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeTierAuto{})
+}
+
+type commandVolumeTierAuto struct {
+}
+
+func (c *commandVolumeTierAuto) Name() string {
+	return "volume.tier.auto"
+}
+
+func (c *commandVolumeTierAuto) Help() string {
+	return `move volumes between tiers by their heat
+
+	volume.tier.auto [-collectionPattern=""] -fromDiskType=ssd -toDiskType=hdd [-untouchedFor=720h] [-promoteReadsPerSecond=1] [-ioBytePerSecond=0] [-force]
+	volume.tier.auto [-collectionPattern=""] -fromDiskType=hdd -toBackend=s3.default [-untouchedFor=4320h] [-promoteReadsPerSecond=1] [-ioBytePerSecond=0] [-force]
+
+	The volume servers report the reads and writes per second and the last access time of each volume.
+	This command applies one tiering rule:
+	* the volumes on the fromDiskType without any reads or writes for the untouchedFor period are demoted,
+	  either moved to the toDiskType, or their dat files are uploaded to the toBackend defined in master.toml.
+	* the volumes on the toDiskType or the toBackend with more than promoteReadsPerSecond reads per second
+	  are promoted back to the fromDiskType, or their dat files are downloaded back. 0 disables the promotion.
+
+	The rules are usually added to the maintenance scripts in master.toml, and executed periodically by the master:
+
+	[master.maintenance]
+	scripts = """
+	  lock
+	  volume.tier.auto -collectionPattern=logs -fromDiskType=ssd -toDiskType=hdd -untouchedFor=720h -ioBytePerSecond=50000000 -force
+	  volume.tier.auto -collectionPattern=logs -fromDiskType=hdd -toBackend=s3.default -untouchedFor=4320h -ioBytePerSecond=50000000 -force
+	  volume.fix.replication
+	  unlock
+	"""
+
+	Even if the volume is replicated, only one replica is moved and the rest replicas are dropped.
+	So "volume.fix.replication" and "volume.balance" should be followed.
+
+`
+}
+
+func (c *commandVolumeTierAuto) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	tierCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	collectionPattern := tierCommand.String("collectionPattern", "", "match with wildcard characters '*' and '?'")
+	source := tierCommand.String("fromDiskType", "", "the disk type of the hotter tier")
+	target := tierCommand.String("toDiskType", "", "the disk type of the colder tier")
+	toBackend := tierCommand.String("toBackend", "", "the remote storage backend of the colder tier, e.g. s3.default")
+	untouchedFor := tierCommand.Duration("untouchedFor", 30*24*time.Hour, "demote volumes without reads or writes for this period")
+	promoteReadsPerSecond := tierCommand.Float64("promoteReadsPerSecond", 1, "promote volumes with more reads per second, 0 to disable")
+	ioBytePerSecond := tierCommand.Int64("ioBytePerSecond", 0, "limit the speed of each move")
+	applyChange := tierCommand.Bool("force", false, "actually apply the changes")
+	if err = tierCommand.Parse(args); err != nil {
+		return nil
+	}
+	infoAboutSimulationMode(writer, *applyChange, "-force")
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	rule := volumeTierRule{
+		collectionPattern:     *collectionPattern,
+		fromDiskType:          types.ToDiskType(*source),
+		toDiskType:            types.ToDiskType(*target),
+		toBackend:             *toBackend,
+		untouchedFor:          *untouchedFor,
+		promoteReadsPerSecond: *promoteReadsPerSecond,
+	}
+	if (*target == "") == (*toBackend == "") {
+		return fmt.Errorf("set either -toDiskType or -toBackend")
+	}
+	if rule.toBackend == "" && rule.fromDiskType == rule.toDiskType {
+		return fmt.Errorf("source tier %s is the same as target tier %s", rule.fromDiskType, rule.toDiskType)
+	}
+	if _, err = filepath.Match(rule.collectionPattern, ""); err != nil {
+		return fmt.Errorf("invalid collection pattern %s: %v", rule.collectionPattern, err)
+	}
+
+	// collect topology information
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+
+	demotions, promotions := rule.plan(topologyInfo, time.Now())
+	fmt.Fprintf(writer, "tier demote volumes: %v\n", demotions)
+	fmt.Fprintf(writer, "tier promote volumes: %v\n", promotions)
+	if !*applyChange {
+		return nil
+	}
+
+	_, allLocations := collectVolumeReplicaLocations(topologyInfo)
+	for _, v := range demotions {
+		if rule.toBackend != "" {
+			err = doVolumeTierUpload(commandEnv, writer, v.collection, needle.VolumeId(v.id), rule.toBackend, false, *ioBytePerSecond)
+		} else {
+			err = moveVolumeToDiskType(commandEnv, writer, needle.VolumeId(v.id), rule.toDiskType, allLocations, *ioBytePerSecond)
+		}
+		if err != nil {
+			fmt.Fprintf(writer, "demote volume %d: %v\n", v.id, err)
+		}
+	}
+	for _, v := range promotions {
+		if rule.toBackend != "" {
+			err = doVolumeTierDownload(commandEnv, writer, v.collection, needle.VolumeId(v.id), *ioBytePerSecond)
+		} else {
+			err = moveVolumeToDiskType(commandEnv, writer, needle.VolumeId(v.id), rule.fromDiskType, allLocations, *ioBytePerSecond)
+		}
+		if err != nil {
+			fmt.Fprintf(writer, "promote volume %d: %v\n", v.id, err)
+		}
+	}
+
+	return nil
+}
+
+type volumeTierRule struct {
+	collectionPattern     string
+	fromDiskType          types.DiskType
+	toDiskType            types.DiskType
+	toBackend             string
+	untouchedFor          time.Duration
+	promoteReadsPerSecond float64
+}
+
+// volumeHeat sums up the heat reported by all replicas of a volume
+type volumeHeat struct {
+	id               uint32
+	collection       string
+	diskType         types.DiskType
+	remoteName       string
+	readPerSecond    float64
+	accessedAtSecond int64
+}
+
+func (h *volumeHeat) String() string {
+	return fmt.Sprintf("%d", h.id)
+}
+
+func collectVolumeHeats(topologyInfo *master_pb.TopologyInfo) map[uint32]*volumeHeat {
+	heats := make(map[uint32]*volumeHeat)
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				h, found := heats[v.Id]
+				if !found {
+					h = &volumeHeat{
+						id:         v.Id,
+						collection: v.Collection,
+						diskType:   types.ToDiskType(v.DiskType),
+						remoteName: v.RemoteStorageName,
+					}
+					heats[v.Id] = h
+				}
+				h.readPerSecond += float64(v.ReadPerSecond)
+				// the last access is not known for the volumes not accessed since the last restart
+				for _, t := range []int64{v.AccessedAtSecond, v.ModifiedAtSecond} {
+					if t > h.accessedAtSecond {
+						h.accessedAtSecond = t
+					}
+				}
+			}
+		}
+	})
+	return heats
+}
+
+// plan selects the volumes to demote to the colder tier, and the volumes to promote back to the hotter tier
+func (rule volumeTierRule) plan(topologyInfo *master_pb.TopologyInfo, now time.Time) (demotions, promotions []*volumeHeat) {
+	untouchedSince := now.Add(-rule.untouchedFor).Unix()
+	for _, h := range collectVolumeHeats(topologyInfo) {
+		if rule.collectionPattern != "" {
+			if matched, _ := filepath.Match(rule.collectionPattern, h.collection); !matched {
+				continue
+			}
+		}
+		isHot := rule.promoteReadsPerSecond > 0 && h.readPerSecond >= rule.promoteReadsPerSecond
+		isRemote := h.remoteName != ""
+		switch {
+		case !isRemote && h.diskType == rule.fromDiskType:
+			if h.accessedAtSecond < untouchedSince && !isHot {
+				demotions = append(demotions, h)
+			}
+		case rule.toBackend == "" && !isRemote && h.diskType == rule.toDiskType:
+			if isHot {
+				promotions = append(promotions, h)
+			}
+		case rule.toBackend != "" && h.remoteName == rule.toBackend:
+			if isHot {
+				promotions = append(promotions, h)
+			}
+		}
+	}
+	sort.Slice(demotions, func(i, j int) bool {
+		return demotions[i].accessedAtSecond < demotions[j].accessedAtSecond
+	})
+	sort.Slice(promotions, func(i, j int) bool {
+		return promotions[i].readPerSecond > promotions[j].readPerSecond
+	})
+	return
+}
+
+// moveVolumeToDiskType moves one replica of the volume to the server with the most free slots of the disk type
+func moveVolumeToDiskType(commandEnv *CommandEnv, writer io.Writer, vid needle.VolumeId, toDiskType types.DiskType, allLocations []location, ioBytePerSecond int64) error {
+	locations, found := commandEnv.MasterClient.GetLocationsClone(uint32(vid))
+	if !found {
+		return fmt.Errorf("volume %d not found", vid)
+	}
+
+	candidates := filterLocationsByDiskType(allLocations, toDiskType)
+	keepDataNodesSorted(candidates, toDiskType)
+	fn := capacityByFreeVolumeCount(toDiskType)
+	for _, dst := range candidates {
+		if fn(dst.dataNode) <= 0 || isOneOf(dst.dataNode.Id, locations) {
+			continue
+		}
+		sourceVolumeServer := locations[0].ServerAddress()
+
+		// adjust volume count
+		dst.dataNode.DiskInfos[string(toDiskType)].VolumeCount++
+
+		fmt.Fprintf(writer, "moving volume %d from %s to %s with disk type %s ...\n", vid, sourceVolumeServer, dst.dataNode.Id, toDiskType.ReadableString())
+		noReplicationChange := ""
+		return (&commandVolumeTierMove{}).doMoveOneVolume(commandEnv, writer, vid, toDiskType, locations, sourceVolumeServer, dst, ioBytePerSecond, &noReplicationChange)
+	}
+	return fmt.Errorf("can not find disk type %s for volume %d", toDiskType.ReadableString(), vid)
+}
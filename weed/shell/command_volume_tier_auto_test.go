@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestVolumeTierRulePlan(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) int64 {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).Unix()
+	}
+	volume := func(id uint32, collection, diskType string, modifiedAt, accessedAt int64, readPerSecond float32) *master_pb.VolumeInformationMessage {
+		return &master_pb.VolumeInformationMessage{Id: id, Collection: collection, DiskType: diskType,
+			ModifiedAtSecond: modifiedAt, AccessedAtSecond: accessedAt, ReadPerSecond: readPerSecond}
+	}
+	remote := volume(7, "logs", "", daysAgo(300), daysAgo(300), 3)
+	remote.RemoteStorageName, remote.RemoteStorageKey = "s3.default", "7.dat"
+
+	topologyInfo := &master_pb.TopologyInfo{
+		DataCenterInfos: []*master_pb.DataCenterInfo{{Id: "dc1", RackInfos: []*master_pb.RackInfo{{Id: "rack1",
+			DataNodeInfos: []*master_pb.DataNodeInfo{
+				{Id: "server1", DiskInfos: map[string]*master_pb.DiskInfo{
+					"ssd": {Type: "ssd", VolumeInfos: []*master_pb.VolumeInformationMessage{
+						volume(1, "logs", "ssd", daysAgo(40), daysAgo(40), 0),
+						volume(2, "logs", "ssd", daysAgo(40), daysAgo(1), 0),
+						volume(3, "images", "ssd", daysAgo(40), daysAgo(40), 0),
+						// written long ago, the last access is not known yet
+						volume(4, "logs", "ssd", daysAgo(40), 0, 0),
+					}},
+					"": {Type: "", VolumeInfos: []*master_pb.VolumeInformationMessage{
+						volume(5, "logs", "", daysAgo(200), daysAgo(200), 0.6),
+						volume(6, "logs", "", daysAgo(200), daysAgo(200), 0),
+						remote,
+					}},
+				}},
+				{Id: "server2", DiskInfos: map[string]*master_pb.DiskInfo{
+					"": {Type: "", VolumeInfos: []*master_pb.VolumeInformationMessage{
+						// the reads of the replicas add up
+						volume(5, "logs", "", daysAgo(200), daysAgo(200), 0.6),
+					}},
+				}},
+			}}}}},
+	}
+
+	ssdToHdd := volumeTierRule{
+		collectionPattern:     "logs",
+		fromDiskType:          types.SsdType,
+		toDiskType:            types.HardDriveType,
+		untouchedFor:          30 * 24 * time.Hour,
+		promoteReadsPerSecond: 1,
+	}
+	demotions, promotions := ssdToHdd.plan(topologyInfo, now)
+	assert.ElementsMatch(t, []uint32{1, 4}, volumeHeatIds(demotions))
+	assert.ElementsMatch(t, []uint32{5}, volumeHeatIds(promotions))
+
+	hddToS3 := volumeTierRule{
+		collectionPattern:     "log*",
+		fromDiskType:          types.HardDriveType,
+		toBackend:             "s3.default",
+		untouchedFor:          180 * 24 * time.Hour,
+		promoteReadsPerSecond: 1,
+	}
+	demotions, promotions = hddToS3.plan(topologyInfo, now)
+	assert.ElementsMatch(t, []uint32{6}, volumeHeatIds(demotions))
+	assert.ElementsMatch(t, []uint32{7}, volumeHeatIds(promotions))
+
+	hddToS3.promoteReadsPerSecond = 0
+	_, promotions = hddToS3.plan(topologyInfo, now)
+	assert.Empty(t, promotions)
+}
+
+func volumeHeatIds(heats []*volumeHeat) (ids []uint32) {
+	for _, h := range heats {
+		ids = append(ids, h.id)
+	}
+	return
+}
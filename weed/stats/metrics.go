@@ -226,6 +226,38 @@ var (
 			Help:      "Actual disk size used by volumes.",
 		}, []string{"collection", "type"})
 
+	VolumeServerScrubCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "scrub_needles_total",
+			Help:      "Counter of needles checked, found corrupt or repaired by the background scrubbing.",
+		}, []string{"collection", "type"})
+
+	VolumeServerRemoteCacheCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "remote_cache_blocks_total",
+			Help:      "Counter of remote tiered file blocks hit, missed, read ahead or evicted in the local block cache.",
+		}, []string{"type"})
+
+	VolumeServerRemoteCacheSizeGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "remote_cache_bytes",
+			Help:      "Bytes of remote tiered file blocks in the local block cache.",
+		})
+
+	VolumeServerReplicationLagGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "replication_lag",
+			Help:      "Number of needle writes not yet caught up by some replicas, and the age in seconds of the oldest one.",
+		}, []string{"collection", "type"})
+
 	VolumeServerResourceGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: Namespace,
@@ -264,6 +296,20 @@ var (
 			Help:      "Bucketed histogram of s3 time to first byte request processing time.",
 			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 27),
 		}, []string{"type", "bucket"})
+	S3RateLimitRejectedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "s3",
+			Name:      "rate_limit_rejected_total",
+			Help:      "Counter of s3 requests rejected by the request rate limits.",
+		}, []string{"scope", "name"})
+	S3RateLimitWaitCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "s3",
+			Name:      "rate_limit_wait_seconds_total",
+			Help:      "Counter of seconds s3 transfers waited for the bandwidth limits.",
+		}, []string{"scope", "name", "direction"})
 )
 
 func init() {
@@ -295,12 +341,18 @@ func init() {
 	Gather.MustRegister(VolumeServerMaxVolumeCounter)
 	Gather.MustRegister(VolumeServerReadOnlyVolumeGauge)
 	Gather.MustRegister(VolumeServerDiskSizeGauge)
+	Gather.MustRegister(VolumeServerReplicationLagGauge)
 	Gather.MustRegister(VolumeServerResourceGauge)
+	Gather.MustRegister(VolumeServerScrubCounter)
+	Gather.MustRegister(VolumeServerRemoteCacheCounter)
+	Gather.MustRegister(VolumeServerRemoteCacheSizeGauge)
 
 	Gather.MustRegister(S3RequestCounter)
 	Gather.MustRegister(S3HandlerCounter)
 	Gather.MustRegister(S3RequestHistogram)
 	Gather.MustRegister(S3TimeToFirstByteHistogram)
+	Gather.MustRegister(S3RateLimitRejectedCounter)
+	Gather.MustRegister(S3RateLimitWaitCounter)
 }
 
 func LoopPushingMetric(name, instance, addr string, intervalSeconds int) {
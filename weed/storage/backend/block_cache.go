@@ -0,0 +1,326 @@
+package backend
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+)
+
+const (
+	blockCacheBlockSize = 1024 * 1024
+	// blocks fetched in the background once the reads of a file turn sequential
+	blockCacheReadAheadBlocks = 4
+	// adjacent reads in a row before reading ahead, so the header and body reads of one needle do not count
+	blockCacheSequentialReads = 2
+)
+
+// RemoteBlockCache caches the blocks of the remote tiered files on the local disk, nil to disable
+var RemoteBlockCache *BlockCache
+
+// BlockCache keeps the recently read blocks of the remote files as local files,
+// and evicts the least recently used blocks when it is over the size limit
+type BlockCache struct {
+	dir       string
+	blockSize int64
+	sizeLimit int64
+
+	sync.Mutex
+	size    int64
+	lru     *list.List // of *cachedBlock, the most recently used in the front
+	blocks  map[string]*list.Element
+	loading map[string]*blockLoad
+}
+
+type cachedBlock struct {
+	name string
+	size int64
+}
+
+// blockLoad lets the concurrent reads of the same missing block wait for one remote read
+type blockLoad struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+func NewBlockCache(dir string, sizeLimit int64) (*BlockCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create block cache dir %s: %v", dir, err)
+	}
+	c := &BlockCache{
+		dir:       dir,
+		blockSize: blockCacheBlockSize,
+		sizeLimit: sizeLimit,
+		lru:       list.New(),
+		blocks:    make(map[string]*list.Element),
+		loading:   make(map[string]*blockLoad),
+	}
+
+	// keep the blocks cached before the restart, the most recently written ones as the most recently used
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read block cache dir %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && isVolumeFile(entry.Name()) {
+			return nil, fmt.Errorf("block cache dir %s holds volume file %s", dir, entry.Name())
+		}
+	}
+	var infos []os.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".blk.tmp") {
+			// left over by a write interrupted by the restart
+			os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".blk") {
+			continue
+		}
+		if info, infoErr := entry.Info(); infoErr == nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	c.Lock()
+	defer c.Unlock()
+	for _, info := range infos {
+		c.blocks[info.Name()] = c.lru.PushFront(&cachedBlock{name: info.Name(), size: info.Size()})
+		c.size += info.Size()
+	}
+	c.evict()
+	glog.V(0).Infof("block cache %s loaded %d blocks of %d bytes", dir, c.lru.Len(), c.size)
+
+	return c, nil
+}
+
+// isVolumeFile tells the files of the volumes, so a volume folder is not used as the cache dir
+func isVolumeFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".dat", ".idx", ".vif", ".ecx", ".ecj", ".cpd", ".cpx", ".ldb":
+		return true
+	}
+	return strings.HasPrefix(filepath.Ext(name), ".ec") && len(filepath.Ext(name)) == 5
+}
+
+// WrapFile reads the remote file through the cache, the key identifies the remote file
+func (c *BlockCache) WrapFile(file BackendStorageFile, key string) BackendStorageFile {
+	if c == nil {
+		return file
+	}
+	return &cachedStorageFile{
+		BackendStorageFile: file,
+		cache:              c,
+		key:                key,
+	}
+}
+
+func (c *BlockCache) blockName(key string, index int64) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%016x_%d.blk", h.Sum64(), index)
+}
+
+// readBlock returns the block from the cache, or reads it from the remote file on a miss
+func (c *BlockCache) readBlock(file BackendStorageFile, key string, index int64) ([]byte, error) {
+	name := c.blockName(key, index)
+	if data, found := c.get(name); found {
+		stats.VolumeServerRemoteCacheCounter.WithLabelValues("hit").Inc()
+		return data, nil
+	}
+
+	c.Lock()
+	load, found := c.loading[name]
+	if found {
+		c.Unlock()
+		// already being read, usually by the read ahead
+		<-load.done
+		stats.VolumeServerRemoteCacheCounter.WithLabelValues("hit").Inc()
+		return load.data, load.err
+	}
+	load = c.startLoad(name)
+	c.Unlock()
+
+	stats.VolumeServerRemoteCacheCounter.WithLabelValues("miss").Inc()
+	c.load(load, file, name, index)
+	return load.data, load.err
+}
+
+// readAhead reads the block in the background if it is neither cached nor being read
+func (c *BlockCache) readAhead(file BackendStorageFile, key string, index int64) {
+	name := c.blockName(key, index)
+	c.Lock()
+	_, cached := c.blocks[name]
+	_, loading := c.loading[name]
+	if cached || loading {
+		c.Unlock()
+		return
+	}
+	load := c.startLoad(name)
+	c.Unlock()
+
+	c.load(load, file, name, index)
+	if load.err != nil {
+		glog.V(1).Infof("read ahead block %s: %v", name, load.err)
+	} else if len(load.data) > 0 {
+		stats.VolumeServerRemoteCacheCounter.WithLabelValues("read_ahead").Inc()
+	}
+}
+
+func (c *BlockCache) get(name string) ([]byte, bool) {
+	c.Lock()
+	elem, found := c.blocks[name]
+	if found {
+		c.lru.MoveToFront(elem)
+	}
+	c.Unlock()
+	if !found {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		// evicted in the meantime
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *BlockCache) startLoad(name string) *blockLoad {
+	load := &blockLoad{done: make(chan struct{})}
+	c.loading[name] = load
+	return load
+}
+
+func (c *BlockCache) load(load *blockLoad, file BackendStorageFile, name string, index int64) {
+	defer func() {
+		c.Lock()
+		delete(c.loading, name)
+		c.Unlock()
+		close(load.done)
+	}()
+
+	offset, length := index*c.blockSize, c.blockSize
+	if datSize, _, err := file.GetStat(); err == nil {
+		if offset >= datSize {
+			return
+		}
+		if offset+length > datSize {
+			length = datSize - offset
+		}
+	}
+	data := make([]byte, length)
+	n, err := file.ReadAt(data, offset)
+	if err != nil && !(err == io.EOF && n > 0) {
+		load.err = err
+		return
+	}
+	load.data = data[:n]
+
+	if err = c.put(name, load.data); err != nil {
+		glog.Warningf("cache block %s: %v", name, err)
+	}
+}
+
+func (c *BlockCache) put(name string, data []byte) error {
+	fileName := filepath.Join(c.dir, name)
+	if err := os.WriteFile(fileName+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(fileName+".tmp", fileName); err != nil {
+		os.Remove(fileName + ".tmp")
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if elem, found := c.blocks[name]; found {
+		c.size -= elem.Value.(*cachedBlock).size
+		c.lru.Remove(elem)
+	}
+	c.blocks[name] = c.lru.PushFront(&cachedBlock{name: name, size: int64(len(data))})
+	c.size += int64(len(data))
+	c.evict()
+	return nil
+}
+
+func (c *BlockCache) evict() {
+	for c.size > c.sizeLimit && c.lru.Len() > 0 {
+		block := c.lru.Remove(c.lru.Back()).(*cachedBlock)
+		delete(c.blocks, block.name)
+		c.size -= block.size
+		if err := os.Remove(filepath.Join(c.dir, block.name)); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("evict cached block %s: %v", block.name, err)
+		}
+		stats.VolumeServerRemoteCacheCounter.WithLabelValues("evict").Inc()
+	}
+	stats.VolumeServerRemoteCacheSizeGauge.Set(float64(c.size))
+}
+
+// cachedStorageFile reads the remote file by blocks through the block cache
+type cachedStorageFile struct {
+	BackendStorageFile
+	cache *BlockCache
+	key   string
+
+	sync.Mutex
+	nextOffset      int64
+	sequentialReads int
+}
+
+func (f *cachedStorageFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	blockSize := f.cache.blockSize
+	f.maybeReadAhead(off, int64(len(p)))
+
+	for n < len(p) {
+		index := (off + int64(n)) / blockSize
+		data, readErr := f.cache.readBlock(f.BackendStorageFile, f.key, index)
+		if readErr != nil {
+			return n, readErr
+		}
+		start := off + int64(n) - index*blockSize
+		if start >= int64(len(data)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[start:])
+	}
+	return n, nil
+}
+
+// maybeReadAhead reads the blocks following this read in the background if the reads are sequential
+func (f *cachedStorageFile) maybeReadAhead(off, length int64) {
+	f.Lock()
+	if off == f.nextOffset {
+		f.sequentialReads++
+	} else {
+		f.sequentialReads = 0
+	}
+	f.nextOffset = off + length
+	sequential := f.sequentialReads >= blockCacheSequentialReads
+	f.Unlock()
+	if !sequential {
+		return
+	}
+
+	lastIndex := (off + length - 1) / f.cache.blockSize
+	go func() {
+		for i := int64(1); i <= blockCacheReadAheadBlocks; i++ {
+			f.cache.readAhead(f.BackendStorageFile, f.key, lastIndex+i)
+		}
+	}()
+}
@@ -0,0 +1,126 @@
+package s3_backend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+)
+
+// fakeS3 serves the ranged reads of one object from memory
+type fakeS3 struct {
+	s3iface.S3API
+	data []byte
+
+	sync.Mutex
+	gets int
+}
+
+func (f *fakeS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	var start, end int
+	if _, err := fmt.Sscanf(*input.Range, "bytes=%d-%d", &start, &end); err != nil {
+		return nil, err
+	}
+	if start >= len(f.data) {
+		return nil, fmt.Errorf("invalid range %s", *input.Range)
+	}
+	if end >= len(f.data) {
+		end = len(f.data) - 1
+	}
+	f.Lock()
+	f.gets++
+	f.Unlock()
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(f.data[start : end+1]))}, nil
+}
+
+func (f *fakeS3) getCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.gets
+}
+
+func TestBlockCache(t *testing.T) {
+	const blockSize = 1024 * 1024
+	data := make([]byte, 5*blockSize+blockSize/2)
+	rand.Read(data)
+	conn := &fakeS3{data: data}
+	storage := &S3BackendStorage{id: "default", bucket: "bucket", conn: conn}
+	tierInfo := &volume_server_pb.VolumeInfo{Files: []*volume_server_pb.RemoteFile{{FileSize: uint64(len(data))}}}
+
+	dir := t.TempDir()
+	cache, err := backend.NewBlockCache(dir, 3*blockSize)
+	assert.NoError(t, err)
+	file := cache.WrapFile(storage.NewStorageFile("1.dat", tierInfo), "s3.default/1.dat")
+
+	readAt := func(off, length int) {
+		p := make([]byte, length)
+		n, err := file.ReadAt(p, int64(off))
+		assert.NoError(t, err)
+		assert.Equal(t, data[off:off+length], p[:n])
+	}
+
+	// repeated reads are served from the cache
+	readAt(blockSize+100, 10)
+	assert.Equal(t, 1, conn.getCount())
+	readAt(blockSize+200, 10)
+	assert.Equal(t, 1, conn.getCount())
+
+	// a read across two blocks only fetches the missing one
+	readAt(2*blockSize-5, 10)
+	assert.Equal(t, 2, conn.getCount())
+
+	// the last block is shorter, reading past the end
+	p := make([]byte, blockSize)
+	n, err := file.ReadAt(p, int64(len(data)-10))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, data[len(data)-10:], p[:n])
+	assert.Equal(t, 3, conn.getCount())
+
+	// the cache stays within its size limit
+	readAt(0, 10)
+	assert.Equal(t, 4, conn.getCount())
+	assert.LessOrEqual(t, cachedBytes(t, dir), int64(3*blockSize))
+	// the least recently used block 1 was evicted
+	readAt(blockSize+1, 10)
+	assert.Equal(t, 5, conn.getCount())
+	readAt(1, 10)
+	assert.Equal(t, 5, conn.getCount())
+
+	// sequential reads fetch the following blocks in the background
+	file = cache.WrapFile(storage.NewStorageFile("1.dat", tierInfo), "s3.default/1.dat")
+	readAt(3*blockSize, 100)
+	readAt(3*blockSize+100, 100)
+	readAt(3*blockSize+200, 100)
+	assert.Eventually(t, func() bool { return conn.getCount() == 8 }, 5*time.Second, 10*time.Millisecond)
+	readAt(4*blockSize, blockSize+blockSize/2)
+	assert.Equal(t, 8, conn.getCount())
+
+	// the cached blocks are kept after a restart
+	cache, err = backend.NewBlockCache(dir, 3*blockSize)
+	assert.NoError(t, err)
+	file = cache.WrapFile(storage.NewStorageFile("1.dat", tierInfo), "s3.default/1.dat")
+	readAt(5*blockSize, 10)
+	assert.Equal(t, 8, conn.getCount())
+}
+
+func cachedBytes(t *testing.T, dir string) (size int64) {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		assert.NoError(t, err)
+		size += info.Size()
+	}
+	return
+}
@@ -228,3 +228,14 @@ func (l *DiskLocation) EcShardCount() int {
 	}
 	return shardCount
 }
+
+// EcShardSlotUnits is the part of the volume slots taken by the ec shards, in erasure_coding.VolumeSlotUnits
+func (l *DiskLocation) EcShardSlotUnits() (units int64) {
+	l.ecVolumesLock.RLock()
+	defer l.ecVolumesLock.RUnlock()
+
+	for _, ecVolume := range l.ecVolumes {
+		units += ecVolume.Scheme.SlotUnits(len(ecVolume.Shards))
+	}
+	return units
+}
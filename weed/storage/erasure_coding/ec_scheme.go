@@ -0,0 +1,82 @@
+package erasure_coding
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+// MaxShardCount is limited by the 32 bits of ShardBits
+const MaxShardCount = 32
+
+// VolumeSlotUnits splits a volume slot to account for the ec shards of any scheme,
+// exactly for the schemes with up to 16 data shards
+const VolumeSlotUnits = 720720
+
+// EcScheme is the number of data shards and parity shards an ec volume is encoded with
+type EcScheme struct {
+	DataShards   int
+	ParityShards int
+}
+
+var DefaultEcScheme = EcScheme{DataShards: DataShardsCount, ParityShards: ParityShardsCount}
+
+func NewEcScheme(dataShards, parityShards int) (EcScheme, error) {
+	if dataShards == 0 && parityShards == 0 {
+		return DefaultEcScheme, nil
+	}
+	scheme := EcScheme{DataShards: dataShards, ParityShards: parityShards}
+	if dataShards <= 0 || parityShards <= 0 || scheme.TotalShards() > MaxShardCount {
+		return scheme, fmt.Errorf("invalid erasure coding scheme %s, at most %d shards in total", scheme, MaxShardCount)
+	}
+	return scheme, nil
+}
+
+// EcSchemeFromVolumeInfo reads the scheme recorded in the .vif file, which is empty for the volumes encoded with the default scheme
+func EcSchemeFromVolumeInfo(volumeInfo *volume_server_pb.VolumeInfo) EcScheme {
+	if volumeInfo == nil || volumeInfo.EcDataShards == 0 || volumeInfo.EcParityShards == 0 {
+		return DefaultEcScheme
+	}
+	return EcScheme{DataShards: int(volumeInfo.EcDataShards), ParityShards: int(volumeInfo.EcParityShards)}
+}
+
+// EcSchemeFromCounts is used for the shard counts in grpc messages, where 0 means the default scheme
+func EcSchemeFromCounts(dataShards, parityShards uint32) EcScheme {
+	if dataShards == 0 || parityShards == 0 {
+		return DefaultEcScheme
+	}
+	return EcScheme{DataShards: int(dataShards), ParityShards: int(parityShards)}
+}
+
+// SlotUnits is the part of a volume slot taken by the shards, each shard holds 1/DataShards of the volume
+func (s EcScheme) SlotUnits(shardCount int) int64 {
+	return (int64(shardCount)*VolumeSlotUnits + int64(s.DataShards) - 1) / int64(s.DataShards)
+}
+
+func (s EcScheme) TotalShards() int {
+	return s.DataShards + s.ParityShards
+}
+
+func (s EcScheme) IsDefault() bool {
+	return s == DefaultEcScheme
+}
+
+func (s EcScheme) String() string {
+	return fmt.Sprintf("%d+%d", s.DataShards, s.ParityShards)
+}
+
+// SaveToVolumeInfo records the scheme in the .vif content, leaving the fields empty for the default scheme
+func (s EcScheme) SaveToVolumeInfo(volumeInfo *volume_server_pb.VolumeInfo) {
+	volumeInfo.EcDataShards, volumeInfo.EcParityShards = 0, 0
+	if !s.IsDefault() {
+		volumeInfo.EcDataShards, volumeInfo.EcParityShards = uint32(s.DataShards), uint32(s.ParityShards)
+	}
+}
+
+// ToCounts returns the shard counts for grpc messages, where 0 means the default scheme
+func (s EcScheme) ToCounts() (dataShards, parityShards uint32) {
+	if s.IsDefault() {
+		return 0, 0
+	}
+	return uint32(s.DataShards), uint32(s.ParityShards)
+}
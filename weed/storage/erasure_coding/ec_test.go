@@ -19,10 +19,16 @@ const (
 )
 
 func TestEncodingDecoding(t *testing.T) {
+	for _, scheme := range []EcScheme{DefaultEcScheme, {DataShards: 16, ParityShards: 4}, {DataShards: 4, ParityShards: 2}} {
+		testEncodingDecoding(t, scheme)
+	}
+}
+
+func testEncodingDecoding(t *testing.T, scheme EcScheme) {
 	bufferSize := 50
 	baseFileName := "1"
 
-	err := generateEcFiles(baseFileName, bufferSize, largeBlockSize, smallBlockSize)
+	err := generateEcFiles(baseFileName, bufferSize, largeBlockSize, smallBlockSize, scheme)
 	if err != nil {
 		t.Logf("generateEcFiles: %v", err)
 	}
@@ -32,16 +38,16 @@ func TestEncodingDecoding(t *testing.T) {
 		t.Logf("WriteSortedFileFromIdx: %v", err)
 	}
 
-	err = validateFiles(baseFileName)
+	err = validateFiles(baseFileName, scheme)
 	if err != nil {
 		t.Logf("WriteSortedFileFromIdx: %v", err)
 	}
 
-	removeGeneratedFiles(baseFileName)
+	removeGeneratedFiles(baseFileName, scheme)
 
 }
 
-func validateFiles(baseFileName string) error {
+func validateFiles(baseFileName string, scheme EcScheme) error {
 	nm, err := readNeedleMap(baseFileName)
 	if err != nil {
 		return fmt.Errorf("readNeedleMap: %v", err)
@@ -59,14 +65,14 @@ func validateFiles(baseFileName string) error {
 		return fmt.Errorf("failed to stat dat file: %v", err)
 	}
 
-	ecFiles, err := openEcFiles(baseFileName, true)
+	ecFiles, err := openEcFiles(baseFileName, true, scheme.TotalShards())
 	if err != nil {
 		return fmt.Errorf("error opening ec files: %w", err)
 	}
 	defer closeEcFiles(ecFiles)
 
 	err = nm.AscendingVisit(func(value needle_map.NeedleValue) error {
-		return assertSame(datFile, fi.Size(), ecFiles, value.Offset, value.Size)
+		return assertSame(datFile, fi.Size(), ecFiles, value.Offset, value.Size, scheme)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to check ec files: %v", err)
@@ -74,14 +80,14 @@ func validateFiles(baseFileName string) error {
 	return nil
 }
 
-func assertSame(datFile *os.File, datSize int64, ecFiles []*os.File, offset types.Offset, size types.Size) error {
+func assertSame(datFile *os.File, datSize int64, ecFiles []*os.File, offset types.Offset, size types.Size, scheme EcScheme) error {
 
 	data, err := readDatFile(datFile, offset, size)
 	if err != nil {
 		return fmt.Errorf("failed to read dat file: %v", err)
 	}
 
-	ecData, err := readEcFile(datSize, ecFiles, offset, size)
+	ecData, err := readEcFile(datSize, ecFiles, offset, size, scheme)
 	if err != nil {
 		return fmt.Errorf("failed to read ec file: %v", err)
 	}
@@ -106,12 +112,12 @@ func readDatFile(datFile *os.File, offset types.Offset, size types.Size) ([]byte
 	return data, nil
 }
 
-func readEcFile(datSize int64, ecFiles []*os.File, offset types.Offset, size types.Size) (data []byte, err error) {
+func readEcFile(datSize int64, ecFiles []*os.File, offset types.Offset, size types.Size, scheme EcScheme) (data []byte, err error) {
 
-	intervals := LocateData(largeBlockSize, smallBlockSize, datSize, offset.ToActualOffset(), size)
+	intervals := LocateData(largeBlockSize, smallBlockSize, datSize, offset.ToActualOffset(), size, scheme.DataShards)
 
 	for i, interval := range intervals {
-		if d, e := readOneInterval(interval, ecFiles); e != nil {
+		if d, e := readOneInterval(interval, ecFiles, scheme); e != nil {
 			return nil, e
 		} else {
 			if i == 0 {
@@ -125,14 +131,14 @@ func readEcFile(datSize int64, ecFiles []*os.File, offset types.Offset, size typ
 	return data, nil
 }
 
-func readOneInterval(interval Interval, ecFiles []*os.File) (data []byte, err error) {
+func readOneInterval(interval Interval, ecFiles []*os.File, scheme EcScheme) (data []byte, err error) {
 
 	ecFileIndex, ecFileOffset := interval.ToShardIdAndOffset(largeBlockSize, smallBlockSize)
 
 	data = make([]byte, interval.Size)
 	err = readFromFile(ecFiles[ecFileIndex], data, ecFileOffset)
 	{ // do some ec testing
-		ecData, err := readFromOtherEcFiles(ecFiles, int(ecFileIndex), ecFileOffset, interval.Size)
+		ecData, err := readFromOtherEcFiles(ecFiles, int(ecFileIndex), ecFileOffset, interval.Size, scheme)
 		if err != nil {
 			return nil, fmt.Errorf("ec reconstruct error: %v", err)
 		}
@@ -143,15 +149,15 @@ func readOneInterval(interval Interval, ecFiles []*os.File) (data []byte, err er
 	return
 }
 
-func readFromOtherEcFiles(ecFiles []*os.File, ecFileIndex int, ecFileOffset int64, size types.Size) (data []byte, err error) {
-	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+func readFromOtherEcFiles(ecFiles []*os.File, ecFileIndex int, ecFileOffset int64, size types.Size, scheme EcScheme) (data []byte, err error) {
+	enc, err := reedsolomon.New(scheme.DataShards, scheme.ParityShards)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	bufs := make([][]byte, TotalShardsCount)
-	for i := 0; i < DataShardsCount; {
-		n := int(rand.Int31n(TotalShardsCount))
+	bufs := make([][]byte, scheme.TotalShards())
+	for i := 0; i < scheme.DataShards; {
+		n := int(rand.Int31n(int32(scheme.TotalShards())))
 		if n == ecFileIndex || bufs[n] != nil {
 			continue
 		}
@@ -181,8 +187,8 @@ func readFromFile(file *os.File, data []byte, ecFileOffset int64) (err error) {
 	return
 }
 
-func removeGeneratedFiles(baseFileName string) {
-	for i := 0; i < DataShardsCount+ParityShardsCount; i++ {
+func removeGeneratedFiles(baseFileName string, scheme EcScheme) {
+	for i := 0; i < scheme.TotalShards(); i++ {
 		fname := fmt.Sprintf("%s.ec%02d", baseFileName, i)
 		os.Remove(fname)
 	}
@@ -190,21 +196,60 @@ func removeGeneratedFiles(baseFileName string) {
 }
 
 func TestLocateData(t *testing.T) {
-	intervals := LocateData(largeBlockSize, smallBlockSize, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize, 1)
+	intervals := LocateData(largeBlockSize, smallBlockSize, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize, 1, DataShardsCount)
 	if len(intervals) != 1 {
 		t.Errorf("unexpected interval size %d", len(intervals))
 	}
-	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1}) {
+	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1, DataShardsCount}) {
 		t.Errorf("unexpected interval %+v", intervals[0])
 	}
 
-	intervals = LocateData(largeBlockSize, smallBlockSize, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize/2+100, DataShardsCount*largeBlockSize+1-DataShardsCount*largeBlockSize/2-100)
+	intervals = LocateData(largeBlockSize, smallBlockSize, DataShardsCount*largeBlockSize+1, DataShardsCount*largeBlockSize/2+100, DataShardsCount*largeBlockSize+1-DataShardsCount*largeBlockSize/2-100, DataShardsCount)
 	fmt.Printf("%+v\n", intervals)
+
+	// the small blocks start after the large block rows of all the data shards
+	intervals = LocateData(largeBlockSize, smallBlockSize, 16*largeBlockSize+1, 16*largeBlockSize+smallBlockSize, 1, 16)
+	if len(intervals) != 1 || !intervals[0].sameAs(Interval{1, 0, 1, false, 1, 16}) {
+		t.Errorf("unexpected intervals %+v", intervals)
+	}
+	shardId, shardOffset := intervals[0].ToShardIdAndOffset(largeBlockSize, smallBlockSize)
+	if shardId != 1 || shardOffset != largeBlockSize {
+		t.Errorf("unexpected shard %d offset %d", shardId, shardOffset)
+	}
+}
+
+func TestNewEcScheme(t *testing.T) {
+	scheme, err := NewEcScheme(16, 4)
+	if err != nil || scheme != (EcScheme{DataShards: 16, ParityShards: 4}) || scheme.TotalShards() != 20 {
+		t.Errorf("unexpected scheme %v: %v", scheme, err)
+	}
+	if scheme, err = NewEcScheme(10, 4); err != nil || !scheme.IsDefault() {
+		t.Errorf("unexpected scheme %v: %v", scheme, err)
+	}
+	for _, counts := range [][2]int{{10, 0}, {0, 4}, {30, 4}, {-1, 4}} {
+		if _, err = NewEcScheme(counts[0], counts[1]); err == nil {
+			t.Errorf("expected error for %v", counts)
+		}
+	}
+}
+
+func TestEcSchemeSlotUnits(t *testing.T) {
+	if units := DefaultEcScheme.SlotUnits(DataShardsCount); units != VolumeSlotUnits {
+		t.Errorf("unexpected slot units %d for the data shards of a volume", units)
+	}
+	// the shards of a 4+2 volume are larger than the default shards
+	if units := (EcScheme{DataShards: 4, ParityShards: 2}).SlotUnits(6); units != VolumeSlotUnits*6/4 {
+		t.Errorf("unexpected slot units %d", units)
+	}
+	if units := (EcScheme{DataShards: 16, ParityShards: 4}).SlotUnits(20); units != VolumeSlotUnits*20/16 {
+		t.Errorf("unexpected slot units %d", units)
+	}
 }
 
 func (this Interval) sameAs(that Interval) bool {
 	return this.IsLargeBlock == that.IsLargeBlock &&
 		this.InnerBlockOffset == that.InnerBlockOffset &&
 		this.BlockIndex == that.BlockIndex &&
-		this.Size == that.Size
+		this.Size == that.Size &&
+		this.DataShards == that.DataShards
 }
@@ -36,6 +36,7 @@ type EcVolume struct {
 	ShardLocationsRefreshTime time.Time
 	ShardLocationsLock        sync.RWMutex
 	Version                   needle.Version
+	Scheme                    EcScheme
 	ecjFile                   *os.File
 	ecjFileAccessLock         sync.Mutex
 	diskType                  types.DiskType
@@ -66,8 +67,10 @@ func NewEcVolume(diskType types.DiskType, dir string, dirIdx string, collection
 
 	// read volume info
 	ev.Version = needle.Version3
+	ev.Scheme = DefaultEcScheme
 	if volumeInfo, _, found, _ := volume_info.MaybeLoadVolumeInfo(dataBaseFileName + ".vif"); found {
 		ev.Version = needle.Version(volumeInfo.Version)
+		ev.Scheme = EcSchemeFromVolumeInfo(volumeInfo)
 	} else {
 		volume_info.SaveVolumeInfo(dataBaseFileName+".vif", &volume_server_pb.VolumeInfo{Version: uint32(ev.Version)})
 	}
@@ -200,6 +203,7 @@ func (ev *EcVolume) ToVolumeEcShardInformationMessage() (messages []*master_pb.V
 				Collection: s.Collection,
 				DiskType:   string(ev.diskType),
 			}
+			m.DataShards, m.ParityShards = ev.Scheme.ToCounts()
 			messages = append(messages, m)
 		}
 		prevVolumeId = s.VolumeId
@@ -223,11 +227,16 @@ func (ev *EcVolume) LocateEcShardNeedle(needleId types.NeedleId, version needle.
 func (ev *EcVolume) LocateEcShardNeedleInterval(version needle.Version, offset int64, size types.Size) (intervals []Interval) {
 	shard := ev.Shards[0]
 	// calculate the locations in the ec shards
-	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, DataShardsCount*shard.ecdFileSize, offset, types.Size(needle.GetActualSize(size, version)))
+	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, int64(ev.Scheme.DataShards)*shard.ecdFileSize, offset, types.Size(needle.GetActualSize(size, version)), ev.Scheme.DataShards)
 
 	return
 }
 
+// WalkIndex calls fn with each entry of the ecx file, including the deleted ones
+func (ev *EcVolume) WalkIndex(fn func(key types.NeedleId, offset types.Offset, size types.Size) error) error {
+	return idx.WalkIndexFile(ev.ecxFile, 0, fn)
+}
+
 func (ev *EcVolume) FindNeedleFromEcx(needleId types.NeedleId) (offset types.Offset, size types.Size, err error) {
 	return SearchNeedleFromSortedIndex(ev.ecxFile, ev.ecxFileSize, needleId, nil)
 }
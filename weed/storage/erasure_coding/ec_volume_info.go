@@ -11,6 +11,12 @@ type EcVolumeInfo struct {
 	Collection string
 	ShardBits  ShardBits
 	DiskType   string
+	Scheme     EcScheme
+
+	// results of the last complete scrub of the shards on the data node
+	ScrubbedAtSecond   int64
+	ScrubCorruptCount  uint64
+	ScrubRepairedCount uint64
 }
 
 func NewEcVolumeInfo(diskType string, collection string, vid needle.VolumeId, shardBits ShardBits) *EcVolumeInfo {
@@ -19,6 +25,7 @@ func NewEcVolumeInfo(diskType string, collection string, vid needle.VolumeId, sh
 		VolumeId:   vid,
 		ShardBits:  shardBits,
 		DiskType:   diskType,
+		Scheme:     DefaultEcScheme,
 	}
 }
 
@@ -42,24 +49,36 @@ func (ecInfo *EcVolumeInfo) ShardIdCount() (count int) {
 	return ecInfo.ShardBits.ShardIdCount()
 }
 
+// SlotUnits is the part of a volume slot taken by the shards, in VolumeSlotUnits
+func (ecInfo *EcVolumeInfo) SlotUnits() int64 {
+	return ecInfo.Scheme.SlotUnits(ecInfo.ShardIdCount())
+}
+
 func (ecInfo *EcVolumeInfo) Minus(other *EcVolumeInfo) *EcVolumeInfo {
 	ret := &EcVolumeInfo{
 		VolumeId:   ecInfo.VolumeId,
 		Collection: ecInfo.Collection,
 		ShardBits:  ecInfo.ShardBits.Minus(other.ShardBits),
 		DiskType:   ecInfo.DiskType,
+		Scheme:     ecInfo.Scheme,
 	}
 
 	return ret
 }
 
 func (ecInfo *EcVolumeInfo) ToVolumeEcShardInformationMessage() (ret *master_pb.VolumeEcShardInformationMessage) {
-	return &master_pb.VolumeEcShardInformationMessage{
+	ret = &master_pb.VolumeEcShardInformationMessage{
 		Id:          uint32(ecInfo.VolumeId),
 		EcIndexBits: uint32(ecInfo.ShardBits),
 		Collection:  ecInfo.Collection,
 		DiskType:    ecInfo.DiskType,
+
+		ScrubbedAtSecond:   ecInfo.ScrubbedAtSecond,
+		ScrubCorruptCount:  ecInfo.ScrubCorruptCount,
+		ScrubRepairedCount: ecInfo.ScrubRepairedCount,
 	}
+	ret.DataShards, ret.ParityShards = ecInfo.Scheme.ToCounts()
+	return ret
 }
 
 type ShardBits uint32 // use bits to indicate the shard id, use 32 bits just for possible future extension
@@ -77,7 +96,7 @@ func (b ShardBits) HasShardId(id ShardId) bool {
 }
 
 func (b ShardBits) ShardIds() (ret []ShardId) {
-	for i := ShardId(0); i < TotalShardsCount; i++ {
+	for i := ShardId(0); i < MaxShardCount; i++ {
 		if b.HasShardId(i) {
 			ret = append(ret, i)
 		}
@@ -86,7 +105,7 @@ func (b ShardBits) ShardIds() (ret []ShardId) {
 }
 
 func (b ShardBits) ToUint32Slice() (ret []uint32) {
-	for i := uint32(0); i < TotalShardsCount; i++ {
+	for i := uint32(0); i < MaxShardCount; i++ {
 		if b.HasShardId(ShardId(i)) {
 			ret = append(ret, i)
 		}
@@ -109,8 +128,8 @@ func (b ShardBits) Plus(other ShardBits) ShardBits {
 	return b | other
 }
 
-func (b ShardBits) MinusParityShards() ShardBits {
-	for i := DataShardsCount; i < TotalShardsCount; i++ {
+func (b ShardBits) MinusParityShards(scheme EcScheme) ShardBits {
+	for i := scheme.DataShards; i < MaxShardCount; i++ {
 		b = b.RemoveShardId(ShardId(i))
 	}
 	return b
@@ -72,6 +72,8 @@ type Store struct {
 	NewEcShardsChan     chan master_pb.VolumeEcShardInformationMessage
 	DeletedEcShardsChan chan master_pb.VolumeEcShardInformationMessage
 	isStopping          bool
+	ecScrubStatus       map[needle.VolumeId]*ScrubStatus
+	ecScrubStatusLock   sync.Mutex
 }
 
 func (s *Store) String() (str string) {
@@ -139,7 +141,7 @@ func (s *Store) findVolume(vid needle.VolumeId) *Volume {
 	return nil
 }
 func (s *Store) FindFreeLocation(diskType DiskType) (ret *DiskLocation) {
-	max := int32(0)
+	max := int64(0)
 	for _, location := range s.Locations {
 		if diskType != location.DiskType {
 			continue
@@ -147,10 +149,10 @@ func (s *Store) FindFreeLocation(diskType DiskType) (ret *DiskLocation) {
 		if location.isDiskSpaceLow {
 			continue
 		}
-		currentFreeCount := location.MaxVolumeCount - int32(location.VolumesLen())
-		currentFreeCount *= erasure_coding.DataShardsCount
-		currentFreeCount -= int32(location.EcShardCount())
-		currentFreeCount /= erasure_coding.DataShardsCount
+		currentFreeCount := int64(location.MaxVolumeCount - int32(location.VolumesLen()))
+		currentFreeCount *= erasure_coding.VolumeSlotUnits
+		currentFreeCount -= location.EcShardSlotUnits()
+		currentFreeCount /= erasure_coding.VolumeSlotUnits
 		if currentFreeCount > max {
 			max = currentFreeCount
 			ret = location
@@ -397,6 +399,9 @@ func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, checkCook
 			return
 		}
 		_, _, isUnchanged, err = v.writeNeedle2(n, checkCookie, fsync && s.isStopping)
+		if err == nil && !isUnchanged {
+			v.heat.recordWrite()
+		}
 		return
 	}
 	glog.V(0).Infoln("volume", i, "not found!")
@@ -409,14 +414,33 @@ func (s *Store) DeleteVolumeNeedle(i needle.VolumeId, n *needle.Needle) (Size, e
 		if v.noWriteOrDelete {
 			return 0, fmt.Errorf("volume %d is read only", i)
 		}
-		return v.deleteNeedle2(n)
+		if v.pendingReplication != nil {
+			// a copy to a lagging replica still in flight would bring the needle back after the deletion
+			v.pendingReplication.catchUpLock.Lock()
+			defer v.pendingReplication.catchUpLock.Unlock()
+		}
+		size, err := v.deleteNeedle2(n)
+		if err == nil {
+			v.heat.recordWrite()
+			if v.pendingReplication != nil {
+				if forgetErr := v.pendingReplication.forget(n.Id); forgetErr != nil {
+					glog.Warningf("volume %d pending replication of deleted needle %s: %v", v.Id, n.Id, forgetErr)
+				}
+			}
+		}
+		return size, err
 	}
 	return 0, fmt.Errorf("volume %d not found on %s:%d", i, s.Ip, s.Port)
 }
 
 func (s *Store) ReadVolumeNeedle(i needle.VolumeId, n *needle.Needle, readOption *ReadOption, onReadSizeFn func(size Size)) (int, error) {
 	if v := s.findVolume(i); v != nil {
-		return v.readNeedle(n, readOption, onReadSizeFn)
+		count, err := v.readNeedle(n, readOption, onReadSizeFn)
+		if err == nil && (readOption == nil || !readOption.IsMetaOnly) {
+			// the meta only reads are followed by ReadVolumeNeedleDataInto
+			v.heat.recordRead()
+		}
+		return count, err
 	}
 	return 0, fmt.Errorf("volume %d not found", i)
 }
@@ -430,7 +454,11 @@ func (s *Store) ReadVolumeNeedleMetaAt(i needle.VolumeId, n *needle.Needle, offs
 
 func (s *Store) ReadVolumeNeedleDataInto(i needle.VolumeId, n *needle.Needle, readOption *ReadOption, writer io.Writer, offset int64, size int64) error {
 	if v := s.findVolume(i); v != nil {
-		return v.readNeedleDataInto(n, readOption, writer, offset, size)
+		err := v.readNeedleDataInto(n, readOption, writer, offset, size)
+		if err == nil {
+			v.heat.recordRead()
+		}
+		return err
 	}
 	return fmt.Errorf("volume %d not found", i)
 }
@@ -51,7 +51,7 @@ func (s *Store) doDeleteNeedleFromAtLeastOneRemoteEcShards(ecVolume *erasure_cod
 		hasDeletionSuccess = true
 	}
 
-	for shardId = erasure_coding.DataShardsCount; shardId < erasure_coding.TotalShardsCount; shardId++ {
+	for shardId = erasure_coding.ShardId(ecVolume.Scheme.DataShards); shardId < erasure_coding.ShardId(ecVolume.Scheme.TotalShards()); shardId++ {
 		if parityDeletionError := s.doDeleteNeedleFromRemoteEcShardServers(shardId, ecVolume, needleId); parityDeletionError == nil {
 			hasDeletionSuccess = true
 		}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+const (
+	replicationCatchUpInterval = 10 * time.Second
+	// bounds how long a deletion of the needle being copied waits
+	replicationCatchUpTimeout = 30 * time.Second
+)
+
+// AddPendingReplication records the replicas which have not acknowledged the write of the needle yet
+func (s *Store) AddPendingReplication(vid needle.VolumeId, key NeedleId, urls []string) error {
+	v := s.findVolume(vid)
+	if v == nil {
+		return fmt.Errorf("volume %d not found on %s:%d", vid, s.Ip, s.Port)
+	}
+	if v.pendingReplication == nil {
+		return fmt.Errorf("volume %d does not track pending replication", vid)
+	}
+	return v.pendingReplication.add(key, urls, time.Now().Unix())
+}
+
+// RemovePendingReplication records that the replica has caught up with the write of the needle
+func (s *Store) RemovePendingReplication(vid needle.VolumeId, key NeedleId, url string) error {
+	v := s.findVolume(vid)
+	if v == nil || v.pendingReplication == nil {
+		return nil
+	}
+	return v.pendingReplication.remove(key, url)
+}
+
+// StartReplicationCatchUp copies the needles written before all replicas acknowledged them
+// to the lagging replicas, and reports the replication lag in the metrics.
+func (s *Store) StartReplicationCatchUp() {
+	for !s.isStopping {
+		lagCounts, lagSeconds := make(map[string]uint64), make(map[string]int64)
+		for _, v := range s.replicatedVolumes() {
+			if s.isStopping {
+				return
+			}
+			if count, _ := v.pendingReplication.status(); count > 0 {
+				s.catchUpVolume(v)
+			}
+			count, sinceSecond := v.pendingReplication.status()
+			lagCounts[v.Collection] += count
+			if count > 0 && time.Now().Unix()-sinceSecond > lagSeconds[v.Collection] {
+				lagSeconds[v.Collection] = time.Now().Unix() - sinceSecond
+			}
+		}
+		for collection, count := range lagCounts {
+			stats.VolumeServerReplicationLagGauge.WithLabelValues(collection, "needles").Set(float64(count))
+			stats.VolumeServerReplicationLagGauge.WithLabelValues(collection, "seconds").Set(float64(lagSeconds[collection]))
+		}
+		time.Sleep(replicationCatchUpInterval)
+	}
+}
+
+func (s *Store) replicatedVolumes() (volumes []*Volume) {
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			if v.pendingReplication != nil && v.NeedToReplicate() {
+				volumes = append(volumes, v)
+			}
+		}
+		location.volumesLock.RUnlock()
+	}
+	return
+}
+
+func (s *Store) catchUpVolume(v *Volume) {
+	locations, err := s.lookupVolumeReplicas(v.Id)
+	if err != nil {
+		glog.V(1).Infof("catch up replicas of volume %d: %v", v.Id, err)
+		return
+	}
+	replicas := make(map[string]pb.ServerAddress)
+	for _, location := range locations {
+		replicas[location.ToHttpAddress()] = location
+	}
+
+	unreachable := make(map[string]bool)
+	recentSecond := time.Now().Add(-replicationCatchUpInterval).Unix()
+	for _, p := range v.pendingReplication.list() {
+		if s.isStopping || p.SinceSecond > recentSecond {
+			// the recent ones are most likely still being replicated by the write requests
+			return
+		}
+		if unreachable[p.Url] {
+			continue
+		}
+		replica, found := replicas[p.Url]
+		if !found {
+			// the replica is moved or deleted, volume.fix.replication takes care of the new copies
+			glog.V(0).Infof("volume %d no longer has a replica on %s, skip catching up needle %s", v.Id, p.Url, p.Key)
+			s.removePendingReplica(v, p)
+			continue
+		}
+		if err = s.catchUpNeedle(v, p, replica); err != nil {
+			glog.V(1).Infof("catch up volume %d needle %s on %s: %v", v.Id, p.Key, p.Url, err)
+			unreachable[p.Url] = true
+		}
+	}
+}
+
+// catchUpNeedle copies the needle to the lagging replica, unless it is deleted since it was queued
+func (s *Store) catchUpNeedle(v *Volume, p pendingReplica, replica pb.ServerAddress) error {
+	v.pendingReplication.catchUpLock.Lock()
+	defer v.pendingReplication.catchUpLock.Unlock()
+
+	if !v.pendingReplication.isPending(p.Key, p.Url) {
+		// deleted or written to the replica in the meantime
+		return nil
+	}
+	blob, size, readErr := v.ReadNeedleBlobById(p.Key)
+	if readErr == ErrorNotFound || readErr == ErrorDeleted {
+		// deleted afterwards, the deletion is replicated synchronously
+		s.removePendingReplica(v, p)
+		return nil
+	}
+	if readErr != nil {
+		glog.Warningf("catch up volume %d needle %s: %v", v.Id, p.Key, readErr)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replicationCatchUpTimeout)
+	defer cancel()
+	err := operation.WithVolumeServerClient(false, replica, s.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, writeErr := client.WriteNeedleBlob(ctx, &volume_server_pb.WriteNeedleBlobRequest{
+			VolumeId:   uint32(v.Id),
+			NeedleId:   uint64(p.Key),
+			Size:       int32(size),
+			NeedleBlob: blob,
+		})
+		return writeErr
+	})
+	if err != nil {
+		return err
+	}
+	s.removePendingReplica(v, p)
+	return nil
+}
+
+func (s *Store) removePendingReplica(v *Volume, p pendingReplica) {
+	if err := v.pendingReplication.remove(p.Key, p.Url); err != nil {
+		glog.Warningf("volume %d pending replication of needle %s on %s: %v", v.Id, p.Key, p.Url, err)
+	}
+}
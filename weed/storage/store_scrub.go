@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	scrubCheckInterval = time.Minute
+	scrubRetryDelay    = time.Hour
+	// the parity shards are verified a block of each shard at a time
+	ecParityScrubBlockSize = int64(erasure_coding.ErasureCodingSmallBlockSize)
+)
+
+// StartScrubbing verifies the checksum of every needle in the local volumes and ec shards once per interval,
+// reading at most bytesPerSecond, and repairs the corrupt needles from a healthy replica or by ec reconstruction.
+func (s *Store) StartScrubbing(interval time.Duration, bytesPerSecond int64) {
+	if interval <= 0 {
+		return
+	}
+	glog.V(0).Infof("scrub volumes every %v at %d bytes per second", interval, bytesPerSecond)
+
+	throttler := util.NewWriteThrottler(bytesPerSecond)
+	firstDelayFn := func() time.Duration {
+		return time.Duration(rand.Int63n(int64(interval)))
+	}
+
+	for !s.isStopping {
+		for _, v := range s.scrubbableVolumes() {
+			if s.isStopping || !v.scrubStatus.isDue(time.Now(), firstDelayFn) {
+				continue
+			}
+			corruptCount, repairedCount, err := s.scrubVolume(v, throttler)
+			if err != nil {
+				glog.Warningf("scrub volume %d: %v", v.Id, err)
+				v.scrubStatus.postpone(time.Now().Add(scrubRetryDelay))
+				continue
+			}
+			v.scrubStatus.finish(time.Now(), interval, corruptCount, repairedCount)
+		}
+
+		ecVolumes := s.EcVolumes()
+		s.retainEcScrubStatus(ecVolumes)
+		for _, ev := range ecVolumes {
+			st := s.getOrCreateEcScrubStatus(ev.VolumeId)
+			if s.isStopping || !st.isDue(time.Now(), firstDelayFn) {
+				continue
+			}
+			corruptCount, repairedCount, err := s.scrubEcVolume(ev, throttler)
+			if err != nil {
+				glog.Warningf("scrub ec volume %d: %v", ev.VolumeId, err)
+				st.postpone(time.Now().Add(scrubRetryDelay))
+				continue
+			}
+			st.finish(time.Now(), interval, corruptCount, repairedCount)
+		}
+
+		time.Sleep(scrubCheckInterval)
+	}
+}
+
+func (s *Store) scrubbableVolumes() (volumes []*Volume) {
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			if !v.HasRemoteFile() {
+				volumes = append(volumes, v)
+			}
+		}
+		location.volumesLock.RUnlock()
+	}
+	return
+}
+
+func (s *Store) scrubVolume(v *Volume, throttler *util.WriteThrottler) (corruptCount, repairedCount uint64, err error) {
+	glog.V(1).Infof("scrub volume %d", v.Id)
+	checked, err := v.ScrubNeedles(throttler, func(key NeedleId, offset Offset, size Size, corruptErr error) {
+		glog.Errorf("scrub volume %d needle %s: %v", v.Id, key, corruptErr)
+		if repairErr := s.repairNeedleFromReplicas(v, key, offset, size); repairErr != nil {
+			glog.Errorf("repair volume %d needle %s: %v", v.Id, key, repairErr)
+			corruptCount++
+			return
+		}
+		glog.V(0).Infof("repaired volume %d needle %s from a replica", v.Id, key)
+		repairedCount++
+	})
+	stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "checked").Add(float64(checked))
+	stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "corrupt").Add(float64(corruptCount + repairedCount))
+	stats.VolumeServerScrubCounter.WithLabelValues(v.Collection, "repaired").Add(float64(repairedCount))
+	return
+}
+
+// repairNeedleFromReplicas overwrites the needle with an intact copy read from one of the other replicas
+func (s *Store) repairNeedleFromReplicas(v *Volume, key NeedleId, offset Offset, size Size) error {
+	if v.ReplicaPlacement == nil || v.ReplicaPlacement.GetCopyCount() <= 1 {
+		return fmt.Errorf("volume %d is not replicated", v.Id)
+	}
+
+	locations, err := s.lookupVolumeReplicas(v.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		var blob []byte
+		err = operation.WithVolumeServerClient(false, location, s.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			resp, readErr := client.ReadNeedleBlob(context.Background(), &volume_server_pb.ReadNeedleBlobRequest{
+				VolumeId: uint32(v.Id),
+				NeedleId: uint64(key),
+			})
+			if readErr != nil {
+				return readErr
+			}
+			blob = resp.NeedleBlob
+			return nil
+		})
+		if err != nil {
+			glog.V(1).Infof("read volume %d needle %s from %s: %v", v.Id, key, location, err)
+			continue
+		}
+		if err = verifyNeedleBlob(blob, key, size, v.Version()); err != nil {
+			glog.V(0).Infof("volume %d needle %s on %s: %v", v.Id, key, location, err)
+			continue
+		}
+		return v.RepairNeedleBlob(key, offset, blob, size)
+	}
+	return fmt.Errorf("no intact copy among %d replicas", len(locations))
+}
+
+func verifyNeedleBlob(blob []byte, key NeedleId, size Size, version needle.Version) error {
+	if int64(len(blob)) != needle.GetActualSize(size, version) {
+		return fmt.Errorf("blob has %d bytes, expected %d", len(blob), needle.GetActualSize(size, version))
+	}
+	n := new(needle.Needle)
+	if err := n.ReadBytes(blob, 0, size, version); err != nil {
+		return err
+	}
+	if n.Id != key {
+		return fmt.Errorf("found needle %s instead of %s", n.Id, key)
+	}
+	return nil
+}
+
+// lookupVolumeReplicas returns the other volume servers holding the volume
+func (s *Store) lookupVolumeReplicas(vid needle.VolumeId) (locations []pb.ServerAddress, err error) {
+	self := util.JoinHostPort(s.Ip, s.Port)
+	err = operation.WithMasterServerClient(false, s.MasterAddress, s.grpcDialOption, func(masterClient master_pb.SeaweedClient) error {
+		resp, err := masterClient.LookupVolume(context.Background(), &master_pb.LookupVolumeRequest{
+			VolumeOrFileIds: []string{vid.String()},
+		})
+		if err != nil {
+			return fmt.Errorf("lookup volume %d: %v", vid, err)
+		}
+		for _, volumeIdLocation := range resp.VolumeIdLocations {
+			if volumeIdLocation.Error != "" {
+				return fmt.Errorf("lookup volume %d: %s", vid, volumeIdLocation.Error)
+			}
+			for _, loc := range volumeIdLocation.Locations {
+				if loc.Url != self {
+					locations = append(locations, pb.NewServerAddressFromLocation(loc))
+				}
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// scrubEcVolume verifies the needles stored at least partially in the local data shards,
+// and the local parity shards by re-encoding each stripe from the data shards.
+func (s *Store) scrubEcVolume(ev *erasure_coding.EcVolume, throttler *util.WriteThrottler) (corruptCount, repairedCount uint64, err error) {
+	glog.V(1).Infof("scrub ec volume %d", ev.VolumeId)
+	defer func() {
+		if err != nil {
+			return
+		}
+		var parityChecked, parityCorrupt, parityRepaired uint64
+		parityChecked, parityCorrupt, parityRepaired, err = s.scrubEcParityShards(ev, throttler)
+		corruptCount, repairedCount = corruptCount+parityCorrupt, repairedCount+parityRepaired
+		stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "ec_parity_checked").Add(float64(parityChecked))
+		stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "ec_corrupt").Add(float64(parityCorrupt + parityRepaired))
+		stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "ec_repaired").Add(float64(parityRepaired))
+	}()
+	var checked int64
+	err = ev.WalkIndex(func(key NeedleId, offset Offset, size Size) error {
+		if s.isStopping {
+			return fmt.Errorf("volume server is stopping")
+		}
+		if offset.IsZero() || size.IsDeleted() || size == 0 {
+			return nil
+		}
+		intervals := ev.LocateEcShardNeedleInterval(ev.Version, offset.ToActualOffset(), size)
+		if !hasLocalEcShardInterval(ev, intervals) {
+			return nil
+		}
+
+		corruptErr := s.verifyEcNeedle(ev, key, offset, size, intervals)
+		checked++
+		throttler.MaybeSlowdown(needle.GetActualSize(size, ev.Version))
+		if corruptErr == nil || corruptErr == ErrorDeleted {
+			return nil
+		}
+
+		glog.Errorf("scrub ec volume %d needle %s: %v", ev.VolumeId, key, corruptErr)
+		if repairErr := s.repairEcNeedle(ev, key, offset, size, intervals); repairErr != nil {
+			glog.Errorf("repair ec volume %d needle %s: %v", ev.VolumeId, key, repairErr)
+			corruptCount++
+			return nil
+		}
+		glog.V(0).Infof("repaired ec volume %d needle %s by reconstruction", ev.VolumeId, key)
+		repairedCount++
+		return nil
+	})
+	stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "ec_checked").Add(float64(checked))
+	stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "ec_corrupt").Add(float64(corruptCount + repairedCount))
+	stats.VolumeServerScrubCounter.WithLabelValues(ev.Collection, "ec_repaired").Add(float64(repairedCount))
+	return
+}
+
+// scrubEcParityShards re-encodes each stripe from the data shards, read locally or from the other servers,
+// and compares the parity with the local parity shards, block by block
+func (s *Store) scrubEcParityShards(ev *erasure_coding.EcVolume, throttler *util.WriteThrottler) (checked, corruptCount, repairedCount uint64, err error) {
+	var parityShards []*erasure_coding.EcVolumeShard
+	for shardId := ev.Scheme.DataShards; shardId < ev.Scheme.TotalShards(); shardId++ {
+		if shard, found := ev.FindEcVolumeShard(erasure_coding.ShardId(shardId)); found {
+			parityShards = append(parityShards, shard)
+		}
+	}
+	if len(parityShards) == 0 {
+		return
+	}
+	if err = s.cachedLookupEcShardLocations(ev); err != nil {
+		return 0, 0, 0, fmt.Errorf("locate ec shards of volume %d: %v", ev.VolumeId, err)
+	}
+	enc, err := reedsolomon.New(ev.Scheme.DataShards, ev.Scheme.ParityShards)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("create encoder: %v", err)
+	}
+
+	shardSize := parityShards[0].Size()
+	for offset := int64(0); offset < shardSize; offset += ecParityScrubBlockSize {
+		if s.isStopping {
+			return checked, corruptCount, repairedCount, fmt.Errorf("volume server is stopping")
+		}
+		blockSize := ecParityScrubBlockSize
+		if remaining := shardSize - offset; remaining < blockSize {
+			blockSize = remaining
+		}
+		stripe := make([][]byte, ev.Scheme.TotalShards())
+		for shardId := range stripe {
+			stripe[shardId] = make([]byte, blockSize)
+			if shardId >= ev.Scheme.DataShards {
+				continue
+			}
+			if err = s.readEcShardBlock(ev, erasure_coding.ShardId(shardId), stripe[shardId], offset); err != nil {
+				return checked, corruptCount, repairedCount, err
+			}
+		}
+		if err = enc.Encode(stripe); err != nil {
+			return checked, corruptCount, repairedCount, fmt.Errorf("encode ec volume %d at %d: %v", ev.VolumeId, offset, err)
+		}
+		throttler.MaybeSlowdown(blockSize * int64(ev.Scheme.DataShards))
+
+		for _, shard := range parityShards {
+			local := make([]byte, blockSize)
+			if _, err = shard.ReadAt(local, offset); err != nil && err != io.EOF {
+				return checked, corruptCount, repairedCount, fmt.Errorf("read ec shard %d.%d: %v", ev.VolumeId, shard.ShardId, err)
+			}
+			err = nil
+			checked++
+			if bytes.Equal(local, stripe[shard.ShardId]) {
+				continue
+			}
+			glog.Errorf("scrub ec volume %d parity shard %d at %d: differs from the encoded data shards", ev.VolumeId, shard.ShardId, offset)
+			if repairErr := s.repairEcParityBlock(ev, shard, stripe, offset); repairErr != nil {
+				glog.Errorf("repair ec shard %d.%d at %d: %v", ev.VolumeId, shard.ShardId, offset, repairErr)
+				corruptCount++
+				continue
+			}
+			glog.V(0).Infof("repaired ec shard %d.%d at %d by encoding", ev.VolumeId, shard.ShardId, offset)
+			repairedCount++
+		}
+	}
+	return
+}
+
+// readEcShardBlock reads the block of a shard, locally or from the servers holding it
+func (s *Store) readEcShardBlock(ev *erasure_coding.EcVolume, shardId erasure_coding.ShardId, buf []byte, offset int64) error {
+	if shard, found := ev.FindEcVolumeShard(shardId); found {
+		if _, err := shard.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return fmt.Errorf("read ec shard %d.%d: %v", ev.VolumeId, shardId, err)
+		}
+		return nil
+	}
+	ev.ShardLocationsLock.RLock()
+	locations := ev.ShardLocations[shardId]
+	ev.ShardLocationsLock.RUnlock()
+	n, _, err := s.readRemoteEcShardInterval(locations, 0, ev.VolumeId, shardId, buf, offset)
+	if err != nil {
+		return err
+	}
+	if n != len(buf) {
+		return fmt.Errorf("read ec shard %d.%d: %d bytes, expected %d", ev.VolumeId, shardId, n, len(buf))
+	}
+	return nil
+}
+
+// repairEcParityBlock overwrites the block of the local parity shard with the encoded one, when another parity shard
+// matches the encoding too, so the data shards are known to be intact
+func (s *Store) repairEcParityBlock(ev *erasure_coding.EcVolume, shard *erasure_coding.EcVolumeShard, stripe [][]byte, offset int64) error {
+	for shardId := ev.Scheme.DataShards; shardId < ev.Scheme.TotalShards(); shardId++ {
+		if erasure_coding.ShardId(shardId) == shard.ShardId {
+			continue
+		}
+		other := make([]byte, len(stripe[shardId]))
+		if err := s.readEcShardBlock(ev, erasure_coding.ShardId(shardId), other, offset); err != nil {
+			glog.V(1).Infof("read ec shard %d.%d at %d: %v", ev.VolumeId, shardId, offset, err)
+			continue
+		}
+		if !bytes.Equal(other, stripe[shardId]) {
+			return fmt.Errorf("parity shard %d does not match the data shards either", shardId)
+		}
+		if _, err := shard.WriteAt(stripe[shard.ShardId], offset); err != nil {
+			return fmt.Errorf("write ec shard %d.%d: %v", ev.VolumeId, shard.ShardId, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no other parity shard to confirm the data shards")
+}
+
+func hasLocalEcShardInterval(ev *erasure_coding.EcVolume, intervals []erasure_coding.Interval) bool {
+	for _, interval := range intervals {
+		shardId, _ := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		if _, found := ev.FindEcVolumeShard(shardId); found {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) verifyEcNeedle(ev *erasure_coding.EcVolume, key NeedleId, offset Offset, size Size, intervals []erasure_coding.Interval) error {
+	data, isDeleted, err := s.readEcShardIntervals(ev.VolumeId, key, ev, intervals)
+	if err != nil {
+		return err
+	}
+	if isDeleted {
+		return ErrorDeleted
+	}
+	n := &needle.Needle{Id: key}
+	if err = n.ReadBytes(data, offset.ToActualOffset(), size, ev.Version); err != nil {
+		return err
+	}
+	if n.Id != key {
+		return fmt.Errorf("found needle %s instead of %s", n.Id, key)
+	}
+	return nil
+}
+
+// repairEcNeedle reconstructs the local parts of the needle from the other shards,
+// and overwrites the ones which differ from the reconstructed data
+func (s *Store) repairEcNeedle(ev *erasure_coding.EcVolume, key NeedleId, offset Offset, size Size, intervals []erasure_coding.Interval) error {
+	rewritten := 0
+	for _, interval := range intervals {
+		shardId, shardOffset := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		shard, found := ev.FindEcVolumeShard(shardId)
+		if !found {
+			continue
+		}
+		local := make([]byte, interval.Size)
+		if _, err := shard.ReadAt(local, shardOffset); err != nil && err != io.EOF {
+			return fmt.Errorf("read ec shard %d.%d: %v", ev.VolumeId, shardId, err)
+		}
+		reconstructed := make([]byte, interval.Size)
+		if _, _, err := s.recoverOneRemoteEcShardInterval(key, ev, shardId, reconstructed, shardOffset); err != nil {
+			return fmt.Errorf("reconstruct ec shard %d.%d: %v", ev.VolumeId, shardId, err)
+		}
+		if bytes.Equal(local, reconstructed) {
+			continue
+		}
+		if _, err := shard.WriteAt(reconstructed, shardOffset); err != nil {
+			return fmt.Errorf("write ec shard %d.%d: %v", ev.VolumeId, shardId, err)
+		}
+		rewritten++
+	}
+	if rewritten == 0 {
+		return fmt.Errorf("the local shards match the reconstruction, the corruption is in a remote shard")
+	}
+	return s.verifyEcNeedle(ev, key, offset, size, intervals)
+}
+
+func (s *Store) findEcScrubStatus(vid needle.VolumeId) *ScrubStatus {
+	s.ecScrubStatusLock.Lock()
+	defer s.ecScrubStatusLock.Unlock()
+	return s.ecScrubStatus[vid]
+}
+
+func (s *Store) getOrCreateEcScrubStatus(vid needle.VolumeId) *ScrubStatus {
+	s.ecScrubStatusLock.Lock()
+	defer s.ecScrubStatusLock.Unlock()
+	if s.ecScrubStatus == nil {
+		s.ecScrubStatus = make(map[needle.VolumeId]*ScrubStatus)
+	}
+	st, found := s.ecScrubStatus[vid]
+	if !found {
+		st = &ScrubStatus{}
+		s.ecScrubStatus[vid] = st
+	}
+	return st
+}
+
+// retainEcScrubStatus forgets the scrub status of the ec volumes which are no longer local
+func (s *Store) retainEcScrubStatus(ecVolumes []*erasure_coding.EcVolume) {
+	local := make(map[needle.VolumeId]bool)
+	for _, ev := range ecVolumes {
+		local[ev.VolumeId] = true
+	}
+	s.ecScrubStatusLock.Lock()
+	defer s.ecScrubStatusLock.Unlock()
+	for vid := range s.ecScrubStatus {
+		if !local[vid] {
+			delete(s.ecScrubStatus, vid)
+		}
+	}
+}
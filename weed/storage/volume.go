@@ -52,6 +52,10 @@ type Volume struct {
 	location   *DiskLocation
 
 	lastIoError error
+
+	scrubStatus        ScrubStatus
+	pendingReplication *pendingReplication
+	heat               VolumeHeat
 }
 
 func NewVolume(dirname string, dirIdx string, collection string, id needle.VolumeId, needleMapKind NeedleMapKind, replicaPlacement *super_block.ReplicaPlacement, ttl *needle.TTL, preallocate int64, memoryMapMaxSizeMb uint32, ldbTimeout int64) (v *Volume, e error) {
@@ -62,6 +66,10 @@ func NewVolume(dirname string, dirIdx string, collection string, id needle.Volum
 	v.needleMapKind = needleMapKind
 	v.ldbTimeout = ldbTimeout
 	e = v.load(true, true, needleMapKind, preallocate)
+	var prErr error
+	if v.pendingReplication, prErr = loadPendingReplication(v.FileName(".rpl")); prErr != nil {
+		glog.Warningf("volume %d failed to load pending replication: %v", id, prErr)
+	}
 	v.startWorker()
 	return
 }
@@ -248,6 +256,9 @@ func (v *Volume) doClose() {
 		v.DataBackend = nil
 		stats.VolumeServerVolumeGauge.WithLabelValues(v.Collection, "volume").Dec()
 	}
+	if v.pendingReplication != nil {
+		v.pendingReplication.close()
+	}
 }
 
 func (v *Volume) NeedToReplicate() bool {
@@ -339,6 +350,12 @@ func (v *Volume) ToVolumeInformationMessage() (types.NeedleId, *master_pb.Volume
 	}
 
 	volumeInfo.RemoteStorageName, volumeInfo.RemoteStorageKey = v.RemoteStorageNameKey()
+	v.scrubStatus.fillVolumeInformationMessage(volumeInfo)
+	v.heat.fillVolumeInformationMessage(volumeInfo, time.Now())
+	v.maybeSaveAccessedAtSecond()
+	if v.pendingReplication != nil {
+		volumeInfo.PendingReplicationCount, volumeInfo.PendingReplicationSinceSecond = v.pendingReplication.status()
+	}
 
 	return maxFileKey, volumeInfo
 }
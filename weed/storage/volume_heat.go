@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+)
+
+const (
+	// the read and write rates decay with this time constant
+	heatRateWindow = 10 * time.Minute
+	// the last access time is saved to the .vif file at most once per this interval
+	heatSaveInterval = time.Hour
+)
+
+// VolumeHeat tracks how often the volume is read and written, reported in the heartbeat for the heat based tiering
+type VolumeHeat struct {
+	reads            int64 // since the last heartbeat
+	writes           int64 // since the last heartbeat
+	accessedAtSecond int64
+	savedAtSecond    int64 // the last access time in the .vif file
+
+	sync.Mutex
+	readPerSecond  float64
+	writePerSecond float64
+	lastUpdate     time.Time
+}
+
+func (h *VolumeHeat) recordRead() {
+	atomic.AddInt64(&h.reads, 1)
+	atomic.StoreInt64(&h.accessedAtSecond, time.Now().Unix())
+}
+
+func (h *VolumeHeat) recordWrite() {
+	atomic.AddInt64(&h.writes, 1)
+	atomic.StoreInt64(&h.accessedAtSecond, time.Now().Unix())
+}
+
+func (h *VolumeHeat) AccessedAtSecond() int64 {
+	return atomic.LoadInt64(&h.accessedAtSecond)
+}
+
+// restoreAccessedAtSecond keeps the last access time saved before a restart
+func (h *VolumeHeat) restoreAccessedAtSecond(accessedAtSecond int64) {
+	atomic.StoreInt64(&h.savedAtSecond, accessedAtSecond)
+	for {
+		current := atomic.LoadInt64(&h.accessedAtSecond)
+		if current >= accessedAtSecond || atomic.CompareAndSwapInt64(&h.accessedAtSecond, current, accessedAtSecond) {
+			return
+		}
+	}
+}
+
+// update folds the reads and writes since the last update into the exponentially decaying rates
+func (h *VolumeHeat) update(now time.Time) (readPerSecond, writePerSecond float64) {
+	h.Lock()
+	defer h.Unlock()
+	reads, writes := atomic.SwapInt64(&h.reads, 0), atomic.SwapInt64(&h.writes, 0)
+	if h.lastUpdate.IsZero() {
+		h.lastUpdate = now
+		return 0, 0
+	}
+	elapsed := now.Sub(h.lastUpdate).Seconds()
+	if elapsed <= 0 {
+		atomic.AddInt64(&h.reads, reads)
+		atomic.AddInt64(&h.writes, writes)
+		return h.readPerSecond, h.writePerSecond
+	}
+	h.lastUpdate = now
+	alpha := 1 - math.Exp(-elapsed/heatRateWindow.Seconds())
+	h.readPerSecond += alpha * (float64(reads)/elapsed - h.readPerSecond)
+	h.writePerSecond += alpha * (float64(writes)/elapsed - h.writePerSecond)
+	return h.readPerSecond, h.writePerSecond
+}
+
+func (h *VolumeHeat) fillVolumeInformationMessage(m *master_pb.VolumeInformationMessage, now time.Time) {
+	readPerSecond, writePerSecond := h.update(now)
+	m.ReadPerSecond = float32(readPerSecond)
+	m.WritePerSecond = float32(writePerSecond)
+	m.AccessedAtSecond = h.AccessedAtSecond()
+}
+
+// isStale tells whether the saved last access time is outdated by more than heatSaveInterval
+func (h *VolumeHeat) isStale(accessedAtSecond int64) bool {
+	return accessedAtSecond-atomic.LoadInt64(&h.savedAtSecond) >= int64(heatSaveInterval/time.Second)
+}
+
+// maybeSaveAccessedAtSecond saves the last access time in the .vif file if it is outdated by more than heatSaveInterval.
+// The volume lock is only taken to rewrite the file, since the tier moves change the remote files in the same file
+func (v *Volume) maybeSaveAccessedAtSecond() {
+	accessedAtSecond := v.heat.AccessedAtSecond()
+	if !v.heat.isStale(accessedAtSecond) {
+		return
+	}
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	if v.volumeInfo == nil || !v.heat.isStale(accessedAtSecond) {
+		return
+	}
+	v.volumeInfo.AccessedAtSecond = accessedAtSecond
+	if err := v.SaveVolumeInfo(); err != nil {
+		glog.Warningf("volume %d failed to save the last access time: %v", v.Id, err)
+		return
+	}
+	atomic.StoreInt64(&v.heat.savedAtSecond, accessedAtSecond)
+}
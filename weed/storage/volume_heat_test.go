@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeHeat(t *testing.T) {
+	var h VolumeHeat
+	start := time.Now()
+	readPerSecond, writePerSecond := h.update(start)
+	assert.Zero(t, readPerSecond)
+	assert.Zero(t, writePerSecond)
+
+	// a steady 10 reads and 1 write per second
+	for i := 1; i <= 60; i++ {
+		for j := 0; j < 600; j++ {
+			h.recordRead()
+		}
+		for j := 0; j < 60; j++ {
+			h.recordWrite()
+		}
+		readPerSecond, writePerSecond = h.update(start.Add(time.Duration(i) * time.Minute))
+	}
+	assert.InDelta(t, 10, readPerSecond, 0.1)
+	assert.InDelta(t, 1, writePerSecond, 0.01)
+	assert.InDelta(t, time.Now().Unix(), h.AccessedAtSecond(), 1)
+
+	// cooling down without any access
+	readPerSecond, _ = h.update(start.Add(2 * time.Hour))
+	assert.Less(t, readPerSecond, 0.1)
+
+	h.restoreAccessedAtSecond(1)
+	assert.InDelta(t, time.Now().Unix(), h.AccessedAtSecond(), 1)
+	assert.True(t, h.isStale(h.AccessedAtSecond()))
+	h.restoreAccessedAtSecond(h.AccessedAtSecond())
+	assert.False(t, h.isStale(h.AccessedAtSecond()))
+}
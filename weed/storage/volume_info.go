@@ -25,6 +25,17 @@ type VolumeInfo struct {
 	ModifiedAtSecond  int64
 	RemoteStorageName string
 	RemoteStorageKey  string
+
+	ScrubbedAtSecond   int64
+	ScrubCorruptCount  uint64
+	ScrubRepairedCount uint64
+
+	PendingReplicationCount       uint64
+	PendingReplicationSinceSecond int64
+
+	ReadPerSecond    float32
+	WritePerSecond   float32
+	AccessedAtSecond int64
 }
 
 func NewVolumeInfo(m *master_pb.VolumeInformationMessage) (vi VolumeInfo, err error) {
@@ -42,6 +53,17 @@ func NewVolumeInfo(m *master_pb.VolumeInformationMessage) (vi VolumeInfo, err er
 		RemoteStorageName: m.RemoteStorageName,
 		RemoteStorageKey:  m.RemoteStorageKey,
 		DiskType:          m.DiskType,
+
+		ScrubbedAtSecond:   m.ScrubbedAtSecond,
+		ScrubCorruptCount:  m.ScrubCorruptCount,
+		ScrubRepairedCount: m.ScrubRepairedCount,
+
+		PendingReplicationCount:       m.PendingReplicationCount,
+		PendingReplicationSinceSecond: m.PendingReplicationSinceSecond,
+
+		ReadPerSecond:    m.ReadPerSecond,
+		WritePerSecond:   m.WritePerSecond,
+		AccessedAtSecond: m.AccessedAtSecond,
 	}
 	rp, e := super_block.NewReplicaPlacementFromByte(byte(m.ReplicaPlacement))
 	if e != nil {
@@ -94,6 +116,17 @@ func (vi VolumeInfo) ToVolumeInformationMessage() *master_pb.VolumeInformationMe
 		RemoteStorageName: vi.RemoteStorageName,
 		RemoteStorageKey:  vi.RemoteStorageKey,
 		DiskType:          vi.DiskType,
+
+		ScrubbedAtSecond:   vi.ScrubbedAtSecond,
+		ScrubCorruptCount:  vi.ScrubCorruptCount,
+		ScrubRepairedCount: vi.ScrubRepairedCount,
+
+		PendingReplicationCount:       vi.PendingReplicationCount,
+		PendingReplicationSinceSecond: vi.PendingReplicationSinceSecond,
+
+		ReadPerSecond:    vi.ReadPerSecond,
+		WritePerSecond:   vi.WritePerSecond,
+		AccessedAtSecond: vi.AccessedAtSecond,
 	}
 }
 
@@ -76,8 +76,15 @@ func SaveVolumeInfo(fileName string, volumeInfo *volume_server_pb.VolumeInfo) er
 		return fmt.Errorf("failed to marshal %s: %v", fileName, marshalErr)
 	}
 
-	if err := util.WriteFile(fileName, text, 0644); err != nil {
-		return fmt.Errorf("failed to write %s: %v", fileName, err)
+	// write a new file and rename it, so a crash never leaves a partial file losing the remote file info
+	tmpFileName := fileName + ".tmp"
+	if err := util.WriteFile(tmpFileName, text, 0644); err != nil {
+		os.Remove(tmpFileName)
+		return fmt.Errorf("failed to write %s: %v", tmpFileName, err)
+	}
+	if err := os.Rename(tmpFileName, fileName); err != nil {
+		os.Remove(tmpFileName)
+		return fmt.Errorf("failed to rename %s: %v", tmpFileName, err)
 	}
 
 	return nil
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// pendingReplication tracks the needles written locally but not yet to some of the replicas,
+// for writes acknowledged before all replicas are written. Every change is appended to the
+// .rpl file, so the background catch-up survives restarts.
+type pendingReplication struct {
+	sync.Mutex
+	fileName   string
+	logFile    *os.File
+	logEntries int
+	// needle id -> replica url -> unix time in seconds when the replica started lagging
+	needles map[NeedleId]map[string]int64
+	// held while a needle is copied to a lagging replica, so a deletion of the needle waits for the copy
+	catchUpLock sync.Mutex
+}
+
+type pendingReplica struct {
+	Key         NeedleId
+	Url         string
+	SinceSecond int64
+}
+
+func loadPendingReplication(fileName string) (*pendingReplication, error) {
+	pr := &pendingReplication{
+		fileName: fileName,
+		needles:  make(map[NeedleId]map[string]int64),
+	}
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return pr, nil
+	}
+	if err != nil {
+		return pr, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var op, url string
+		var key NeedleId
+		var sinceSecond int64
+		if _, err := fmt.Sscanf(scanner.Text(), "%s %d %s %d", &op, &key, &url, &sinceSecond); err != nil {
+			glog.Warningf("skip line %q in %s: %v", scanner.Text(), fileName, err)
+			continue
+		}
+		switch op {
+		case "+":
+			pr.doAdd(key, url, sinceSecond)
+		case "-":
+			pr.doRemove(key, url)
+		}
+		pr.logEntries++
+	}
+	if err = scanner.Err(); err != nil {
+		return pr, fmt.Errorf("read %s: %v", fileName, err)
+	}
+	return pr, nil
+}
+
+func (pr *pendingReplication) doAdd(key NeedleId, url string, sinceSecond int64) {
+	replicas, found := pr.needles[key]
+	if !found {
+		replicas = make(map[string]int64)
+		pr.needles[key] = replicas
+	}
+	if _, found = replicas[url]; !found {
+		replicas[url] = sinceSecond
+	}
+}
+
+func (pr *pendingReplication) doRemove(key NeedleId, url string) bool {
+	replicas, found := pr.needles[key]
+	if !found {
+		return false
+	}
+	if _, found = replicas[url]; !found {
+		return false
+	}
+	delete(replicas, url)
+	if len(replicas) == 0 {
+		delete(pr.needles, key)
+	}
+	return true
+}
+
+func (pr *pendingReplication) appendLog(op string, key NeedleId, url string, sinceSecond int64) (err error) {
+	if pr.logFile == nil {
+		if pr.logFile, err = os.OpenFile(pr.fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+			return fmt.Errorf("open %s: %v", pr.fileName, err)
+		}
+	}
+	if _, err = fmt.Fprintf(pr.logFile, "%s %d %s %d\n", op, key, url, sinceSecond); err != nil {
+		return fmt.Errorf("append to %s: %v", pr.fileName, err)
+	}
+	if err = pr.logFile.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %v", pr.fileName, err)
+	}
+	pr.logEntries++
+	return nil
+}
+
+// add records that the replicas at the urls do not have the needle yet
+func (pr *pendingReplication) add(key NeedleId, urls []string, nowSecond int64) error {
+	pr.Lock()
+	defer pr.Unlock()
+	for _, url := range urls {
+		if err := pr.appendLog("+", key, url, nowSecond); err != nil {
+			return err
+		}
+		pr.doAdd(key, url, nowSecond)
+	}
+	return nil
+}
+
+// remove records that the replica at the url has caught up with the needle
+func (pr *pendingReplication) remove(key NeedleId, url string) error {
+	pr.Lock()
+	defer pr.Unlock()
+	if !pr.doRemove(key, url) {
+		return nil
+	}
+	if err := pr.appendLog("-", key, url, 0); err != nil {
+		return err
+	}
+	return pr.maybeCompact()
+}
+
+// forget drops the pending replicas of a deleted needle, since the deletion is replicated synchronously
+func (pr *pendingReplication) forget(key NeedleId) error {
+	pr.Lock()
+	defer pr.Unlock()
+	for url := range pr.needles[key] {
+		if err := pr.appendLog("-", key, url, 0); err != nil {
+			return err
+		}
+		pr.doRemove(key, url)
+	}
+	return pr.maybeCompact()
+}
+
+func (pr *pendingReplication) isPending(key NeedleId, url string) bool {
+	pr.Lock()
+	defer pr.Unlock()
+	_, found := pr.needles[key][url]
+	return found
+}
+
+// maybeCompact rewrites the log with only the pending entries, once most of the log is obsolete
+func (pr *pendingReplication) maybeCompact() error {
+	pending := pr.doCount()
+	if pr.logEntries < 1024 || pr.logEntries < 4*pending {
+		return nil
+	}
+	if pr.logFile != nil {
+		pr.logFile.Close()
+		pr.logFile = nil
+	}
+	pr.logEntries = 0
+	if pending == 0 {
+		if err := os.Remove(pr.fileName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	tmpFileName := pr.fileName + ".tmp"
+	file, err := os.OpenFile(tmpFileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", tmpFileName, err)
+	}
+	writer := bufio.NewWriter(file)
+	for key, replicas := range pr.needles {
+		for url, sinceSecond := range replicas {
+			fmt.Fprintf(writer, "+ %d %s %d\n", key, url, sinceSecond)
+			pr.logEntries++
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("write %s: %v", tmpFileName, err)
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("sync %s: %v", tmpFileName, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("close %s: %v", tmpFileName, err)
+	}
+	return os.Rename(tmpFileName, pr.fileName)
+}
+
+func (pr *pendingReplication) doCount() (count int) {
+	for _, replicas := range pr.needles {
+		count += len(replicas)
+	}
+	return
+}
+
+// status returns the number of pending needle replicas, and since when the oldest one is lagging
+func (pr *pendingReplication) status() (count uint64, sinceSecond int64) {
+	pr.Lock()
+	defer pr.Unlock()
+	for _, replicas := range pr.needles {
+		for _, since := range replicas {
+			count++
+			if sinceSecond == 0 || since < sinceSecond {
+				sinceSecond = since
+			}
+		}
+	}
+	return
+}
+
+// list returns the pending needle replicas, the oldest first
+func (pr *pendingReplication) list() (pending []pendingReplica) {
+	pr.Lock()
+	for key, replicas := range pr.needles {
+		for url, sinceSecond := range replicas {
+			pending = append(pending, pendingReplica{Key: key, Url: url, SinceSecond: sinceSecond})
+		}
+	}
+	pr.Unlock()
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].SinceSecond != pending[j].SinceSecond {
+			return pending[i].SinceSecond < pending[j].SinceSecond
+		}
+		return pending[i].Key < pending[j].Key
+	})
+	return
+}
+
+func (pr *pendingReplication) close() {
+	pr.Lock()
+	defer pr.Unlock()
+	if pr.logFile != nil {
+		pr.logFile.Close()
+		pr.logFile = nil
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestPendingReplication(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "1.rpl")
+
+	pr, err := loadPendingReplication(fileName)
+	assert.NoError(t, err)
+	assert.NoError(t, pr.add(types.NeedleId(1), []string{"a:8080", "b:8080"}, 100))
+	assert.NoError(t, pr.add(types.NeedleId(2), []string{"b:8080"}, 200))
+	assert.NoError(t, pr.remove(types.NeedleId(1), "a:8080"))
+	// a deleted needle is no longer copied to the lagging replicas
+	assert.NoError(t, pr.add(types.NeedleId(3), []string{"a:8080", "b:8080"}, 150))
+	assert.NoError(t, pr.forget(types.NeedleId(3)))
+	assert.False(t, pr.isPending(types.NeedleId(3), "a:8080"))
+	assert.True(t, pr.isPending(types.NeedleId(2), "b:8080"))
+	pr.close()
+
+	// reloaded from the log
+	pr, err = loadPendingReplication(fileName)
+	assert.NoError(t, err)
+	count, sinceSecond := pr.status()
+	assert.Equal(t, uint64(2), count)
+	assert.Equal(t, int64(100), sinceSecond)
+	assert.Equal(t, []pendingReplica{
+		{Key: 1, Url: "b:8080", SinceSecond: 100},
+		{Key: 2, Url: "b:8080", SinceSecond: 200},
+	}, pr.list())
+
+	// the log is compacted once most of it is obsolete
+	for i := 3; i < 1024; i++ {
+		assert.NoError(t, pr.add(types.NeedleId(i), []string{"c:8080"}, 300))
+	}
+	for i := 3; i < 1024; i++ {
+		assert.NoError(t, pr.remove(types.NeedleId(i), "c:8080"))
+	}
+	assert.True(t, pr.logEntries < 1024)
+	assert.NoError(t, pr.remove(types.NeedleId(1), "b:8080"))
+	assert.NoError(t, pr.remove(types.NeedleId(2), "b:8080"))
+	pr.close()
+
+	pr, err = loadPendingReplication(fileName)
+	assert.NoError(t, err)
+	count, _ = pr.status()
+	assert.Equal(t, uint64(0), count)
+	pr.close()
+
+	_, err = os.Stat(fileName)
+	assert.True(t, err == nil || os.IsNotExist(err))
+}
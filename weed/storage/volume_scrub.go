@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ScrubStatus keeps the results of the last complete scrub, reported in the heartbeat
+type ScrubStatus struct {
+	sync.Mutex
+	nextScrubAt      time.Time
+	scrubbedAtSecond int64
+	corruptCount     uint64
+	repairedCount    uint64
+}
+
+func (st *ScrubStatus) isDue(now time.Time, firstDelayFn func() time.Duration) bool {
+	st.Lock()
+	defer st.Unlock()
+	if st.nextScrubAt.IsZero() {
+		// spread the first scrubs over the interval, instead of scrubbing everything after each restart
+		st.nextScrubAt = now.Add(firstDelayFn())
+	}
+	return !now.Before(st.nextScrubAt)
+}
+
+func (st *ScrubStatus) postpone(nextScrubAt time.Time) {
+	st.Lock()
+	defer st.Unlock()
+	st.nextScrubAt = nextScrubAt
+}
+
+func (st *ScrubStatus) finish(now time.Time, interval time.Duration, corruptCount, repairedCount uint64) {
+	st.Lock()
+	defer st.Unlock()
+	st.nextScrubAt = now.Add(interval)
+	st.scrubbedAtSecond = now.Unix()
+	st.corruptCount = corruptCount
+	st.repairedCount = repairedCount
+}
+
+func (st *ScrubStatus) fillVolumeInformationMessage(m *master_pb.VolumeInformationMessage) {
+	st.Lock()
+	defer st.Unlock()
+	m.ScrubbedAtSecond = st.scrubbedAtSecond
+	m.ScrubCorruptCount = st.corruptCount
+	m.ScrubRepairedCount = st.repairedCount
+}
+
+func (st *ScrubStatus) fillVolumeEcShardInformationMessage(m *master_pb.VolumeEcShardInformationMessage) {
+	st.Lock()
+	defer st.Unlock()
+	m.ScrubbedAtSecond = st.scrubbedAtSecond
+	m.ScrubCorruptCount = st.corruptCount
+	m.ScrubRepairedCount = st.repairedCount
+}
+
+// ScrubNeedles reads every live needle listed in the index and verifies its checksum,
+// calling onCorruptFn with the needles which can not be read back intact.
+func (v *Volume) ScrubNeedles(throttler *util.WriteThrottler, onCorruptFn func(key NeedleId, offset Offset, size Size, err error)) (checked int64, err error) {
+	if v.HasRemoteFile() {
+		return 0, nil
+	}
+
+	v.dataFileAccessLock.RLock()
+	if v.nm == nil {
+		v.dataFileAccessLock.RUnlock()
+		return 0, fmt.Errorf("volume %d is not loaded", v.Id)
+	}
+	indexSize := int64(v.nm.IndexFileSize())
+	compactionRevision := v.SuperBlock.CompactionRevision
+	v.dataFileAccessLock.RUnlock()
+
+	if indexSize%NeedleMapEntrySize != 0 {
+		return 0, fmt.Errorf("index file of volume %d has size %d, maybe corrupted", v.Id, indexSize)
+	}
+
+	for i := int64(0); i < indexSize/NeedleMapEntrySize; i++ {
+		key, offset, size, corruptErr, scrubErr := v.scrubIndexEntry(i, compactionRevision)
+		if scrubErr != nil {
+			return checked, scrubErr
+		}
+		if size == 0 {
+			continue
+		}
+		if corruptErr != nil {
+			onCorruptFn(key, offset, size, corruptErr)
+		}
+		checked++
+		throttler.MaybeSlowdown(needle.GetActualSize(size, v.Version()))
+	}
+	return checked, nil
+}
+
+// scrubIndexEntry verifies the needle of the n-th index entry, returning a zero size if it is no longer live
+func (v *Volume) scrubIndexEntry(n int64, compactionRevision uint16) (key NeedleId, offset Offset, size Size, corruptErr error, err error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil || v.SuperBlock.CompactionRevision != compactionRevision {
+		return 0, offset, 0, nil, fmt.Errorf("volume %d is compacted while scrubbing", v.Id)
+	}
+	key, offset, size, err = v.nm.ReadIndexEntry(n)
+	if err != nil {
+		return 0, offset, 0, nil, fmt.Errorf("read index entry %d of volume %d: %v", n, v.Id, err)
+	}
+	if nv, found := v.nm.Get(key); !found || nv.Offset != offset || nv.Size != size || offset.IsZero() || size.IsDeleted() || size == 0 {
+		// overwritten or deleted later on
+		return key, offset, 0, nil, nil
+	}
+
+	nd := &needle.Needle{Id: key}
+	if corruptErr = nd.ReadData(v.DataBackend, offset.ToActualOffset(), size, v.Version()); corruptErr == nil && nd.Id != key {
+		corruptErr = fmt.Errorf("found needle %s instead of %s", nd.Id, key)
+	}
+	return key, offset, size, corruptErr, nil
+}
+
+// RepairNeedleBlob appends the intact blob of the corrupted needle at the offset and points the index to it,
+// leaving the corrupted copy to the next vacuum, unless the needle has been deleted or overwritten since it was scrubbed
+func (v *Volume) RepairNeedleBlob(needleId NeedleId, offset Offset, needleBlob []byte, size Size) error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	if v.nm == nil {
+		return fmt.Errorf("volume %d is not loaded", v.Id)
+	}
+	if nv, found := v.nm.Get(needleId); !found || nv.Offset != offset || nv.Size != size {
+		return fmt.Errorf("needle %s is deleted or overwritten since scrubbed", needleId)
+	}
+	return v.doWriteNeedleBlob(needleId, needleBlob, size)
+}
+
+// ReadNeedleBlobById reads the raw bytes of the live needle with the given id
+func (v *Volume) ReadNeedleBlobById(needleId NeedleId) ([]byte, Size, error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	nv, found := v.nm.Get(needleId)
+	if !found || nv.Offset.IsZero() {
+		return nil, 0, ErrorNotFound
+	}
+	if nv.Size.IsDeleted() {
+		return nil, 0, ErrorDeleted
+	}
+	blob, err := needle.ReadNeedleBlob(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version())
+	return blob, nv.Size, err
+}
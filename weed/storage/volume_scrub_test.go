@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestScrubNeedles(t *testing.T) {
+	dir, replicaDir := t.TempDir(), t.TempDir()
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+	replica, err := NewVolume(replicaDir, replicaDir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("replica creation: %v", err)
+	}
+	defer replica.Close()
+
+	for i := 1; i <= 10; i++ {
+		n := newRandomNeedle(uint64(i))
+		n.Data = []byte("some data which will be corrupted")
+		n.Checksum = needle.NewCRC(n.Data)
+		for _, vol := range []*Volume{v, replica} {
+			if _, _, _, err := vol.writeNeedle2(n, true, false); err != nil {
+				t.Fatalf("write needle %d: %v", i, err)
+			}
+		}
+	}
+	if _, err := v.doDeleteRequest(newEmptyNeedle(2)); err != nil {
+		t.Fatalf("delete needle 2: %v", err)
+	}
+
+	throttler := util.NewWriteThrottler(0)
+	var corrupted []types.NeedleId
+	onCorruptFn := func(key types.NeedleId, offset types.Offset, size types.Size, err error) {
+		corrupted = append(corrupted, key)
+	}
+
+	checked, err := v.ScrubNeedles(throttler, onCorruptFn)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), checked)
+	assert.Empty(t, corrupted)
+
+	// flip one byte in the data of needle 5
+	nv, _ := v.nm.Get(types.NeedleId(5))
+	_, err = v.DataBackend.WriteAt([]byte{'X'}, nv.Offset.ToActualOffset()+types.NeedleHeaderSize+4)
+	assert.NoError(t, err)
+
+	checked, err = v.ScrubNeedles(throttler, onCorruptFn)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), checked)
+	assert.Equal(t, []types.NeedleId{5}, corrupted)
+
+	// repair the needle with the copy of the replica
+	blob, size, err := replica.ReadNeedleBlobById(types.NeedleId(5))
+	assert.NoError(t, err)
+	assert.Equal(t, nv.Size, size)
+	assert.NoError(t, verifyNeedleBlob(blob, 5, size, v.Version()))
+	assert.Error(t, verifyNeedleBlob(blob, 6, size, v.Version()))
+	assert.Error(t, v.RepairNeedleBlob(2, nv.Offset, blob, size), "needle 2 is deleted")
+	assert.NoError(t, v.RepairNeedleBlob(5, nv.Offset, blob, size))
+	assert.Error(t, v.RepairNeedleBlob(5, nv.Offset, blob, size), "needle 5 is already overwritten by the repair")
+
+	corrupted = nil
+	checked, err = v.ScrubNeedles(throttler, onCorruptFn)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), checked)
+	assert.Empty(t, corrupted)
+
+	_, _, err = v.ReadNeedleBlobById(types.NeedleId(2))
+	assert.Equal(t, ErrorDeleted, err)
+}
@@ -23,6 +23,7 @@ func (v *Volume) maybeLoadVolumeInfo() (found bool) {
 	if v.volumeInfo.Version == 0 {
 		v.volumeInfo.Version = uint32(needle.CurrentVersion)
 	}
+	v.heat.restoreAccessedAtSecond(v.volumeInfo.AccessedAtSecond)
 
 	if v.hasRemoteFile {
 		glog.V(0).Infof("volume %d is tiered to %s as %s and read only", v.Id,
@@ -65,10 +66,19 @@ func (v *Volume) LoadRemoteFile() error {
 		v.DataBackend.Close()
 	}
 
-	v.DataBackend = backendStorage.NewStorageFile(tierFile.Key, v.volumeInfo)
+	v.DataBackend = backend.RemoteBlockCache.WrapFile(backendStorage.NewStorageFile(tierFile.Key, v.volumeInfo),
+		tierFile.BackendName()+"/"+tierFile.Key)
 	return nil
 }
 
+// UpdateVolumeInfo changes the volume info and saves it under the volume lock
+func (v *Volume) UpdateVolumeInfo(update func(volumeInfo *volume_server_pb.VolumeInfo)) error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	update(v.volumeInfo)
+	return v.SaveVolumeInfo()
+}
+
 func (v *Volume) SaveVolumeInfo() error {
 
 	tierFileName := v.FileName(".vif")
@@ -90,6 +90,8 @@ func removeVolumeFiles(filename string) {
 	os.Remove(filename + ".dat")
 	os.Remove(filename + ".idx")
 	os.Remove(filename + ".vif")
+	// needles not yet written to some replicas
+	os.Remove(filename + ".rpl")
 	// sorted index file
 	os.Remove(filename + ".sdx")
 	// compaction
@@ -319,6 +321,10 @@ func (v *Volume) WriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size
 	v.dataFileAccessLock.Lock()
 	defer v.dataFileAccessLock.Unlock()
 
+	return v.doWriteNeedleBlob(needleId, needleBlob, size)
+}
+
+func (v *Volume) doWriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size) error {
 	if MaxPossibleVolumeSize < v.nm.ContentSize()+uint64(len(needleBlob)) {
 		return fmt.Errorf("volume size limit %d exceeded! current size is %d", MaxPossibleVolumeSize, v.nm.ContentSize())
 	}
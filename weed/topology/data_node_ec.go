@@ -26,33 +26,43 @@ func (dn *DataNode) UpdateEcShards(actualShards []*erasure_coding.EcVolumeInfo)
 	existingEcShards := dn.GetEcShards()
 
 	// find out the newShards and deletedShards
-	var newShardCount, deletedShardCount int
 	for _, ecShards := range existingEcShards {
 
 		disk := dn.getOrCreateDisk(ecShards.DiskType)
 		deltaDiskUsages := newDiskUsages()
 		deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(ecShards.DiskType))
 
+		var newShardCount, deletedShardCount int
+		var newSlotUnits, deletedSlotUnits int64
 		vid := ecShards.VolumeId
 		if actualEcShards, ok := actualEcShardMap[vid]; !ok {
 			// dn registered ec shards not found in the new set of ec shards
 			deletedShards = append(deletedShards, ecShards)
 			deletedShardCount += ecShards.ShardIdCount()
+			deletedSlotUnits += ecShards.SlotUnits()
 		} else {
 			// found, but maybe the actual shard could be missing
+			dn.Lock()
+			ecShards.ScrubbedAtSecond = actualEcShards.ScrubbedAtSecond
+			ecShards.ScrubCorruptCount = actualEcShards.ScrubCorruptCount
+			ecShards.ScrubRepairedCount = actualEcShards.ScrubRepairedCount
+			dn.Unlock()
 			a := actualEcShards.Minus(ecShards)
 			if a.ShardIdCount() > 0 {
 				newShards = append(newShards, a)
 				newShardCount += a.ShardIdCount()
+				newSlotUnits += a.SlotUnits()
 			}
 			d := ecShards.Minus(actualEcShards)
 			if d.ShardIdCount() > 0 {
 				deletedShards = append(deletedShards, d)
 				deletedShardCount += d.ShardIdCount()
+				deletedSlotUnits += d.SlotUnits()
 			}
 		}
 
 		deltaDiskUsage.ecShardCount = int64(newShardCount - deletedShardCount)
+		deltaDiskUsage.ecShardSlotUnits = newSlotUnits - deletedSlotUnits
 		disk.UpAdjustDiskUsageDelta(deltaDiskUsages)
 
 	}
@@ -68,6 +78,7 @@ func (dn *DataNode) UpdateEcShards(actualShards []*erasure_coding.EcVolumeInfo)
 		deltaDiskUsages := newDiskUsages()
 		deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(ecShards.DiskType))
 		deltaDiskUsage.ecShardCount = int64(ecShards.ShardIdCount())
+		deltaDiskUsage.ecShardSlotUnits = ecShards.SlotUnits()
 		disk.UpAdjustDiskUsageDelta(deltaDiskUsages)
 	}
 
@@ -53,6 +53,7 @@ func (d *DiskUsages) negative() *DiskUsages {
 		a.remoteVolumeCount = -b.remoteVolumeCount
 		a.activeVolumeCount = -b.activeVolumeCount
 		a.ecShardCount = -b.ecShardCount
+		a.ecShardSlotUnits = -b.ecShardSlotUnits
 		a.maxVolumeCount = -b.maxVolumeCount
 
 	}
@@ -98,6 +99,8 @@ type DiskUsageCounts struct {
 	activeVolumeCount int64
 	ecShardCount      int64
 	maxVolumeCount    int64
+	// the volume slots taken by the ec shards of all schemes, in erasure_coding.VolumeSlotUnits
+	ecShardSlotUnits int64
 }
 
 func (a *DiskUsageCounts) addDiskUsageCounts(b *DiskUsageCounts) {
@@ -105,13 +108,14 @@ func (a *DiskUsageCounts) addDiskUsageCounts(b *DiskUsageCounts) {
 	atomic.AddInt64(&a.remoteVolumeCount, b.remoteVolumeCount)
 	atomic.AddInt64(&a.activeVolumeCount, b.activeVolumeCount)
 	atomic.AddInt64(&a.ecShardCount, b.ecShardCount)
+	atomic.AddInt64(&a.ecShardSlotUnits, b.ecShardSlotUnits)
 	atomic.AddInt64(&a.maxVolumeCount, b.maxVolumeCount)
 }
 
 func (a *DiskUsageCounts) FreeSpace() int64 {
 	freeVolumeSlotCount := a.maxVolumeCount + a.remoteVolumeCount - a.volumeCount
 	if a.ecShardCount > 0 {
-		freeVolumeSlotCount = freeVolumeSlotCount - a.ecShardCount/erasure_coding.DataShardsCount - 1
+		freeVolumeSlotCount = freeVolumeSlotCount - a.ecShardSlotUnits/erasure_coding.VolumeSlotUnits - 1
 	}
 	return freeVolumeSlotCount
 }
@@ -122,6 +126,7 @@ func (a *DiskUsageCounts) minus(b *DiskUsageCounts) *DiskUsageCounts {
 		remoteVolumeCount: a.remoteVolumeCount - b.remoteVolumeCount,
 		activeVolumeCount: a.activeVolumeCount - b.activeVolumeCount,
 		ecShardCount:      a.ecShardCount - b.ecShardCount,
+		ecShardSlotUnits:  a.ecShardSlotUnits - b.ecShardSlotUnits,
 		maxVolumeCount:    a.maxVolumeCount - b.maxVolumeCount,
 	}
 }
@@ -19,19 +19,22 @@ func (d *Disk) AddOrUpdateEcShard(s *erasure_coding.EcVolumeInfo) {
 	d.ecShardsLock.Lock()
 	defer d.ecShardsLock.Unlock()
 
-	delta := 0
+	delta, deltaSlotUnits := 0, int64(0)
 	if existing, ok := d.ecShards[s.VolumeId]; !ok {
 		d.ecShards[s.VolumeId] = s
 		delta = s.ShardBits.ShardIdCount()
+		deltaSlotUnits = s.SlotUnits()
 	} else {
-		oldCount := existing.ShardBits.ShardIdCount()
+		oldCount, oldSlotUnits := existing.ShardBits.ShardIdCount(), existing.SlotUnits()
 		existing.ShardBits = existing.ShardBits.Plus(s.ShardBits)
 		delta = existing.ShardBits.ShardIdCount() - oldCount
+		deltaSlotUnits = existing.SlotUnits() - oldSlotUnits
 	}
 
 	deltaDiskUsages := newDiskUsages()
 	deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(string(d.Id())))
 	deltaDiskUsage.ecShardCount = int64(delta)
+	deltaDiskUsage.ecShardSlotUnits = deltaSlotUnits
 	d.UpAdjustDiskUsageDelta(deltaDiskUsages)
 
 }
@@ -41,13 +44,15 @@ func (d *Disk) DeleteEcShard(s *erasure_coding.EcVolumeInfo) {
 	defer d.ecShardsLock.Unlock()
 
 	if existing, ok := d.ecShards[s.VolumeId]; ok {
-		oldCount := existing.ShardBits.ShardIdCount()
+		oldCount, oldSlotUnits := existing.ShardBits.ShardIdCount(), existing.SlotUnits()
 		existing.ShardBits = existing.ShardBits.Minus(s.ShardBits)
 		delta := existing.ShardBits.ShardIdCount() - oldCount
+		deltaSlotUnits := existing.SlotUnits() - oldSlotUnits
 
 		deltaDiskUsages := newDiskUsages()
 		deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(string(d.Id())))
 		deltaDiskUsage.ecShardCount = int64(delta)
+		deltaDiskUsage.ecShardSlotUnits = deltaSlotUnits
 		d.UpAdjustDiskUsageDelta(deltaDiskUsages)
 
 		if existing.ShardBits.ShardIdCount() == 0 {
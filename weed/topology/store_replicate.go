@@ -22,7 +22,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/util/buffer_pool"
 )
 
-func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOption, s *storage.Store, volumeId needle.VolumeId, n *needle.Needle, r *http.Request, contentMd5 string) (isUnchanged bool, err error) {
+func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOption, s *storage.Store, volumeId needle.VolumeId, n *needle.Needle, r *http.Request, contentMd5 string, consistencyConfig *WriteConsistencyConfig) (isUnchanged bool, err error) {
 
 	//check JWT
 	jwt := security.GetJwt(r)
@@ -44,7 +44,19 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOpt
 		fsync = true
 	}
 
-	if s.GetVolume(volumeId) != nil {
+	// read consistency value, the replicas can only catch up from a local copy
+	consistency := WriteConsistencyAll
+	v := s.GetVolume(volumeId)
+	if v != nil {
+		consistency = consistencyConfig.For(v.Collection)
+		if requested := r.FormValue("consistency"); requested != "" {
+			if consistency, err = ParseWriteConsistency(requested); err != nil {
+				return
+			}
+		}
+	}
+
+	if v != nil {
 		start := time.Now()
 		isUnchanged, err = s.WriteVolumeNeedle(volumeId, n, true, fsync)
 		stats.VolumeServerRequestHistogram.WithLabelValues(stats.WriteToLocalDisk).Observe(time.Since(start).Seconds())
@@ -58,59 +70,29 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOpt
 
 	if len(remoteLocations) > 0 { //send to other replica locations
 		start := time.Now()
-		err = DistributedOperation(remoteLocations, func(location operation.Location) error {
-			u := url.URL{
-				Scheme: "http",
-				Host:   location.Url,
-				Path:   r.URL.Path,
-			}
-			q := url.Values{
-				"type": {"replicate"},
-				"ttl":  {n.Ttl.String()},
-			}
-			if n.LastModified > 0 {
-				q.Set("ts", strconv.FormatUint(n.LastModified, 10))
-			}
-			if n.IsChunkedManifest() {
-				q.Set("cm", "true")
-			}
-			u.RawQuery = q.Encode()
-
-			pairMap := make(map[string]string)
-			if n.HasPairs() {
-				tmpMap := make(map[string]string)
-				err := json.Unmarshal(n.Pairs, &tmpMap)
-				if err != nil {
-					stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorUnmarshalPairs).Inc()
-					glog.V(0).Infoln("Unmarshal pairs error:", err)
-				}
-				for k, v := range tmpMap {
-					pairMap[needle.PairNamePrefix+k] = v
-				}
-			}
-			bytesBuffer := buffer_pool.SyncPoolGetBuffer()
-			defer buffer_pool.SyncPoolPutBuffer(bytesBuffer)
-
-			// volume server do not know about encryption
-			// TODO optimize here to compress data only once
-			uploadOption := &operation.UploadOption{
-				UploadUrl:         u.String(),
-				Filename:          string(n.Name),
-				Cipher:            false,
-				IsInputCompressed: n.IsCompressed(),
-				MimeType:          string(n.Mime),
-				PairMap:           pairMap,
-				Jwt:               jwt,
-				Md5:               contentMd5,
-				BytesBuffer:       bytesBuffer,
-			}
-
-			_, err := operation.UploadData(n.Data, uploadOption)
-			if err != nil {
-				glog.Errorf("replication-UploadData, err:%v, url:%s", err, u.String())
-			}
-			return err
-		})
+		if consistency == WriteConsistencyAll {
+			err = DistributedOperation(remoteLocations, replicateNeedleFn(n, n.Data, r.URL.Path, jwt, contentMd5))
+		} else {
+			// the request buffer is reused once the request is answered, while the replicas may still be written
+			data := make([]byte, len(n.Data))
+			copy(data, n.Data)
+			required := consistency.RequiredCopies(len(remoteLocations)+1) - 1
+			err = QuorumDistributedOperation(remoteLocations, required, replicateNeedleFn(n, data, r.URL.Path, jwt, contentMd5),
+				func(lagging []operation.Location) {
+					var urls []string
+					for _, location := range lagging {
+						urls = append(urls, location.Url)
+					}
+					if addErr := s.AddPendingReplication(volumeId, n.Id, urls); addErr != nil {
+						glog.Errorf("failed to track pending replication of %d,%s on %v: %v", volumeId, n.Id, urls, addErr)
+					}
+				},
+				func(location operation.Location) {
+					if removeErr := s.RemovePendingReplication(volumeId, n.Id, location.Url); removeErr != nil {
+						glog.Errorf("failed to track replication of %d,%s on %s: %v", volumeId, n.Id, location.Url, removeErr)
+					}
+				})
+		}
 		stats.VolumeServerRequestHistogram.WithLabelValues(stats.WriteToReplicas).Observe(time.Since(start).Seconds())
 		if err != nil {
 			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorWriteToReplicas).Inc()
@@ -122,6 +104,68 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOpt
 	return
 }
 
+// replicateNeedleFn uploads the needle with the data to a replica.
+// Everything needed from the needle is copied before returning.
+func replicateNeedleFn(n *needle.Needle, data []byte, path string, jwt security.EncodedJwt, contentMd5 string) func(location operation.Location) error {
+	q := url.Values{
+		"type": {"replicate"},
+		"ttl":  {n.Ttl.String()},
+	}
+	if n.LastModified > 0 {
+		q.Set("ts", strconv.FormatUint(n.LastModified, 10))
+	}
+	if n.IsChunkedManifest() {
+		q.Set("cm", "true")
+	}
+	rawQuery := q.Encode()
+
+	pairMap := make(map[string]string)
+	if n.HasPairs() {
+		tmpMap := make(map[string]string)
+		err := json.Unmarshal(n.Pairs, &tmpMap)
+		if err != nil {
+			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorUnmarshalPairs).Inc()
+			glog.V(0).Infoln("Unmarshal pairs error:", err)
+		}
+		for k, v := range tmpMap {
+			pairMap[needle.PairNamePrefix+k] = v
+		}
+	}
+	filename, mimeType, isCompressed := string(n.Name), string(n.Mime), n.IsCompressed()
+
+	return func(location operation.Location) error {
+		u := url.URL{
+			Scheme:   "http",
+			Host:     location.Url,
+			Path:     path,
+			RawQuery: rawQuery,
+		}
+
+		bytesBuffer := buffer_pool.SyncPoolGetBuffer()
+		defer buffer_pool.SyncPoolPutBuffer(bytesBuffer)
+
+		// volume server do not know about encryption
+		// TODO optimize here to compress data only once
+		uploadOption := &operation.UploadOption{
+			UploadUrl:         u.String(),
+			Filename:          filename,
+			Cipher:            false,
+			IsInputCompressed: isCompressed,
+			MimeType:          mimeType,
+			PairMap:           pairMap,
+			Jwt:               jwt,
+			Md5:               contentMd5,
+			BytesBuffer:       bytesBuffer,
+		}
+
+		_, err := operation.UploadData(data, uploadOption)
+		if err != nil {
+			glog.Errorf("replication-UploadData, err:%v, url:%s", err, u.String())
+		}
+		return err
+	}
+}
+
 func ReplicatedDelete(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOption, store *storage.Store, volumeId needle.VolumeId, n *needle.Needle, r *http.Request) (size types.Size, err error) {
 
 	//check JWT
@@ -189,6 +233,61 @@ func DistributedOperation(locations []operation.Location, op func(location opera
 	return ret.Error()
 }
 
+// QuorumDistributedOperation runs op on all locations, and returns once required of them succeed,
+// or once too many of them failed to reach required. The locations not succeeded by then are passed to
+// onLagging before returning, and the ones succeeding afterwards are passed to onCaughtUp.
+func QuorumDistributedOperation(locations []operation.Location, required int, op func(location operation.Location) error,
+	onLagging func(lagging []operation.Location), onCaughtUp func(location operation.Location)) error {
+	length := len(locations)
+	// buffered, so the remaining operations do not block after returning
+	results := make(chan RemoteResult, length)
+	for _, location := range locations {
+		go func(location operation.Location, results chan RemoteResult) {
+			results <- RemoteResult{location.Url, op(location)}
+		}(location, results)
+	}
+	ret := DistributedOperationResult(make(map[string]error))
+	succeeded, received := 0, 0
+	for succeeded < required && received-succeeded <= length-required {
+		result := <-results
+		received++
+		ret[result.Host] = result.Error
+		if result.Error == nil {
+			succeeded++
+		}
+	}
+
+	var lagging []operation.Location
+	locationByUrl := make(map[string]operation.Location)
+	for _, location := range locations {
+		if err, found := ret[location.Url]; !found || err != nil {
+			lagging = append(lagging, location)
+			locationByUrl[location.Url] = location
+		}
+	}
+	if len(lagging) > 0 {
+		onLagging(lagging)
+	}
+	if received < length {
+		go func() {
+			for i := received; i < length; i++ {
+				result := <-results
+				if result.Error == nil {
+					onCaughtUp(locationByUrl[result.Host])
+				}
+			}
+		}()
+	}
+
+	if succeeded < required {
+		if err := ret.Error(); err != nil {
+			return err
+		}
+		return fmt.Errorf("only %d of %d required replicas succeeded", succeeded, required)
+	}
+	return nil
+}
+
 func GetWritableRemoteReplications(s *storage.Store, grpcDialOption grpc.DialOption, volumeId needle.VolumeId, masterFn operation.GetMasterFn) (remoteLocations []operation.Location, err error) {
 
 	v := s.GetVolume(volumeId)
@@ -10,19 +10,24 @@ import (
 
 type EcShardLocations struct {
 	Collection string
-	Locations  [erasure_coding.TotalShardsCount][]*DataNode
+	Scheme     erasure_coding.EcScheme
+	Locations  [erasure_coding.MaxShardCount][]*DataNode
 }
 
 func (t *Topology) SyncDataNodeEcShards(shardInfos []*master_pb.VolumeEcShardInformationMessage, dn *DataNode) (newShards, deletedShards []*erasure_coding.EcVolumeInfo) {
 	// convert into in memory struct storage.VolumeInfo
 	var shards []*erasure_coding.EcVolumeInfo
 	for _, shardInfo := range shardInfos {
-		shards = append(shards,
-			erasure_coding.NewEcVolumeInfo(
-				shardInfo.DiskType,
-				shardInfo.Collection,
-				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits)))
+		shard := erasure_coding.NewEcVolumeInfo(
+			shardInfo.DiskType,
+			shardInfo.Collection,
+			needle.VolumeId(shardInfo.Id),
+			erasure_coding.ShardBits(shardInfo.EcIndexBits))
+		shard.ScrubbedAtSecond = shardInfo.ScrubbedAtSecond
+		shard.ScrubCorruptCount = shardInfo.ScrubCorruptCount
+		shard.ScrubRepairedCount = shardInfo.ScrubRepairedCount
+		shard.Scheme = erasure_coding.EcSchemeFromCounts(shardInfo.DataShards, shardInfo.ParityShards)
+		shards = append(shards, shard)
 	}
 	// find out the delta volumes
 	newShards, deletedShards = dn.UpdateEcShards(shards)
@@ -39,12 +44,13 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 	// convert into in memory struct storage.VolumeInfo
 	var newShards, deletedShards []*erasure_coding.EcVolumeInfo
 	for _, shardInfo := range newEcShards {
-		newShards = append(newShards,
-			erasure_coding.NewEcVolumeInfo(
-				shardInfo.DiskType,
-				shardInfo.Collection,
-				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits)))
+		shard := erasure_coding.NewEcVolumeInfo(
+			shardInfo.DiskType,
+			shardInfo.Collection,
+			needle.VolumeId(shardInfo.Id),
+			erasure_coding.ShardBits(shardInfo.EcIndexBits))
+		shard.Scheme = erasure_coding.EcSchemeFromCounts(shardInfo.DataShards, shardInfo.ParityShards)
+		newShards = append(newShards, shard)
 	}
 	for _, shardInfo := range deletedEcShards {
 		deletedShards = append(deletedShards,
@@ -66,9 +72,10 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 	return
 }
 
-func NewEcShardLocations(collection string) *EcShardLocations {
+func NewEcShardLocations(collection string, scheme erasure_coding.EcScheme) *EcShardLocations {
 	return &EcShardLocations{
 		Collection: collection,
+		Scheme:     scheme,
 	}
 }
 
@@ -105,7 +112,7 @@ func (t *Topology) RegisterEcShards(ecShardInfos *erasure_coding.EcVolumeInfo, d
 
 	locations, found := t.ecShardMap[ecShardInfos.VolumeId]
 	if !found {
-		locations = NewEcShardLocations(ecShardInfos.Collection)
+		locations = NewEcShardLocations(ecShardInfos.Collection, ecShardInfos.Scheme)
 		t.ecShardMap[ecShardInfos.VolumeId] = locations
 	}
 	for _, shardId := range ecShardInfos.ShardIds() {
@@ -0,0 +1,86 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WriteConsistency is how many copies of a replicated write must succeed before it is acknowledged
+type WriteConsistency string
+
+const (
+	// WriteConsistencyAll waits for every replica
+	WriteConsistencyAll WriteConsistency = "all"
+	// WriteConsistencyQuorum waits for a majority of the copies, including the local one
+	WriteConsistencyQuorum WriteConsistency = "quorum"
+	// WriteConsistencyOne only waits for the local copy, and the replicas catch up asynchronously
+	WriteConsistencyOne WriteConsistency = "one"
+)
+
+func ParseWriteConsistency(s string) (WriteConsistency, error) {
+	switch c := WriteConsistency(strings.ToLower(strings.TrimSpace(s))); c {
+	case WriteConsistencyAll, WriteConsistencyQuorum, WriteConsistencyOne:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown write consistency %q, expecting all, quorum or one", s)
+}
+
+// RequiredCopies returns how many of the total copies must be written
+func (c WriteConsistency) RequiredCopies(total int) int {
+	switch c {
+	case WriteConsistencyQuorum:
+		return total/2 + 1
+	case WriteConsistencyOne:
+		return 1
+	}
+	return total
+}
+
+// WriteConsistencyConfig is the default write consistency, optionally overridden per collection
+type WriteConsistencyConfig struct {
+	Default     WriteConsistency
+	Collections map[string]WriteConsistency
+}
+
+// ParseWriteConsistencyConfig parses the default level followed by the collection overrides,
+// e.g. "quorum,logs=one,images=all"
+func ParseWriteConsistencyConfig(s string) (*WriteConsistencyConfig, error) {
+	config := &WriteConsistencyConfig{
+		Default:     WriteConsistencyAll,
+		Collections: make(map[string]WriteConsistency),
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		collection, level, isOverride := strings.Cut(part, "=")
+		if !isOverride {
+			level = collection
+		}
+		c, err := ParseWriteConsistency(level)
+		if err != nil {
+			return nil, err
+		}
+		if isOverride {
+			config.Collections[strings.TrimSpace(collection)] = c
+		} else {
+			config.Default = c
+		}
+	}
+	return config, nil
+}
+
+// For returns the write consistency of the collection
+func (config *WriteConsistencyConfig) For(collection string) WriteConsistency {
+	if config == nil {
+		return WriteConsistencyAll
+	}
+	if c, found := config.Collections[collection]; found {
+		return c
+	}
+	if config.Default == "" {
+		return WriteConsistencyAll
+	}
+	return config.Default
+}
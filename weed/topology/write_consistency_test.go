@@ -0,0 +1,94 @@
+package topology
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+)
+
+func TestParseWriteConsistencyConfig(t *testing.T) {
+	config, err := ParseWriteConsistencyConfig("quorum, logs=one,images=ALL")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for collection, expected := range map[string]WriteConsistency{
+		"":       WriteConsistencyQuorum,
+		"other":  WriteConsistencyQuorum,
+		"logs":   WriteConsistencyOne,
+		"images": WriteConsistencyAll,
+	} {
+		if c := config.For(collection); c != expected {
+			t.Errorf("collection %q: expected %s, got %s", collection, expected, c)
+		}
+	}
+
+	if config, err = ParseWriteConsistencyConfig(""); err != nil || config.For("any") != WriteConsistencyAll {
+		t.Errorf("empty config should default to all: %v %v", config, err)
+	}
+	if _, err = ParseWriteConsistencyConfig("logs=two"); err == nil {
+		t.Errorf("expected an error for an unknown level")
+	}
+
+	for total, expected := range map[int]int{1: 1, 2: 2, 3: 2, 4: 3, 5: 3} {
+		if required := WriteConsistencyQuorum.RequiredCopies(total); required != expected {
+			t.Errorf("quorum of %d copies: expected %d, got %d", total, expected, required)
+		}
+	}
+}
+
+func TestQuorumDistributedOperation(t *testing.T) {
+	locations := []operation.Location{{Url: "fast:8080"}, {Url: "slow:8080"}, {Url: "down:8080"}}
+	release := make(chan struct{})
+	op := func(location operation.Location) error {
+		switch location.Url {
+		case "slow:8080":
+			<-release
+		case "down:8080":
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+
+	var lock sync.Mutex
+	var lagging []string
+	caughtUp := make(chan string, len(locations))
+	onLagging := func(locations []operation.Location) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, location := range locations {
+			lagging = append(lagging, location.Url)
+		}
+	}
+	onCaughtUp := func(location operation.Location) {
+		caughtUp <- location.Url
+	}
+
+	// require one replica besides the local copy, so the write succeeds with the fast replica alone
+	if err := QuorumDistributedOperation(locations, 1, op, onLagging, onCaughtUp); err != nil {
+		t.Fatalf("quorum: %v", err)
+	}
+	lock.Lock()
+	if len(lagging) != 2 {
+		t.Errorf("expected the slow and the down replicas to lag, got %v", lagging)
+	}
+	lock.Unlock()
+
+	close(release)
+	select {
+	case url := <-caughtUp:
+		if url != "slow:8080" {
+			t.Errorf("expected the slow replica to catch up, got %s", url)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the slow replica did not catch up")
+	}
+
+	// the down replica makes it impossible to write all 3 replicas
+	lagging = nil
+	if err := QuorumDistributedOperation(locations, 3, op, onLagging, onCaughtUp); err == nil {
+		t.Errorf("expected an error without enough replicas")
+	}
+}
@@ -100,6 +100,18 @@ func (vp *ViperProxy) GetStringSlice(key string) []string {
 	return vp.Viper.GetStringSlice(key)
 }
 
+func (vp *ViperProxy) GetStringMapString(key string) map[string]string {
+	vp.Lock()
+	defer vp.Unlock()
+	return vp.Viper.GetStringMapString(key)
+}
+
+func (vp *ViperProxy) GetStringMap(key string) map[string]interface{} {
+	vp.Lock()
+	defer vp.Unlock()
+	return vp.Viper.GetStringMap(key)
+}
+
 func GetViper() *ViperProxy {
 	vp.Lock()
 	defer vp.Unlock()
@@ -66,3 +66,15 @@ func CanonicalizeETag(etag string) string {
 	canonicalETag := strings.TrimPrefix(etag, "\"")
 	return strings.TrimSuffix(canonicalETag, "\"")
 }
+
+// MatchETag tells whether the etag is in the comma separated list of an If-Match or If-None-Match header.
+// "*" matches any etag, which should only be checked if the resource exists.
+func MatchETag(list string, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || CanonicalizeETag(candidate) == CanonicalizeETag(etag) {
+			return true
+		}
+	}
+	return false
+}